@@ -35,6 +35,14 @@ func main() {
 
 	versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
 
+	replayCmd := flag.NewFlagSet("replay", flag.ExitOnError)
+	replayFile := replayCmd.String("capture", "", "Capture file (pcapng or jsonl)")
+	replayTarget := replayCmd.String("target", "", "Send the captured requests to this host:port")
+	replayServe := replayCmd.String("serve", "", "Answer like the captured device on this address instead")
+	replaySpeed := replayCmd.Float64("speed", 0, "Pace of the replay: 1 = as recorded, 0 = as fast as answered")
+	replayTimeout := replayCmd.Duration("timeout", 5*time.Second, "Timeout per request")
+	replayJSON := replayCmd.Bool("json", false, "Print the result as JSON")
+
 	// Parse command
 	if len(os.Args) < 2 {
 		printUsage()
@@ -48,6 +56,12 @@ func main() {
 			os.Exit(1)
 		}
 		runServer(*configFile, *port)
+	case "replay":
+		if err := replayCmd.Parse(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to parse replay command: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(runReplay(*replayFile, *replayTarget, *replayServe, *replaySpeed, *replayTimeout, *replayJSON))
 	case "version":
 		if err := versionCmd.Parse(os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to parse version command: %v\n", err)
@@ -64,12 +78,26 @@ func printUsage() {
 	fmt.Println("ModBridge CLI - Modbus TCP Proxy Manager")
 	fmt.Println("\nUsage:")
 	fmt.Println("  cli server [options]    Start the ModBridge server")
+	fmt.Println("  cli replay [options]    Replay a capture against a device, or serve it as a mock")
 	fmt.Println("  cli version             Show version information")
 	fmt.Println("\nServer Options:")
 	fmt.Println("  -config string")
 	fmt.Println("        Configuration file path")
 	fmt.Println("  -port int")
 	fmt.Println("        Server port (default 8080)")
+	fmt.Println("\nReplay Options:")
+	fmt.Println("  -capture string")
+	fmt.Println("        Capture file (pcapng or jsonl)")
+	fmt.Println("  -target string")
+	fmt.Println("        Send the captured requests to this host:port")
+	fmt.Println("  -serve string")
+	fmt.Println("        Answer like the captured device on this address instead")
+	fmt.Println("  -speed float")
+	fmt.Println("        Pace of the replay: 1 = as recorded, 0 = as fast as answered (default 0)")
+	fmt.Println("  -timeout duration")
+	fmt.Println("        Timeout per request (default 5s)")
+	fmt.Println("  -json")
+	fmt.Println("        Print the result as JSON")
 }
 
 func runServer(configFile string, port int) {
@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"modbridge/pkg/capture"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runReplay replays a capture against target, or with serve set answers
+// like the captured device until interrupted. It returns the exit code: 1
+// when the replay could not run, 2 when an answer differed from the capture.
+func runReplay(file, target, serve string, speed float64, timeout time.Duration, asJSON bool) int {
+	if file == "" || (target == "") == (serve == "") {
+		fmt.Fprintln(os.Stderr, "replay needs -capture and either -target or -serve")
+		return 1
+	}
+	frames, err := capture.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read capture: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if serve != "" {
+		mock := capture.NewMock(frames)
+		if err := mock.Listen(serve); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to listen: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Answering %d captured requests on %s, Ctrl+C to stop\n", len(capture.ClientExchanges(frames)), mock.Addr())
+		<-ctx.Done()
+		mock.Close()
+		return 0
+	}
+
+	result, err := capture.Replay(ctx, frames, target, capture.ReplayOptions{Speed: speed, Timeout: timeout})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay failed after %d requests: %v\n", result.Sent, err)
+		return 1
+	}
+	if asJSON {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Sent %d requests: %d answered as captured, %d differently, %d not at all\n", result.Sent, result.Matched, result.Differed, result.Failed)
+		fmt.Printf("Latency: mean %v, max %v\n", result.MeanLatency, result.MaxLatency)
+		for _, m := range result.Mismatches {
+			fmt.Printf("#%d request %s\n    want %s\n    got  %s", m.Index, m.Request, m.Want, m.Got)
+			if m.Error != "" {
+				fmt.Printf(" (%s)", m.Error)
+			}
+			fmt.Println()
+		}
+	}
+	if result.Differed > 0 || result.Failed > 0 {
+		return 2
+	}
+	return 0
+}
@@ -142,9 +142,13 @@ func main() {
 // No authentication is required. The surface exposes no secrets and supports
 // no state mutations. If a deploy needs network-layer protection, bind to
 // localhost or use a reverse proxy / firewall.
+//
+// In a cluster the heartbeats of the other nodes arrive here as well, see
+// registerClusterHandlers.
 func startReadOnlyHTTP(addr string, mgr *manager.Manager, l *logger.Logger) *http.Server {
 	mux := http.NewServeMux()
 	registerReadOnlyHandlers(mux, mgr, l)
+	registerClusterHandlers(mux, mgr)
 
 	srv := &http.Server{
 		Addr:              addr,
@@ -192,11 +196,11 @@ func registerReadOnlyHandlers(mux *http.ServeMux, mgr *manager.Manager, _ *logge
 		})
 	}))
 
-	// A single shared Metrics registry collects nothing automatically; proxies
-	// report via their own stats. We expose a minimal process-level scrape so
-	// Prometheus has something to pull even before per-proxy instrumentation
-	// is wired. This keeps /metrics non-empty and useful out of the box.
-	_ = metrics.NewMetrics()
+	// The per-proxy series are the very ones the full server serves on
+	// /api/metrics, read from the proxies through the same provider, so one
+	// dashboard fits both binaries. The process series come on top.
+	exporter := metrics.NewMetrics()
+	exporter.SetDiagnosticsProvider(mgr.ProxyDiagnostics)
 	mux.HandleFunc("/metrics", getOnly(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
 		var mem runtime.MemStats
@@ -213,6 +217,7 @@ func registerReadOnlyHandlers(mux *http.ServeMux, mgr *manager.Manager, _ *logge
 		fmt.Fprintf(w, "# HELP modbridge_headless_proxies_total Total proxies configured.\n")
 		fmt.Fprintf(w, "# TYPE modbridge_headless_proxies_total gauge\n")
 		fmt.Fprintf(w, "modbridge_headless_proxies_total %d\n", len(mgr.GetProxies()))
+		fmt.Fprint(w, exporter.GetPrometheusMetrics())
 	}))
 
 	mux.HandleFunc("/status", getOnly(func(w http.ResponseWriter, r *http.Request) {
@@ -221,6 +226,7 @@ func registerReadOnlyHandlers(mux *http.ServeMux, mgr *manager.Manager, _ *logge
 			"version":        version,
 			"uptime_seconds": int(time.Since(startTime).Seconds()),
 			"proxies":        mgr.GetProxies(),
+			"cluster":        mgr.ClusterStats(),
 		})
 	}))
 
@@ -229,3 +235,24 @@ func registerReadOnlyHandlers(mux *http.ServeMux, mgr *manager.Manager, _ *logge
 		w.WriteHeader(http.StatusNoContent)
 	}))
 }
+
+// registerClusterHandlers wires the heartbeats between the nodes of a cluster:
+// POST /cluster/join and GET /cluster/members. They are signed with the
+// cluster's secret and change nothing but what this node knows about the
+// others. Without a cluster they answer 404.
+func registerClusterHandlers(mux *http.ServeMux, mgr *manager.Manager) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c := mgr.Cluster()
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/cluster/members" {
+			c.HandleMembers(w, r)
+			return
+		}
+		c.HandleJoin(w, r)
+	}
+	mux.HandleFunc("/cluster/join", handler)
+	mux.HandleFunc("/cluster/members", handler)
+}
@@ -7,6 +7,7 @@ package main
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -93,6 +94,49 @@ func TestMetricsEndpoint_PrometheusFormat(t *testing.T) {
 	}
 }
 
+// TestMetricsEndpoint_ProxySeries verifies that /metrics carries the same
+// per-proxy families as the full server's /api/metrics.
+func TestMetricsEndpoint_ProxySeries(t *testing.T) {
+	mgr := newTestManager(t)
+	if err := mgr.AddProxy(config.ProxyConfig{
+		ID:                "proxy-a",
+		Name:              "Proxy A",
+		ListenAddr:        ":15021",
+		TargetAddr:        "127.0.0.1:502",
+		ConnectionTimeout: 5,
+		ReadTimeout:       5,
+	}, false); err != nil {
+		t.Fatalf("AddProxy: %v", err)
+	}
+	log, _ := logger.NewLogger("headless-test.log", 100)
+	defer log.Close()
+	srv := newTestServer(mgr, log)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	body := string(raw)
+	for _, want := range []string{
+		`modbridge_proxy_requests_total{proxy_id="proxy-a"} 0`,
+		`modbridge_proxy_request_duration_seconds_bucket{proxy_id="proxy-a",le="+Inf"} 0`,
+		`modbridge_proxy_circuit_breaker_state{proxy_id="proxy-a",state="closed"} 1`,
+		`modbridge_proxy_target_connections{proxy_id="proxy-a",state="idle"}`,
+		`modbridge_proxy_pacing_wait_seconds_total{proxy_id="proxy-a"} 0`,
+		"# TYPE modbridge_proxy_exceptions_total counter",
+	} {
+		if !contains(body, want) {
+			t.Errorf("metrics body missing %q", want)
+		}
+	}
+}
+
 func TestStatusEndpoint_ReturnsProxies(t *testing.T) {
 	mgr := newTestManager(t)
 	log, _ := logger.NewLogger("headless-test.log", 100)
@@ -306,7 +306,10 @@ const de = {
         noProbeKnown: 'Noch keine Leseanfrage gesehen und keine angegeben: lass einen Client einmal abfragen oder nenne ein Register zum Antasten.',
         proxyIdRequired: 'Kein Proxy angegeben.',
         proxyNotFound: 'Proxy nicht gefunden.',
-        alreadyRunning: 'Für diesen Proxy läuft bereits eine Messung.'
+        alreadyRunning: 'Für diesen Proxy läuft bereits eine Messung.',
+        serialNotMeasured: 'Eine serielle Leitung wird nicht vermessen: ihr Takt folgt aus der Baudrate, und der Mindestabstand zwischen zwei Frames ist fest vorgegeben.',
+        routesNotMeasured: 'Dieser Proxy leitet nur über seine Unit-ID-Routen weiter und hat kein eigenes Ziel, das sich vermessen ließe.',
+        targetsNotMeasured: 'Dieser Proxy verteilt seine Anfragen auf mehrere Ziele; vermessen Sie jedes davon als eigenen Proxy.'
       },
       calibrateGap: 'Abstand',
       calibrateConnections: 'Verbindungen',
@@ -806,7 +809,10 @@ const en = {
         noProbeKnown: 'No read request observed yet and none supplied: let a client poll once, or name a register to probe.',
         proxyIdRequired: 'No proxy given.',
         proxyNotFound: 'Proxy not found.',
-        alreadyRunning: 'A measurement is already running for this proxy.'
+        alreadyRunning: 'A measurement is already running for this proxy.',
+        serialNotMeasured: 'A serial line is not measured: its pace follows from the baud rate, and the silence between two frames is fixed.',
+        routesNotMeasured: 'This proxy only forwards over its unit-ID routes and has no target of its own to measure.',
+        targetsNotMeasured: 'This proxy spreads its requests over several targets; measure each of them as a proxy of its own.'
       },
       calibrateGap: 'Spacing',
       calibrateConnections: 'Connections',
@@ -3,287 +3,649 @@
 // Created by Xerolux
 // https://github.com/Xerolux/modbridge
 
+// Package alerting watches the metrics of the proxies with rules the
+// operator sets up, such as "error rate above 5 % over 5 minutes", and raises
+// an alert when one is broken. An alert stays open until its condition
+// clears or someone resolves it, and is kept in the database with who
+// acknowledged and resolved it.
 package alerting
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Errors of the rule and alert operations.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrResolved = errors.New("alert is already resolved")
+	ErrPending  = errors.New("delivery is still being tried")
+)
+
+// ValidationError is a rule or webhook that cannot be stored as it is.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Events an alert goes through, as sent to webhooks.
+const (
+	EventFired        = "alert.fired"
+	EventAcknowledged = "alert.acknowledged"
+	EventResolved     = "alert.resolved"
 )
 
-// Manager handles alerting
+// Sample is what the alerting reads of a proxy at one moment. The counters
+// count from when the proxy was created.
+type Sample struct {
+	Running        bool
+	Requests       int64 // Requests answered
+	Errors         int64 // Requests that failed
+	PollFailures   int64 // Failed refreshes of the background poller
+	StaleResponses int64 // Late target responses that were discarded
+	LatencyP95     time.Duration
+	CircuitOpen    bool
+	Clients        int64 // Connected clients
+}
+
+// Source reads the metrics of every proxy, keyed by proxy ID.
+type Source interface {
+	Samples() map[string]Sample
+}
+
+// Config holds the settings of the alerting.
+type Config struct {
+	Interval  time.Duration // Between two evaluations of the rules (0 = 15 s)
+	Retention time.Duration // How long resolved alerts are kept (0 = 90 days)
+	// Mail sends an alert that fired or was resolved to the operator, for
+	// rules with the email action. Nil sends nothing.
+	Mail func(event string, alert database.Alert)
+	// A webhook delivery that fails is tried again after RetryDelay (0 =
+	// 30 s), then after twice as long each time, up to an hour, until
+	// MaxAttempts (0 = 8) have failed.
+	RetryDelay  time.Duration
+	MaxAttempts int
+}
+
+// timedSample is a sample with the time it was taken.
+type timedSample struct {
+	at time.Time
+	Sample
+}
+
+// Manager evaluates the rules against the proxies on a goroutine of its own
+// and keeps rules, webhooks and alerts in the database.
 type Manager struct {
-	mu          sync.RWMutex
-	rules       map[string]*AlertRule
-	webhooks    map[string]*WebhookConfig
-	alertBuffer chan *Alert
-	webhookSem  chan struct{}  // bounds concurrent webhook deliveries
-	wg          sync.WaitGroup // tracks in-flight webhook goroutines
-	sendMu      sync.Mutex     // guards alertBuffer sends vs Close
-	closed      bool
-	stopOnce    sync.Once
-}
-
-// AlertRule represents an alert rule
-type AlertRule struct {
-	ID            string            `json:"id"`
-	Name          string            `json:"name"`
-	Description   string            `json:"description"`
-	Enabled       bool              `json:"enabled"`
-	RuleType      string            `json:"rule_type"`
-	Condition     string            `json:"condition"`
-	Threshold     float64           `json:"threshold_value"`
-	TimeWindow    int               `json:"time_window"`
-	Severity      string            `json:"severity"`
-	Actions       []string          `json:"actions"`
-	Cooldown      int               `json:"cooldown_seconds"`
-	LastTriggered time.Time         `json:"last_triggered"`
-	CreatedBy     string            `json:"created_by"`
-	Metadata      map[string]string `json:"metadata"`
-}
-
-// WebhookConfig represents a webhook configuration
-type WebhookConfig struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	URL       string            `json:"url"`
-	Secret    string            `json:"secret"`
-	Events    []string          `json:"events"`
-	Enabled   bool              `json:"enabled"`
-	Headers   map[string]string `json:"headers"`
-	CreatedAt time.Time         `json:"created_at"`
-}
-
-// Alert represents an alert
-type Alert struct {
-	RuleID    string                 `json:"rule_id"`
-	Severity  string                 `json:"severity"`
-	Message   string                 `json:"message"`
-	Details   map[string]interface{} `json:"details"`
-	Timestamp time.Time              `json:"timestamp"`
-}
-
-// NewManager creates a new alerting manager
-func NewManager() *Manager {
-	m := &Manager{
-		rules:       make(map[string]*AlertRule),
-		webhooks:    make(map[string]*WebhookConfig),
-		alertBuffer: make(chan *Alert, 1000),
-		webhookSem:  make(chan struct{}, 16), // cap concurrent webhook deliveries
-	}
-	m.wg.Add(1)
-	go m.processAlerts()
-	return m
-}
-
-// Close stops the background alert processor and waits for in-flight webhook
-// deliveries to finish. It is safe to call multiple times.
-func (m *Manager) Close() {
-	m.stopOnce.Do(func() {
-		m.sendMu.Lock()
-		m.closed = true
-		close(m.alertBuffer)
-		m.sendMu.Unlock()
-	})
-	m.wg.Wait()
+	db     *database.DB
+	source Source
+	cfg    Config
+	log    *logger.Logger
+	client *http.Client
+
+	mu            sync.Mutex
+	rules         map[string]*Rule
+	webhooks      map[string]*Webhook
+	open          map[string]*database.Alert // Firing or acknowledged alerts by alertKey
+	lastFired     map[string]time.Time       // By alertKey, for the cooldown
+	breachedSince map[string]time.Time       // By alertKey, for a condition that must hold for the window
+	samples       map[string][]timedSample   // Recent samples by proxy ID, oldest first
+	lastEval      time.Time
+	evaluations   int64
+	stopped       bool
+
+	delivered        int64         // Webhook deliveries that went through
+	deliveryFailures int64         // Webhook attempts that failed
+	webhookSem       chan struct{} // Bounds concurrent webhook deliveries
+	wake             chan struct{} // Tells the delivery loop there is a delivery due
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// NewManager creates the alerting. It evaluates nothing until Start.
+func NewManager(db *database.DB, source Source, cfg Config, log *logger.Logger) *Manager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 15 * time.Second
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 90 * 24 * time.Hour
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = 30 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	return &Manager{
+		db:            db,
+		source:        source,
+		cfg:           cfg,
+		log:           log,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		rules:         make(map[string]*Rule),
+		webhooks:      make(map[string]*Webhook),
+		open:          make(map[string]*database.Alert),
+		lastFired:     make(map[string]time.Time),
+		breachedSince: make(map[string]time.Time),
+		samples:       make(map[string][]timedSample),
+		webhookSem:    make(chan struct{}, 16),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Start loads the rules, the webhooks and the open alerts, and evaluates
+// the rules and makes the webhook deliveries in the background until Stop.
+func (m *Manager) Start() error {
+	if err := m.load(); err != nil {
+		return err
+	}
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.wg.Add(2)
+	go m.run(ctx)
+	go m.runDeliveries(ctx)
+	return nil
 }
 
-// AddRule adds an alert rule
-func (m *Manager) AddRule(rule *AlertRule) {
+// Stop stops evaluating and delivering. Deliveries cut short are made
+// after the next Start.
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.rules[rule.ID] = rule
+	m.stopped = true
+	m.mu.Unlock()
 }
 
-// RemoveRule removes an alert rule
-func (m *Manager) RemoveRule(id string) {
+// load reads what the database holds. An alert left open by the last run
+// stays open, and resolves once its condition is found cleared.
+func (m *Manager) load() error {
+	rules, err := m.db.AlertRules()
+	if err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	webhooks, err := m.db.AlertWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	open, err := m.db.QueryAlerts(database.AlertFilter{States: []string{database.AlertFiring, database.AlertAcknowledged}})
+	if err != nil {
+		return fmt.Errorf("failed to load open alerts: %w", err)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.rules, id)
+	for _, data := range rules {
+		var r Rule
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			m.log.Warn("ALERT", fmt.Sprintf("Skipping unreadable alert rule: %v", err))
+			continue
+		}
+		m.rules[r.ID] = &r
+	}
+	for _, data := range webhooks {
+		var wh Webhook
+		if err := json.Unmarshal([]byte(data), &wh); err != nil {
+			m.log.Warn("ALERT", fmt.Sprintf("Skipping unreadable webhook: %v", err))
+			continue
+		}
+		m.webhooks[wh.ID] = &wh
+	}
+	for _, a := range open {
+		key := alertKey(a.RuleID, a.ProxyID)
+		m.open[key] = a
+		m.lastFired[key] = a.FiredAt
+	}
+	return nil
 }
 
-// GetRules returns all alert rules
-func (m *Manager) GetRules() []*AlertRule {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *Manager) run(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	m.cleanup()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.evaluate(now)
+		case <-cleanup.C:
+			m.cleanup()
+		}
+	}
+}
 
-	rules := make([]*AlertRule, 0, len(m.rules))
-	for _, rule := range m.rules {
-		rules = append(rules, rule)
+// cleanup removes resolved alerts and dead letters past the retention.
+func (m *Manager) cleanup() {
+	cutoff := time.Now().Add(-m.cfg.Retention)
+	n, err := m.db.DeleteResolvedAlerts(cutoff)
+	if err != nil {
+		m.log.Warn("ALERT", fmt.Sprintf("Failed to remove old alerts: %v", err))
+	} else if n > 0 {
+		m.log.Info("ALERT", fmt.Sprintf("Removed %d resolved alerts older than %v", n, m.cfg.Retention))
+	}
+	n, err = m.db.DeleteFailedWebhookDeliveries(cutoff)
+	if err != nil {
+		m.log.Warn("ALERT", fmt.Sprintf("Failed to remove old webhook deliveries: %v", err))
+	} else if n > 0 {
+		m.log.Info("ALERT", fmt.Sprintf("Removed %d dead letters older than %v", n, m.cfg.Retention))
 	}
-	return rules
 }
 
-// AddWebhook adds a webhook configuration
-func (m *Manager) AddWebhook(webhook *WebhookConfig) {
+// Stats reports how many rules and open alerts there are, when the rules
+// were last evaluated and how the webhook deliveries fare.
+func (m *Manager) Stats() map[string]interface{} {
+	deliveries, err := m.db.CountWebhookDeliveries()
+	if err != nil {
+		deliveries = map[string]int64{}
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.webhooks[webhook.ID] = webhook
+	enabled := 0
+	for _, r := range m.rules {
+		if r.Enabled {
+			enabled++
+		}
+	}
+	stats := map[string]interface{}{
+		"enabled":       true,
+		"rules":         len(m.rules),
+		"enabled_rules": enabled,
+		"webhooks":      len(m.webhooks),
+		"open_alerts":   len(m.open),
+		"evaluations":   m.evaluations,
+
+		"webhook_deliveries":   m.delivered,
+		"webhook_failures":     m.deliveryFailures,
+		"webhook_pending":      deliveries[database.DeliveryPending],
+		"webhook_dead_letters": deliveries[database.DeliveryFailed],
+	}
+	if !m.lastEval.IsZero() {
+		stats["last_evaluation"] = m.lastEval
+	}
+	return stats
 }
 
-// RemoveWebhook removes a webhook
-func (m *Manager) RemoveWebhook(id string) {
+// Rules returns every rule, ordered by name.
+func (m *Manager) Rules() []Rule {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.webhooks, id)
+	out := make([]Rule, 0, len(m.rules))
+	for _, r := range m.rules {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
 }
 
-// GetWebhooks returns all webhooks
-func (m *Manager) GetWebhooks() []*WebhookConfig {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	webhooks := make([]*WebhookConfig, 0, len(m.webhooks))
-	for _, webhook := range m.webhooks {
-		webhooks = append(webhooks, webhook)
+// Rule returns one rule.
+func (m *Manager) Rule(id string) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rules[id]
+	if !ok {
+		return Rule{}, fmt.Errorf("rule %q: %w", id, ErrNotFound)
 	}
-	return webhooks
+	return *r, nil
 }
 
-// TriggerAlert triggers an alert
-func (m *Manager) TriggerAlert(alert *Alert) {
-	m.sendMu.Lock()
-	if m.closed {
-		m.sendMu.Unlock()
-		return
+// AddRule stores a new rule with an ID of its own.
+func (m *Manager) AddRule(r Rule, createdBy string) (Rule, error) {
+	if err := r.Validate(); err != nil {
+		return Rule{}, &ValidationError{err}
 	}
-	select {
-	case m.alertBuffer <- alert:
-		m.sendMu.Unlock()
-	default:
-		m.sendMu.Unlock()
-		log.Printf("WARNING: Alert buffer full, dropping alert")
+	r.ID = uuid.New().String()
+	r.CreatedBy = createdBy
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.saveRule(&r); err != nil {
+		return Rule{}, err
 	}
+	return r, nil
 }
 
-// processAlerts processes buffered alerts
-func (m *Manager) processAlerts() {
-	defer m.wg.Done()
+// UpdateRule replaces a rule. An open alert of it is judged by the new
+// condition at the next evaluation.
+func (m *Manager) UpdateRule(id string, r Rule) (Rule, error) {
+	if err := r.Validate(); err != nil {
+		return Rule{}, &ValidationError{err}
+	}
 
-	for alert := range m.alertBuffer {
-		m.sendAlert(alert)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.rules[id]
+	if !ok {
+		return Rule{}, fmt.Errorf("rule %q: %w", id, ErrNotFound)
+	}
+	r.ID, r.CreatedBy, r.CreatedAt = old.ID, old.CreatedBy, old.CreatedAt
+	r.UpdatedAt = time.Now()
+	if err := m.saveRule(&r); err != nil {
+		return Rule{}, err
 	}
+	// A condition that must hold for a while starts over.
+	for key := range m.breachedSince {
+		if ruleOfKey(key) == id {
+			delete(m.breachedSince, key)
+		}
+	}
+	return r, nil
 }
 
-// sendAlert sends an alert to all configured destinations
-func (m *Manager) sendAlert(alert *Alert) {
-	// Snapshot the webhook configurations (by value) under the read lock so the
-	// goroutines below never race with AddWebhook/RemoveWebhook mutating the
-	// shared *WebhookConfig values.
-	m.mu.RLock()
-	webhooks := make([]WebhookConfig, 0, len(m.webhooks))
-	for _, webhook := range m.webhooks {
-		if webhook.Enabled {
-			webhooks = append(webhooks, *webhook)
+// DeleteRule removes a rule and resolves its open alerts in the name of
+// whoever removed it.
+func (m *Manager) DeleteRule(id, deletedBy string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rules[id]
+	if !ok {
+		return fmt.Errorf("rule %q: %w", id, ErrNotFound)
+	}
+	if _, err := m.db.DeleteAlertRule(id); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	delete(m.rules, id)
+	now := time.Now()
+	for key, a := range m.open {
+		if a.RuleID == id {
+			m.resolve(key, r, deletedBy, now)
 		}
 	}
-	m.mu.RUnlock()
+	for key := range m.lastFired {
+		if ruleOfKey(key) == id {
+			delete(m.lastFired, key)
+			delete(m.breachedSince, key)
+		}
+	}
+	return nil
+}
+
+// saveRule writes a rule to the database and keeps it. The caller holds mu.
+func (m *Manager) saveRule(r *Rule) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := m.db.SaveAlertRule(r.ID, string(data)); err != nil {
+		return fmt.Errorf("failed to save rule: %w", err)
+	}
+	m.rules[r.ID] = r
+	return nil
+}
 
-	for i := range webhooks {
-		wh := webhooks[i] // local copy per goroutine
-		m.wg.Add(1)
-		go func() {
-			defer m.wg.Done()
-			// Bound concurrent deliveries so a flood of alerts can't spawn an
-			// unbounded number of goroutines.
-			m.webhookSem <- struct{}{}
-			defer func() { <-m.webhookSem }()
-			m.sendWebhook(&wh, alert)
-		}()
+// Webhooks returns every webhook, ordered by name.
+func (m *Manager) Webhooks() []Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Webhook, 0, len(m.webhooks))
+	for _, wh := range m.webhooks {
+		out = append(out, *wh)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
 }
 
-// sendWebhook sends an alert to a webhook
-func (m *Manager) sendWebhook(webhook *WebhookConfig, alert *Alert) {
-	payload := map[string]interface{}{
-		"timestamp": alert.Timestamp,
-		"severity":  alert.Severity,
-		"message":   alert.Message,
-		"details":   alert.Details,
+// Webhook returns one webhook.
+func (m *Manager) Webhook(id string) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wh, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, fmt.Errorf("webhook %q: %w", id, ErrNotFound)
 	}
+	return *wh, nil
+}
 
-	data, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("ERROR: Failed to marshal webhook payload: %v", err)
-		return
+// AddWebhook stores a new webhook with an ID of its own.
+func (m *Manager) AddWebhook(wh Webhook) (Webhook, error) {
+	if err := wh.Validate(); err != nil {
+		return Webhook{}, &ValidationError{err}
 	}
+	wh.ID = uuid.New().String()
+	wh.CreatedAt = time.Now()
 
-	req, err := http.NewRequest("POST", webhook.URL, bytes.NewBuffer(data))
-	if err != nil {
-		log.Printf("ERROR: Failed to create webhook request: %v", err)
-		return
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.saveWebhook(&wh); err != nil {
+		return Webhook{}, err
 	}
+	return wh, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range webhook.Headers {
-		req.Header.Set(k, v)
+// UpdateWebhook replaces a webhook. An empty secret keeps the stored one,
+// since the API never shows it.
+func (m *Manager) UpdateWebhook(id string, wh Webhook) (Webhook, error) {
+	if err := wh.Validate(); err != nil {
+		return Webhook{}, &ValidationError{err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, fmt.Errorf("webhook %q: %w", id, ErrNotFound)
+	}
+	wh.ID, wh.CreatedAt = old.ID, old.CreatedAt
+	if wh.Secret == "" {
+		wh.Secret = old.Secret
+	}
+	if err := m.saveWebhook(&wh); err != nil {
+		return Webhook{}, err
 	}
+	return wh, nil
+}
 
-	if webhook.Secret != "" {
-		req.Header.Set("X-Webhook-Secret", webhook.Secret)
+// DeleteWebhook removes a webhook and the deliveries still to be made to
+// it.
+func (m *Manager) DeleteWebhook(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[id]; !ok {
+		return fmt.Errorf("webhook %q: %w", id, ErrNotFound)
+	}
+	if _, err := m.db.DeleteAlertWebhook(id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
 	}
+	delete(m.webhooks, id)
+	if _, err := m.db.DeleteWebhookDeliveries(id); err != nil {
+		m.log.Warn("ALERT", fmt.Sprintf("Failed to remove the deliveries to webhook %s: %v", id, err))
+	}
+	return nil
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// saveWebhook writes a webhook to the database and keeps it. The caller
+// holds mu.
+func (m *Manager) saveWebhook(wh *Webhook) error {
+	data, err := json.Marshal(wh)
 	if err != nil {
-		log.Printf("ERROR: Failed to send webhook: %v", err)
-		return
+		return err
+	}
+	if err := m.db.SaveAlertWebhook(wh.ID, string(data)); err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+	m.webhooks[wh.ID] = wh
+	return nil
+}
+
+// Alerts returns the alerts a filter selects, newest first.
+func (m *Manager) Alerts(f database.AlertFilter) ([]*database.Alert, error) {
+	return m.db.QueryAlerts(f)
+}
+
+// Alert returns one alert.
+func (m *Manager) Alert(id int64) (*database.Alert, error) {
+	a, err := m.db.GetAlert(id)
+	if err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	if a == nil {
+		return nil, fmt.Errorf("alert %d: %w", id, ErrNotFound)
+	}
+	return a, nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("WARNING: Webhook returned non-success status: %d", resp.StatusCode)
+// Acknowledge records that someone is looking into an alert. It stays open
+// until its condition clears or it is resolved.
+func (m *Manager) Acknowledge(id int64, user string) (*database.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, a := m.openAlert(id)
+	if a == nil {
+		return m.closedAlert(id)
+	}
+	if a.State == database.AlertAcknowledged {
+		return copyAlert(a), nil
 	}
+	now := time.Now()
+	updated := *a
+	updated.State = database.AlertAcknowledged
+	updated.AcknowledgedAt, updated.AcknowledgedBy = &now, user
+	if err := m.db.UpdateAlert(&updated); err != nil {
+		return nil, fmt.Errorf("failed to save alert: %w", err)
+	}
+	m.open[key] = &updated
+	m.dispatch(EventAcknowledged, m.rules[a.RuleID], updated)
+	return copyAlert(&updated), nil
 }
 
-// EvaluateMetric evaluates a metric against alert rules
-func (m *Manager) EvaluateMetric(metricName string, value float64, labels map[string]string) {
-	// Use the write lock: triggering a rule mutates rule.LastTriggered, so an
-	// RLock here would race with concurrent evaluators.
+// Resolve closes an alert by hand. If its condition still holds, the rule
+// raises a new one once its cooldown has passed.
+func (m *Manager) Resolve(id int64, user string) (*database.Alert, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	key, a := m.openAlert(id)
+	if a == nil {
+		return m.closedAlert(id)
+	}
+	resolved, err := m.resolve(key, m.rules[a.RuleID], user, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return copyAlert(resolved), nil
+}
 
-	for _, rule := range m.rules {
-		if !rule.Enabled {
-			continue
+// openAlert finds an open alert by ID. The caller holds mu.
+func (m *Manager) openAlert(id int64) (string, *database.Alert) {
+	for key, a := range m.open {
+		if a.ID == id {
+			return key, a
 		}
+	}
+	return "", nil
+}
 
-		// Check cooldown
-		if time.Since(rule.LastTriggered) < time.Duration(rule.Cooldown)*time.Second {
-			continue
-		}
+// closedAlert answers for an alert that is not open: it is resolved, or
+// there is none.
+func (m *Manager) closedAlert(id int64) (*database.Alert, error) {
+	a, err := m.db.GetAlert(id)
+	switch {
+	case err != nil:
+		return nil, err
+	case a == nil:
+		return nil, fmt.Errorf("alert %d: %w", id, ErrNotFound)
+	default:
+		return a, fmt.Errorf("alert %d: %w", id, ErrResolved)
+	}
+}
+
+// fire raises an alert. The caller holds mu.
+func (m *Manager) fire(key string, r *Rule, proxyID string, value float64, now time.Time) {
+	a := &database.Alert{
+		RuleID:    r.ID,
+		RuleName:  r.Name,
+		ProxyID:   proxyID,
+		Metric:    r.Metric,
+		Severity:  r.Severity,
+		Value:     value,
+		Threshold: r.Threshold,
+		Message:   r.describe(proxyID, value),
+		State:     database.AlertFiring,
+		FiredAt:   now,
+	}
+	if err := m.db.AddAlert(a); err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Failed to save alert of rule %s: %v", r.Name, err))
+		return
+	}
+	m.open[key] = a
+	m.lastFired[key] = now
+	delete(m.breachedSince, key)
+	m.log.Warn("ALERT", a.Message)
+	m.dispatch(EventFired, r, *a)
+}
 
-		// Simple threshold evaluation
-		if rule.RuleType == "threshold" {
-			shouldTrigger := false
-			if rule.Condition == "greater_than" && value > rule.Threshold {
-				shouldTrigger = true
-			} else if rule.Condition == "less_than" && value < rule.Threshold {
-				shouldTrigger = true
-			} else if rule.Condition == "equals" && value == rule.Threshold {
-				shouldTrigger = true
-			}
-
-			if shouldTrigger {
-				rule.LastTriggered = time.Now()
-				alert := &Alert{
-					RuleID:   rule.ID,
-					Severity: rule.Severity,
-					Message:  rule.Name,
-					Details: map[string]interface{}{
-						"metric":    metricName,
-						"value":     value,
-						"threshold": rule.Threshold,
-						"condition": rule.Condition,
-						"labels":    labels,
-					},
-					Timestamp: time.Now(),
-				}
-				m.TriggerAlert(alert)
-			}
+// resolve closes an open alert. An empty user means its condition cleared
+// by itself. r is nil when the rule is gone. The caller holds mu.
+func (m *Manager) resolve(key string, r *Rule, user string, now time.Time) (*database.Alert, error) {
+	a := m.open[key]
+	updated := *a
+	updated.State = database.AlertResolved
+	updated.ResolvedAt, updated.ResolvedBy = &now, user
+	if err := m.db.UpdateAlert(&updated); err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Failed to save alert %d: %v", a.ID, err))
+		return nil, fmt.Errorf("failed to save alert: %w", err)
+	}
+	delete(m.open, key)
+	m.dispatch(EventResolved, r, updated)
+	return &updated, nil
+}
+
+// dispatch sends an alert to where its rule says: the operator's mail for a
+// fired or resolved one, and every webhook that takes the event, by way of
+// the delivery queue. The caller holds mu.
+func (m *Manager) dispatch(event string, r *Rule, a database.Alert) {
+	if r == nil || m.stopped {
+		return
+	}
+	if r.wants(ActionEmail) && m.cfg.Mail != nil && event != EventAcknowledged {
+		m.cfg.Mail(event, a)
+	}
+	if !r.wants(ActionWebhook) {
+		return
+	}
+	now := time.Now()
+	for _, wh := range m.webhooks {
+		if wh.wants(event) {
+			m.enqueue(wh, event, a, now)
 		}
 	}
 }
+
+// alertKey identifies the alert of one rule about one proxy; a rule has at
+// most one open alert per proxy.
+func alertKey(ruleID, proxyID string) string {
+	return ruleID + "|" + proxyID
+}
+
+// ruleOfKey returns the rule ID of an alertKey. Rule IDs are UUIDs, so the
+// first separator ends it.
+func ruleOfKey(key string) string {
+	ruleID, _, _ := strings.Cut(key, "|")
+	return ruleID
+}
+
+func copyAlert(a *database.Alert) *database.Alert {
+	c := *a
+	return &c
+}
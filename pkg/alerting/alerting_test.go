@@ -0,0 +1,432 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSource returns the samples a test sets.
+type fakeSource struct {
+	mu      sync.Mutex
+	samples map[string]Sample
+}
+
+func (s *fakeSource) set(id string, sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples[id] = sample
+}
+
+func (s *fakeSource) Samples() map[string]Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Sample, len(s.samples))
+	for id, sample := range s.samples {
+		out[id] = sample
+	}
+	return out
+}
+
+// mailed is an alert handed to Config.Mail.
+type mailed struct {
+	event string
+	alert database.Alert
+}
+
+// newTestManager starts an alerting that evaluates only when the test calls
+// evaluate, and tries a failed webhook delivery three times, quickly.
+func newTestManager(t *testing.T, db *database.DB, source Source) (*Manager, *[]mailed) {
+	t.Helper()
+	var mu sync.Mutex
+	mails := &[]mailed{}
+	m := NewManager(db, source, Config{
+		Interval:    time.Hour,
+		RetryDelay:  10 * time.Millisecond,
+		MaxAttempts: 3,
+		Mail: func(event string, a database.Alert) {
+			mu.Lock()
+			defer mu.Unlock()
+			*mails = append(*mails, mailed{event, a})
+		},
+	}, logger.NewNullLogger(100))
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+	return m, mails
+}
+
+func openDB(t *testing.T, dir string) *database.DB {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(dir, "alerts.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// signedBy decodes a webhook request, and reports whether it carries the
+// signature of secret, or none without one.
+func signedBy(r *http.Request, secret string) (WebhookPayload, bool) {
+	var p WebhookPayload
+	body, err := io.ReadAll(r.Body)
+	if err != nil || json.Unmarshal(body, &p) != nil {
+		return p, false
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		return p, false
+	}
+	want := ""
+	if secret != "" {
+		want = Sign(secret, ts, body)
+	}
+	return p, r.Header.Get(HeaderSignature) == want && r.Header.Get(HeaderEvent) == p.Event
+}
+
+// waitFor polls cond until it holds, or fails the test after a while.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestErrorRateRuleFiresAndResolves(t *testing.T) {
+	received := make(chan WebhookPayload, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := signedBy(r, "s3cret")
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- p
+	}))
+	defer hook.Close()
+
+	source := &fakeSource{samples: map[string]Sample{}}
+	m, mails := newTestManager(t, openDB(t, t.TempDir()), source)
+	if _, err := m.AddWebhook(Webhook{Name: "on-call", URL: hook.URL, Secret: "s3cret", Enabled: true}); err != nil {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+	rule, err := m.AddRule(Rule{
+		Name: "High error rate", Enabled: true, Metric: MetricErrorRate, Condition: ConditionAbove,
+		Threshold: 10, WindowMinutes: 5, Severity: SeverityError,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	start := time.Now()
+	source.set("p1", Sample{Running: true, Requests: 1000, Errors: 10})
+	m.evaluate(start)
+	// 50 of the 100 requests since the first sample failed.
+	source.set("p1", Sample{Running: true, Requests: 1050, Errors: 60})
+	m.evaluate(start.Add(time.Minute))
+
+	open, _ := m.Alerts(database.AlertFilter{States: []string{database.AlertFiring}})
+	if len(open) != 1 || open[0].Value != 50 || open[0].ProxyID != "p1" || open[0].RuleID != rule.ID {
+		t.Fatalf("open alerts = %+v, want one at 50%%", open)
+	}
+	if p := <-received; p.Event != EventFired || p.Alert.ID != open[0].ID {
+		t.Errorf("webhook got %+v", p)
+	}
+
+	// Six minutes on, the window no longer holds the failures.
+	source.set("p1", Sample{Running: true, Requests: 1500, Errors: 60})
+	m.evaluate(start.Add(7 * time.Minute))
+	a, err := m.Alert(open[0].ID)
+	if err != nil || a.State != database.AlertResolved || a.ResolvedBy != "" || a.ResolvedAt == nil {
+		t.Fatalf("Alert() = %+v, %v, want resolved by itself", a, err)
+	}
+	if p := <-received; p.Event != EventResolved {
+		t.Errorf("webhook got %s, want %s", p.Event, EventResolved)
+	}
+	m.Stop()
+	if len(*mails) != 2 || (*mails)[0].event != EventFired || (*mails)[1].event != EventResolved {
+		t.Errorf("mails = %+v, want fired and resolved", *mails)
+	}
+}
+
+func TestGaugeRuleHoldsForWindowAndCooldown(t *testing.T) {
+	source := &fakeSource{samples: map[string]Sample{}}
+	m, _ := newTestManager(t, openDB(t, t.TempDir()), source)
+	_, err := m.AddRule(Rule{
+		Name: "Circuit open", Enabled: true, ProxyID: "p1", Metric: MetricCircuitOpen, Condition: ConditionEquals,
+		Threshold: 1, WindowMinutes: 2, Severity: SeverityWarning, Actions: []string{ActionEmail}, CooldownSeconds: 600,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	openAlerts := func() []*database.Alert {
+		t.Helper()
+		list, err := m.Alerts(database.AlertFilter{States: []string{database.AlertFiring, database.AlertAcknowledged}})
+		if err != nil {
+			t.Fatalf("Alerts() error = %v", err)
+		}
+		return list
+	}
+
+	start := time.Now()
+	source.set("p1", Sample{Running: true, CircuitOpen: true})
+	source.set("p2", Sample{Running: true, CircuitOpen: true}) // Not watched by the rule
+	m.evaluate(start)
+	m.evaluate(start.Add(time.Minute))
+	if n := len(openAlerts()); n != 0 {
+		t.Fatalf("%d alerts before the window passed, want none", n)
+	}
+	m.evaluate(start.Add(2 * time.Minute))
+	list := openAlerts()
+	if len(list) != 1 || list[0].ProxyID != "p1" {
+		t.Fatalf("open alerts = %+v, want one about p1", list)
+	}
+
+	acked, err := m.Acknowledge(list[0].ID, "alice")
+	if err != nil || acked.State != database.AlertAcknowledged || acked.AcknowledgedBy != "alice" {
+		t.Fatalf("Acknowledge() = %+v, %v", acked, err)
+	}
+	resolved, err := m.Resolve(list[0].ID, "bob")
+	if err != nil || resolved.State != database.AlertResolved || resolved.ResolvedBy != "bob" {
+		t.Fatalf("Resolve() = %+v, %v", resolved, err)
+	}
+	if _, err := m.Resolve(list[0].ID, "bob"); !errors.Is(err, ErrResolved) {
+		t.Errorf("Resolve() twice error = %v, want ErrResolved", err)
+	}
+	if _, err := m.Acknowledge(9999, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Acknowledge() of no alert error = %v, want ErrNotFound", err)
+	}
+
+	// Still open, but within the cooldown of the last alert.
+	m.evaluate(start.Add(5 * time.Minute))
+	if n := len(openAlerts()); n != 0 {
+		t.Fatalf("%d alerts within the cooldown, want none", n)
+	}
+	m.evaluate(start.Add(13 * time.Minute))
+	if n := len(openAlerts()); n != 1 {
+		t.Fatalf("%d alerts after the cooldown, want one", n)
+	}
+}
+
+func TestOpenAlertsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	db := openDB(t, dir)
+	source := &fakeSource{samples: map[string]Sample{"p1": {Running: true, Clients: 0}}}
+
+	m, _ := newTestManager(t, db, source)
+	rule, err := m.AddRule(Rule{
+		Name: "No clients", Enabled: true, Metric: MetricClients, Condition: ConditionBelow,
+		Threshold: 1, Severity: SeverityInfo,
+	}, "admin")
+	if err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+	now := time.Now()
+	m.evaluate(now)
+	m.Stop()
+
+	m2, _ := newTestManager(t, db, source)
+	if rules := m2.Rules(); len(rules) != 1 || rules[0].ID != rule.ID || rules[0].CreatedBy != "admin" {
+		t.Fatalf("Rules() after restart = %+v", rules)
+	}
+	if stats := m2.Stats(); stats["open_alerts"] != 1 {
+		t.Fatalf("Stats() after restart = %v, want the open alert back", stats)
+	}
+	// Still breached: no second alert. Then a client connects.
+	m2.evaluate(now.Add(time.Minute))
+	source.set("p1", Sample{Running: true, Clients: 2})
+	m2.evaluate(now.Add(2 * time.Minute))
+	all, _ := m2.Alerts(database.AlertFilter{})
+	if len(all) != 1 || all[0].State != database.AlertResolved {
+		t.Fatalf("alerts = %+v, want the one alert resolved", all)
+	}
+
+	// Deleting a rule resolves its alerts in the name of whoever did it.
+	source.set("p1", Sample{Running: true, Clients: 0})
+	m2.evaluate(now.Add(3 * time.Minute))
+	if err := m2.DeleteRule(rule.ID, "carol"); err != nil {
+		t.Fatalf("DeleteRule() error = %v", err)
+	}
+	latest, _ := m2.Alerts(database.AlertFilter{Limit: 1})
+	if len(latest) != 1 || latest[0].State != database.AlertResolved || latest[0].ResolvedBy != "carol" {
+		t.Errorf("alert after DeleteRule = %+v, want resolved by carol", latest)
+	}
+}
+
+func TestWebhookRetriesAndDeadLetters(t *testing.T) {
+	var calls, failing atomic.Int32
+	failing.Store(http.StatusServiceUnavailable)
+	deliveries := make(chan string, 20)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if _, ok := signedBy(r, "k"); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if status := failing.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+		deliveries <- r.Header.Get(HeaderDelivery)
+	}))
+	defer hook.Close()
+
+	source := &fakeSource{samples: map[string]Sample{"p1": {Running: true, Clients: 0}}}
+	m, _ := newTestManager(t, openDB(t, t.TempDir()), source)
+	wh, err := m.AddWebhook(Webhook{Name: "flaky", URL: hook.URL, Secret: "k", Enabled: true, Events: []string{EventFired}})
+	if err != nil {
+		t.Fatalf("AddWebhook() error = %v", err)
+	}
+	if status, err := m.TestWebhook(context.Background(), wh.ID); err == nil || status != http.StatusServiceUnavailable {
+		t.Errorf("TestWebhook() = %d, %v, want the 503", status, err)
+	}
+	if _, err := m.AddRule(Rule{
+		Name: "No clients", Enabled: true, Metric: MetricClients, Condition: ConditionBelow,
+		Threshold: 1, Severity: SeverityWarning, Actions: []string{ActionWebhook},
+	}, "admin"); err != nil {
+		t.Fatalf("AddRule() error = %v", err)
+	}
+
+	// Three attempts, then a dead letter.
+	calls.Store(0)
+	m.evaluate(time.Now())
+	var dead []*database.WebhookDelivery
+	waitFor(t, "a dead letter", func() bool {
+		dead, _ = m.DeadLetters(0, 0)
+		return len(dead) == 1
+	})
+	if n := calls.Load(); n != 3 || dead[0].Attempts != 3 || dead[0].LastStatus != http.StatusServiceUnavailable {
+		t.Fatalf("dead letter %+v after %d calls, want 3 attempts", dead[0], n)
+	}
+	if _, err := m.Redeliver(9999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Redeliver() of no delivery error = %v, want ErrNotFound", err)
+	}
+
+	// The receiver is back: redelivered under the same delivery ID.
+	failing.Store(0)
+	if _, err := m.Redeliver(dead[0].ID); err != nil {
+		t.Fatalf("Redeliver() error = %v", err)
+	}
+	if id := <-deliveries; id != strconv.FormatInt(dead[0].ID, 10) {
+		t.Errorf("delivery header = %q, want %d", id, dead[0].ID)
+	}
+	waitFor(t, "the delivery to be removed", func() bool {
+		stats := m.Stats()
+		return stats["webhook_dead_letters"] == int64(0) && stats["webhook_pending"] == int64(0)
+	})
+	if _, err := m.Redeliver(dead[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Redeliver() of a delivered one error = %v, want ErrNotFound", err)
+	}
+
+	// A receiver that turns a request down for good is not asked again.
+	failing.Store(http.StatusBadRequest)
+	calls.Store(0)
+	source.set("p1", Sample{Running: true, Clients: 3})
+	m.evaluate(time.Now())
+	source.set("p1", Sample{Running: true, Clients: 0})
+	m.evaluate(time.Now())
+	waitFor(t, "a second dead letter", func() bool {
+		dead, _ = m.DeadLetters(0, 0)
+		return len(dead) == 1
+	})
+	if n := calls.Load(); n != 1 || dead[0].Attempts != 1 {
+		t.Errorf("dead letter %+v after %d calls, want 1 attempt", dead[0], n)
+	}
+	if err := m.DiscardDeadLetter(dead[0].ID); err != nil {
+		t.Fatalf("DiscardDeadLetter() error = %v", err)
+	}
+	if dead, _ = m.DeadLetters(0, 0); len(dead) != 0 {
+		t.Errorf("dead letters after discarding = %+v", dead)
+	}
+}
+
+func TestPendingDeliveriesSurviveRestart(t *testing.T) {
+	received := make(chan WebhookPayload, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := signedBy(r, ""); ok {
+			received <- p
+		}
+	}))
+	defer hook.Close()
+
+	db := openDB(t, t.TempDir())
+	data, _ := json.Marshal(Webhook{ID: "wh1", Name: "w", URL: hook.URL, Enabled: true})
+	if err := db.SaveAlertWebhook("wh1", string(data)); err != nil {
+		t.Fatalf("SaveAlertWebhook() error = %v", err)
+	}
+	// Left pending, with an attempt due, by the last run.
+	body, _ := json.Marshal(WebhookPayload{Event: EventResolved, Alert: database.Alert{ID: 7}})
+	if err := db.AddWebhookDelivery(&database.WebhookDelivery{
+		WebhookID: "wh1", Event: EventResolved, AlertID: 7, Payload: body, State: database.DeliveryPending,
+		Attempts: 2, NextAttemptAt: time.Now().Add(-time.Minute), CreatedAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("AddWebhookDelivery() error = %v", err)
+	}
+
+	newTestManager(t, db, &fakeSource{samples: map[string]Sample{}})
+	select {
+	case p := <-received:
+		if p.Event != EventResolved || p.Alert.ID != 7 {
+			t.Errorf("webhook got %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pending delivery was not made after the restart")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := Rule{Name: "r", Metric: MetricLatencyP95, Condition: ConditionAbove, Threshold: 500, Severity: SeverityWarning}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() of a valid rule error = %v", err)
+	}
+	for name, change := range map[string]func(r *Rule){
+		"no name":           func(r *Rule) { r.Name = " " },
+		"unknown metric":    func(r *Rule) { r.Metric = "cpu" },
+		"unknown cond":      func(r *Rule) { r.Condition = ">" },
+		"counted no window": func(r *Rule) { r.Metric = MetricErrorRate },
+		"window too long":   func(r *Rule) { r.WindowMinutes = MaxWindowMinutes + 1 },
+		"bad severity":      func(r *Rule) { r.Severity = "critical" },
+		"bad action":        func(r *Rule) { r.Actions = []string{"sms"} },
+		"negative cooldown": func(r *Rule) { r.CooldownSeconds = -1 },
+	} {
+		r := valid
+		change(&r)
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+
+	wh := Webhook{Name: "w", URL: "ftp://example.com/hook"}
+	if err := wh.Validate(); err == nil {
+		t.Error("Validate() accepted a webhook URL that is not http")
+	}
+	wh = Webhook{Name: "w", URL: "https://example.com/hook", Events: []string{"alert.exploded"}}
+	if err := wh.Validate(); err == nil {
+		t.Error("Validate() accepted an unknown event")
+	}
+	wh = Webhook{Name: "w", URL: "https://example.com/hook", Headers: map[string]string{"x-modbridge-signature": "x"}}
+	if err := wh.Validate(); err == nil {
+		t.Error("Validate() accepted a header ModBridge sets")
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics a rule can watch. The counted ones are counted over the rule's
+// window; the others are read as they are.
+const (
+	MetricErrorRate      = "error_rate"      // Percent of requests that failed within the window
+	MetricLatencyP95     = "latency_p95"     // 95th percentile latency in milliseconds
+	MetricCircuitOpen    = "circuit_open"    // 1 while the circuit breaker is open, else 0
+	MetricPollerFailures = "poller_failures" // Failed refreshes of the background poller within the window
+	MetricStaleResponses = "stale_responses" // Late target responses discarded within the window
+	MetricClients        = "clients"         // Connected clients
+)
+
+// Conditions comparing a metric with the threshold.
+const (
+	ConditionAbove  = "greater_than"
+	ConditionBelow  = "less_than"
+	ConditionEquals = "equals"
+)
+
+// Actions a rule takes when it fires or resolves. Every alert is kept in
+// the history either way.
+const (
+	ActionEmail   = "email"   // Mail it with the mail notification settings
+	ActionWebhook = "webhook" // Post it to the webhooks
+)
+
+// Severities of a rule, the same as those of the mail notifications.
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+// MaxWindowMinutes is the longest window of a rule.
+const MaxWindowMinutes = 1440
+
+// metricInfo describes a metric in an alert message.
+type metricInfo struct {
+	label   string
+	unit    string
+	counted bool // Counted over the window rather than read as it is
+}
+
+var metrics = map[string]metricInfo{
+	MetricErrorRate:      {"error rate", "%", true},
+	MetricLatencyP95:     {"p95 latency", " ms", false},
+	MetricCircuitOpen:    {"circuit breaker open", "", false},
+	MetricPollerFailures: {"poller failures", "", true},
+	MetricStaleResponses: {"stale responses", "", true},
+	MetricClients:        {"connected clients", "", false},
+}
+
+var conditionWords = map[string]string{
+	ConditionAbove:  "above",
+	ConditionBelow:  "below",
+	ConditionEquals: "equal to",
+}
+
+// Rule raises an alert for each proxy whose metric meets the condition. A
+// counted metric is counted over the window; any other must meet the
+// condition for the whole window before the rule fires (0 = at once).
+type Rule struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description,omitempty"`
+	Enabled         bool      `json:"enabled"`
+	ProxyID         string    `json:"proxy_id,omitempty"` // Empty watches every proxy
+	Metric          string    `json:"metric"`
+	Condition       string    `json:"condition"`
+	Threshold       float64   `json:"threshold"`
+	WindowMinutes   int       `json:"window_minutes"`
+	Severity        string    `json:"severity"`
+	Actions         []string  `json:"actions"`          // Empty takes every action
+	CooldownSeconds int       `json:"cooldown_seconds"` // Minimum time between two alerts of the rule about one proxy
+	CreatedBy       string    `json:"created_by,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Validate checks a rule before it is stored.
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(r.Name) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+	info, ok := metrics[r.Metric]
+	if !ok {
+		return fmt.Errorf("metric must be one of: %s", strings.Join(sortedKeys(metrics), ", "))
+	}
+	if _, ok := conditionWords[r.Condition]; !ok {
+		return fmt.Errorf("condition must be one of: %s", strings.Join(sortedKeys(conditionWords), ", "))
+	}
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		return errors.New("threshold must be a number")
+	}
+	if r.WindowMinutes < 0 || r.WindowMinutes > MaxWindowMinutes {
+		return fmt.Errorf("window_minutes must be between 0 and %d", MaxWindowMinutes)
+	}
+	if info.counted && r.WindowMinutes == 0 {
+		return fmt.Errorf("window_minutes must be at least 1 for %s", r.Metric)
+	}
+	switch r.Severity {
+	case SeverityInfo, SeverityWarning, SeverityError:
+	default:
+		return errors.New("severity must be info, warning or error")
+	}
+	for _, a := range r.Actions {
+		if a != ActionEmail && a != ActionWebhook {
+			return errors.New("actions must be email or webhook")
+		}
+	}
+	if r.CooldownSeconds < 0 || r.CooldownSeconds > 7*24*3600 {
+		return errors.New("cooldown_seconds must be between 0 and 604800")
+	}
+	return nil
+}
+
+// covers reports whether the rule watches a proxy.
+func (r *Rule) covers(proxyID string) bool {
+	return r.ProxyID == "" || r.ProxyID == proxyID
+}
+
+// wants reports whether the rule takes an action.
+func (r *Rule) wants(action string) bool {
+	return len(r.Actions) == 0 || slices.Contains(r.Actions, action)
+}
+
+// window returns the rule's window.
+func (r *Rule) window() time.Duration {
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+// breached reports whether a value meets the condition.
+func (r *Rule) breached(value float64) bool {
+	switch r.Condition {
+	case ConditionAbove:
+		return value > r.Threshold
+	case ConditionBelow:
+		return value < r.Threshold
+	case ConditionEquals:
+		return value == r.Threshold
+	}
+	return false
+}
+
+// describe returns the message of an alert, such as "High error rate: error
+// rate of proxy p1 is 12.5% (above 5% over 5 min)".
+func (r *Rule) describe(proxyID string, value float64) string {
+	info := metrics[r.Metric]
+	msg := fmt.Sprintf("%s: %s of proxy %s is %s%s (%s %s%s", r.Name, info.label, proxyID,
+		formatValue(value), info.unit, conditionWords[r.Condition], formatValue(r.Threshold), info.unit)
+	switch {
+	case r.WindowMinutes == 0:
+	case info.counted:
+		msg += fmt.Sprintf(" over %d min", r.WindowMinutes)
+	default:
+		msg += fmt.Sprintf(" for %d min", r.WindowMinutes)
+	}
+	return msg + ")"
+}
+
+func formatValue(v float64) string {
+	return fmt.Sprint(math.Round(v*100) / 100)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// evaluate reads the proxies and checks every rule against them: an alert
+// fires for a broken rule and resolves once its condition has cleared.
+func (m *Manager) evaluate(now time.Time) {
+	samples := m.source.Samples()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(now, samples)
+	m.lastEval = now
+	m.evaluations++
+
+	// Alerts whose rule no longer watches their proxy, or whose proxy is
+	// gone, have nothing left to wait for.
+	for key, a := range m.open {
+		r := m.rules[a.RuleID]
+		if _, exists := samples[a.ProxyID]; !exists || r == nil || !r.Enabled || !r.covers(a.ProxyID) {
+			_, _ = m.resolve(key, r, "", now)
+		}
+	}
+
+	proxyIDs := sortedKeys(samples)
+	for _, ruleID := range sortedKeys(m.rules) {
+		r := m.rules[ruleID]
+		if !r.Enabled {
+			continue
+		}
+		for _, proxyID := range proxyIDs {
+			// A stopped proxy says nothing about its target; its alerts wait.
+			if !r.covers(proxyID) || !samples[proxyID].Running {
+				continue
+			}
+			value, ok := m.value(r, proxyID, now)
+			if !ok {
+				continue
+			}
+			key := alertKey(r.ID, proxyID)
+			if !r.breached(value) {
+				delete(m.breachedSince, key)
+				if m.open[key] != nil {
+					_, _ = m.resolve(key, r, "", now)
+				}
+				continue
+			}
+			if m.open[key] != nil {
+				continue
+			}
+			if !metrics[r.Metric].counted && r.WindowMinutes > 0 {
+				since, ok := m.breachedSince[key]
+				if !ok {
+					m.breachedSince[key] = now
+					since = now
+				}
+				if now.Sub(since) < r.window() {
+					continue
+				}
+			}
+			if last, ok := m.lastFired[key]; ok && now.Sub(last) < time.Duration(r.CooldownSeconds)*time.Second {
+				continue
+			}
+			m.fire(key, r, proxyID, value, now)
+		}
+	}
+}
+
+// record keeps the samples as long as the longest window needs them. The
+// caller holds mu.
+func (m *Manager) record(now time.Time, samples map[string]Sample) {
+	keep := m.cfg.Interval
+	for _, r := range m.rules {
+		if w := r.window() + m.cfg.Interval; w > keep {
+			keep = w
+		}
+	}
+	for id := range m.samples {
+		if _, ok := samples[id]; !ok {
+			delete(m.samples, id)
+		}
+	}
+	for id, s := range samples {
+		history := append(m.samples[id], timedSample{at: now, Sample: s})
+		drop := 0
+		for drop < len(history)-1 && now.Sub(history[drop].at) > keep {
+			drop++
+		}
+		m.samples[id] = history[drop:]
+	}
+}
+
+// value returns a proxy's metric for a rule. ok is false while there is
+// nothing to judge by: a counted metric before the second sample, or an
+// error rate without requests. The caller holds mu.
+func (m *Manager) value(r *Rule, proxyID string, now time.Time) (float64, bool) {
+	history := m.samples[proxyID]
+	if len(history) == 0 {
+		return 0, false
+	}
+	cur := history[len(history)-1]
+	switch r.Metric {
+	case MetricLatencyP95:
+		return float64(cur.LatencyP95) / float64(time.Millisecond), true
+	case MetricCircuitOpen:
+		if cur.CircuitOpen {
+			return 1, true
+		}
+		return 0, true
+	case MetricClients:
+		return float64(cur.Clients), true
+	}
+
+	if len(history) < 2 {
+		return 0, false
+	}
+	// Count from the last sample at or before the start of the window; until
+	// a whole window has passed, from the oldest.
+	start := now.Add(-r.window())
+	base := history[0]
+	for _, s := range history[:len(history)-1] {
+		if s.at.After(start) {
+			break
+		}
+		base = s
+	}
+	switch r.Metric {
+	case MetricErrorRate:
+		requests := counted(cur.Requests, base.Requests)
+		errs := counted(cur.Errors, base.Errors)
+		if requests+errs == 0 {
+			return 0, false
+		}
+		return 100 * float64(errs) / float64(requests+errs), true
+	case MetricPollerFailures:
+		return float64(counted(cur.PollFailures, base.PollFailures)), true
+	case MetricStaleResponses:
+		return float64(counted(cur.StaleResponses, base.StaleResponses)), true
+	}
+	return 0, false
+}
+
+// counted returns how much a counter grew. One that went down belongs to a
+// proxy that was created anew, and counts from zero.
+func counted(cur, base int64) int64 {
+	if cur < base {
+		return cur
+	}
+	return cur - base
+}
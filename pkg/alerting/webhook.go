@@ -0,0 +1,365 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"modbridge/pkg/database"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventTest is the event of a test message, sent only on request.
+const EventTest = "webhook.test"
+
+// Headers of a webhook request. The signature is "sha256=" and the hex
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the
+// webhook's secret; a receiver recomputes it and rejects old timestamps.
+const (
+	HeaderEvent     = "X-ModBridge-Event"
+	HeaderDelivery  = "X-ModBridge-Delivery" // The same on every attempt of a delivery
+	HeaderTimestamp = "X-ModBridge-Timestamp"
+	HeaderSignature = "X-ModBridge-Signature" // Only with a secret
+)
+
+// maxRetryDelay bounds the wait between two attempts of a delivery.
+const maxRetryDelay = time.Hour
+
+// dueBatch is how many deliveries one round attempts at most.
+const dueBatch = 100
+
+// Webhook is a URL the alerts of rules with the webhook action are posted
+// to.
+type Webhook struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Secret    string            `json:"secret,omitempty"` // Signs every request
+	Events    []string          `json:"events"`           // Empty takes every event
+	Enabled   bool              `json:"enabled"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// WebhookPayload is the body posted to a webhook.
+type WebhookPayload struct {
+	Event     string         `json:"event"`
+	Timestamp time.Time      `json:"timestamp"`
+	Alert     database.Alert `json:"alert"`
+}
+
+// Validate checks a webhook before it is stored.
+func (wh *Webhook) Validate() error {
+	if strings.TrimSpace(wh.Name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	for _, ev := range wh.Events {
+		if ev != EventFired && ev != EventAcknowledged && ev != EventResolved {
+			return fmt.Errorf("events must be %s, %s or %s", EventFired, EventAcknowledged, EventResolved)
+		}
+	}
+	for name := range wh.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("header name %q is not valid", name)
+		}
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), "X-Modbridge-") {
+			return fmt.Errorf("header %s is set by ModBridge", name)
+		}
+	}
+	return nil
+}
+
+// wants reports whether the webhook takes an event.
+func (wh *Webhook) wants(event string) bool {
+	return wh.Enabled && (len(wh.Events) == 0 || slices.Contains(wh.Events, event))
+}
+
+// Sign returns the signature of a request body sent at timestamp, in Unix
+// seconds, as sent in HeaderSignature.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueue stores a delivery of an alert event to a webhook, to be made by
+// the delivery loop. The caller holds mu.
+func (m *Manager) enqueue(wh *Webhook, event string, a database.Alert, now time.Time) {
+	body, err := json.Marshal(WebhookPayload{Event: event, Timestamp: now, Alert: a})
+	if err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Webhook %s: %v", wh.Name, err))
+		return
+	}
+	d := &database.WebhookDelivery{
+		WebhookID:     wh.ID,
+		Event:         event,
+		AlertID:       a.ID,
+		Payload:       body,
+		State:         database.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := m.db.AddWebhookDelivery(d); err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Webhook %s: failed to queue %s of alert %d: %v", wh.Name, event, a.ID, err))
+		return
+	}
+	m.wakeDeliveries()
+}
+
+// wakeDeliveries has the delivery loop look for due deliveries now.
+func (m *Manager) wakeDeliveries() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runDeliveries makes the deliveries as they fall due, until ctx is done.
+// They are in the database, so those left over by the last run are made
+// too.
+func (m *Manager) runDeliveries(ctx context.Context) {
+	defer m.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-timer.C:
+		}
+		m.deliverDue(ctx, time.Now())
+		timer.Reset(m.untilNextDelivery())
+	}
+}
+
+// untilNextDelivery returns how long the delivery loop may sleep. It looks
+// again after a minute at the latest, in case the clock jumped.
+func (m *Manager) untilNextDelivery() time.Duration {
+	next, ok, err := m.db.NextWebhookDelivery()
+	if err != nil || !ok {
+		return time.Minute
+	}
+	return min(max(time.Until(next), 0), time.Minute)
+}
+
+// deliverDue attempts the deliveries due at now, a few at a time, and
+// waits for them.
+func (m *Manager) deliverDue(ctx context.Context, now time.Time) {
+	due, err := m.db.DueWebhookDeliveries(now, dueBatch)
+	if err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Failed to load webhook deliveries: %v", err))
+		return
+	}
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.webhookSem <- struct{}{}
+			defer func() { <-m.webhookSem }()
+			m.attempt(ctx, d)
+		}()
+	}
+	wg.Wait()
+}
+
+// attempt makes one attempt of a delivery. One that fails is tried again
+// later, each time waiting twice as long, until MaxAttempts; then, or at
+// once if the receiver turned it down for good, it becomes a dead letter.
+func (m *Manager) attempt(ctx context.Context, d *database.WebhookDelivery) {
+	m.mu.Lock()
+	wh, ok := m.webhooks[d.WebhookID]
+	var target Webhook
+	if ok {
+		target = *wh
+	}
+	m.mu.Unlock()
+	if !ok {
+		// The webhook was removed along with its deliveries.
+		_, _ = m.db.DeleteWebhookDelivery(d.ID)
+		return
+	}
+
+	now := time.Now()
+	var status int
+	err := errors.New("webhook is disabled")
+	if target.Enabled {
+		status, err = m.post(ctx, &target, d.Event, strconv.FormatInt(d.ID, 10), d.Payload)
+		if err != nil && ctx.Err() != nil {
+			// Stopping: the attempt does not count, and is made after the restart.
+			return
+		}
+		d.Attempts++
+	}
+	d.LastAttemptAt, d.LastStatus = &now, status
+
+	m.mu.Lock()
+	if err == nil {
+		m.delivered++
+	} else {
+		m.deliveryFailures++
+	}
+	m.mu.Unlock()
+
+	if err == nil {
+		if _, err := m.db.DeleteWebhookDelivery(d.ID); err != nil {
+			m.log.Error("ALERT", fmt.Sprintf("Webhook %s: failed to remove delivery %d: %v", target.Name, d.ID, err))
+		}
+		return
+	}
+	d.LastError = err.Error()
+	if !target.Enabled || permanent(status) || d.Attempts >= m.cfg.MaxAttempts {
+		d.State = database.DeliveryFailed
+		m.log.Error("ALERT", fmt.Sprintf("Webhook %s: gave up on %s of alert %d at attempt %d: %v",
+			target.Name, d.Event, d.AlertID, d.Attempts, err))
+	} else {
+		delay := m.retryDelay(d.Attempts)
+		d.NextAttemptAt = now.Add(delay)
+		m.log.Warn("ALERT", fmt.Sprintf("Webhook %s: attempt %d of %s of alert %d failed, trying again in %v: %v",
+			target.Name, d.Attempts, d.Event, d.AlertID, delay, err))
+	}
+	if err := m.db.UpdateWebhookDelivery(d); err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Webhook %s: failed to save delivery %d: %v", target.Name, d.ID, err))
+	}
+}
+
+// retryDelay returns the wait after a number of failed attempts:
+// RetryDelay, doubled after each further one, up to maxRetryDelay.
+func (m *Manager) retryDelay(attempts int) time.Duration {
+	delay := m.cfg.RetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// permanent reports whether a receiver's answer means trying again would
+// not help: a client error other than a timeout or too many requests.
+func permanent(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// post sends a body to a webhook once, signed if it has a secret, and
+// returns the status of the answer. Anything but 2xx is an error.
+func (m *Manager) post(ctx context.Context, wh *Webhook, event, deliveryID string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ModBridge-Webhook")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if wh.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(wh.Secret, timestamp, body))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// TestWebhook posts a made-up alert to a webhook once, disabled or not, and
+// returns the status of the answer.
+func (m *Manager) TestWebhook(ctx context.Context, id string) (int, error) {
+	wh, err := m.Webhook(id)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	body, err := json.Marshal(WebhookPayload{Event: EventTest, Timestamp: now, Alert: database.Alert{
+		RuleName: "Test",
+		Severity: SeverityInfo,
+		Message:  "Test message from ModBridge",
+		State:    database.AlertFiring,
+		FiredAt:  now,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return m.post(ctx, &wh, EventTest, "test", body)
+}
+
+// DeadLetters returns the deliveries given up on, newest first.
+func (m *Manager) DeadLetters(limit, offset int) ([]*database.WebhookDelivery, error) {
+	return m.db.QueryWebhookDeliveries(database.DeliveryFilter{State: database.DeliveryFailed, Limit: limit, Offset: offset})
+}
+
+// Redeliver queues a dead letter again, with its attempts starting over.
+func (m *Manager) Redeliver(id int64) (*database.WebhookDelivery, error) {
+	d, err := m.deadLetter(id)
+	if err != nil {
+		return nil, err
+	}
+	d.State = database.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	if err := m.db.UpdateWebhookDelivery(d); err != nil {
+		return nil, fmt.Errorf("failed to save delivery: %w", err)
+	}
+	m.wakeDeliveries()
+	return d, nil
+}
+
+// DiscardDeadLetter removes a dead letter for good.
+func (m *Manager) DiscardDeadLetter(id int64) error {
+	if _, err := m.deadLetter(id); err != nil {
+		return err
+	}
+	if _, err := m.db.DeleteWebhookDelivery(id); err != nil {
+		return fmt.Errorf("failed to delete delivery: %w", err)
+	}
+	return nil
+}
+
+// deadLetter returns a delivery that was given up on.
+func (m *Manager) deadLetter(id int64) (*database.WebhookDelivery, error) {
+	d, err := m.db.GetWebhookDelivery(id)
+	switch {
+	case err != nil:
+		return nil, err
+	case d == nil:
+		return nil, fmt.Errorf("delivery %d: %w", id, ErrNotFound)
+	case d.State != database.DeliveryFailed:
+		return nil, fmt.Errorf("delivery %d: %w", id, ErrPending)
+	}
+	return d, nil
+}
@@ -0,0 +1,495 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/alerting"
+	"modbridge/pkg/database"
+	"modbridge/pkg/rbac"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxAlertLimit bounds one page of the alert history.
+const maxAlertLimit = 1000
+
+// handleAlerts serves the alerting:
+//
+//	GET    /api/alerts                               the alert history, newest first
+//	GET    /api/alerts/{id}                          one alert
+//	POST   /api/alerts/{id}/acknowledge              someone is on it
+//	POST   /api/alerts/{id}/resolve                  close it by hand
+//	GET    /api/alerts/rules                         the rules
+//	POST   /api/alerts/rules                         add a rule
+//	GET    /api/alerts/rules/{id}                    one rule
+//	PUT    /api/alerts/rules/{id}                    replace a rule
+//	DELETE /api/alerts/rules/{id}                    remove a rule and resolve its alerts
+//	GET    /api/alerts/webhooks                      the webhooks, without their secrets
+//	POST   /api/alerts/webhooks                      add a webhook
+//	GET    /api/alerts/webhooks/{id}                 one webhook
+//	PUT    /api/alerts/webhooks/{id}                 replace a webhook; an empty secret keeps the stored one
+//	DELETE /api/alerts/webhooks/{id}                 remove a webhook
+//	POST   /api/alerts/webhooks/{id}/test            post a test message once
+//	GET    /api/alerts/dead-letters                  webhook deliveries given up on, newest first
+//	POST   /api/alerts/dead-letters/{id}/redeliver   queue one again
+//	DELETE /api/alerts/dead-letters/{id}             discard one
+//
+// The history takes ?state= (firing, acknowledged, resolved or open, comma
+// separated), ?rule_id=, ?proxy_id=, ?limit= and ?offset=; the dead letters
+// take ?limit= and ?offset=.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/alerts"), "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+	switch {
+	case len(parts) == 3 && parts[0] == "webhooks" && parts[2] == "test":
+		s.handleWebhookTest(w, r, parts[1])
+		return
+	case len(parts) > 0 && parts[0] == "dead-letters":
+		s.handleDeadLetters(w, r, parts[1:])
+		return
+	}
+	if len(parts) > 0 && (parts[0] == "rules" || parts[0] == "webhooks") {
+		if len(parts) > 2 {
+			http.NotFound(w, r)
+			return
+		}
+		id := ""
+		if len(parts) == 2 {
+			id = parts[1]
+		}
+		if parts[0] == "rules" {
+			s.handleAlertRules(w, r, id)
+		} else {
+			s.handleAlertWebhooks(w, r, id)
+		}
+		return
+	}
+
+	switch {
+	case len(parts) == 0:
+		s.handleAlertList(w, r)
+	case len(parts) == 1:
+		s.handleAlertByID(w, r, parts[0], "")
+	case len(parts) == 2 && (parts[1] == "acknowledge" || parts[1] == "resolve"):
+		s.handleAlertByID(w, r, parts[0], parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAlertList answers GET /api/alerts.
+func (s *Server) handleAlertList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermAlertView) == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := database.AlertFilter{RuleID: q.Get("rule_id"), ProxyID: q.Get("proxy_id"), Limit: 100}
+	for _, state := range strings.Split(q.Get("state"), ",") {
+		switch state = strings.TrimSpace(state); state {
+		case "":
+		case "open":
+			filter.States = append(filter.States, database.AlertFiring, database.AlertAcknowledged)
+		case database.AlertFiring, database.AlertAcknowledged, database.AlertResolved:
+			filter.States = append(filter.States, state)
+		default:
+			http.Error(w, "state must be firing, acknowledged, resolved or open", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			filter.Limit = min(n, maxAlertLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			filter.Offset = n
+		}
+	}
+
+	list, err := alerts.Alerts(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to load alerts: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []*database.Alert{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, list)
+}
+
+// handleAlertByID shows an alert, or acknowledges or resolves it.
+func (s *Server) handleAlertByID(w http.ResponseWriter, r *http.Request, rawID, action string) {
+	if action == "" && r.Method != http.MethodGet || action != "" && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	permission := rbac.PermAlertView
+	if action != "" {
+		permission = rbac.PermAlertAcknowledge
+	}
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	var alert *database.Alert
+	auditAction := ""
+	switch action {
+	case "":
+		alert, err = alerts.Alert(id)
+	case "acknowledge":
+		auditAction = "alert.acknowledged"
+		alert, err = alerts.Acknowledge(id, session.Username)
+	default:
+		auditAction = "alert.resolved"
+		alert, err = alerts.Resolve(id, session.Username)
+	}
+	if auditAction != "" && s.auditor != nil {
+		ip, ua := requestMeta(r)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		details := ""
+		if alert != nil {
+			details = alert.Message
+		}
+		s.auditor.LogAction(auditAction, "alert", rawID, session.UserID, session.Username, details, ip, ua, err == nil, errMsg)
+	}
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, alert)
+}
+
+// handleAlertRules serves /api/alerts/rules and /api/alerts/rules/{id}.
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request, id string) {
+	permission, ok := alertPermission(r.Method, id, rbac.PermAlertView)
+	if !ok {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+
+	var rule alerting.Rule
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if id == "" {
+			s.writeJSON(w, alerts.Rules())
+			return
+		}
+		if rule, err = alerts.Rule(id); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		s.writeJSON(w, rule)
+		return
+	case http.MethodDelete:
+		rule, _ = alerts.Rule(id)
+		err = alerts.DeleteRule(id, session.Username)
+		s.auditAlertChange(r, session.UserID, session.Username, "alert.rule.deleted", "alert_rule", id, rule.Name, err)
+		if err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// A rule is created to be evaluated.
+	req := alerting.Rule{Enabled: true}
+	if err := decodeJSON(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := "alert.rule.updated"
+	if r.Method == http.MethodPost {
+		action = "alert.rule.created"
+		rule, err = alerts.AddRule(req, session.Username)
+		id = rule.ID
+	} else {
+		rule, err = alerts.UpdateRule(id, req)
+	}
+	s.auditAlertChange(r, session.UserID, session.Username, action, "alert_rule", id, req.Name, err)
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	s.writeJSON(w, rule)
+}
+
+// handleAlertWebhooks serves /api/alerts/webhooks and
+// /api/alerts/webhooks/{id}. A webhook's URL may carry a token, so even
+// reading them takes the permission to manage them.
+func (s *Server) handleAlertWebhooks(w http.ResponseWriter, r *http.Request, id string) {
+	permission, ok := alertPermission(r.Method, id, rbac.PermAlertManage)
+	if !ok {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+
+	var webhook alerting.Webhook
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if id == "" {
+			list := alerts.Webhooks()
+			for i := range list {
+				list[i].Secret = ""
+			}
+			s.writeJSON(w, list)
+			return
+		}
+		if webhook, err = alerts.Webhook(id); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		webhook.Secret = ""
+		s.writeJSON(w, webhook)
+		return
+	case http.MethodDelete:
+		webhook, _ = alerts.Webhook(id)
+		err = alerts.DeleteWebhook(id)
+		s.auditAlertChange(r, session.UserID, session.Username, "alert.webhook.deleted", "alert_webhook", id, webhook.Name, err)
+		if err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	req := alerting.Webhook{Enabled: true}
+	if err := decodeJSON(w, r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := "alert.webhook.updated"
+	if r.Method == http.MethodPost {
+		action = "alert.webhook.created"
+		webhook, err = alerts.AddWebhook(req)
+		id = webhook.ID
+	} else {
+		webhook, err = alerts.UpdateWebhook(id, req)
+	}
+	s.auditAlertChange(r, session.UserID, session.Username, action, "alert_webhook", id, req.Name, err)
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+	webhook.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	s.writeJSON(w, webhook)
+}
+
+// handleWebhookTest posts a test message to a webhook, enabled or not, and
+// answers with the status the receiver gave.
+func (s *Server) handleWebhookTest(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermAlertManage)
+	if session == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+
+	webhook, _ := alerts.Webhook(id)
+	status, err := alerts.TestWebhook(r.Context(), id)
+	s.auditAlertChange(r, session.UserID, session.Username, "alert.webhook.tested", "alert_webhook", id, webhook.Name, err)
+	switch {
+	case errors.Is(err, alerting.ErrNotFound):
+		writeAlertError(w, err)
+		return
+	case err != nil:
+		// The server is fine; the receiver or the webhook's settings are not.
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, map[string]interface{}{"status": "sent", "http_status": status})
+}
+
+// handleDeadLetters lists the webhook deliveries given up on, and
+// redelivers or discards one.
+func (s *Server) handleDeadLetters(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet,
+		len(parts) == 1 && r.Method == http.MethodDelete,
+		len(parts) == 2 && parts[1] == "redeliver" && r.Method == http.MethodPost:
+	case len(parts) > 2 || len(parts) == 2 && parts[1] != "redeliver":
+		http.NotFound(w, r)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// A delivery holds the webhook's alert, and is only ever seen by those
+	// who manage the webhooks.
+	session := s.requirePermission(w, r, rbac.PermAlertManage)
+	if session == nil {
+		return
+	}
+	alerts := s.alerting(w)
+	if alerts == nil {
+		return
+	}
+
+	if len(parts) == 0 {
+		limit, offset := 100, 0
+		q := r.URL.Query()
+		if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+			limit = min(n, maxAlertLimit)
+		}
+		if n, err := strconv.Atoi(q.Get("offset")); err == nil && n >= 0 {
+			offset = n
+		}
+		list, err := alerts.DeadLetters(limit, offset)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to load dead letters: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []*database.WebhookDelivery{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		s.writeJSON(w, list)
+		return
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		err = alerts.DiscardDeadLetter(id)
+		s.auditAlertChange(r, session.UserID, session.Username, "alert.delivery.discarded", "webhook_delivery", parts[0], "", err)
+		if err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	delivery, err := alerts.Redeliver(id)
+	s.auditAlertChange(r, session.UserID, session.Username, "alert.delivery.redelivered", "webhook_delivery", parts[0], "", err)
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	s.writeJSON(w, delivery)
+}
+
+// alertPermission returns what a request on a rule or webhook takes:
+// reading the list or one, adding to the list, changing one. ok is false for
+// a method that does not fit the path.
+func alertPermission(method, id string, view rbac.Permission) (rbac.Permission, bool) {
+	switch method {
+	case http.MethodGet:
+		return view, true
+	case http.MethodPost:
+		return rbac.PermAlertManage, id == ""
+	case http.MethodPut, http.MethodDelete:
+		return rbac.PermAlertManage, id != ""
+	}
+	return "", false
+}
+
+// auditAlertChange records a change to a rule or webhook.
+func (s *Server) auditAlertChange(r *http.Request, userID, username, action, resourceType, id, name string, err error) {
+	if s.auditor == nil {
+		return
+	}
+	ip, ua := requestMeta(r)
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	s.auditor.LogAction(action, resourceType, id, userID, username, name, ip, ua, err == nil, errMsg)
+}
+
+// alerting returns the alerting, or answers that there is none.
+func (s *Server) alerting(w http.ResponseWriter) *alerting.Manager {
+	if s.mgr == nil || s.mgr.Alerting() == nil {
+		http.Error(w, "Alerting unavailable", http.StatusServiceUnavailable)
+		return nil
+	}
+	return s.mgr.Alerting()
+}
+
+// writeAlertError answers with the status that fits an alerting error.
+func writeAlertError(w http.ResponseWriter, err error) {
+	var invalid *alerting.ValidationError
+	switch {
+	case errors.Is(err, alerting.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, alerting.ErrResolved), errors.Is(err, alerting.ErrPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
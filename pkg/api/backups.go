@@ -0,0 +1,189 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/backup"
+	"modbridge/pkg/rbac"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// backupListResponse is the body of GET /api/backups.
+type backupListResponse struct {
+	Status  backup.Status `json:"status"`
+	Backups []backup.Info `json:"backups"`
+}
+
+// restoreBackupRequest is the body of POST /api/backups/{name}/restore.
+type restoreBackupRequest struct {
+	Passphrase string `json:"passphrase"` // For an encrypted backup (empty = the configured one)
+	Config     *bool  `json:"config"`     // Restore the configuration (default: if the backup has it)
+	Database   *bool  `json:"database"`   // Restore the database (default: if the backup has it)
+}
+
+// handleBackups lists the backups and makes one on request:
+//
+//	GET  /api/backups  the backups, newest first, and the schedule
+//	POST /api/backups  make a backup now
+//
+// A backup holds the whole configuration and every password hash, so both
+// take the permission to export the configuration.
+func (s *Server) handleBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermConfigExport)
+	if session == nil {
+		return
+	}
+	backups := s.backupService(w)
+	if backups == nil {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		info, err := backups.Run()
+		if s.auditor != nil {
+			ip, ua := requestMeta(r)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			s.auditor.LogAction("backup.created", "backup", info.Name, session.UserID, session.Username, "", ip, ua, err == nil, errMsg)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		s.writeJSON(w, info)
+		return
+	}
+
+	list, err := backups.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to list backups: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, backupListResponse{Status: backups.Status(), Backups: list})
+}
+
+// handleBackupByName downloads or restores a backup:
+//
+//	GET  /api/backups/{name}          the backup file
+//	POST /api/backups/{name}/restore  put its contents back
+func (s *Server) handleBackupByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/backups/"), "/")
+	if rest, ok := strings.CutSuffix(name, "/restore"); ok {
+		s.handleBackupRestore(w, r, rest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermConfigExport)
+	if session == nil {
+		return
+	}
+	backups := s.backupService(w)
+	if backups == nil {
+		return
+	}
+
+	path, info, err := backups.Path(name)
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+	f, err := os.Open(path) // #nosec G304 -- path comes from the backup list, not the request
+	if err != nil {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		s.auditor.LogAction("backup.downloaded", "backup", info.Name, session.UserID, session.Username, "", ip, ua, true, "")
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+info.Name)
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// handleBackupRestore restores a backup. This replaces the configuration
+// and the user accounts, so it takes the permission to import a
+// configuration.
+func (s *Server) handleBackupRestore(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermConfigImport)
+	if session == nil {
+		return
+	}
+	if s.backupService(w) == nil {
+		return
+	}
+
+	var req restoreBackupRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	restoreConfig := req.Config == nil || *req.Config
+	restoreDatabase := req.Database == nil || *req.Database
+
+	result, err := s.mgr.RestoreBackup(name, req.Passphrase, restoreConfig, restoreDatabase)
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		details := fmt.Sprintf("config: %v, database: %v", result.Config, result.Database)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		s.auditor.LogAction("backup.restored", "backup", name, session.UserID, session.Username, details, ip, ua, err == nil, errMsg)
+	}
+	if err != nil {
+		writeBackupError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, result)
+}
+
+// backupService returns the backup service, or answers that there is none.
+func (s *Server) backupService(w http.ResponseWriter) *backup.Service {
+	if s.mgr == nil || s.mgr.Backups() == nil {
+		http.Error(w, "Backups unavailable", http.StatusServiceUnavailable)
+		return nil
+	}
+	return s.mgr.Backups()
+}
+
+// writeBackupError answers with the status that fits a backup error.
+func writeBackupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, backup.ErrPassphrase):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
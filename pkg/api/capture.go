@@ -0,0 +1,214 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/capture"
+	"modbridge/pkg/rbac"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// handleProxyResource serves what lives under /api/proxies/{id}/: its data
+// points and its capture.
+func (s *Server) handleProxyResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/proxies/"), "/"), "/")
+	if len(parts) == 2 && parts[1] == "capture" {
+		s.handleProxyCapture(w, r, parts[0])
+		return
+	}
+	s.handleProxyPoints(w, r)
+}
+
+// startCaptureRequest is the body of POST /api/proxies/{id}/capture.
+type startCaptureRequest struct {
+	Format        string `json:"format"`          // pcapng (default) or jsonl
+	MaxBytes      int64  `json:"max_bytes"`       // 0 = 10 MiB
+	MaxDurationMs int64  `json:"max_duration_ms"` // 0 = 10 minutes
+}
+
+// handleProxyCapture starts, shows and stops the capture of a proxy:
+//
+//	GET    /api/proxies/{id}/capture  the running capture
+//	POST   /api/proxies/{id}/capture  start one
+//	DELETE /api/proxies/{id}/capture  stop it
+//
+// A capture holds every value the clients read and write, so looking at one
+// takes the permission to read the logs, and starting one the permission to
+// control the proxy.
+func (s *Server) handleProxyCapture(w http.ResponseWriter, r *http.Request, proxyID string) {
+	permissionByMethod := map[string]rbac.Permission{
+		http.MethodGet:    rbac.PermLogsView,
+		http.MethodPost:   rbac.PermProxyControl,
+		http.MethodDelete: rbac.PermProxyControl,
+	}
+	permission, exists := permissionByMethod[r.Method]
+	if !exists {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	ip, ua := requestMeta(r)
+
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := s.mgr.GetProxyInstance(proxyID); !ok {
+		http.Error(w, "Proxy not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		active := s.mgr.Captures().Active(proxyID)
+		if active == nil {
+			http.Error(w, "No capture running", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		s.writeJSON(w, active.Info())
+
+	case http.MethodPost:
+		var req startCaptureRequest
+		if r.ContentLength != 0 {
+			if err := decodeJSON(w, r, &req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		info, err := s.mgr.StartCapture(proxyID, capture.Options{
+			Format:      req.Format,
+			MaxBytes:    req.MaxBytes,
+			MaxDuration: time.Duration(req.MaxDurationMs) * time.Millisecond,
+		})
+		if s.auditor != nil {
+			s.auditor.LogProxyAction("proxy.capture.started", proxyID, session.UserID, session.Username, fmt.Sprintf("format %s", req.Format), ip, ua, err == nil)
+		}
+		switch {
+		case errors.Is(err, capture.ErrActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		s.writeJSON(w, info)
+
+	case http.MethodDelete:
+		info, ok := s.mgr.StopCapture(proxyID)
+		if s.auditor != nil {
+			s.auditor.LogProxyAction("proxy.capture.stopped", proxyID, session.UserID, session.Username, info.ID, ip, ua, ok)
+		}
+		if !ok {
+			http.Error(w, "No capture running", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		s.writeJSON(w, info)
+	}
+}
+
+// handleCaptures lists the captures of every proxy: GET /api/captures.
+func (s *Server) handleCaptures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermLogsView) == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	list, err := s.mgr.Captures().List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to list captures: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, list)
+}
+
+// handleCaptureByID downloads or removes a finished capture:
+//
+//	GET    /api/captures/{id}  the capture file
+//	DELETE /api/captures/{id}  remove it
+func (s *Server) handleCaptureByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/captures/"), "/")
+	permissionByMethod := map[string]rbac.Permission{
+		http.MethodGet:    rbac.PermLogsExport,
+		http.MethodDelete: rbac.PermProxyControl,
+	}
+	permission, exists := permissionByMethod[r.Method]
+	if !exists {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	captures := s.mgr.Captures()
+
+	if r.Method == http.MethodDelete {
+		err := captures.Delete(id)
+		if s.auditor != nil {
+			ip, ua := requestMeta(r)
+			s.auditor.LogAction("capture.deleted", "capture", id, session.UserID, session.Username, "", ip, ua, err == nil, "")
+		}
+		writeCaptureError(w, err)
+		return
+	}
+
+	path, info, err := captures.Path(id)
+	if err != nil {
+		writeCaptureError(w, err)
+		return
+	}
+	f, err := os.Open(path) // #nosec G304 -- path comes from the capture list, not the request
+	if err != nil {
+		http.Error(w, "Capture not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	contentType := "application/x-pcapng"
+	if info.Format == capture.FormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+info.ID+"."+info.Format)
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// writeCaptureError answers a failed capture operation; nil answers OK.
+func writeCaptureError(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, capture.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, capture.ErrActive):
+		http.Error(w, "capture is still running, stop it first", http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
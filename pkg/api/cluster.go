@@ -0,0 +1,188 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/cluster"
+	"modbridge/pkg/configsync"
+	"modbridge/pkg/rbac"
+	"net/http"
+)
+
+// handleClusterPeer serves the heartbeats of the other nodes at
+// /cluster/join and /cluster/members, and the replicated configuration at
+// /cluster/config. They carry no session; the cluster checks their
+// signature. Without a cluster there is nothing here.
+func (s *Server) handleClusterPeer(w http.ResponseWriter, r *http.Request) {
+	var c *cluster.Cluster
+	if s.mgr != nil {
+		c = s.mgr.Cluster()
+	}
+	if c == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Path == configsync.Path {
+		syncer := s.mgr.ConfigSync()
+		if syncer == nil {
+			http.NotFound(w, r)
+			return
+		}
+		syncer.HandleConfig(w, r)
+		return
+	}
+	if r.URL.Path == "/cluster/members" {
+		c.HandleMembers(w, r)
+		return
+	}
+	c.HandleJoin(w, r)
+}
+
+// clusterStatus is the answer of GET /api/cluster.
+type clusterStatus struct {
+	Enabled bool `json:"enabled"`
+	*cluster.Status
+}
+
+// handleCluster reports the role of this node and what it knows about the
+// others.
+func (s *Server) handleCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermSystemView) == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := clusterStatus{}
+	if c := s.mgr.Cluster(); c != nil {
+		st := c.Status()
+		resp = clusterStatus{Enabled: true, Status: &st}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, resp)
+}
+
+// handleClusterStepDown hands the proxies over to another node, for
+// maintenance of this one.
+func (s *Server) handleClusterStepDown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermSystemManage)
+	if session == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	c := s.mgr.Cluster()
+	if c == nil {
+		http.Error(w, "This node is not part of a cluster", http.StatusConflict)
+		return
+	}
+
+	err := c.StepDown()
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		s.auditor.LogAction("cluster.step_down", "system", "cluster", session.UserID, session.Username, "", ip, ua, err == nil, errMsg)
+	}
+	switch {
+	case errors.Is(err, cluster.ErrNotActive):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, c.Status())
+}
+
+// configSyncStatus is the answer of GET /api/config/sync.
+type configSyncStatus struct {
+	Enabled bool `json:"enabled"`
+	*configsync.Status
+}
+
+// handleConfigSync reports which version of the configuration this node
+// has, and whether the other nodes of the cluster have the same.
+func (s *Server) handleConfigSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermConfigView) == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := configSyncStatus{}
+	if syncer := s.mgr.ConfigSync(); syncer != nil {
+		st := syncer.Status()
+		resp = configSyncStatus{Enabled: true, Status: &st}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, resp)
+}
+
+// handleConfigSyncPush makes this node's configuration the one of every
+// node, to resolve a conflict.
+func (s *Server) handleConfigSyncPush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermConfigImport)
+	if session == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	syncer := s.mgr.ConfigSync()
+	if syncer == nil {
+		http.Error(w, "The configuration is not replicated on this node", http.StatusConflict)
+		return
+	}
+
+	version, err := syncer.Push()
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		s.auditor.LogAction("config.sync_push", "config", "cluster", session.UserID, session.Username, fmt.Sprintf("version %d", version), ip, ua, err == nil, errMsg)
+	}
+	switch {
+	case errors.Is(err, configsync.ErrNotRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, syncer.Status())
+}
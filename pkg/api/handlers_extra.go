@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -72,6 +73,13 @@ func (s *Server) handleConfigExport(w http.ResponseWriter, r *http.Request) {
 	cfg := s.cfgMgr.Get()
 	cfg.AdminPassHash = ""
 	cfg.EmailPassword = ""
+	cfg.BackupPassphrase = ""
+	if cfg.MQTT != nil {
+		cfg.MQTT.Password = ""
+	}
+	if cfg.Cluster != nil {
+		cfg.Cluster.Secret = ""
+	}
 	if err := json.NewEncoder(w).Encode(cfg); err != nil {
 		s.log.Error("API", fmt.Sprintf("Failed to encode config export response: %v", err))
 	}
@@ -131,6 +139,12 @@ func (s *Server) handleConfigImport(w http.ResponseWriter, r *http.Request) {
 	currentCfg := s.cfgMgr.Get()
 	newCfg.AdminPassHash = currentCfg.AdminPassHash
 	newCfg.EmailPassword = currentCfg.EmailPassword
+	if newCfg.BackupPassphrase == "" {
+		newCfg.BackupPassphrase = currentCfg.BackupPassphrase
+	}
+	if newCfg.Cluster != nil && newCfg.Cluster.Secret == "" && currentCfg.Cluster != nil {
+		newCfg.Cluster.Secret = currentCfg.Cluster.Secret
+	}
 
 	v := config.NewValidator()
 	if err := v.Validate(&newCfg); err != nil {
@@ -182,6 +196,13 @@ func (s *Server) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
 		// Sanitize sensitive fields before sending to client
 		cfg.AdminPassHash = ""
 		cfg.EmailPassword = ""
+		cfg.BackupPassphrase = ""
+		if cfg.MQTT != nil {
+			cfg.MQTT.Password = ""
+		}
+		if cfg.Cluster != nil {
+			cfg.Cluster.Secret = ""
+		}
 		w.Header().Set("Content-Type", "application/json")
 		s.writeJSON(w, cfg)
 		return
@@ -193,6 +214,39 @@ func (s *Server) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if err := config.ValidateMQTTConfig(req.MQTT); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := config.ValidateHistoryConfig(req.History); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Like the MQTT password, the cluster secret is never sent out.
+		if req.Cluster != nil && req.Cluster.Secret == "" {
+			if current := s.cfgMgr.Get().Cluster; current != nil {
+				req.Cluster.Secret = current.Secret
+			}
+		}
+		if err := config.ValidateClusterConfig(req.Cluster); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Rejoining hands the proxies over, so only do it on a change.
+		clusterChanged := req.Cluster != nil && !reflect.DeepEqual(req.Cluster, s.cfgMgr.Get().Cluster)
+		// The passphrase is never sent out, so a client that does not
+		// change it sends none.
+		if req.BackupPassphrase == "" {
+			req.BackupPassphrase = s.cfgMgr.Get().BackupPassphrase
+		}
+		if err := config.ValidateBackupConfig(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := config.ValidateEmailConfig(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		err := s.cfgMgr.Update(func(c *config.Config) error {
 			c.LogLevel = req.LogLevel
@@ -219,19 +273,40 @@ func (s *Server) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
 			c.EmailFrom = req.EmailFrom
 			c.EmailTo = req.EmailTo
 			c.EmailUsername = req.EmailUsername
-			c.EmailPassword = req.EmailPassword
+			// Like the MQTT password, the mail password is never sent out.
+			if req.EmailPassword != "" {
+				c.EmailPassword = req.EmailPassword
+			}
 			c.EmailAlertOnError = req.EmailAlertOnError
 			c.EmailAlertOnWarning = req.EmailAlertOnWarning
+			c.EmailSecurity = req.EmailSecurity
+			c.EmailThrottleMinutes = req.EmailThrottleMinutes
 			c.BackupEnabled = req.BackupEnabled
 			c.BackupInterval = req.BackupInterval
 			c.BackupRetention = req.BackupRetention
 			c.BackupPath = req.BackupPath
 			c.BackupDatabase = req.BackupDatabase
 			c.BackupConfig = req.BackupConfig
+			c.BackupEncrypt = req.BackupEncrypt
+			c.BackupPassphrase = req.BackupPassphrase
 			c.MetricsEnabled = req.MetricsEnabled
 			c.MetricsPort = req.MetricsPort
 			c.DebugMode = req.DebugMode
 			c.MaxConnections = req.MaxConnections
+			// Older clients do not send the MQTT block at all, and the
+			// password is never sent out: keep what is stored then.
+			if req.MQTT != nil {
+				if req.MQTT.Password == "" && c.MQTT != nil {
+					req.MQTT.Password = c.MQTT.Password
+				}
+				c.MQTT = req.MQTT
+			}
+			if req.History != nil {
+				c.History = req.History
+			}
+			if req.Cluster != nil {
+				c.Cluster = req.Cluster
+			}
 			return nil
 		})
 
@@ -242,9 +317,29 @@ func (s *Server) handleSystemConfig(w http.ResponseWriter, r *http.Request) {
 
 		// Apply log level change immediately to the running logger
 		s.log.SetLogLevel(logger.LogLevel(req.LogLevel))
+		if req.MQTT != nil && s.mgr != nil {
+			s.mgr.ReloadMQTT()
+		}
+		if req.History != nil && s.mgr != nil {
+			s.mgr.ReloadHistory()
+		}
+		if clusterChanged && s.mgr != nil {
+			s.mgr.ReloadCluster()
+		}
+		if s.mgr != nil {
+			s.mgr.ReloadBackups()
+			s.mgr.ReloadNotifications()
+		}
 
+		resp := map[string]interface{}{"status": "ok"}
+		// In a cluster the change is a new version, on its way to the others.
+		if s.mgr != nil {
+			if syncer := s.mgr.ConfigSync(); syncer != nil {
+				resp["sync"] = syncer.Status()
+			}
+		}
 		w.Header().Set("Content-Type", "application/json")
-		s.writeJSON(w, map[string]string{"status": "ok"})
+		s.writeJSON(w, resp)
 		return
 	}
 }
@@ -287,6 +382,11 @@ func (s *Server) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
 		"num_cpu":         runtime.NumCPU(),
 		"total_proxies":   len(proxies),
 		"running_proxies": runningProxies,
+		"mqtt":            s.mgr.MQTTStats(),
+		"history":         s.mgr.HistoryStats(),
+		"notifications":   s.mgr.NotificationStats(),
+		"alerting":        s.mgr.AlertingStats(),
+		"cluster":         s.mgr.ClusterStats(),
 		"go_version":      runtime.Version(),
 		"os":              runtime.GOOS,
 		"arch":            runtime.GOARCH,
@@ -469,6 +569,10 @@ func (s *Server) handleProxyConnectivityCheck(w http.ResponseWriter, r *http.Req
 	results := make(map[string]map[string]interface{})
 
 	for _, proxy := range cfg.Proxies {
+		if len(proxy.Targets) > 0 {
+			results[proxy.ID] = checkTargetsReachable(proxy)
+			continue
+		}
 		testConn, err := net.DialTimeout("tcp", proxy.TargetAddr, 5*time.Second)
 		isReachable := err == nil
 		var errorMsg string
@@ -495,6 +599,41 @@ func (s *Server) handleProxyConnectivityCheck(w http.ResponseWriter, r *http.Req
 	s.writeJSON(w, results)
 }
 
+// checkTargetsReachable checks every target of a proxy that has several. The
+// proxy counts as reachable while one of them is, as it can still serve.
+func checkTargetsReachable(proxy config.ProxyConfig) map[string]interface{} {
+	targets := make([]map[string]interface{}, 0, len(proxy.Targets))
+	var addrs, errs []string
+	reachable := false
+	for _, t := range proxy.Targets {
+		addrs = append(addrs, t.TargetAddr)
+		result := map[string]interface{}{"target": t.TargetAddr, "reachable": true, "error": ""}
+		testConn, err := net.DialTimeout("tcp", t.TargetAddr, 5*time.Second)
+		if err != nil {
+			result["reachable"] = false
+			result["error"] = err.Error()
+			errs = append(errs, err.Error())
+		} else {
+			testConn.Close()
+			reachable = true
+		}
+		targets = append(targets, result)
+	}
+	errorMsg := ""
+	if !reachable {
+		errorMsg = strings.Join(errs, "; ")
+	}
+	return map[string]interface{}{
+		"name":        proxy.Name,
+		"target":      strings.Join(addrs, ", "),
+		"targets":     targets,
+		"reachable":   reachable,
+		"error":       errorMsg,
+		"status":      "unknown",
+		"listen_addr": proxy.ListenAddr,
+	}
+}
+
 func (s *Server) handleAuditLogs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,111 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"fmt"
+	"modbridge/pkg/timeseries"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// defaultHistorySpan is the range of a history query without from.
+const defaultHistorySpan = 24 * time.Hour
+
+// unsafeFilename matches what does not belong in a download's file name.
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// handlePointHistory serves the recorded values of one data point:
+//
+//	GET /api/proxies/{id}/points/{point}/history?from=&to=&resolution=&format=
+//
+// from and to are RFC 3339 times (default: the last 24 hours). resolution is
+// raw, 1m, 1h or auto (default), which picks the finest one that suits the
+// range. format=csv downloads the values as CSV instead of JSON.
+//
+// {point} is the point's ID or name. A name that is no longer configured
+// still finds the history recorded under it, so a point removed since can
+// be looked at too.
+func (s *Server) handlePointHistory(w http.ResponseWriter, r *http.Request, proxyID, pointID string) {
+	h := s.mgr.History()
+	if h == nil {
+		http.Error(w, "History is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	point := pointID
+	if points, err := s.mgr.GetPoints(proxyID); err == nil {
+		for _, pt := range points {
+			if pt.ID == pointID {
+				point = pt.Name
+				break
+			}
+		}
+	}
+
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "to must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultHistorySpan)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "from must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	resolution, err := h.Resolve(q.Get("resolution"), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch q.Get("format") {
+	case "", "json":
+	case "csv":
+		filename := unsafeFilename.ReplaceAllString(fmt.Sprintf("%s_%s_%s.csv", proxyID, point, resolution), "_")
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+		if err := h.ExportCSV(w, proxyID, point, resolution, from, to); err != nil {
+			s.log.Error("API", fmt.Sprintf("Failed to export history of %s/%s: %v", proxyID, point, err))
+			http.Error(w, "Failed to export history", http.StatusInternalServerError)
+		}
+		return
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	samples, err := h.Query(proxyID, point, resolution, from, to)
+	if err != nil {
+		s.log.Error("API", fmt.Sprintf("Failed to query history of %s/%s: %v", proxyID, point, err))
+		http.Error(w, "Failed to query history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, map[string]interface{}{
+		"proxy_id":   proxyID,
+		"point":      point,
+		"resolution": resolution,
+		"from":       from,
+		"to":         to,
+		"stats":      timeseries.Aggregate(samples),
+		"samples":    samples,
+	})
+}
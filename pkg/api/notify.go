@@ -0,0 +1,198 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"modbridge/pkg/notify"
+	"modbridge/pkg/proxy"
+	"modbridge/pkg/rbac"
+	"modbridge/pkg/updater"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failed logins from one address that make an alert: loginAlertFailures
+// within loginAlertWindow.
+const (
+	loginAlertFailures = 5
+	loginAlertWindow   = 10 * time.Minute
+)
+
+// How often, and how soon after the start, the server looks for a new
+// release to mail about.
+var (
+	updateCheckInterval = 24 * time.Hour
+	updateCheckDelay    = 5 * time.Minute
+)
+
+// handleTestEmail mails a test message with the stored settings:
+//
+//	POST /api/system/test-email
+func (s *Server) handleTestEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermConfigEdit)
+	if session == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := s.mgr.SendTestMail(r.Context())
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		s.auditor.LogAction("email.test", "system", "email", session.UserID, session.Username, "", ip, ua, err == nil, errMsg)
+	}
+	if err != nil {
+		// The server is fine; the mail server or the settings are not.
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, map[string]string{"status": "sent"})
+}
+
+// loginFailures counts failed logins per client address, to tell someone
+// guessing passwords from someone who mistyped one.
+type loginFailures struct {
+	mu     sync.Mutex
+	byAddr map[string]*loginFailureWindow
+}
+
+// loginFailureWindow counts the failures of one address since start.
+type loginFailureWindow struct {
+	start time.Time
+	count int
+}
+
+// record counts a failed login and reports whether it is the one that makes
+// an alert. An address alerts at most once per window.
+func (f *loginFailures) record(addr string, now time.Time) (count int, alert bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.byAddr == nil {
+		f.byAddr = make(map[string]*loginFailureWindow)
+	}
+	w := f.byAddr[addr]
+	if w == nil || now.Sub(w.start) >= loginAlertWindow {
+		if len(f.byAddr) >= 10000 {
+			for a, old := range f.byAddr {
+				if now.Sub(old.start) >= loginAlertWindow {
+					delete(f.byAddr, a)
+				}
+			}
+		}
+		w = &loginFailureWindow{start: now}
+		f.byAddr[addr] = w
+	}
+	w.count++
+	return w.count, w.count == loginAlertFailures
+}
+
+// loginFailed counts a failed login and reports a run of them.
+func (s *Server) loginFailed(r *http.Request, username string) {
+	if s.mgr == nil {
+		return
+	}
+	ip, _ := requestMeta(r)
+	count, alert := s.loginFailures.record(ip, time.Now())
+	if !alert {
+		return
+	}
+	s.mgr.Notify(notify.Event{
+		Kind:     notify.AuthFailures,
+		Severity: notify.SeverityWarning,
+		Subject:  ip,
+		Title:    fmt.Sprintf("%d failed logins from %s", count, ip),
+		Message: fmt.Sprintf("%d logins from %s failed within %v, the last one as %q. "+
+			"If that was not you, someone is guessing passwords.", count, ip, loginAlertWindow, username),
+	})
+}
+
+// notifyCalibration reports a finished calibration run.
+func (s *Server) notifyCalibration(proxyID string, result *proxy.CalibrationResult) {
+	if s.mgr == nil {
+		return
+	}
+	var notes []string
+	for _, n := range result.Notes {
+		notes = append(notes, "- "+n.Text)
+	}
+	msg := fmt.Sprintf("Calibration of proxy %s against %s took %v.\n\nRecommended: %d ms between requests, "+
+		"%d connection(s), %d s read timeout. Nothing was changed; apply the recommendation in the proxy settings.",
+		proxyID, result.TargetAddr, time.Duration(result.DurationMs)*time.Millisecond,
+		result.Recommended.MinRequestGapMs, result.Recommended.MaxTargetConns, result.Recommended.ReadTimeoutS)
+	if len(notes) > 0 {
+		msg += "\n\n" + strings.Join(notes, "\n")
+	}
+	s.mgr.Notify(notify.Event{
+		Kind:     notify.CalibrationDone,
+		Severity: notify.SeverityInfo,
+		ProxyID:  proxyID,
+		Subject:  result.TargetAddr,
+		Title:    fmt.Sprintf("%s: calibration finished", proxyID),
+		Message:  msg,
+	})
+}
+
+// watchUpdates looks for a new release once a day while mail alerts are on,
+// and reports each new version once.
+func (s *Server) watchUpdates(stop <-chan struct{}) {
+	timer := time.NewTimer(updateCheckDelay)
+	defer timer.Stop()
+	reported := ""
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		timer.Reset(updateCheckInterval)
+		if version, ok := s.checkForUpdate(reported); ok {
+			reported = version
+		}
+	}
+}
+
+// checkForUpdate mails about a release newer than the running version, unless
+// it is the one already reported. A development build never asks.
+func (s *Server) checkForUpdate(reported string) (string, bool) {
+	current := s.updater.CurrentVersion()
+	if s.mgr == nil || !s.mgr.NotificationsEnabled() || current == "" || current == "dev" {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	release, err := s.updater.CheckForUpdate(ctx)
+	if err != nil {
+		s.log.Warn("NOTIFY", fmt.Sprintf("Update check failed: %v", err))
+		return "", false
+	}
+	latest := strings.TrimPrefix(release.TagName, "v")
+	if newer, _ := updater.CompareVersions(current, latest); !newer || latest == reported {
+		return "", false
+	}
+	s.mgr.Notify(notify.Event{
+		Kind:     notify.UpdateAvailable,
+		Severity: notify.SeverityWarning,
+		Subject:  latest,
+		Title:    fmt.Sprintf("ModBridge %s is available", latest),
+		Message:  fmt.Sprintf("This server runs %s. Release notes: %s", current, release.HTMLURL),
+	})
+	return latest, true
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailuresAlertOncePerWindow(t *testing.T) {
+	var f loginFailures
+	now := time.Now()
+
+	alerts := 0
+	for i := 0; i < 2*loginAlertFailures; i++ {
+		if _, alert := f.record("192.0.2.7", now.Add(time.Duration(i)*time.Second)); alert {
+			alerts++
+		}
+	}
+	if alerts != 1 {
+		t.Errorf("%d alerts for %d failures in one window, want 1", alerts, 2*loginAlertFailures)
+	}
+
+	// Another address counts on its own.
+	if count, alert := f.record("192.0.2.8", now); count != 1 || alert {
+		t.Errorf("record() for another address = %d, %v, want 1, false", count, alert)
+	}
+
+	// After the window the count starts over.
+	if count, _ := f.record("192.0.2.7", now.Add(loginAlertWindow+time.Minute)); count != 1 {
+		t.Errorf("count after the window = %d, want 1", count)
+	}
+}
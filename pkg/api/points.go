@@ -0,0 +1,162 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/manager"
+	"modbridge/pkg/mapping"
+	"modbridge/pkg/rbac"
+	"net/http"
+	"strings"
+)
+
+// handleProxyPoints serves the named data points of a proxy:
+//
+//	GET    /api/proxies/{id}/points          every point with its current value
+//	POST   /api/proxies/{id}/points          add a point
+//	GET    /api/proxies/{id}/points/{point}  one point with its current value
+//	PUT    /api/proxies/{id}/points/{point}  replace a point
+//	DELETE /api/proxies/{id}/points/{point}  remove a point
+//	GET    /api/proxies/{id}/points/{point}/history  recorded values, see handlePointHistory
+//
+// {point} is a point's ID or its name, so a dashboard can ask for
+// /api/proxies/{id}/points/pv_power_w directly.
+func (s *Server) handleProxyPoints(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/proxies/"), "/"), "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[0] == "" || parts[1] != "points" || len(parts) == 4 && parts[3] != "history" {
+		http.NotFound(w, r)
+		return
+	}
+	proxyID, pointID := parts[0], ""
+	if len(parts) >= 3 {
+		pointID = parts[2]
+	}
+	history := len(parts) == 4
+
+	permissionByMethod := map[string]rbac.Permission{
+		http.MethodGet:    rbac.PermProxyView,
+		http.MethodPost:   rbac.PermProxyEdit,
+		http.MethodPut:    rbac.PermProxyEdit,
+		http.MethodDelete: rbac.PermProxyEdit,
+	}
+	permission, exists := permissionByMethod[r.Method]
+	// Points are added to the list and changed one by one.
+	if r.Method == http.MethodPost {
+		exists = pointID == ""
+	} else if r.Method != http.MethodGet {
+		exists = exists && pointID != "" && !history
+	}
+	if !exists {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := s.requirePermission(w, r, permission)
+	if session == nil {
+		return
+	}
+	ip, ua := requestMeta(r)
+
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := s.mgr.GetProxyInstance(proxyID); !ok {
+		http.Error(w, "Proxy not found", http.StatusNotFound)
+		return
+	}
+
+	if history {
+		s.handlePointHistory(w, r, proxyID, pointID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if pointID == "" {
+			values, err := s.mgr.ReadPoints(proxyID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			s.writeJSON(w, values)
+			return
+		}
+		value, err := s.mgr.ReadPoint(proxyID, pointID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, value)
+		return
+
+	case http.MethodDelete:
+		err := s.mgr.RemovePoint(proxyID, pointID)
+		if s.auditor != nil {
+			s.auditor.LogProxyAction("proxy.point.deleted", proxyID, session.UserID, session.Username, pointID, ip, ua, err == nil)
+		}
+		if err != nil {
+			writePointError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// POST and PUT carry a point. Enabled defaults to true: a point is
+	// created to be read.
+	req := mapping.Mapping{Enabled: true}
+	if err := decodeJSON(w, r, &req); err != nil {
+		if err == http.ErrBodyReadAfterClose {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	action := "proxy.point.created"
+	if r.Method == http.MethodPut {
+		action = "proxy.point.updated"
+	}
+	var saved mapping.Mapping
+	err := req.Validate()
+	switch {
+	case err != nil:
+		err = config.ValidationErrors{{Field: "point", Message: err.Error()}}
+	case r.Method == http.MethodPost:
+		saved, err = s.mgr.AddPoint(proxyID, req)
+	default:
+		saved, err = s.mgr.UpdatePoint(proxyID, pointID, req)
+	}
+	if s.auditor != nil {
+		s.auditor.LogProxyAction(action, proxyID, session.UserID, session.Username, req.Name, ip, ua, err == nil)
+	}
+	if err != nil {
+		writePointError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, saved)
+}
+
+// writePointError answers a failed change to a data point.
+func writePointError(w http.ResponseWriter, err error) {
+	var invalid config.ValidationErrors
+	switch {
+	case errors.Is(err, manager.ErrPointNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("failed to save point: %v", err), http.StatusInternalServerError)
+	}
+}
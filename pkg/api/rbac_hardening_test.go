@@ -158,6 +158,42 @@ func TestRBAC_UpdatePerform_BenutzerDenied(t *testing.T) {
 	denyWith(t, server, "benutzer", "u9", server.handleUpdatePerform, http.MethodPost, "/api/update/perform")
 }
 
+func TestRBAC_AlertRuleCreate_BenutzerDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "benutzer", "alert-rules", server.handleAlerts, http.MethodPost, "/api/alerts/rules")
+}
+
+func TestRBAC_AlertWebhooks_BenutzerDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "benutzer", "alert-webhooks", server.handleAlerts, http.MethodGet, "/api/alerts/webhooks")
+}
+
+func TestRBAC_AlertDeadLetters_BenutzerDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "benutzer", "alert-dead-letters", server.handleAlerts, http.MethodGet, "/api/alerts/dead-letters")
+}
+
+func TestRBAC_AlertResolve_AuditorDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "auditor", "alert-auditor", server.handleAlerts, http.MethodPost, "/api/alerts/1/resolve")
+}
+
+func TestRBAC_ClusterStepDown_BenutzerDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "benutzer", "cluster-step-down", server.handleClusterStepDown, http.MethodPost, "/api/cluster/step-down")
+}
+
+func TestRBAC_ConfigSyncPush_BenutzerDenied(t *testing.T) {
+	server, cleanup := auditedTestServer(t)
+	defer cleanup()
+	denyWith(t, server, "benutzer", "config-sync-push", server.handleConfigSyncPush, http.MethodPost, "/api/config/sync/push")
+}
+
 // Positive control: auditor role DOES have audit:view, so it must NOT be denied.
 // This catches the inverse bug (over-restrictive permission check).
 func TestRBAC_AuditLogs_AuditorAllowed(t *testing.T) {
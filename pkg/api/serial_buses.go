@@ -0,0 +1,27 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"modbridge/pkg/rbac"
+	"net/http"
+)
+
+// handleSerialBuses returns the shared serial buses, the proxies using each
+// one and the counters of every unit seen on it. Buses are defined in the
+// configuration; this endpoint only reports.
+func (s *Server) handleSerialBuses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermProxyView) == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, s.mgr.GetSerialBuses())
+}
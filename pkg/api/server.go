@@ -13,7 +13,6 @@ import (
 	"net/http/pprof"
 	"os"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -134,6 +133,10 @@ type Server struct {
 	userMgr          *users.Manager
 	auditor          *audit.Auditor
 	updater          *updater.Updater
+	loginFailures    loginFailures // Failed logins per address, for the alert on a run of them
+
+	stopUpdates     chan struct{} // Closed by Stop to end watchUpdates
+	stopUpdatesOnce sync.Once
 
 	restartSignal chan struct{}
 	restartOnce   sync.Once
@@ -191,6 +194,11 @@ func NewServer(cfg *config.Manager, mgr *manager.Manager, a *auth.Authenticator,
 	var auditorInstance *audit.Auditor
 	if db != nil {
 		auditorInstance = audit.NewAuditor(db)
+		if mgr != nil {
+			// Requests refused by a proxy's access policy belong in the
+			// same audit trail as the changes made through the API.
+			mgr.SetAuditor(auditorInstance)
+		}
 	}
 
 	srv := &Server{
@@ -208,6 +216,7 @@ func NewServer(cfg *config.Manager, mgr *manager.Manager, a *auth.Authenticator,
 		userMgr:          userMgr,
 		auditor:          auditorInstance,
 		restartSignal:    make(chan struct{}),
+		stopUpdates:      make(chan struct{}),
 		updater: updater.New("Xerolux/modbridge", updater.BuildInfo{
 			Version:   version,
 			BuildTime: buildTime,
@@ -218,6 +227,7 @@ func NewServer(cfg *config.Manager, mgr *manager.Manager, a *auth.Authenticator,
 	}
 
 	srv.wireDiagnostics()
+	go srv.watchUpdates(srv.stopUpdates)
 	return srv
 }
 
@@ -285,6 +295,11 @@ func (s *Server) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/logout", csrfMW(s.handleLogout))
 	mux.HandleFunc("/api/setup", s.cors.Middleware(s.security.Middleware(s.handleSetup)))
 
+	// Heartbeats between the nodes of a cluster, signed with its secret
+	mux.HandleFunc("/cluster/join", s.security.Middleware(s.handleClusterPeer))
+	mux.HandleFunc("/cluster/members", s.security.Middleware(s.handleClusterPeer))
+	mux.HandleFunc("/cluster/config", s.security.Middleware(s.handleClusterPeer))
+
 	// Pprof endpoints (debug mode only)
 	if os.Getenv("DEBUG") == "true" {
 		debugMW := compose(s.cors.Middleware, s.security.Middleware, authMiddleware)
@@ -305,6 +320,12 @@ func (s *Server) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/proxies/stream", authMW(s.handleProxiesStream))
 	mux.HandleFunc("/api/proxies/control", csrfMW(s.handleProxyControl))
 	mux.HandleFunc("/api/proxies/calibrate", csrfMW(s.handleProxyCalibrate))
+	mux.HandleFunc("/api/proxies/", csrfMW(s.handleProxyResource))
+	mux.HandleFunc("/api/sessions", authMW(s.handleSessions))
+	mux.HandleFunc("/api/sessions/", csrfMW(s.handleSessionByID))
+	mux.HandleFunc("/api/captures", authMW(s.handleCaptures))
+	mux.HandleFunc("/api/captures/", csrfMW(s.handleCaptureByID))
+	mux.HandleFunc("/api/serial-buses", authMW(s.handleSerialBuses))
 	mux.HandleFunc("/api/devices", csrfMW(s.handleDevices))
 	mux.HandleFunc("/api/devices/history", authMW(s.handleDeviceHistory))
 	mux.HandleFunc("/api/logs", authMW(s.handleLogs))
@@ -318,8 +339,17 @@ func (s *Server) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/config/webport", csrfMW(s.handleWebPort))
 	mux.HandleFunc("/api/config/password", csrfMW(s.handleChangePassword))
 	mux.HandleFunc("/api/config/system", csrfMW(s.handleSystemConfig))
+	mux.HandleFunc("/api/config/sync", authMW(s.handleConfigSync))
+	mux.HandleFunc("/api/config/sync/push", csrfMW(s.handleConfigSyncPush))
+	mux.HandleFunc("/api/backups", csrfMW(s.handleBackups))
+	mux.HandleFunc("/api/backups/", csrfMW(s.handleBackupByName))
+	mux.HandleFunc("/api/alerts", csrfMW(s.handleAlerts))
+	mux.HandleFunc("/api/alerts/", csrfMW(s.handleAlerts))
+	mux.HandleFunc("/api/cluster", authMW(s.handleCluster))
+	mux.HandleFunc("/api/cluster/step-down", csrfMW(s.handleClusterStepDown))
 	mux.HandleFunc("/api/system/restart", csrfMW(s.handleSystemRestart))
 	mux.HandleFunc("/api/system/info", authMW(s.handleSystemInfo))
+	mux.HandleFunc("/api/system/test-email", csrfMW(s.handleTestEmail))
 	mux.HandleFunc("/api/system/ports/diagnostics", csrfMW(s.handlePortDiagnostics))
 	mux.HandleFunc("/api/system/ports/release", csrfMW(s.handlePortRelease))
 	mux.HandleFunc("/api/system/ports/check", authMW(s.handleCheckProxyPorts))
@@ -342,6 +372,9 @@ func (s *Server) Stop() {
 	if s.loginRateLimiter != nil {
 		s.loginRateLimiter.Stop()
 	}
+	if s.stopUpdates != nil {
+		s.stopUpdatesOnce.Do(func() { close(s.stopUpdates) })
+	}
 }
 
 // handleHealth is a health check endpoint.
@@ -354,7 +387,8 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleReady is a readiness check endpoint.
+// handleReady is a readiness check endpoint. It fails while a proxy's health
+// probe finds its target unhealthy.
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -381,6 +415,16 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 		ready = false
 	} else {
 		checks["manager"] = "ok"
+		// Only targets with a health probe count: the operator configured
+		// what healthy means for them.
+		for id, health := range s.mgr.ProbedTargets() {
+			if health.Healthy {
+				checks["target:"+id] = "ok"
+			} else {
+				checks["target:"+id] = "unhealthy: " + health.LastError
+				ready = false
+			}
+		}
 	}
 
 	statusCode := http.StatusOK
@@ -408,97 +452,11 @@ func (s *Server) wireDiagnostics() {
 	s.metrics.SetDiagnosticsProvider(s.mgr.ProxyDiagnostics)
 }
 
-// handleMetrics returns Prometheus metrics.
+// handleMetrics returns Prometheus metrics. modbridge-headless serves the same
+// exposition on /metrics.
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-	var output strings.Builder
-	output.WriteString(s.metrics.GetPrometheusMetrics())
-
-	if s.mgr != nil {
-		proxies := s.mgr.GetProxies()
-		if len(proxies) > 0 {
-			output.WriteString("# HELP modbridge_proxy_requests_current Current request counter snapshot per proxy\n")
-			output.WriteString("# TYPE modbridge_proxy_requests_current gauge\n")
-			output.WriteString("# HELP modbridge_proxy_errors_current Current error counter snapshot per proxy\n")
-			output.WriteString("# TYPE modbridge_proxy_errors_current gauge\n")
-			output.WriteString("# HELP modbridge_proxy_active_connections_current Current active connections per proxy\n")
-			output.WriteString("# TYPE modbridge_proxy_active_connections_current gauge\n")
-			output.WriteString("# HELP modbridge_proxy_latency_p95_ms Current p95 latency in milliseconds per proxy\n")
-			output.WriteString("# TYPE modbridge_proxy_latency_p95_ms gauge\n")
-			output.WriteString("# HELP modbridge_proxy_status_running 1 when proxy is running, else 0\n")
-			output.WriteString("# TYPE modbridge_proxy_status_running gauge\n")
-
-			for _, proxyData := range proxies {
-				id, _ := proxyData["id"].(string)
-				if id == "" {
-					continue
-				}
-				label := fmt.Sprintf(`proxy_id="%s"`, escapePrometheusLabelValue(id))
-				output.WriteString(fmt.Sprintf("modbridge_proxy_requests_current{%s} %d\n", label, getInt64MetricValue(proxyData["requests"])))
-				output.WriteString(fmt.Sprintf("modbridge_proxy_errors_current{%s} %d\n", label, getInt64MetricValue(proxyData["errors"])))
-				output.WriteString(fmt.Sprintf("modbridge_proxy_active_connections_current{%s} %d\n", label, getInt64MetricValue(proxyData["active_connections"])))
-				output.WriteString(fmt.Sprintf("modbridge_proxy_latency_p95_ms{%s} %f\n", label, getFloat64MetricValue(proxyData["latency_p95_ms"])))
-
-				status, _ := proxyData["status"].(string)
-				running := 0
-				if status == "Running" {
-					running = 1
-				}
-				output.WriteString(fmt.Sprintf("modbridge_proxy_status_running{%s} %d\n", label, running))
-			}
-			output.WriteString("\n")
-		}
-	}
-
-	_, _ = w.Write([]byte(output.String()))
-}
-
-func escapePrometheusLabelValue(v string) string {
-	v = strings.ReplaceAll(v, "\\", "\\\\")
-	v = strings.ReplaceAll(v, "\"", "\\\"")
-	v = strings.ReplaceAll(v, "\n", "\\n")
-	v = strings.ReplaceAll(v, "\t", "\\t")
-	v = strings.ReplaceAll(v, "\x00", "\\0")
-	return v
-}
-
-func getInt64MetricValue(v interface{}) int64 {
-	switch n := v.(type) {
-	case int64:
-		return n
-	case int:
-		return int64(n)
-	case float64:
-		return int64(n)
-	case json.Number:
-		parsed, err := n.Int64()
-		if err == nil {
-			return parsed
-		}
-	}
-	return 0
-}
-
-func getFloat64MetricValue(v interface{}) float64 {
-	switch n := v.(type) {
-	case float64:
-		return n
-	case int64:
-		return float64(n)
-	case int:
-		return float64(n)
-	case json.Number:
-		parsed, err := n.Float64()
-		if err == nil {
-			return parsed
-		}
-	case string:
-		parsed, err := strconv.ParseFloat(n, 64)
-		if err == nil {
-			return parsed
-		}
-	}
-	return 0
+	_, _ = w.Write([]byte(s.metrics.GetPrometheusMetrics()))
 }
 
 func writeSSEHeartbeat(w io.Writer) error {
@@ -582,6 +540,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			if s.auditor != nil {
 				s.auditor.LogLogin(req.Username, ip, ua, "invalid credentials", false)
 			}
+			s.loginFailed(r, req.Username)
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
@@ -604,6 +563,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		if s.auditor != nil {
 			s.auditor.LogLogin("admin", ip, ua, "invalid password", false)
 		}
+		s.loginFailed(r, "admin")
 		http.Error(w, "Invalid password", http.StatusUnauthorized)
 		return
 	}
@@ -1160,8 +1120,9 @@ func (s *Server) handleProxies(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// On a node that stands by, the active one starts it.
 		if req.Enabled && !req.Paused {
-			if err := s.mgr.StartProxy(req.ID); err != nil {
+			if err := s.mgr.StartProxy(req.ID); err != nil && !errors.Is(err, manager.ErrStandby) {
 				s.log.Error(req.ID, fmt.Sprintf("Failed to start proxy after creation: %v", err))
 				if s.auditor != nil {
 					s.auditor.LogProxyAction("proxy.created", req.ID, session.UserID, session.Username, req.Name, ip, ua, false)
@@ -1320,6 +1281,7 @@ func (s *Server) handleProxyCalibrate(w http.ResponseWriter, r *http.Request) {
 		// it away, so this is reported and the result still goes back.
 		s.log.Error(req.ID, fmt.Sprintf("Failed to store calibration report: %v", err))
 	}
+	s.notifyCalibration(req.ID, result)
 
 	if s.auditor != nil {
 		ip, ua := requestMeta(r)
@@ -1372,6 +1334,8 @@ func (s *Server) handleProxyControl(w http.ResponseWriter, r *http.Request) {
 	case "start_all":
 		if len(req.IDs) > 0 {
 			err = s.mgr.StartProxies(req.IDs)
+		} else if s.mgr.Standby() {
+			err = manager.ErrStandby
 		} else {
 			s.mgr.StartAll()
 		}
@@ -1406,7 +1370,11 @@ func (s *Server) handleProxyControl(w http.ResponseWriter, r *http.Request) {
 				s.auditor.LogProxyAction(auditAction, req.ID, session.UserID, session.Username, "", ip, ua, false)
 			}
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, manager.ErrStandby) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 	if s.auditor != nil {
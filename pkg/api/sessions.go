@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package api
+
+import (
+	"modbridge/pkg/rbac"
+	"net/http"
+	"strings"
+)
+
+// handleSessions lists the live client connections of every proxy, or of one
+// with ?proxy_id=: GET /api/sessions.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.requirePermission(w, r, rbac.PermProxyView) == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	sessions := s.mgr.Sessions()
+	if proxyID := r.URL.Query().Get("proxy_id"); proxyID != "" {
+		filtered := sessions[:0]
+		for _, session := range sessions {
+			if session.ProxyID == proxyID {
+				filtered = append(filtered, session)
+			}
+		}
+		sessions = filtered
+	}
+	w.Header().Set("Content-Type", "application/json")
+	s.writeJSON(w, sessions)
+}
+
+// handleSessionByID ends a client session: DELETE /api/sessions/{id}. Ending
+// one cuts a client off a device, so it takes the permission to control the
+// proxy, and it is audited.
+func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := s.requirePermission(w, r, rbac.PermProxyControl)
+	if session == nil {
+		return
+	}
+	if s.mgr == nil {
+		http.Error(w, "Proxy manager unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/")
+	proxyID, ok := s.mgr.KickSession(id)
+	if s.auditor != nil {
+		ip, ua := requestMeta(r)
+		s.auditor.LogProxyAction("proxy.session.kicked", proxyID, session.UserID, session.Username, id, ip, ua, ok)
+	}
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
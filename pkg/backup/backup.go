@@ -0,0 +1,493 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package backup writes snapshots of the configuration and the database on a
+// schedule, keeps the most recent ones and unpacks one again for a restore.
+// A backup is one tar.gz file, encrypted with a passphrase if one is set, so
+// it can be downloaded and kept elsewhere as it is.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"modbridge/pkg/logger"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the files inside a backup.
+const (
+	ConfigFile   = "config.json"
+	DatabaseFile = "modbridge.db"
+)
+
+// maxConfigSize bounds the configuration read back from a backup.
+const maxConfigSize = 64 << 20
+
+// ErrNotFound is returned for a backup that does not exist.
+var ErrNotFound = errors.New("backup not found")
+
+// namePattern matches backup file names: the time of the backup in UTC and
+// whether it is encrypted.
+var namePattern = regexp.MustCompile(`^modbridge-backup-(\d{8}-\d{6})\.tar\.gz(\.enc)?$`)
+
+// Delays of the schedule. The first backup after a start waits a little so
+// it does not compete with the proxies starting up; a failed one is retried
+// sooner than the next regular one.
+var (
+	firstRunDelay = time.Minute
+	retryDelay    = 15 * time.Minute
+)
+
+// Config describes what is backed up, where to and how often.
+type Config struct {
+	Dir        string
+	Interval   time.Duration // Time between two backups (0 = only on request)
+	Retention  int           // Backups to keep (0 = all)
+	Config     bool          // Include the configuration
+	Database   bool          // Include the database
+	Passphrase string        // Encrypt with this passphrase (empty = plain)
+}
+
+// Sources provides what goes into a backup.
+type Sources struct {
+	Config   func() ([]byte, error)                       // The configuration as JSON
+	Database func(ctx context.Context, path string) error // Writes a consistent copy of the database to path (nil = no database)
+}
+
+// Info describes a backup file.
+type Info struct {
+	Name      string    `json:"name"`
+	Created   time.Time `json:"created"`
+	Size      int64     `json:"size"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// Status is the state of the schedule.
+type Status struct {
+	Scheduled bool       `json:"scheduled"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+// Contents is a backup unpacked for a restore.
+type Contents struct {
+	Dir      string // Temporary directory of the unpacked files
+	Config   []byte // The configuration (nil = not in the backup)
+	Database string // The database copy (empty = not in the backup)
+}
+
+// Remove deletes the unpacked files.
+func (c *Contents) Remove() {
+	if c != nil && c.Dir != "" {
+		os.RemoveAll(c.Dir)
+	}
+}
+
+// Service makes the backups and keeps the backup directory tidy.
+type Service struct {
+	cfg Config
+	src Sources
+	log *logger.Logger
+
+	runMu sync.Mutex // One backup at a time
+
+	mu     sync.Mutex
+	status Status
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewService creates a service. Nothing happens until Start or Run.
+func NewService(cfg Config, src Sources, log *logger.Logger) *Service {
+	return &Service{cfg: cfg, src: src, log: log}
+}
+
+// Start runs backups on the schedule until Stop. The schedule continues
+// from the newest backup in the directory, so a restart does not put the
+// next one off.
+func (s *Service) Start() {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.status.Scheduled = true
+	s.mu.Unlock()
+	go s.schedule(ctx)
+}
+
+// Stop ends the schedule, waiting for a backup in progress.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.status.Scheduled = false
+	s.status.NextRun = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Status returns the state of the schedule.
+func (s *Service) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// schedule runs a backup whenever one is due.
+func (s *Service) schedule(ctx context.Context) {
+	defer close(s.done)
+
+	wait := firstRunDelay
+	if list, err := s.List(); err == nil && len(list) > 0 {
+		if due := time.Until(list[0].Created.Add(s.cfg.Interval)); due > wait {
+			wait = due
+		}
+	}
+	for {
+		next := time.Now().Add(wait)
+		s.mu.Lock()
+		s.status.NextRun = &next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		wait = s.cfg.Interval
+		if _, err := s.Run(); err != nil {
+			s.log.Error("BACKUP", fmt.Sprintf("Scheduled backup failed: %v", err))
+			wait = min(retryDelay, s.cfg.Interval)
+		}
+	}
+}
+
+// Run makes a backup now and removes the oldest ones beyond the retention.
+func (s *Service) Run() (Info, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	info, err := s.write()
+	now := time.Now()
+	s.mu.Lock()
+	s.status.LastRun = &now
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return Info{}, err
+	}
+
+	s.log.Info("BACKUP", fmt.Sprintf("Backup %s written (%d bytes)", info.Name, info.Size))
+	s.prune()
+	return info, nil
+}
+
+// write writes a backup under a temporary name and renames it once it is
+// complete, so the directory never lists a half-written backup.
+func (s *Service) write() (Info, error) {
+	if !s.cfg.Config && !(s.cfg.Database && s.src.Database != nil) {
+		return Info{}, errors.New("nothing to back up")
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return Info{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	created := time.Now().UTC().Truncate(time.Second)
+	name := "modbridge-backup-" + created.Format("20060102-150405") + ".tar.gz"
+	if s.cfg.Passphrase != "" {
+		name += ".enc"
+	}
+	path := filepath.Join(s.cfg.Dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return Info{}, fmt.Errorf("backup %s already exists", name)
+	}
+	part := path + ".part"
+	if err := s.writeArchive(part, created); err != nil {
+		os.Remove(part)
+		return Info{}, err
+	}
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return Info{}, fmt.Errorf("failed to store backup: %w", err)
+	}
+
+	info := Info{Name: name, Created: created, Encrypted: s.cfg.Passphrase != ""}
+	if fi, err := os.Stat(path); err == nil {
+		info.Size = fi.Size()
+	}
+	return info, nil
+}
+
+// writeArchive writes the archive of one backup to path.
+func (s *Service) writeArchive(path string, created time.Time) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) // #nosec G304 -- name built from the backup time
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer f.Close()
+
+	var out io.Writer = f
+	var enc *encryptWriter
+	if s.cfg.Passphrase != "" {
+		if enc, err = newEncryptWriter(f, s.cfg.Passphrase); err != nil {
+			return fmt.Errorf("failed to set up encryption: %w", err)
+		}
+		out = enc
+	}
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	if s.cfg.Config && s.src.Config != nil {
+		data, err := s.src.Config()
+		if err != nil {
+			return fmt.Errorf("failed to snapshot configuration: %w", err)
+		}
+		hdr := &tar.Header{Name: ConfigFile, Mode: 0o600, Size: int64(len(data)), ModTime: created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if s.cfg.Database && s.src.Database != nil {
+		if err := s.addDatabase(tw, path+".db", created); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addDatabase copies the database to a scratch file and adds that to the
+// archive.
+func (s *Service) addDatabase(tw *tar.Writer, scratch string, created time.Time) error {
+	defer func() {
+		for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+			os.Remove(scratch + suffix)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if err := s.src.Database(ctx, scratch); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	f, err := os.Open(scratch) // #nosec G304 -- our own scratch file
+	if err != nil {
+		return fmt.Errorf("failed to read database snapshot: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: DatabaseFile, Mode: 0o600, Size: fi.Size(), ModTime: created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// List returns the backups in the directory, newest first.
+func (s *Service) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Info{}, nil
+		}
+		return nil, err
+	}
+	list := make([]Info, 0, len(entries))
+	for _, e := range entries {
+		m := namePattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		created, err := time.Parse("20060102-150405", m[1])
+		if err != nil {
+			continue
+		}
+		info := Info{Name: e.Name(), Created: created, Encrypted: m[2] != ""}
+		if fi, err := e.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list, nil
+}
+
+// Path returns the file of a backup. Only names of backups in the directory
+// are accepted, so a name cannot point anywhere else.
+func (s *Service) Path(name string) (string, Info, error) {
+	if !namePattern.MatchString(name) {
+		return "", Info{}, ErrNotFound
+	}
+	list, err := s.List()
+	if err != nil {
+		return "", Info{}, err
+	}
+	for _, info := range list {
+		if info.Name == name {
+			return filepath.Join(s.cfg.Dir, name), info, nil
+		}
+	}
+	return "", Info{}, ErrNotFound
+}
+
+// Extract unpacks a backup into a temporary directory. An encrypted backup
+// opens with passphrase, or with the configured one if that is empty. The
+// caller removes the contents when done.
+func (s *Service) Extract(name, passphrase string) (*Contents, error) {
+	path, info, err := s.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path) // #nosec G304 -- path comes from the backup list, not the request
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var in io.Reader = f
+	if info.Encrypted {
+		if passphrase == "" {
+			passphrase = s.cfg.Passphrase
+		}
+		if passphrase == "" {
+			return nil, fmt.Errorf("%w: the backup is encrypted", ErrPassphrase)
+		}
+		if in, err = newDecryptReader(f, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		if errors.Is(err, ErrPassphrase) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("damaged backup: %w", err)
+	}
+	defer gz.Close()
+
+	dir, err := os.MkdirTemp(s.cfg.Dir, ".restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack backup: %w", err)
+	}
+	contents := &Contents{Dir: dir}
+	if err := contents.unpack(tar.NewReader(gz)); err != nil {
+		contents.Remove()
+		return nil, err
+	}
+	return contents, nil
+}
+
+// unpack reads the files of a backup. Anything else in the archive is
+// ignored.
+func (c *Contents) unpack(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, ErrPassphrase) {
+				return err
+			}
+			return fmt.Errorf("damaged backup: %w", err)
+		}
+		switch hdr.Name {
+		case ConfigFile:
+			data, err := io.ReadAll(io.LimitReader(tr, maxConfigSize))
+			if err != nil {
+				return fmt.Errorf("damaged backup: %w", err)
+			}
+			c.Config = data
+		case DatabaseFile:
+			path := filepath.Join(c.Dir, DatabaseFile)
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) // #nosec G304 -- fixed name in our temporary directory
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("damaged backup: %w", err)
+			}
+			c.Database = path
+		}
+	}
+}
+
+// prune removes the oldest backups beyond the retention, and what a crashed
+// backup or restore has left behind.
+func (s *Service) prune() {
+	if entries, err := os.ReadDir(s.cfg.Dir); err == nil {
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".part") || strings.HasPrefix(e.Name(), ".restore-") {
+				if fi, err := e.Info(); err == nil && time.Since(fi.ModTime()) > time.Hour {
+					os.RemoveAll(filepath.Join(s.cfg.Dir, e.Name()))
+				}
+			}
+		}
+	}
+	if s.cfg.Retention <= 0 {
+		return
+	}
+	list, err := s.List()
+	if err != nil {
+		return
+	}
+	for _, info := range list[min(len(list), s.cfg.Retention):] {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, info.Name)); err != nil {
+			s.log.Warn("BACKUP", fmt.Sprintf("Failed to remove old backup %s: %v", info.Name, err))
+		} else {
+			s.log.Info("BACKUP", fmt.Sprintf("Removed old backup %s", info.Name))
+		}
+	}
+}
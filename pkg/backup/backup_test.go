@@ -0,0 +1,182 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"modbridge/pkg/logger"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testService returns a service that backs up a fixed configuration and a
+// fake database.
+func testService(t *testing.T, cfg Config) *Service {
+	t.Helper()
+	l, err := logger.NewLogger(filepath.Join(t.TempDir(), "test.log"), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	return NewService(cfg, Sources{
+		Config: func() ([]byte, error) { return []byte(`{"web_port":":8080"}`), nil },
+		Database: func(ctx context.Context, path string) error {
+			return os.WriteFile(path, []byte("SQLite format 3"), 0o600)
+		},
+	}, l)
+}
+
+func TestRunAndExtract(t *testing.T) {
+	for _, passphrase := range []string{"", "correct horse battery"} {
+		s := testService(t, Config{Config: true, Database: true, Passphrase: passphrase})
+
+		info, err := s.Run()
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if info.Encrypted != (passphrase != "") || info.Size == 0 {
+			t.Errorf("Run() = %+v", info)
+		}
+
+		contents, err := s.Extract(info.Name, "")
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if string(contents.Config) != `{"web_port":":8080"}` {
+			t.Errorf("Config = %q", contents.Config)
+		}
+		if data, _ := os.ReadFile(contents.Database); string(data) != "SQLite format 3" {
+			t.Errorf("Database = %q", data)
+		}
+		contents.Remove()
+		if _, err := os.Stat(contents.Dir); !os.IsNotExist(err) {
+			t.Errorf("Remove() left %s behind", contents.Dir)
+		}
+
+		if passphrase != "" {
+			if _, err := s.Extract(info.Name, "wrong"); !errors.Is(err, ErrPassphrase) {
+				t.Errorf("Extract() with a wrong passphrase error = %v, want ErrPassphrase", err)
+			}
+		}
+	}
+}
+
+func TestPathRejectsForeignNames(t *testing.T) {
+	s := testService(t, Config{Config: true})
+	for _, name := range []string{"../config.json", "modbridge-backup-20260101-000000.tar.gz"} {
+		if _, _, err := s.Path(name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Path(%q) error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"modbridge-backup-20250101-000000.tar.gz",
+		"modbridge-backup-20250102-000000.tar.gz.enc",
+		"modbridge-backup-20250103-000000.tar.gz",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := testService(t, Config{Dir: dir, Config: true, Retention: 2})
+	info, err := s.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 2 || list[0].Name != info.Name || list[1].Name != "modbridge-backup-20250103-000000.tar.gz" {
+		t.Errorf("List() = %+v, want the new backup and the newest old one", list)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("retention removed a file that is not a backup: %v", err)
+	}
+}
+
+func TestScheduleRunsFirstBackup(t *testing.T) {
+	defer func(d time.Duration) { firstRunDelay = d }(firstRunDelay)
+	firstRunDelay = 10 * time.Millisecond
+
+	s := testService(t, Config{Config: true, Interval: time.Hour})
+	s.Start()
+	defer s.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if list, _ := s.List(); len(list) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no backup after the first delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for time.Now().Before(deadline) {
+		if st := s.Status(); st.LastRun != nil && st.NextRun != nil {
+			if until := time.Until(*st.NextRun); until < 59*time.Minute {
+				t.Errorf("next backup in %v, want about an hour", until)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("status never showed the run")
+}
+
+func TestEncryptionDetectsTruncation(t *testing.T) {
+	plain := make([]byte, 3*chunkSize+100)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := newEncryptWriter(&buf, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newDecryptReader(bytes.NewReader(buf.Bytes()), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	// Cut off after the first full chunk: every byte read is genuine, but
+	// the reader must not pass the shortened stream off as complete.
+	cut := len(encryptedMagic) + saltSize + noncePrefixSize + 4 + chunkSize + 16
+	r, err = newDecryptReader(bytes.NewReader(buf.Bytes()[:cut]), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrPassphrase) {
+		t.Errorf("truncated stream error = %v, want ErrPassphrase", err)
+	}
+}
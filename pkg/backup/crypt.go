@@ -0,0 +1,199 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted backup is the archive in AES-256-GCM, cut into chunks so that
+// a large database never has to fit in memory. The key comes from the
+// passphrase with scrypt. Each chunk's nonce holds its number and whether it
+// is the last one, so chunks cannot be reordered, and a file cut short is
+// noticed rather than restored in part.
+//
+//	magic (8) | salt (16) | nonce prefix (7) | chunks: length (4) | sealed chunk
+
+var encryptedMagic = []byte("MBBKENC1")
+
+const (
+	saltSize        = 16
+	noncePrefixSize = 7
+	chunkSize       = 64 << 10
+)
+
+// ErrPassphrase is returned when an encrypted backup does not open with the
+// passphrase given.
+var ErrPassphrase = errors.New("wrong passphrase or damaged backup")
+
+// deriveKey turns a passphrase into an AES-256 key.
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce of one chunk.
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], n)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter encrypts what is written to it. Close seals the last chunk.
+type encryptWriter struct {
+	out    io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+}
+
+// newEncryptWriter writes the header of an encrypted backup to out.
+func newEncryptWriter(out io.Writer, passphrase string) (*encryptWriter, error) {
+	header := make([]byte, len(encryptedMagic)+saltSize+noncePrefixSize)
+	copy(header, encryptedMagic)
+	if _, err := rand.Read(header[len(encryptedMagic):]); err != nil {
+		return nil, err
+	}
+	salt := header[len(encryptedMagic) : len(encryptedMagic)+saltSize]
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		out:    out,
+		aead:   aead,
+		prefix: header[len(encryptedMagic)+saltSize:],
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk, which may be empty.
+func (w *encryptWriter) Close() error {
+	return w.seal(true)
+}
+
+// seal writes the buffered chunk.
+func (w *encryptWriter) seal(last bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.prefix, w.n, last), w.buf, nil)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := w.out.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := w.out.Write(sealed); err != nil {
+		return err
+	}
+	w.n++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// decryptReader reads an encrypted backup.
+type decryptReader struct {
+	in     *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	plain  []byte
+	done   bool
+}
+
+// newDecryptReader reads the header of an encrypted backup from in.
+func newDecryptReader(in io.Reader, passphrase string) (*decryptReader, error) {
+	header := make([]byte, len(encryptedMagic)+saltSize+noncePrefixSize)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, fmt.Errorf("not an encrypted backup: %w", err)
+	}
+	if string(header[:len(encryptedMagic)]) != string(encryptedMagic) {
+		return nil, errors.New("not an encrypted backup")
+	}
+	aead, err := deriveKey(passphrase, header[len(encryptedMagic):len(encryptedMagic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{in: bufio.NewReader(in), aead: aead, prefix: header[len(encryptedMagic)+saltSize:]}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// open reads and opens the next chunk. Only the last chunk opens with the
+// last-chunk nonce, so that is how the end is recognised.
+func (r *decryptReader) open() error {
+	var length [4]byte
+	if _, err := io.ReadFull(r.in, length[:]); err != nil {
+		return ErrPassphrase
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > chunkSize+uint32(r.aead.Overhead()) {
+		return ErrPassphrase
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.in, sealed); err != nil {
+		return ErrPassphrase
+	}
+	plain, err := r.aead.Open(nil, chunkNonce(r.prefix, r.n, false), sealed, nil)
+	if err != nil {
+		if plain, err = r.aead.Open(nil, chunkNonce(r.prefix, r.n, true), sealed, nil); err != nil {
+			return ErrPassphrase
+		}
+		r.done = true
+	}
+	r.n++
+	r.plain = plain
+	return nil
+}
@@ -0,0 +1,448 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package capture records the Modbus frames a proxy exchanges with its
+// clients and its target, on demand and bounded in size and time. A capture
+// is written as pcapng, which Wireshark dissects as Modbus/TCP, or as JSON
+// Lines for scripts. Captures can be replayed against a device or served by
+// a mock that answers like the recorded device did.
+package capture
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Capture formats.
+const (
+	FormatPCAPNG = "pcapng"
+	FormatJSONL  = "jsonl"
+)
+
+// Limits of a capture.
+const (
+	DefaultMaxBytes    = 10 << 20
+	MaxBytesLimit      = 512 << 20
+	DefaultMaxDuration = 10 * time.Minute
+	MaxDurationLimit   = 24 * time.Hour
+)
+
+// ErrActive is returned when a proxy is already being captured.
+var ErrActive = errors.New("a capture of this proxy is already running")
+
+// ErrNotFound is returned for a capture that does not exist.
+var ErrNotFound = errors.New("capture not found")
+
+// Side says which connection of the proxy a frame was seen on.
+type Side string
+
+const (
+	SideClient Side = "client" // Between a client and the proxy
+	SideTarget Side = "target" // Between the proxy and its target
+)
+
+// Frame is one Modbus frame as it went over the wire.
+type Frame struct {
+	Time     time.Time
+	Side     Side
+	Response bool
+	RTU      bool   // Modbus RTU (rtu-tcp targets) rather than Modbus TCP
+	Src      string // host:port of the sender
+	Dst      string // host:port of the receiver
+	Data     []byte
+	Latency  time.Duration // Responses: time since the request was sent
+	Error    string        // Why no response came, or why this one was discarded
+}
+
+// Options describe a capture.
+type Options struct {
+	Format      string        // FormatPCAPNG (default) or FormatJSONL
+	MaxBytes    int64         // Stop once the file is this large (0 = DefaultMaxBytes)
+	MaxDuration time.Duration // Stop after this long (0 = DefaultMaxDuration)
+	Comment     string        // Describes the capture in the file, where the format has room
+}
+
+// Validate checks the options and fills in the defaults.
+func (o *Options) Validate() error {
+	switch o.Format {
+	case "":
+		o.Format = FormatPCAPNG
+	case FormatPCAPNG, FormatJSONL:
+	default:
+		return fmt.Errorf("unknown format %q, use pcapng or jsonl", o.Format)
+	}
+	if o.MaxBytes == 0 {
+		o.MaxBytes = DefaultMaxBytes
+	}
+	if o.MaxBytes < 4096 || o.MaxBytes > MaxBytesLimit {
+		return fmt.Errorf("max_bytes must be between 4096 and %d", int64(MaxBytesLimit))
+	}
+	if o.MaxDuration == 0 {
+		o.MaxDuration = DefaultMaxDuration
+	}
+	if o.MaxDuration < time.Second || o.MaxDuration > MaxDurationLimit {
+		return fmt.Errorf("max_duration must be between 1s and %v", MaxDurationLimit)
+	}
+	return nil
+}
+
+// Info describes a capture.
+type Info struct {
+	ID         string     `json:"id"`
+	ProxyID    string     `json:"proxy_id"`
+	Format     string     `json:"format"`
+	Started    time.Time  `json:"started"`
+	Ended      *time.Time `json:"ended,omitempty"`
+	Active     bool       `json:"active"`
+	Frames     int64      `json:"frames"`
+	Bytes      int64      `json:"bytes"`
+	MaxBytes   int64      `json:"max_bytes,omitempty"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+	StopReason string     `json:"stop_reason,omitempty"` // stopped, max_bytes, max_duration or an error
+}
+
+// encoder writes frames in one format.
+type encoder interface {
+	header(w *countingWriter, opts Options) error
+	frame(w *countingWriter, f Frame) error
+}
+
+// Session is a running capture. Record is safe for concurrent use and does
+// nothing once the capture has ended, so a proxy can hold on to a session a
+// little longer than it lasts.
+type Session struct {
+	info  Info
+	path  string
+	onEnd func(*Session)
+
+	mu     sync.Mutex
+	file   *os.File
+	out    *countingWriter
+	enc    encoder
+	timer  *time.Timer
+	frames atomic.Int64
+	ended  atomic.Bool
+}
+
+// Record writes a frame. When the frame reaches the size limit the capture
+// ends.
+func (s *Session) Record(f Frame) {
+	if s.ended.Load() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended.Load() {
+		s.mu.Unlock()
+		return
+	}
+	err := s.enc.frame(s.out, f)
+	if err == nil {
+		s.frames.Add(1)
+	}
+	full := s.out.n >= s.info.MaxBytes
+	s.mu.Unlock()
+
+	switch {
+	case err != nil:
+		s.end("write failed: " + err.Error())
+	case full:
+		s.end("max_bytes")
+	}
+}
+
+// ID returns the capture's ID.
+func (s *Session) ID() string {
+	return s.info.ID
+}
+
+// Info describes the capture as it is now.
+func (s *Session) Info() Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := s.info
+	info.Active = !s.ended.Load()
+	info.Frames = s.frames.Load()
+	if s.out != nil {
+		info.Bytes = s.out.n
+	}
+	return info
+}
+
+// end closes the capture once, with the reason it ended.
+func (s *Session) end(reason string) {
+	s.mu.Lock()
+	if s.ended.Swap(true) {
+		s.mu.Unlock()
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	err := s.out.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	now := time.Now()
+	s.info.Ended = &now
+	s.info.Deadline = nil
+	s.info.StopReason = reason
+	if err != nil {
+		s.info.StopReason = reason + ", write failed: " + err.Error()
+	}
+	s.mu.Unlock()
+
+	if s.onEnd != nil {
+		s.onEnd(s)
+	}
+}
+
+// countingWriter buffers a file and counts what was written to it.
+type countingWriter struct {
+	*bufio.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// idPattern matches capture IDs: the proxy ID and the start time.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+_\d{8}T\d{6}\.\d{3}Z$`)
+
+// Manager keeps the captures of all proxies in one directory: one running
+// per proxy at most, and the last few finished ones for download.
+type Manager struct {
+	dir  string
+	keep int
+
+	mu       sync.Mutex
+	active   map[string]*Session // By proxy ID
+	finished map[string]Info     // Ended in this run, by capture ID
+}
+
+// NewManager creates a manager that writes to dir and keeps the keep most
+// recent finished captures there.
+func NewManager(dir string, keep int) *Manager {
+	if keep <= 0 {
+		keep = 20
+	}
+	return &Manager{dir: dir, keep: keep, active: make(map[string]*Session), finished: make(map[string]Info)}
+}
+
+// Start starts capturing a proxy. onEnd is called once the capture ends, by
+// Stop or by one of its limits.
+func (m *Manager) Start(proxyID string, opts Options, onEnd func(*Session)) (*Session, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.active[proxyID]; ok {
+		return nil, ErrActive
+	}
+	if err := os.MkdirAll(m.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	started := time.Now()
+	id := proxyID + "_" + started.UTC().Format("20060102T150405.000Z")
+	path := filepath.Join(m.dir, id+"."+opts.Format)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640) // #nosec G304 -- name built from a validated proxy ID
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	deadline := started.Add(opts.MaxDuration)
+	s := &Session{
+		info: Info{ID: id, ProxyID: proxyID, Format: opts.Format, Started: started, MaxBytes: opts.MaxBytes, Deadline: &deadline},
+		path: path,
+		file: file,
+		out:  &countingWriter{Writer: bufio.NewWriterSize(file, 64<<10)},
+		enc:  newEncoder(opts.Format),
+	}
+	if err := s.enc.header(s.out, opts); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	s.onEnd = func(s *Session) {
+		m.mu.Lock()
+		if m.active[proxyID] == s {
+			delete(m.active, proxyID)
+		}
+		m.finished[s.info.ID] = s.Info()
+		m.mu.Unlock()
+		m.prune()
+		if onEnd != nil {
+			onEnd(s)
+		}
+	}
+	s.mu.Lock()
+	s.timer = time.AfterFunc(opts.MaxDuration, func() { s.end("max_duration") })
+	s.mu.Unlock()
+	m.active[proxyID] = s
+	return s, nil
+}
+
+// Active returns the running capture of a proxy, or nil.
+func (m *Manager) Active(proxyID string) *Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active[proxyID]
+}
+
+// Stop ends the running capture of a proxy and returns it, or nil if there
+// was none.
+func (m *Manager) Stop(proxyID string) *Session {
+	s := m.Active(proxyID)
+	if s != nil {
+		s.end("stopped")
+	}
+	return s
+}
+
+// StopAll ends every running capture.
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	sessions := make([]*Session, 0, len(m.active))
+	for _, s := range m.active {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+	for _, s := range sessions {
+		s.end("stopped")
+	}
+}
+
+// List returns every capture, running ones and those on disk, newest first.
+// Captures left from an earlier run are described by their file alone.
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	byID := make(map[string]Info)
+	for _, e := range entries {
+		if info, ok := m.fileInfo(e); ok {
+			byID[info.ID] = info
+		}
+	}
+	for id, info := range m.finished {
+		if _, ok := byID[id]; ok {
+			info.Bytes = byID[id].Bytes
+			byID[id] = info
+		}
+	}
+	active := make([]*Session, 0, len(m.active))
+	for _, s := range m.active {
+		active = append(active, s)
+	}
+	m.mu.Unlock()
+	for _, s := range active {
+		byID[s.ID()] = s.Info()
+	}
+
+	list := make([]Info, 0, len(byID))
+	for _, info := range byID {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.After(list[j].Started) })
+	return list, nil
+}
+
+// fileInfo describes a capture file, if the entry is one.
+func (m *Manager) fileInfo(e os.DirEntry) (Info, bool) {
+	name := e.Name()
+	format := strings.TrimPrefix(filepath.Ext(name), ".")
+	if e.IsDir() || (format != FormatPCAPNG && format != FormatJSONL) {
+		return Info{}, false
+	}
+	id := strings.TrimSuffix(name, "."+format)
+	if !idPattern.MatchString(id) {
+		return Info{}, false
+	}
+	sep := strings.LastIndex(id, "_")
+	started, err := time.Parse("20060102T150405.000Z", id[sep+1:])
+	if err != nil {
+		return Info{}, false
+	}
+	info := Info{ID: id, ProxyID: id[:sep], Format: format, Started: started}
+	if fi, err := e.Info(); err == nil {
+		info.Bytes = fi.Size()
+		ended := fi.ModTime()
+		info.Ended = &ended
+	}
+	return info, true
+}
+
+// Path returns the file of a finished capture. A running capture cannot be
+// downloaded: its file is incomplete until it ends.
+func (m *Manager) Path(id string) (string, Info, error) {
+	list, err := m.List()
+	if err != nil {
+		return "", Info{}, err
+	}
+	for _, info := range list {
+		if info.ID != id {
+			continue
+		}
+		if info.Active {
+			return "", info, ErrActive
+		}
+		return filepath.Join(m.dir, id+"."+info.Format), info, nil
+	}
+	return "", Info{}, ErrNotFound
+}
+
+// Delete removes a finished capture.
+func (m *Manager) Delete(id string) error {
+	path, _, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.finished, id)
+	m.mu.Unlock()
+	return os.Remove(path)
+}
+
+// prune removes the oldest finished captures beyond the number to keep.
+func (m *Manager) prune() {
+	list, err := m.List()
+	if err != nil {
+		return
+	}
+	kept := 0
+	for _, info := range list {
+		if info.Active {
+			continue
+		}
+		if kept++; kept > m.keep {
+			m.Delete(info.ID)
+		}
+	}
+}
+
+// newEncoder returns the encoder of a format.
+func newEncoder(format string) encoder {
+	if format == FormatJSONL {
+		return jsonlEncoder{}
+	}
+	return &pcapngEncoder{flows: make(map[flowKey]uint32)}
+}
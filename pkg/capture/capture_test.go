@@ -0,0 +1,177 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package capture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"modbridge/pkg/modbus"
+	"testing"
+	"time"
+)
+
+// testExchange returns the four frames of one read through the proxy: the
+// client's request and response, and the proxy's own to the target.
+func testExchange(at time.Time, txID uint16, value byte) []Frame {
+	req := modbus.CreateReadRequest(txID, 1, 3, 100, 1)
+	resp, _ := modbus.CreateReadResponse(txID, 1, 3, []byte{0, value})
+	targetReq := modbus.CreateReadRequest(0x8000+txID, 1, 3, 100, 1)
+	targetResp, _ := modbus.CreateReadResponse(0x8000+txID, 1, 3, []byte{0, value})
+	return []Frame{
+		{Time: at, Side: SideClient, Src: "192.168.1.20:50000", Dst: "192.168.1.2:5020", Data: req},
+		{Time: at.Add(time.Millisecond), Side: SideTarget, Src: "192.168.1.2:41000", Dst: "192.168.1.50:502", Data: targetReq},
+		{Time: at.Add(9 * time.Millisecond), Side: SideTarget, Response: true, Src: "192.168.1.50:502", Dst: "192.168.1.2:41000", Data: targetResp, Latency: 8 * time.Millisecond},
+		{Time: at.Add(10 * time.Millisecond), Side: SideClient, Response: true, Src: "192.168.1.2:5020", Dst: "192.168.1.20:50000", Data: resp, Latency: 10 * time.Millisecond},
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	frames := append(testExchange(at, 1, 7), testExchange(at.Add(time.Second), 2, 8)...)
+
+	for _, format := range []string{FormatPCAPNG, FormatJSONL} {
+		m := NewManager(t.TempDir(), 0)
+		s, err := m.Start("p1", Options{Format: format, Comment: "test"}, nil)
+		if err != nil {
+			t.Fatalf("%s: Start() error = %v", format, err)
+		}
+		for _, f := range frames {
+			s.Record(f)
+		}
+		if got := m.Stop("p1"); got != s {
+			t.Fatalf("%s: Stop() = %v, want the session", format, got)
+		}
+
+		path, info, err := m.Path(s.ID())
+		if err != nil || info.Frames != 8 || info.StopReason != "stopped" || info.Active {
+			t.Fatalf("%s: Path() = %+v, %v", format, info, err)
+		}
+		read, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: ReadFile() error = %v", format, err)
+		}
+		if len(read) != len(frames) {
+			t.Fatalf("%s: read %d frames, want %d", format, len(read), len(frames))
+		}
+		for i, f := range read {
+			want := frames[i]
+			if f.Side != want.Side || f.Response != want.Response || !bytes.Equal(f.Data, want.Data) || !f.Time.Equal(want.Time) {
+				t.Errorf("%s: frame %d = %+v, want %+v", format, i, f, want)
+			}
+		}
+		// pcapng shows the server side on port 502 so that Wireshark
+		// dissects it; JSON Lines keeps the real addresses.
+		wantDst := map[string]string{FormatPCAPNG: "192.168.1.2:502", FormatJSONL: "192.168.1.2:5020"}[format]
+		if read[0].Dst != wantDst || read[0].Src != "192.168.1.20:50000" {
+			t.Errorf("%s: first frame %s -> %s, want 192.168.1.20:50000 -> %s", format, read[0].Src, read[0].Dst, wantDst)
+		}
+	}
+}
+
+func TestCaptureLimits(t *testing.T) {
+	m := NewManager(t.TempDir(), 0)
+	ended := make(chan string, 2)
+	onEnd := func(s *Session) { ended <- s.Info().StopReason }
+
+	s, err := m.Start("p1", Options{Format: FormatJSONL, MaxBytes: 4096}, onEnd)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := m.Start("p1", Options{}, nil); !errors.Is(err, ErrActive) {
+		t.Errorf("second Start() error = %v, want ErrActive", err)
+	}
+	if _, _, err := m.Path(s.ID()); !errors.Is(err, ErrActive) {
+		t.Errorf("Path() of a running capture error = %v, want ErrActive", err)
+	}
+	for i := 0; i < 100; i++ {
+		for _, f := range testExchange(time.Now(), uint16(i), 1) {
+			s.Record(f)
+		}
+	}
+	if reason := <-ended; reason != "max_bytes" {
+		t.Errorf("capture ended with %q, want max_bytes", reason)
+	}
+	if info := s.Info(); info.Bytes < 4096 || info.Bytes > 8192 {
+		t.Errorf("capture has %d bytes, want just over 4096", info.Bytes)
+	}
+
+	if _, err := m.Start("p2", Options{MaxDuration: time.Second}, onEnd); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	select {
+	case reason := <-ended:
+		if reason != "max_duration" {
+			t.Errorf("capture ended with %q, want max_duration", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("capture did not end after its duration")
+	}
+
+	list, err := m.List()
+	if err != nil || len(list) != 2 || list[0].ProxyID != "p2" {
+		t.Fatalf("List() = %+v, %v; want both captures, newest first", list, err)
+	}
+	if err := m.Delete(list[1].ID); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, _, err := m.Path(list[1].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Path() of a deleted capture error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := m.Path("../config"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Path(../config) error = %v, want ErrNotFound", err)
+	}
+
+	for _, opts := range []Options{{Format: "pcap"}, {MaxBytes: 100}, {MaxDuration: 48 * time.Hour}} {
+		if _, err := m.Start("p3", opts, nil); err == nil {
+			t.Errorf("Start(%+v) accepted invalid options", opts)
+		}
+	}
+}
+
+func TestCaptureKeepsRecentFiles(t *testing.T) {
+	m := NewManager(t.TempDir(), 2)
+	for i := 0; i < 3; i++ {
+		if _, err := m.Start("p1", Options{}, nil); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		m.Stop("p1")
+		time.Sleep(2 * time.Millisecond) // A new ID each time
+	}
+	if list, _ := m.List(); len(list) != 2 {
+		t.Errorf("kept %d captures, want 2", len(list))
+	}
+}
+
+func TestReplayAgainstMock(t *testing.T) {
+	at := time.Now()
+	frames := append(testExchange(at, 1, 7), testExchange(at.Add(10*time.Millisecond), 2, 8)...)
+
+	mock := NewMock(frames)
+	if err := mock.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer mock.Close()
+
+	result, err := Replay(context.Background(), frames, mock.Addr(), ReplayOptions{Speed: 1})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	// The same request was answered 7, then 8: the mock answers in turn.
+	if result.Sent != 2 || result.Matched != 2 || len(result.Mismatches) != 0 {
+		t.Errorf("Replay() = %+v, want both requests answered as captured", result)
+	}
+
+	// Against a device that now answers differently, the difference shows.
+	changed := append(testExchange(at, 1, 7), testExchange(at.Add(10*time.Millisecond), 2, 9)...)
+	result, err = Replay(context.Background(), changed, mock.Addr(), ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if result.Matched != 1 || result.Differed != 1 || len(result.Mismatches) != 1 || result.Mismatches[0].Index != 1 {
+		t.Errorf("Replay() = %+v, want the second answer to differ", result)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonlRecord is one line of a JSON Lines capture. The fields after frame
+// are decoded from it for readers that do not want to parse Modbus.
+type jsonlRecord struct {
+	Time      time.Time `json:"time"`
+	Side      Side      `json:"side"`
+	Direction string    `json:"direction"` // request or response
+	Protocol  string    `json:"protocol"`  // tcp or rtu
+	Src       string    `json:"src,omitempty"`
+	Dst       string    `json:"dst,omitempty"`
+	Frame     string    `json:"frame,omitempty"` // Hex
+	TxID      *uint16   `json:"tx_id,omitempty"`
+	UnitID    *uint8    `json:"unit_id,omitempty"`
+	Function  *uint8    `json:"function,omitempty"`
+	LatencyMs *float64  `json:"latency_ms,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// jsonlEncoder writes one JSON object per frame.
+type jsonlEncoder struct{}
+
+func (jsonlEncoder) header(w *countingWriter, opts Options) error {
+	return nil
+}
+
+func (jsonlEncoder) frame(w *countingWriter, f Frame) error {
+	r := jsonlRecord{
+		Time:      f.Time,
+		Side:      f.Side,
+		Direction: "request",
+		Protocol:  "tcp",
+		Src:       f.Src,
+		Dst:       f.Dst,
+		Frame:     hex.EncodeToString(f.Data),
+		Error:     f.Error,
+	}
+	if f.Response {
+		r.Direction = "response"
+		ms := float64(f.Latency.Microseconds()) / 1000
+		r.LatencyMs = &ms
+	}
+	if f.RTU {
+		r.Protocol = "rtu"
+	}
+	if txID, unitID, fc, ok := decodeHeader(f); ok {
+		if !f.RTU {
+			r.TxID = &txID
+		}
+		r.UnitID, r.Function = &unitID, &fc
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// decodeHeader returns the transaction ID, unit ID and function code of a
+// frame.
+func decodeHeader(f Frame) (txID uint16, unitID, fc uint8, ok bool) {
+	if f.RTU {
+		if len(f.Data) < 2 {
+			return 0, 0, 0, false
+		}
+		return 0, f.Data[0], f.Data[1], true
+	}
+	if len(f.Data) < 8 {
+		return 0, 0, 0, false
+	}
+	return uint16(f.Data[0])<<8 | uint16(f.Data[1]), f.Data[6], f.Data[7], true
+}
+
+// readJSONL reads the frames of a JSON Lines capture.
+func readJSONL(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		data, err := hex.DecodeString(rec.Frame)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: frame: %w", line, err)
+		}
+		f := Frame{
+			Time:     rec.Time,
+			Side:     rec.Side,
+			Response: rec.Direction == "response",
+			RTU:      rec.Protocol == "rtu",
+			Src:      rec.Src,
+			Dst:      rec.Dst,
+			Data:     data,
+			Error:    rec.Error,
+		}
+		if rec.LatencyMs != nil {
+			f.Latency = time.Duration(*rec.LatencyMs * float64(time.Millisecond))
+		}
+		frames = append(frames, f)
+	}
+	return frames, scanner.Err()
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// pcapng block types and options, see draft-ietf-opsawg-pcapng.
+const (
+	blockSection        = 0x0A0D0D0A
+	blockInterface      = 0x00000001
+	blockEnhancedPacket = 0x00000006
+	byteOrderMagic      = 0x1A2B3C4D
+
+	optEnd     = 0
+	optComment = 1
+	optName    = 2 // if_name
+	optFlags   = 2 // epb_flags
+	optTSResol = 9 // if_tsresol
+
+	linkTypeRaw = 101 // Raw IPv4 or IPv6, no link-layer header
+
+	flagInbound  = 1
+	flagOutbound = 2
+)
+
+// modbusPort is the server port written for Modbus TCP, whatever the real one
+// was: Wireshark dissects Modbus/TCP on port 502 without further setup.
+const modbusPort = 502
+
+// Interface IDs: client-side frames on the first interface, target-side ones
+// on the second.
+var interfaceOf = map[Side]uint32{SideClient: 0, SideTarget: 1}
+
+// flowKey names one direction of a TCP connection.
+type flowKey struct {
+	src, dst string
+}
+
+// pcapngEncoder writes every frame as a TCP segment in an IP packet. The
+// connection handshakes were never seen, so sequence numbers start at 1 per
+// direction; Wireshark follows such streams fine.
+type pcapngEncoder struct {
+	flows map[flowKey]uint32 // Next sequence number of each direction
+}
+
+func (e *pcapngEncoder) header(w *countingWriter, opts Options) error {
+	var shb []byte
+	shb = binary.LittleEndian.AppendUint32(shb, byteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) // Major version
+	shb = binary.LittleEndian.AppendUint16(shb, 0) // Minor version
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	if opts.Comment != "" {
+		shb = appendOption(shb, optComment, []byte(opts.Comment))
+	}
+	shb = appendOption(shb, optEnd, nil)
+	if err := writeBlock(w, blockSection, shb); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"client side (server port written as 502)", "target side (server port written as 502 for Modbus TCP)"} {
+		var idb []byte
+		idb = binary.LittleEndian.AppendUint16(idb, linkTypeRaw)
+		idb = binary.LittleEndian.AppendUint16(idb, 0)
+		idb = binary.LittleEndian.AppendUint32(idb, 0) // No snap length
+		idb = appendOption(idb, optName, []byte(name))
+		idb = appendOption(idb, optTSResol, []byte{6}) // Microseconds
+		idb = appendOption(idb, optEnd, nil)
+		if err := writeBlock(w, blockInterface, idb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frame writes a frame as an Enhanced Packet Block. A frame without data or
+// without addresses (a failed exchange, a serial line) has no packet to show
+// and is left out.
+func (e *pcapngEncoder) frame(w *countingWriter, f Frame) error {
+	if len(f.Data) == 0 {
+		return nil
+	}
+	src, dst, err := e.endpoints(f)
+	if err != nil {
+		return nil
+	}
+	key := flowKey{src.String(), dst.String()}
+	seq := e.flows[key]
+	if seq == 0 {
+		seq = 1
+	}
+	e.flows[key] = seq + uint32(len(f.Data))
+	ack := e.flows[flowKey{key.dst, key.src}]
+	if ack == 0 {
+		ack = 1
+	}
+	packet := ipPacket(src, dst, seq, ack, f.Data)
+
+	// Requests flow into the proxy on the client side and out of it on the
+	// target side.
+	flags := uint32(flagInbound)
+	if f.Response == (f.Side == SideClient) {
+		flags = flagOutbound
+	}
+	us := uint64(f.Time.UnixMicro())
+
+	var epb []byte
+	epb = binary.LittleEndian.AppendUint32(epb, interfaceOf[f.Side])
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(packet)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(packet)))
+	epb = append(epb, packet...)
+	epb = append(epb, make([]byte, pad4(len(packet)))...)
+	epb = appendOption(epb, optFlags, binary.LittleEndian.AppendUint32(nil, flags))
+	if f.Error != "" {
+		epb = appendOption(epb, optComment, []byte(f.Error))
+	} else if f.Response {
+		epb = appendOption(epb, optComment, []byte("latency "+f.Latency.String()))
+	}
+	epb = appendOption(epb, optEnd, nil)
+	return writeBlock(w, blockEnhancedPacket, epb)
+}
+
+// endpoints returns the sender and receiver of a frame, with the Modbus
+// server's port replaced by 502.
+func (e *pcapngEncoder) endpoints(f Frame) (*net.TCPAddr, *net.TCPAddr, error) {
+	src, err := parseAddr(f.Src)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseAddr(f.Dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !f.RTU {
+		if f.Response {
+			src.Port = modbusPort
+		} else {
+			dst.Port = modbusPort
+		}
+	}
+	// Both ends in the same family, or the packet cannot be built.
+	if (src.IP.To4() == nil) != (dst.IP.To4() == nil) {
+		src.IP, dst.IP = src.IP.To16(), dst.IP.To16()
+	}
+	return src, dst, nil
+}
+
+func parseAddr(addr string) (*net.TCPAddr, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP address: %s", host)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return &net.TCPAddr{IP: ip, Port: n}, nil
+}
+
+// ipPacket builds an IPv4 or IPv6 packet holding one TCP segment with PSH
+// and ACK set.
+func ipPacket(src, dst *net.TCPAddr, seq, ack uint32, payload []byte) []byte {
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = 5 << 4 // Header length in 32-bit words
+	tcp[13] = 0x18   // PSH, ACK
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	tcp = append(tcp, payload...)
+
+	var pseudo []byte
+	var ip []byte
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // Don't fragment
+		ip[8] = 64
+		ip[9] = 6 // TCP
+		copy(ip[12:], src4)
+		copy(ip[16:], dst4)
+		binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+		pseudo = append(append(append(pseudo, src4...), dst4...), 0, 6, byte(len(tcp)>>8), byte(len(tcp)))
+	} else {
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(tcp)))
+		ip[6] = 6 // TCP
+		ip[7] = 64
+		copy(ip[8:], src.IP.To16())
+		copy(ip[24:], dst.IP.To16())
+		pseudo = append(append(pseudo, src.IP.To16()...), dst.IP.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(tcp)))
+		pseudo = append(pseudo, 0, 0, 0, 6)
+	}
+	binary.BigEndian.PutUint16(tcp[16:], checksum(append(pseudo, tcp...)))
+	return append(ip, tcp...)
+}
+
+// checksum is the Internet checksum (RFC 1071).
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value)))...)
+}
+
+func writeBlock(w io.Writer, kind uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := make([]byte, 0, length)
+	block = binary.LittleEndian.AppendUint32(block, kind)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	_, err := w.Write(block)
+	return err
+}
+
+// readPCAPNG reads the frames of a pcapng capture written by this package.
+// Which side a frame belongs to comes from its interface, whether it is a
+// request from its direction flag. Latencies and errors are not read back,
+// and RTU frames of the target side read back as if they were Modbus TCP;
+// a replay only sends what clients sent, which never is.
+func readPCAPNG(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+	var tsUnit []time.Duration // Per interface
+	order := binary.ByteOrder(binary.LittleEndian)
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return frames, nil
+			}
+			return nil, err
+		}
+		// The section header's type reads the same in both byte orders;
+		// its magic tells which one the section uses.
+		kind := order.Uint32(head[0:])
+		if kind == blockSection {
+			var magic [4]byte
+			if _, err := io.ReadFull(r, magic[:]); err != nil {
+				return nil, err
+			}
+			order = binary.ByteOrder(binary.LittleEndian)
+			if binary.BigEndian.Uint32(magic[:]) == byteOrderMagic {
+				order = binary.BigEndian
+			}
+			tsUnit = nil
+		}
+		length := order.Uint32(head[4:])
+		if length < 12 || length > 1<<24 {
+			return nil, fmt.Errorf("invalid block length %d", length)
+		}
+		rest := int(length) - 8
+		if kind == blockSection {
+			rest -= 4
+		}
+		body := make([]byte, rest)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+		body = body[:len(body)-4] // Trailing length
+
+		switch kind {
+		case blockInterface:
+			unit := time.Microsecond
+			if len(body) >= 8 {
+				opts := parseOptions(order, body[8:])
+				if v, ok := opts[optTSResol]; ok && len(v) == 1 && v[0]&0x80 == 0 {
+					unit = time.Second
+					for i := byte(0); i < v[0] && unit > 1; i++ {
+						unit /= 10
+					}
+				}
+			}
+			tsUnit = append(tsUnit, unit)
+		case blockEnhancedPacket:
+			if len(body) < 20 {
+				return nil, errors.New("short packet block")
+			}
+			iface := order.Uint32(body[0:])
+			ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			capLen := int(order.Uint32(body[12:]))
+			if 20+capLen > len(body) || int(iface) >= len(tsUnit) {
+				return nil, errors.New("malformed packet block")
+			}
+			f, ok := frameFromPacket(body[20 : 20+capLen])
+			if !ok {
+				continue
+			}
+			f.Time = time.Unix(0, int64(ts)*int64(tsUnit[iface]))
+			f.Side = SideClient
+			if iface == interfaceOf[SideTarget] {
+				f.Side = SideTarget
+			}
+			flags := uint32(0)
+			if v, ok := parseOptions(order, body[20+capLen+pad4(capLen):])[optFlags]; ok && len(v) == 4 {
+				flags = order.Uint32(v)
+			}
+			f.Response = (flags&3 == flagOutbound) == (f.Side == SideClient)
+			frames = append(frames, f)
+		}
+	}
+}
+
+// parseOptions returns the options of a block by code; the last one of a
+// code wins.
+func parseOptions(order binary.ByteOrder, b []byte) map[uint16][]byte {
+	opts := make(map[uint16][]byte)
+	for len(b) >= 4 {
+		code, n := order.Uint16(b), int(order.Uint16(b[2:]))
+		if code == optEnd || 4+n > len(b) {
+			break
+		}
+		opts[code] = b[4 : 4+n]
+		b = b[4+n+pad4(n):]
+	}
+	return opts
+}
+
+// frameFromPacket takes the TCP payload and addresses out of an IP packet.
+func frameFromPacket(p []byte) (Frame, bool) {
+	if len(p) < 1 {
+		return Frame{}, false
+	}
+	var src, dst net.IP
+	var tcp []byte
+	switch p[0] >> 4 {
+	case 4:
+		ihl := int(p[0]&0x0F) * 4
+		if len(p) < ihl+20 || p[9] != 6 {
+			return Frame{}, false
+		}
+		src, dst, tcp = net.IP(p[12:16]), net.IP(p[16:20]), p[ihl:]
+	case 6:
+		if len(p) < 60 || p[6] != 6 {
+			return Frame{}, false
+		}
+		src, dst, tcp = net.IP(p[8:24]), net.IP(p[24:40]), p[40:]
+	default:
+		return Frame{}, false
+	}
+	offset := int(tcp[12]>>4) * 4
+	if offset < 20 || len(tcp) < offset {
+		return Frame{}, false
+	}
+	srcPort, dstPort := binary.BigEndian.Uint16(tcp[0:]), binary.BigEndian.Uint16(tcp[2:])
+	return Frame{
+		Src:  net.JoinHostPort(src.String(), strconv.Itoa(int(srcPort))),
+		Dst:  net.JoinHostPort(dst.String(), strconv.Itoa(int(dstPort))),
+		Data: append([]byte(nil), tcp[offset:]...),
+	}, true
+}
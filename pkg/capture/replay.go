@@ -0,0 +1,325 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"modbridge/pkg/modbus"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Read reads a capture in either format.
+func Read(r io.Reader) ([]Frame, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(head) == 4 && bytes.Equal(head, []byte{0x0A, 0x0D, 0x0D, 0x0A}) {
+		return readPCAPNG(br)
+	}
+	return readJSONL(br)
+}
+
+// ReadFile reads a capture file in either format.
+func ReadFile(path string) ([]Frame, error) {
+	f, err := os.Open(path) // #nosec G304 -- the operator names the file
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Exchange is a request a client sent and the response it got, if any.
+type Exchange struct {
+	Request  Frame
+	Response *Frame
+}
+
+// ClientExchanges pairs the client-side requests of a capture with their
+// responses, in the order the requests came in. A response belongs to the
+// earliest unanswered request on the same connection with the same
+// transaction ID.
+func ClientExchanges(frames []Frame) []Exchange {
+	type key struct {
+		client string
+		txID   uint16
+	}
+	sorted := append([]Frame(nil), frames...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	var exchanges []Exchange
+	open := make(map[key][]int)
+	for _, f := range sorted {
+		if f.Side != SideClient || len(f.Data) < 8 {
+			continue
+		}
+		txID, _ := modbus.FrameTxID(f.Data)
+		if !f.Response {
+			k := key{f.Src, txID}
+			open[k] = append(open[k], len(exchanges))
+			exchanges = append(exchanges, Exchange{Request: f})
+			continue
+		}
+		k := key{f.Dst, txID}
+		if waiting := open[k]; len(waiting) > 0 {
+			resp := f
+			exchanges[waiting[0]].Response = &resp
+			open[k] = waiting[1:]
+		}
+	}
+	return exchanges
+}
+
+// ReplayOptions describe a replay.
+type ReplayOptions struct {
+	Speed   float64       // 1 keeps the recorded pace, 2 doubles it; 0 sends each request once the last is answered
+	Timeout time.Duration // Per request (0 = 5s)
+}
+
+// Mismatch is a request that got a different answer than in the capture.
+type Mismatch struct {
+	Index   int    `json:"index"`
+	Request string `json:"request"`        // Hex
+	Want    string `json:"want,omitempty"` // Hex; empty when the capture had no answer
+	Got     string `json:"got,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	Sent        int           `json:"sent"`
+	Matched     int           `json:"matched"`  // Same answer as in the capture
+	Differed    int           `json:"differed"` // A different answer
+	Failed      int           `json:"failed"`   // No answer
+	Mismatches  []Mismatch    `json:"mismatches,omitempty"`
+	MaxLatency  time.Duration `json:"max_latency_ns"`
+	MeanLatency time.Duration `json:"mean_latency_ns"`
+}
+
+// maxMismatches bounds the mismatches a result lists; the counters go on.
+const maxMismatches = 100
+
+// Replay sends the requests the clients of a capture sent to addr, one at a
+// time over one connection, and compares every answer with the recorded one.
+// A connection that breaks is opened again for the next request.
+func Replay(ctx context.Context, frames []Frame, addr string, opts ReplayOptions) (ReplayResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	exchanges := ClientExchanges(frames)
+	if len(exchanges) == 0 {
+		return ReplayResult{}, errors.New("the capture holds no client requests")
+	}
+
+	var result ReplayResult
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var total time.Duration
+	start, first := time.Now(), exchanges[0].Request.Time
+	for i, ex := range exchanges {
+		if opts.Speed > 0 {
+			at := start.Add(time.Duration(float64(ex.Request.Time.Sub(first)) / opts.Speed))
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(time.Until(at)):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if conn == nil {
+			var err error
+			dialer := net.Dialer{Timeout: opts.Timeout}
+			if conn, err = dialer.DialContext(ctx, "tcp", addr); err != nil {
+				return result, fmt.Errorf("failed to connect to %s: %w", addr, err)
+			}
+		}
+		sent := time.Now()
+		got, err := exchange(conn, ex.Request.Data, opts.Timeout)
+		latency := time.Since(sent)
+		result.Sent++
+
+		m := Mismatch{Index: i, Request: hex.EncodeToString(ex.Request.Data), Got: hex.EncodeToString(got)}
+		if ex.Response != nil {
+			m.Want = hex.EncodeToString(ex.Response.Data)
+		}
+		switch {
+		case err != nil:
+			conn.Close()
+			conn = nil
+			result.Failed++
+			m.Error = err.Error()
+		case ex.Response != nil && bytes.Equal(got, ex.Response.Data):
+			result.Matched++
+			m.Index = -1
+		default:
+			result.Differed++
+		}
+		if err == nil {
+			total += latency
+			if latency > result.MaxLatency {
+				result.MaxLatency = latency
+			}
+		}
+		if m.Index >= 0 && len(result.Mismatches) < maxMismatches {
+			result.Mismatches = append(result.Mismatches, m)
+		}
+	}
+	if answered := result.Matched + result.Differed; answered > 0 {
+		result.MeanLatency = total / time.Duration(answered)
+	}
+	return result, nil
+}
+
+// exchange sends one frame and reads the answer with the same transaction ID.
+func exchange(conn net.Conn, req []byte, timeout time.Duration) ([]byte, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	want, _ := modbus.FrameTxID(req)
+	for {
+		resp, err := modbus.ReadFrame(conn)
+		if err != nil {
+			return nil, err
+		}
+		if txID, _ := modbus.FrameTxID(resp); txID == want {
+			return resp, nil
+		}
+	}
+}
+
+// Mock is a Modbus TCP server that answers like the device of a capture
+// did: a request gets the response recorded for the same request, the
+// recorded ones in turn when there were several. A request the capture does
+// not hold is answered with exception 0x0B (gateway target failed to
+// respond).
+type Mock struct {
+	listener  net.Listener
+	mu        sync.Mutex
+	responses map[string][][]byte // By request PDU, without the MBAP header
+	next      map[string]int
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewMock creates a mock from the client-side exchanges of a capture.
+func NewMock(frames []Frame) *Mock {
+	m := &Mock{responses: make(map[string][][]byte), next: make(map[string]int), conns: make(map[net.Conn]struct{})}
+	for _, ex := range ClientExchanges(frames) {
+		if ex.Response != nil && len(ex.Response.Data) >= 8 {
+			k := string(ex.Request.Data[6:])
+			m.responses[k] = append(m.responses[k], ex.Response.Data)
+		}
+	}
+	return m
+}
+
+// Listen starts serving on addr.
+func (m *Mock) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	m.listener = l
+	m.wg.Add(1)
+	go m.accept()
+	return nil
+}
+
+// Addr returns the address the mock listens on.
+func (m *Mock) Addr() string {
+	return m.listener.Addr().String()
+}
+
+// Close stops the mock and ends its connections.
+func (m *Mock) Close() error {
+	err := m.listener.Close()
+	m.mu.Lock()
+	for c := range m.conns {
+		c.Close()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return err
+}
+
+func (m *Mock) accept() {
+	defer m.wg.Done()
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		m.conns[conn] = struct{}{}
+		m.mu.Unlock()
+		m.wg.Add(1)
+		go m.serve(conn)
+	}
+}
+
+func (m *Mock) serve(conn net.Conn) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.conns, conn)
+		m.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		req, err := modbus.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(m.answer(req)); err != nil {
+			return
+		}
+	}
+}
+
+// answer returns the recorded response to a request, with the request's
+// transaction ID.
+func (m *Mock) answer(req []byte) []byte {
+	if len(req) < 8 {
+		return modbus.CreateExceptionResponse(req, 0x0B)
+	}
+	k := string(req[6:])
+	m.mu.Lock()
+	recorded := m.responses[k]
+	var resp []byte
+	if len(recorded) > 0 {
+		resp = append([]byte(nil), recorded[m.next[k]%len(recorded)]...)
+		m.next[k]++
+	}
+	m.mu.Unlock()
+	if resp == nil {
+		return modbus.CreateExceptionResponse(req, 0x0B)
+	}
+	txID, _ := modbus.FrameTxID(req)
+	modbus.SetFrameTxID(resp, txID)
+	return resp
+}
@@ -3,18 +3,26 @@
 // Created by Xerolux
 // https://github.com/Xerolux/modbridge
 
-// NOT WIRED UP: no code path reaches this package yet.
-//
-// High-availability coordination across instances. Kept as unfinished work;
-// nothing imports it today.
+// Package cluster runs several ModBridge nodes as one: they exchange
+// heartbeats over HTTP, and exactly one of them, the active node, owns the
+// proxies while the others stand by to take over.
 package cluster
 
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -29,37 +37,119 @@ const (
 	NodeStateLeft
 )
 
+// Headers authenticating a request between nodes. The signature is the hex
+// HMAC-SHA256 of the timestamp, the method, the path and the body, keyed
+// with the shared secret.
+const (
+	HeaderTimestamp = "X-Cluster-Timestamp"
+	HeaderSignature = "X-Cluster-Signature"
+)
+
+// maxClockSkew is how far the timestamp of a signed request may be off.
+const maxClockSkew = time.Minute
+
 // Node represents a cluster member
 type Node struct {
 	ID       string    `json:"id"`
 	Address  string    `json:"address"`
 	State    NodeState `json:"state"`
 	LastSeen time.Time `json:"last_seen"`
+	Active   bool      `json:"active"`   // Owns the proxies
+	Eligible bool      `json:"eligible"` // May become the active node
+}
+
+// Config holds the settings of a node.
+type Config struct {
+	NodeID  string
+	Address string   // host:port or URL the other nodes reach this one at
+	Peers   []string // The other nodes, host:port or URL; they make up the quorum
+	Secret  string   // Signs every request between nodes (empty = unsigned)
+	// Tiebreaker is a host:port a node of a two-node cluster must reach to
+	// have the third vote, such as the router both are connected to.
+	Tiebreaker string
+	Heartbeat  time.Duration // Between two heartbeats (0 = 15 s)
+	Timeout    time.Duration // A node not heard from for this long is gone (0 = 3 × Heartbeat)
+	// OnActivate is called when this node becomes the active one, OnFence
+	// when it must stop acting at once. OnFence returns only once the
+	// proxies are stopped; the other nodes are told after it returned.
+	OnActivate func()
+	OnFence    func(reason string)
+}
+
+// peerReach is the outcome of the last heartbeat sent to a configured peer.
+type peerReach struct {
+	nodeID   string
+	lastOK   time.Time
+	lastErr  string
+	attempts int
 }
 
 // Cluster manages cluster membership via HTTP-based peer discovery.
 // Each node announces itself to a list of seed addresses and periodically
-// exchanges heartbeats so all members stay in sync.
+// exchanges heartbeats so all members stay in sync. The answer to a
+// heartbeat carries the state of the node that received it, so either
+// direction of a heartbeat tells each node about the other.
 type Cluster struct {
 	mu    sync.RWMutex
 	nodes map[string]*Node
 	self  *Node
+	cfg   Config
+
+	reach          map[string]*peerReach // By configured peer address
+	departed       map[string]bool       // Nodes that said they left, by ID; they do not vote until they are back
+	tiebreakerOK   bool
+	active         bool                 // This node runs the proxies
+	activeSince    time.Time            // When this node became the active one
+	joinedUntil    time.Time            // No takeover before this: a node not heard from yet may run the proxies
+	claims         map[string]time.Time // By node ID: until when it may run the proxies unbeknownst to this one
+	holdUntil      time.Time            // This node stepped down and stays out until then
+	lastTransition string
+
+	transition sync.Mutex    // Serializes activation and fencing
+	wake       chan struct{} // Has the heartbeat loop run at once
+	done       chan struct{} // Closed when the heartbeat loop has ended
 
 	client *http.Client
 }
 
 // NewCluster creates a new cluster
 func NewCluster(nodeID, address string) *Cluster {
+	return New(Config{NodeID: nodeID, Address: address})
+}
+
+// New creates a node with its settings. It does nothing until Join.
+func New(cfg Config) *Cluster {
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 15 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 3 * cfg.Heartbeat
+	}
 	c := &Cluster{
 		nodes: make(map[string]*Node),
 		self: &Node{
-			ID:      nodeID,
-			Address: address,
-			State:   NodeStateReady,
+			ID:       cfg.NodeID,
+			Address:  cfg.Address,
+			State:    NodeStateReady,
+			Eligible: true,
+		},
+		cfg:      cfg,
+		reach:    make(map[string]*peerReach),
+		departed: make(map[string]bool),
+		claims:   make(map[string]time.Time),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		// A heartbeat that takes longer than the next one is due is lost.
+		// Nodes prove who they are with the secret, not with certificates.
+		client: &http.Client{
+			Timeout:   min(cfg.Heartbeat, 5*time.Second),
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}, //nolint:gosec // Authenticated by the secret
 		},
-		client: &http.Client{Timeout: 5 * time.Second},
 	}
-	c.nodes[nodeID] = c.self
+	for _, addr := range cfg.Peers {
+		c.reach[addr] = &peerReach{}
+	}
+	c.nodes[cfg.NodeID] = c.self
 	return c
 }
 
@@ -69,6 +159,9 @@ func NewCluster(nodeID, address string) *Cluster {
 func (c *Cluster) Join(ctx context.Context, discoveryAddrs []string) error {
 	c.mu.Lock()
 	c.self.State = NodeStateReady
+	// Whoever ran the proxies before may still do so: no takeover until it
+	// had the time to say so, unless every peer has answered by then.
+	c.joinedUntil = time.Now().Add(c.takeoverDelay())
 	c.mu.Unlock()
 
 	// Announce to all seed nodes and collect their known members.
@@ -94,7 +187,77 @@ func (c *Cluster) Join(ctx context.Context, discoveryAddrs []string) error {
 	return nil
 }
 
-// announceToNode sends a POST with this node's info to addr/cluster/join.
+// Done is closed when the heartbeat loop has ended, after ctx of Join was
+// cancelled: this node has stopped acting and told the others.
+func (c *Cluster) Done() <-chan struct{} {
+	return c.done
+}
+
+// takeoverDelay is how long a node waits after the last word of the active
+// one before it takes over. The active node fences itself once it has not
+// heard from a quorum within Timeout, so by then it has stopped.
+func (c *Cluster) takeoverDelay() time.Duration {
+	return 2 * c.cfg.Timeout
+}
+
+// PeerURL returns the URL of a path on a node given as host:port or URL.
+func PeerURL(addr, path string) string {
+	if strings.Contains(addr, "://") {
+		return strings.TrimSuffix(addr, "/") + path
+	}
+	return "http://" + addr + path
+}
+
+// Sign adds the signature headers to a request between nodes. Without a
+// secret it leaves the request unsigned.
+func Sign(secret string, req *http.Request, body []byte) {
+	if secret == "" {
+		return
+	}
+	ts := time.Now().Unix()
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, signature(secret, ts, req.Method, req.URL.Path, body))
+}
+
+// Verify checks the signature of a request between nodes. Without a secret
+// every request passes.
+func Verify(secret string, r *http.Request, body []byte) error {
+	if secret == "" {
+		return nil
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return errors.New("missing timestamp")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.New("timestamp out of range; are the clocks of the nodes set?")
+	}
+	want := signature(secret, ts, r.Method, r.URL.Path, body)
+	if !hmac.Equal([]byte(r.Header.Get(HeaderSignature)), []byte(want)) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// sign adds the signature headers to a request between nodes.
+func (c *Cluster) sign(req *http.Request, body []byte) {
+	Sign(c.cfg.Secret, req, body)
+}
+
+// verify checks the signature of a request between nodes.
+func (c *Cluster) verify(r *http.Request, body []byte) error {
+	return Verify(c.cfg.Secret, r, body)
+}
+
+func signature(secret string, ts int64, method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s %s.", ts, method, path)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// announceToNode sends a POST with this node's info to addr/cluster/join,
+// and takes in the state of the node that answers.
 func (c *Cluster) announceToNode(addr string) error {
 	c.mu.RLock()
 	self := *c.self
@@ -104,26 +267,48 @@ func (c *Cluster) announceToNode(addr string) error {
 	if err != nil {
 		return err
 	}
-	url := fmt.Sprintf("http://%s/cluster/join", addr)
-	resp, err := c.client.Post(url, "application/json", bytes.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, PeerURL(addr, "/cluster/join"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, body)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("peer %s returned %d", addr, resp.StatusCode)
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("peer %s returned %d: %s", addr, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var peer Node
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&peer); err == nil && peer.ID != "" {
+		c.observe(&peer)
+		c.mu.Lock()
+		if r, ok := c.reach[addr]; ok {
+			r.nodeID = peer.ID
+		}
+		c.mu.Unlock()
 	}
 	return nil
 }
 
 // fetchMembers fetches the member list from addr/cluster/members.
 func (c *Cluster) fetchMembers(addr string) ([]*Node, error) {
-	url := fmt.Sprintf("http://%s/cluster/members", addr)
-	resp, err := c.client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, PeerURL(addr, "/cluster/members"), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, nil)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("peer %s returned %d", addr, resp.StatusCode)
+	}
 	var members []*Node
 	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil {
 		return nil, err
@@ -131,75 +316,181 @@ func (c *Cluster) fetchMembers(addr string) ([]*Node, error) {
 	return members, nil
 }
 
+// observe takes in what a node said about itself, in a heartbeat it sent or
+// in the answer to one.
+func (c *Cluster) observe(peer *Node) {
+	if peer.ID == "" {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if peer.ID == c.self.ID {
+		return
+	}
+	n := *peer
+	n.LastSeen = now
+	if n.State != NodeStateLeaving && n.State != NodeStateLeft {
+		n.State = NodeStateReady
+	}
+	c.nodes[n.ID] = &n
+	if n.State == NodeStateLeft {
+		c.departed[n.ID] = true
+	} else {
+		delete(c.departed, n.ID)
+	}
+
+	// A node that runs the proxies, or may start them any moment, may do so
+	// until it fenced itself for not hearing from this one. One that cannot
+	// start them is out of the way.
+	if n.Active || (n.Eligible && n.State == NodeStateReady) {
+		c.claims[n.ID] = now.Add(c.takeoverDelay())
+	} else {
+		delete(c.claims, n.ID)
+	}
+}
+
 // heartbeatLoop periodically announces this node to all known peers and evicts
-// peers that haven't been seen within 3× the heartbeat interval.
+// peers that haven't been seen within the timeout, then decides whether this
+// node should run the proxies.
 func (c *Cluster) heartbeatLoop(ctx context.Context) {
-	const interval = 15 * time.Second
-	const ttl = 3 * interval
+	defer close(c.done)
 
-	ticker := time.NewTicker(interval)
+	ticker := time.NewTicker(c.cfg.Heartbeat)
 	defer ticker.Stop()
+	c.beat()
 
 	for {
 		select {
 		case <-ctx.Done():
-			c.mu.Lock()
-			c.self.State = NodeStateLeft
-			c.mu.Unlock()
+			c.leave()
 			return
 		case <-ticker.C:
-			// Update our own LastSeen.
-			c.mu.Lock()
-			c.self.LastSeen = time.Now()
-			c.mu.Unlock()
+		case <-c.wake:
+		}
+		c.beat()
+	}
+}
 
-			// Announce to peers.
-			c.mu.RLock()
-			peers := make([]*Node, 0, len(c.nodes))
-			for _, n := range c.nodes {
-				if n.ID != c.self.ID {
-					peers = append(peers, n)
-				}
-			}
-			c.mu.RUnlock()
+// beat runs one round: heartbeats out, stale nodes out, and a decision.
+func (c *Cluster) beat() {
+	// Update our own LastSeen.
+	c.mu.Lock()
+	c.self.LastSeen = time.Now()
+	c.mu.Unlock()
 
-			for _, peer := range peers {
-				_ = c.announceToNode(peer.Address) //nolint:errcheck // best effort
-			}
+	c.announceAll()
+
+	// Evict stale nodes.
+	c.mu.Lock()
+	cutoff := time.Now().Add(-c.cfg.Timeout)
+	for id, n := range c.nodes {
+		if id != c.self.ID && n.LastSeen.Before(cutoff) {
+			delete(c.nodes, id)
+		}
+	}
+	c.mu.Unlock()
+
+	c.decide(time.Now())
+}
+
+// announceAll sends a heartbeat to every configured and known peer, and
+// checks the tiebreaker, all at once.
+func (c *Cluster) announceAll() {
+	c.mu.RLock()
+	targets := make(map[string]bool, len(c.reach)+len(c.nodes))
+	for addr := range c.reach {
+		targets[addr] = true
+	}
+	for _, n := range c.nodes {
+		if n.ID != c.self.ID && n.Address != "" && !c.knownAddressLocked(n.ID) {
+			targets[n.Address] = true
+		}
+	}
+	c.mu.RUnlock()
 
-			// Evict stale nodes.
+	var wg sync.WaitGroup
+	for addr := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.announceToNode(addr)
 			c.mu.Lock()
-			cutoff := time.Now().Add(-ttl)
-			for id, n := range c.nodes {
-				if id != c.self.ID && n.LastSeen.Before(cutoff) {
-					delete(c.nodes, id)
-				}
+			defer c.mu.Unlock()
+			r, ok := c.reach[addr]
+			if !ok {
+				return
+			}
+			r.attempts++
+			if err != nil {
+				r.lastErr = err.Error()
+			} else {
+				r.lastOK, r.lastErr = time.Now(), ""
+			}
+		}()
+	}
+	if c.countsTiebreaker() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", c.cfg.Tiebreaker, c.client.Timeout)
+			if err == nil {
+				conn.Close()
 			}
+			c.mu.Lock()
+			c.tiebreakerOK = err == nil
 			c.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+// knownAddressLocked reports whether a node is reached through a configured
+// address already. The caller holds mu.
+func (c *Cluster) knownAddressLocked(nodeID string) bool {
+	for _, r := range c.reach {
+		if r.nodeID == nodeID {
+			return true
 		}
 	}
+	return false
 }
 
-// HandleJoin processes an inbound join announcement from a peer.
+// countsTiebreaker reports whether the tiebreaker has a vote: only in a
+// cluster of two, where it decides which one has the majority.
+func (c *Cluster) countsTiebreaker() bool {
+	return c.cfg.Tiebreaker != "" && len(c.cfg.Peers) == 1
+}
+
+// HandleJoin processes an inbound join announcement from a peer, and
+// answers with this node's state.
 // Register this as an HTTP handler at POST /cluster/join.
 func (c *Cluster) HandleJoin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.verify(r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	var peer Node
-	if err := json.NewDecoder(r.Body).Decode(&peer); err != nil {
+	if err := json.Unmarshal(body, &peer); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	peer.LastSeen = time.Now()
-	peer.State = NodeStateReady
-
-	c.mu.Lock()
-	c.nodes[peer.ID] = &peer
-	c.mu.Unlock()
+	c.observe(&peer)
 
-	w.WriteHeader(http.StatusOK)
+	c.mu.RLock()
+	self := *c.self
+	c.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(self)
 }
 
 // HandleMembers returns the current cluster member list as JSON.
@@ -209,6 +500,10 @@ func (c *Cluster) HandleMembers(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if err := c.verify(r, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	members := c.Members()
 	if err := json.NewEncoder(w).Encode(members); err != nil {
@@ -221,9 +516,21 @@ func (c *Cluster) Leave() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.self.State = NodeStateLeaving
+	c.self.Eligible = false
 	return nil
 }
 
+// leave stops acting and tells the peers, so that one of them takes over
+// without waiting for the timeout.
+func (c *Cluster) leave() {
+	_ = c.Leave()
+	c.fence("node is shutting down")
+	c.mu.Lock()
+	c.self.State = NodeStateLeft
+	c.mu.Unlock()
+	c.announceAll()
+}
+
 // Members returns all ready cluster members
 func (c *Cluster) Members() []*Node {
 	c.mu.RLock()
@@ -232,7 +539,8 @@ func (c *Cluster) Members() []*Node {
 	members := make([]*Node, 0, len(c.nodes))
 	for _, node := range c.nodes {
 		if node.State == NodeStateReady {
-			members = append(members, node)
+			n := *node
+			members = append(members, &n)
 		}
 	}
 	return members
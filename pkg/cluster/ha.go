@@ -0,0 +1,287 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNotActive is returned by StepDown on a node that runs no proxies.
+var ErrNotActive = errors.New("this node is not the active one")
+
+// Status is what a node knows about the cluster.
+type Status struct {
+	NodeID         string       `json:"node_id"`
+	Address        string       `json:"address"`
+	Role           string       `json:"role"`                  // "active" or "standby"
+	ActiveNode     string       `json:"active_node,omitempty"` // The node running the proxies, as far as this one knows
+	ActiveSince    *time.Time   `json:"active_since,omitempty"`
+	Quorum         bool         `json:"quorum"`
+	Votes          int          `json:"votes"`  // This node, the peers it hears and the tiebreaker
+	Voters         int          `json:"voters"` // Of which it needs more than half
+	Eligible       bool         `json:"eligible"`
+	Tiebreaker     string       `json:"tiebreaker,omitempty"`
+	TiebreakerOK   bool         `json:"tiebreaker_reachable"`
+	LastTransition string       `json:"last_transition,omitempty"`
+	Peers          []PeerStatus `json:"peers"`
+}
+
+// PeerStatus is what a node knows about one of the others.
+type PeerStatus struct {
+	Address   string     `json:"address"`
+	NodeID    string     `json:"node_id,omitempty"`
+	Reachable bool       `json:"reachable"` // Heard from within the timeout
+	Active    bool       `json:"active"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	Error     string     `json:"error,omitempty"` // Of the last heartbeat sent to it
+}
+
+// Active reports whether this node runs the proxies.
+func (c *Cluster) Active() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// decide makes this node the active one, or fences it:
+//
+//   - The active node fences itself as soon as it lacks a quorum, or finds
+//     another active node with a smaller ID (after a split healed).
+//   - A standby node takes over when it has a quorum, no node that it hears
+//     is active, every node that it does not hear has been silent for the
+//     takeover delay (or said it is out), and no eligible node it hears
+//     has a smaller ID.
+func (c *Cluster) decide(now time.Time) {
+	c.transition.Lock()
+	defer c.transition.Unlock()
+
+	c.mu.Lock()
+	if !c.self.Eligible && c.self.State == NodeStateReady && !now.Before(c.holdUntil) {
+		c.self.Eligible = true
+	}
+	votes, voters := c.votesLocked(now)
+	quorum := 2*votes > voters
+	var reason string
+	activate := false
+	if c.active {
+		switch other := c.activePeerLocked(now); {
+		case !quorum:
+			reason = fmt.Sprintf("lost the quorum (%d of %d votes)", votes, voters)
+		case other != nil && other.ID < c.self.ID:
+			reason = fmt.Sprintf("node %s is active as well", other.ID)
+		}
+	} else {
+		activate = quorum && c.self.Eligible && c.mayTakeOverLocked(now)
+	}
+	c.mu.Unlock()
+
+	switch {
+	case reason != "":
+		c.fenceLocked(reason)
+	case activate:
+		c.activateLocked(now, votes, voters)
+	}
+}
+
+// votesLocked counts the votes this node has: its own, one for each peer
+// heard from within the timeout, and the tiebreaker's in a cluster of two.
+// A peer that said it left votes no more until it is back, so the last
+// node standing keeps the quorum. The caller holds mu.
+func (c *Cluster) votesLocked(now time.Time) (votes, voters int) {
+	peers := len(c.cfg.Peers)
+	for _, r := range c.reach {
+		if r.nodeID != "" && c.departed[r.nodeID] {
+			peers--
+		}
+	}
+	heard := 0
+	for _, n := range c.nodes {
+		if n.ID != c.self.ID && c.aliveLocked(n, now) {
+			heard++
+		}
+	}
+	// Only the configured peers vote; a node that joined on its own does not.
+	votes, voters = 1+min(heard, peers), 1+peers
+	if c.countsTiebreaker() && peers == 1 {
+		voters++
+		if c.tiebreakerOK {
+			votes++
+		}
+	}
+	return votes, voters
+}
+
+// aliveLocked reports whether a peer was heard from within the timeout and
+// has not left. The caller holds mu.
+func (c *Cluster) aliveLocked(n *Node, now time.Time) bool {
+	return (n.State == NodeStateReady || n.State == NodeStateLeaving) && now.Sub(n.LastSeen) <= c.cfg.Timeout
+}
+
+// activePeerLocked returns a peer heard from within the timeout that says
+// it runs the proxies. The caller holds mu.
+func (c *Cluster) activePeerLocked(now time.Time) *Node {
+	for _, n := range c.nodes {
+		if n.ID != c.self.ID && n.Active && c.aliveLocked(n, now) {
+			return n
+		}
+	}
+	return nil
+}
+
+// mayTakeOverLocked reports whether nothing stops this standby node from
+// becoming the active one. The caller holds mu.
+func (c *Cluster) mayTakeOverLocked(now time.Time) bool {
+	if c.activePeerLocked(now) != nil {
+		return false
+	}
+	heard := 0
+	for _, n := range c.nodes {
+		if n.ID == c.self.ID || !c.aliveLocked(n, now) {
+			continue
+		}
+		heard++
+		if n.Eligible && n.ID < c.self.ID {
+			return false // It goes first
+		}
+	}
+	// With every peer heard and none of them active, nobody runs the
+	// proxies; otherwise a silent one may, until its claim ran out.
+	if heard >= len(c.cfg.Peers) {
+		return true
+	}
+	if now.Before(c.joinedUntil) {
+		return false
+	}
+	for id, until := range c.claims {
+		if n, ok := c.nodes[id]; ok && c.aliveLocked(n, now) {
+			continue // Heard, and not active
+		}
+		if now.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// activateLocked makes this node the active one: it says so first, then
+// starts acting. The caller holds transition.
+func (c *Cluster) activateLocked(now time.Time, votes, voters int) {
+	c.mu.Lock()
+	c.active, c.activeSince = true, now
+	c.self.Active = true
+	c.lastTransition = fmt.Sprintf("%s: became active (%d of %d votes)", now.Format(time.RFC3339), votes, voters)
+	onActivate := c.cfg.OnActivate
+	c.mu.Unlock()
+
+	if onActivate != nil {
+		onActivate()
+	}
+	c.wakeUp()
+}
+
+// fence stops this node from acting, if it does.
+func (c *Cluster) fence(reason string) {
+	c.transition.Lock()
+	defer c.transition.Unlock()
+	c.fenceLocked(reason)
+}
+
+// fenceLocked stops this node from acting, and only then tells the others
+// it no longer does. The caller holds transition.
+func (c *Cluster) fenceLocked(reason string) {
+	c.mu.Lock()
+	if !c.active {
+		c.mu.Unlock()
+		return
+	}
+	c.active = false
+	c.lastTransition = fmt.Sprintf("%s: stopped, %s", time.Now().Format(time.RFC3339), reason)
+	onFence := c.cfg.OnFence
+	c.mu.Unlock()
+
+	if onFence != nil {
+		onFence(reason)
+	}
+
+	c.mu.Lock()
+	c.self.Active = false
+	c.mu.Unlock()
+	c.wakeUp()
+}
+
+// StepDown hands the proxies over: this node stops acting and stays out for
+// the takeover delay, so that another one takes over. If none does, it
+// takes them back afterwards.
+func (c *Cluster) StepDown() error {
+	c.transition.Lock()
+	defer c.transition.Unlock()
+
+	c.mu.Lock()
+	if !c.active {
+		c.mu.Unlock()
+		return ErrNotActive
+	}
+	c.self.Eligible = false
+	c.holdUntil = time.Now().Add(c.takeoverDelay())
+	c.mu.Unlock()
+
+	c.fenceLocked("stepped down")
+	return nil
+}
+
+// wakeUp has the heartbeat loop tell the others at once.
+func (c *Cluster) wakeUp() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status reports what this node knows about the cluster.
+func (c *Cluster) Status() Status {
+	now := time.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	votes, voters := c.votesLocked(now)
+	st := Status{
+		NodeID:         c.self.ID,
+		Address:        c.self.Address,
+		Role:           "standby",
+		Quorum:         2*votes > voters,
+		Votes:          votes,
+		Voters:         voters,
+		Eligible:       c.self.Eligible,
+		LastTransition: c.lastTransition,
+		Peers:          []PeerStatus{},
+	}
+	if c.active {
+		st.Role, st.ActiveNode = "active", c.self.ID
+		since := c.activeSince
+		st.ActiveSince = &since
+	} else if n := c.activePeerLocked(now); n != nil {
+		st.ActiveNode = n.ID
+	}
+	if c.countsTiebreaker() {
+		st.Tiebreaker, st.TiebreakerOK = c.cfg.Tiebreaker, c.tiebreakerOK
+	}
+
+	for addr, r := range c.reach {
+		ps := PeerStatus{Address: addr, NodeID: r.nodeID, Error: r.lastErr}
+		if n, ok := c.nodes[r.nodeID]; ok && r.nodeID != "" {
+			seen := n.LastSeen
+			ps.LastSeen = &seen
+			ps.Reachable = c.aliveLocked(n, now)
+			ps.Active = n.Active && ps.Reachable
+		}
+		st.Peers = append(st.Peers, ps)
+	}
+	sort.Slice(st.Peers, func(i, j int) bool { return st.Peers[i].Address < st.Peers[j].Address })
+	return st
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testHeartbeat = 30 * time.Millisecond
+	testTimeout   = 150 * time.Millisecond
+)
+
+// proxies stands in for the proxy listeners: it fails the test if two nodes
+// run them at once.
+type proxies struct {
+	t       *testing.T
+	mu      sync.Mutex
+	running map[string]bool
+	log     []string
+}
+
+func (p *proxies) start(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for other := range p.running {
+		p.t.Errorf("%s started the proxies while %s runs them", id, other)
+	}
+	p.running[id] = true
+	p.log = append(p.log, "+"+id)
+}
+
+func (p *proxies) stop(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.running, id)
+	p.log = append(p.log, "-"+id)
+}
+
+func (p *proxies) owner() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	owners := make([]string, 0, len(p.running))
+	for id := range p.running {
+		owners = append(owners, id)
+	}
+	sort.Strings(owners)
+	if len(owners) != 1 {
+		return ""
+	}
+	return owners[0]
+}
+
+// testNode is a node on a loopback HTTP server. Cutting it off drops every
+// heartbeat to and from it, as if its network cable was pulled.
+type testNode struct {
+	*Cluster
+	srv    *httptest.Server
+	cut    atomic.Bool
+	cancel context.CancelFunc
+}
+
+type cutTransport struct {
+	n    *testNode
+	next http.RoundTripper
+}
+
+func (t cutTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.n.cut.Load() {
+		return nil, errors.New("network is unreachable")
+	}
+	return t.next.RoundTrip(r)
+}
+
+// startNodes starts one node for each ID, all peers of each other.
+// tiebreaker, if not nil, returns the tiebreaker of a node.
+func startNodes(t *testing.T, p *proxies, tiebreaker func(id string) string, ids ...string) map[string]*testNode {
+	t.Helper()
+	nodes := make(map[string]*testNode, len(ids))
+	for _, id := range ids {
+		n := &testNode{}
+		n.srv = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n.cut.Load() {
+				http.Error(w, "unreachable", http.StatusServiceUnavailable)
+				return
+			}
+			switch r.URL.Path {
+			case "/cluster/join":
+				n.HandleJoin(w, r)
+			case "/cluster/members":
+				n.HandleMembers(w, r)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		nodes[id] = n
+	}
+	for _, id := range ids {
+		n := nodes[id]
+		var peers []string
+		tb := ""
+		if tiebreaker != nil {
+			tb = tiebreaker(id)
+		}
+		for _, other := range ids {
+			if other != id {
+				peers = append(peers, nodes[other].srv.Listener.Addr().String())
+			}
+		}
+		n.Cluster = New(Config{
+			NodeID:     id,
+			Address:    n.srv.Listener.Addr().String(),
+			Peers:      peers,
+			Secret:     "s3cret",
+			Tiebreaker: tb,
+			Heartbeat:  testHeartbeat,
+			Timeout:    testTimeout,
+			OnActivate: func() { p.start(id) },
+			OnFence:    func(string) { p.stop(id) },
+		})
+		n.client.Transport = cutTransport{n: n, next: n.client.Transport}
+		n.srv.Start()
+	}
+	for _, id := range ids {
+		n := nodes[id]
+		ctx, cancel := context.WithCancel(context.Background())
+		n.cancel = cancel
+		if err := n.Join(ctx, n.cfg.Peers); err != nil {
+			t.Fatalf("Join(%s): %v", id, err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			n.stop()
+		}
+	})
+	return nodes
+}
+
+// stop shuts the node down gracefully.
+func (n *testNode) stop() {
+	n.cancel()
+	<-n.Done()
+	n.srv.Close()
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExactlyOneNodeIsActive(t *testing.T) {
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, nil, "a", "b", "c")
+
+	waitFor(t, "an active node", func() bool { return p.owner() != "" })
+	// It stays that way, and the others know who it is.
+	time.Sleep(3 * testTimeout)
+	if got := p.owner(); got != "a" {
+		t.Fatalf("owner = %q, want a", got)
+	}
+	for _, id := range []string{"b", "c"} {
+		st := nodes[id].Status()
+		if st.Role != "standby" || st.ActiveNode != "a" || !st.Quorum || st.Votes != 3 {
+			t.Fatalf("status of %s = %+v", id, st)
+		}
+	}
+}
+
+func TestStandbyTakesOverWhenActiveNodeIsCutOff(t *testing.T) {
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, nil, "a", "b", "c")
+	waitFor(t, "a to be active", func() bool { return p.owner() == "a" })
+
+	nodes["a"].cut.Store(true)
+	// a fences itself for lack of a quorum before b takes over; proxies
+	// fails the test otherwise.
+	waitFor(t, "b to take over", func() bool { return p.owner() == "b" })
+	if st := nodes["a"].Status(); st.Role != "standby" || st.Quorum {
+		t.Fatalf("status of the cut-off node = %+v", st)
+	}
+
+	// Once it is back, it stays out of the way.
+	nodes["a"].cut.Store(false)
+	time.Sleep(3 * testTimeout)
+	if got := p.owner(); got != "b" {
+		t.Fatalf("owner after a came back = %q, want b", got)
+	}
+}
+
+func TestTwoNodesWithoutQuorumStandBy(t *testing.T) {
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, nil, "a", "b")
+	waitFor(t, "a to be active", func() bool { return p.owner() == "a" })
+
+	// Neither side of a split can tell whether the other is dead.
+	nodes["a"].cut.Store(true)
+	waitFor(t, "a to fence itself", func() bool { return !nodes["a"].Active() })
+	time.Sleep(3 * testTimeout)
+	if got := p.owner(); got != "" {
+		t.Fatalf("owner without a quorum = %q", got)
+	}
+}
+
+// listen accepts and drops TCP connections on a loopback port until the
+// test ends or it is closed.
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestTiebreakerGivesTwoNodesAQuorum(t *testing.T) {
+	// Both reach the same router, each through its own port here, so that
+	// one of them can lose it.
+	routers := map[string]net.Listener{"a": listen(t), "b": listen(t)}
+
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, func(id string) string { return routers[id].Addr().String() }, "a", "b")
+	waitFor(t, "a to be active", func() bool { return p.owner() == "a" })
+
+	// a loses its network: no peer and no tiebreaker.
+	nodes["a"].cut.Store(true)
+	routers["a"].Close()
+	waitFor(t, "b to take over", func() bool { return p.owner() == "b" })
+	if st := nodes["b"].Status(); !st.TiebreakerOK || st.Votes != 2 || st.Voters != 3 {
+		t.Fatalf("status of b = %+v", st)
+	}
+}
+
+func TestGracefulShutdownHandsOver(t *testing.T) {
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, nil, "a", "b")
+	waitFor(t, "a to be active", func() bool { return p.owner() == "a" })
+
+	// A node that says it left no longer votes, so b alone has the quorum.
+	nodes["a"].stop()
+	waitFor(t, "b to take over", func() bool { return p.owner() == "b" })
+}
+
+func TestStepDown(t *testing.T) {
+	p := &proxies{t: t, running: make(map[string]bool)}
+	nodes := startNodes(t, p, nil, "a", "b")
+	waitFor(t, "a to be active", func() bool { return p.owner() == "a" })
+
+	if err := nodes["b"].StepDown(); !errors.Is(err, ErrNotActive) {
+		t.Fatalf("StepDown on standby = %v, want ErrNotActive", err)
+	}
+	if err := nodes["a"].StepDown(); err != nil {
+		t.Fatalf("StepDown: %v", err)
+	}
+	waitFor(t, "b to take over", func() bool { return p.owner() == "b" })
+	time.Sleep(3 * testTimeout)
+	if got := p.owner(); got != "b" {
+		t.Fatalf("owner after the hold = %q, want b", got)
+	}
+}
+
+func TestRejectsUnsignedHeartbeat(t *testing.T) {
+	c := New(Config{NodeID: "a", Address: "127.0.0.1:9001", Secret: "s3cret"})
+	body := []byte(`{"id":"evil","address":"127.0.0.1:9666","state":1,"active":true}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/cluster/join", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	c.HandleJoin(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned: got %d, want 401", w.Code)
+	}
+
+	other := New(Config{NodeID: "b", Secret: "wrong"})
+	req = httptest.NewRequest(http.MethodPost, "/cluster/join", bytes.NewReader(body))
+	other.sign(req, body)
+	w = httptest.NewRecorder()
+	c.HandleJoin(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: got %d, want 401", w.Code)
+	}
+	if len(c.Members()) != 1 {
+		t.Fatalf("a rejected heartbeat added a member: %v", c.Members())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/cluster/join", bytes.NewReader(body))
+	c.sign(req, body)
+	w = httptest.NewRecorder()
+	c.HandleJoin(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed: got %d, want 200", w.Code)
+	}
+}
@@ -9,9 +9,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"modbridge/pkg/mapping"
+	"net"
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 // FlexibleTags is a custom type that can unmarshal both string and array for tags
@@ -79,6 +82,7 @@ type ProxyConfig struct {
 	CacheEnabled      bool   `json:"cache_enabled"`      // Serve repeated reads from a cache instead of asking the target every time
 	CacheTTLMs        int    `json:"cache_ttl_ms"`       // Lifetime of a cached read (ms, 0 = 5000). A cached value is not the live value.
 	PollIntervalMs    int    `json:"poll_interval_ms"`   // Refresh cached reads in the background at this interval (ms, 0 = passive cache only)
+	CoalesceReads     bool   `json:"coalesce_reads"`     // Let identical reads in flight at the same time share one request to the target
 	CalibratedAt      string `json:"calibrated_at"`      // When this proxy was last measured (RFC3339). Informational: tells you how old the tuned values are.
 	DeviceProfile     string `json:"device_profile"`     // Device profile last applied in the UI. Purely informational: it records which preset the settings came from, the proxy behaviour follows the individual fields.
 	// LastCalibration is the full report of the most recent measurement, kept
@@ -95,7 +99,497 @@ type ProxyConfig struct {
 	// "rtu-tcp" – Modbus RTU over TCP: client sends TCP frames, proxy strips
 	//             the MBAP header, appends CRC-16, forwards raw RTU frames to
 	//             the target, then wraps the RTU response back in a TCP frame.
+	// "serial"  – Modbus RTU on a local serial line (RS-485/RS-232) described
+	//             by Serial or shared through BusID; TargetAddr is not used.
+	// "virtual" – a simulated device described by Virtual, held in memory;
+	//             TargetAddr is not used.
 	Protocol string `json:"protocol"`
+	// Serial describes the local serial line when Protocol is "serial" and
+	// this proxy is its only user.
+	Serial *SerialConfig `json:"serial,omitempty"`
+	// BusID names an entry of Config.SerialBuses when Protocol is "serial"
+	// and the line is shared with other proxies. Set either this or Serial.
+	BusID string `json:"bus_id,omitempty"`
+	// Virtual describes the simulated device when Protocol is "virtual".
+	Virtual *VirtualConfig `json:"virtual,omitempty"`
+	// Targets are redundant targets for the same device — two gateways on
+	// one bus, a primary and a standby PLC — in order of preference. They
+	// take the place of TargetAddr, which stays empty; TargetPolicy says how
+	// requests are spread over them.
+	Targets      []TargetConfig `json:"targets,omitempty"`
+	TargetPolicy string         `json:"target_policy,omitempty"` // failover (default), round_robin, least_connections or weighted
+	// Routes send chosen unit IDs to targets of their own, so one listener
+	// can front many devices. Unit IDs no route claims go to TargetAddr; with
+	// routes, TargetAddr may be left empty.
+	Routes []RouteConfig `json:"routes,omitempty"`
+
+	// Rewrite translates unit IDs and register addresses between what the
+	// clients ask for and what the device understands.
+	Rewrite *RewriteConfig `json:"rewrite,omitempty"`
+
+	// Policy limits what clients may ask of the device: read-only mode,
+	// allowed function codes and register ranges, per client network.
+	Policy *PolicyConfig `json:"policy,omitempty"`
+
+	// RateLimit caps how fast clients may send requests and shares the
+	// target fairly among them when it is busy.
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+
+	// Points name registers of the devices behind the proxy and say how to
+	// decode them, so they can be read as values instead of raw registers.
+	Points []mapping.Mapping `json:"points,omitempty"`
+
+	// HealthProbe replaces the TCP connect check of the target with a real
+	// Modbus read, for devices that accept connections without answering.
+	HealthProbe *HealthProbeConfig `json:"health_probe,omitempty"`
+
+	// ListenTLS makes the proxy listen with Modbus/TCP Security (Modbus TCP
+	// inside TLS, port 802 by convention) instead of plain Modbus TCP.
+	ListenTLS *ListenTLSConfig `json:"listen_tls,omitempty"`
+
+	// TargetTLS makes the proxy talk to its target over TLS. Redundant
+	// targets share it; routes have their own.
+	TargetTLS *TargetTLSConfig `json:"target_tls,omitempty"`
+}
+
+// ListenTLSConfig is the certificate and client authentication of a
+// Modbus/TCP Security listener.
+type ListenTLSConfig struct {
+	CertFile     string `json:"cert_file"`                // Server certificate (PEM)
+	KeyFile      string `json:"key_file"`                 // Its private key (PEM)
+	ClientCAFile string `json:"client_ca_file,omitempty"` // CA that signs the client certificates (PEM)
+	ClientAuth   string `json:"client_auth,omitempty"`    // verify-cert (default), verify-ca, require, request or none
+	MinVersion   string `json:"min_version,omitempty"`    // 1.2 (default) or 1.3
+
+	// Authorization maps client certificates to what their holders may do.
+	// The first matching entry applies; a client no entry matches is
+	// refused every request. Empty = every verified client may do anything
+	// the policy allows.
+	Authorization []CertGrantConfig `json:"authorization,omitempty"`
+}
+
+// CertGrantConfig gives the holders of some client certificates a role. An
+// entry without subject and cert_role matches every verified certificate.
+type CertGrantConfig struct {
+	Subject        string `json:"subject,omitempty"`          // Certificate subject, the full DN or its common name (empty = any)
+	CertRole       string `json:"cert_role,omitempty"`        // Modbus role in the certificate (empty = any)
+	Role           string `json:"role"`                       // Permission role: admin, techniker, benutzer or auditor
+	AllowedUnitIDs []int  `json:"allowed_unit_ids,omitempty"` // Unit IDs the certificate may address (empty = all)
+	PolicyRuleConfig
+}
+
+// clone returns a deep copy of the listener settings.
+func (l *ListenTLSConfig) clone() *ListenTLSConfig {
+	if l == nil {
+		return nil
+	}
+	out := *l
+	if l.Authorization != nil {
+		out.Authorization = make([]CertGrantConfig, len(l.Authorization))
+		for i, g := range l.Authorization {
+			if g.AllowedUnitIDs != nil {
+				g.AllowedUnitIDs = append([]int(nil), g.AllowedUnitIDs...)
+			}
+			g.PolicyRuleConfig = g.PolicyRuleConfig.clone()
+			out.Authorization[i] = g
+		}
+	}
+	return &out
+}
+
+// TargetTLSConfig is how a proxy checks a TLS target and identifies itself
+// to it.
+type TargetTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`              // CA that signs the device's certificate (empty = system roots)
+	CertFile           string `json:"cert_file,omitempty"`            // Client certificate for devices that ask for one (PEM)
+	KeyFile            string `json:"key_file,omitempty"`             // Its private key (PEM)
+	ServerName         string `json:"server_name,omitempty"`          // Name in the device's certificate (empty = host of target_addr)
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Accept any device certificate
+}
+
+// clone returns a copy of the target settings.
+func (t *TargetTLSConfig) clone() *TargetTLSConfig {
+	if t == nil {
+		return nil
+	}
+	out := *t
+	return &out
+}
+
+// HealthProbeConfig describes the read that decides whether a proxy's target
+// is healthy. Unit ID and address are the device's, not the client's: the
+// probe goes to the target directly, past rewrite, cache and firewall. The
+// assertions apply to the first register read, as an unsigned 16-bit value.
+type HealthProbeConfig struct {
+	UnitID           int  `json:"unit_id"`                     // Unit ID the device is asked for (0-255)
+	FunctionCode     int  `json:"function_code,omitempty"`     // 3 = holding, 4 = input registers (0 = 3)
+	Address          int  `json:"address"`                     // First register read
+	Count            int  `json:"count,omitempty"`             // Registers read (0 = 1, at most 125)
+	Expect           *int `json:"expect,omitempty"`            // The first register must hold exactly this value
+	Min              *int `json:"min,omitempty"`               // ... at least this value
+	Max              *int `json:"max,omitempty"`               // ... at most this value
+	MaxLatencyMs     int  `json:"max_latency_ms,omitempty"`    // A slower answer counts as a failure (0 = any)
+	IntervalMs       int  `json:"interval_ms,omitempty"`       // Time between two probes (0 = 30000)
+	FailureThreshold int  `json:"failure_threshold,omitempty"` // Failed probes in a row before the target counts as unhealthy (0 = 1)
+}
+
+// clone returns a deep copy of the probe.
+func (h *HealthProbeConfig) clone() *HealthProbeConfig {
+	if h == nil {
+		return nil
+	}
+	out := *h
+	for _, v := range []**int{&out.Expect, &out.Min, &out.Max} {
+		if *v != nil {
+			n := **v
+			*v = &n
+		}
+	}
+	return &out
+}
+
+// PolicyConfig is the Modbus-level firewall of a proxy. The embedded rule
+// applies to every client no entry in Clients matches; the first matching
+// entry replaces it for its clients. Addresses and unit IDs are the ones the
+// client sends, before any rewrite.
+type PolicyConfig struct {
+	PolicyRuleConfig
+	Clients []ClientPolicyConfig `json:"clients,omitempty"`
+}
+
+// PolicyRuleConfig says what a client may do. An empty rule allows everything.
+type PolicyRuleConfig struct {
+	ReadOnly             bool                `json:"read_only,omitempty"`              // Refuse every write function code
+	AllowedFunctionCodes []int               `json:"allowed_function_codes,omitempty"` // Function codes clients may use (empty = all)
+	AllowedRanges        []PolicyRangeConfig `json:"allowed_ranges,omitempty"`         // Addresses clients may touch (empty = all)
+}
+
+// ClientPolicyConfig applies a rule to the clients from some networks.
+type ClientPolicyConfig struct {
+	Sources []string `json:"sources"` // Client IPs or CIDR networks
+	PolicyRuleConfig
+}
+
+// PolicyRangeConfig allows access to an address range of some units.
+type PolicyRangeConfig struct {
+	UnitIDs  []int `json:"unit_ids,omitempty"`  // Client unit IDs of the range (empty = all)
+	Start    int   `json:"start"`               // First address of the range
+	Count    int   `json:"count"`               // Number of addresses in the range
+	ReadOnly bool  `json:"read_only,omitempty"` // Reads only; writes to the range are refused
+}
+
+// clone returns a deep copy of the policy.
+func (p *PolicyConfig) clone() *PolicyConfig {
+	if p == nil {
+		return nil
+	}
+	out := &PolicyConfig{PolicyRuleConfig: p.PolicyRuleConfig.clone()}
+	if p.Clients != nil {
+		out.Clients = make([]ClientPolicyConfig, len(p.Clients))
+		for i, c := range p.Clients {
+			out.Clients[i] = ClientPolicyConfig{
+				Sources:          append([]string(nil), c.Sources...),
+				PolicyRuleConfig: c.PolicyRuleConfig.clone(),
+			}
+		}
+	}
+	return out
+}
+
+// clone returns a deep copy of the rule.
+func (r PolicyRuleConfig) clone() PolicyRuleConfig {
+	if r.AllowedFunctionCodes != nil {
+		r.AllowedFunctionCodes = append([]int(nil), r.AllowedFunctionCodes...)
+	}
+	if r.AllowedRanges != nil {
+		ranges := make([]PolicyRangeConfig, len(r.AllowedRanges))
+		for i, rg := range r.AllowedRanges {
+			if rg.UnitIDs != nil {
+				rg.UnitIDs = append([]int(nil), rg.UnitIDs...)
+			}
+			ranges[i] = rg
+		}
+		r.AllowedRanges = ranges
+	}
+	return r
+}
+
+// RateLimitConfig limits the request rate per client address and for the
+// proxy as a whole. A request over a limit is answered with Modbus exception
+// 0x06 (server device busy). While rate limits are configured, requests that
+// find every target connection in use also wait their turn by client and
+// priority class instead of in arrival order.
+type RateLimitConfig struct {
+	ClientRate  float64 `json:"client_rate,omitempty"`  // Requests per second per client address (0 = unlimited)
+	ClientBurst int     `json:"client_burst,omitempty"` // Requests a client may send in a row above the rate (0 = one second's worth)
+	ProxyRate   float64 `json:"proxy_rate,omitempty"`   // Requests per second of all clients together (0 = unlimited)
+	ProxyBurst  int     `json:"proxy_burst,omitempty"`  // As client_burst, for proxy_rate
+	// InternalPriority is the queue class of the proxy's own requests, such
+	// as point reads and MQTT: low (default), normal, high or critical.
+	InternalPriority string `json:"internal_priority,omitempty"`
+	// Classes give the clients from some networks a priority and limits of
+	// their own. The first class that matches a client applies; clients no
+	// class matches are of normal priority.
+	Classes []RateLimitClassConfig `json:"classes,omitempty"`
+}
+
+// RateLimitClassConfig is the priority and limits of some client networks.
+type RateLimitClassConfig struct {
+	Sources     []string `json:"sources"`                // Client IPs or CIDR networks
+	Priority    string   `json:"priority,omitempty"`     // low, normal (default), high or critical
+	ClientRate  *float64 `json:"client_rate,omitempty"`  // Replaces the proxy's client_rate for these clients (0 = unlimited)
+	ClientBurst int      `json:"client_burst,omitempty"` // Replaces the proxy's client_burst (0 = keep it)
+}
+
+// clone returns a deep copy of the rate limits.
+func (r *RateLimitConfig) clone() *RateLimitConfig {
+	if r == nil {
+		return nil
+	}
+	out := *r
+	if r.Classes != nil {
+		out.Classes = make([]RateLimitClassConfig, len(r.Classes))
+		for i, c := range r.Classes {
+			c.Sources = append([]string(nil), c.Sources...)
+			if c.ClientRate != nil {
+				rate := *c.ClientRate
+				c.ClientRate = &rate
+			}
+			out.Classes[i] = c
+		}
+	}
+	return &out
+}
+
+// clonePoints returns a deep copy of a proxy's data points.
+func clonePoints(points []mapping.Mapping) []mapping.Mapping {
+	if points == nil {
+		return nil
+	}
+	out := make([]mapping.Mapping, len(points))
+	for i, pt := range points {
+		out[i] = pt.Clone()
+	}
+	return out
+}
+
+// RewriteConfig holds the rewrite rules of a proxy. Every rule is written in
+// the client's terms: the unit IDs and addresses the client sends. Answers are
+// translated back, so the client never sees the device's numbering.
+type RewriteConfig struct {
+	Units     []UnitRewriteConfig     `json:"units,omitempty"`
+	Registers []RegisterRewriteConfig `json:"registers,omitempty"`
+}
+
+// UnitRewriteConfig maps one client unit ID to the device's.
+type UnitRewriteConfig struct {
+	From int `json:"from"` // Unit ID the client sends (0-255)
+	To   int `json:"to"`   // Unit ID the device is asked for (0-255)
+}
+
+// RegisterRewriteConfig moves a range of client addresses. A request must lie
+// entirely inside the range or entirely outside it; one that straddles its
+// edge is refused with "illegal data address".
+type RegisterRewriteConfig struct {
+	FunctionCodes []int `json:"function_codes,omitempty"` // Function codes the rule applies to (empty = every addressed one)
+	UnitIDs       []int `json:"unit_ids,omitempty"`       // Client unit IDs the rule applies to (empty = all)
+	Start         int   `json:"start"`                    // First client address of the range
+	Count         int   `json:"count"`                    // Number of addresses in the range
+	Offset        int   `json:"offset,omitempty"`         // Added to every address in the range
+	TargetStart   *int  `json:"target_start,omitempty"`   // Device address of start, instead of offset
+}
+
+// AddressOffset returns the shift the rule applies to an address.
+func (r RegisterRewriteConfig) AddressOffset() int {
+	if r.TargetStart != nil {
+		return *r.TargetStart - r.Start
+	}
+	return r.Offset
+}
+
+// clone returns a deep copy of the rewrite rules.
+func (r *RewriteConfig) clone() *RewriteConfig {
+	if r == nil {
+		return nil
+	}
+	out := &RewriteConfig{}
+	if r.Units != nil {
+		out.Units = make([]UnitRewriteConfig, len(r.Units))
+		copy(out.Units, r.Units)
+	}
+	if r.Registers != nil {
+		out.Registers = make([]RegisterRewriteConfig, len(r.Registers))
+		for i, rule := range r.Registers {
+			if rule.FunctionCodes != nil {
+				rule.FunctionCodes = append([]int(nil), rule.FunctionCodes...)
+			}
+			if rule.UnitIDs != nil {
+				rule.UnitIDs = append([]int(nil), rule.UnitIDs...)
+			}
+			if rule.TargetStart != nil {
+				start := *rule.TargetStart
+				rule.TargetStart = &start
+			}
+			out.Registers[i] = rule
+		}
+	}
+	return out
+}
+
+// TargetConfig is one of several targets of a proxy.
+type TargetConfig struct {
+	TargetAddr string `json:"target_addr"`
+	Weight     int    `json:"weight,omitempty"` // Share of the requests under the weighted policy (0 = 1)
+}
+
+// ProxyConfig returns the settings of the proxy that serves this target:
+// everything of the owning proxy that concerns the way to a device, for this
+// target's address. The cache stays with the owner, which serves it for all
+// targets alike.
+func (t TargetConfig) ProxyConfig(owner ProxyConfig) ProxyConfig {
+	return ProxyConfig{
+		ID:                owner.ID,
+		Name:              t.TargetAddr,
+		TargetAddr:        t.TargetAddr,
+		ConnectionTimeout: owner.ConnectionTimeout,
+		ReadTimeout:       owner.ReadTimeout,
+		MaxRetries:        owner.MaxRetries,
+		MaxReadSize:       owner.MaxReadSize,
+		ConnectDelayMs:    owner.ConnectDelayMs,
+		MaxTargetConns:    owner.MaxTargetConns,
+		MinRequestGapMs:   owner.MinRequestGapMs,
+		RequestTimeoutMs:  owner.RequestTimeoutMs,
+		Protocol:          owner.Protocol,
+		HealthProbe:       owner.HealthProbe,
+		TargetTLS:         owner.TargetTLS,
+	}
+}
+
+// RouteConfig sends a range of unit IDs to a target of its own. The target
+// fields mean the same as on ProxyConfig; timeouts and retries follow the
+// proxy the route belongs to.
+type RouteConfig struct {
+	ID           string `json:"id"`
+	UnitIDFirst  int    `json:"unit_id_first"`  // First client unit ID of the route (0-255)
+	UnitIDLast   int    `json:"unit_id_last"`   // Last client unit ID of the route (0 = same as unit_id_first)
+	TargetUnitID int    `json:"target_unit_id"` // Unit ID sent to the target for unit_id_first, the rest follow in order (0 = keep the client's)
+
+	TargetAddr       string         `json:"target_addr"`
+	Protocol         string         `json:"protocol"`
+	Serial           *SerialConfig  `json:"serial,omitempty"`
+	BusID            string         `json:"bus_id,omitempty"`
+	Virtual          *VirtualConfig `json:"virtual,omitempty"`
+	MaxReadSize      int            `json:"max_read_size"`
+	ConnectDelayMs   int            `json:"connect_delay_ms"`
+	MaxTargetConns   int            `json:"max_target_conns"`
+	MinRequestGapMs  int            `json:"min_request_gap_ms"`
+	RequestTimeoutMs int            `json:"request_timeout_ms"`
+	CacheEnabled     bool           `json:"cache_enabled"`
+	CacheTTLMs       int            `json:"cache_ttl_ms"`
+	PollIntervalMs   int            `json:"poll_interval_ms"`
+	// TargetTLS makes the route talk to its target over TLS.
+	TargetTLS *TargetTLSConfig `json:"target_tls,omitempty"`
+}
+
+// ProxyConfig returns the settings of the proxy that serves this route: the
+// route's target and tuning, the owning proxy's identity and timeouts.
+func (r RouteConfig) ProxyConfig(owner ProxyConfig) ProxyConfig {
+	return ProxyConfig{
+		ID:                owner.ID,
+		Name:              r.ID,
+		TargetAddr:        r.TargetAddr,
+		ConnectionTimeout: owner.ConnectionTimeout,
+		ReadTimeout:       owner.ReadTimeout,
+		MaxRetries:        owner.MaxRetries,
+		MaxReadSize:       r.MaxReadSize,
+		ConnectDelayMs:    r.ConnectDelayMs,
+		MaxTargetConns:    r.MaxTargetConns,
+		MinRequestGapMs:   r.MinRequestGapMs,
+		RequestTimeoutMs:  r.RequestTimeoutMs,
+		CacheEnabled:      r.CacheEnabled,
+		CacheTTLMs:        r.CacheTTLMs,
+		PollIntervalMs:    r.PollIntervalMs,
+		Protocol:          r.Protocol,
+		Serial:            r.Serial,
+		BusID:             r.BusID,
+		Virtual:           r.Virtual,
+		TargetTLS:         r.TargetTLS,
+	}
+}
+
+// SerialConfig holds the line settings of a locally attached Modbus RTU bus.
+type SerialConfig struct {
+	Device            string `json:"device"`               // Serial device, e.g. /dev/ttyUSB0
+	BaudRate          int    `json:"baud_rate"`            // Line speed (0 = 9600)
+	DataBits          int    `json:"data_bits"`            // 5-8 (0 = 8)
+	Parity            string `json:"parity"`               // None, Odd, Even, Mark, Space (empty = None)
+	StopBits          int    `json:"stop_bits"`            // 1 or 2 (0 = 1)
+	InterFrameDelayMs int    `json:"inter_frame_delay_ms"` // Silence between two frames (ms, 0 = t3.5 derived from the baud rate)
+}
+
+// SerialBusConfig describes a serial line shared by several proxies, each
+// addressing its own unit IDs on it. The line settings are the same as for a
+// proxy's own line; the unit settings keep one dead slave from holding up the
+// rest of the bus.
+type SerialBusConfig struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	SerialConfig
+	UnitTimeoutMs        int `json:"unit_timeout_ms"`        // Longest wait for one unit's answer (ms, 0 = 1000)
+	UnitFailureThreshold int `json:"unit_failure_threshold"` // Consecutive failures before a unit is skipped (0 = 3)
+	UnitBackoffMs        int `json:"unit_backoff_ms"`        // How long a failing unit is skipped before it is tried again (ms, 0 = 30000)
+}
+
+// VirtualConfig describes a simulated Modbus device: a register bank held
+// in memory, answering any unit ID, with the quirks of a real device if
+// wanted. Addresses no register covers answer "illegal data address". Writes
+// to holding registers and coils stay until the proxy is built anew — on a
+// change of its settings or a restart of ModBridge.
+type VirtualConfig struct {
+	Registers []VirtualRegisterConfig `json:"registers"`
+	// ReplayFile is a capture (pcapng or JSON Lines, as downloaded from the
+	// captures) whose reads the device replays at the recorded pace.
+	// Registers override the addresses it holds.
+	ReplayFile    string  `json:"replay_file,omitempty"`
+	SingleSession bool    `json:"single_session"` // Answer one connection only, like many inverters; the others get no answers
+	MinGapMs      int     `json:"min_gap_ms"`     // Leave requests unanswered that come sooner than this after the last answer (ms, 0 = none)
+	DropRate      float64 `json:"drop_rate"`      // Share of requests left unanswered (0-1)
+	ExceptionRate float64 `json:"exception_rate"` // Share of requests answered with exception_code (0-1)
+	ExceptionCode int     `json:"exception_code"` // Exception of exception_rate (0 = 6, server device busy)
+}
+
+// VirtualRegisterConfig describes a register of a simulated device, or a
+// block of them that behave alike. Values are 16 bits, from -32768 to 65535;
+// negative ones are sent in two's complement. A coil is on when its value is
+// not zero.
+type VirtualRegisterConfig struct {
+	Table      string `json:"table"` // holding (default), input, coil or discrete
+	Address    int    `json:"address"`
+	Count      int    `json:"count"` // Consecutive addresses that behave alike (0 = 1)
+	Type       string `json:"type"`  // static (default), sine, ramp, random_walk or replay
+	Value      int    `json:"value"` // static: the value; random_walk: where it starts
+	Min        int    `json:"min"`   // sine, ramp and random_walk: the range
+	Max        int    `json:"max"`
+	PeriodMs   int    `json:"period_ms"`   // sine and ramp: one cycle (ms, 0 = 60000)
+	Step       int    `json:"step"`        // random_walk: largest change per interval (0 = 1)
+	IntervalMs int    `json:"interval_ms"` // random_walk and replay: how long a value holds (ms, 0 = 1000)
+	Values     []int  `json:"values,omitempty"`
+	Exception  int    `json:"exception"` // Answer every access with this exception code (0 = none)
+}
+
+func (v *VirtualConfig) clone() *VirtualConfig {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	if v.Registers != nil {
+		out.Registers = make([]VirtualRegisterConfig, len(v.Registers))
+		for i, r := range v.Registers {
+			r.Values = append([]int(nil), r.Values...)
+			out.Registers[i] = r
+		}
+	}
+	return &out
 }
 
 // Config holds the global configuration.
@@ -106,6 +600,17 @@ type Config struct {
 	MultiUser           bool          `json:"multi_user"`            // Enable DB-backed multi-user authentication
 	Proxies             []ProxyConfig `json:"proxies"`
 
+	SerialBuses []SerialBusConfig `json:"serial_buses,omitempty"`
+
+	// MQTT publishes the data points of every proxy to a broker.
+	MQTT *MQTTConfig `json:"mqtt,omitempty"`
+
+	// History records the data points of every proxy in the database.
+	History *HistoryConfig `json:"history,omitempty"`
+
+	// Cluster runs this node as one of several, of which one runs the proxies.
+	Cluster *ClusterConfig `json:"cluster,omitempty"`
+
 	LogLevel      string `json:"log_level"`
 	LogMaxSize    int    `json:"log_max_size"`
 	LogMaxFiles   int    `json:"log_max_files"`
@@ -138,6 +643,11 @@ type Config struct {
 	EmailPassword       string `json:"email_password"`
 	EmailAlertOnError   bool   `json:"email_alert_on_error"`
 	EmailAlertOnWarning bool   `json:"email_alert_on_warning"`
+	// EmailSecurity protects the connection to the SMTP server: starttls
+	// (default), tls or none. EmailThrottleMinutes is the least time between
+	// two mails about the same thing (0 = 15).
+	EmailSecurity        string `json:"email_security,omitempty"`
+	EmailThrottleMinutes int    `json:"email_throttle_minutes,omitempty"`
 
 	BackupEnabled   bool   `json:"backup_enabled"`
 	BackupInterval  string `json:"backup_interval"`
@@ -145,6 +655,11 @@ type Config struct {
 	BackupPath      string `json:"backup_path"`
 	BackupDatabase  bool   `json:"backup_database"`
 	BackupConfig    bool   `json:"backup_config"`
+	// BackupEncrypt encrypts backups with BackupPassphrase. The passphrase
+	// is never sent out by the API; keep it somewhere else as well, a
+	// backup cannot be restored without it.
+	BackupEncrypt    bool   `json:"backup_encrypt"`
+	BackupPassphrase string `json:"backup_passphrase,omitempty"`
 
 	MetricsEnabled bool   `json:"metrics_enabled"`
 	MetricsPort    string `json:"metrics_port"`
@@ -153,12 +668,122 @@ type Config struct {
 	MaxConnections int  `json:"max_connections"`
 }
 
+// MQTTConfig describes the broker the data points are published to. Values
+// are published when the background poller has refreshed them and, with
+// PublishIntervalMs, again on a fixed schedule.
+type MQTTConfig struct {
+	Enabled           bool   `json:"enabled"`
+	Broker            string `json:"broker"`                        // host:port, or a tcp://, mqtt://, ssl:// or mqtts:// URL
+	ProtocolVersion   int    `json:"protocol_version,omitempty"`    // 4 = MQTT 3.1.1 (default), 5 = MQTT 5
+	ClientID          string `json:"client_id,omitempty"`           // Default: modbridge-<hostname>
+	Username          string `json:"username,omitempty"`            // Optional broker login
+	Password          string `json:"password,omitempty"`            // Never returned by the API
+	TLSInsecure       bool   `json:"tls_insecure,omitempty"`        // Accept any broker certificate (self-signed brokers on the LAN)
+	TopicPrefix       string `json:"topic_prefix,omitempty"`        // First topic level (default: modbridge)
+	QoS               int    `json:"qos"`                           // 0, 1 or 2
+	Retain            bool   `json:"retain"`                        // Publish values as retained messages
+	KeepAliveMs       int    `json:"keep_alive_ms,omitempty"`       // MQTT keep-alive (ms, 0 = 60000)
+	PublishIntervalMs int    `json:"publish_interval_ms,omitempty"` // Publish every point at this interval even if unchanged (ms, 0 = on change only)
+	Discovery         string `json:"discovery,omitempty"`           // "homeassistant" publishes discovery configs (empty = none)
+	DiscoveryPrefix   string `json:"discovery_prefix,omitempty"`    // Discovery topic prefix (default: homeassistant)
+}
+
+// HistoryConfig describes how long the history of the data points is kept.
+// Every reading is kept for RawRetentionHours; one-minute and one-hour
+// summaries of them much longer, for charts over weeks and months.
+type HistoryConfig struct {
+	Enabled             bool `json:"enabled"`
+	RawRetentionHours   int  `json:"raw_retention_hours,omitempty"`   // Every reading (0 = 48)
+	MinuteRetentionDays int  `json:"minute_retention_days,omitempty"` // One-minute summaries (0 = 30)
+	HourRetentionDays   int  `json:"hour_retention_days,omitempty"`   // One-hour summaries (0 = 365)
+	FlushIntervalMs     int  `json:"flush_interval_ms,omitempty"`     // How often readings are written to the database (ms, 0 = 60000)
+}
+
+// ClusterConfig makes this node part of an active/standby cluster. The
+// nodes exchange heartbeats on their web port, and only the active one runs
+// the proxies; the others take over when it is gone. Each node lists all the
+// others as peers. A cluster of two needs a tiebreaker to survive the loss
+// of a node: without one, neither can tell a dead peer from a broken link.
+type ClusterConfig struct {
+	Enabled     bool     `json:"enabled"`
+	NodeID      string   `json:"node_id,omitempty"`      // Unique per node (default: hostname); the smallest goes first
+	Address     string   `json:"address"`                // host:port or URL the other nodes reach this one's web port at
+	Peers       []string `json:"peers"`                  // The other nodes, host:port or URL
+	Secret      string   `json:"secret,omitempty"`       // Shared by all nodes, signs their heartbeats; never returned by the API
+	Tiebreaker  string   `json:"tiebreaker,omitempty"`   // host:port both nodes of a cluster of two check, such as the router; required for two nodes
+	HeartbeatMs int      `json:"heartbeat_ms,omitempty"` // Between two heartbeats (ms, 0 = 1000)
+	TimeoutMs   int      `json:"timeout_ms,omitempty"`   // A node not heard from for this long is gone (ms, 0 = 5000)
+}
+
+// Heartbeat returns the time between two heartbeats.
+func (c *ClusterConfig) Heartbeat() time.Duration {
+	if c.HeartbeatMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(c.HeartbeatMs) * time.Millisecond
+}
+
+// Timeout returns how long a node may be silent before it counts as gone.
+func (c *ClusterConfig) Timeout() time.Duration {
+	if c.TimeoutMs <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.TimeoutMs) * time.Millisecond
+}
+
+// Shared returns the part of the configuration that the nodes of a cluster
+// have in common: all but the node's place in the cluster and its web
+// server. It shares slices and pointers with c.
+func (c Config) Shared() Config {
+	c.Cluster = nil
+	c.WebPort = ""
+	c.TLSEnabled, c.TLSCertFile, c.TLSKeyFile = false, "", ""
+	return c
+}
+
+// WithShared returns shared, the part of a configuration the nodes of a
+// cluster have in common, completed with the settings of c's own node.
+func (c Config) WithShared(shared Config) Config {
+	shared.Cluster = c.Cluster
+	shared.WebPort = c.WebPort
+	shared.TLSEnabled, shared.TLSCertFile, shared.TLSKeyFile = c.TLSEnabled, c.TLSCertFile, c.TLSKeyFile
+	return shared
+}
+
+// BrokerAddr returns the host:port to dial and whether the broker speaks TLS.
+// A broker without a port gets the standard one: 1883, or 8883 with TLS.
+func (c *MQTTConfig) BrokerAddr() (string, bool, error) {
+	addr, useTLS := c.Broker, false
+	if i := strings.Index(addr, "://"); i >= 0 {
+		switch strings.ToLower(addr[:i]) {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS = true
+		default:
+			return "", false, fmt.Errorf("unsupported scheme %q", addr[:i])
+		}
+		addr = strings.TrimSuffix(addr[i+3:], "/")
+	}
+	if addr == "" {
+		return "", false, fmt.Errorf("broker address is empty")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+	return addr, useTLS, nil
+}
+
 // Manager handles config persistence.
 type Manager struct {
 	mu       sync.RWMutex
 	path     string
 	cfg      Config
 	previous *Config // snapshot before the last Update call, enables Rollback
+	onUpdate func()  // called after a saved Update or Rollback
 }
 
 // NewManager creates a config manager.
@@ -284,8 +909,53 @@ func (m *Manager) deepCopyConfig(c Config) Config {
 				result.Proxies[i].Tags = make(FlexibleTags, len(c.Proxies[i].Tags))
 				copy(result.Proxies[i].Tags, c.Proxies[i].Tags)
 			}
+			if c.Proxies[i].Serial != nil {
+				serial := *c.Proxies[i].Serial
+				result.Proxies[i].Serial = &serial
+			}
+			result.Proxies[i].Virtual = c.Proxies[i].Virtual.clone()
+			if c.Proxies[i].Routes != nil {
+				routes := make([]RouteConfig, len(c.Proxies[i].Routes))
+				for j, r := range c.Proxies[i].Routes {
+					if r.Serial != nil {
+						serial := *r.Serial
+						r.Serial = &serial
+					}
+					r.Virtual = r.Virtual.clone()
+					r.TargetTLS = r.TargetTLS.clone()
+					routes[j] = r
+				}
+				result.Proxies[i].Routes = routes
+			}
+			if c.Proxies[i].Targets != nil {
+				result.Proxies[i].Targets = append([]TargetConfig(nil), c.Proxies[i].Targets...)
+			}
+			result.Proxies[i].Rewrite = c.Proxies[i].Rewrite.clone()
+			result.Proxies[i].Policy = c.Proxies[i].Policy.clone()
+			result.Proxies[i].RateLimit = c.Proxies[i].RateLimit.clone()
+			result.Proxies[i].Points = clonePoints(c.Proxies[i].Points)
+			result.Proxies[i].HealthProbe = c.Proxies[i].HealthProbe.clone()
+			result.Proxies[i].ListenTLS = c.Proxies[i].ListenTLS.clone()
+			result.Proxies[i].TargetTLS = c.Proxies[i].TargetTLS.clone()
 		}
 	}
+	if c.SerialBuses != nil {
+		result.SerialBuses = make([]SerialBusConfig, len(c.SerialBuses))
+		copy(result.SerialBuses, c.SerialBuses)
+	}
+	if c.MQTT != nil {
+		mqtt := *c.MQTT
+		result.MQTT = &mqtt
+	}
+	if c.History != nil {
+		history := *c.History
+		result.History = &history
+	}
+	if c.Cluster != nil {
+		cluster := *c.Cluster
+		cluster.Peers = append([]string(nil), c.Cluster.Peers...)
+		result.Cluster = &cluster
+	}
 	if c.CORSAllowedOrigins != nil {
 		result.CORSAllowedOrigins = make([]string, len(c.CORSAllowedOrigins))
 		copy(result.CORSAllowedOrigins, c.CORSAllowedOrigins)
@@ -310,7 +980,16 @@ func (m *Manager) deepCopyConfig(c Config) Config {
 	return result
 }
 
+// Update changes the configuration with fn and saves it.
 func (m *Manager) Update(fn func(*Config) error) error {
+	if err := m.update(fn); err != nil {
+		return err
+	}
+	m.updated()
+	return nil
+}
+
+func (m *Manager) update(fn func(*Config) error) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -340,6 +1019,35 @@ func (m *Manager) Update(fn func(*Config) error) error {
 	return enc.Encode(m.cfg)
 }
 
+// Replace stores a configuration that was changed elsewhere, such as on
+// another node of a cluster, in place of this one. Unlike Update it does
+// not call the update hook. The configuration it replaced can be rolled
+// back to.
+func (m *Manager) Replace(cfg Config) error {
+	return m.update(func(c *Config) error {
+		*c = m.deepCopyConfig(cfg)
+		return nil
+	})
+}
+
+// SetOnUpdate has fn called after every Update and Rollback, once the
+// change is saved.
+func (m *Manager) SetOnUpdate(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUpdate = fn
+}
+
+// updated calls the update hook, if one is set.
+func (m *Manager) updated() {
+	m.mu.RLock()
+	fn := m.onUpdate
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
 // CanRollback reports whether a previous config snapshot is available.
 func (m *Manager) CanRollback() bool {
 	m.mu.RLock()
@@ -350,6 +1058,14 @@ func (m *Manager) CanRollback() bool {
 // Rollback restores the configuration to the state before the last Update call.
 // Returns an error if no previous snapshot exists or the save fails.
 func (m *Manager) Rollback() error {
+	if err := m.rollback(); err != nil {
+		return err
+	}
+	m.updated()
+	return nil
+}
+
+func (m *Manager) rollback() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -144,3 +144,38 @@ func TestConfigDeepCopy(t *testing.T) {
 		t.Errorf("Deep copy failed, original was modified")
 	}
 }
+
+func TestUpdateHookAndReplace(t *testing.T) {
+	testFile := "test_replace_temp.json"
+	defer os.Remove(testFile)
+
+	mgr := NewManager(testFile)
+	calls := 0
+	mgr.SetOnUpdate(func() { calls++ })
+
+	if err := mgr.Update(func(c *Config) error { c.LogLevel = "DEBUG"; return nil }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := mgr.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("hook called %d times for an Update and a Rollback, want 2", calls)
+	}
+
+	// A configuration from another node keeps this node's own settings.
+	other := mgr.Get()
+	other.WebPort = ":9999"
+	other.Cluster = &ClusterConfig{Enabled: true, NodeID: "b"}
+	other.LogLevel = "WARN"
+	if err := mgr.Replace(mgr.Get().WithShared(other.Shared())); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if calls != 2 {
+		t.Fatal("Replace called the update hook")
+	}
+	cfg := mgr.Get()
+	if cfg.LogLevel != "WARN" || cfg.WebPort != ":8080" || cfg.Cluster != nil {
+		t.Fatalf("after Replace: log level %q, web port %q, cluster %+v", cfg.LogLevel, cfg.WebPort, cfg.Cluster)
+	}
+}
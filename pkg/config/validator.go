@@ -8,6 +8,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"modbridge/pkg/rbac"
 	"net"
 	"net/url"
 	"os"
@@ -83,6 +84,9 @@ func (v *Validator) Validate(cfg *Config) error {
 	// Check for duplicate listen addresses across proxies
 	v.validateDuplicateListenAddrs(cfg.Proxies)
 
+	// Validate shared serial buses and who uses which serial device
+	v.validateSerialBuses(cfg)
+
 	// Validate TLS configuration
 	if cfg.TLSEnabled {
 		v.validateTLSConfig(cfg)
@@ -106,10 +110,26 @@ func (v *Validator) Validate(cfg *Config) error {
 	if cfg.BackupEnabled {
 		v.validateBackupConfig(cfg)
 	}
+	v.validateBackupEncryption(cfg)
 
 	// Validate metrics configuration
 	v.validateMetricsConfig(cfg)
 
+	// Validate the MQTT publisher
+	if cfg.MQTT != nil && cfg.MQTT.Enabled {
+		v.validateMQTTConfig(cfg.MQTT)
+	}
+
+	// Validate the data point history
+	if cfg.History != nil && cfg.History.Enabled {
+		v.validateHistoryConfig(cfg.History)
+	}
+
+	// Validate the cluster
+	if cfg.Cluster != nil && cfg.Cluster.Enabled {
+		v.validateClusterConfig(cfg.Cluster)
+	}
+
 	if len(v.errors) > 0 {
 		return v.errors
 	}
@@ -205,22 +225,29 @@ func (v *Validator) validateProxyConfig(cfg *ProxyConfig, index int) {
 		}
 	}
 
-	// Validate target address
-	if cfg.TargetAddr == "" {
-		v.AddError(prefix+".target_addr", "cannot be empty", cfg.TargetAddr)
-	} else {
-		host, port, err := v.ParseHostPort(cfg.TargetAddr)
-		if err != nil {
-			v.AddError(prefix+".target_addr", "invalid format (expected host:port)", cfg.TargetAddr)
-		} else {
-			if !v.IsValidIP(host) && !v.IsValidHostname(host) {
-				v.AddError(prefix+".target_addr", "invalid host address or hostname", cfg.TargetAddr)
-			}
-			if port < 1 || port > 65535 {
-				v.AddError(prefix+".target_addr", "port must be between 1 and 65535", cfg.TargetAddr)
-			}
-		}
+	// Validate protocol and target. A gateway whose routes cover every unit
+	// it serves may leave its own target empty, and a proxy with several
+	// targets names them in targets instead.
+	if len(cfg.Targets) > 0 || cfg.TargetPolicy != "" {
+		v.validateTargets(prefix, cfg)
+	} else if len(cfg.Routes) == 0 || cfg.TargetAddr != "" || cfg.Protocol == "serial" || cfg.Protocol == "virtual" {
+		v.validateTarget(prefix, cfg)
+	}
+	v.validateRoutes(prefix, cfg)
+	if cfg.Rewrite != nil {
+		v.validateRewrite(prefix+".rewrite", cfg.Rewrite)
+	}
+	if cfg.Policy != nil {
+		v.validatePolicy(prefix+".policy", cfg.Policy)
 	}
+	if cfg.RateLimit != nil {
+		v.validateRateLimit(prefix+".rate_limit", cfg.RateLimit)
+	}
+	v.validatePoints(prefix+".points", cfg)
+	if cfg.HealthProbe != nil {
+		v.validateHealthProbe(prefix+".health_probe", cfg.HealthProbe)
+	}
+	v.validateProxyTLS(prefix, cfg)
 
 	// Check for port conflicts (listen and target cannot be the same)
 	if cfg.ListenAddr != "" && cfg.TargetAddr != "" && cfg.ListenAddr == cfg.TargetAddr {
@@ -247,6 +274,84 @@ func (v *Validator) validateProxyConfig(cfg *ProxyConfig, index int) {
 		v.AddError(prefix+".max_retries", "must not exceed 10", strconv.Itoa(cfg.MaxRetries))
 	}
 
+	v.validateTuning(prefix, cfg)
+
+	// Validate device profile id. The backend does not know the profile list —
+	// it only stores which one the UI applied — so this guards length and
+	// charset, nothing more.
+	if len(cfg.DeviceProfile) > 64 {
+		v.AddError(prefix+".device_profile", "must not exceed 64 characters", cfg.DeviceProfile)
+	} else if cfg.DeviceProfile != "" && !idRegex.MatchString(cfg.DeviceProfile) {
+		v.AddError(prefix+".device_profile", "must contain only alphanumeric characters, hyphens, and underscores", cfg.DeviceProfile)
+	}
+
+	// Validate description length
+	if len(cfg.Description) > 500 {
+		v.AddError(prefix+".description", "must not exceed 500 characters", strconv.Itoa(len(cfg.Description)))
+	}
+
+	// Validate tags
+	for i, tag := range cfg.Tags {
+		if len(tag) > 50 {
+			v.AddError(fmt.Sprintf("%s.tags[%d]", prefix, i), "must not exceed 50 characters", tag)
+		}
+		if !v.IsValidTag(tag) {
+			v.AddError(fmt.Sprintf("%s.tags[%d]", prefix, i), "must contain only alphanumeric characters, hyphens, and underscores", tag)
+		}
+	}
+}
+
+// validateTarget validates how a proxy or route reaches its target.
+func (v *Validator) validateTarget(prefix string, cfg *ProxyConfig) {
+	// Validate protocol. Empty means the default, Modbus TCP.
+	switch cfg.Protocol {
+	case "", "tcp", "rtu-tcp", "serial", "virtual":
+	default:
+		v.AddError(prefix+".protocol", "must be one of: tcp, rtu-tcp, serial, virtual", cfg.Protocol)
+	}
+
+	// Validate target address. A serial target has no network address: the
+	// device on the line is addressed by unit ID alone. Nor has a simulated
+	// device, which lives in memory.
+	if cfg.Protocol == "virtual" {
+		if cfg.Virtual == nil {
+			v.AddError(prefix+".virtual", "is required when protocol is virtual", "")
+		} else {
+			v.validateVirtualConfig(prefix+".virtual", cfg.Virtual)
+		}
+	} else if cfg.Protocol == "serial" {
+		switch {
+		case cfg.Serial != nil && cfg.BusID != "":
+			v.AddError(prefix+".bus_id", "cannot be combined with serial: a proxy either owns its line or shares a bus", cfg.BusID)
+		case cfg.BusID != "":
+			if !v.IsValidID(cfg.BusID) {
+				v.AddError(prefix+".bus_id", "must contain only alphanumeric characters, hyphens, and underscores", cfg.BusID)
+			}
+		case cfg.Serial != nil:
+			v.validateSerialConfig(prefix+".serial", cfg.Serial)
+		default:
+			v.AddError(prefix+".serial", "is required when protocol is serial (or set bus_id)", "")
+		}
+	} else if cfg.TargetAddr == "" {
+		v.AddError(prefix+".target_addr", "cannot be empty", cfg.TargetAddr)
+	} else {
+		host, port, err := v.ParseHostPort(cfg.TargetAddr)
+		if err != nil {
+			v.AddError(prefix+".target_addr", "invalid format (expected host:port)", cfg.TargetAddr)
+		} else {
+			if !v.IsValidIP(host) && !v.IsValidHostname(host) {
+				v.AddError(prefix+".target_addr", "invalid host address or hostname", cfg.TargetAddr)
+			}
+			if port < 1 || port > 65535 {
+				v.AddError(prefix+".target_addr", "port must be between 1 and 65535", cfg.TargetAddr)
+			}
+		}
+	}
+}
+
+// validateTuning validates the settings that shape the traffic towards a
+// target. Proxies and routes share them.
+func (v *Validator) validateTuning(prefix string, cfg *ProxyConfig) {
 	// Validate max read size
 	if cfg.MaxReadSize < 0 {
 		v.AddError(prefix+".max_read_size", "must be non-negative", strconv.Itoa(cfg.MaxReadSize))
@@ -299,28 +404,581 @@ func (v *Validator) validateProxyConfig(cfg *ProxyConfig, index int) {
 	} else if cfg.PollIntervalMs > 0 && !cfg.CacheEnabled {
 		v.AddError(prefix+".poll_interval_ms", "requires cache_enabled: background polling only fills the cache", strconv.Itoa(cfg.PollIntervalMs))
 	}
+}
 
-	// Validate device profile id. The backend does not know the profile list —
-	// it only stores which one the UI applied — so this guards length and
-	// charset, nothing more.
-	if len(cfg.DeviceProfile) > 64 {
-		v.AddError(prefix+".device_profile", "must not exceed 64 characters", cfg.DeviceProfile)
-	} else if cfg.DeviceProfile != "" && !idRegex.MatchString(cfg.DeviceProfile) {
-		v.AddError(prefix+".device_profile", "must contain only alphanumeric characters, hyphens, and underscores", cfg.DeviceProfile)
+// validateTargets validates the targets of a proxy that has several.
+func (v *Validator) validateTargets(prefix string, cfg *ProxyConfig) {
+	switch cfg.TargetPolicy {
+	case "", "failover", "round_robin", "least_connections", "weighted", "ip_hash":
+	default:
+		v.AddError(prefix+".target_policy", "must be one of: failover, round_robin, least_connections, weighted, ip_hash", cfg.TargetPolicy)
+	}
+	if len(cfg.Targets) == 0 {
+		v.AddError(prefix+".targets", "must list at least one target when target_policy is set", "")
+		return
+	}
+	if cfg.TargetAddr != "" {
+		v.AddError(prefix+".target_addr", "must be empty when targets are listed", cfg.TargetAddr)
+	}
+	if cfg.Protocol == "serial" {
+		v.AddError(prefix+".protocol", "serial cannot be combined with targets: a serial line is one target", cfg.Protocol)
+		return
+	}
+	if cfg.Protocol == "virtual" {
+		v.AddError(prefix+".protocol", "virtual cannot be combined with targets: a simulated device is one target", cfg.Protocol)
+		return
 	}
 
-	// Validate description length
-	if len(cfg.Description) > 500 {
-		v.AddError(prefix+".description", "must not exceed 500 characters", strconv.Itoa(len(cfg.Description)))
+	seen := make(map[string]bool)
+	for i, t := range cfg.Targets {
+		tp := fmt.Sprintf("%s.targets[%d]", prefix, i)
+		if seen[t.TargetAddr] {
+			v.AddError(tp+".target_addr", "duplicate target", t.TargetAddr)
+		}
+		seen[t.TargetAddr] = true
+		if t.Weight < 0 || t.Weight > 100 {
+			v.AddError(tp+".weight", "must be between 0 and 100", strconv.Itoa(t.Weight))
+		}
+		targetCfg := t.ProxyConfig(*cfg)
+		v.validateTarget(tp, &targetCfg)
+		if cfg.ListenAddr != "" && t.TargetAddr == cfg.ListenAddr {
+			v.AddError(tp+".target_addr", "cannot be the same as listen_addr", t.TargetAddr)
+		}
 	}
+}
 
-	// Validate tags
-	for i, tag := range cfg.Tags {
-		if len(tag) > 50 {
-			v.AddError(fmt.Sprintf("%s.tags[%d]", prefix, i), "must not exceed 50 characters", tag)
+// validateRoutes validates the unit-ID routes of a proxy. Ranges must not
+// overlap: a unit ID with two routes would go wherever the first one points,
+// and the second would silently never be used.
+func (v *Validator) validateRoutes(prefix string, cfg *ProxyConfig) {
+	seen := make(map[string]bool)
+	var owner [256]string
+
+	for i, r := range cfg.Routes {
+		rp := fmt.Sprintf("%s.routes[%d]", prefix, i)
+
+		if r.ID == "" {
+			v.AddError(rp+".id", "cannot be empty", r.ID)
+		} else if !v.IsValidID(r.ID) {
+			v.AddError(rp+".id", "must contain only alphanumeric characters, hyphens, and underscores", r.ID)
+		} else if seen[r.ID] {
+			v.AddError(rp+".id", "duplicate route id", r.ID)
 		}
-		if !v.IsValidTag(tag) {
-			v.AddError(fmt.Sprintf("%s.tags[%d]", prefix, i), "must contain only alphanumeric characters, hyphens, and underscores", tag)
+		seen[r.ID] = true
+
+		first, last := r.UnitIDFirst, r.UnitIDLast
+		if last == 0 {
+			last = first
+		}
+		switch {
+		case first < 0 || first > 255:
+			v.AddError(rp+".unit_id_first", "must be between 0 and 255", strconv.Itoa(r.UnitIDFirst))
+		case last < first || last > 255:
+			v.AddError(rp+".unit_id_last", "must be between unit_id_first and 255", strconv.Itoa(r.UnitIDLast))
+		default:
+			for unit := first; unit <= last; unit++ {
+				if owner[unit] != "" {
+					v.AddError(rp, fmt.Sprintf("unit ID %d is already routed by %s", unit, owner[unit]), r.ID)
+					break
+				}
+				owner[unit] = r.ID
+			}
+			if r.TargetUnitID < 0 || r.TargetUnitID+(last-first) > 255 {
+				v.AddError(rp+".target_unit_id", "must keep every rewritten unit ID between 0 and 255", strconv.Itoa(r.TargetUnitID))
+			}
+		}
+
+		routeCfg := r.ProxyConfig(*cfg)
+		v.validateTarget(rp, &routeCfg)
+		v.validateTuning(rp, &routeCfg)
+		if r.TargetTLS != nil {
+			v.validateTargetTLS(rp+".target_tls", r.TargetTLS, r.Protocol)
+		}
+	}
+}
+
+// validateRewrite validates the rewrite rules of a proxy.
+func (v *Validator) validateRewrite(prefix string, r *RewriteConfig) {
+	seen := make(map[int]bool)
+	for i, u := range r.Units {
+		up := fmt.Sprintf("%s.units[%d]", prefix, i)
+		if u.From < 0 || u.From > 255 {
+			v.AddError(up+".from", "must be between 0 and 255", strconv.Itoa(u.From))
+		} else if seen[u.From] {
+			v.AddError(up+".from", "unit ID is already rewritten by another rule", strconv.Itoa(u.From))
+		}
+		seen[u.From] = true
+		if u.To < 0 || u.To > 255 {
+			v.AddError(up+".to", "must be between 0 and 255", strconv.Itoa(u.To))
+		}
+	}
+
+	addressed := map[int]bool{
+		0x01: true, 0x02: true, 0x03: true, 0x04: true, 0x05: true,
+		0x06: true, 0x0F: true, 0x10: true, 0x16: true, 0x17: true,
+	}
+	for i, rule := range r.Registers {
+		rp := fmt.Sprintf("%s.registers[%d]", prefix, i)
+		for _, fc := range rule.FunctionCodes {
+			if !addressed[fc] {
+				v.AddError(rp+".function_codes", "must be one of: 1, 2, 3, 4, 5, 6, 15, 16, 22, 23", strconv.Itoa(fc))
+			}
+		}
+		for _, unit := range rule.UnitIDs {
+			if unit < 0 || unit > 255 {
+				v.AddError(rp+".unit_ids", "must be between 0 and 255", strconv.Itoa(unit))
+			}
+		}
+		if rule.Start < 0 || rule.Start > 65535 {
+			v.AddError(rp+".start", "must be between 0 and 65535", strconv.Itoa(rule.Start))
+			continue
+		}
+		if rule.Count < 1 || rule.Start+rule.Count > 65536 {
+			v.AddError(rp+".count", "must be at least 1 and keep the range below 65536", strconv.Itoa(rule.Count))
+			continue
+		}
+		if rule.TargetStart != nil && rule.Offset != 0 {
+			v.AddError(rp, "offset and target_start are mutually exclusive", "")
+			continue
+		}
+		if first := rule.Start + rule.AddressOffset(); first < 0 || first+rule.Count > 65536 {
+			v.AddError(rp, "rewritten range must stay between 0 and 65535", strconv.Itoa(first))
+		}
+	}
+}
+
+// validatePoints validates the data points of a proxy. Names must be unique
+// within the proxy because clients read points by name.
+func (v *Validator) validatePoints(prefix string, cfg *ProxyConfig) {
+	names := make(map[string]bool, len(cfg.Points))
+	ids := make(map[string]bool, len(cfg.Points))
+	for i := range cfg.Points {
+		pt := &cfg.Points[i]
+		pp := fmt.Sprintf("%s[%d]", prefix, i)
+		if err := pt.Validate(); err != nil {
+			v.AddError(pp, err.Error(), pt.Name)
+			continue
+		}
+		if names[pt.Name] {
+			v.AddError(pp+".name", "duplicate point name", pt.Name)
+		}
+		names[pt.Name] = true
+		if pt.ID != "" {
+			if ids[pt.ID] {
+				v.AddError(pp+".id", "duplicate point ID", pt.ID)
+			}
+			ids[pt.ID] = true
+		}
+	}
+}
+
+// validatePolicy validates the Modbus firewall of a proxy.
+func (v *Validator) validatePolicy(prefix string, p *PolicyConfig) {
+	v.validatePolicyRule(prefix, p.PolicyRuleConfig)
+	for i, c := range p.Clients {
+		cp := fmt.Sprintf("%s.clients[%d]", prefix, i)
+		if len(c.Sources) == 0 {
+			v.AddError(cp+".sources", "must list at least one IP address or CIDR network", "")
+		}
+		for _, src := range c.Sources {
+			if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+				v.AddError(cp+".sources", "must be an IP address or CIDR network", src)
+			}
+		}
+		v.validatePolicyRule(cp, c.PolicyRuleConfig)
+	}
+}
+
+// validatePolicyRule validates one rule of a proxy's firewall.
+func (v *Validator) validatePolicyRule(prefix string, r PolicyRuleConfig) {
+	for _, fc := range r.AllowedFunctionCodes {
+		if fc < 1 || fc > 127 {
+			v.AddError(prefix+".allowed_function_codes", "must be between 1 and 127", strconv.Itoa(fc))
+		}
+	}
+	for i, rg := range r.AllowedRanges {
+		rp := fmt.Sprintf("%s.allowed_ranges[%d]", prefix, i)
+		for _, unit := range rg.UnitIDs {
+			if unit < 0 || unit > 255 {
+				v.AddError(rp+".unit_ids", "must be between 0 and 255", strconv.Itoa(unit))
+			}
+		}
+		if rg.Start < 0 || rg.Start > 65535 {
+			v.AddError(rp+".start", "must be between 0 and 65535", strconv.Itoa(rg.Start))
+		} else if rg.Count < 1 || rg.Start+rg.Count > 65536 {
+			v.AddError(rp+".count", "must be at least 1 and keep the range below 65536", strconv.Itoa(rg.Count))
+		}
+	}
+}
+
+// validateRateLimit validates the request-rate limits of a proxy.
+func (v *Validator) validateRateLimit(prefix string, r *RateLimitConfig) {
+	v.validateRate(prefix+".client_rate", r.ClientRate)
+	v.validateBurst(prefix+".client_burst", r.ClientBurst)
+	v.validateRate(prefix+".proxy_rate", r.ProxyRate)
+	v.validateBurst(prefix+".proxy_burst", r.ProxyBurst)
+	switch r.InternalPriority {
+	case "", "low", "normal", "high", "critical":
+	default:
+		v.AddError(prefix+".internal_priority", "must be one of: low, normal, high, critical", r.InternalPriority)
+	}
+	for i, c := range r.Classes {
+		cp := fmt.Sprintf("%s.classes[%d]", prefix, i)
+		if len(c.Sources) == 0 {
+			v.AddError(cp+".sources", "must list at least one IP address or CIDR network", "")
+		}
+		for _, src := range c.Sources {
+			if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+				v.AddError(cp+".sources", "must be an IP address or CIDR network", src)
+			}
+		}
+		switch c.Priority {
+		case "", "low", "normal", "high", "critical":
+		default:
+			v.AddError(cp+".priority", "must be one of: low, normal, high, critical", c.Priority)
+		}
+		if c.ClientRate != nil {
+			v.validateRate(cp+".client_rate", *c.ClientRate)
+		}
+		v.validateBurst(cp+".client_burst", c.ClientBurst)
+	}
+}
+
+// validateRate validates a request rate in requests per second.
+func (v *Validator) validateRate(field string, rate float64) {
+	if !(rate >= 0 && rate <= 100000) {
+		v.AddError(field, "must be between 0 and 100000 requests per second", strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+}
+
+// validateBurst validates the burst size of a request rate.
+func (v *Validator) validateBurst(field string, burst int) {
+	if burst < 0 || burst > 100000 {
+		v.AddError(field, "must be between 0 and 100000", strconv.Itoa(burst))
+	}
+}
+
+// validateProxyTLS validates the TLS settings of a proxy's listener and
+// target.
+func (v *Validator) validateProxyTLS(prefix string, cfg *ProxyConfig) {
+	if l := cfg.ListenTLS; l != nil {
+		lp := prefix + ".listen_tls"
+		v.validateTLSFile(lp+".cert_file", l.CertFile, true)
+		v.validateTLSFile(lp+".key_file", l.KeyFile, true)
+		switch l.ClientAuth {
+		case "", "verify-cert", "verify-ca":
+			// Verifying client certificates takes the CA that signs them.
+			if l.ClientCAFile == "" {
+				v.AddError(lp+".client_ca_file", "required unless client_auth is require, request or none", "")
+			}
+		case "require", "request", "none":
+		default:
+			v.AddError(lp+".client_auth", "must be one of: verify-cert, verify-ca, require, request, none", l.ClientAuth)
+		}
+		v.validateTLSFile(lp+".client_ca_file", l.ClientCAFile, false)
+		switch l.MinVersion {
+		case "", "1.2", "1.3":
+		default:
+			v.AddError(lp+".min_version", "must be 1.2 or 1.3", l.MinVersion)
+		}
+		if len(l.Authorization) > 0 {
+			v.validateAuthorization(lp, l)
+		}
+	}
+	if cfg.TargetTLS != nil {
+		v.validateTargetTLS(prefix+".target_tls", cfg.TargetTLS, cfg.Protocol)
+	}
+}
+
+// validateAuthorization validates the certificate grants of a listener.
+func (v *Validator) validateAuthorization(prefix string, l *ListenTLSConfig) {
+	// Without verification anyone can make a certificate with any subject
+	// and role, so only a verified one may grant anything.
+	switch l.ClientAuth {
+	case "", "verify-cert", "verify-ca":
+	default:
+		v.AddError(prefix+".authorization", "needs client_auth verify-cert or verify-ca", l.ClientAuth)
+	}
+	for i, g := range l.Authorization {
+		gp := fmt.Sprintf("%s.authorization[%d]", prefix, i)
+		if _, err := rbac.ParseRole(g.Role); err != nil {
+			v.AddError(gp+".role", "must be one of: admin, techniker, benutzer, auditor", g.Role)
+		}
+		for _, unit := range g.AllowedUnitIDs {
+			if unit < 0 || unit > 255 {
+				v.AddError(gp+".allowed_unit_ids", "must be between 0 and 255", strconv.Itoa(unit))
+			}
+		}
+		v.validatePolicyRule(gp, g.PolicyRuleConfig)
+	}
+}
+
+// validateTargetTLS validates how a proxy or route reaches a TLS target.
+func (v *Validator) validateTargetTLS(prefix string, t *TargetTLSConfig, protocol string) {
+	if protocol == "serial" || protocol == "virtual" {
+		v.AddError(prefix, "cannot be combined with a "+protocol+" target", protocol)
+		return
+	}
+	v.validateTLSFile(prefix+".ca_file", t.CAFile, false)
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		v.AddError(prefix, "cert_file and key_file must be set together", "")
+	}
+	v.validateTLSFile(prefix+".cert_file", t.CertFile, false)
+	v.validateTLSFile(prefix+".key_file", t.KeyFile, false)
+}
+
+// validateTLSFile validates the path of a certificate or key file.
+func (v *Validator) validateTLSFile(field, path string, required bool) {
+	switch {
+	case path == "":
+		if required {
+			v.AddError(field, "cannot be empty", path)
+		}
+	case !v.FileExists(path):
+		v.AddError(field, "file does not exist or is not readable", path)
+	}
+}
+
+// validateHealthProbe validates the read that checks a proxy's target.
+func (v *Validator) validateHealthProbe(prefix string, h *HealthProbeConfig) {
+	if h.UnitID < 0 || h.UnitID > 255 {
+		v.AddError(prefix+".unit_id", "must be between 0 and 255", strconv.Itoa(h.UnitID))
+	}
+	if h.FunctionCode != 0 && h.FunctionCode != 3 && h.FunctionCode != 4 {
+		v.AddError(prefix+".function_code", "must be 3 (holding registers) or 4 (input registers)", strconv.Itoa(h.FunctionCode))
+	}
+	if h.Count < 0 || h.Count > 125 {
+		v.AddError(prefix+".count", "must be between 1 and 125", strconv.Itoa(h.Count))
+	}
+	count := max(h.Count, 1)
+	if h.Address < 0 || h.Address+count > 65536 {
+		v.AddError(prefix+".address", "must keep the read between 0 and 65535", strconv.Itoa(h.Address))
+	}
+	bounds := []struct {
+		name  string
+		value *int
+	}{{"expect", h.Expect}, {"min", h.Min}, {"max", h.Max}}
+	for _, b := range bounds {
+		if b.value != nil && (*b.value < 0 || *b.value > 65535) {
+			v.AddError(prefix+"."+b.name, "must be between 0 and 65535", strconv.Itoa(*b.value))
+		}
+	}
+	if h.Min != nil && h.Max != nil && *h.Min > *h.Max {
+		v.AddError(prefix+".min", "must not exceed max", strconv.Itoa(*h.Min))
+	}
+	if h.MaxLatencyMs < 0 || h.MaxLatencyMs > 60000 {
+		v.AddError(prefix+".max_latency_ms", "must be between 0 and 60000", strconv.Itoa(h.MaxLatencyMs))
+	}
+	if h.IntervalMs != 0 && (h.IntervalMs < 1000 || h.IntervalMs > 3600000) {
+		v.AddError(prefix+".interval_ms", "must be 0 (default) or between 1000 and 3600000", strconv.Itoa(h.IntervalMs))
+	}
+	if h.FailureThreshold < 0 || h.FailureThreshold > 100 {
+		v.AddError(prefix+".failure_threshold", "must be between 0 and 100", strconv.Itoa(h.FailureThreshold))
+	}
+}
+
+// validateSerialConfig validates the line settings of a serial proxy. Zero
+// values stand for the defaults and are accepted.
+func (v *Validator) validateSerialConfig(prefix string, s *SerialConfig) {
+	if s.Device == "" {
+		v.AddError(prefix+".device", "cannot be empty", s.Device)
+	} else if !strings.HasPrefix(s.Device, "/dev/") {
+		v.AddError(prefix+".device", "must be a device path below /dev", s.Device)
+	}
+
+	validBaudRates := map[int]bool{
+		0: true, 300: true, 600: true, 1200: true, 2400: true, 4800: true,
+		9600: true, 19200: true, 38400: true, 57600: true, 115200: true,
+	}
+	if !validBaudRates[s.BaudRate] {
+		v.AddError(prefix+".baud_rate", "must be one of: 300, 600, 1200, 2400, 4800, 9600, 19200, 38400, 57600, 115200", strconv.Itoa(s.BaudRate))
+	}
+
+	if s.DataBits != 0 && (s.DataBits < 5 || s.DataBits > 8) {
+		v.AddError(prefix+".data_bits", "must be between 5 and 8", strconv.Itoa(s.DataBits))
+	}
+
+	switch s.Parity {
+	case "", "None", "Odd", "Even", "Mark", "Space":
+	default:
+		v.AddError(prefix+".parity", "must be one of: None, Odd, Even, Mark, Space", s.Parity)
+	}
+
+	if s.StopBits != 0 && s.StopBits != 1 && s.StopBits != 2 {
+		v.AddError(prefix+".stop_bits", "must be 1 or 2", strconv.Itoa(s.StopBits))
+	}
+
+	if s.InterFrameDelayMs < 0 {
+		v.AddError(prefix+".inter_frame_delay_ms", "must be non-negative", strconv.Itoa(s.InterFrameDelayMs))
+	} else if s.InterFrameDelayMs > 1000 {
+		v.AddError(prefix+".inter_frame_delay_ms", "must not exceed 1000 ms", strconv.Itoa(s.InterFrameDelayMs))
+	}
+}
+
+// validateVirtualConfig validates a simulated device. Registers of one table
+// must not overlap: which of two would answer is not obvious from the config.
+func (v *Validator) validateVirtualConfig(prefix string, cfg *VirtualConfig) {
+	if len(cfg.Registers) == 0 && cfg.ReplayFile == "" {
+		v.AddError(prefix+".registers", "must describe at least one register (or set replay_file)", "")
+	}
+	if cfg.ReplayFile != "" && !v.FileExists(cfg.ReplayFile) {
+		v.AddError(prefix+".replay_file", "file does not exist or is not readable", cfg.ReplayFile)
+	}
+	if cfg.MinGapMs < 0 || cfg.MinGapMs > 60000 {
+		v.AddError(prefix+".min_gap_ms", "must be between 0 and 60000", strconv.Itoa(cfg.MinGapMs))
+	}
+	if cfg.DropRate < 0 || cfg.DropRate > 1 {
+		v.AddError(prefix+".drop_rate", "must be between 0 and 1", strconv.FormatFloat(cfg.DropRate, 'g', -1, 64))
+	}
+	if cfg.ExceptionRate < 0 || cfg.ExceptionRate > 1 {
+		v.AddError(prefix+".exception_rate", "must be between 0 and 1", strconv.FormatFloat(cfg.ExceptionRate, 'g', -1, 64))
+	}
+	if cfg.ExceptionCode < 0 || cfg.ExceptionCode > 255 {
+		v.AddError(prefix+".exception_code", "must be between 0 and 255", strconv.Itoa(cfg.ExceptionCode))
+	}
+
+	type span struct{ first, last, index int }
+	taken := make(map[string][]span)
+	for i, r := range cfg.Registers {
+		rp := fmt.Sprintf("%s.registers[%d]", prefix, i)
+		table := r.Table
+		switch table {
+		case "":
+			table = "holding"
+		case "holding", "input", "coil", "discrete":
+		default:
+			v.AddError(rp+".table", "must be one of: holding, input, coil, discrete", r.Table)
+		}
+		count := max(r.Count, 1)
+		if r.Count < 0 {
+			v.AddError(rp+".count", "must be non-negative", strconv.Itoa(r.Count))
+		}
+		if r.Address < 0 || r.Address+count > 65536 {
+			v.AddError(rp+".address", "must keep the registers between 0 and 65535", strconv.Itoa(r.Address))
+		} else {
+			s := span{r.Address, r.Address + count - 1, i}
+			for _, o := range taken[table] {
+				if s.first <= o.last && o.first <= s.last {
+					v.AddError(rp+".address", fmt.Sprintf("overlaps registers[%d]", o.index), strconv.Itoa(r.Address))
+					break
+				}
+			}
+			taken[table] = append(taken[table], s)
+		}
+
+		inRange := func(field string, value int) {
+			if value < -32768 || value > 65535 {
+				v.AddError(rp+"."+field, "must be between -32768 and 65535", strconv.Itoa(value))
+			}
+		}
+		switch r.Type {
+		case "", "static":
+			inRange("value", r.Value)
+		case "sine", "ramp", "random_walk":
+			inRange("min", r.Min)
+			inRange("max", r.Max)
+			if r.Min > r.Max {
+				v.AddError(rp+".min", "must not exceed max", strconv.Itoa(r.Min))
+			}
+			if r.Type == "random_walk" && (r.Value < r.Min || r.Value > r.Max) {
+				v.AddError(rp+".value", "must be between min and max, where the walk starts", strconv.Itoa(r.Value))
+			}
+		case "replay":
+			if len(r.Values) == 0 {
+				v.AddError(rp+".values", "must list at least one value to replay", "")
+			} else if len(r.Values) > 100000 {
+				v.AddError(rp+".values", "must not list more than 100000 values", strconv.Itoa(len(r.Values)))
+			}
+			for _, value := range r.Values {
+				if value < -32768 || value > 65535 {
+					v.AddError(rp+".values", "must be between -32768 and 65535", strconv.Itoa(value))
+					break
+				}
+			}
+		default:
+			v.AddError(rp+".type", "must be one of: static, sine, ramp, random_walk, replay", r.Type)
+		}
+		if r.PeriodMs < 0 || r.PeriodMs > 86400000 {
+			v.AddError(rp+".period_ms", "must be between 0 and 86400000", strconv.Itoa(r.PeriodMs))
+		}
+		if r.IntervalMs < 0 || r.IntervalMs > 86400000 {
+			v.AddError(rp+".interval_ms", "must be between 0 and 86400000", strconv.Itoa(r.IntervalMs))
+		}
+		if r.Step < 0 || r.Step > 65535 {
+			v.AddError(rp+".step", "must be between 0 and 65535", strconv.Itoa(r.Step))
+		}
+		if r.Exception < 0 || r.Exception > 255 {
+			v.AddError(rp+".exception", "must be between 0 and 255", strconv.Itoa(r.Exception))
+		}
+	}
+}
+
+// validateSerialBuses validates the shared serial buses and their users. A
+// serial device can have only one owner — a bus or a single proxy — because
+// two independent writers on one line corrupt each other's frames.
+func (v *Validator) validateSerialBuses(cfg *Config) {
+	buses := make(map[string]bool)
+	devices := make(map[string]string)
+
+	for i, bus := range cfg.SerialBuses {
+		prefix := fmt.Sprintf("serial_buses[%d]", i)
+
+		if bus.ID == "" {
+			v.AddError(prefix+".id", "cannot be empty", bus.ID)
+		} else if !v.IsValidID(bus.ID) {
+			v.AddError(prefix+".id", "must contain only alphanumeric characters, hyphens, and underscores", bus.ID)
+		} else if buses[bus.ID] {
+			v.AddError(prefix+".id", "duplicate bus id", bus.ID)
+		}
+		buses[bus.ID] = true
+
+		if len(bus.Name) > 100 {
+			v.AddError(prefix+".name", "must not exceed 100 characters", bus.Name)
+		}
+
+		v.validateSerialConfig(prefix, &bus.SerialConfig)
+		if owner, taken := devices[bus.Device]; taken && bus.Device != "" {
+			v.AddError(prefix+".device", "already used by "+owner, bus.Device)
+		}
+		devices[bus.Device] = "bus " + bus.ID
+
+		if bus.UnitTimeoutMs < 0 {
+			v.AddError(prefix+".unit_timeout_ms", "must be non-negative", strconv.Itoa(bus.UnitTimeoutMs))
+		} else if bus.UnitTimeoutMs > 60000 {
+			v.AddError(prefix+".unit_timeout_ms", "must not exceed 60000 ms", strconv.Itoa(bus.UnitTimeoutMs))
+		}
+		if bus.UnitFailureThreshold < 0 {
+			v.AddError(prefix+".unit_failure_threshold", "must be non-negative", strconv.Itoa(bus.UnitFailureThreshold))
+		} else if bus.UnitFailureThreshold > 100 {
+			v.AddError(prefix+".unit_failure_threshold", "must not exceed 100", strconv.Itoa(bus.UnitFailureThreshold))
+		}
+		if bus.UnitBackoffMs < 0 {
+			v.AddError(prefix+".unit_backoff_ms", "must be non-negative", strconv.Itoa(bus.UnitBackoffMs))
+		} else if bus.UnitBackoffMs > 3600000 {
+			v.AddError(prefix+".unit_backoff_ms", "must not exceed 3600000 ms", strconv.Itoa(bus.UnitBackoffMs))
+		}
+	}
+
+	// Proxies and their routes use buses and lines the same way.
+	checkUser := func(prefix, name, protocol, busID string, serial *SerialConfig) {
+		if protocol != "serial" {
+			return
+		}
+		if busID != "" && !buses[busID] {
+			v.AddError(prefix+".bus_id", "references an unknown serial bus", busID)
+		}
+		if serial != nil && serial.Device != "" {
+			if owner, taken := devices[serial.Device]; taken {
+				v.AddError(prefix+".serial.device", "already used by "+owner+"; share it through bus_id instead", serial.Device)
+			}
+			devices[serial.Device] = name
+		}
+	}
+	for i, proxy := range cfg.Proxies {
+		prefix := fmt.Sprintf("proxies[%d]", i)
+		checkUser(prefix, "proxy "+proxy.ID, proxy.Protocol, proxy.BusID, proxy.Serial)
+		for j, r := range proxy.Routes {
+			checkUser(fmt.Sprintf("%s.routes[%d]", prefix, j), "route "+proxy.ID+"/"+r.ID, r.Protocol, r.BusID, r.Serial)
 		}
 	}
 }
@@ -466,6 +1124,15 @@ func (v *Validator) validateEmailConfig(cfg *Config) {
 			}
 		}
 	}
+
+	switch cfg.EmailSecurity {
+	case "", "starttls", "tls", "none":
+	default:
+		v.AddError("email_security", "must be one of: starttls, tls, none", cfg.EmailSecurity)
+	}
+	if cfg.EmailThrottleMinutes < 0 || cfg.EmailThrottleMinutes > 1440 {
+		v.AddError("email_throttle_minutes", "must be between 0 and 1440", strconv.Itoa(cfg.EmailThrottleMinutes))
+	}
 }
 
 // validateBackupConfig validates backup configuration
@@ -494,6 +1161,15 @@ func (v *Validator) validateBackupConfig(cfg *Config) {
 	}
 }
 
+// validateBackupEncryption validates the passphrase of encrypted backups. It
+// applies to backups made on request too, so it is checked whether or not
+// scheduled backups are on.
+func (v *Validator) validateBackupEncryption(cfg *Config) {
+	if cfg.BackupEncrypt && len(cfg.BackupPassphrase) < 8 {
+		v.AddError("backup_passphrase", "must be at least 8 characters when backup_encrypt is on", "")
+	}
+}
+
 // validateMetricsConfig validates metrics configuration
 func (v *Validator) validateMetricsConfig(cfg *Config) {
 	if cfg.MetricsEnabled {
@@ -505,6 +1181,144 @@ func (v *Validator) validateMetricsConfig(cfg *Config) {
 	}
 }
 
+// validateMQTTConfig validates the MQTT publisher.
+func (v *Validator) validateMQTTConfig(m *MQTTConfig) {
+	if m.Broker == "" {
+		v.AddError("mqtt.broker", "required when MQTT is enabled", m.Broker)
+	} else if _, _, err := m.BrokerAddr(); err != nil {
+		v.AddError("mqtt.broker", err.Error(), m.Broker)
+	}
+	if m.ProtocolVersion != 0 && m.ProtocolVersion != 4 && m.ProtocolVersion != 5 {
+		v.AddError("mqtt.protocol_version", "must be 4 (MQTT 3.1.1) or 5", strconv.Itoa(m.ProtocolVersion))
+	}
+	if m.QoS < 0 || m.QoS > 2 {
+		v.AddError("mqtt.qos", "must be 0, 1 or 2", strconv.Itoa(m.QoS))
+	}
+	if len(m.ClientID) > 128 {
+		v.AddError("mqtt.client_id", "must be at most 128 characters", m.ClientID)
+	}
+	for field, prefix := range map[string]string{"mqtt.topic_prefix": m.TopicPrefix, "mqtt.discovery_prefix": m.DiscoveryPrefix} {
+		if strings.ContainsAny(prefix, "+#") || strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			v.AddError(field, "must not contain wildcards or start or end with /", prefix)
+		}
+	}
+	if m.KeepAliveMs != 0 && (m.KeepAliveMs < 1000 || m.KeepAliveMs > 65535000) {
+		v.AddError("mqtt.keep_alive_ms", "must be 0 or between 1000 and 65535000 ms", strconv.Itoa(m.KeepAliveMs))
+	}
+	if m.PublishIntervalMs < 0 || (m.PublishIntervalMs > 0 && m.PublishIntervalMs < 1000) {
+		v.AddError("mqtt.publish_interval_ms", "must be 0 or at least 1000 ms", strconv.Itoa(m.PublishIntervalMs))
+	}
+	if m.Discovery != "" && m.Discovery != "homeassistant" {
+		v.AddError("mqtt.discovery", "must be empty or homeassistant", m.Discovery)
+	}
+}
+
+// validateHistoryConfig validates the data point history. Each tier must be
+// kept at least as long as the finer one before it, or a chart would fall
+// back to a resolution that has already been removed.
+func (v *Validator) validateHistoryConfig(h *HistoryConfig) {
+	if h.RawRetentionHours < 0 || h.RawRetentionHours > 24*90 {
+		v.AddError("history.raw_retention_hours", "must be between 0 and 2160 hours (90 days)", strconv.Itoa(h.RawRetentionHours))
+	}
+	if h.MinuteRetentionDays < 0 || h.MinuteRetentionDays > 3650 {
+		v.AddError("history.minute_retention_days", "must be between 0 and 3650 days", strconv.Itoa(h.MinuteRetentionDays))
+	}
+	if h.HourRetentionDays < 0 || h.HourRetentionDays > 3650 {
+		v.AddError("history.hour_retention_days", "must be between 0 and 3650 days", strconv.Itoa(h.HourRetentionDays))
+	}
+	if h.FlushIntervalMs < 0 || (h.FlushIntervalMs > 0 && (h.FlushIntervalMs < 1000 || h.FlushIntervalMs > 3600000)) {
+		v.AddError("history.flush_interval_ms", "must be 0 or between 1000 and 3600000 ms", strconv.Itoa(h.FlushIntervalMs))
+	}
+
+	raw, minute, hour := h.RawRetentionHours, h.MinuteRetentionDays*24, h.HourRetentionDays*24
+	if raw == 0 {
+		raw = 48
+	}
+	if minute == 0 {
+		minute = 30 * 24
+	}
+	if hour == 0 {
+		hour = 365 * 24
+	}
+	if minute < raw {
+		v.AddError("history.minute_retention_days", "must keep one-minute summaries at least as long as raw readings", strconv.Itoa(h.MinuteRetentionDays))
+	}
+	if hour < minute {
+		v.AddError("history.hour_retention_days", "must keep one-hour summaries at least as long as one-minute summaries", strconv.Itoa(h.HourRetentionDays))
+	}
+}
+
+// validateClusterConfig validates the cluster. The timeout must leave room
+// for a few lost heartbeats, or a busy node would fence itself for nothing.
+func (v *Validator) validateClusterConfig(c *ClusterConfig) {
+	if c.NodeID != "" && !v.IsValidID(c.NodeID) {
+		v.AddError("cluster.node_id", "must contain only alphanumeric characters, hyphens, and underscores", c.NodeID)
+	}
+	if c.Address == "" {
+		v.AddError("cluster.address", "required when the cluster is enabled", c.Address)
+	} else if err := clusterAddrError(c.Address); err != nil {
+		v.AddError("cluster.address", err.Error(), c.Address)
+	}
+	if len(c.Peers) == 0 {
+		v.AddError("cluster.peers", "must list at least one other node", "")
+	}
+	seen := make(map[string]bool, len(c.Peers))
+	for i, peer := range c.Peers {
+		field := fmt.Sprintf("cluster.peers[%d]", i)
+		switch err := clusterAddrError(peer); {
+		case err != nil:
+			v.AddError(field, err.Error(), peer)
+		case peer == c.Address:
+			v.AddError(field, "must not be this node's own address", peer)
+		case seen[peer]:
+			v.AddError(field, "duplicate peer", peer)
+		}
+		seen[peer] = true
+	}
+	if len(c.Secret) < 16 {
+		v.AddError("cluster.secret", "must be at least 16 characters", "")
+	}
+	// Of two nodes, the one left after the other crashed has half the votes
+	// and no quorum: it would stand by, and the cluster never fail over.
+	if c.Tiebreaker == "" {
+		if len(c.Peers) == 1 {
+			v.AddError("cluster.tiebreaker", "required in a cluster of two nodes: without it neither node takes over when the other fails", "")
+		}
+	} else if _, port, err := v.ParseHostPort(c.Tiebreaker); err != nil || port == 0 {
+		v.AddError("cluster.tiebreaker", "must be host:port", c.Tiebreaker)
+	} else if len(c.Peers) != 1 {
+		v.AddError("cluster.tiebreaker", "only decides in a cluster of two nodes", c.Tiebreaker)
+	}
+	if c.HeartbeatMs != 0 && (c.HeartbeatMs < 100 || c.HeartbeatMs > 60000) {
+		v.AddError("cluster.heartbeat_ms", "must be 0 or between 100 and 60000 ms", strconv.Itoa(c.HeartbeatMs))
+	}
+	if c.TimeoutMs != 0 && c.TimeoutMs > 600000 {
+		v.AddError("cluster.timeout_ms", "must not exceed 600000 ms", strconv.Itoa(c.TimeoutMs))
+	} else if c.Timeout() < 3*c.Heartbeat() {
+		v.AddError("cluster.timeout_ms", "must be at least three heartbeats", strconv.Itoa(c.TimeoutMs))
+	}
+}
+
+// clusterAddrError checks the address of a cluster node: host:port, or an
+// http:// or https:// URL.
+func clusterAddrError(addr string) error {
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("must be host:port or an http:// or https:// URL")
+		}
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return errors.New("must be host:port or an http:// or https:// URL")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+	return nil
+}
+
 // AddError adds a validation error
 func (v *Validator) AddError(field, message, value string) {
 	v.errors = append(v.errors, ValidationError{
@@ -707,6 +1521,149 @@ func (v *Validator) Clear() {
 	v.errors = make(ValidationErrors, 0)
 }
 
+// ValidateProxyRoutes validates the unit-ID routes of a proxy on their own,
+// for callers that check the rest of the proxy themselves.
+func ValidateProxyRoutes(cfg *ProxyConfig) error {
+	v := NewValidator()
+	v.validateRoutes("proxy", cfg)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateProxyRewrite validates the rewrite rules of a proxy on their own,
+// for callers that check the rest of the proxy themselves.
+func ValidateProxyRewrite(cfg *ProxyConfig) error {
+	if cfg.Rewrite == nil {
+		return nil
+	}
+	v := NewValidator()
+	v.validateRewrite("proxy.rewrite", cfg.Rewrite)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateProxyPolicy validates the firewall of a proxy on its own, for
+// callers that check the rest of the proxy themselves.
+func ValidateProxyPolicy(cfg *ProxyConfig) error {
+	if cfg.Policy == nil {
+		return nil
+	}
+	v := NewValidator()
+	v.validatePolicy("proxy.policy", cfg.Policy)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateProxyRateLimit validates the rate limits of a proxy on their own,
+// for callers that check the rest of the proxy themselves.
+func ValidateProxyRateLimit(cfg *ProxyConfig) error {
+	if cfg.RateLimit == nil {
+		return nil
+	}
+	v := NewValidator()
+	v.validateRateLimit("proxy.rate_limit", cfg.RateLimit)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateProxyTLS validates the TLS settings of a proxy's listener and
+// target on their own, for callers that check the rest of the proxy
+// themselves.
+func ValidateProxyTLS(cfg *ProxyConfig) error {
+	v := NewValidator()
+	v.validateProxyTLS("proxy", cfg)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateProxyPoints validates the data points of a proxy on their own, for
+// callers that check the rest of the proxy themselves.
+func ValidateProxyPoints(cfg *ProxyConfig) error {
+	v := NewValidator()
+	v.validatePoints("proxy.points", cfg)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateMQTTConfig validates the MQTT settings on their own, for callers
+// that change only those.
+func ValidateMQTTConfig(m *MQTTConfig) error {
+	if m == nil || !m.Enabled {
+		return nil
+	}
+	v := NewValidator()
+	v.validateMQTTConfig(m)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateHistoryConfig validates the history settings on their own, for
+// callers that change only those.
+func ValidateHistoryConfig(h *HistoryConfig) error {
+	if h == nil || !h.Enabled {
+		return nil
+	}
+	v := NewValidator()
+	v.validateHistoryConfig(h)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateClusterConfig validates the cluster settings on their own, for
+// callers that change only those.
+func ValidateClusterConfig(c *ClusterConfig) error {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	v := NewValidator()
+	v.validateClusterConfig(c)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateEmailConfig validates the mail settings of a configuration.
+func ValidateEmailConfig(cfg *Config) error {
+	v := NewValidator()
+	if cfg.EmailEnabled {
+		v.validateEmailConfig(cfg)
+	}
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateBackupConfig validates the backup settings of a configuration.
+func ValidateBackupConfig(cfg *Config) error {
+	v := NewValidator()
+	if cfg.BackupEnabled {
+		v.validateBackupConfig(cfg)
+	}
+	v.validateBackupEncryption(cfg)
+	if v.HasErrors() {
+		return v.errors
+	}
+	return nil
+}
+
 // ValidateProxyConfigQuick is a quick validation for proxy creation/update
 func ValidateProxyConfigQuick(cfg *ProxyConfig) error {
 	v := NewValidator()
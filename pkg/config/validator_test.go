@@ -6,6 +6,9 @@
 package config
 
 import (
+	"modbridge/pkg/mapping"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -760,3 +763,625 @@ func containsInMiddle(s, substr string) bool {
 	}
 	return false
 }
+
+func TestValidator_SerialValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		targetAddr string
+		serial     *SerialConfig
+		wantErr    bool
+	}{
+		{"defaults only need a device", "", &SerialConfig{Device: "/dev/ttyUSB0"}, false},
+		{"full line settings", "", &SerialConfig{Device: "/dev/ttyUSB0", BaudRate: 19200, DataBits: 8, Parity: "Even", StopBits: 1, InterFrameDelayMs: 5}, false},
+		{"missing serial block", "", nil, true},
+		{"missing device", "", &SerialConfig{BaudRate: 9600}, true},
+		{"device outside /dev", "", &SerialConfig{Device: "ttyUSB0"}, true},
+		{"unsupported baud rate", "", &SerialConfig{Device: "/dev/ttyUSB0", BaudRate: 12345}, true},
+		{"invalid data bits", "", &SerialConfig{Device: "/dev/ttyUSB0", DataBits: 9}, true},
+		{"invalid parity", "", &SerialConfig{Device: "/dev/ttyUSB0", Parity: "even"}, true},
+		{"invalid stop bits", "", &SerialConfig{Device: "/dev/ttyUSB0", StopBits: 3}, true},
+		{"negative frame gap", "", &SerialConfig{Device: "/dev/ttyUSB0", InterFrameDelayMs: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator()
+			cfg := getValidBaseConfig()
+			cfg.Proxies = []ProxyConfig{
+				{
+					ID:         "test-proxy",
+					Name:       "Test Proxy",
+					ListenAddr: ":5020",
+					TargetAddr: tt.targetAddr,
+					Protocol:   "serial",
+					Serial:     tt.serial,
+				},
+			}
+
+			err := v.Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_VirtualValidation(t *testing.T) {
+	static := VirtualRegisterConfig{Address: 100, Count: 10, Value: 42}
+	tests := []struct {
+		name    string
+		virtual *VirtualConfig
+		wantErr bool
+	}{
+		{"static block", &VirtualConfig{Registers: []VirtualRegisterConfig{static}}, false},
+		{"waveforms and quirks", &VirtualConfig{
+			Registers: []VirtualRegisterConfig{
+				{Table: "input", Address: 0, Type: "sine", Min: -1000, Max: 5000, PeriodMs: 60000},
+				{Table: "input", Address: 1, Type: "ramp", Min: 0, Max: 100},
+				{Table: "input", Address: 2, Type: "random_walk", Value: 50, Min: 0, Max: 100, Step: 5},
+				{Table: "input", Address: 3, Type: "replay", Values: []int{1, 2, 3}, IntervalMs: 500},
+				{Table: "coil", Address: 100, Value: 1},
+				{Address: 200, Exception: 4},
+			},
+			SingleSession: true, MinGapMs: 100, DropRate: 0.1, ExceptionRate: 0.05, ExceptionCode: 6,
+		}, false},
+		{"same address in another table", &VirtualConfig{Registers: []VirtualRegisterConfig{static, {Table: "input", Address: 105}}}, false},
+		{"missing virtual block", nil, true},
+		{"no registers", &VirtualConfig{}, true},
+		{"unknown table", &VirtualConfig{Registers: []VirtualRegisterConfig{{Table: "holdings"}}}, true},
+		{"unknown type", &VirtualConfig{Registers: []VirtualRegisterConfig{{Type: "square"}}}, true},
+		{"block past the last address", &VirtualConfig{Registers: []VirtualRegisterConfig{{Address: 65530, Count: 10}}}, true},
+		{"overlapping registers", &VirtualConfig{Registers: []VirtualRegisterConfig{static, {Address: 109}}}, true},
+		{"value out of range", &VirtualConfig{Registers: []VirtualRegisterConfig{{Value: 70000}}}, true},
+		{"min above max", &VirtualConfig{Registers: []VirtualRegisterConfig{{Type: "sine", Min: 10, Max: 0}}}, true},
+		{"walk starting outside its range", &VirtualConfig{Registers: []VirtualRegisterConfig{{Type: "random_walk", Value: 200, Max: 100}}}, true},
+		{"replay without values", &VirtualConfig{Registers: []VirtualRegisterConfig{{Type: "replay"}}}, true},
+		{"drop rate above 1", &VirtualConfig{Registers: []VirtualRegisterConfig{static}, DropRate: 1.5}, true},
+		{"missing replay file", &VirtualConfig{ReplayFile: "/nonexistent/capture.pcapng"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.Proxies = []ProxyConfig{{ID: "sim", Name: "Sim", ListenAddr: ":5020", Protocol: "virtual", Virtual: tt.virtual}}
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_ProtocolValidation(t *testing.T) {
+	for _, protocol := range []string{"", "tcp", "rtu-tcp"} {
+		cfg := getValidBaseConfig()
+		cfg.Proxies = []ProxyConfig{{ID: "p", Name: "P", ListenAddr: ":5020", TargetAddr: "localhost:502", Protocol: protocol}}
+		if err := NewValidator().Validate(&cfg); err != nil {
+			t.Errorf("protocol %q: unexpected error %v", protocol, err)
+		}
+	}
+
+	cfg := getValidBaseConfig()
+	cfg.Proxies = []ProxyConfig{{ID: "p", Name: "P", ListenAddr: ":5020", TargetAddr: "localhost:502", Protocol: "udp"}}
+	if err := NewValidator().Validate(&cfg); err == nil {
+		t.Error("protocol \"udp\" was accepted")
+	}
+}
+
+func TestValidator_SerialBusValidation(t *testing.T) {
+	busProxy := func(id, busID string) ProxyConfig {
+		return ProxyConfig{ID: id, Name: id, ListenAddr: ":" + id, Protocol: "serial", BusID: busID}
+	}
+	bus := SerialBusConfig{ID: "rs485", SerialConfig: SerialConfig{Device: "/dev/ttyUSB0"}}
+
+	tests := []struct {
+		name    string
+		buses   []SerialBusConfig
+		proxies []ProxyConfig
+		wantErr bool
+	}{
+		{"two proxies on one bus", []SerialBusConfig{bus}, []ProxyConfig{busProxy("5020", "rs485"), busProxy("5021", "rs485")}, false},
+		{"unknown bus", []SerialBusConfig{bus}, []ProxyConfig{busProxy("5020", "other")}, true},
+		{"duplicate bus id", []SerialBusConfig{bus, {ID: "rs485", SerialConfig: SerialConfig{Device: "/dev/ttyUSB1"}}}, nil, true},
+		{"two buses on one device", []SerialBusConfig{bus, {ID: "rs485b", SerialConfig: SerialConfig{Device: "/dev/ttyUSB0"}}}, nil, true},
+		{"bus without device", []SerialBusConfig{{ID: "rs485"}}, nil, true},
+		{"negative unit timeout", []SerialBusConfig{{ID: "rs485", SerialConfig: SerialConfig{Device: "/dev/ttyUSB0"}, UnitTimeoutMs: -1}}, nil, true},
+		{
+			"proxy owns a bus device",
+			[]SerialBusConfig{bus},
+			[]ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", Protocol: "serial", Serial: &SerialConfig{Device: "/dev/ttyUSB0"}}},
+			true,
+		},
+		{
+			"serial and bus_id together",
+			[]SerialBusConfig{bus},
+			[]ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", Protocol: "serial", BusID: "rs485", Serial: &SerialConfig{Device: "/dev/ttyUSB1"}}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.SerialBuses = tt.buses
+			cfg.Proxies = tt.proxies
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_RouteValidation(t *testing.T) {
+	gateway := func(routes ...RouteConfig) ProxyConfig {
+		return ProxyConfig{ID: "gw", Name: "gw", ListenAddr: ":502", Routes: routes}
+	}
+	meter := RouteConfig{ID: "meter", UnitIDFirst: 1, UnitIDLast: 10, TargetAddr: "192.168.1.10:502"}
+
+	tests := []struct {
+		name    string
+		proxy   ProxyConfig
+		wantErr bool
+	}{
+		{"routes without own target", gateway(meter), false},
+		{"route with unit rewrite", gateway(RouteConfig{ID: "inv", UnitIDFirst: 20, TargetUnitID: 1, TargetAddr: "192.168.1.11:502"}), false},
+		{"serial route", gateway(RouteConfig{ID: "rs485", UnitIDFirst: 30, Protocol: "serial", Serial: &SerialConfig{Device: "/dev/ttyUSB0"}}), false},
+		{"overlapping ranges", gateway(meter, RouteConfig{ID: "other", UnitIDFirst: 10, TargetAddr: "192.168.1.11:502"}), true},
+		{"duplicate route id", gateway(meter, RouteConfig{ID: "meter", UnitIDFirst: 20, TargetAddr: "192.168.1.11:502"}), true},
+		{"last before first", gateway(RouteConfig{ID: "r", UnitIDFirst: 10, UnitIDLast: 5, TargetAddr: "192.168.1.11:502"}), true},
+		{"unit out of range", gateway(RouteConfig{ID: "r", UnitIDFirst: 256, TargetAddr: "192.168.1.11:502"}), true},
+		{"rewrite past 255", gateway(RouteConfig{ID: "r", UnitIDFirst: 1, UnitIDLast: 10, TargetUnitID: 250, TargetAddr: "192.168.1.11:502"}), true},
+		{"route without target", gateway(RouteConfig{ID: "r", UnitIDFirst: 1}), true},
+		{"no routes and no target", gateway(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.Proxies = []ProxyConfig{tt.proxy}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_RewriteValidation(t *testing.T) {
+	target := 40000
+	tests := []struct {
+		name    string
+		rewrite RewriteConfig
+		wantErr bool
+	}{
+		{"unit and register rules", RewriteConfig{
+			Units:     []UnitRewriteConfig{{From: 1, To: 126}},
+			Registers: []RegisterRewriteConfig{{FunctionCodes: []int{3, 16}, Start: 0, Count: 100, TargetStart: &target}},
+		}, false},
+		{"negative offset", RewriteConfig{Registers: []RegisterRewriteConfig{{Start: 40000, Count: 100, Offset: -40000}}}, false},
+		{"duplicate unit", RewriteConfig{Units: []UnitRewriteConfig{{From: 1, To: 2}, {From: 1, To: 3}}}, true},
+		{"unit out of range", RewriteConfig{Units: []UnitRewriteConfig{{From: 1, To: 300}}}, true},
+		{"unknown function code", RewriteConfig{Registers: []RegisterRewriteConfig{{FunctionCodes: []int{8}, Start: 0, Count: 10}}}, true},
+		{"empty range", RewriteConfig{Registers: []RegisterRewriteConfig{{Start: 0, Count: 0}}}, true},
+		{"offset and target_start", RewriteConfig{Registers: []RegisterRewriteConfig{{Start: 0, Count: 10, Offset: 5, TargetStart: &target}}}, true},
+		{"rewritten past 65535", RewriteConfig{Registers: []RegisterRewriteConfig{{Start: 100, Count: 100, Offset: 65400}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			rewrite := tt.rewrite
+			cfg.Proxies = []ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", Rewrite: &rewrite}}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_PolicyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  PolicyConfig
+		wantErr bool
+	}{
+		{"read-only", PolicyConfig{PolicyRuleConfig: PolicyRuleConfig{ReadOnly: true}}, false},
+		{"ranges and client networks", PolicyConfig{
+			PolicyRuleConfig: PolicyRuleConfig{
+				AllowedFunctionCodes: []int{3, 6},
+				AllowedRanges:        []PolicyRangeConfig{{UnitIDs: []int{1}, Start: 100, Count: 10}},
+			},
+			Clients: []ClientPolicyConfig{{Sources: []string{"10.0.0.0/24", "192.168.1.5"}}},
+		}, false},
+		{"bad function code", PolicyConfig{PolicyRuleConfig: PolicyRuleConfig{AllowedFunctionCodes: []int{200}}}, true},
+		{"bad source", PolicyConfig{Clients: []ClientPolicyConfig{{Sources: []string{"not-an-ip"}}}}, true},
+		{"client without sources", PolicyConfig{Clients: []ClientPolicyConfig{{}}}, true},
+		{"empty range", PolicyConfig{PolicyRuleConfig: PolicyRuleConfig{AllowedRanges: []PolicyRangeConfig{{Start: 10}}}}, true},
+		{"range past 65535", PolicyConfig{PolicyRuleConfig: PolicyRuleConfig{AllowedRanges: []PolicyRangeConfig{{Start: 65000, Count: 1000}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			policy := tt.policy
+			cfg.Proxies = []ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", Policy: &policy}}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_HealthProbeValidation(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		probe   HealthProbeConfig
+		wantErr bool
+	}{
+		{"defaults", HealthProbeConfig{UnitID: 1, Address: 30000}, false},
+		{"range and SLO", HealthProbeConfig{UnitID: 1, FunctionCode: 4, Address: 32016, Count: 2, Min: intPtr(1), Max: intPtr(1000), MaxLatencyMs: 500, IntervalMs: 10000, FailureThreshold: 3}, false},
+		{"expected value", HealthProbeConfig{UnitID: 3, Address: 40000, Expect: intPtr(0x5375)}, false},
+		{"write function", HealthProbeConfig{UnitID: 1, FunctionCode: 6}, true},
+		{"too many registers", HealthProbeConfig{UnitID: 1, Count: 200}, true},
+		{"read past 65535", HealthProbeConfig{UnitID: 1, Address: 65535, Count: 2}, true},
+		{"bad unit", HealthProbeConfig{UnitID: 300}, true},
+		{"min above max", HealthProbeConfig{UnitID: 1, Min: intPtr(10), Max: intPtr(5)}, true},
+		{"negative expectation", HealthProbeConfig{UnitID: 1, Expect: intPtr(-1)}, true},
+		{"interval too short", HealthProbeConfig{UnitID: 1, IntervalMs: 100}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			probe := tt.probe
+			cfg.Proxies = []ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", HealthProbe: &probe}}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_TargetsValidation(t *testing.T) {
+	primary := TargetConfig{TargetAddr: "192.168.1.10:502"}
+	standby := TargetConfig{TargetAddr: "192.168.1.11:502"}
+	tests := []struct {
+		name    string
+		proxy   ProxyConfig
+		wantErr bool
+	}{
+		{"failover", ProxyConfig{Targets: []TargetConfig{primary, standby}}, false},
+		{"weighted", ProxyConfig{Targets: []TargetConfig{{TargetAddr: "192.168.1.10:502", Weight: 3}, standby}, TargetPolicy: "weighted"}, false},
+		{"ip hash", ProxyConfig{Targets: []TargetConfig{primary, standby}, TargetPolicy: "ip_hash"}, false},
+		{"unknown policy", ProxyConfig{Targets: []TargetConfig{primary, standby}, TargetPolicy: "random"}, true},
+		{"policy without targets", ProxyConfig{TargetAddr: "192.168.1.10:502", TargetPolicy: "round_robin"}, true},
+		{"target_addr as well", ProxyConfig{TargetAddr: "192.168.1.12:502", Targets: []TargetConfig{primary, standby}}, true},
+		{"duplicate target", ProxyConfig{Targets: []TargetConfig{primary, primary}}, true},
+		{"invalid address", ProxyConfig{Targets: []TargetConfig{primary, {TargetAddr: "standby"}}}, true},
+		{"weight too high", ProxyConfig{Targets: []TargetConfig{primary, {TargetAddr: "192.168.1.11:502", Weight: 101}}}, true},
+		{"serial", ProxyConfig{Protocol: "serial", Targets: []TargetConfig{primary, standby}}, true},
+		{"virtual", ProxyConfig{Protocol: "virtual", Targets: []TargetConfig{primary, standby}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			pc := tt.proxy
+			pc.ID, pc.Name, pc.ListenAddr = "p", "p", ":5020"
+			cfg.Proxies = []ProxyConfig{pc}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_PointsValidation(t *testing.T) {
+	power := mapping.Mapping{ID: "a", Name: "pv_power_w", DataType: "int32", RegisterAddress: 40083}
+	tests := []struct {
+		name    string
+		points  []mapping.Mapping
+		wantErr bool
+	}{
+		{"one point", []mapping.Mapping{power}, false},
+		{"two points", []mapping.Mapping{power, {ID: "b", Name: "serial", DataType: "string", RegisterCount: 8}}, false},
+		{"duplicate name", []mapping.Mapping{power, {ID: "b", Name: "pv_power_w", DataType: "int16"}}, true},
+		{"duplicate ID", []mapping.Mapping{power, {ID: "a", Name: "other", DataType: "int16"}}, true},
+		{"invalid point", []mapping.Mapping{{Name: "x", DataType: "int24"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.Proxies = []ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", Points: tt.points}}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_MQTTValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		mqtt    MQTTConfig
+		wantErr bool
+	}{
+		{"host only", MQTTConfig{Enabled: true, Broker: "192.168.1.5"}, false},
+		{"URL with TLS", MQTTConfig{Enabled: true, Broker: "mqtts://broker.local:8883", ProtocolVersion: 5, QoS: 2}, false},
+		{"disabled without broker", MQTTConfig{}, false},
+		{"missing broker", MQTTConfig{Enabled: true}, true},
+		{"unknown scheme", MQTTConfig{Enabled: true, Broker: "ws://broker.local"}, true},
+		{"protocol 3", MQTTConfig{Enabled: true, Broker: "broker.local", ProtocolVersion: 3}, true},
+		{"QoS 3", MQTTConfig{Enabled: true, Broker: "broker.local", QoS: 3}, true},
+		{"wildcard prefix", MQTTConfig{Enabled: true, Broker: "broker.local", TopicPrefix: "home/#"}, true},
+		{"short interval", MQTTConfig{Enabled: true, Broker: "broker.local", PublishIntervalMs: 10}, true},
+		{"unknown discovery", MQTTConfig{Enabled: true, Broker: "broker.local", Discovery: "homie"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.MQTT = &tt.mqtt
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMQTTConfig_BrokerAddr(t *testing.T) {
+	tests := []struct {
+		broker  string
+		addr    string
+		useTLS  bool
+		wantErr bool
+	}{
+		{"broker.local", "broker.local:1883", false, false},
+		{"broker.local:1884", "broker.local:1884", false, false},
+		{"tcp://broker.local", "broker.local:1883", false, false},
+		{"mqtts://broker.local", "broker.local:8883", true, false},
+		{"ssl://[fd00::5]:8884/", "[fd00::5]:8884", true, false},
+		{"ws://broker.local", "", false, true},
+	}
+
+	for _, tt := range tests {
+		addr, useTLS, err := (&MQTTConfig{Broker: tt.broker}).BrokerAddr()
+		if (err != nil) != tt.wantErr || addr != tt.addr || useTLS != tt.useTLS {
+			t.Errorf("BrokerAddr(%q) = %q, %v, %v; want %q, %v", tt.broker, addr, useTLS, err, tt.addr, tt.useTLS)
+		}
+	}
+}
+
+func TestValidator_HistoryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		history HistoryConfig
+		wantErr bool
+	}{
+		{"defaults", HistoryConfig{Enabled: true}, false},
+		{"custom tiers", HistoryConfig{Enabled: true, RawRetentionHours: 24, MinuteRetentionDays: 7, HourRetentionDays: 730, FlushIntervalMs: 30000}, false},
+		{"disabled with nonsense", HistoryConfig{RawRetentionHours: -1}, false},
+		{"negative retention", HistoryConfig{Enabled: true, HourRetentionDays: -1}, true},
+		{"short flush interval", HistoryConfig{Enabled: true, FlushIntervalMs: 100}, true},
+		{"minutes shorter than raw", HistoryConfig{Enabled: true, RawRetentionHours: 72, MinuteRetentionDays: 2}, true},
+		{"hours shorter than default minutes", HistoryConfig{Enabled: true, HourRetentionDays: 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.History = &tt.history
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_ClusterValidation(t *testing.T) {
+	secret := "0123456789abcdef"
+	tests := []struct {
+		name    string
+		cluster ClusterConfig
+		wantErr bool
+	}{
+		{"two nodes", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.2:8080"}, Secret: secret, Tiebreaker: "10.0.0.254:53"}, false},
+		{"three nodes by URL", ClusterConfig{Enabled: true, NodeID: "pi-1", Address: "https://pi-1:8443", Peers: []string{"https://pi-2:8443", "https://pi-3:8443"}, Secret: secret, HeartbeatMs: 500, TimeoutMs: 2000}, false},
+		{"disabled with nonsense", ClusterConfig{Address: "nowhere"}, false},
+		{"no peers", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Secret: secret}, true},
+		{"short secret", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.2:8080"}, Secret: "short", Tiebreaker: "10.0.0.254:53"}, true},
+		{"bad peer", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"ftp://10.0.0.2"}, Secret: secret, Tiebreaker: "10.0.0.254:53"}, true},
+		{"itself as peer", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.1:8080"}, Secret: secret, Tiebreaker: "10.0.0.254:53"}, true},
+		{"tiebreaker with three nodes", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.2:8080", "10.0.0.3:8080"}, Secret: secret, Tiebreaker: "10.0.0.254:53"}, true},
+		{"two nodes without a tiebreaker", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.2:8080"}, Secret: secret}, true},
+		{"timeout under three heartbeats", ClusterConfig{Enabled: true, Address: "10.0.0.1:8080", Peers: []string{"10.0.0.2:8080"}, Secret: secret, HeartbeatMs: 2000, Tiebreaker: "10.0.0.254:53"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.Cluster = &tt.cluster
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_EmailSettingsValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		security string
+		throttle int
+		wantErr  bool
+	}{
+		{"defaults", "", 0, false},
+		{"implicit tls", "tls", 60, false},
+		{"unknown security", "ssl", 0, true},
+		{"negative throttle", "starttls", -1, true},
+		{"throttle over a day", "none", 1441, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.EmailEnabled = true
+			cfg.EmailSMTPServer = "smtp.example.com"
+			cfg.EmailSMTPPort = 587
+			cfg.EmailFrom = "modbridge@example.com"
+			cfg.EmailTo = "ops@example.com, oncall@example.com"
+			cfg.EmailSecurity = tt.security
+			cfg.EmailThrottleMinutes = tt.throttle
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_BackupEncryptionValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		encrypt    bool
+		passphrase string
+		wantErr    bool
+	}{
+		{"unencrypted", false, "", false},
+		{"encrypted", true, "correct horse", false},
+		{"no passphrase", true, "", true},
+		{"short passphrase", true, "secret", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			cfg.BackupEncrypt = tt.encrypt
+			cfg.BackupPassphrase = tt.passphrase
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_ProxyRateLimitValidation(t *testing.T) {
+	rate := func(r float64) *float64 { return &r }
+	tests := []struct {
+		name    string
+		limit   RateLimitConfig
+		wantErr bool
+	}{
+		{"client and proxy limits", RateLimitConfig{ClientRate: 5, ClientBurst: 10, ProxyRate: 20}, false},
+		{"classes", RateLimitConfig{ClientRate: 2, Classes: []RateLimitClassConfig{
+			{Sources: []string{"10.0.0.5"}, Priority: "critical", ClientRate: rate(0)},
+			{Sources: []string{"192.168.0.0/16"}, Priority: "low", ClientBurst: 1},
+		}}, false},
+		{"negative rate", RateLimitConfig{ClientRate: -1}, true},
+		{"negative burst", RateLimitConfig{ProxyRate: 5, ProxyBurst: -2}, true},
+		{"internal priority", RateLimitConfig{ProxyRate: 20, InternalPriority: "high"}, false},
+		{"unknown internal priority", RateLimitConfig{InternalPriority: "background"}, true},
+		{"unknown priority", RateLimitConfig{Classes: []RateLimitClassConfig{{Sources: []string{"10.0.0.5"}, Priority: "urgent"}}}, true},
+		{"class without sources", RateLimitConfig{Classes: []RateLimitClassConfig{{Priority: "high"}}}, true},
+		{"bad source", RateLimitConfig{Classes: []RateLimitClassConfig{{Sources: []string{"scada"}}}}, true},
+		{"negative class rate", RateLimitConfig{Classes: []RateLimitClassConfig{{Sources: []string{"10.0.0.5"}, ClientRate: rate(-3)}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			limit := tt.limit
+			cfg.Proxies = []ProxyConfig{{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", RateLimit: &limit}}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidator_ProxyTLSValidation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.WriteFile(file, []byte("pem"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing.crt")
+
+	tests := []struct {
+		name     string
+		protocol string
+		listen   *ListenTLSConfig
+		target   *TargetTLSConfig
+		wantErr  bool
+	}{
+		{"listener with client CA", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, nil, false},
+		{"listener without client auth", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "none", MinVersion: "1.3"}, nil, false},
+		{"target with system roots", "", nil, &TargetTLSConfig{}, false},
+		{"target with client certificate", "", nil, &TargetTLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, false},
+		{"listener without key", "", &ListenTLSConfig{CertFile: certFile, ClientAuth: "none"}, nil, true},
+		{"verify-cert without CA", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile}, nil, true},
+		{"unknown client auth", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "always"}, nil, true},
+		{"old TLS version", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "none", MinVersion: "1.0"}, nil, true},
+		{"missing certificate file", "", &ListenTLSConfig{CertFile: missing, KeyFile: keyFile, ClientAuth: "none"}, nil, true},
+		{"target certificate without key", "", nil, &TargetTLSConfig{CertFile: certFile}, true},
+		{"serial target", "serial", nil, &TargetTLSConfig{}, true},
+		{"authorization", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, Authorization: []CertGrantConfig{
+			{CertRole: "operator", Role: "techniker"},
+			{Subject: "hmi-01", Role: "viewer", AllowedUnitIDs: []int{1, 2}},
+		}}, nil, false},
+		{"authorization with unknown role", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, Authorization: []CertGrantConfig{
+			{CertRole: "operator", Role: "root"},
+		}}, nil, true},
+		{"authorization with bad unit ID", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, Authorization: []CertGrantConfig{
+			{CertRole: "operator", Role: "admin", AllowedUnitIDs: []int{300}},
+		}}, nil, true},
+		{"authorization without verified certificates", "", &ListenTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "require", Authorization: []CertGrantConfig{
+			{CertRole: "operator", Role: "admin"},
+		}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := getValidBaseConfig()
+			proxy := ProxyConfig{ID: "p", Name: "p", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502", ListenTLS: tt.listen, TargetTLS: tt.target}
+			if tt.protocol == "serial" {
+				proxy.Protocol, proxy.TargetAddr = "serial", ""
+				proxy.Serial = &SerialConfig{Device: "/dev/ttyUSB0", BaudRate: 9600}
+			}
+			cfg.Proxies = []ProxyConfig{proxy}
+
+			err := NewValidator().Validate(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,573 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package configsync replicates the configuration between the nodes of a
+// cluster. Every change made on a node becomes a new numbered version, kept
+// in the database and offered to the other nodes. A node takes a version
+// that builds on its own and reports a conflict for one that does not, such
+// as when two nodes were changed while they could not reach each other. A
+// node that was offline catches up when it is back.
+//
+// Only the part of the configuration the nodes have in common is replicated,
+// see config.Config.Shared; each node keeps its own cluster and web server
+// settings.
+package configsync
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of a peer, as seen from this node.
+const (
+	StateInSync      = "in_sync"
+	StateBehind      = "behind"   // The peer has an older version and did not take this one
+	StateAhead       = "ahead"    // The peer has a newer version that this node did not take
+	StateConflict    = "conflict" // The versions went separate ways; a push resolves it
+	StateUnreachable = "unreachable"
+	StatePending     = "pending" // Not asked yet
+)
+
+// Results of an offer, as answered by the node it was made to.
+const (
+	resultApplied  = "applied"  // Taken over
+	resultCurrent  = "current"  // The node has this version already
+	resultAhead    = "ahead"    // The node has a newer version; fetch it
+	resultConflict = "conflict" // The node has a version that does not lead to this one
+	resultRejected = "rejected" // The configuration is invalid or damaged
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	// lineageSize is how many earlier versions an offer names. A node that
+	// missed more changes than this reports a conflict instead of catching up.
+	lineageSize = 200
+	// keepVersions is how many versions stay in the database.
+	keepVersions = 1000
+)
+
+// ErrNotRunning is returned when the replication was not started or is
+// stopped.
+var ErrNotRunning = errors.New("the configuration is not being replicated")
+
+// Config describes the replication of one node.
+type Config struct {
+	NodeID   string
+	Peers    []string      // Addresses of the other nodes, host:port or URL
+	Secret   string        // Signs the requests and encrypts the configuration
+	Interval time.Duration // Time between two rounds with every peer (0 = 10 s)
+	// Apply brings the proxies and services in line with the stored
+	// configuration once a version from a peer replaced prev.
+	Apply func(prev config.Config)
+}
+
+// Status is the replication state of this node and its peers.
+type Status struct {
+	NodeID  string       `json:"node_id"`
+	Version int          `json:"version"`          // 0 = not changed since the cluster was set up
+	Hash    string       `json:"hash"`             // Of the shared configuration
+	Origin  string       `json:"origin,omitempty"` // Node the version was made on
+	Peers   []PeerStatus `json:"peers"`
+}
+
+// PeerStatus is the replication state of one peer.
+type PeerStatus struct {
+	Address  string     `json:"address"`
+	NodeID   string     `json:"node_id,omitempty"`
+	State    string     `json:"state"`
+	Version  int        `json:"version"`             // As last heard from it
+	LastSync *time.Time `json:"last_sync,omitempty"` // Last time it was found in sync
+	Error    string     `json:"error,omitempty"`
+}
+
+// Syncer replicates the configuration of this node.
+type Syncer struct {
+	cfg    Config
+	db     *database.DB
+	cfgMgr *config.Manager
+	log    *logger.Logger
+	client *http.Client
+	aead   cipher.AEAD
+
+	applyMu sync.Mutex // One version taken over at a time, with its Apply
+	roundMu sync.Mutex // One round with the peers at a time
+
+	mu      sync.Mutex
+	version int
+	hash    string
+	origin  string
+	peers   map[string]*PeerStatus
+	force   map[string]bool // Peers a push still has to reach
+	running bool            // Between Start and Stop
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// New creates a syncer. Nothing happens until Start.
+func New(cfg Config, db *database.DB, cfgMgr *config.Manager, log *logger.Logger) *Syncer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	s := &Syncer{
+		cfg:    cfg,
+		db:     db,
+		cfgMgr: cfgMgr,
+		log:    log,
+		client: &http.Client{Timeout: 10 * time.Second},
+		aead:   newAEAD(cfg.Secret),
+		peers:  make(map[string]*PeerStatus, len(cfg.Peers)),
+		force:  make(map[string]bool),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, addr := range cfg.Peers {
+		s.peers[addr] = &PeerStatus{Address: addr, State: StatePending}
+	}
+	return s
+}
+
+// Start picks up the newest stored version and replicates from then on
+// until Stop. A configuration changed while the node was not replicating
+// becomes a new version. Start takes over the update hook of the
+// configuration manager.
+func (s *Syncer) Start() error {
+	latest, err := s.db.LatestConfigVersion()
+	if err != nil {
+		return fmt.Errorf("failed to read the configuration versions: %w", err)
+	}
+	s.mu.Lock()
+	if latest != nil {
+		s.version, s.hash, s.origin = latest.Version, latest.Hash, latest.Origin
+	} else if _, hash, err := fingerprint(s.cfgMgr.Get()); err == nil {
+		s.hash = hash // The configuration as set up: version 0
+	}
+	s.mu.Unlock()
+	if err := s.record("changed while not replicating"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel, s.done = cancel, make(chan struct{})
+	s.running = true
+	s.mu.Unlock()
+	s.cfgMgr.SetOnUpdate(s.Changed)
+	go s.run(ctx)
+	return nil
+}
+
+// Stop ends the replication and waits for a version being taken over.
+func (s *Syncer) Stop() {
+	s.cfgMgr.SetOnUpdate(nil)
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.running = false
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	s.applyMu.Lock()
+	s.applyMu.Unlock()
+}
+
+// Changed records a change of the configuration made on this node and
+// offers it to the peers.
+func (s *Syncer) Changed() {
+	if err := s.record(""); err != nil {
+		s.log.Error("CLUSTER", fmt.Sprintf("Failed to record the configuration change: %v", err))
+		return
+	}
+	s.wakeUp()
+}
+
+// record stores the configuration as a new version if it changed.
+func (s *Syncer) record(description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recordLocked(description)
+}
+
+// recordLocked stores the configuration as a new version if it changed.
+// The caller holds mu.
+func (s *Syncer) recordLocked(description string) error {
+	data, hash, err := fingerprint(s.cfgMgr.Get())
+	if err != nil {
+		return err
+	}
+	if hash == s.hash {
+		return nil
+	}
+	return s.storeLocked(database.ConfigVersion{
+		Version:       s.version + 1,
+		Hash:          hash,
+		Data:          string(data),
+		Origin:        s.cfg.NodeID,
+		Description:   description,
+		ParentVersion: s.version,
+	})
+}
+
+// storeLocked stores a version and makes it the current one. The caller
+// holds mu.
+func (s *Syncer) storeLocked(v database.ConfigVersion) error {
+	if err := s.db.AddConfigVersion(v); err != nil {
+		return fmt.Errorf("failed to store configuration version %d: %w", v.Version, err)
+	}
+	if err := s.db.PruneConfigVersions(keepVersions); err != nil {
+		s.log.Warn("CLUSTER", fmt.Sprintf("Failed to prune the configuration versions: %v", err))
+	}
+	s.version, s.hash, s.origin = v.Version, v.Hash, v.Origin
+	return nil
+}
+
+// Push makes the configuration of this node the one of the cluster: it
+// becomes a version above every one known of the peers and replaces
+// theirs, conflicting or not. It returns the new version once every
+// reachable peer was offered it.
+func (s *Syncer) Push() (int, error) {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return 0, ErrNotRunning
+	}
+	data, hash, err := fingerprint(s.cfgMgr.Get())
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	version := s.version
+	for _, p := range s.peers {
+		version = max(version, p.Version)
+	}
+	err = s.storeLocked(database.ConfigVersion{
+		Version:       version + 1,
+		Hash:          hash,
+		Data:          string(data),
+		Origin:        s.cfg.NodeID,
+		Description:   "pushed to the cluster",
+		ParentVersion: s.version,
+	})
+	if err == nil {
+		for addr := range s.peers {
+			s.force[addr] = true
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	s.log.Info("CLUSTER", fmt.Sprintf("Pushing configuration version %d to the cluster", version+1))
+	s.round()
+	return version + 1, nil
+}
+
+// Status reports the replication state of this node and its peers.
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{
+		NodeID:  s.cfg.NodeID,
+		Version: s.version,
+		Hash:    s.hash,
+		Origin:  s.origin,
+		Peers:   make([]PeerStatus, 0, len(s.peers)),
+	}
+	for _, p := range s.peers {
+		ps := *p
+		if p.LastSync != nil {
+			t := *p.LastSync
+			ps.LastSync = &t
+		}
+		st.Peers = append(st.Peers, ps)
+	}
+	sort.Slice(st.Peers, func(i, j int) bool { return st.Peers[i].Address < st.Peers[j].Address })
+	return st
+}
+
+// run holds a round with the peers on every interval, and at once after a
+// change.
+func (s *Syncer) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.round()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// wakeUp has the loop hold a round at once.
+func (s *Syncer) wakeUp() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// round brings every peer up to date, or this node up to the peers.
+func (s *Syncer) round() {
+	s.roundMu.Lock()
+	defer s.roundMu.Unlock()
+	// A change whose hook has not run yet goes out as well.
+	if err := s.record(""); err != nil {
+		s.log.Error("CLUSTER", fmt.Sprintf("Failed to record the configuration change: %v", err))
+	}
+	for _, addr := range s.cfg.Peers {
+		s.syncPeer(addr)
+	}
+}
+
+// syncPeer compares the versions of this node and a peer and sends or
+// fetches the newer one.
+func (s *Syncer) syncPeer(addr string) {
+	s.mu.Lock()
+	force := s.force[addr]
+	s.mu.Unlock()
+
+	if !force {
+		theirs, err := s.fetch(addr, false)
+		if err != nil {
+			s.setPeer(addr, "", StateUnreachable, -1, err)
+			return
+		}
+		s.mu.Lock()
+		version, hash := s.version, s.hash
+		s.mu.Unlock()
+		switch {
+		case theirs.Hash == hash && theirs.Version == version:
+			s.setPeer(addr, theirs.Node, StateInSync, theirs.Version, nil)
+			return
+		case theirs.Version > version:
+			s.pull(addr)
+			return
+		case theirs.Version == version:
+			s.setPeer(addr, theirs.Node, StateConflict, theirs.Version, fmt.Errorf("version %d differs from this node's", version))
+			return
+		}
+	}
+
+	o, err := s.offer(true)
+	if err != nil {
+		s.setPeer(addr, "", StateBehind, -1, err)
+		return
+	}
+	o.Force = force
+	a, err := s.send(addr, o)
+	if err != nil {
+		s.setPeer(addr, "", StateUnreachable, -1, err)
+		return
+	}
+	if force {
+		// Pushed once: a peer that went on meanwhile stays in conflict
+		// until the next push.
+		s.mu.Lock()
+		delete(s.force, addr)
+		s.mu.Unlock()
+	}
+	switch a.Result {
+	case resultApplied, resultCurrent:
+		s.setPeer(addr, a.Node, StateInSync, a.Version, nil)
+	case resultAhead:
+		s.pull(addr)
+	case resultConflict:
+		s.setPeer(addr, a.Node, StateConflict, a.Version, errors.New(a.Error))
+	default:
+		s.setPeer(addr, a.Node, StateBehind, a.Version, errors.New(a.Error))
+	}
+}
+
+// pull fetches the newer version of a peer and takes it over.
+func (s *Syncer) pull(addr string) {
+	o, err := s.fetch(addr, true)
+	if err != nil {
+		s.setPeer(addr, "", StateUnreachable, -1, err)
+		return
+	}
+	a := s.take(o)
+	switch a.Result {
+	case resultApplied, resultCurrent:
+		s.setPeer(addr, o.Node, StateInSync, o.Version, nil)
+	case resultConflict:
+		s.setPeer(addr, o.Node, StateConflict, o.Version, errors.New(a.Error))
+	case resultAhead:
+		// This node changed meanwhile; the next round sends it.
+		s.setPeer(addr, o.Node, StateBehind, o.Version, nil)
+	default:
+		s.setPeer(addr, o.Node, StateAhead, o.Version, errors.New(a.Error))
+	}
+}
+
+// setPeer records the state of a peer. A version below 0 keeps the one
+// last heard.
+func (s *Syncer) setPeer(addr, nodeID, state string, version int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.peers[addr]
+	if !ok {
+		return
+	}
+	if nodeID != "" {
+		p.NodeID = nodeID
+	}
+	if version >= 0 {
+		p.Version = version
+	}
+	if state != p.State && state != StateInSync {
+		msg := ""
+		if err != nil {
+			msg = ": " + err.Error()
+		}
+		s.log.Warn("CLUSTER", fmt.Sprintf("Configuration of %s is %s%s", addr, state, msg))
+	}
+	p.State, p.Error = state, ""
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if state == StateInSync {
+		now := time.Now()
+		p.LastSync = &now
+	}
+}
+
+// take decides what to make of a version offered by, or fetched from, a
+// peer, and takes it over if it builds on this node's version.
+func (s *Syncer) take(o offer) answer {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return answer{Node: s.cfg.NodeID, Result: resultRejected, Error: ErrNotRunning.Error()}
+	}
+	// A change whose hook has not run yet counts as well.
+	if err := s.recordLocked(""); err != nil {
+		s.log.Error("CLUSTER", fmt.Sprintf("Failed to record the configuration change: %v", err))
+	}
+	a := answer{Node: s.cfg.NodeID, Version: s.version, Hash: s.hash}
+	switch {
+	case o.Hash == s.hash:
+		a.Result = resultCurrent
+		if o.Version > s.version {
+			// The same configuration under a newer number, such as after a
+			// push: take the number.
+			data, _, err := fingerprint(s.cfgMgr.Get())
+			if err == nil {
+				err = s.storeLocked(database.ConfigVersion{
+					Version: o.Version, Hash: o.Hash, Data: string(data), Origin: o.Origin,
+					Description: "from node " + o.Node, ParentVersion: s.version,
+				})
+			}
+			if err != nil {
+				a.Result, a.Error = resultRejected, err.Error()
+			}
+			a.Version = s.version
+		}
+		s.mu.Unlock()
+		return a
+	case o.Version < s.version:
+		a.Result = resultAhead
+	case o.Version == s.version:
+		a.Result = resultConflict
+		a.Error = fmt.Sprintf("version %d differs from the one on node %s", o.Version, s.cfg.NodeID)
+	case o.Force || s.version == 0 || o.descendsFrom(s.version, s.hash):
+		prev, err := s.applyLocked(o)
+		if err != nil {
+			a.Result, a.Error = resultRejected, err.Error()
+			break
+		}
+		a.Result, a.Version, a.Hash = resultApplied, s.version, s.hash
+		s.mu.Unlock()
+
+		s.log.Info("CLUSTER", fmt.Sprintf("Took over configuration version %d from node %s", o.Version, o.Node))
+		if s.cfg.Apply != nil {
+			s.cfg.Apply(prev)
+		}
+		return a
+	default:
+		a.Result = resultConflict
+		a.Error = fmt.Sprintf("version %d does not build on version %d of node %s", o.Version, s.version, s.cfg.NodeID)
+	}
+	s.mu.Unlock()
+	return a
+}
+
+// applyLocked checks the configuration of an offer and stores it in place
+// of this node's. It returns the configuration it replaced. The caller
+// holds mu.
+func (s *Syncer) applyLocked(o offer) (config.Config, error) {
+	prev := s.cfgMgr.Get()
+	if o.Config == nil {
+		return prev, errors.New("the offer carries no configuration")
+	}
+	plain, err := s.open(o.Config)
+	if err != nil {
+		return prev, err
+	}
+	var shared config.Config
+	if err := json.Unmarshal(plain, &shared); err != nil {
+		return prev, fmt.Errorf("configuration is unreadable: %w", err)
+	}
+	merged := prev.WithShared(shared)
+	data, hash, err := fingerprint(merged)
+	if err != nil {
+		return prev, err
+	}
+	if hash != o.Hash {
+		return prev, errors.New("configuration does not match its hash")
+	}
+	if err := config.NewValidator().Validate(&merged); err != nil {
+		return prev, fmt.Errorf("configuration is invalid: %w", err)
+	}
+	if err := s.cfgMgr.Replace(merged); err != nil {
+		return prev, fmt.Errorf("failed to save configuration: %w", err)
+	}
+	return prev, s.storeLocked(database.ConfigVersion{
+		Version:       o.Version,
+		Hash:          hash,
+		Data:          string(data),
+		Origin:        o.Origin,
+		Description:   "from node " + o.Node,
+		ParentVersion: s.version,
+	})
+}
+
+// fingerprint returns the shared part of a configuration as JSON, and its
+// hash. It decodes and encodes it once more, so that a node decoding the
+// JSON gets the same hash.
+func fingerprint(cfg config.Config) ([]byte, string, error) {
+	data, err := json.Marshal(cfg.Shared())
+	if err != nil {
+		return nil, "", err
+	}
+	var shared config.Config
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return nil, "", err
+	}
+	if data, err = json.Marshal(shared); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package configsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+// testNode is a node on a loopback HTTP server. Cutting it off drops every
+// request to and from it, as if it was offline.
+type testNode struct {
+	*Syncer
+	cfgMgr *config.Manager
+	srv    *httptest.Server
+	cut    atomic.Bool
+
+	mu      sync.Mutex
+	applied []config.Config // The configurations replaced by a peer's
+}
+
+type cutTransport struct {
+	n    *testNode
+	next http.RoundTripper
+}
+
+func (t cutTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.n.cut.Load() {
+		return nil, errors.New("network is unreachable")
+	}
+	return t.next.RoundTrip(r)
+}
+
+func (n *testNode) appliedCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.applied)
+}
+
+// startNodes starts one node for each ID, all peers of each other, each
+// with a web port of its own.
+func startNodes(t *testing.T, ids ...string) map[string]*testNode {
+	t.Helper()
+	log, err := logger.NewLogger(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+
+	nodes := make(map[string]*testNode, len(ids))
+	for _, id := range ids {
+		n := &testNode{}
+		n.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n.cut.Load() || n.Syncer == nil {
+				http.Error(w, "unreachable", http.StatusServiceUnavailable)
+				return
+			}
+			n.HandleConfig(w, r)
+		}))
+		t.Cleanup(n.srv.Close)
+		nodes[id] = n
+	}
+	for i, id := range ids {
+		n := nodes[id]
+		dir := t.TempDir()
+		db, err := database.NewDB(filepath.Join(dir, "modbridge.db"))
+		if err != nil {
+			t.Fatalf("NewDB: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		n.cfgMgr = config.NewManager(filepath.Join(dir, "config.json"))
+		if err := n.cfgMgr.Update(func(c *config.Config) error { c.WebPort = fmt.Sprintf(":%d", 8081+i); return nil }); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		var peers []string
+		for _, other := range ids {
+			if other != id {
+				peers = append(peers, nodes[other].srv.URL)
+			}
+		}
+		n.Syncer = New(Config{
+			NodeID:   id,
+			Peers:    peers,
+			Secret:   testSecret,
+			Interval: 20 * time.Millisecond,
+			Apply: func(prev config.Config) {
+				n.mu.Lock()
+				n.applied = append(n.applied, prev)
+				n.mu.Unlock()
+			},
+		}, db, n.cfgMgr, log)
+		n.client.Transport = cutTransport{n: n, next: http.DefaultTransport}
+	}
+	for _, id := range ids {
+		if err := nodes[id].Start(); err != nil {
+			t.Fatalf("Start(%s): %v", id, err)
+		}
+		t.Cleanup(nodes[id].Stop)
+	}
+	return nodes
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func addProxy(t *testing.T, n *testNode, id string) {
+	t.Helper()
+	err := n.cfgMgr.Update(func(c *config.Config) error {
+		listen := fmt.Sprintf(":%d", 5020+len(c.Proxies))
+		c.Proxies = append(c.Proxies, config.ProxyConfig{ID: id, Name: id, ListenAddr: listen, TargetAddr: "192.168.1.10:502"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+}
+
+func hasProxy(n *testNode, id string) bool {
+	for _, p := range n.cfgMgr.Get().Proxies {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func peerState(n *testNode) string {
+	return n.Status().Peers[0].State
+}
+
+func TestChangeIsReplicated(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes["a"], nodes["b"]
+
+	addProxy(t, a, "p1")
+	waitFor(t, "b to take the change", func() bool { return hasProxy(b, "p1") })
+	if got := b.cfgMgr.Get().WebPort; got != ":8082" {
+		t.Fatalf("web port of b = %q, the node's own setting must stay", got)
+	}
+	waitFor(t, "b to apply the change", func() bool { return b.appliedCount() == 1 })
+	b.mu.Lock()
+	prev := b.applied[0]
+	b.mu.Unlock()
+	if len(prev.Proxies) != 0 {
+		t.Fatalf("Apply was called with %+v, want the configuration before the change", prev)
+	}
+	waitFor(t, "both to be in sync", func() bool { return peerState(a) == StateInSync && peerState(b) == StateInSync })
+	if sa, sb := a.Status(), b.Status(); sa.Version != 1 || sb.Version != 1 || sa.Hash != sb.Hash || sb.Origin != "a" {
+		t.Fatalf("status of a = %+v, of b = %+v", sa, sb)
+	}
+
+	// And back the other way.
+	addProxy(t, b, "p2")
+	waitFor(t, "a to take the change", func() bool { return hasProxy(a, "p2") })
+	if v := a.Status().Version; v != 2 {
+		t.Fatalf("version of a = %d, want 2", v)
+	}
+}
+
+func TestNodeCatchesUpAfterBeingOffline(t *testing.T) {
+	nodes := startNodes(t, "a", "b", "c")
+	a, c := nodes["a"], nodes["c"]
+
+	c.cut.Store(true)
+	addProxy(t, a, "p1")
+	addProxy(t, a, "p2")
+	waitFor(t, "b to take both changes", func() bool { return nodes["b"].Status().Version == 2 })
+	waitFor(t, "a to see c as unreachable", func() bool {
+		for _, p := range a.Status().Peers {
+			if p.NodeID == "" && p.State == StateUnreachable {
+				return true
+			}
+		}
+		return false
+	})
+	if hasProxy(c, "p1") {
+		t.Fatal("an offline node took a change")
+	}
+
+	c.cut.Store(false)
+	waitFor(t, "c to catch up", func() bool { return hasProxy(c, "p1") && hasProxy(c, "p2") })
+	if v := c.Status().Version; v != 2 {
+		t.Fatalf("version of c = %d, want 2", v)
+	}
+}
+
+func TestConflictIsReportedAndResolvedByPush(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes["a"], nodes["b"]
+	addProxy(t, a, "base")
+	waitFor(t, "b to take the change", func() bool { return hasProxy(b, "base") })
+
+	// Both are changed while they cannot reach each other.
+	a.cut.Store(true)
+	b.cut.Store(true)
+	addProxy(t, a, "from-a")
+	addProxy(t, b, "from-b")
+	a.cut.Store(false)
+	b.cut.Store(false)
+
+	waitFor(t, "both to report the conflict", func() bool { return peerState(a) == StateConflict && peerState(b) == StateConflict })
+	time.Sleep(100 * time.Millisecond)
+	if hasProxy(a, "from-b") || hasProxy(b, "from-a") {
+		t.Fatal("a conflicting version was taken over")
+	}
+
+	v, err := a.Push()
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if v != 3 {
+		t.Fatalf("pushed version = %d, want 3", v)
+	}
+	waitFor(t, "b to take the pushed version", func() bool { return hasProxy(b, "from-a") && !hasProxy(b, "from-b") })
+	waitFor(t, "both to be in sync", func() bool { return peerState(a) == StateInSync && peerState(b) == StateInSync })
+}
+
+func TestRejectsOfferWithWrongSecret(t *testing.T) {
+	nodes := startNodes(t, "a", "b")
+	a, b := nodes["a"], nodes["b"]
+
+	evil := New(Config{NodeID: "evil", Secret: "another secret!!"}, nil, a.cfgMgr, a.log)
+	o := offer{Node: "evil", Version: 99, Hash: "x"}
+	if _, err := evil.send(b.srv.URL, o); err == nil {
+		t.Fatal("an offer signed with another secret was accepted")
+	}
+
+	// Signed with the right secret, but sealed with another.
+	o, err := a.offer(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Version, o.Hash = 99, "another"
+	o.Config, _ = evil.seal(bytes.Repeat([]byte("x"), 10))
+	ans, err := a.send(b.srv.URL, o)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if ans.Result != resultRejected || b.Status().Version == 99 {
+		t.Fatalf("answer = %+v, want the configuration rejected", ans)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package configsync
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"modbridge/pkg/cluster"
+	"modbridge/pkg/database"
+	"net/http"
+	"strings"
+)
+
+// Path is where a node serves its configuration to the others: GET for its
+// version (with ?full=1 the configuration as well), POST to offer it one.
+const Path = "/cluster/config"
+
+// maxOfferSize bounds an offer read from a peer.
+const maxOfferSize = 32 << 20
+
+// offer is a version of the configuration, sent to a peer or fetched from
+// one.
+type offer struct {
+	Node    string                      `json:"node"` // The node it comes from
+	Version int                         `json:"version"`
+	Hash    string                      `json:"hash"`
+	Origin  string                      `json:"origin,omitempty"`  // The node it was made on
+	Lineage []database.ConfigVersionRef `json:"lineage,omitempty"` // Versions it builds on, newest first
+	Force   bool                        `json:"force,omitempty"`   // Replace the peer's version even if it conflicts
+	Config  []byte                      `json:"config,omitempty"`  // The shared configuration, sealed with the secret
+}
+
+// answer is what a node made of an offer.
+type answer struct {
+	Node    string `json:"node"`
+	Version int    `json:"version"` // The node's version afterwards
+	Hash    string `json:"hash"`
+	Result  string `json:"result"`
+	Error   string `json:"error,omitempty"`
+}
+
+// descendsFrom reports whether the offered version builds on the given one.
+func (o offer) descendsFrom(version int, hash string) bool {
+	for _, r := range o.Lineage {
+		if r.Version == version && r.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// offer returns this node's version; in full with the versions it builds on
+// and the configuration.
+func (s *Syncer) offer(full bool) (offer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The configuration sent has to be the version offered.
+	if err := s.recordLocked(""); err != nil {
+		return offer{}, err
+	}
+	o := offer{Node: s.cfg.NodeID, Version: s.version, Hash: s.hash, Origin: s.origin}
+	if !full {
+		return o, nil
+	}
+	lineage, err := s.db.ConfigVersionRefs(lineageSize)
+	if err != nil {
+		return offer{}, err
+	}
+	data, _, err := fingerprint(s.cfgMgr.Get())
+	if err != nil {
+		return offer{}, err
+	}
+	sealed, err := s.seal(data)
+	if err != nil {
+		return offer{}, err
+	}
+	o.Lineage, o.Config = lineage, sealed
+	return o, nil
+}
+
+// fetch asks a peer for its version.
+func (s *Syncer) fetch(addr string, full bool) (offer, error) {
+	url := cluster.PeerURL(addr, Path)
+	if full {
+		url += "?full=1"
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return offer{}, err
+	}
+	var o offer
+	err = s.do(req, nil, &o)
+	return o, err
+}
+
+// send offers a peer a version.
+func (s *Syncer) send(addr string, o offer) (answer, error) {
+	body, err := json.Marshal(o)
+	if err != nil {
+		return answer{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, cluster.PeerURL(addr, Path), bytes.NewReader(body))
+	if err != nil {
+		return answer{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var a answer
+	err = s.do(req, body, &a)
+	return a, err
+}
+
+// do signs and sends a request to a peer and decodes its answer.
+func (s *Syncer) do(req *http.Request, body []byte, out interface{}) error {
+	cluster.Sign(s.cfg.Secret, req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxOfferSize)).Decode(out)
+}
+
+// HandleConfig serves this node's configuration to the others and takes
+// the versions they offer. Register it at Path; the requests carry no
+// session, the signature authenticates them.
+func (s *Syncer) HandleConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		http.Error(w, ErrNotRunning.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var body []byte
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(r.Body, maxOfferSize)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := cluster.Verify(s.cfg.Secret, r, body); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var out interface{}
+	if r.Method == http.MethodGet {
+		o, err := s.offer(r.URL.Query().Get("full") == "1")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out = o
+	} else {
+		var o offer
+		if err := json.Unmarshal(body, &o); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out = s.take(o)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// newAEAD derives the key that encrypts the configuration between the
+// nodes from the cluster secret: it holds passwords.
+func newAEAD(secret string) cipher.AEAD {
+	key := sha256.Sum256([]byte("modbridge config replication\x00" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // A 32-byte key always makes a cipher
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// seal encrypts the configuration for a peer: nonce | sealed data.
+func (s *Syncer) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// open decrypts the configuration from a peer.
+func (s *Syncer) open(sealed []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("configuration is damaged or sealed with another secret")
+	}
+	plain, err := s.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, errors.New("configuration is damaged or sealed with another secret")
+	}
+	return plain, nil
+}
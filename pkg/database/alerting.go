@@ -0,0 +1,484 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Alert states.
+const (
+	AlertFiring       = "firing"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// Webhook delivery states. A delivery that went through is removed.
+const (
+	DeliveryPending = "pending" // Waiting for its next attempt
+	DeliveryFailed  = "failed"  // Given up on: a dead letter until redelivered or discarded
+)
+
+// initAlertSchema creates the tables of the alerting: its rules and
+// webhooks, kept as JSON so that a new field needs no migration, and the
+// alerts they raised, and the webhook deliveries still to be made. Times are
+// Unix milliseconds.
+func (db *DB) initAlertSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS alert_rules (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS alert_webhooks (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id TEXT NOT NULL,
+		rule_name TEXT NOT NULL,
+		proxy_id TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		value REAL NOT NULL,
+		threshold REAL NOT NULL,
+		message TEXT NOT NULL,
+		state TEXT NOT NULL,
+		fired_at INTEGER NOT NULL,
+		acknowledged_at INTEGER,
+		acknowledged_by TEXT,
+		resolved_at INTEGER,
+		resolved_by TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+	CREATE INDEX IF NOT EXISTS idx_alerts_fired ON alerts(fired_at DESC);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		alert_id INTEGER NOT NULL,
+		payload TEXT NOT NULL,
+		state TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL,
+		last_attempt_at INTEGER,
+		last_status INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(state, next_attempt_at);
+	`
+	_, err := db.conn.Exec(schema)
+	return err
+}
+
+// Alert is an alert a rule raised, from the moment it fired until someone
+// or the rule resolved it.
+type Alert struct {
+	ID             int64      `json:"id"`
+	RuleID         string     `json:"rule_id"`
+	RuleName       string     `json:"rule_name"`
+	ProxyID        string     `json:"proxy_id"`
+	Metric         string     `json:"metric"`
+	Severity       string     `json:"severity"`
+	Value          float64    `json:"value"`     // Of the metric when it fired
+	Threshold      float64    `json:"threshold"` // Of the rule when it fired
+	Message        string     `json:"message"`
+	State          string     `json:"state"`
+	FiredAt        time.Time  `json:"fired_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"` // Empty when the condition cleared by itself
+}
+
+// AlertFilter selects alerts. Zero fields match every alert.
+type AlertFilter struct {
+	States  []string
+	RuleID  string
+	ProxyID string
+	Limit   int
+	Offset  int
+}
+
+// WebhookDelivery is an alert event on its way to a webhook. Payload is the
+// body posted, fixed when the event happened.
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Event         string          `json:"event"`
+	AlertID       int64           `json:"alert_id"`
+	Payload       json.RawMessage `json:"payload"`
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastAttemptAt *time.Time      `json:"last_attempt_at,omitempty"`
+	LastStatus    int             `json:"last_status,omitempty"` // HTTP status of the last attempt; 0 without an answer
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// DeliveryFilter selects webhook deliveries. Zero fields match every one.
+type DeliveryFilter struct {
+	State     string
+	WebhookID string
+	Limit     int
+	Offset    int
+}
+
+// SaveAlertRule stores a rule, replacing one with the same ID.
+func (db *DB) SaveAlertRule(id, data string) error {
+	return db.saveAlertObject("alert_rules", id, data)
+}
+
+// DeleteAlertRule removes a rule. It reports whether there was one.
+func (db *DB) DeleteAlertRule(id string) (bool, error) {
+	return db.deleteAlertObject("alert_rules", id)
+}
+
+// AlertRules returns every stored rule.
+func (db *DB) AlertRules() ([]string, error) {
+	return db.alertObjects("alert_rules")
+}
+
+// SaveAlertWebhook stores a webhook, replacing one with the same ID.
+func (db *DB) SaveAlertWebhook(id, data string) error {
+	return db.saveAlertObject("alert_webhooks", id, data)
+}
+
+// DeleteAlertWebhook removes a webhook. It reports whether there was one.
+func (db *DB) DeleteAlertWebhook(id string) (bool, error) {
+	return db.deleteAlertObject("alert_webhooks", id)
+}
+
+// AlertWebhooks returns every stored webhook.
+func (db *DB) AlertWebhooks() ([]string, error) {
+	return db.alertObjects("alert_webhooks")
+}
+
+// The table names below come from the functions above, never from a caller.
+
+func (db *DB) saveAlertObject(table, id, data string) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO `+table+` (id, data, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = CURRENT_TIMESTAMP
+	`, id, data)
+	return err
+}
+
+func (db *DB) deleteAlertObject(table, id string) (bool, error) {
+	res, err := db.conn.Exec(`DELETE FROM `+table+` WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (db *DB) alertObjects(table string) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT data FROM ` + table + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		out = append(out, data)
+	}
+	return out, rows.Err()
+}
+
+// AddAlert stores a new alert and sets its ID.
+func (db *DB) AddAlert(a *Alert) error {
+	res, err := db.conn.Exec(`
+		INSERT INTO alerts (rule_id, rule_name, proxy_id, metric, severity, value, threshold, message, state,
+			fired_at, acknowledged_at, acknowledged_by, resolved_at, resolved_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.RuleID, a.RuleName, a.ProxyID, a.Metric, a.Severity, a.Value, a.Threshold, a.Message, a.State,
+		a.FiredAt.UnixMilli(), nullMillis(a.AcknowledgedAt), a.AcknowledgedBy, nullMillis(a.ResolvedAt), a.ResolvedBy)
+	if err != nil {
+		return err
+	}
+	a.ID, err = res.LastInsertId()
+	return err
+}
+
+// UpdateAlert stores the state of an alert: who acknowledged or resolved it
+// and when.
+func (db *DB) UpdateAlert(a *Alert) error {
+	_, err := db.conn.Exec(`
+		UPDATE alerts SET state = ?, acknowledged_at = ?, acknowledged_by = ?, resolved_at = ?, resolved_by = ?
+		WHERE id = ?
+	`, a.State, nullMillis(a.AcknowledgedAt), a.AcknowledgedBy, nullMillis(a.ResolvedAt), a.ResolvedBy, a.ID)
+	return err
+}
+
+// GetAlert returns one alert, or nil if there is none with that ID.
+func (db *DB) GetAlert(id int64) (*Alert, error) {
+	alerts, err := db.queryAlerts(`WHERE id = ?`, id)
+	if err != nil || len(alerts) == 0 {
+		return nil, err
+	}
+	return alerts[0], nil
+}
+
+// QueryAlerts returns the alerts a filter selects, newest first.
+func (db *DB) QueryAlerts(f AlertFilter) ([]*Alert, error) {
+	var where []string
+	var args []interface{}
+	if len(f.States) > 0 {
+		where = append(where, "state IN (?"+strings.Repeat(", ?", len(f.States)-1)+")")
+		for _, s := range f.States {
+			args = append(args, s)
+		}
+	}
+	if f.RuleID != "" {
+		where = append(where, "rule_id = ?")
+		args = append(args, f.RuleID)
+	}
+	if f.ProxyID != "" {
+		where = append(where, "proxy_id = ?")
+		args = append(args, f.ProxyID)
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	args = append(args, limit, f.Offset)
+	return db.queryAlerts(clause+` ORDER BY fired_at DESC, id DESC LIMIT ? OFFSET ?`, args...)
+}
+
+// DeleteResolvedAlerts removes the alerts resolved before cutoff and
+// returns how many there were.
+func (db *DB) DeleteResolvedAlerts(cutoff time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM alerts WHERE state = ? AND resolved_at < ?`, AlertResolved, cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (db *DB) queryAlerts(clause string, args ...interface{}) ([]*Alert, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, rule_id, rule_name, proxy_id, metric, severity, value, threshold, message, state,
+			fired_at, acknowledged_at, acknowledged_by, resolved_at, resolved_by
+		FROM alerts `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*Alert
+	for rows.Next() {
+		a := &Alert{}
+		var fired int64
+		var acked, resolved sql.NullInt64
+		var ackedBy, resolvedBy sql.NullString
+		if err := rows.Scan(&a.ID, &a.RuleID, &a.RuleName, &a.ProxyID, &a.Metric, &a.Severity, &a.Value, &a.Threshold,
+			&a.Message, &a.State, &fired, &acked, &ackedBy, &resolved, &resolvedBy); err != nil {
+			return nil, err
+		}
+		a.FiredAt = time.UnixMilli(fired)
+		a.AcknowledgedAt, a.AcknowledgedBy = fromMillis(acked), ackedBy.String
+		a.ResolvedAt, a.ResolvedBy = fromMillis(resolved), resolvedBy.String
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// AddWebhookDelivery stores a new delivery and sets its ID.
+func (db *DB) AddWebhookDelivery(d *WebhookDelivery) error {
+	res, err := db.conn.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, alert_id, payload, state, attempts, next_attempt_at,
+			last_attempt_at, last_status, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.WebhookID, d.Event, d.AlertID, string(d.Payload), d.State, d.Attempts, d.NextAttemptAt.UnixMilli(),
+		nullMillis(d.LastAttemptAt), d.LastStatus, d.LastError, d.CreatedAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+	d.ID, err = res.LastInsertId()
+	return err
+}
+
+// UpdateWebhookDelivery stores the outcome of an attempt: the state, the
+// attempts so far and when the next one is due.
+func (db *DB) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	_, err := db.conn.Exec(`
+		UPDATE webhook_deliveries SET state = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?,
+			last_status = ?, last_error = ?
+		WHERE id = ?
+	`, d.State, d.Attempts, d.NextAttemptAt.UnixMilli(), nullMillis(d.LastAttemptAt), d.LastStatus, d.LastError, d.ID)
+	return err
+}
+
+// DeleteWebhookDelivery removes a delivery. It reports whether there was one.
+func (db *DB) DeleteWebhookDelivery(id int64) (bool, error) {
+	res, err := db.conn.Exec(`DELETE FROM webhook_deliveries WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteWebhookDeliveries removes every delivery to a webhook and returns
+// how many there were.
+func (db *DB) DeleteWebhookDeliveries(webhookID string) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, webhookID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteFailedWebhookDeliveries removes the dead letters created before
+// cutoff and returns how many there were.
+func (db *DB) DeleteFailedWebhookDeliveries(cutoff time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM webhook_deliveries WHERE state = ? AND created_at < ?`,
+		DeliveryFailed, cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetWebhookDelivery returns one delivery, or nil if there is none with
+// that ID.
+func (db *DB) GetWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	deliveries, err := db.queryWebhookDeliveries(`WHERE id = ?`, id)
+	if err != nil || len(deliveries) == 0 {
+		return nil, err
+	}
+	return deliveries[0], nil
+}
+
+// DueWebhookDeliveries returns at most limit pending deliveries whose next
+// attempt is due at now, the longest waiting first.
+func (db *DB) DueWebhookDeliveries(now time.Time, limit int) ([]*WebhookDelivery, error) {
+	return db.queryWebhookDeliveries(`WHERE state = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		DeliveryPending, now.UnixMilli(), limit)
+}
+
+// NextWebhookDelivery returns when the next pending delivery is due. ok is
+// false when none is pending.
+func (db *DB) NextWebhookDelivery() (next time.Time, ok bool, err error) {
+	var at sql.NullInt64
+	err = db.conn.QueryRow(`SELECT MIN(next_attempt_at) FROM webhook_deliveries WHERE state = ?`,
+		DeliveryPending).Scan(&at)
+	if err != nil || !at.Valid {
+		return time.Time{}, false, err
+	}
+	return time.UnixMilli(at.Int64), true, nil
+}
+
+// QueryWebhookDeliveries returns the deliveries a filter selects, newest
+// first.
+func (db *DB) QueryWebhookDeliveries(f DeliveryFilter) ([]*WebhookDelivery, error) {
+	var where []string
+	var args []interface{}
+	if f.State != "" {
+		where = append(where, "state = ?")
+		args = append(args, f.State)
+	}
+	if f.WebhookID != "" {
+		where = append(where, "webhook_id = ?")
+		args = append(args, f.WebhookID)
+	}
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	args = append(args, limit, f.Offset)
+	return db.queryWebhookDeliveries(clause+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`, args...)
+}
+
+// CountWebhookDeliveries returns how many deliveries there are in each
+// state.
+func (db *DB) CountWebhookDeliveries() (map[string]int64, error) {
+	rows, err := db.conn.Query(`SELECT state, COUNT(*) FROM webhook_deliveries GROUP BY state`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var state string
+		var n int64
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		counts[state] = n
+	}
+	return counts, rows.Err()
+}
+
+func (db *DB) queryWebhookDeliveries(clause string, args ...interface{}) ([]*WebhookDelivery, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, webhook_id, event, alert_id, payload, state, attempts, next_attempt_at, last_attempt_at,
+			last_status, last_error, created_at
+		FROM webhook_deliveries `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		var payload string
+		var next, created int64
+		var last sql.NullInt64
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.AlertID, &payload, &d.State, &d.Attempts, &next,
+			&last, &d.LastStatus, &d.LastError, &created); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		d.NextAttemptAt, d.CreatedAt = time.UnixMilli(next), time.UnixMilli(created)
+		d.LastAttemptAt = fromMillis(last)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func nullMillis(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+func fromMillis(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.UnixMilli(v.Int64)
+	return &t
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// BackupTo writes a consistent copy of the database to path with SQLite's
+// online backup API. Writers carry on meanwhile; the copy shows the database
+// as it was when the backup started.
+func (db *DB) BackupTo(ctx context.Context, path string) error {
+	dest, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer dest.Close()
+	return copyDatabase(ctx, dest, db.conn)
+}
+
+// RestoreFrom replaces the content of the database with that of the
+// database file at path, again with the online backup API, so the open
+// connections see the restored data without a restart.
+func (db *DB) RestoreFrom(ctx context.Context, path string) error {
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer src.Close()
+	if err := src.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return copyDatabase(ctx, db.conn, src)
+}
+
+// copyDatabase copies every page of the main database of src into dest in
+// one step, which holds a read lock on src for the duration and so makes
+// the copy consistent.
+func copyDatabase(ctx context.Context, dest, src *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(d interface{}) error {
+		return srcConn.Raw(func(s interface{}) error {
+			destSQLite, ok1 := d.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := s.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return errors.New("not a SQLite connection")
+			}
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Close()
+				return fmt.Errorf("failed to copy database: %w", err)
+			}
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(filepath.Join(dir, "live.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	countDevices := func(db *DB) int {
+		var n int
+		if err := db.conn.QueryRow("SELECT COUNT(*) FROM devices").Scan(&n); err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		return n
+	}
+	insert := func(ip string) {
+		if _, err := db.conn.Exec("INSERT INTO devices (ip, first_seen, last_connect) VALUES (?, datetime('now'), datetime('now'))", ip); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	insert("10.0.0.1")
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := db.BackupTo(context.Background(), snapshot); err != nil {
+		t.Fatalf("BackupTo() error = %v", err)
+	}
+
+	copyDB, err := NewDB(snapshot)
+	if err != nil {
+		t.Fatalf("snapshot does not open: %v", err)
+	}
+	if n := countDevices(copyDB); n != 1 {
+		t.Errorf("snapshot holds %d devices, want 1", n)
+	}
+	copyDB.Close()
+
+	insert("10.0.0.2")
+	if err := db.RestoreFrom(context.Background(), snapshot); err != nil {
+		t.Fatalf("RestoreFrom() error = %v", err)
+	}
+	if n := countDevices(db); n != 1 {
+		t.Errorf("restored database holds %d devices, want 1", n)
+	}
+}
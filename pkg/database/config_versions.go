@@ -0,0 +1,115 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigVersion is one stored version of the configuration.
+type ConfigVersion struct {
+	Version       int
+	Hash          string
+	Data          string // The configuration as JSON
+	CreatedBy     string // User who made the change, if known
+	Origin        string // Cluster node the change was made on
+	Description   string
+	IsRollback    bool
+	ParentVersion int // The version it replaced (0 = none)
+	CreatedAt     time.Time
+}
+
+// ConfigVersionRef names a version of the configuration.
+type ConfigVersionRef struct {
+	Version int    `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// migrateConfigVersions adds the node a version was made on, for
+// configurations replicated between the nodes of a cluster.
+func (db *DB) migrateConfigVersions() error {
+	m := "ALTER TABLE config_versions ADD COLUMN origin TEXT NOT NULL DEFAULT ''"
+	if _, err := db.conn.Exec(m); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return fmt.Errorf("migration failed (%s): %w", m, err)
+	}
+	return nil
+}
+
+// AddConfigVersion stores a version of the configuration.
+func (db *DB) AddConfigVersion(v ConfigVersion) error {
+	var createdBy sql.NullString
+	if v.CreatedBy != "" {
+		createdBy = sql.NullString{String: v.CreatedBy, Valid: true}
+	}
+	var parent sql.NullInt64
+	if v.ParentVersion > 0 {
+		parent = sql.NullInt64{Int64: int64(v.ParentVersion), Valid: true}
+	}
+	_, err := db.conn.Exec(`
+		INSERT INTO config_versions (version, config_hash, config_data, created_by, origin, change_description, is_rollback, parent_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Version, v.Hash, v.Data, createdBy, v.Origin, v.Description, v.IsRollback, parent)
+	return err
+}
+
+// LatestConfigVersion returns the newest stored version of the
+// configuration, or nil if there is none.
+func (db *DB) LatestConfigVersion() (*ConfigVersion, error) {
+	var (
+		v               ConfigVersion
+		createdBy, desc sql.NullString
+		parent          sql.NullInt64
+		createdAt       sql.NullTime
+	)
+	err := db.conn.QueryRow(`
+		SELECT version, config_hash, config_data, created_by, origin, change_description, is_rollback, parent_version, created_at
+		FROM config_versions ORDER BY version DESC, id DESC LIMIT 1`).
+		Scan(&v.Version, &v.Hash, &v.Data, &createdBy, &v.Origin, &desc, &v.IsRollback, &parent, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.CreatedBy, v.Description = createdBy.String, desc.String
+	v.ParentVersion = int(parent.Int64)
+	v.CreatedAt = createdAt.Time
+	return &v, nil
+}
+
+// ConfigVersionRefs returns the newest stored versions of the configuration,
+// newest first.
+func (db *DB) ConfigVersionRefs(limit int) ([]ConfigVersionRef, error) {
+	rows, err := db.conn.Query(`SELECT version, config_hash FROM config_versions ORDER BY version DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []ConfigVersionRef
+	for rows.Next() {
+		var r ConfigVersionRef
+		if err := rows.Scan(&r.Version, &r.Hash); err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// PruneConfigVersions deletes all but the newest keep versions of the
+// configuration.
+func (db *DB) PruneConfigVersions(keep int) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM config_versions WHERE id NOT IN (
+			SELECT id FROM config_versions ORDER BY version DESC, id DESC LIMIT ?
+		)`, keep)
+	return err
+}
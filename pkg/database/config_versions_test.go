@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigVersions(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if v, err := db.LatestConfigVersion(); err != nil || v != nil {
+		t.Fatalf("LatestConfigVersion() on an empty table = %v, %v", v, err)
+	}
+
+	// Without a user: a change from another node, or from the system.
+	if err := db.SaveConfigVersion(1, "h1", "{}", "", "first", false, 0); err != nil {
+		t.Fatalf("SaveConfigVersion() error = %v", err)
+	}
+	for i, hash := range []string{"h2", "h3"} {
+		err := db.AddConfigVersion(ConfigVersion{Version: i + 2, Hash: hash, Data: "{}", Origin: "node-b", ParentVersion: i + 1})
+		if err != nil {
+			t.Fatalf("AddConfigVersion() error = %v", err)
+		}
+	}
+
+	latest, err := db.LatestConfigVersion()
+	if err != nil {
+		t.Fatalf("LatestConfigVersion() error = %v", err)
+	}
+	if latest.Version != 3 || latest.Hash != "h3" || latest.Origin != "node-b" || latest.ParentVersion != 2 {
+		t.Fatalf("LatestConfigVersion() = %+v", latest)
+	}
+	if _, err := db.GetConfigVersions(10); err != nil {
+		t.Fatalf("GetConfigVersions() error = %v", err)
+	}
+
+	if err := db.PruneConfigVersions(2); err != nil {
+		t.Fatalf("PruneConfigVersions() error = %v", err)
+	}
+	refs, err := db.ConfigVersionRefs(10)
+	if err != nil {
+		t.Fatalf("ConfigVersionRefs() error = %v", err)
+	}
+	want := []ConfigVersionRef{{3, "h3"}, {2, "h2"}}
+	if len(refs) != len(want) || refs[0] != want[0] || refs[1] != want[1] {
+		t.Fatalf("ConfigVersionRefs() after pruning = %v, want %v", refs, want)
+	}
+}
@@ -72,6 +72,12 @@ func NewDB(path string) (*DB, error) {
 	if err := db.initSchema(); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
+	if err := db.initHistorySchema(); err != nil {
+		return nil, fmt.Errorf("failed to initialize history schema: %w", err)
+	}
+	if err := db.initAlertSchema(); err != nil {
+		return nil, fmt.Errorf("failed to initialize alert schema: %w", err)
+	}
 
 	// Clear defer error since we succeeded
 	err = nil
@@ -0,0 +1,141 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// initHistorySchema creates the table of data point history. Every
+// resolution shares it: a raw reading is a bucket of width 0 holding one
+// reading. Times are Unix milliseconds rather than DATETIME text, because
+// this table is the one that grows.
+func (db *DB) initHistorySchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS point_history (
+		proxy_id TEXT NOT NULL,
+		point TEXT NOT NULL,
+		bucket INTEGER NOT NULL,
+		ts INTEGER NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		errors INTEGER NOT NULL DEFAULT 0,
+		value_sum REAL NOT NULL DEFAULT 0,
+		value_min REAL,
+		value_max REAL,
+		error TEXT,
+		PRIMARY KEY (proxy_id, point, bucket, ts)
+	) WITHOUT ROWID;
+
+	CREATE INDEX IF NOT EXISTS idx_point_history_age ON point_history(bucket, ts);
+	`
+	_, err := db.conn.Exec(schema)
+	return err
+}
+
+// PointSample is one row of data point history: a single reading when
+// Bucket is 0, otherwise the summary of the readings in the Bucket seconds
+// from Time on.
+type PointSample struct {
+	ProxyID string
+	Point   string
+	Bucket  int64     // Width in seconds; 0 = a raw reading
+	Time    time.Time // Time of the reading, or start of the bucket
+	Count   int       // Readings with a value
+	Errors  int       // Readings that failed
+	Sum     float64
+	Min     float64 // Only meaningful when Count > 0
+	Max     float64
+	Error   string // Why a raw reading failed
+}
+
+// SavePointSamples stores samples in one transaction. A sample for a bucket
+// that already has a row is merged into it, so a bucket can be filled over
+// several calls.
+func (db *DB) SavePointSamples(samples []PointSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO point_history (proxy_id, point, bucket, ts, count, errors, value_sum, value_min, value_max, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(proxy_id, point, bucket, ts) DO UPDATE SET
+			count = count + excluded.count,
+			errors = errors + excluded.errors,
+			value_sum = value_sum + excluded.value_sum,
+			value_min = CASE WHEN value_min IS NULL OR excluded.value_min < value_min THEN excluded.value_min ELSE value_min END,
+			value_max = CASE WHEN value_max IS NULL OR excluded.value_max > value_max THEN excluded.value_max ELSE value_max END,
+			error = COALESCE(excluded.error, error)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range samples {
+		var min, max sql.NullFloat64
+		if s.Count > 0 {
+			min = sql.NullFloat64{Float64: s.Min, Valid: true}
+			max = sql.NullFloat64{Float64: s.Max, Valid: true}
+		}
+		var errText sql.NullString
+		if s.Error != "" {
+			errText = sql.NullString{String: s.Error, Valid: true}
+		}
+		if _, err := stmt.Exec(s.ProxyID, s.Point, s.Bucket, s.Time.UnixMilli(), s.Count, s.Errors, s.Sum, min, max, errText); err != nil {
+			return fmt.Errorf("failed to save history of %s/%s: %w", s.ProxyID, s.Point, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryPointSamples returns the samples of one point at one resolution
+// from start (inclusive) to end (exclusive), oldest first, at most limit.
+func (db *DB) QueryPointSamples(proxyID, point string, bucket int64, start, end time.Time, limit int) ([]PointSample, error) {
+	rows, err := db.conn.Query(`
+		SELECT ts, count, errors, value_sum, value_min, value_max, error
+		FROM point_history
+		WHERE proxy_id = ? AND point = ? AND bucket = ? AND ts >= ? AND ts < ?
+		ORDER BY ts
+		LIMIT ?
+	`, proxyID, point, bucket, start.UnixMilli(), end.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []PointSample
+	for rows.Next() {
+		s := PointSample{ProxyID: proxyID, Point: point, Bucket: bucket}
+		var ts int64
+		var min, max sql.NullFloat64
+		var errText sql.NullString
+		if err := rows.Scan(&ts, &s.Count, &s.Errors, &s.Sum, &min, &max, &errText); err != nil {
+			return nil, err
+		}
+		s.Time = time.UnixMilli(ts)
+		s.Min, s.Max, s.Error = min.Float64, max.Float64, errText.String
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// DeletePointSamples removes the samples of one resolution older than
+// cutoff and returns how many there were.
+func (db *DB) DeletePointSamples(bucket int64, cutoff time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM point_history WHERE bucket = ? AND ts < ?`, bucket, cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -80,7 +80,10 @@ func (db *DB) initExtendedSchema() error {
 		return err
 	}
 
-	return db.migrateUsersTable()
+	if err := db.migrateUsersTable(); err != nil {
+		return err
+	}
+	return db.migrateConfigVersions()
 }
 
 func (db *DB) migrateUsersTable() error {
@@ -318,12 +321,15 @@ func (db *DB) GetAuditLogs(limit, offset int) ([]*AuditLogEntry, error) {
 
 // SaveConfigVersion saves a configuration version
 func (db *DB) SaveConfigVersion(version int, configHash, configData, createdBy, description string, isRollback bool, parentVersion int) error {
-	query := `
-		INSERT INTO config_versions (version, config_hash, config_data, created_by, change_description, is_rollback, parent_version)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
-	_, err := db.conn.Exec(query, version, configHash, configData, createdBy, description, isRollback, parentVersion)
-	return err
+	return db.AddConfigVersion(ConfigVersion{
+		Version:       version,
+		Hash:          configHash,
+		Data:          configData,
+		CreatedBy:     createdBy,
+		Description:   description,
+		IsRollback:    isRollback,
+		ParentVersion: parentVersion,
+	})
 }
 
 // GetConfigVersions retrieves all config versions
@@ -342,7 +348,8 @@ func (db *DB) GetConfigVersions(limit int) ([]map[string]interface{}, error) {
 
 	var versions []map[string]interface{}
 	for rows.Next() {
-		var id, version, configHash, createdBy, description string
+		var id, version, configHash string
+		var createdBy, description sql.NullString
 		var createdAt time.Time
 		var isRollback bool
 		var parentVersion sql.NullInt64
@@ -357,8 +364,8 @@ func (db *DB) GetConfigVersions(limit int) ([]map[string]interface{}, error) {
 			"version":            version,
 			"config_hash":        configHash,
 			"created_at":         createdAt,
-			"created_by":         createdBy,
-			"change_description": description,
+			"created_by":         createdBy.String,
+			"change_description": description.String,
 			"is_rollback":        isRollback,
 		}
 		if parentVersion.Valid {
@@ -0,0 +1,120 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"fmt"
+	"modbridge/pkg/alerting"
+	"modbridge/pkg/config"
+	"modbridge/pkg/database"
+	"modbridge/pkg/notify"
+)
+
+// startAlerting starts evaluating the alert rules. They live in the
+// database, so without one there is no alerting.
+func (m *Manager) startAlerting() {
+	m.stopAlerting()
+	if m.db == nil {
+		return
+	}
+	a := alerting.NewManager(m.db, alertSource{m}, alerting.Config{Mail: m.mailAlert}, m.log)
+	if err := a.Start(); err != nil {
+		m.log.Error("ALERT", fmt.Sprintf("Failed to start alerting: %v", err))
+		return
+	}
+
+	m.alertMu.Lock()
+	m.alerts = a
+	m.alertMu.Unlock()
+}
+
+// stopAlerting stops evaluating the alert rules.
+func (m *Manager) stopAlerting() {
+	m.alertMu.Lock()
+	a := m.alerts
+	m.alerts = nil
+	m.alertMu.Unlock()
+	if a != nil {
+		a.Stop()
+	}
+}
+
+// Alerting returns the alert rules and alerts, or nil without a database.
+func (m *Manager) Alerting() *alerting.Manager {
+	m.alertMu.Lock()
+	defer m.alertMu.Unlock()
+	return m.alerts
+}
+
+// AlertingStats reports the alert rules and how many alerts are open.
+func (m *Manager) AlertingStats() map[string]interface{} {
+	if a := m.Alerting(); a != nil {
+		return a.Stats()
+	}
+	return map[string]interface{}{"enabled": false}
+}
+
+// mailAlert mails an alert that fired or was resolved, with the mail
+// notification settings.
+func (m *Manager) mailAlert(event string, a database.Alert) {
+	label := a.ProxyID
+	if cfg, ok := m.proxyConfig(a.ProxyID); ok {
+		label = proxyLabel(cfg)
+	}
+	ev := notify.Event{
+		Kind:     notify.AlertFired,
+		Severity: a.Severity,
+		ProxyID:  a.ProxyID,
+		Subject:  a.RuleID,
+		Title:    fmt.Sprintf("%s: %s", label, a.RuleName),
+		Message:  a.Message + fmt.Sprintf("\n\nAcknowledge or resolve alert %d under Alerts in the web interface.", a.ID),
+		Time:     a.FiredAt,
+	}
+	if event == alerting.EventResolved {
+		ev.Kind = notify.AlertResolved
+		ev.Message = fmt.Sprintf("Alert %d is resolved: %s", a.ID, a.Message)
+		if a.ResolvedBy != "" {
+			ev.Message += fmt.Sprintf("\n\nResolved by %s.", a.ResolvedBy)
+		}
+		if a.ResolvedAt != nil {
+			ev.Time = *a.ResolvedAt
+		}
+	}
+	m.Notify(ev)
+}
+
+// proxyConfig returns the stored configuration of a proxy.
+func (m *Manager) proxyConfig(id string) (config.ProxyConfig, bool) {
+	for _, p := range m.cfgMgr.Get().Proxies {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return config.ProxyConfig{}, false
+}
+
+// alertSource lets the alerting read the metrics of the proxies.
+type alertSource struct {
+	m *Manager
+}
+
+func (s alertSource) Samples() map[string]alerting.Sample {
+	diagnostics := s.m.ProxyDiagnostics()
+	out := make(map[string]alerting.Sample, len(diagnostics))
+	for id, d := range diagnostics {
+		out[id] = alerting.Sample{
+			Running:        d.Running,
+			Requests:       d.Requests,
+			Errors:         d.Errors,
+			PollFailures:   d.PollFailures,
+			StaleResponses: d.StaleResponses,
+			LatencyP95:     d.LatencyP95,
+			CircuitOpen:    d.CircuitState == "open",
+			Clients:        d.ClientConns,
+		}
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"modbridge/pkg/backup"
+	"modbridge/pkg/config"
+	"time"
+)
+
+// defaultBackupDir is where backups go when no path is configured.
+const defaultBackupDir = "./backups"
+
+// backupIntervals maps the configured backup interval to the time between
+// two backups. A month is counted as 30 days.
+var backupIntervals = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+}
+
+// RestoreResult says what a restore replaced.
+type RestoreResult struct {
+	Config   bool `json:"config"`
+	Database bool `json:"database"`
+}
+
+// ReloadBackups applies the stored backup settings. Backups can be listed,
+// made and restored on request whether or not the schedule is on.
+func (m *Manager) ReloadBackups() {
+	m.stopBackups()
+
+	cfg := m.cfgMgr.Get()
+	bc := backup.Config{
+		Dir:       cfg.BackupPath,
+		Retention: cfg.BackupRetention,
+		Config:    cfg.BackupConfig,
+		Database:  cfg.BackupDatabase,
+	}
+	if bc.Dir == "" {
+		bc.Dir = defaultBackupDir
+	}
+	if cfg.BackupEnabled {
+		bc.Interval = backupIntervals[cfg.BackupInterval]
+	}
+	if !bc.Config && !bc.Database {
+		// Settings from before backups existed: a backup on request still
+		// takes everything.
+		bc.Config, bc.Database = true, true
+	}
+	if cfg.BackupEncrypt {
+		bc.Passphrase = cfg.BackupPassphrase
+	}
+	src := backup.Sources{Config: m.configSnapshot}
+	if m.db != nil {
+		src.Database = m.db.BackupTo
+	}
+
+	s := backup.NewService(bc, src, m.log)
+	s.Start()
+	m.backupMu.Lock()
+	m.backups = s
+	m.backupMu.Unlock()
+}
+
+// stopBackups ends the backup schedule.
+func (m *Manager) stopBackups() {
+	m.backupMu.Lock()
+	s := m.backups
+	m.backupMu.Unlock()
+	if s != nil {
+		s.Stop()
+	}
+}
+
+// Backups returns the backup service. It is nil until Initialize.
+func (m *Manager) Backups() *backup.Service {
+	m.backupMu.Lock()
+	defer m.backupMu.Unlock()
+	return m.backups
+}
+
+// configSnapshot returns the configuration as it is stored, secrets
+// included: a backup has to bring back everything.
+func (m *Manager) configSnapshot() ([]byte, error) {
+	return json.MarshalIndent(m.cfgMgr.Get(), "", "  ")
+}
+
+// RestoreBackup puts back the configuration and the database of a backup,
+// whichever of them it holds and the caller asks for. The configuration is
+// checked before anything is replaced; once it is in place the proxies are
+// restarted with it. The configuration restore can be undone like any other
+// change, with a rollback.
+func (m *Manager) RestoreBackup(name, passphrase string, restoreConfig, restoreDatabase bool) (RestoreResult, error) {
+	var result RestoreResult
+	s := m.Backups()
+	if s == nil {
+		return result, errors.New("backups are not available")
+	}
+	contents, err := s.Extract(name, passphrase)
+	if err != nil {
+		return result, err
+	}
+	defer contents.Remove()
+
+	var cfg config.Config
+	restoreConfig = restoreConfig && contents.Config != nil
+	if restoreConfig {
+		if err := json.Unmarshal(contents.Config, &cfg); err != nil {
+			return result, fmt.Errorf("configuration in the backup is unreadable: %w", err)
+		}
+		if err := config.NewValidator().Validate(&cfg); err != nil {
+			return result, fmt.Errorf("configuration in the backup is invalid: %w", err)
+		}
+	}
+	restoreDatabase = restoreDatabase && contents.Database != ""
+	if restoreDatabase && m.db == nil {
+		return result, errors.New("there is no database to restore into")
+	}
+	if !restoreConfig && !restoreDatabase {
+		return result, errors.New("the backup holds nothing to restore")
+	}
+
+	if restoreDatabase {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := m.db.RestoreFrom(ctx, contents.Database); err != nil {
+			return result, fmt.Errorf("failed to restore database: %w", err)
+		}
+		result.Database = true
+		m.log.Info("BACKUP", fmt.Sprintf("Database restored from %s", name))
+	}
+	if restoreConfig {
+		if err := m.cfgMgr.Update(func(c *config.Config) error {
+			*c = cfg
+			return nil
+		}); err != nil {
+			return result, fmt.Errorf("failed to restore configuration: %w", err)
+		}
+		result.Config = true
+		m.log.Info("BACKUP", fmt.Sprintf("Configuration restored from %s", name))
+		m.StopAll()
+		m.Initialize()
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+
+	"modbridge/pkg/config"
+	"modbridge/pkg/logger"
+)
+
+func TestRestoreBackupConfig(t *testing.T) {
+	dir := t.TempDir()
+	log, err := logger.NewLogger(filepath.Join(dir, "logs"), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer log.Close()
+
+	cfgMgr := config.NewManager(filepath.Join(dir, "config.json"))
+	if err := cfgMgr.Update(func(c *config.Config) error {
+		c.BackupEnabled = false
+		c.BackupPath = filepath.Join(dir, "backups")
+		c.Proxies = []config.ProxyConfig{{ID: "kept", Name: "Kept", ListenAddr: ":15020", TargetAddr: "192.0.2.1:502"}}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	m := NewManager(cfgMgr, log, nil)
+	m.ReloadBackups()
+	defer m.stopBackups()
+
+	info, err := m.Backups().Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := cfgMgr.Update(func(c *config.Config) error {
+		c.Proxies = nil
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// Without a database only the configuration can come back.
+	if _, err := m.RestoreBackup(info.Name, "", false, true); err == nil {
+		t.Error("Expected an error for a database restore without a database")
+	}
+	result, err := m.RestoreBackup(info.Name, "", true, true)
+	if err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	defer m.StopAll()
+	if !result.Config || result.Database {
+		t.Errorf("RestoreBackup() = %+v, want the configuration only", result)
+	}
+	if proxies := cfgMgr.Get().Proxies; len(proxies) != 1 || proxies[0].ID != "kept" {
+		t.Errorf("Proxies = %+v, want the one from the backup", proxies)
+	}
+}
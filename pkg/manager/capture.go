@@ -0,0 +1,53 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"fmt"
+	"modbridge/pkg/capture"
+)
+
+// Where captures are written, next to config.json and the logs, and how many
+// finished ones are kept there.
+const (
+	captureDir  = "captures"
+	maxCaptures = 20
+)
+
+// StartCapture starts capturing the frames of a proxy until opts' limits are
+// reached or StopCapture is called.
+func (m *Manager) StartCapture(proxyID string, opts capture.Options) (capture.Info, error) {
+	p, ok := m.GetProxyInstance(proxyID)
+	if !ok {
+		return capture.Info{}, fmt.Errorf("proxy not found")
+	}
+	opts.Comment = fmt.Sprintf("ModBridge proxy %s (%s), listening on %s, target %s", p.Name, p.ID, p.ListenAddr, p.TargetAddr)
+	s, err := m.captures.Start(proxyID, opts, func(s *capture.Session) {
+		info := s.Info()
+		m.log.Info(proxyID, fmt.Sprintf("Capture %s ended (%s): %d frames, %d bytes", info.ID, info.StopReason, info.Frames, info.Bytes))
+	})
+	if err != nil {
+		return capture.Info{}, err
+	}
+	p.SetCapture(s)
+	m.log.Info(proxyID, fmt.Sprintf("Capture %s started (%s)", s.ID(), opts.Format))
+	return s.Info(), nil
+}
+
+// StopCapture ends the running capture of a proxy. ok is false when there
+// was none.
+func (m *Manager) StopCapture(proxyID string) (info capture.Info, ok bool) {
+	s := m.captures.Stop(proxyID)
+	if s == nil {
+		return capture.Info{}, false
+	}
+	return s.Info(), true
+}
+
+// Captures returns the captures of every proxy, running and finished.
+func (m *Manager) Captures() *capture.Manager {
+	return m.captures
+}
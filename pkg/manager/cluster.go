@@ -0,0 +1,180 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"modbridge/pkg/cluster"
+	"modbridge/pkg/proxy"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrStandby is returned when a proxy is started on a node of a cluster that
+// stands by: only the active node runs the proxies.
+var ErrStandby = errors.New("this node stands by, the active node of the cluster runs the proxies")
+
+// ReloadCluster joins, rejoins or leaves the cluster to match the stored
+// configuration. A node that joins stands by until the cluster made it the
+// active one; a node that leaves runs its proxies on its own again.
+func (m *Manager) ReloadCluster() {
+	m.stopCluster()
+
+	cfg := m.cfgMgr.Get().Cluster
+	if cfg == nil || !cfg.Enabled {
+		m.clusterMu.Lock()
+		wasStandby := m.standby
+		m.standby = false
+		m.clusterMu.Unlock()
+		if wasStandby {
+			m.StartAll()
+		}
+		return
+	}
+
+	m.fenceProxies("joining the cluster")
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID, _ = os.Hostname()
+	}
+	c := cluster.New(cluster.Config{
+		NodeID:     nodeID,
+		Address:    cfg.Address,
+		Peers:      cfg.Peers,
+		Secret:     cfg.Secret,
+		Tiebreaker: cfg.Tiebreaker,
+		Heartbeat:  cfg.Heartbeat(),
+		Timeout:    cfg.Timeout(),
+		OnActivate: m.activateProxies,
+		OnFence:    m.fenceProxies,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.clusterMu.Lock()
+	m.cluster, m.clusterCancel = c, cancel
+	m.clusterMu.Unlock()
+
+	m.log.Info("CLUSTER", fmt.Sprintf("Node %s joins the cluster with %d peer(s); standing by", nodeID, len(cfg.Peers)))
+	if err := c.Join(ctx, cfg.Peers); err != nil {
+		m.log.Error("CLUSTER", fmt.Sprintf("Failed to join the cluster: %v", err))
+	}
+	m.startConfigSync(cfg, nodeID)
+}
+
+// stopCluster leaves the cluster, if this node is part of one. It returns
+// once the proxies are stopped and the peers were told.
+func (m *Manager) stopCluster() {
+	m.stopConfigSync()
+	m.clusterMu.Lock()
+	c, cancel := m.cluster, m.clusterCancel
+	m.cluster, m.clusterCancel = nil, nil
+	m.clusterMu.Unlock()
+	if c != nil {
+		cancel()
+		<-c.Done()
+	}
+}
+
+// Cluster returns the cluster this node is part of, or nil.
+func (m *Manager) Cluster() *cluster.Cluster {
+	m.clusterMu.RLock()
+	defer m.clusterMu.RUnlock()
+	return m.cluster
+}
+
+// ClusterStats reports the role of this node in the cluster.
+func (m *Manager) ClusterStats() map[string]interface{} {
+	c := m.Cluster()
+	if c == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	st := c.Status()
+	return map[string]interface{}{
+		"enabled":     true,
+		"node_id":     st.NodeID,
+		"role":        st.Role,
+		"active_node": st.ActiveNode,
+		"quorum":      st.Quorum,
+		"votes":       st.Votes,
+		"voters":      st.Voters,
+	}
+}
+
+// Standby reports whether this node leaves the proxies to another one.
+func (m *Manager) Standby() bool {
+	m.clusterMu.RLock()
+	defer m.clusterMu.RUnlock()
+	return m.standby
+}
+
+// startProxyInstance starts a proxy unless this node stands by. Fencing
+// waits for a start in progress, so a proxy never outlives it.
+func (m *Manager) startProxyInstance(p *proxy.ProxyInstance) error {
+	m.clusterMu.RLock()
+	defer m.clusterMu.RUnlock()
+	if m.standby {
+		return ErrStandby
+	}
+	return p.Start()
+}
+
+// activateProxies starts the enabled proxies once the cluster made this node
+// the active one.
+func (m *Manager) activateProxies() {
+	m.clusterMu.Lock()
+	m.standby = false
+	m.clusterMu.Unlock()
+
+	m.log.Info("CLUSTER", "This node is the active one now; starting the proxies")
+	m.broadcastClusterRole("active", "")
+	m.StartAll()
+}
+
+// fenceProxies stops every proxy at once and keeps them stopped, so that
+// the node taking over is the only one talking to the devices. The stored
+// configuration keeps them enabled.
+func (m *Manager) fenceProxies(reason string) {
+	m.clusterMu.Lock()
+	m.standby = true
+	m.clusterMu.Unlock()
+
+	m.mu.RLock()
+	var running []*proxy.ProxyInstance
+	for _, p := range m.proxies {
+		if p.Stats.GetStatus() == "Running" {
+			running = append(running, p)
+		}
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, p := range running {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Stop()
+		}()
+	}
+	wg.Wait()
+
+	if len(running) > 0 {
+		m.log.Warn("CLUSTER", fmt.Sprintf("Stopped %d proxy(s): %s", len(running), reason))
+	}
+	m.broadcastClusterRole("standby", reason)
+}
+
+// broadcastClusterRole tells the web interface this node's new role.
+func (m *Manager) broadcastClusterRole(role, reason string) {
+	m.broadcaster.Broadcast(map[string]interface{}{
+		"type":      "cluster_role",
+		"timestamp": time.Now(),
+		"role":      role,
+		"reason":    reason,
+	})
+}
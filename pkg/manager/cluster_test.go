@@ -0,0 +1,91 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"modbridge/pkg/config"
+	"modbridge/pkg/logger"
+)
+
+func TestStandbyNodeKeepsProxiesStopped(t *testing.T) {
+	log, err := logger.NewLogger(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer log.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listenAddr := ln.Addr().String()
+	ln.Close()
+	// The device: it only has to accept connections.
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "config.json"))
+	err = cfgMgr.Update(func(c *config.Config) error {
+		c.Proxies = []config.ProxyConfig{{ID: "p1", Name: "P1", ListenAddr: listenAddr, TargetAddr: target.Addr().String(), Enabled: true}}
+		// The only peer is not there: without a quorum this node stands by.
+		c.Cluster = &config.ClusterConfig{
+			Enabled:     true,
+			NodeID:      "a",
+			Address:     "127.0.0.1:1",
+			Peers:       []string{"127.0.0.1:2"},
+			Secret:      "0123456789abcdef",
+			HeartbeatMs: 50,
+			TimeoutMs:   200,
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	m := NewManager(cfgMgr, log, nil)
+	m.Initialize()
+	defer m.StopAll()
+
+	if !m.Standby() || m.Cluster() == nil {
+		t.Fatal("a node of a cluster without a quorum must stand by")
+	}
+	if err := m.StartProxy("p1"); !errors.Is(err, ErrStandby) {
+		t.Fatalf("StartProxy on standby = %v, want ErrStandby", err)
+	}
+	m.checkAndRestartProxies()
+	if status := m.getProxyStatus("p1")["status"]; status == "Running" {
+		t.Fatal("the health monitor restarted a proxy on a standby node")
+	}
+
+	// Leaving the cluster hands the proxies back to this node.
+	if err := cfgMgr.Update(func(c *config.Config) error { c.Cluster.Enabled = false; return nil }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	m.ReloadCluster()
+	if m.Standby() || m.Cluster() != nil {
+		t.Fatal("still standing by after leaving the cluster")
+	}
+	if status := m.getProxyStatus("p1")["status"]; status != "Running" {
+		t.Fatalf("proxy status after leaving the cluster = %v, want Running", status)
+	}
+}
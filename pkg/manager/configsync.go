@@ -0,0 +1,107 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/configsync"
+	"modbridge/pkg/logger"
+	"reflect"
+)
+
+// startConfigSync replicates the configuration between this node and the
+// others of the cluster. The versions are kept in the database, so without
+// one every node keeps its own configuration.
+func (m *Manager) startConfigSync(cfg *config.ClusterConfig, nodeID string) {
+	if m.db == nil {
+		m.log.Warn("CLUSTER", "No database: the configuration is not replicated to the other nodes")
+		return
+	}
+	s := configsync.New(configsync.Config{
+		NodeID: nodeID,
+		Peers:  cfg.Peers,
+		Secret: cfg.Secret,
+		Apply:  m.Reconcile,
+	}, m.db, m.cfgMgr, m.log)
+	if err := s.Start(); err != nil {
+		m.log.Error("CLUSTER", fmt.Sprintf("Failed to start the configuration replication: %v", err))
+		return
+	}
+	m.clusterMu.Lock()
+	m.configSync = s
+	m.clusterMu.Unlock()
+}
+
+// stopConfigSync ends the replication of the configuration, if it runs.
+func (m *Manager) stopConfigSync() {
+	m.clusterMu.Lock()
+	s := m.configSync
+	m.configSync = nil
+	m.clusterMu.Unlock()
+	if s != nil {
+		s.Stop()
+	}
+}
+
+// ConfigSync returns the replication of the configuration, or nil.
+func (m *Manager) ConfigSync() *configsync.Syncer {
+	m.clusterMu.RLock()
+	defer m.clusterMu.RUnlock()
+	return m.configSync
+}
+
+// Reconcile brings the proxies and services in line with the stored
+// configuration after it was replaced by a version from another node; prev
+// is the configuration it replaced. Proxies whose settings did not change
+// keep running.
+func (m *Manager) Reconcile(prev config.Config) {
+	cfg := m.cfgMgr.Get()
+
+	busesChanged := !reflect.DeepEqual(prev.SerialBuses, cfg.SerialBuses)
+	if busesChanged {
+		m.loadSerialBuses(cfg.SerialBuses)
+	}
+	old := make(map[string]config.ProxyConfig, len(prev.Proxies))
+	for _, pc := range prev.Proxies {
+		old[pc.ID] = pc
+	}
+	for _, pc := range cfg.Proxies {
+		before, existed := old[pc.ID]
+		delete(old, pc.ID)
+		if existed && reflect.DeepEqual(before, pc) && !(busesChanged && pc.BusID != "") {
+			continue
+		}
+		if err := m.AddProxy(pc, false); err != nil {
+			m.log.Error(pc.ID, fmt.Sprintf("Failed to add proxy: %v", err))
+			continue
+		}
+		if !pc.Enabled || pc.Paused {
+			continue
+		}
+		if p, ok := m.GetProxyInstance(pc.ID); ok {
+			if err := m.startProxyInstance(p); err != nil && !errors.Is(err, ErrStandby) {
+				m.log.Error(pc.ID, fmt.Sprintf("Failed to start proxy: %v", err))
+			}
+		}
+	}
+	for id := range old {
+		m.dropProxy(id)
+	}
+
+	if prev.LogLevel != cfg.LogLevel {
+		m.log.SetLogLevel(logger.LogLevel(cfg.LogLevel))
+	}
+	if !reflect.DeepEqual(prev.MQTT, cfg.MQTT) {
+		m.ReloadMQTT()
+	}
+	if !reflect.DeepEqual(prev.History, cfg.History) {
+		m.ReloadHistory()
+	}
+	m.ReloadBackups()
+	m.ReloadNotifications()
+}
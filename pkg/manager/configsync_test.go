@@ -0,0 +1,66 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+
+	"modbridge/pkg/config"
+	"modbridge/pkg/logger"
+)
+
+func TestReconcileRebuildsOnlyChangedProxies(t *testing.T) {
+	log, err := logger.NewLogger(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer log.Close()
+
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "config.json"))
+	err = cfgMgr.Update(func(c *config.Config) error {
+		c.Proxies = []config.ProxyConfig{
+			{ID: "same", Name: "Same", ListenAddr: ":5020", TargetAddr: "192.168.1.10:502"},
+			{ID: "edited", Name: "Edited", ListenAddr: ":5021", TargetAddr: "192.168.1.11:502"},
+			{ID: "gone", Name: "Gone", ListenAddr: ":5022", TargetAddr: "192.168.1.12:502"},
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	m := NewManager(cfgMgr, log, nil)
+	m.Initialize()
+	defer m.StopAll()
+	same, _ := m.GetProxyInstance("same")
+	edited, _ := m.GetProxyInstance("edited")
+
+	// The version of another node.
+	prev := cfgMgr.Get()
+	next := cfgMgr.Get()
+	next.Proxies = []config.ProxyConfig{
+		prev.Proxies[0],
+		{ID: "edited", Name: "Edited", ListenAddr: ":5021", TargetAddr: "192.168.1.21:502"},
+		{ID: "new", Name: "New", ListenAddr: ":5023", TargetAddr: "192.168.1.13:502"},
+	}
+	if err := cfgMgr.Replace(next); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	m.Reconcile(prev)
+
+	if p, _ := m.GetProxyInstance("same"); p != same {
+		t.Error("an unchanged proxy was rebuilt")
+	}
+	if p, _ := m.GetProxyInstance("edited"); p == edited || p.TargetAddr != "192.168.1.21:502" {
+		t.Error("an edited proxy was not rebuilt with its new settings")
+	}
+	if _, ok := m.GetProxyInstance("gone"); ok {
+		t.Error("a removed proxy is still there")
+	}
+	if _, ok := m.GetProxyInstance("new"); !ok {
+		t.Error("an added proxy is missing")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"modbridge/pkg/timeseries"
+	"time"
+)
+
+// ReloadHistory starts, restarts or stops recording the data point history
+// to match the stored configuration. Without a database it stays off.
+func (m *Manager) ReloadHistory() {
+	m.stopHistory()
+
+	cfg := m.cfgMgr.Get().History
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	if m.db == nil {
+		m.log.Warn("HISTORY", "History is enabled but there is no database; nothing is recorded")
+		return
+	}
+	h := timeseries.NewManager(m.db, historySource{m}, timeseries.Config{
+		RawRetention:    time.Duration(cfg.RawRetentionHours) * time.Hour,
+		MinuteRetention: time.Duration(cfg.MinuteRetentionDays) * 24 * time.Hour,
+		HourRetention:   time.Duration(cfg.HourRetentionDays) * 24 * time.Hour,
+		FlushInterval:   time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+	}, m.log)
+	h.Start()
+
+	m.historyMu.Lock()
+	m.history = h
+	m.historyMu.Unlock()
+}
+
+// stopHistory stops recording, writing what is still held.
+func (m *Manager) stopHistory() {
+	m.historyMu.Lock()
+	h := m.history
+	m.history = nil
+	m.historyMu.Unlock()
+	if h != nil {
+		h.Stop()
+	}
+}
+
+// History returns the data point history, or nil when it is off.
+func (m *Manager) History() *timeseries.Manager {
+	m.historyMu.Lock()
+	defer m.historyMu.Unlock()
+	return m.history
+}
+
+// HistoryStats reports whether the history is recorded and how much of it.
+func (m *Manager) HistoryStats() map[string]interface{} {
+	if h := m.History(); h != nil {
+		return h.GetStats()
+	}
+	return map[string]interface{}{"enabled": false}
+}
+
+// historySource lets the history read the data points of the proxies. It
+// records them under their names, which is what the MQTT topics and the API
+// use too.
+type historySource struct {
+	m *Manager
+}
+
+func (s historySource) ReadPoints(proxyID string) ([]timeseries.Reading, error) {
+	values, err := s.m.ReadPoints(proxyID)
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]timeseries.Reading, len(values))
+	for i, v := range values {
+		readings[i] = timeseries.Reading{Point: v.Name, Value: v.Value, Err: v.Error}
+	}
+	return readings, nil
+}
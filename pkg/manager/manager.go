@@ -10,12 +10,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"modbridge/pkg/alerting"
+	"modbridge/pkg/audit"
+	"modbridge/pkg/backup"
+	"modbridge/pkg/capture"
+	"modbridge/pkg/cluster"
 	"modbridge/pkg/config"
+	"modbridge/pkg/configsync"
 	"modbridge/pkg/database"
 	"modbridge/pkg/devices"
 	"modbridge/pkg/logger"
+	"modbridge/pkg/mapping"
 	"modbridge/pkg/metrics"
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/mqtt"
+	"modbridge/pkg/notify"
 	"modbridge/pkg/proxy"
+	"modbridge/pkg/rtu"
+	"modbridge/pkg/timeseries"
+	mtls "modbridge/pkg/tls"
+	"sort"
 	"sync"
 	"time"
 )
@@ -27,9 +41,40 @@ type Manager struct {
 	cfgMgr        *config.Manager
 	log           *logger.Logger
 	deviceTracker *devices.Tracker
+	db            *database.DB // Nil without a database; the history then stays off
 	broadcaster   *EventBroadcaster
+	buses         map[string]*rtu.Bus // Shared serial buses by ID, rebuilt by Initialize
+	points        *mapping.Manager    // Data points of every proxy, as stored in the config
 	healthCancel  context.CancelFunc
 	healthWg      sync.WaitGroup
+
+	mqttMu sync.Mutex
+	mqtt   *mqtt.Publisher // Publishes data points to a broker (nil = off)
+
+	historyMu sync.Mutex
+	history   *timeseries.Manager // Records data points in the database (nil = off)
+
+	captures *capture.Manager // On-demand frame captures of the proxies
+
+	backupMu sync.Mutex
+	backups  *backup.Service // Backups of the configuration and the database
+
+	notifyMu      sync.Mutex
+	notifier      *notify.Notifier // Mails events to the operator (nil = off)
+	restartFailed map[string]bool  // Proxies the health monitor could not restart, see proxyStopped
+
+	alertMu sync.Mutex
+	alerts  *alerting.Manager // Evaluates the alert rules (nil without a database)
+
+	clusterMu     sync.RWMutex
+	cluster       *cluster.Cluster // The cluster this node is part of (nil = on its own)
+	clusterCancel context.CancelFunc
+	standby       bool               // Another node of the cluster runs the proxies, see startProxyInstance
+	configSync    *configsync.Syncer // Replicates the configuration to the other nodes (nil = off)
+
+	auditMu    sync.Mutex
+	auditor    *audit.Auditor       // Records refused Modbus requests (nil = not recorded)
+	deniedSeen map[string]time.Time // Last audit entry per refused request kind, see auditDenial
 }
 
 // NewManager creates a manager with database support.
@@ -39,7 +84,13 @@ func NewManager(cfgMgr *config.Manager, log *logger.Logger, db *database.DB) *Ma
 		cfgMgr:        cfgMgr,
 		log:           log,
 		deviceTracker: devices.NewTracker(db),
+		db:            db,
 		broadcaster:   NewEventBroadcaster(),
+		buses:         make(map[string]*rtu.Bus),
+		points:        mapping.NewManager(),
+		captures:      capture.NewManager(captureDir, maxCaptures),
+		deniedSeen:    make(map[string]time.Time),
+		restartFailed: make(map[string]bool),
 	}
 	return m
 }
@@ -49,18 +100,32 @@ func (m *Manager) Initialize() {
 	m.stopHealthMonitor()
 
 	cfg := m.cfgMgr.Get()
+	// In a cluster the proxies wait until this node is the active one.
+	clustered := cfg.Cluster != nil && cfg.Cluster.Enabled
+	if clustered {
+		m.clusterMu.Lock()
+		m.standby = true
+		m.clusterMu.Unlock()
+	}
+	m.loadSerialBuses(cfg.SerialBuses)
 	for _, pCfg := range cfg.Proxies {
 		if err := m.AddProxy(pCfg, false); err != nil {
 			m.log.Error(pCfg.ID, fmt.Sprintf("Failed to add proxy: %v", err))
 			continue
 		}
-		if pCfg.Enabled {
+		if pCfg.Enabled && !clustered {
 			if err := m.StartProxy(pCfg.ID); err != nil {
 				m.log.Error(pCfg.ID, fmt.Sprintf("Failed to start proxy: %v", err))
 			}
 		}
 	}
 
+	m.ReloadCluster()
+	m.ReloadMQTT()
+	m.ReloadHistory()
+	m.ReloadBackups()
+	m.ReloadNotifications()
+	m.startAlerting()
 	m.startHealthMonitor()
 }
 
@@ -78,30 +143,9 @@ func (m *Manager) AddProxy(cfg config.ProxyConfig, save bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	p := proxy.NewProxyInstance(cfg.ID, cfg.Name, cfg.ListenAddr, cfg.TargetAddr, cfg.MaxReadSize, cfg.ConnectionTimeout, cfg.ReadTimeout, cfg.MaxRetries, m.log, m.deviceTracker)
-	if cfg.Protocol != "" {
-		p.Protocol = cfg.Protocol
-	}
-	if cfg.ConnectDelayMs > 0 {
-		p.ConnectDelay = time.Duration(cfg.ConnectDelayMs) * time.Millisecond
-	}
-	if cfg.MaxTargetConns > 0 {
-		p.MaxTargetConns = cfg.MaxTargetConns
-	}
-	if cfg.MinRequestGapMs > 0 {
-		p.MinRequestGap = time.Duration(cfg.MinRequestGapMs) * time.Millisecond
-	}
-	if cfg.RequestTimeoutMs > 0 {
-		p.RequestTimeout = time.Duration(cfg.RequestTimeoutMs) * time.Millisecond
-	}
-	p.CacheEnabled = cfg.CacheEnabled
-	if cfg.CacheTTLMs > 0 {
-		p.CacheTTL = time.Duration(cfg.CacheTTLMs) * time.Millisecond
-	}
-	if cfg.PollIntervalMs > 0 {
-		p.PollInterval = time.Duration(cfg.PollIntervalMs) * time.Millisecond
-	}
+	p := m.newProxyInstance(cfg)
 	m.proxies[cfg.ID] = p
+	m.points.SetMappings(cfg.ID, cfg.Points)
 
 	// Broadcast event
 	m.broadcaster.Broadcast(map[string]interface{}{
@@ -130,26 +174,242 @@ func (m *Manager) AddProxy(cfg config.ProxyConfig, save bool) error {
 	return nil
 }
 
-// RemoveProxy removes a proxy.
-func (m *Manager) RemoveProxy(id string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// newProxyInstance builds a proxy from its stored configuration. Zero values
+// keep the proxy's own defaults.
+func (m *Manager) newProxyInstance(cfg config.ProxyConfig) *proxy.ProxyInstance {
+	p := proxy.NewProxyInstance(cfg.ID, cfg.Name, cfg.ListenAddr, cfg.TargetAddr, cfg.MaxReadSize, cfg.ConnectionTimeout, cfg.ReadTimeout, cfg.MaxRetries, m.log, m.deviceTracker)
+	if cfg.Protocol != "" {
+		p.Protocol = cfg.Protocol
+	}
+	if cfg.Serial != nil {
+		p.Serial = serialLineConfig(cfg.Serial, p.ReadTimeout)
+	}
+	if cfg.BusID != "" {
+		p.BusID = cfg.BusID
+		p.Bus = m.buses[cfg.BusID]
+	}
+	if cfg.Virtual != nil {
+		p.Virtual = m.virtualDevice(cfg.ID, cfg.Virtual)
+	}
+	if cfg.ConnectDelayMs > 0 {
+		p.ConnectDelay = time.Duration(cfg.ConnectDelayMs) * time.Millisecond
+	}
+	if cfg.MaxTargetConns > 0 {
+		p.MaxTargetConns = cfg.MaxTargetConns
+	}
+	if cfg.MinRequestGapMs > 0 {
+		p.MinRequestGap = time.Duration(cfg.MinRequestGapMs) * time.Millisecond
+	}
+	if cfg.RequestTimeoutMs > 0 {
+		p.RequestTimeout = time.Duration(cfg.RequestTimeoutMs) * time.Millisecond
+	}
+	p.CacheEnabled = cfg.CacheEnabled
+	if cfg.CacheTTLMs > 0 {
+		p.CacheTTL = time.Duration(cfg.CacheTTLMs) * time.Millisecond
+	}
+	if cfg.PollIntervalMs > 0 {
+		p.PollInterval = time.Duration(cfg.PollIntervalMs) * time.Millisecond
+	}
+	p.CoalesceReads = cfg.CoalesceReads
+	if cfg.Rewrite != nil {
+		p.Rewrite = rewriteRules(cfg.Rewrite)
+	}
+	if cfg.Policy != nil {
+		p.Policy = accessPolicy(cfg.Policy)
+	}
+	if cfg.RateLimit != nil {
+		p.RateLimit = rateLimit(cfg.RateLimit)
+	}
+	if cfg.HealthProbe != nil {
+		p.HealthProbe = healthProbe(cfg.HealthProbe)
+	}
+	if l := cfg.ListenTLS; l != nil {
+		p.ListenTLS = &mtls.ModbusServerConfig{
+			CertFile:     l.CertFile,
+			KeyFile:      l.KeyFile,
+			ClientCAFile: l.ClientCAFile,
+			ClientAuth:   l.ClientAuth,
+			MinVersion:   l.MinVersion,
+		}
+		if len(l.Authorization) > 0 {
+			p.Authorization = certAuthorization(l.Authorization)
+		}
+	}
+	if t := cfg.TargetTLS; t != nil {
+		p.TargetTLS = &mtls.ModbusClientConfig{
+			CAFile:             t.CAFile,
+			CertFile:           t.CertFile,
+			KeyFile:            t.KeyFile,
+			ServerName:         t.ServerName,
+			InsecureSkipVerify: t.InsecureSkipVerify,
+		}
+	}
+	p.OnDenied = m.auditDenial
+	p.OnRefreshed = func() { m.pointsRefreshed(cfg.ID) }
+	p.OnTargetHealth = func(healthy bool, reason string) { m.targetHealthChanged(cfg, healthy, reason) }
+	p.OnCircuitOpen = func() { m.circuitOpened(cfg) }
+	for _, tc := range cfg.Targets {
+		target := m.newProxyInstance(tc.ProxyConfig(cfg))
+		p.Endpoints = append(p.Endpoints, proxy.NewTargetEndpoint(tc.Weight, target))
+	}
+	// The validator has rejected unknown policies.
+	p.EndpointPolicy, _ = proxy.ParseLoadBalancingPolicy(cfg.TargetPolicy)
+	for _, rc := range cfg.Routes {
+		last := rc.UnitIDLast
+		if last == 0 {
+			last = rc.UnitIDFirst
+		}
+		target := m.newProxyInstance(rc.ProxyConfig(cfg))
+		p.Routes = append(p.Routes, proxy.NewRoute(rc.ID, uint8(rc.UnitIDFirst), uint8(last), uint8(rc.TargetUnitID), target))
+	}
+	// A proxy rebuilt by a config change keeps being captured.
+	if s := m.captures.Active(cfg.ID); s != nil {
+		p.SetCapture(s)
+	}
+	return p
+}
 
-	if _, ok := m.proxies[id]; !ok {
-		return fmt.Errorf("proxy not found")
+// rewriteRules turns stored rewrite rules into the proxy's. The validator has
+// already checked every number against its range.
+func rewriteRules(cfg *config.RewriteConfig) *proxy.RewriteRules {
+	rules := &proxy.RewriteRules{Units: make(map[uint8]uint8, len(cfg.Units))}
+	for _, u := range cfg.Units {
+		rules.Units[uint8(u.From)] = uint8(u.To)
+	}
+	for _, rc := range cfg.Registers {
+		rule := proxy.RegisterRewrite{
+			Start:  uint16(rc.Start),
+			Count:  rc.Count,
+			Offset: rc.AddressOffset(),
+		}
+		for _, fc := range rc.FunctionCodes {
+			rule.Functions = append(rule.Functions, uint8(fc))
+		}
+		for _, unit := range rc.UnitIDs {
+			rule.Units = append(rule.Units, uint8(unit))
+		}
+		rules.Registers = append(rules.Registers, rule)
 	}
+	return rules
+}
 
-	p := m.proxies[id]
-	p.Stop()
-	delete(m.proxies, id)
+// healthProbe turns a stored health probe into the proxy's, filling in the
+// defaults for what was left at zero.
+func healthProbe(cfg *config.HealthProbeConfig) *proxy.HealthProbe {
+	hp := &proxy.HealthProbe{
+		UnitID:           uint8(cfg.UnitID),
+		Function:         modbus.FuncReadHoldingRegisters,
+		Address:          uint16(cfg.Address),
+		Count:            uint16(max(cfg.Count, 1)),
+		MaxLatency:       time.Duration(cfg.MaxLatencyMs) * time.Millisecond,
+		Interval:         time.Duration(cfg.IntervalMs) * time.Millisecond,
+		FailureThreshold: cfg.FailureThreshold,
+	}
+	if cfg.FunctionCode != 0 {
+		hp.Function = uint8(cfg.FunctionCode)
+	}
+	for _, b := range []struct {
+		from *int
+		to   **uint16
+	}{{cfg.Expect, &hp.Expect}, {cfg.Min, &hp.Min}, {cfg.Max, &hp.Max}} {
+		if b.from != nil {
+			v := uint16(*b.from)
+			*b.to = &v
+		}
+	}
+	return hp
+}
 
-	// Broadcast event
-	m.broadcaster.Broadcast(map[string]interface{}{
-		"type":      "proxy_removed",
-		"timestamp": time.Now(),
-		"proxy_id":  id,
-	})
+// serialLineConfig turns stored serial settings into the RTU client's
+// configuration, filling in the defaults of pkg/rtu for anything left at zero.
+// The frame gap follows from the baud rate unless it was set explicitly.
+func serialLineConfig(s *config.SerialConfig, readTimeout time.Duration) *rtu.Config {
+	line := rtu.DefaultConfig()
+	line.Device = s.Device
+	if s.BaudRate > 0 {
+		line.BaudRate = s.BaudRate
+	}
+	if s.DataBits > 0 {
+		line.DataBits = s.DataBits
+	}
+	if s.Parity != "" {
+		line.Parity = s.Parity
+	}
+	if s.StopBits > 0 {
+		line.StopBits = s.StopBits
+	}
+	line.InterFrameDelay = rtu.FrameGap(line.BaudRate)
+	if s.InterFrameDelayMs > 0 {
+		line.InterFrameDelay = time.Duration(s.InterFrameDelayMs) * time.Millisecond
+	}
+	if readTimeout > 0 {
+		line.Timeout = readTimeout
+	}
+	return line
+}
+
+// Defaults of a shared serial bus, applied to zero values in its config.
+const defaultUnitTimeout = time.Second
+
+// loadSerialBuses builds the shared serial buses from the config. Proxies
+// created afterwards pick theirs up by ID; a bus opens its line only when the
+// first of them starts.
+func (m *Manager) loadSerialBuses(cfgs []config.SerialBusConfig) {
+	buses := make(map[string]*rtu.Bus, len(cfgs))
+	for _, b := range cfgs {
+		unitTimeout := defaultUnitTimeout
+		if b.UnitTimeoutMs > 0 {
+			unitTimeout = time.Duration(b.UnitTimeoutMs) * time.Millisecond
+		}
+		serial := b.SerialConfig
+		buses[b.ID] = rtu.NewBus(serialLineConfig(&serial, unitTimeout), rtu.BusOptions{
+			UnitTimeout:      unitTimeout,
+			FailureThreshold: b.UnitFailureThreshold,
+			Backoff:          time.Duration(b.UnitBackoffMs) * time.Millisecond,
+		}, nil)
+	}
 
+	m.mu.Lock()
+	m.buses = buses
+	m.mu.Unlock()
+}
+
+// GetSerialBuses returns the shared serial buses with their per-unit counters.
+func (m *Manager) GetSerialBuses() []map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cfg := m.cfgMgr.Get()
+	res := make([]map[string]interface{}, 0, len(cfg.SerialBuses))
+	for _, b := range cfg.SerialBuses {
+		entry := map[string]interface{}{
+			"id":     b.ID,
+			"name":   b.Name,
+			"device": b.Device,
+			"open":   false,
+			"units":  []rtu.UnitStats{},
+		}
+		if bus, ok := m.buses[b.ID]; ok {
+			entry["open"] = bus.IsOpen()
+			entry["units"] = bus.Units()
+		}
+		users := []string{}
+		for _, p := range cfg.Proxies {
+			if p.Protocol == proxy.ProtocolSerial && p.BusID == b.ID {
+				users = append(users, p.ID)
+			}
+		}
+		entry["proxies"] = users
+		res = append(res, entry)
+	}
+	return res
+}
+
+// RemoveProxy removes a proxy.
+func (m *Manager) RemoveProxy(id string) error {
+	if !m.dropProxy(id) {
+		return fmt.Errorf("proxy not found")
+	}
 	return m.cfgMgr.Update(func(c *config.Config) error {
 		newProxies := make([]config.ProxyConfig, 0, len(c.Proxies))
 		for _, pc := range c.Proxies {
@@ -162,6 +422,30 @@ func (m *Manager) RemoveProxy(id string) error {
 	})
 }
 
+// dropProxy stops a proxy and forgets it, leaving the stored configuration
+// alone. It reports whether there was one.
+func (m *Manager) dropProxy(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proxies[id]
+	if !ok {
+		return false
+	}
+	p.Stop()
+	delete(m.proxies, id)
+	m.points.RemoveProxy(id)
+	m.captures.Stop(id)
+
+	// Broadcast event
+	m.broadcaster.Broadcast(map[string]interface{}{
+		"type":      "proxy_removed",
+		"timestamp": time.Now(),
+		"proxy_id":  id,
+	})
+	return true
+}
+
 // StartProxy starts a proxy.
 func (m *Manager) StartProxy(id string) error {
 	m.mu.Lock()
@@ -172,7 +456,7 @@ func (m *Manager) StartProxy(id string) error {
 		return fmt.Errorf("proxy not found")
 	}
 
-	if err := p.Start(); err != nil {
+	if err := m.startProxyInstance(p); err != nil {
 		return err
 	}
 
@@ -284,7 +568,7 @@ func (m *Manager) ResumeProxy(id string) error {
 	}
 
 	// Start the proxy and set paused=false
-	if err := p.Start(); err != nil {
+	if err := m.startProxyInstance(p); err != nil {
 		return err
 	}
 
@@ -310,6 +594,17 @@ func (m *Manager) UpdateProxy(cfg config.ProxyConfig) error {
 		return fmt.Errorf("proxy not found")
 	}
 
+	// Data points have endpoints of their own. An edit of the proxy that
+	// leaves them out keeps the stored ones instead of dropping them.
+	if cfg.Points == nil {
+		for _, pc := range m.cfgMgr.Get().Proxies {
+			if pc.ID == cfg.ID {
+				cfg.Points = pc.Points
+				break
+			}
+		}
+	}
+
 	// Stop the old proxy without holding the lock
 	old.Stop()
 
@@ -317,34 +612,13 @@ func (m *Manager) UpdateProxy(cfg config.ProxyConfig) error {
 	defer m.mu.Unlock()
 
 	// Create new proxy with updated config
-	p := proxy.NewProxyInstance(cfg.ID, cfg.Name, cfg.ListenAddr, cfg.TargetAddr, cfg.MaxReadSize, cfg.ConnectionTimeout, cfg.ReadTimeout, cfg.MaxRetries, m.log, m.deviceTracker)
-	if cfg.Protocol != "" {
-		p.Protocol = cfg.Protocol
-	}
-	if cfg.ConnectDelayMs > 0 {
-		p.ConnectDelay = time.Duration(cfg.ConnectDelayMs) * time.Millisecond
-	}
-	if cfg.MaxTargetConns > 0 {
-		p.MaxTargetConns = cfg.MaxTargetConns
-	}
-	if cfg.MinRequestGapMs > 0 {
-		p.MinRequestGap = time.Duration(cfg.MinRequestGapMs) * time.Millisecond
-	}
-	if cfg.RequestTimeoutMs > 0 {
-		p.RequestTimeout = time.Duration(cfg.RequestTimeoutMs) * time.Millisecond
-	}
-	p.CacheEnabled = cfg.CacheEnabled
-	if cfg.CacheTTLMs > 0 {
-		p.CacheTTL = time.Duration(cfg.CacheTTLMs) * time.Millisecond
-	}
-	if cfg.PollIntervalMs > 0 {
-		p.PollInterval = time.Duration(cfg.PollIntervalMs) * time.Millisecond
-	}
+	p := m.newProxyInstance(cfg)
 	m.proxies[cfg.ID] = p
+	m.points.SetMappings(cfg.ID, cfg.Points)
 
-	// Start if it was enabled and not paused
+	// Start if it was enabled and not paused, and this node runs the proxies
 	if cfg.Enabled && !cfg.Paused {
-		if err := p.Start(); err != nil {
+		if err := m.startProxyInstance(p); err != nil && !errors.Is(err, ErrStandby) {
 			return fmt.Errorf("proxy %s failed to start after update: %w", cfg.ID, err)
 		}
 	}
@@ -378,18 +652,105 @@ func (m *Manager) ProxyDiagnostics() map[string]metrics.ProxyDiagnostics {
 	out := make(map[string]metrics.ProxyDiagnostics, len(m.proxies))
 	for id, p := range m.proxies {
 		cache := p.CacheStats()
-		polled, _, _ := p.PollerStats()
+		polled, refreshes, failures := p.PollerStats()
+		latency := p.LatencyHistogram()
+		active, idle := p.TargetConns()
 		out[id] = metrics.ProxyDiagnostics{
+			Running:    p.Stats.GetStatus() == "Running",
+			Requests:   p.Stats.Requests.Load(),
+			Errors:     p.Stats.Errors.Load(),
+			Exceptions: p.ExceptionCounts(),
+			Latency: metrics.LatencyHistogram{
+				Bounds: latency.Bounds,
+				Counts: latency.Counts,
+				Count:  latency.Count,
+				Sum:    latency.Sum,
+			},
+			LatencyP95:     p.LatencyPercentiles().P95,
 			StaleResponses: p.StaleResponses(),
+			Coalesced:      p.Stats.Coalesced.Load(),
 			CacheHits:      cache.Hits,
 			CacheMisses:    cache.Misses,
 			CacheEntries:   cache.Size,
 			PolledRequests: polled,
+			PollRefreshes:  refreshes,
+			PollFailures:   failures,
+			CircuitState:   p.CircuitState(),
+			PacingWait:     p.PacingWait(),
+			ClientConns:    p.Stats.ActiveConns.Load(),
+			TargetActive:   active,
+			TargetIdle:     idle,
+			Certificates:   certificateExpiry(p.Certificates()),
 		}
 	}
 	return out
 }
 
+// certificateExpiry returns when each certificate of a proxy expires, by use.
+// Of two with the same use, the earlier counts.
+func certificateExpiry(certs []proxy.CertificateStatus) map[string]time.Time {
+	if len(certs) == 0 {
+		return nil
+	}
+	out := make(map[string]time.Time, len(certs))
+	for _, c := range certs {
+		if t, ok := out[c.Use]; !ok || c.NotAfter.Before(t) {
+			out[c.Use] = c.NotAfter
+		}
+	}
+	return out
+}
+
+// ProbedTargets returns the verdict of every running proxy that checks its
+// target with a health probe, keyed by proxy ID. Proxies with only the TCP
+// connect check are left out: a connect says too little to hold readiness on.
+func (m *Manager) ProbedTargets() map[string]proxy.HealthStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]proxy.HealthStatus)
+	for id, p := range m.proxies {
+		if p.HealthProbe == nil {
+			continue
+		}
+		if hs, ok := p.Health(); ok {
+			out[id] = hs
+		}
+	}
+	return out
+}
+
+// Sessions returns the live client connections of every proxy, ordered by
+// proxy and then by age.
+func (m *Manager) Sessions() []proxy.SessionInfo {
+	m.mu.RLock()
+	proxies := make([]*proxy.ProxyInstance, 0, len(m.proxies))
+	for _, p := range m.proxies {
+		proxies = append(proxies, p)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(proxies, func(i, j int) bool { return proxies[i].ID < proxies[j].ID })
+	out := make([]proxy.SessionInfo, 0)
+	for _, p := range proxies {
+		out = append(out, p.Sessions()...)
+	}
+	return out
+}
+
+// KickSession ends a client session, whichever proxy holds it, and returns
+// that proxy's ID. ok is false when no proxy has the session.
+func (m *Manager) KickSession(id string) (proxyID string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for pid, p := range m.proxies {
+		if p.KickSession(id) {
+			return pid, true
+		}
+	}
+	return "", false
+}
+
 // GetProxyInstance returns the running instance for a proxy ID.
 func (m *Manager) GetProxyInstance(id string) (*proxy.ProxyInstance, bool) {
 	m.mu.RLock()
@@ -433,6 +794,10 @@ func (m *Manager) GetProxies() []map[string]interface{} {
 		}
 
 		pCfg := cfgMap[p.ID]
+		var health *proxy.HealthStatus
+		if hs, ok := p.Health(); ok {
+			health = &hs
+		}
 
 		// Normalize tags so the JSON response never carries a bare null that
 		// would break frontend form round-trips (Chips v-model expects an array).
@@ -452,6 +817,10 @@ func (m *Manager) GetProxies() []map[string]interface{} {
 			"uptime_s":           uptime.Seconds(),
 			"requests":           status.Requests.Load(),
 			"errors":             status.Errors.Load(),
+			"denied":             status.Denied.Load(),
+			"limited":            status.Limited.Load(),
+			"coalesced":          status.Coalesced.Load(),
+			"queued_requests":    p.QueuedRequests(),
 			"active_connections": status.ActiveConns.Load(),
 			"latency_mean_ms":    latency.Mean.Seconds() * 1000,
 			"latency_p50_ms":     latency.P50.Seconds() * 1000,
@@ -473,12 +842,30 @@ func (m *Manager) GetProxies() []map[string]interface{} {
 			"cache_enabled":      pCfg.CacheEnabled,
 			"cache_ttl_ms":       pCfg.CacheTTLMs,
 			"poll_interval_ms":   pCfg.PollIntervalMs,
+			"coalesce_reads":     pCfg.CoalesceReads,
 			"cache_hits":         cacheStats.Hits,
 			"cache_misses":       cacheStats.Misses,
 			"cache_entries":      cacheStats.Size,
 			"polled_requests":    polledRequests,
 			"tags":               tags,
 			"protocol":           pCfg.Protocol,
+			"serial":             pCfg.Serial,
+			"bus_id":             pCfg.BusID,
+			"virtual":            pCfg.Virtual,
+			"virtual_stats":      p.VirtualStats(),
+			"targets":            pCfg.Targets,
+			"target_policy":      pCfg.TargetPolicy,
+			"endpoint_stats":     p.EndpointStats(),
+			"routes":             pCfg.Routes,
+			"route_stats":        p.RouteStats(),
+			"rewrite":            pCfg.Rewrite,
+			"policy":             pCfg.Policy,
+			"rate_limit":         pCfg.RateLimit,
+			"health_probe":       pCfg.HealthProbe,
+			"health":             health,
+			"listen_tls":         pCfg.ListenTLS,
+			"target_tls":         pCfg.TargetTLS,
+			"certificates":       p.Certificates(),
 		})
 	}
 	return res
@@ -486,7 +873,14 @@ func (m *Manager) GetProxies() []map[string]interface{} {
 
 // StopAll stops all running proxies and cleans up resources.
 func (m *Manager) StopAll() {
+	m.stopCluster()
 	m.stopHealthMonitor()
+	m.stopMQTT()
+	m.stopHistory()
+	m.stopBackups()
+	m.stopAlerting()
+	m.stopNotifications()
+	m.captures.StopAll()
 
 	m.mu.Lock()
 	var wg sync.WaitGroup
@@ -508,7 +902,7 @@ func (m *Manager) StopAll() {
 	m.mu.Unlock()
 }
 
-// StartAll starts all enabled proxies.
+// StartAll starts all enabled proxies, unless this node stands by.
 func (m *Manager) StartAll() {
 	m.mu.Lock()
 	proxies := make([]*proxy.ProxyInstance, 0, len(m.proxies))
@@ -526,7 +920,11 @@ func (m *Manager) StartAll() {
 	for _, p := range proxies {
 		pCfg := cfgMap[p.ID]
 		if pCfg.Enabled && !pCfg.Paused && p.Stats.GetStatus() != "Running" {
-			if err := p.Start(); err != nil {
+			err := m.startProxyInstance(p)
+			if errors.Is(err, ErrStandby) {
+				return
+			}
+			if err != nil {
 				m.log.Error(p.ID, fmt.Sprintf("Failed to start: %v", err))
 			}
 		}
@@ -586,7 +984,11 @@ func (m *Manager) stopHealthMonitor() {
 
 // checkAndRestartProxies checks all proxies that should be running and restarts
 // them if they have unexpectedly stopped (not paused or intentionally disabled).
+// A node that stands by leaves them stopped.
 func (m *Manager) checkAndRestartProxies() {
+	if m.Standby() {
+		return
+	}
 	cfg := m.cfgMgr.Get()
 	cfgMap := make(map[string]config.ProxyConfig)
 	for _, pc := range cfg.Proxies {
@@ -612,11 +1014,16 @@ func (m *Manager) checkAndRestartProxies() {
 		}
 
 		m.log.Info(id, "Health monitor: proxy unexpectedly stopped, attempting restart")
-		if err := p.Start(); err != nil {
+		err := m.startProxyInstance(p)
+		if errors.Is(err, ErrStandby) {
+			return // Fenced meanwhile
+		}
+		if err != nil {
 			m.log.Error(id, fmt.Sprintf("Health monitor: restart failed: %v", err))
 		} else {
 			m.log.Info(id, "Health monitor: proxy restarted successfully")
 		}
+		m.proxyStopped(cfgMap[id], err)
 	}
 }
 
@@ -664,6 +1071,10 @@ func (m *Manager) getProxyStatusLocked(id string) map[string]interface{} {
 	}
 
 	pCfg := cfgMap[p.ID]
+	var health *proxy.HealthStatus
+	if hs, ok := p.Health(); ok {
+		health = &hs
+	}
 
 	tags := pCfg.Tags
 	if tags == nil {
@@ -681,6 +1092,10 @@ func (m *Manager) getProxyStatusLocked(id string) map[string]interface{} {
 		"uptime_s":           uptime.Seconds(),
 		"requests":           status.Requests.Load(),
 		"errors":             status.Errors.Load(),
+		"denied":             status.Denied.Load(),
+		"limited":            status.Limited.Load(),
+		"coalesced":          status.Coalesced.Load(),
+		"queued_requests":    p.QueuedRequests(),
 		"active_connections": status.ActiveConns.Load(),
 		"latency_mean_ms":    latency.Mean.Seconds() * 1000,
 		"latency_p50_ms":     latency.P50.Seconds() * 1000,
@@ -702,11 +1117,29 @@ func (m *Manager) getProxyStatusLocked(id string) map[string]interface{} {
 		"cache_enabled":      pCfg.CacheEnabled,
 		"cache_ttl_ms":       pCfg.CacheTTLMs,
 		"poll_interval_ms":   pCfg.PollIntervalMs,
+		"coalesce_reads":     pCfg.CoalesceReads,
 		"cache_hits":         cacheStats.Hits,
 		"cache_misses":       cacheStats.Misses,
 		"cache_entries":      cacheStats.Size,
 		"polled_requests":    polledRequests,
 		"tags":               tags,
 		"protocol":           pCfg.Protocol,
+		"serial":             pCfg.Serial,
+		"bus_id":             pCfg.BusID,
+		"virtual":            pCfg.Virtual,
+		"virtual_stats":      p.VirtualStats(),
+		"targets":            pCfg.Targets,
+		"target_policy":      pCfg.TargetPolicy,
+		"endpoint_stats":     p.EndpointStats(),
+		"routes":             pCfg.Routes,
+		"route_stats":        p.RouteStats(),
+		"rewrite":            pCfg.Rewrite,
+		"policy":             pCfg.Policy,
+		"rate_limit":         pCfg.RateLimit,
+		"health_probe":       pCfg.HealthProbe,
+		"health":             health,
+		"listen_tls":         pCfg.ListenTLS,
+		"target_tls":         pCfg.TargetTLS,
+		"certificates":       p.Certificates(),
 	}
 }
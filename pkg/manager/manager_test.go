@@ -6,6 +6,7 @@
 package manager
 
 import (
+	"path/filepath"
 	"testing"
 
 	"modbridge/pkg/config"
@@ -13,7 +14,7 @@ import (
 )
 
 func TestNewManager(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
@@ -34,12 +35,12 @@ func TestNewManager(t *testing.T) {
 }
 
 func TestAddProxy(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
 	defer log.Close()
-	cfgMgr := config.NewManager("test.json")
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "test.json"))
 	m := NewManager(cfgMgr, log, nil)
 
 	cfg := config.ProxyConfig{
@@ -70,12 +71,12 @@ func TestAddProxy(t *testing.T) {
 }
 
 func TestRemoveProxy(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
 	defer log.Close()
-	cfgMgr := config.NewManager("test.json")
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "test.json"))
 	m := NewManager(cfgMgr, log, nil)
 
 	cfg := config.ProxyConfig{
@@ -103,12 +104,12 @@ func TestRemoveProxy(t *testing.T) {
 }
 
 func TestRemoveProxyNotFound(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
 	defer log.Close()
-	cfgMgr := config.NewManager("test.json")
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "test.json"))
 	m := NewManager(cfgMgr, log, nil)
 
 	err = m.RemoveProxy("non-existent-id")
@@ -118,12 +119,12 @@ func TestRemoveProxyNotFound(t *testing.T) {
 }
 
 func TestGetProxies(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
 	defer log.Close()
-	cfgMgr := config.NewManager("test.json")
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "test.json"))
 	m := NewManager(cfgMgr, log, nil)
 
 	cfg := config.ProxyConfig{
@@ -149,12 +150,12 @@ func TestGetProxies(t *testing.T) {
 }
 
 func TestGetProxyStatus(t *testing.T) {
-	log, err := logger.NewLogger("test_logs", 100)
+	log, err := logger.NewLogger(t.TempDir(), 100)
 	if err != nil {
 		t.Fatalf("Failed to create logger: %v", err)
 	}
 	defer log.Close()
-	cfgMgr := config.NewManager("test.json")
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "test.json"))
 	m := NewManager(cfgMgr, log, nil)
 
 	cfg := config.ProxyConfig{
@@ -0,0 +1,184 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/mqtt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReloadMQTT starts, restarts or stops the MQTT publisher to match the
+// stored configuration.
+func (m *Manager) ReloadMQTT() {
+	m.stopMQTT()
+
+	cfg := m.cfgMgr.Get().MQTT
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	pubCfg, err := mqttPublisherConfig(cfg)
+	if err != nil {
+		m.log.Error("MQTT", fmt.Sprintf("Publisher not started: %v", err))
+		return
+	}
+	pub := mqtt.NewPublisher(pubCfg, mqttSource{m: m, clientID: pubCfg.ClientID, broker: pubCfg.Addr}, m.log)
+	pub.Start()
+
+	m.mqttMu.Lock()
+	m.mqtt = pub
+	m.mqttMu.Unlock()
+}
+
+// stopMQTT stops the publisher, if one runs.
+func (m *Manager) stopMQTT() {
+	m.mqttMu.Lock()
+	pub := m.mqtt
+	m.mqtt = nil
+	m.mqttMu.Unlock()
+	if pub != nil {
+		pub.Stop()
+	}
+}
+
+// MQTTStats reports whether the publisher is enabled and connected, and how
+// many values it has published.
+func (m *Manager) MQTTStats() map[string]interface{} {
+	m.mqttMu.Lock()
+	pub := m.mqtt
+	m.mqttMu.Unlock()
+	if pub == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	connected, published := pub.Stats()
+	return map[string]interface{}{"enabled": true, "connected": connected, "published": published}
+}
+
+// pointsRefreshed is called by a proxy's poller after every round.
+func (m *Manager) pointsRefreshed(proxyID string) {
+	m.mqttMu.Lock()
+	pub := m.mqtt
+	m.mqttMu.Unlock()
+	if pub != nil {
+		pub.Refreshed(proxyID)
+	}
+	if h := m.History(); h != nil {
+		h.Refreshed(proxyID)
+	}
+}
+
+// mqttPublisherConfig turns the stored MQTT settings into the publisher's.
+func mqttPublisherConfig(cfg *config.MQTTConfig) (mqtt.PublisherConfig, error) {
+	addr, useTLS, err := cfg.BrokerAddr()
+	if err != nil {
+		return mqtt.PublisherConfig{}, err
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "modbridge-" + host
+	}
+	opts := mqtt.Options{
+		Addr:            addr,
+		ProtocolVersion: byte(cfg.ProtocolVersion),
+		ClientID:        clientID,
+		Username:        cfg.Username,
+		Password:        cfg.Password,
+		KeepAlive:       time.Duration(cfg.KeepAliveMs) * time.Millisecond,
+	}
+	if useTLS {
+		opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.TLSInsecure} // #nosec G402 -- opt-in for self-signed brokers
+	}
+	return mqtt.PublisherConfig{
+		Options:         opts,
+		TopicPrefix:     cfg.TopicPrefix,
+		QoS:             byte(cfg.QoS),
+		Retain:          cfg.Retain,
+		Interval:        time.Duration(cfg.PublishIntervalMs) * time.Millisecond,
+		Discovery:       cfg.Discovery,
+		DiscoveryPrefix: cfg.DiscoveryPrefix,
+	}, nil
+}
+
+// mqttSource lets the publisher read and write the data points of the
+// proxies.
+type mqttSource struct {
+	m        *Manager
+	clientID string // Our own client ID and broker, which name the writer in the audit log
+	broker   string
+}
+
+func (s mqttSource) PointProxies() []mqtt.ProxyRef {
+	var refs []mqtt.ProxyRef
+	for _, pc := range s.m.cfgMgr.Get().Proxies {
+		if len(pc.Points) > 0 {
+			refs = append(refs, mqtt.ProxyRef{ID: pc.ID, Name: pc.Name})
+		}
+	}
+	return refs
+}
+
+func (s mqttSource) ReadPoints(proxyID string) ([]mqtt.Reading, error) {
+	values, err := s.m.ReadPoints(proxyID)
+	if err != nil {
+		return nil, err
+	}
+	readings := make([]mqtt.Reading, len(values))
+	for i, v := range values {
+		readings[i] = mqtt.Reading{Point: v.Mapping, Value: v.Value, Err: v.Error}
+		if v.Writable {
+			readings[i].Min, readings[i].Max, _ = s.m.points.ValueRange(&v.Mapping)
+		}
+	}
+	return readings, nil
+}
+
+// WritePoint writes a point on an MQTT command. The payload is the plain
+// value, as it is published. Every attempt on an existing proxy is audited:
+// the broker does not say who sent a message, so the entry names the
+// session it came in on.
+func (s mqttSource) WritePoint(proxyID, point string, payload []byte) error {
+	text := strings.TrimSpace(string(payload))
+	var value interface{} = text
+	if pt, ok := s.m.points.GetMapping(proxyID, point); ok && pt.DataType != "string" {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			value = nil // EncodeValue refuses it below
+		} else {
+			value = f
+		}
+	}
+
+	pt, regs, err := s.m.WritePoint(proxyID, point, value)
+	if errors.Is(err, ErrPointNotFound) {
+		return err
+	}
+
+	s.m.auditMu.Lock()
+	a := s.m.auditor
+	s.m.auditMu.Unlock()
+	if a != nil {
+		details := fmt.Sprintf("point %s = %q", point, text)
+		if err == nil {
+			details += fmt.Sprintf(", unit %d, registers %d-%d", pt.UnitID, pt.RegisterAddress, pt.RegisterAddress+len(regs)-1)
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		a.LogAction("proxy.point.written", "proxy", proxyID, "", "mqtt:"+s.clientID, details, s.broker, "", err == nil, errMsg)
+	}
+	if err == nil {
+		s.m.log.Info(proxyID, fmt.Sprintf("Point %s set to %s over MQTT", point, text))
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"modbridge/pkg/audit"
+	"modbridge/pkg/config"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"modbridge/pkg/mapping"
+	"modbridge/pkg/proxy"
+)
+
+func TestMQTTWriteRefusedByReadOnlyPolicy(t *testing.T) {
+	log, err := logger.NewLogger(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer log.Close()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	// A free port for the proxy to listen on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	listenAddr := l.Addr().String()
+	l.Close()
+
+	cfgMgr := config.NewManager(filepath.Join(t.TempDir(), "config.json"))
+	err = cfgMgr.Update(func(c *config.Config) error {
+		c.Proxies = []config.ProxyConfig{{
+			ID:         "inverter",
+			Name:       "Inverter",
+			ListenAddr: listenAddr,
+			Protocol:   "virtual",
+			Virtual:    &config.VirtualConfig{Registers: []config.VirtualRegisterConfig{{Address: 10, Value: 3}}},
+			Policy:     &config.PolicyConfig{PolicyRuleConfig: config.PolicyRuleConfig{ReadOnly: true}},
+			Points: []mapping.Mapping{{
+				ID: "limit", Name: "limit", UnitID: 1, RegisterAddress: 10, DataType: "uint16", Enabled: true, Writable: true,
+			}},
+			Enabled: true,
+		}}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	m := NewManager(cfgMgr, log, nil)
+	a := audit.NewAuditor(db)
+	m.SetAuditor(a)
+	m.Initialize()
+	defer m.StopAll()
+
+	source := mqttSource{m: m, clientID: "modbridge", broker: "broker:1883"}
+	if err := source.WritePoint("inverter", "limit", []byte("50")); !errors.Is(err, proxy.ErrDenied) {
+		t.Fatalf("WritePoint() error = %v, want the access policy's refusal", err)
+	}
+	p, _ := m.GetProxyInstance("inverter")
+	if s := p.VirtualStats(); s == nil || s.Writes != 0 {
+		t.Errorf("device stats = %+v, want no write", s)
+	}
+	if v, err := m.ReadPoint("inverter", "limit"); err != nil || v.Value != 3.0 {
+		t.Errorf("point after the refused write = %+v (%v), want 3", v, err)
+	}
+
+	a.Close()
+	entries, err := db.GetAuditLogs(10, 0)
+	if err != nil {
+		t.Fatalf("GetAuditLogs: %v", err)
+	}
+	for _, e := range entries {
+		if e.Action == "proxy.point.written" {
+			if e.Success || e.ErrorMsg == "" {
+				t.Errorf("audit entry = %+v, want a failure with the reason", e)
+			}
+			return
+		}
+	}
+	t.Errorf("no audit entry for the refused write in %+v", entries)
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/notify"
+	"strings"
+	"time"
+)
+
+// ReloadNotifications starts, restarts or stops the mail notifier to match
+// the stored configuration.
+func (m *Manager) ReloadNotifications() {
+	m.stopNotifications()
+
+	cfg := m.cfgMgr.Get()
+	if !cfg.EmailEnabled {
+		return
+	}
+	n := notify.New(notifyConfig(&cfg), m.log)
+	n.Start()
+
+	m.notifyMu.Lock()
+	m.notifier = n
+	m.notifyMu.Unlock()
+}
+
+// stopNotifications stops the notifier, if one runs.
+func (m *Manager) stopNotifications() {
+	m.notifyMu.Lock()
+	n := m.notifier
+	m.notifier = nil
+	m.notifyMu.Unlock()
+	if n != nil {
+		n.Stop()
+	}
+}
+
+// Notify reports an event to the operator. Without mail settings it goes
+// nowhere.
+func (m *Manager) Notify(ev notify.Event) {
+	m.notifyMu.Lock()
+	n := m.notifier
+	m.notifyMu.Unlock()
+	if n != nil {
+		n.Notify(ev)
+	}
+}
+
+// NotificationsEnabled reports whether events are mailed.
+func (m *Manager) NotificationsEnabled() bool {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+	return m.notifier != nil
+}
+
+// NotificationStats reports whether mail alerts are on and how many mails
+// went out.
+func (m *Manager) NotificationStats() map[string]interface{} {
+	m.notifyMu.Lock()
+	n := m.notifier
+	m.notifyMu.Unlock()
+	if n == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{"enabled": true, "email": n.Stats()}
+}
+
+// SendTestMail mails a test message with the stored settings. It works
+// before alerts are switched on, so the settings can be tried first.
+func (m *Manager) SendTestMail(ctx context.Context) error {
+	cfg := m.cfgMgr.Get()
+	if cfg.EmailSMTPServer == "" || cfg.EmailTo == "" {
+		return errors.New("no SMTP server or recipient configured")
+	}
+	cfg.EmailEnabled = true
+	if err := config.ValidateEmailConfig(&cfg); err != nil {
+		return err
+	}
+	return notify.New(notifyConfig(&cfg), m.log).SendTest(ctx)
+}
+
+// notifyConfig turns the stored mail settings into the notifier's.
+func notifyConfig(cfg *config.Config) notify.Config {
+	var to []string
+	for _, addr := range strings.Split(cfg.EmailTo, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return notify.Config{
+		SMTP: notify.SMTPConfig{
+			Host:     cfg.EmailSMTPServer,
+			Port:     cfg.EmailSMTPPort,
+			Security: cfg.EmailSecurity,
+			Username: cfg.EmailUsername,
+			Password: cfg.EmailPassword,
+			From:     cfg.EmailFrom,
+			To:       to,
+		},
+		OnError:   cfg.EmailAlertOnError,
+		OnWarning: cfg.EmailAlertOnWarning,
+		Throttle:  time.Duration(cfg.EmailThrottleMinutes) * time.Minute,
+	}
+}
+
+// proxyLabel names a proxy in a notification.
+func proxyLabel(cfg config.ProxyConfig) string {
+	if cfg.Name == "" || cfg.Name == cfg.ID {
+		return cfg.ID
+	}
+	return fmt.Sprintf("%s (%s)", cfg.Name, cfg.ID)
+}
+
+// targetHealthChanged is called by a proxy's health check when its target
+// stops answering or answers again.
+func (m *Manager) targetHealthChanged(cfg config.ProxyConfig, healthy bool, reason string) {
+	ev := notify.Event{ProxyID: cfg.ID, Subject: cfg.TargetAddr}
+	if healthy {
+		ev.Kind, ev.Severity = notify.ProxyUp, notify.SeverityInfo
+		ev.Title = fmt.Sprintf("%s: target %s is back", proxyLabel(cfg), cfg.TargetAddr)
+		ev.Message = fmt.Sprintf("The target %s of proxy %s answers again.", cfg.TargetAddr, proxyLabel(cfg))
+	} else {
+		ev.Kind, ev.Severity = notify.ProxyDown, notify.SeverityError
+		ev.Title = fmt.Sprintf("%s: target %s is down", proxyLabel(cfg), cfg.TargetAddr)
+		ev.Message = fmt.Sprintf("The target %s of proxy %s does not answer: %s", cfg.TargetAddr, proxyLabel(cfg), reason)
+	}
+	m.Notify(ev)
+}
+
+// circuitOpened is called when a proxy's circuit breaker opens.
+func (m *Manager) circuitOpened(cfg config.ProxyConfig) {
+	m.Notify(notify.Event{
+		Kind:     notify.CircuitOpen,
+		Severity: notify.SeverityError,
+		ProxyID:  cfg.ID,
+		Subject:  cfg.TargetAddr,
+		Title:    fmt.Sprintf("%s: circuit breaker opened", proxyLabel(cfg)),
+		Message: fmt.Sprintf("Requests of proxy %s to %s failed repeatedly. Clients get a gateway exception "+
+			"at once until the target answers again.", proxyLabel(cfg), cfg.TargetAddr),
+	})
+}
+
+// proxyStopped reports a proxy found stopped that should run, and whether
+// restarting it worked. One that is back after a failed restart is
+// reported once more. Only the health monitor calls it, so restartFailed
+// needs no lock.
+func (m *Manager) proxyStopped(cfg config.ProxyConfig, restartErr error) {
+	ev := notify.Event{
+		Kind:     notify.ProxyDown,
+		Severity: notify.SeverityError,
+		ProxyID:  cfg.ID,
+		Subject:  cfg.ListenAddr,
+		Title:    fmt.Sprintf("%s stopped unexpectedly", proxyLabel(cfg)),
+		Message:  fmt.Sprintf("Proxy %s on %s was not running although it is enabled. ", proxyLabel(cfg), cfg.ListenAddr),
+	}
+	failedBefore := m.restartFailed[cfg.ID]
+	if restartErr != nil {
+		m.restartFailed[cfg.ID] = true
+		ev.Message += fmt.Sprintf("Restarting it failed: %v", restartErr)
+		m.Notify(ev)
+		return
+	}
+	delete(m.restartFailed, cfg.ID)
+	if failedBefore {
+		ev.Kind, ev.Severity = notify.ProxyUp, notify.SeverityInfo
+		ev.Title = fmt.Sprintf("%s runs again", proxyLabel(cfg))
+		ev.Message = fmt.Sprintf("Proxy %s on %s was restarted and accepts clients again.", proxyLabel(cfg), cfg.ListenAddr)
+	} else {
+		ev.Message += "It was restarted."
+	}
+	m.Notify(ev)
+}
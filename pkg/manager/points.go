@@ -0,0 +1,187 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"modbridge/pkg/config"
+	"modbridge/pkg/mapping"
+	"modbridge/pkg/proxy"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrPointNotFound is returned for a data point a proxy does not have.
+var ErrPointNotFound = errors.New("point not found")
+
+// ErrPointNotWritable is returned for a write to a data point that is not
+// marked writable, or is disabled.
+var ErrPointNotWritable = errors.New("point is not writable")
+
+// PointValue is a data point together with its current value. Error is set
+// instead of Value when the registers could not be read or decoded.
+type PointValue struct {
+	mapping.Mapping
+	Value  interface{} `json:"value"`
+	Error  string      `json:"error,omitempty"`
+	ReadAt time.Time   `json:"read_at"`
+}
+
+// GetPoints returns the data points of a proxy without reading them.
+func (m *Manager) GetPoints(proxyID string) ([]mapping.Mapping, error) {
+	if _, ok := m.GetProxyInstance(proxyID); !ok {
+		return nil, fmt.Errorf("proxy not found")
+	}
+	stored := m.points.GetMappings(proxyID)
+	points := make([]mapping.Mapping, len(stored))
+	for i, pt := range stored {
+		points[i] = pt.Clone()
+	}
+	return points, nil
+}
+
+// ReadPoints reads and decodes every data point of a proxy. A point that
+// fails does not fail the others; its error is reported with it.
+func (m *Manager) ReadPoints(proxyID string) ([]PointValue, error) {
+	p, ok := m.GetProxyInstance(proxyID)
+	if !ok {
+		return nil, fmt.Errorf("proxy not found")
+	}
+	stored := m.points.GetMappings(proxyID)
+	values := make([]PointValue, len(stored))
+	for i, pt := range stored {
+		values[i] = m.readPoint(p, pt)
+	}
+	return values, nil
+}
+
+// ReadPoint reads and decodes one data point of a proxy, named by ID or name.
+func (m *Manager) ReadPoint(proxyID, idOrName string) (*PointValue, error) {
+	p, ok := m.GetProxyInstance(proxyID)
+	if !ok {
+		return nil, fmt.Errorf("proxy not found")
+	}
+	pt, ok := m.points.GetMapping(proxyID, idOrName)
+	if !ok {
+		return nil, ErrPointNotFound
+	}
+	value := m.readPoint(p, pt)
+	return &value, nil
+}
+
+// readPoint reads one data point through its proxy. The read takes the
+// proxy's cache, so points of a proxy that caches and polls are answered
+// without a round trip to the device.
+func (m *Manager) readPoint(p *proxy.ProxyInstance, pt *mapping.Mapping) PointValue {
+	value := PointValue{Mapping: pt.Clone(), ReadAt: time.Now()}
+	if !pt.Enabled {
+		return value
+	}
+	regs, err := p.ReadRegisters(uint8(pt.UnitID), pt.FunctionCode(), uint16(pt.RegisterAddress), uint16(pt.Registers()))
+	if err != nil {
+		value.Error = err.Error()
+		return value
+	}
+	if value.Value, err = m.points.TransformValue(pt, regs); err != nil {
+		value.Error = err.Error()
+	}
+	return value
+}
+
+// WritePoint sets a data point of a proxy, named by ID or name, to a value in
+// the point's units: a float64 for a number, a string for a string. The value
+// is encoded with the inverse of the point's transform and held to its
+// limits. It returns the point and the registers written.
+func (m *Manager) WritePoint(proxyID, idOrName string, value interface{}) (mapping.Mapping, []uint16, error) {
+	p, ok := m.GetProxyInstance(proxyID)
+	if !ok {
+		return mapping.Mapping{}, nil, fmt.Errorf("proxy not found")
+	}
+	pt, ok := m.points.GetMapping(proxyID, idOrName)
+	if !ok {
+		return mapping.Mapping{}, nil, ErrPointNotFound
+	}
+	if !pt.Enabled || !pt.Writable {
+		return pt.Clone(), nil, ErrPointNotWritable
+	}
+	regs, err := m.points.EncodeValue(pt, value)
+	if err != nil {
+		return pt.Clone(), nil, err
+	}
+	return pt.Clone(), regs, p.WriteRegisters(uint8(pt.UnitID), uint16(pt.RegisterAddress), regs)
+}
+
+// AddPoint adds a data point to a proxy and stores it. A point without an ID
+// gets one.
+func (m *Manager) AddPoint(proxyID string, pt mapping.Mapping) (mapping.Mapping, error) {
+	if pt.ID == "" {
+		pt.ID = uuid.New().String()
+	}
+	pt.ProxyID = ""
+	err := m.updatePoints(proxyID, func(points []mapping.Mapping) ([]mapping.Mapping, error) {
+		return append(points, pt), nil
+	})
+	return pt, err
+}
+
+// UpdatePoint replaces a data point of a proxy, named by ID or name. The
+// point keeps its ID.
+func (m *Manager) UpdatePoint(proxyID, idOrName string, pt mapping.Mapping) (mapping.Mapping, error) {
+	pt.ProxyID = ""
+	err := m.updatePoints(proxyID, func(points []mapping.Mapping) ([]mapping.Mapping, error) {
+		for i := range points {
+			if points[i].ID == idOrName || points[i].Name == idOrName {
+				pt.ID = points[i].ID
+				points[i] = pt
+				return points, nil
+			}
+		}
+		return nil, ErrPointNotFound
+	})
+	return pt, err
+}
+
+// RemovePoint removes a data point of a proxy, named by ID or name.
+func (m *Manager) RemovePoint(proxyID, idOrName string) error {
+	return m.updatePoints(proxyID, func(points []mapping.Mapping) ([]mapping.Mapping, error) {
+		for i := range points {
+			if points[i].ID == idOrName || points[i].Name == idOrName {
+				return append(points[:i], points[i+1:]...), nil
+			}
+		}
+		return nil, ErrPointNotFound
+	})
+}
+
+// updatePoints changes the stored data points of a proxy and, once they are
+// saved, the ones it is read by. Points are data about the proxy, not part of
+// how it forwards, so the proxy keeps running.
+func (m *Manager) updatePoints(proxyID string, fn func([]mapping.Mapping) ([]mapping.Mapping, error)) error {
+	if _, ok := m.GetProxyInstance(proxyID); !ok {
+		return fmt.Errorf("proxy not found")
+	}
+
+	var saved []mapping.Mapping
+	err := m.cfgMgr.UpdateProxy(proxyID, func(p *config.ProxyConfig) error {
+		points, err := fn(p.Points)
+		if err != nil {
+			return err
+		}
+		p.Points = points
+		if err := config.ValidateProxyPoints(p); err != nil {
+			return err
+		}
+		saved = points
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.points.SetMappings(proxyID, saved)
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"fmt"
+	"modbridge/pkg/audit"
+	"modbridge/pkg/config"
+	"modbridge/pkg/proxy"
+	"modbridge/pkg/rbac"
+	"net"
+	"strings"
+	"time"
+)
+
+// denialAuditInterval is how often the same refused request from the same
+// client reaches the audit log. A misconfigured client retries many times a
+// second; the audit log should show that it happens, not every attempt.
+const denialAuditInterval = time.Minute
+
+// SetAuditor sets where refused Modbus requests are recorded.
+func (m *Manager) SetAuditor(a *audit.Auditor) {
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+	m.auditor = a
+}
+
+// auditDenial records a request refused by a proxy's access policy or
+// certificate authorization.
+func (m *Manager) auditDenial(d proxy.Denial) {
+	m.auditMu.Lock()
+	a := m.auditor
+	if a == nil {
+		m.auditMu.Unlock()
+		return
+	}
+	now := time.Now()
+	key := fmt.Sprintf("%s|%s|%s|%d|%d", d.ProxyID, d.Client, d.Certificate, d.UnitID, d.Function)
+	if last, ok := m.deniedSeen[key]; ok && now.Sub(last) < denialAuditInterval {
+		m.auditMu.Unlock()
+		return
+	}
+	if len(m.deniedSeen) >= 1000 {
+		for k, last := range m.deniedSeen {
+			if now.Sub(last) >= denialAuditInterval {
+				delete(m.deniedSeen, k)
+			}
+		}
+	}
+	m.deniedSeen[key] = now
+	m.auditMu.Unlock()
+
+	// A client with a certificate is known by its subject.
+	details := fmt.Sprintf("unit %d, function 0x%02X", d.UnitID, d.Function)
+	a.LogAction("modbus.denied", "proxy", d.ProxyID, "", d.Certificate, details, d.Client, "", false, d.Reason)
+}
+
+// accessPolicy turns a stored firewall into the proxy's. The validator has
+// already checked every number and network.
+func accessPolicy(cfg *config.PolicyConfig) *proxy.AccessPolicy {
+	policy := &proxy.AccessPolicy{AccessRule: accessRule(cfg.PolicyRuleConfig)}
+	for _, c := range cfg.Clients {
+		client := proxy.ClientAccess{AccessRule: accessRule(c.PolicyRuleConfig)}
+		client.Sources = sourceNetworks(c.Sources)
+		policy.Clients = append(policy.Clients, client)
+	}
+	return policy
+}
+
+// accessRule converts one rule of a firewall.
+func accessRule(cfg config.PolicyRuleConfig) proxy.AccessRule {
+	rule := proxy.AccessRule{ReadOnly: cfg.ReadOnly}
+	for _, fc := range cfg.AllowedFunctionCodes {
+		rule.Functions = append(rule.Functions, uint8(fc))
+	}
+	for _, rc := range cfg.AllowedRanges {
+		rg := proxy.AccessRange{Start: uint16(rc.Start), Count: rc.Count, ReadOnly: rc.ReadOnly}
+		for _, unit := range rc.UnitIDs {
+			rg.Units = append(rg.Units, uint8(unit))
+		}
+		rule.Ranges = append(rule.Ranges, rg)
+	}
+	return rule
+}
+
+// certAuthorization turns the stored certificate grants of a listener into
+// the proxy's. The validator has already checked every role.
+func certAuthorization(grants []config.CertGrantConfig) *proxy.CertAuthorization {
+	ca := &proxy.CertAuthorization{}
+	for _, g := range grants {
+		grant := proxy.CertGrant{Subject: g.Subject, CertRole: g.CertRole, AccessRule: accessRule(g.PolicyRuleConfig)}
+		grant.Role, _ = rbac.ParseRole(g.Role)
+		for _, unit := range g.AllowedUnitIDs {
+			grant.Units = append(grant.Units, uint8(unit))
+		}
+		ca.Grants = append(ca.Grants, grant)
+	}
+	return ca
+}
+
+// rateLimit turns stored rate limits into the proxy's. The validator has
+// already checked every rate, network and priority name.
+func rateLimit(cfg *config.RateLimitConfig) *proxy.RateLimit {
+	rl := &proxy.RateLimit{
+		ClientRate:       cfg.ClientRate,
+		ClientBurst:      cfg.ClientBurst,
+		ProxyRate:        cfg.ProxyRate,
+		ProxyBurst:       cfg.ProxyBurst,
+		InternalPriority: proxy.PriorityLow,
+	}
+	if cfg.InternalPriority != "" {
+		rl.InternalPriority, _ = proxy.ParseRequestPriority(cfg.InternalPriority)
+	}
+	for _, c := range cfg.Classes {
+		class := proxy.ClientClass{Sources: sourceNetworks(c.Sources), ClientBurst: c.ClientBurst}
+		class.Priority, _ = proxy.ParseRequestPriority(c.Priority)
+		if c.ClientRate != nil {
+			rate := *c.ClientRate
+			class.ClientRate = &rate
+		}
+		rl.Classes = append(rl.Classes, class)
+	}
+	return rl
+}
+
+// sourceNetworks parses the client networks of a policy or rate-limit class.
+// A bare address stands for itself.
+func sourceNetworks(sources []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, src := range sources {
+		if !strings.Contains(src, "/") {
+			if ip := net.ParseIP(src); ip != nil && ip.To4() != nil {
+				src += "/32"
+			} else {
+				src += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(src); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
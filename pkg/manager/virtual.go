@@ -0,0 +1,66 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package manager
+
+import (
+	"fmt"
+	"modbridge/pkg/capture"
+	"modbridge/pkg/config"
+	"modbridge/pkg/simulator"
+	"time"
+)
+
+// virtualDevice builds the simulated device of a proxy. The replayed capture
+// comes first, so that configured registers override the addresses it holds.
+// A capture that cannot be read is logged and left out: the proxy still
+// starts, with the registers that are configured.
+func (m *Manager) virtualDevice(proxyID string, cfg *config.VirtualConfig) *simulator.Device {
+	var registers []simulator.Register
+	if cfg.ReplayFile != "" {
+		frames, err := capture.ReadFile(cfg.ReplayFile)
+		if err != nil {
+			m.log.Error(proxyID, fmt.Sprintf("Failed to read the capture to replay: %v", err))
+		} else if registers = simulator.FromCapture(frames); len(registers) == 0 {
+			m.log.Warn(proxyID, fmt.Sprintf("The capture %s holds no reads to replay", cfg.ReplayFile))
+		}
+	}
+	for _, r := range cfg.Registers {
+		registers = append(registers, virtualRegister(r))
+	}
+	return simulator.New(registers, simulator.Quirks{
+		SingleSession: cfg.SingleSession,
+		MinGap:        time.Duration(cfg.MinGapMs) * time.Millisecond,
+		DropRate:      cfg.DropRate,
+		ExceptionRate: cfg.ExceptionRate,
+		ExceptionCode: byte(cfg.ExceptionCode),
+	})
+}
+
+// virtualRegister turns a stored register into the simulator's. The
+// validator has already checked every number against its range.
+func virtualRegister(r config.VirtualRegisterConfig) simulator.Register {
+	reg := simulator.Register{
+		Table:     simulator.Table(r.Table),
+		Address:   uint16(r.Address),
+		Count:     uint16(r.Count),
+		Kind:      simulator.Kind(r.Type),
+		Value:     r.Value,
+		Min:       r.Min,
+		Max:       r.Max,
+		Period:    time.Duration(r.PeriodMs) * time.Millisecond,
+		Step:      r.Step,
+		Interval:  time.Duration(r.IntervalMs) * time.Millisecond,
+		Exception: byte(r.Exception),
+	}
+	interval := reg.Interval
+	if interval <= 0 {
+		interval = simulator.DefaultInterval
+	}
+	for i, v := range r.Values {
+		reg.Samples = append(reg.Samples, simulator.Sample{At: time.Duration(i) * interval, Value: v})
+	}
+	return reg
+}
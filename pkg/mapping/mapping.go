@@ -3,45 +3,215 @@
 // Created by Xerolux
 // https://github.com/Xerolux/modbridge
 
-// NOT WIRED UP: no code path reaches this package yet.
-//
-// Register transformations (scaling, type conversion) are a sensible thing for
-// a proxy to offer. Kept as unfinished work; nothing imports it today.
+// Package mapping gives registers names. A mapping — a "data point" in the
+// API — says where a value lives on a device and how to decode it, so that a
+// dashboard can ask for "pv_power_w" instead of holding register 40083 and
+// knowing it is a big-endian int32 in tenths of a watt.
 package mapping
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"modbridge/pkg/transform"
+	"regexp"
+	"strings"
 	"sync"
 )
 
+// Register types a mapping can read from.
+const (
+	RegisterHolding = "holding"
+	RegisterInput   = "input"
+)
+
+// Word and byte orders. Big endian is the Modbus default: the high word in
+// the lower register, the high byte first within each register.
+const (
+	OrderBig    = "big"
+	OrderLittle = "little"
+)
+
+// registerCounts is how many registers each fixed-size data type spans.
+var registerCounts = map[string]int{
+	"uint16": 1, "int16": 1,
+	"uint32": 2, "int32": 2, "float32": 2,
+	"uint64": 4, "int64": 4, "float64": 4,
+}
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
 // Mapping represents a register mapping configuration
 type Mapping struct {
-	ID              string            `json:"id"`
-	ProxyID         string            `json:"proxy_id"`
-	Name            string            `json:"name"`
-	Description     string            `json:"description"`
-	RegisterAddress int               `json:"register_address"`
-	RegisterCount   int               `json:"register_count"`
-	DataType        string            `json:"data_type"` // uint16, int16, uint32, int32, float32
-	ScaleFactor     float64           `json:"scale_factor"`
-	Offset          float64           `json:"offset"`
-	Unit            string            `json:"unit"`
-	Tags            []string          `json:"tags"`
-	Enabled         bool              `json:"enabled"`
-	Metadata        map[string]string `json:"metadata"`
+	ID              string                     `json:"id"`
+	ProxyID         string                     `json:"proxy_id,omitempty"`
+	Name            string                     `json:"name"`
+	Description     string                     `json:"description,omitempty"`
+	UnitID          int                        `json:"unit_id"`
+	RegisterType    string                     `json:"register_type,omitempty"` // holding (default) or input
+	RegisterAddress int                        `json:"register_address"`
+	RegisterCount   int                        `json:"register_count,omitempty"` // Registers of a string; numbers take theirs from the data type
+	DataType        string                     `json:"data_type"`                // uint16, int16, uint32, int32, float32, uint64, int64, float64, string
+	WordOrder       string                     `json:"word_order,omitempty"`     // big (default): high word first; little: low word first
+	ByteOrder       string                     `json:"byte_order,omitempty"`     // big (default): high byte first; little: bytes swapped in every register
+	ScaleFactor     float64                    `json:"scale_factor"`             // Multiplier for the raw value (0 = 1)
+	Offset          float64                    `json:"offset"`
+	Unit            string                     `json:"unit"`
+	Transform       *transform.TransformConfig `json:"transform,omitempty"` // Replaces scale_factor and offset when set
+	Tags            []string                   `json:"tags"`
+	Enabled         bool                       `json:"enabled"`
+	Writable        bool                       `json:"writable,omitempty"` // May be set through MQTT command topics
+	Metadata        map[string]string          `json:"metadata"`
+}
+
+// Registers returns how many registers the mapping spans.
+func (m *Mapping) Registers() int {
+	if n, ok := registerCounts[m.DataType]; ok {
+		return n
+	}
+	return m.RegisterCount
+}
+
+// FunctionCode returns the Modbus function code that reads the mapping.
+func (m *Mapping) FunctionCode() uint8 {
+	if m.RegisterType == RegisterInput {
+		return 0x04
+	}
+	return 0x03
+}
+
+// Step returns how far apart two values of the mapping lie in its units when
+// their raw numbers differ by one — the finest setting a write can make. It
+// is 0 for floating-point and string types, which have no such step.
+func (m *Mapping) Step() float64 {
+	if m.DataType == "string" || strings.HasPrefix(m.DataType, "float") {
+		return 0
+	}
+	if t := m.Transform; t != nil {
+		switch t.Type {
+		case transform.TransformScale:
+			return math.Abs(t.Scale)
+		case transform.TransformLinear:
+			return math.Abs(t.Slope)
+		case transform.TransformToInt:
+			return math.Pow(10, -float64(t.Precision))
+		}
+		return 1
+	}
+	if m.ScaleFactor == 0 {
+		return 1
+	}
+	return math.Abs(m.ScaleFactor)
+}
+
+// Clone returns a deep copy of the mapping.
+func (m Mapping) Clone() Mapping {
+	if m.Tags != nil {
+		m.Tags = append([]string(nil), m.Tags...)
+	}
+	if m.Metadata != nil {
+		metadata := make(map[string]string, len(m.Metadata))
+		for k, v := range m.Metadata {
+			metadata[k] = v
+		}
+		m.Metadata = metadata
+	}
+	if m.Transform != nil {
+		t := *m.Transform
+		if t.Map != nil {
+			values := make(map[uint64]float64, len(t.Map))
+			for k, v := range t.Map {
+				values[k] = v
+			}
+			t.Map = values
+		}
+		if t.MinValue != nil {
+			min := *t.MinValue
+			t.MinValue = &min
+		}
+		if t.MaxValue != nil {
+			max := *t.MaxValue
+			t.MaxValue = &max
+		}
+		m.Transform = &t
+	}
+	return m
+}
+
+// Validate checks a mapping on its own. Whether its name is unique is up to
+// whoever keeps the mappings of a proxy.
+func (m *Mapping) Validate() error {
+	if m.Name == "" {
+		return errors.New("name cannot be empty")
+	}
+	if len(m.Name) > 100 || !nameRegex.MatchString(m.Name) {
+		return errors.New("name must be at most 100 letters, digits, dots, hyphens or underscores")
+	}
+	if m.UnitID < 0 || m.UnitID > 255 {
+		return errors.New("unit_id must be between 0 and 255")
+	}
+	switch m.RegisterType {
+	case "", RegisterHolding, RegisterInput:
+	default:
+		return errors.New("register_type must be holding or input")
+	}
+
+	if m.DataType == "string" {
+		if m.RegisterCount < 1 || m.RegisterCount > 125 {
+			return errors.New("register_count must be between 1 and 125 for a string")
+		}
+	} else if _, ok := registerCounts[m.DataType]; !ok {
+		return errors.New("data_type must be one of: uint16, int16, uint32, int32, float32, uint64, int64, float64, string")
+	}
+	if m.RegisterAddress < 0 || m.RegisterAddress+m.Registers() > 65536 {
+		return errors.New("register_address must keep every register between 0 and 65535")
+	}
+
+	for field, order := range map[string]string{"word_order": m.WordOrder, "byte_order": m.ByteOrder} {
+		if order != "" && order != OrderBig && order != OrderLittle {
+			return fmt.Errorf("%s must be big or little", field)
+		}
+	}
+	if m.Writable {
+		if m.RegisterType == RegisterInput {
+			return errors.New("input registers cannot be written")
+		}
+		if m.Transform != nil {
+			switch m.Transform.Type {
+			case transform.TransformNone, transform.TransformScale, transform.TransformLinear, transform.TransformToInt:
+			default:
+				return errors.New("a writable point needs a transform that can be inverted: none, scale, linear or to_int")
+			}
+		}
+	}
+	if m.Transform != nil {
+		if m.DataType == "string" {
+			return errors.New("transform does not apply to strings")
+		}
+		if m.Transform.Type == transform.TransformSwap {
+			return errors.New("transform swap is not supported, use byte_order")
+		}
+		if err := m.Transform.Validate(); err != nil {
+			return fmt.Errorf("transform: %w", err)
+		}
+	}
+	return nil
 }
 
 // Manager manages register mappings
 type Manager struct {
-	mu       sync.RWMutex
-	mappings map[string][]*Mapping // proxy_id -> mappings
+	mu          sync.RWMutex
+	mappings    map[string][]*Mapping // proxy_id -> mappings
+	transformer *transform.Transformer
 }
 
 // NewManager creates a new mapping manager
 func NewManager() *Manager {
 	return &Manager{
-		mappings: make(map[string][]*Mapping),
+		mappings:    make(map[string][]*Mapping),
+		transformer: transform.NewTransformer(),
 	}
 }
 
@@ -55,6 +225,25 @@ func (m *Manager) AddMapping(mapping *Mapping) {
 	m.mappings[mapping.ProxyID] = mappings
 }
 
+// SetMappings replaces all mappings of a proxy. Every mapping is copied and
+// tagged with the proxy's ID.
+func (m *Manager) SetMappings(proxyID string, mappings []Mapping) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(mappings) == 0 {
+		delete(m.mappings, proxyID)
+		return
+	}
+	list := make([]*Mapping, len(mappings))
+	for i := range mappings {
+		mapping := mappings[i].Clone()
+		mapping.ProxyID = proxyID
+		list[i] = &mapping
+	}
+	m.mappings[proxyID] = list
+}
+
 // RemoveMapping removes a register mapping
 func (m *Manager) RemoveMapping(mappingID string) {
 	m.mu.Lock()
@@ -71,6 +260,13 @@ func (m *Manager) RemoveMapping(mappingID string) {
 	}
 }
 
+// RemoveProxy drops every mapping of a proxy.
+func (m *Manager) RemoveProxy(proxyID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.mappings, proxyID)
+}
+
 // GetMappings returns all mappings for a proxy
 func (m *Manager) GetMappings(proxyID string) []*Mapping {
 	m.mu.RLock()
@@ -82,42 +278,208 @@ func (m *Manager) GetMappings(proxyID string) []*Mapping {
 	return result
 }
 
+// GetMapping returns one mapping of a proxy by ID or name.
+func (m *Manager) GetMapping(proxyID, idOrName string) (*Mapping, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, mapping := range m.mappings[proxyID] {
+		if mapping.ID == idOrName || mapping.Name == idOrName {
+			return mapping, true
+		}
+	}
+	return nil, false
+}
+
 // TransformValue transforms a raw register value according to the mapping
 func (m *Manager) TransformValue(mapping *Mapping, rawValue []uint16) (interface{}, error) {
 	if !mapping.Enabled {
 		return nil, nil
 	}
+	if len(rawValue) < mapping.Registers() {
+		return nil, fmt.Errorf("%s needs %d registers, got %d", mapping.Name, mapping.Registers(), len(rawValue))
+	}
+
+	// Put the registers into big-endian byte order, whatever the device
+	// uses, so every type below decodes the same way.
+	regs := append([]uint16(nil), rawValue[:mapping.Registers()]...)
+	if mapping.WordOrder == OrderLittle {
+		for i, j := 0, len(regs)-1; i < j; i, j = i+1, j-1 {
+			regs[i], regs[j] = regs[j], regs[i]
+		}
+	}
+	raw := make([]byte, 2*len(regs))
+	for i, r := range regs {
+		if mapping.ByteOrder == OrderLittle {
+			r = r<<8 | r>>8
+		}
+		binary.BigEndian.PutUint16(raw[2*i:], r)
+	}
 
 	var value float64
 
 	switch mapping.DataType {
+	case "string":
+		return strings.TrimRight(string(raw), "\x00 "), nil
 	case "uint16":
-		if len(rawValue) > 0 {
-			value = float64(rawValue[0])
-		}
+		value = float64(binary.BigEndian.Uint16(raw))
 	case "int16":
-		if len(rawValue) > 0 {
-			value = float64(int16(rawValue[0]))
-		}
+		value = float64(int16(binary.BigEndian.Uint16(raw)))
 	case "uint32":
-		if len(rawValue) >= 2 {
-			value = float64(uint32(rawValue[0])<<16 | uint32(rawValue[1]))
-		}
+		value = float64(binary.BigEndian.Uint32(raw))
 	case "int32":
-		if len(rawValue) >= 2 {
-			value = float64(int32(rawValue[0])<<16 | int32(rawValue[1]))
-		}
+		value = float64(int32(binary.BigEndian.Uint32(raw)))
 	case "float32":
-		if len(rawValue) >= 2 {
-			bits := uint32(rawValue[0])<<16 | uint32(rawValue[1])
-			value = float64(math.Float32frombits(bits))
-		}
+		value = float64(math.Float32frombits(binary.BigEndian.Uint32(raw)))
+	case "uint64":
+		value = float64(binary.BigEndian.Uint64(raw))
+	case "int64":
+		value = float64(int64(binary.BigEndian.Uint64(raw)))
+	case "float64":
+		value = math.Float64frombits(binary.BigEndian.Uint64(raw))
+	default:
+		return nil, fmt.Errorf("unknown data type %q", mapping.DataType)
+	}
+
+	return m.scale(mapping, value)
+}
+
+// scale turns a decoded raw number into the mapping's units.
+func (m *Manager) scale(mapping *Mapping, value float64) (float64, error) {
+	if mapping.Transform != nil {
+		return m.transformer.TransformValue(value, mapping.Transform)
 	}
 
 	// Apply scale and offset
-	value = value*mapping.ScaleFactor + mapping.Offset
+	scale := mapping.ScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+	return value*scale + mapping.Offset, nil
+}
 
-	return value, nil
+// rawRanges is the smallest and largest raw number each data type holds.
+var rawRanges = map[string][2]float64{
+	"uint16":  {0, math.MaxUint16},
+	"int16":   {math.MinInt16, math.MaxInt16},
+	"uint32":  {0, math.MaxUint32},
+	"int32":   {math.MinInt32, math.MaxInt32},
+	"float32": {-math.MaxFloat32, math.MaxFloat32},
+	"uint64":  {0, math.MaxUint64},
+	"int64":   {math.MinInt64, math.MaxInt64},
+	"float64": {-math.MaxFloat64, math.MaxFloat64},
+}
+
+// ValueRange returns the smallest and largest value a numeric mapping can
+// take in its units: what its data type holds, scaled, within the limits of
+// its transform.
+func (m *Manager) ValueRange(mapping *Mapping) (min, max float64, ok bool) {
+	r, ok := rawRanges[mapping.DataType]
+	if !ok {
+		return 0, 0, false
+	}
+	min, errMin := m.scale(mapping, r[0])
+	max, errMax := m.scale(mapping, r[1])
+	if errMin != nil || errMax != nil {
+		min, max = r[0], r[1]
+	}
+	if min > max {
+		min, max = max, min
+	}
+	return min, max, true
+}
+
+// EncodeValue turns a value in the mapping's units into the registers that
+// hold it — the inverse of TransformValue, for writing. Numbers are held to
+// the limits of the transform first; one the data type cannot hold is an
+// error rather than being wrapped around. Strings are padded with NUL bytes.
+func (m *Manager) EncodeValue(mapping *Mapping, value interface{}) ([]uint16, error) {
+	var raw []byte
+	if mapping.DataType == "string" {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s needs a string", mapping.Name)
+		}
+		if len(s) > 2*mapping.RegisterCount {
+			return nil, fmt.Errorf("%s holds at most %d bytes", mapping.Name, 2*mapping.RegisterCount)
+		}
+		raw = make([]byte, 2*mapping.RegisterCount)
+		copy(raw, s)
+	} else {
+		v, ok := value.(float64)
+		if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%s needs a finite number", mapping.Name)
+		}
+		var err error
+		if raw, err = m.encodeNumber(mapping, v); err != nil {
+			return nil, err
+		}
+	}
+
+	// Undo what TransformValue does to the order: the same swaps again.
+	regs := make([]uint16, len(raw)/2)
+	for i := range regs {
+		r := binary.BigEndian.Uint16(raw[2*i:])
+		if mapping.ByteOrder == OrderLittle {
+			r = r<<8 | r>>8
+		}
+		regs[i] = r
+	}
+	if mapping.WordOrder == OrderLittle {
+		for i, j := 0, len(regs)-1; i < j; i, j = i+1, j-1 {
+			regs[i], regs[j] = regs[j], regs[i]
+		}
+	}
+	return regs, nil
+}
+
+// encodeNumber turns a number in the mapping's units into the big-endian
+// bytes of its data type.
+func (m *Manager) encodeNumber(mapping *Mapping, v float64) ([]byte, error) {
+	var raw float64
+	if mapping.Transform != nil {
+		var err error
+		if raw, err = m.transformer.InverseValue(v, mapping.Transform); err != nil {
+			return nil, err
+		}
+	} else {
+		scale := mapping.ScaleFactor
+		if scale == 0 {
+			scale = 1
+		}
+		raw = (v - mapping.Offset) / scale
+	}
+	if !strings.HasPrefix(mapping.DataType, "float") {
+		raw = math.Round(raw)
+	}
+	r, ok := rawRanges[mapping.DataType]
+	if !ok {
+		return nil, fmt.Errorf("unknown data type %q", mapping.DataType)
+	}
+	// The 64-bit maximums are not exact as floats; 2^63 and 2^64 round to
+	// them and would overflow the conversion.
+	if raw < r[0] || raw > r[1] || (mapping.DataType == "int64" && raw >= math.MaxInt64) || (mapping.DataType == "uint64" && raw >= math.MaxUint64) {
+		return nil, fmt.Errorf("%v is out of range for %s (raw %v)", v, mapping.DataType, raw)
+	}
+
+	switch mapping.DataType {
+	case "uint16":
+		return binary.BigEndian.AppendUint16(nil, uint16(raw)), nil
+	case "int16":
+		return binary.BigEndian.AppendUint16(nil, uint16(int16(raw))), nil
+	case "uint32":
+		return binary.BigEndian.AppendUint32(nil, uint32(raw)), nil
+	case "int32":
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(raw))), nil
+	case "float32":
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(raw))), nil
+	case "uint64":
+		return binary.BigEndian.AppendUint64(nil, uint64(raw)), nil
+	case "int64":
+		return binary.BigEndian.AppendUint64(nil, uint64(int64(raw))), nil
+	default: // float64
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(raw)), nil
+	}
 }
 
 // TransformMultiple transforms multiple register values
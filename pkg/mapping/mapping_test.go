@@ -0,0 +1,154 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package mapping
+
+import (
+	"math"
+	"modbridge/pkg/transform"
+	"testing"
+)
+
+func TestTransformValueDecodesTypes(t *testing.T) {
+	m := NewManager()
+	f32 := math.Float32bits(230.5)
+
+	tests := []struct {
+		name    string
+		mapping Mapping
+		regs    []uint16
+		want    interface{}
+	}{
+		{"int16", Mapping{DataType: "int16"}, []uint16{0xFFFE}, -2.0},
+		{"int32 scaled", Mapping{DataType: "int32", ScaleFactor: 0.1}, []uint16{0xFFFF, 0xFF38}, -20.0},
+		{"uint32 word swapped", Mapping{DataType: "uint32", WordOrder: OrderLittle}, []uint16{0x0002, 0x0001}, float64(0x00010002)},
+		{"float32", Mapping{DataType: "float32"}, []uint16{uint16(f32 >> 16), uint16(f32)}, 230.5},
+		{"float32 byte swapped", Mapping{DataType: "float32", ByteOrder: OrderLittle}, []uint16{swap(uint16(f32 >> 16)), swap(uint16(f32))}, 230.5},
+		{"uint64 with offset", Mapping{DataType: "uint64", Offset: 1}, []uint16{0, 0, 1, 0}, 65537.0},
+		{"string", Mapping{DataType: "string", RegisterCount: 3}, []uint16{0x534D, 0x4100, 0}, "SMA"},
+		{"transform map", Mapping{DataType: "uint16", Transform: &transform.TransformConfig{Type: transform.TransformMap, Map: map[uint64]float64{307: 1}}}, []uint16{307}, 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mapping.Name = tt.name
+			tt.mapping.Enabled = true
+			got, err := m.TransformValue(&tt.mapping, tt.regs)
+			if err != nil {
+				t.Fatalf("TransformValue() error = %v", err)
+			}
+			if f, ok := got.(float64); ok {
+				if want, _ := tt.want.(float64); math.Abs(f-want) > 1e-6 {
+					t.Errorf("TransformValue() = %v, want %v", got, tt.want)
+				}
+			} else if got != tt.want {
+				t.Errorf("TransformValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := m.TransformValue(&Mapping{Name: "short", DataType: "int32", Enabled: true}, []uint16{1}); err == nil {
+		t.Error("TransformValue() decoded an int32 from one register")
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping Mapping
+		wantErr bool
+	}{
+		{"valid", Mapping{Name: "pv_power_w", DataType: "int32", RegisterAddress: 40083}, false},
+		{"missing name", Mapping{DataType: "int32"}, true},
+		{"bad name", Mapping{Name: "pv power", DataType: "int32"}, true},
+		{"unknown type", Mapping{Name: "x", DataType: "int24"}, true},
+		{"string without length", Mapping{Name: "x", DataType: "string"}, true},
+		{"past the last register", Mapping{Name: "x", DataType: "float64", RegisterAddress: 65534}, true},
+		{"bad word order", Mapping{Name: "x", DataType: "int32", WordOrder: "middle"}, true},
+		{"bad register type", Mapping{Name: "x", DataType: "int16", RegisterType: "coil"}, true},
+		{"swap transform", Mapping{Name: "x", DataType: "int16", Transform: &transform.TransformConfig{Type: transform.TransformSwap}}, true},
+		{"writable", Mapping{Name: "x", DataType: "int16", Writable: true, Transform: &transform.TransformConfig{Type: transform.TransformScale, Scale: 0.1}}, false},
+		{"writable input register", Mapping{Name: "x", DataType: "int16", RegisterType: RegisterInput, Writable: true}, true},
+		{"writable map transform", Mapping{Name: "x", DataType: "uint16", Writable: true, Transform: &transform.TransformConfig{Type: transform.TransformMap, Map: map[uint64]float64{1: 2}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mapping.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeValueInvertsTransformValue(t *testing.T) {
+	m := NewManager()
+	max := 80.0
+
+	tests := []struct {
+		name    string
+		mapping Mapping
+		value   interface{}
+		want    []uint16
+	}{
+		{"int16", Mapping{DataType: "int16"}, -2.0, []uint16{0xFFFE}},
+		{"int32 scaled", Mapping{DataType: "int32", ScaleFactor: 0.1}, -20.0, []uint16{0xFFFF, 0xFF38}},
+		{"uint32 word swapped", Mapping{DataType: "uint32", WordOrder: OrderLittle}, float64(0x00010002), []uint16{0x0002, 0x0001}},
+		{"float32 byte swapped", Mapping{DataType: "float32", ByteOrder: OrderLittle}, 230.5, []uint16{swap(0x4366), swap(0x8000)}},
+		{"uint64 with offset", Mapping{DataType: "uint64", Offset: 1}, 65537.0, []uint16{0, 0, 1, 0}},
+		{"rounded", Mapping{DataType: "uint16", ScaleFactor: 0.1}, 12.34, []uint16{123}},
+		{"clamped", Mapping{DataType: "uint16", Transform: &transform.TransformConfig{Type: transform.TransformScale, Scale: 0.5, MaxValue: &max}}, 95.0, []uint16{160}},
+		{"string", Mapping{DataType: "string", RegisterCount: 3}, "SMA", []uint16{0x534D, 0x4100, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mapping.Name = tt.name
+			tt.mapping.Enabled = true
+			regs, err := m.EncodeValue(&tt.mapping, tt.value)
+			if err != nil {
+				t.Fatalf("EncodeValue() error = %v", err)
+			}
+			if len(regs) != len(tt.want) {
+				t.Fatalf("EncodeValue() = %04X, want %04X", regs, tt.want)
+			}
+			for i := range regs {
+				if regs[i] != tt.want[i] {
+					t.Fatalf("EncodeValue() = %04X, want %04X", regs, tt.want)
+				}
+			}
+		})
+	}
+
+	for _, bad := range []struct {
+		mapping Mapping
+		value   interface{}
+	}{
+		{Mapping{Name: "negative", DataType: "uint16"}, -1.0},
+		{Mapping{Name: "too big", DataType: "int16", ScaleFactor: 0.1}, 4000.0},
+		{Mapping{Name: "not a number", DataType: "int16"}, math.NaN()},
+		{Mapping{Name: "string for a number", DataType: "int16"}, "5"},
+		{Mapping{Name: "too long", DataType: "string", RegisterCount: 1}, "SMA"},
+	} {
+		if regs, err := m.EncodeValue(&bad.mapping, bad.value); err == nil {
+			t.Errorf("EncodeValue(%s, %v) = %04X, want an error", bad.mapping.Name, bad.value, regs)
+		}
+	}
+}
+
+func TestValueRange(t *testing.T) {
+	m := NewManager()
+	min, max, ok := m.ValueRange(&Mapping{DataType: "int16", ScaleFactor: -0.1})
+	if !ok || math.Abs(min+3276.7) > 1e-9 || math.Abs(max-3276.8) > 1e-9 {
+		t.Errorf("ValueRange() = %v, %v, %v; want -3276.7, 3276.8", min, max, ok)
+	}
+	if _, _, ok := m.ValueRange(&Mapping{DataType: "string", RegisterCount: 2}); ok {
+		t.Error("ValueRange() returned a range for a string")
+	}
+}
+
+func swap(r uint16) uint16 {
+	return r<<8 | r>>8
+}
@@ -215,11 +215,36 @@ type ProxyStats struct {
 // the response cache and background poller are doing. They matter because they
 // turn "it feels slow" into a number you can graph.
 type ProxyDiagnostics struct {
+	Running        bool
+	Requests       int64
+	Errors         int64
+	Exceptions     map[uint8]int64  // Exception responses sent to clients, by code
+	Latency        LatencyHistogram // Time to an answer from the target
+	LatencyP95     time.Duration    // Over the recent requests only
 	StaleResponses int64
+	Coalesced      int64 // Reads answered with the response to an identical read in flight
 	CacheHits      int64
 	CacheMisses    int64
 	CacheEntries   int
 	PolledRequests int
+	PollRefreshes  int64
+	PollFailures   int64
+	CircuitState   string        // closed, open or half-open
+	PacingWait     time.Duration // Spent waiting for the minimum request gap
+	ClientConns    int64
+	TargetActive   int // Target connections serving a request
+	TargetIdle     int // Target connections idle in the pool
+	// Certificates holds when the TLS certificates of the proxy expire, by
+	// use (listen, target).
+	Certificates map[string]time.Time
+}
+
+// LatencyHistogram is a latency distribution in the shape Prometheus expects.
+type LatencyHistogram struct {
+	Bounds []time.Duration // Upper bound of each bucket
+	Counts []int64         // Observations at or below each bound (cumulative)
+	Count  int64
+	Sum    time.Duration
 }
 
 // SetDiagnosticsProvider registers a source for those counters. Without one,
@@ -263,68 +288,9 @@ func (m *Metrics) GetPrometheusMetrics() string {
 	output.WriteString("# TYPE modbridge_uptime_seconds gauge\n")
 	output.WriteString(fmt.Sprintf("modbridge_uptime_seconds %f\n\n", stats.Uptime.Seconds()))
 
-	diagnostics := m.currentDiagnostics()
-
-	// Per-proxy metrics
-	for proxyID, proxyStats := range stats.ProxyStats {
-		output.WriteString("# HELP modbridge_proxy_requests_total Total requests for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_requests_total counter\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_requests_total{proxy_id=%q} %d\n\n", proxyID, proxyStats.Requests))
-
-		output.WriteString("# HELP modbridge_proxy_errors_total Total errors for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_errors_total counter\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_errors_total{proxy_id=%q} %d\n\n", proxyID, proxyStats.Errors))
-
-		output.WriteString("# HELP modbridge_proxy_active_connections Active connections for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_active_connections gauge\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_active_connections{proxy_id=%q} %d\n\n", proxyID, proxyStats.ActiveConns))
-
-		if d, ok := diagnostics[proxyID]; ok {
-			output.WriteString("# HELP modbridge_proxy_stale_responses_total Target responses discarded because they belonged to an abandoned request\n")
-			output.WriteString("# TYPE modbridge_proxy_stale_responses_total counter\n")
-			output.WriteString(fmt.Sprintf("modbridge_proxy_stale_responses_total{proxy_id=%q} %d\n\n", proxyID, d.StaleResponses))
-
-			output.WriteString("# HELP modbridge_proxy_cache_hits_total Reads answered from the response cache\n")
-			output.WriteString("# TYPE modbridge_proxy_cache_hits_total counter\n")
-			output.WriteString(fmt.Sprintf("modbridge_proxy_cache_hits_total{proxy_id=%q} %d\n\n", proxyID, d.CacheHits))
-
-			output.WriteString("# HELP modbridge_proxy_cache_misses_total Reads that had to reach the target\n")
-			output.WriteString("# TYPE modbridge_proxy_cache_misses_total counter\n")
-			output.WriteString(fmt.Sprintf("modbridge_proxy_cache_misses_total{proxy_id=%q} %d\n\n", proxyID, d.CacheMisses))
-
-			output.WriteString("# HELP modbridge_proxy_cache_entries Registers currently held in the response cache\n")
-			output.WriteString("# TYPE modbridge_proxy_cache_entries gauge\n")
-			output.WriteString(fmt.Sprintf("modbridge_proxy_cache_entries{proxy_id=%q} %d\n\n", proxyID, d.CacheEntries))
-
-			output.WriteString("# HELP modbridge_proxy_polled_requests Requests the background poller keeps warm\n")
-			output.WriteString("# TYPE modbridge_proxy_polled_requests gauge\n")
-			output.WriteString(fmt.Sprintf("modbridge_proxy_polled_requests{proxy_id=%q} %d\n\n", proxyID, d.PolledRequests))
-		}
-
-		output.WriteString("# HELP modbridge_proxy_latency_seconds_avg Average latency for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_latency_seconds_avg gauge\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_latency_seconds_avg{proxy_id=%q} %f\n\n", proxyID, proxyStats.LatencyAvg.Seconds()))
-
-		output.WriteString("# HELP modbridge_proxy_latency_seconds_p50 P50 latency for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_latency_seconds_p50 gauge\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_latency_seconds_p50{proxy_id=%q} %f\n\n", proxyID, proxyStats.LatencyP50.Seconds()))
-
-		output.WriteString("# HELP modbridge_proxy_latency_seconds_p95 P95 latency for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_latency_seconds_p95 gauge\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_latency_seconds_p95{proxy_id=%q} %f\n\n", proxyID, proxyStats.LatencyP95.Seconds()))
-
-		output.WriteString("# HELP modbridge_proxy_latency_seconds_p99 P99 latency for proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_latency_seconds_p99 gauge\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_latency_seconds_p99{proxy_id=%q} %f\n\n", proxyID, proxyStats.LatencyP99.Seconds()))
-
-		output.WriteString("# HELP modbridge_proxy_bytes_in_total Total bytes received by proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_bytes_in_total counter\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_bytes_in_total{proxy_id=%q} %d\n\n", proxyID, proxyStats.BytesIn))
-
-		output.WriteString("# HELP modbridge_proxy_bytes_out_total Total bytes sent by proxy\n")
-		output.WriteString("# TYPE modbridge_proxy_bytes_out_total counter\n")
-		output.WriteString(fmt.Sprintf("modbridge_proxy_bytes_out_total{proxy_id=%q} %d\n\n", proxyID, proxyStats.BytesOut))
-	}
+	// Per-proxy metrics come from the proxies themselves. The per-proxy
+	// counters kept in this package are only summed into the totals above.
+	writeProxyMetrics(&output, m.currentDiagnostics())
 
 	return output.String()
 }
@@ -0,0 +1,158 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// circuitStates are the states a circuit breaker reports. Each gets a series
+// of its own, 1 for the current state and 0 for the others, so a dashboard
+// can count the open breakers with a plain sum.
+var circuitStates = []string{"closed", "open", "half-open"}
+
+// proxyFamily is one metric family with a series per proxy.
+type proxyFamily struct {
+	name, kind, help string
+	value            func(d ProxyDiagnostics) string
+}
+
+// proxyFamilies are the single-valued families, in output order.
+var proxyFamilies = []proxyFamily{
+	{"modbridge_proxy_status_running", "gauge", "1 when proxy is running, else 0", func(d ProxyDiagnostics) string { return boolValue(d.Running) }},
+	{"modbridge_proxy_requests_total", "counter", "Client requests answered, from the cache or the target", func(d ProxyDiagnostics) string { return fmt.Sprint(d.Requests) }},
+	{"modbridge_proxy_errors_total", "counter", "Client requests that failed", func(d ProxyDiagnostics) string { return fmt.Sprint(d.Errors) }},
+	{"modbridge_proxy_stale_responses_total", "counter", "Target responses discarded because they belonged to an abandoned request", func(d ProxyDiagnostics) string { return fmt.Sprint(d.StaleResponses) }},
+	{"modbridge_proxy_coalesced_requests_total", "counter", "Reads that shared the target's answer to an identical read already in flight", func(d ProxyDiagnostics) string { return fmt.Sprint(d.Coalesced) }},
+	{"modbridge_proxy_cache_hits_total", "counter", "Reads answered from the response cache", func(d ProxyDiagnostics) string { return fmt.Sprint(d.CacheHits) }},
+	{"modbridge_proxy_cache_misses_total", "counter", "Reads that had to reach the target", func(d ProxyDiagnostics) string { return fmt.Sprint(d.CacheMisses) }},
+	{"modbridge_proxy_cache_entries", "gauge", "Registers currently held in the response cache", func(d ProxyDiagnostics) string { return fmt.Sprint(d.CacheEntries) }},
+	{"modbridge_proxy_polled_requests", "gauge", "Requests the background poller keeps warm", func(d ProxyDiagnostics) string { return fmt.Sprint(d.PolledRequests) }},
+	{"modbridge_proxy_poll_refreshes_total", "counter", "Cache entries the background poller refreshed", func(d ProxyDiagnostics) string { return fmt.Sprint(d.PollRefreshes) }},
+	{"modbridge_proxy_poll_failures_total", "counter", "Background refreshes the target did not answer", func(d ProxyDiagnostics) string { return fmt.Sprint(d.PollFailures) }},
+	{"modbridge_proxy_pacing_wait_seconds_total", "counter", "Time requests waited for the minimum gap between requests to the target", func(d ProxyDiagnostics) string { return seconds(d.PacingWait) }},
+	{"modbridge_proxy_client_connections", "gauge", "Open client connections", func(d ProxyDiagnostics) string { return fmt.Sprint(d.ClientConns) }},
+	// Snapshots under their old names, which existing dashboards use.
+	{"modbridge_proxy_requests_current", "gauge", "Current request counter snapshot per proxy", func(d ProxyDiagnostics) string { return fmt.Sprint(d.Requests) }},
+	{"modbridge_proxy_errors_current", "gauge", "Current error counter snapshot per proxy", func(d ProxyDiagnostics) string { return fmt.Sprint(d.Errors) }},
+	{"modbridge_proxy_active_connections_current", "gauge", "Current active connections per proxy", func(d ProxyDiagnostics) string { return fmt.Sprint(d.ClientConns) }},
+	{"modbridge_proxy_latency_p95_ms", "gauge", "Current p95 latency in milliseconds per proxy", func(d ProxyDiagnostics) string {
+		return formatFloat(float64(d.LatencyP95) / float64(time.Millisecond))
+	}},
+}
+
+// writeProxyMetrics writes the per-proxy families, each with its HELP and
+// TYPE once and one series per proxy, ordered by proxy ID so that two scrapes
+// differ only in their values.
+func writeProxyMetrics(b *strings.Builder, diagnostics map[string]ProxyDiagnostics) {
+	if len(diagnostics) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(diagnostics))
+	for id := range diagnostics {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	label := func(id string) string {
+		return fmt.Sprintf(`proxy_id="%s"`, escapeLabelValue(id))
+	}
+
+	for _, f := range proxyFamilies {
+		header(f.name, f.kind, f.help)
+		for _, id := range ids {
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, label(id), f.value(diagnostics[id]))
+		}
+		b.WriteString("\n")
+	}
+
+	header("modbridge_proxy_exceptions_total", "counter", "Modbus exception responses sent to clients, by exception code")
+	for _, id := range ids {
+		exceptions := diagnostics[id].Exceptions
+		codes := make([]int, 0, len(exceptions))
+		for code := range exceptions {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(b, "modbridge_proxy_exceptions_total{%s,code=\"0x%02X\"} %d\n", label(id), code, exceptions[uint8(code)])
+		}
+	}
+	b.WriteString("\n")
+
+	header("modbridge_proxy_request_duration_seconds", "histogram", "Time until the target answered a forwarded request")
+	for _, id := range ids {
+		h := diagnostics[id].Latency
+		for i, bound := range h.Bounds {
+			fmt.Fprintf(b, "modbridge_proxy_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", label(id), seconds(bound), h.Counts[i])
+		}
+		fmt.Fprintf(b, "modbridge_proxy_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", label(id), h.Count)
+		fmt.Fprintf(b, "modbridge_proxy_request_duration_seconds_sum{%s} %s\n", label(id), seconds(h.Sum))
+		fmt.Fprintf(b, "modbridge_proxy_request_duration_seconds_count{%s} %d\n", label(id), h.Count)
+	}
+	b.WriteString("\n")
+
+	header("modbridge_proxy_circuit_breaker_state", "gauge", "1 for the current state of the proxy's circuit breaker, else 0")
+	for _, id := range ids {
+		current := diagnostics[id].CircuitState
+		for _, state := range circuitStates {
+			fmt.Fprintf(b, "modbridge_proxy_circuit_breaker_state{%s,state=%q} %s\n", label(id), state, boolValue(current == state))
+		}
+	}
+	b.WriteString("\n")
+
+	header("modbridge_proxy_target_connections", "gauge", "Open connections to the target, serving a request (active) or pooled (idle)")
+	for _, id := range ids {
+		d := diagnostics[id]
+		fmt.Fprintf(b, "modbridge_proxy_target_connections{%s,state=\"active\"} %d\n", label(id), d.TargetActive)
+		fmt.Fprintf(b, "modbridge_proxy_target_connections{%s,state=\"idle\"} %d\n", label(id), d.TargetIdle)
+	}
+	b.WriteString("\n")
+
+	// Only proxies with certificates have series here; a family without any
+	// is left out altogether.
+	var certified []string
+	for _, id := range ids {
+		if len(diagnostics[id].Certificates) > 0 {
+			certified = append(certified, id)
+		}
+	}
+	if len(certified) == 0 {
+		return
+	}
+	header("modbridge_proxy_certificate_expiry_timestamp_seconds", "gauge", "When a TLS certificate of the proxy expires, as a Unix timestamp, by use (listen or target)")
+	for _, id := range certified {
+		certs := diagnostics[id].Certificates
+		uses := make([]string, 0, len(certs))
+		for use := range certs {
+			uses = append(uses, use)
+		}
+		sort.Strings(uses)
+		for _, use := range uses {
+			fmt.Fprintf(b, "modbridge_proxy_certificate_expiry_timestamp_seconds{%s,use=%q} %d\n", label(id), use, certs[use].Unix())
+		}
+	}
+	b.WriteString("\n")
+}
+
+// boolValue renders a flag as a sample value.
+func boolValue(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// seconds renders a duration in seconds, the base unit Prometheus uses.
+func seconds(d time.Duration) string {
+	return formatFloat(d.Seconds())
+}
@@ -66,12 +66,30 @@ func (v *Validator) ValidateProxyConfig(cfg config.ProxyConfig) error {
 		}
 	}
 
-	// Validate Target Address
-	if cfg.TargetAddr == "" {
-		errs = append(errs, &ValidationError{
-			Field:   "target_addr",
-			Message: "cannot be empty",
-		})
+	// Validate Target Address. A serial proxy talks to a local line instead
+	// and needs its device path; a virtual one needs its simulated device.
+	if cfg.Protocol == "virtual" {
+		if cfg.Virtual == nil {
+			errs = append(errs, &ValidationError{
+				Field:   "virtual",
+				Message: "cannot be empty when protocol is virtual",
+			})
+		}
+	} else if cfg.Protocol == "serial" {
+		if cfg.BusID == "" && (cfg.Serial == nil || cfg.Serial.Device == "") {
+			errs = append(errs, &ValidationError{
+				Field:   "serial.device",
+				Message: "cannot be empty when protocol is serial and no bus_id is set",
+			})
+		}
+	} else if cfg.TargetAddr == "" {
+		// A gateway may leave its own target empty when routes serve it.
+		if len(cfg.Routes) == 0 {
+			errs = append(errs, &ValidationError{
+				Field:   "target_addr",
+				Message: "cannot be empty",
+			})
+		}
 	} else {
 		if err := v.validateAddress(cfg.TargetAddr); err != nil {
 			errs = append(errs, &ValidationError{
@@ -168,6 +186,48 @@ func (v *Validator) ValidateProxyConfig(cfg config.ProxyConfig) error {
 		})
 	}
 
+	// Routes carry targets and tuning of their own; the config package knows
+	// how to check them, including overlapping unit IDs.
+	if len(cfg.Routes) > 0 {
+		if err := config.ValidateProxyRoutes(&cfg); err != nil {
+			errs = append(errs, &ValidationError{
+				Field:   "routes",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if err := config.ValidateProxyRewrite(&cfg); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "rewrite",
+			Message: err.Error(),
+		})
+	}
+	if err := config.ValidateProxyPolicy(&cfg); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "policy",
+			Message: err.Error(),
+		})
+	}
+	if err := config.ValidateProxyRateLimit(&cfg); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "rate_limit",
+			Message: err.Error(),
+		})
+	}
+	if err := config.ValidateProxyTLS(&cfg); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "tls",
+			Message: err.Error(),
+		})
+	}
+	if err := config.ValidateProxyPoints(&cfg); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "points",
+			Message: err.Error(),
+		})
+	}
+
 	if len(errs) > 0 {
 		return v.combineErrors(errs)
 	}
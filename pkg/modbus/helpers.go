@@ -21,6 +21,7 @@ const (
 	ExceptionIllegalDataAddress = 0x02
 	ExceptionIllegalDataValue   = 0x03
 	ExceptionSlaveDeviceFailure = 0x04
+	ExceptionServerDeviceBusy   = 0x06
 )
 
 // IsReadRequest checks if the frame is a Read Holding/Input Registers request.
@@ -61,6 +62,38 @@ func CreateReadRequest(txID uint16, unitID uint8, fc uint8, startAddr uint16, qu
 	return frame
 }
 
+// CreateWriteRequest constructs a Modbus TCP request that writes registers
+// from startAddr on: Write Single Register (0x06) for one value, Write
+// Multiple Registers (0x10) for up to 123.
+func CreateWriteRequest(txID uint16, unitID uint8, startAddr uint16, values []uint16) ([]byte, error) {
+	if len(values) == 0 || len(values) > 123 {
+		return nil, fmt.Errorf("cannot write %d registers at once", len(values))
+	}
+	if len(values) == 1 {
+		frame := make([]byte, 12)
+		binary.BigEndian.PutUint16(frame[0:2], txID)
+		binary.BigEndian.PutUint16(frame[4:6], 6)
+		frame[6] = unitID
+		frame[7] = FuncWriteSingleRegister
+		binary.BigEndian.PutUint16(frame[8:10], startAddr)
+		binary.BigEndian.PutUint16(frame[10:12], values[0])
+		return frame, nil
+	}
+	byteCount := 2 * len(values)
+	frame := make([]byte, 13+byteCount)
+	binary.BigEndian.PutUint16(frame[0:2], txID)
+	binary.BigEndian.PutUint16(frame[4:6], uint16(7+byteCount)) // UnitID + FC + Addr + Quantity + ByteCount + Data
+	frame[6] = unitID
+	frame[7] = FuncWriteMultipleRegisters
+	binary.BigEndian.PutUint16(frame[8:10], startAddr)
+	binary.BigEndian.PutUint16(frame[10:12], uint16(len(values)))
+	frame[12] = uint8(byteCount)
+	for i, v := range values {
+		binary.BigEndian.PutUint16(frame[13+2*i:], v)
+	}
+	return frame, nil
+}
+
 // Read and write function codes. Reads may be served from a cache; writes
 // change device state and must always reach the target.
 const (
@@ -55,6 +55,33 @@ func TestReadRequestHelpers(t *testing.T) {
 	}
 }
 
+func TestCreateWriteRequest(t *testing.T) {
+	single, err := CreateWriteRequest(7, 1, 40100, []uint16{500})
+	if err != nil {
+		t.Fatalf("CreateWriteRequest failed: %v", err)
+	}
+	want := []byte{0, 7, 0, 0, 0, 6, 1, FuncWriteSingleRegister, 0x9C, 0xA4, 0x01, 0xF4}
+	if !bytes.Equal(single, want) {
+		t.Errorf("single register: got % X, want % X", single, want)
+	}
+
+	multiple, err := CreateWriteRequest(7, 1, 40100, []uint16{0x0001, 0x86A0})
+	if err != nil {
+		t.Fatalf("CreateWriteRequest failed: %v", err)
+	}
+	want = []byte{0, 7, 0, 0, 0, 11, 1, FuncWriteMultipleRegisters, 0x9C, 0xA4, 0, 2, 4, 0x00, 0x01, 0x86, 0xA0}
+	if !bytes.Equal(multiple, want) {
+		t.Errorf("multiple registers: got % X, want % X", multiple, want)
+	}
+
+	if _, err := CreateWriteRequest(7, 1, 0, nil); err == nil {
+		t.Error("CreateWriteRequest accepted no values")
+	}
+	if _, err := CreateWriteRequest(7, 1, 0, make([]uint16, 124)); err == nil {
+		t.Error("CreateWriteRequest accepted more values than fit a frame")
+	}
+}
+
 func TestReadResponseHelpers(t *testing.T) {
 	txID := uint16(12345)
 	unitID := uint8(1)
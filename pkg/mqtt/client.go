@@ -0,0 +1,550 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package mqtt is a small MQTT 3.1.1 and 5.0 client, and the publisher that
+// puts the data points of the proxies on a broker and takes commands for them.
+// It implements only what that needs: one session, publishing and receiving
+// at QoS 0 to 2 with retain, subscriptions, a last will and keep-alive. A
+// full client library would be a large dependency for a handful of packet
+// types.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned for work on a connection that has ended.
+var ErrClosed = errors.New("mqtt: connection closed")
+
+// Message is an application message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Options describe a connection to a broker.
+type Options struct {
+	Addr            string      // host:port of the broker
+	TLS             *tls.Config // nil = plain TCP
+	ProtocolVersion byte        // Version311 (default) or Version5
+	ClientID        string
+	Username        string
+	Password        string
+	KeepAlive       time.Duration // 0 = 60s
+	ConnectTimeout  time.Duration // 0 = 10s
+	Will            *Message      // Published by the broker if the connection is lost
+
+	// OnMessage is called with every message received on a subscription,
+	// one after the other on a goroutine of its own. It may take its time:
+	// acknowledgements do not wait for it. Messages arriving while it is
+	// more than a queue behind are dropped.
+	OnMessage func(Message)
+}
+
+// Client is one connection to a broker. It does not reconnect: when Done is
+// closed the connection is gone, and the caller dials a new one.
+type Client struct {
+	opts Options
+	conn net.Conn
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan packet // Acknowledgements awaited, by packet ID
+	err     error
+
+	lastRecv  atomic.Int64    // Unix nanoseconds of the last packet from the broker
+	inbound   map[uint16]bool // QoS 2 messages received and not yet released, owned by readLoop
+	incoming  chan Message    // Messages waiting for OnMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Dial connects to a broker and completes the MQTT handshake.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.ProtocolVersion == 0 {
+		opts.ProtocolVersion = Version311
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.ConnectTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if opts.TLS != nil {
+		d := &tls.Dialer{Config: opts.TLS}
+		conn, err = d.DialContext(ctx, "tcp", opts.Addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", opts.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		opts:     opts,
+		conn:     conn,
+		pending:  make(map[uint16]chan packet),
+		inbound:  make(map[uint16]bool),
+		incoming: make(chan Message, 64),
+		done:     make(chan struct{}),
+	}
+	r := bufio.NewReader(conn)
+	if err := c.handshake(ctx, r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.lastRecv.Store(time.Now().UnixNano())
+	c.wg.Add(3)
+	go c.readLoop(r)
+	go c.keepAlive()
+	go c.deliver()
+	return c, nil
+}
+
+// handshake sends CONNECT and waits for CONNACK.
+func (c *Client) handshake(ctx context.Context, r *bufio.Reader) error {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write(c.connectPacket().encode()); err != nil {
+		return err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("mqtt: waiting for CONNACK: %w", err)
+	}
+	if p.kind != packetConnAck {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", p.kind)
+	}
+	d := decoder{buf: p.body}
+	d.byte() // Session present: this client always starts a clean session
+	code := d.byte()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		return fmt.Errorf("mqtt: broker refused the connection: %s", connectReason(c.opts.ProtocolVersion, code))
+	}
+	return nil
+}
+
+// connectPacket builds CONNECT. The session is always clean: the publisher
+// sends current state, and nothing is gained from a broker replaying old
+// messages.
+func (c *Client) connectPacket() packet {
+	v5 := c.opts.ProtocolVersion == Version5
+	flags := byte(0x02) // Clean session / clean start
+	if w := c.opts.Will; w != nil {
+		flags |= 0x04 | (w.QoS&0x03)<<3
+		if w.Retain {
+			flags |= 0x20
+		}
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, c.opts.ProtocolVersion, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.opts.KeepAlive/time.Second))
+	if v5 {
+		body = appendVarint(body, 0) // No properties
+	}
+	body = appendString(body, c.opts.ClientID)
+	if w := c.opts.Will; w != nil {
+		if v5 {
+			body = appendVarint(body, 0) // No will properties
+		}
+		body = appendString(body, w.Topic)
+		body = appendBytes(body, w.Payload)
+	}
+	if c.opts.Username != "" {
+		body = appendString(body, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		body = appendString(body, c.opts.Password)
+	}
+	return packet{kind: packetConnect, body: body}
+}
+
+// connectReason describes a CONNACK code.
+func connectReason(version, code byte) string {
+	if version == Version5 {
+		switch code {
+		case 0x84:
+			return "unsupported protocol version"
+		case 0x85:
+			return "client identifier not valid"
+		case 0x86:
+			return "bad user name or password"
+		case 0x87:
+			return "not authorized"
+		case 0x88:
+			return "server unavailable"
+		}
+		return fmt.Sprintf("reason code 0x%02X", code)
+	}
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("return code %d", code)
+}
+
+// Publish sends a message and, for QoS 1 and 2, waits until the broker has
+// taken it over.
+func (c *Client) Publish(ctx context.Context, msg Message) error {
+	if msg.QoS > 2 {
+		return fmt.Errorf("mqtt: invalid QoS %d", msg.QoS)
+	}
+	flags := msg.QoS << 1
+	if msg.Retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, msg.Topic)
+
+	if msg.QoS == 0 {
+		if c.opts.ProtocolVersion == Version5 {
+			body = appendVarint(body, 0)
+		}
+		return c.write(packet{kind: packetPublish, flags: flags, body: append(body, msg.Payload...)})
+	}
+
+	id, acks := c.await()
+	defer c.release(id)
+	body = binary.BigEndian.AppendUint16(body, id)
+	if c.opts.ProtocolVersion == Version5 {
+		body = appendVarint(body, 0)
+	}
+	if err := c.write(packet{kind: packetPublish, flags: flags, body: append(body, msg.Payload...)}); err != nil {
+		return err
+	}
+
+	want := byte(packetPubAck)
+	if msg.QoS == 2 {
+		want = packetPubRec
+	}
+	if err := c.ack(ctx, acks, want); err != nil {
+		return err
+	}
+	if msg.QoS == 2 {
+		rel := binary.BigEndian.AppendUint16(nil, id)
+		if err := c.write(packet{kind: packetPubRel, flags: 0x02, body: rel}); err != nil {
+			return err
+		}
+		return c.ack(ctx, acks, packetPubComp)
+	}
+	return nil
+}
+
+// await reserves a packet ID and the channel its acknowledgements arrive on.
+func (c *Client) await() (uint16, chan packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			continue // Zero is not a valid packet ID
+		}
+		if _, busy := c.pending[c.nextID]; !busy {
+			break
+		}
+	}
+	ch := make(chan packet, 2)
+	c.pending[c.nextID] = ch
+	return c.nextID, ch
+}
+
+func (c *Client) release(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// ack waits for one acknowledgement of a packet.
+func (c *Client) ack(ctx context.Context, acks chan packet, want byte) error {
+	p, err := c.receive(ctx, acks)
+	if err != nil {
+		return err
+	}
+	if p.kind != want {
+		return fmt.Errorf("mqtt: expected packet type %d, got %d", want, p.kind)
+	}
+	// MQTT 5 may append a reason code; 0x80 and above is a failure.
+	if len(p.body) > 2 && p.body[2] >= 0x80 {
+		return fmt.Errorf("mqtt: broker rejected the message: reason code 0x%02X", p.body[2])
+	}
+	return nil
+}
+
+// receive waits for the next packet on acks.
+func (c *Client) receive(ctx context.Context, acks chan packet) (packet, error) {
+	select {
+	case p := <-acks:
+		return p, nil
+	case <-c.done:
+		return packet{}, c.Err()
+	case <-ctx.Done():
+		return packet{}, ctx.Err()
+	}
+}
+
+// Subscribe subscribes to a topic filter and waits until the broker has
+// granted it. Messages arrive at Options.OnMessage. Sessions are clean, so
+// subscriptions end with the connection.
+func (c *Client) Subscribe(ctx context.Context, filter string, qos byte) error {
+	if qos > 2 {
+		return fmt.Errorf("mqtt: invalid QoS %d", qos)
+	}
+	id, acks := c.await()
+	defer c.release(id)
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	if c.opts.ProtocolVersion == Version5 {
+		body = appendVarint(body, 0)
+	}
+	body = appendString(body, filter)
+	body = append(body, qos) // For MQTT 5 the other option bits stay zero: local messages, no retain-as-published
+	if err := c.write(packet{kind: packetSubscribe, flags: 0x02, body: body}); err != nil {
+		return err
+	}
+
+	p, err := c.receive(ctx, acks)
+	if err != nil {
+		return err
+	}
+	if p.kind != packetSubAck {
+		return fmt.Errorf("mqtt: expected SUBACK, got packet type %d", p.kind)
+	}
+	d := decoder{buf: p.body}
+	d.uint16()
+	if c.opts.ProtocolVersion == Version5 {
+		d.skipProperties()
+	}
+	code := d.byte()
+	if d.err != nil {
+		return d.err
+	}
+	if code >= 0x80 {
+		return fmt.Errorf("mqtt: broker refused the subscription to %s: code 0x%02X", filter, code)
+	}
+	return nil
+}
+
+// write sends one packet. Packets from concurrent callers never interleave.
+func (c *Client) write(p packet) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.KeepAlive)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(p.encode()); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// readLoop hands acknowledgements to whoever waits for them.
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer c.wg.Done()
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.lastRecv.Store(time.Now().UnixNano())
+
+		switch p.kind {
+		case packetPublish:
+			if err := c.received(p); err != nil {
+				c.fail(err)
+				return
+			}
+		case packetPubRel:
+			// The broker releases a QoS 2 message; it was delivered when
+			// it arrived.
+			if len(p.body) < 2 {
+				c.fail(errMalformed)
+				return
+			}
+			delete(c.inbound, binary.BigEndian.Uint16(p.body))
+			if err := c.write(packet{kind: packetPubComp, body: p.body[:2]}); err != nil {
+				return
+			}
+		case packetPubAck, packetPubRec, packetPubComp, packetSubAck:
+			if len(p.body) < 2 {
+				c.fail(errMalformed)
+				return
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- p:
+				default:
+				}
+			}
+		case packetPingResp:
+		case packetDisconnect:
+			c.fail(errors.New("mqtt: broker closed the session"))
+			return
+		}
+	}
+}
+
+// received acknowledges a message from the broker and queues it for
+// OnMessage. A QoS 2 message the broker sends again before releasing it is
+// acknowledged again but delivered only once.
+func (c *Client) received(p packet) error {
+	qos := p.flags >> 1 & 0x03
+	d := decoder{buf: p.body}
+	msg := Message{Topic: d.string(), QoS: qos, Retain: p.flags&0x01 != 0}
+	var id uint16
+	if qos > 0 {
+		id = d.uint16()
+	}
+	if c.opts.ProtocolVersion == Version5 {
+		d.skipProperties()
+	}
+	if d.err != nil || qos > 2 {
+		return errMalformed
+	}
+	msg.Payload = d.buf
+
+	deliver := true
+	switch qos {
+	case 1:
+		if err := c.write(packet{kind: packetPubAck, body: binary.BigEndian.AppendUint16(nil, id)}); err != nil {
+			return err
+		}
+	case 2:
+		deliver = !c.inbound[id]
+		c.inbound[id] = true
+		if err := c.write(packet{kind: packetPubRec, body: binary.BigEndian.AppendUint16(nil, id)}); err != nil {
+			return err
+		}
+	}
+	if deliver && c.opts.OnMessage != nil {
+		select {
+		case c.incoming <- msg:
+		default: // OnMessage is hopelessly behind
+		}
+	}
+	return nil
+}
+
+// deliver hands received messages to OnMessage.
+func (c *Client) deliver() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.incoming:
+			c.opts.OnMessage(msg)
+		}
+	}
+}
+
+// keepAlive pings the broker when nothing else was sent, and gives the
+// connection up when the broker has been silent for one and a half periods.
+func (c *Client) keepAlive() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if time.Since(time.Unix(0, c.lastRecv.Load())) > c.opts.KeepAlive*3/2 {
+			c.fail(errors.New("mqtt: broker stopped answering"))
+			return
+		}
+		if err := c.write(packet{kind: packetPingReq}); err != nil {
+			return
+		}
+	}
+}
+
+// fail ends the connection with an error.
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Done is closed when the connection has ended.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return ErrClosed
+	}
+	return c.err
+}
+
+// Close disconnects cleanly, so the broker does not publish the will.
+func (c *Client) Close() error {
+	err := c.write(packet{kind: packetDisconnect})
+	c.fail(ErrClosed)
+	c.wg.Wait()
+	return err
+}
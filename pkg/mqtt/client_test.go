@@ -0,0 +1,380 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBroker is an in-process stand-in for a broker: it accepts sessions,
+// acknowledges what QoS asks for, records every message and can send
+// messages to the sessions that subscribed to them.
+type testBroker struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	messages    []Message
+	will        *Message
+	version     byte
+	username    string
+	disconnects int
+	sessions    []*testSession
+	published   chan Message
+	subscribed  chan string
+}
+
+// testSession is one client connection to the test broker.
+type testSession struct {
+	conn    net.Conn
+	version byte
+	writeMu sync.Mutex
+	filters []string // Guarded by the broker's mu
+}
+
+func (s *testSession) write(p packet) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.Write(p.encode())
+}
+
+func startTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test broker: %v", err)
+	}
+	b := &testBroker{listener: l, published: make(chan Message, 100), subscribed: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s := &testSession{conn: conn}
+			b.mu.Lock()
+			b.sessions = append(b.sessions, s)
+			b.mu.Unlock()
+			go b.serve(s)
+		}
+	}()
+	t.Cleanup(b.close)
+	return b
+}
+
+func (b *testBroker) addr() string {
+	return b.listener.Addr().String()
+}
+
+func (b *testBroker) close() {
+	b.listener.Close()
+	b.dropAll()
+}
+
+// dropAll cuts every session without a word, as a crashed broker would.
+func (b *testBroker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sessions {
+		s.conn.Close()
+	}
+	b.sessions = nil
+}
+
+func (b *testBroker) serve(s *testSession) {
+	defer s.conn.Close()
+	r := bufio.NewReader(s.conn)
+
+	p, err := readPacket(r)
+	if err != nil || p.kind != packetConnect {
+		return
+	}
+	s.version = b.parseConnect(p.body)
+	connack := []byte{0, 0}
+	if s.version == Version5 {
+		connack = append(connack, 0)
+	}
+	s.write(packet{kind: packetConnAck, body: connack})
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			msg, id := parsePublish(p, s.version)
+			b.mu.Lock()
+			b.messages = append(b.messages, msg)
+			b.mu.Unlock()
+			b.published <- msg
+			switch msg.QoS {
+			case 1:
+				s.write(packet{kind: packetPubAck, body: binary.BigEndian.AppendUint16(nil, id)})
+			case 2:
+				s.write(packet{kind: packetPubRec, body: binary.BigEndian.AppendUint16(nil, id)})
+			}
+		case packetPubRel:
+			s.write(packet{kind: packetPubComp, body: p.body[:2]})
+		case packetSubscribe:
+			d := decoder{buf: p.body}
+			id := d.uint16()
+			if s.version == Version5 {
+				d.skipProperties()
+			}
+			filter := d.string()
+			qos := d.byte()
+			b.mu.Lock()
+			s.filters = append(s.filters, filter)
+			b.mu.Unlock()
+			suback := binary.BigEndian.AppendUint16(nil, id)
+			if s.version == Version5 {
+				suback = append(suback, 0)
+			}
+			s.write(packet{kind: packetSubAck, body: append(suback, qos)})
+			b.subscribed <- filter
+		case packetPingReq:
+			s.write(packet{kind: packetPingResp})
+		case packetDisconnect:
+			b.mu.Lock()
+			b.disconnects++
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// send delivers a message to every session subscribed to its topic, with
+// the given packet ID for QoS 1 and 2. It never releases a QoS 2 message, so
+// sending one again with the same ID makes a duplicate.
+func (b *testBroker) send(msg Message, id uint16) {
+	b.mu.Lock()
+	var to []*testSession
+	for _, s := range b.sessions {
+		for _, f := range s.filters {
+			if topicMatches(f, msg.Topic) {
+				to = append(to, s)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, s := range to {
+		flags := msg.QoS << 1
+		if msg.Retain {
+			flags |= 0x01
+		}
+		body := appendString(nil, msg.Topic)
+		if msg.QoS > 0 {
+			body = binary.BigEndian.AppendUint16(body, id)
+		}
+		if s.version == Version5 {
+			body = appendVarint(body, 0)
+		}
+		s.write(packet{kind: packetPublish, flags: flags, body: append(body, msg.Payload...)})
+	}
+}
+
+// topicMatches matches a topic against a filter with + and # wildcards.
+func topicMatches(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// nextSubscription waits for the next subscription the broker grants.
+func (b *testBroker) nextSubscription(t *testing.T) string {
+	t.Helper()
+	select {
+	case filter := <-b.subscribed:
+		return filter
+	case <-time.After(5 * time.Second):
+		t.Fatal("broker received no subscription")
+		return ""
+	}
+}
+
+func (b *testBroker) parseConnect(body []byte) byte {
+	d := decoder{buf: body}
+	d.string()
+	version := d.byte()
+	flags := d.byte()
+	d.uint16()
+	if version == Version5 {
+		d.skipProperties()
+	}
+	d.string()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.version = version
+	if flags&0x04 != 0 {
+		if version == Version5 {
+			d.skipProperties()
+		}
+		topic := d.string()
+		payload := d.string()
+		b.will = &Message{Topic: topic, Payload: []byte(payload), QoS: flags >> 3 & 0x03, Retain: flags&0x20 != 0}
+	}
+	if flags&0x80 != 0 {
+		b.username = d.string()
+	}
+	return version
+}
+
+func parsePublish(p packet, version byte) (Message, uint16) {
+	d := decoder{buf: p.body}
+	msg := Message{Topic: d.string(), QoS: p.flags >> 1 & 0x03, Retain: p.flags&0x01 != 0}
+	var id uint16
+	if msg.QoS > 0 {
+		id = d.uint16()
+	}
+	if version == Version5 {
+		d.skipProperties()
+	}
+	msg.Payload = d.buf
+	return msg, id
+}
+
+// next waits for the next message the broker receives.
+func (b *testBroker) next(t *testing.T) Message {
+	t.Helper()
+	select {
+	case msg := <-b.published:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("broker received no message")
+		return Message{}
+	}
+}
+
+func TestClientPublishesAtEveryQoS(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		broker := startTestBroker(t)
+		will := &Message{Topic: "modbridge/status", Payload: []byte("offline"), QoS: 1, Retain: true}
+		c, err := Dial(context.Background(), Options{Addr: broker.addr(), ProtocolVersion: version, ClientID: "test", Username: "user", Password: "secret", Will: will})
+		if err != nil {
+			t.Fatalf("v%d: Dial() error = %v", version, err)
+		}
+
+		for qos := byte(0); qos <= 2; qos++ {
+			msg := Message{Topic: "modbridge/p1/power", Payload: []byte("230.5"), QoS: qos, Retain: qos == 1}
+			if err := c.Publish(context.Background(), msg); err != nil {
+				t.Fatalf("v%d: Publish(QoS %d) error = %v", version, qos, err)
+			}
+			got := broker.next(t)
+			if got.Topic != msg.Topic || string(got.Payload) != "230.5" || got.QoS != qos || got.Retain != msg.Retain {
+				t.Errorf("v%d: broker got %+v, want %+v", version, got, msg)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("v%d: Close() error = %v", version, err)
+		}
+
+		broker.mu.Lock()
+		if broker.version != version {
+			t.Errorf("broker saw protocol level %d, want %d", broker.version, version)
+		}
+		if broker.will == nil || broker.will.Topic != will.Topic || string(broker.will.Payload) != "offline" || !broker.will.Retain {
+			t.Errorf("v%d: broker got will %+v, want %+v", version, broker.will, will)
+		}
+		if broker.username != "user" {
+			t.Errorf("v%d: broker got user name %q", version, broker.username)
+		}
+		broker.mu.Unlock()
+	}
+}
+
+func TestClientReceivesSubscribedMessages(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		broker := startTestBroker(t)
+		received := make(chan Message, 10)
+		c, err := Dial(context.Background(), Options{Addr: broker.addr(), ProtocolVersion: version, ClientID: "test", OnMessage: func(msg Message) { received <- msg }})
+		if err != nil {
+			t.Fatalf("v%d: Dial() error = %v", version, err)
+		}
+		if err := c.Subscribe(context.Background(), "modbridge/+/+/set", 2); err != nil {
+			t.Fatalf("v%d: Subscribe() error = %v", version, err)
+		}
+
+		for qos := byte(0); qos <= 2; qos++ {
+			broker.send(Message{Topic: "modbridge/p1/limit/set", Payload: []byte{'0' + qos}, QoS: qos}, 10)
+		}
+		// A QoS 2 message sent again before it was released is a duplicate.
+		broker.send(Message{Topic: "modbridge/p1/limit/set", Payload: []byte("2"), QoS: 2}, 10)
+
+		for qos := byte(0); qos <= 2; qos++ {
+			select {
+			case msg := <-received:
+				if msg.Topic != "modbridge/p1/limit/set" || string(msg.Payload) != string([]byte{'0' + qos}) || msg.QoS != qos {
+					t.Errorf("v%d: received %s %q (QoS %d), want payload %d at QoS %d", version, msg.Topic, msg.Payload, msg.QoS, qos, qos)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("v%d: no message at QoS %d", version, qos)
+			}
+		}
+		select {
+		case msg := <-received:
+			t.Errorf("v%d: unexpected message %s %q", version, msg.Topic, msg.Payload)
+		case <-time.After(100 * time.Millisecond):
+		}
+		c.Close()
+	}
+}
+
+func TestClientNoticesLostConnection(t *testing.T) {
+	broker := startTestBroker(t)
+	c, err := Dial(context.Background(), Options{Addr: broker.addr(), ClientID: "test"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+
+	broker.dropAll()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not notice the broker going away")
+	}
+	if err := c.Publish(context.Background(), Message{Topic: "t", QoS: 1}); err == nil {
+		t.Error("Publish() succeeded on a lost connection")
+	}
+}
+
+func TestClientRefusedConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readPacket(bufio.NewReader(conn))
+		conn.Write(packet{kind: packetConnAck, body: []byte{0, 4}}.encode())
+	}()
+
+	if _, err := Dial(context.Background(), Options{Addr: l.Addr().String(), ClientID: "test"}); err == nil {
+		t.Error("Dial() succeeded although the broker refused the login")
+	}
+}
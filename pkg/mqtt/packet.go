@@ -0,0 +1,185 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Control packet types (MQTT 3.1.1 section 2.2.1, unchanged in 5.0).
+const (
+	packetConnect     = 1
+	packetConnAck     = 2
+	packetPublish     = 3
+	packetPubAck      = 4
+	packetPubRec      = 5
+	packetPubRel      = 6
+	packetPubComp     = 7
+	packetSubscribe   = 8
+	packetSubAck      = 9
+	packetUnsubscribe = 10
+	packetUnsubAck    = 11
+	packetPingReq     = 12
+	packetPingResp    = 13
+	packetDisconnect  = 14
+)
+
+// Protocol levels sent in CONNECT.
+const (
+	Version311 = 4
+	Version5   = 5
+)
+
+// maxPacketSize bounds what is accepted from a broker. Nothing this client
+// subscribes to comes close; a larger packet means a confused peer.
+const maxPacketSize = 1 << 20
+
+var errMalformed = errors.New("mqtt: malformed packet")
+
+// packet is one control packet: the type and flags of the fixed header and
+// everything after the remaining length.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads one control packet.
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, err := readVarint(r)
+	if err != nil {
+		return packet{}, err
+	}
+	if length > maxPacketSize {
+		return packet{}, fmt.Errorf("mqtt: packet of %d bytes exceeds the limit", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0F, body: body}, nil
+}
+
+// encode returns the packet as it goes on the wire.
+func (p packet) encode() []byte {
+	out := make([]byte, 0, len(p.body)+5)
+	out = append(out, p.kind<<4|p.flags)
+	out = appendVarint(out, len(p.body))
+	return append(out, p.body...)
+}
+
+// readVarint reads a variable byte integer: seven bits per byte, at most
+// four bytes.
+func readVarint(r io.ByteReader) (int, error) {
+	value, shift := 0, 0
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+		shift += 7
+	}
+	return 0, errMalformed
+}
+
+func appendVarint(b []byte, v int) []byte {
+	for {
+		digit := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// decoder reads the fields of a packet body in order. The first failure
+// sticks, so a caller checks err once at the end.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) < 1 {
+		d.err = errMalformed
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.buf) < 2 {
+		d.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.buf)
+	d.buf = d.buf[2:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := int(d.uint16())
+	if d.err != nil || len(d.buf) < n {
+		d.err = errMalformed
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+// skipProperties skips an MQTT 5 property block. This client sends none and
+// needs none of what a broker sends back.
+func (d *decoder) skipProperties() {
+	if d.err != nil {
+		return
+	}
+	r := &sliceReader{buf: d.buf}
+	n, err := readVarint(r)
+	if err != nil || len(r.buf) < n {
+		d.err = errMalformed
+		return
+	}
+	d.buf = r.buf[n:]
+}
+
+type sliceReader struct{ buf []byte }
+
+func (s *sliceReader) ReadByte() (byte, error) {
+	if len(s.buf) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := s.buf[0]
+	s.buf = s.buf[1:]
+	return b, nil
+}
@@ -0,0 +1,478 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"modbridge/pkg/logger"
+	"modbridge/pkg/mapping"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PublisherConfig describes what the publisher sends and where.
+type PublisherConfig struct {
+	Options
+	TopicPrefix     string        // First topic level (default: modbridge)
+	QoS             byte          // QoS of every message
+	Retain          bool          // Publish values as retained messages
+	Interval        time.Duration // Publish every point at this interval even if unchanged (0 = on change only)
+	Discovery       string        // "homeassistant" publishes discovery configs
+	DiscoveryPrefix string        // Default: homeassistant
+}
+
+// ProxyRef names a proxy whose data points are published.
+type ProxyRef struct {
+	ID   string
+	Name string
+}
+
+// Reading is the current value of one data point. Err is set instead of
+// Value when it could not be read. Min and Max bound what a writable numeric
+// point can be set to.
+type Reading struct {
+	Point    mapping.Mapping
+	Value    interface{}
+	Err      string
+	Min, Max float64
+}
+
+// Source is where the publisher gets its data points from.
+type Source interface {
+	// PointProxies lists the proxies that have data points.
+	PointProxies() []ProxyRef
+	// ReadPoints reads every data point of a proxy.
+	ReadPoints(proxyID string) ([]Reading, error)
+	// WritePoint sets a data point of a proxy to the value in a command
+	// payload.
+	WritePoint(proxyID, point string, payload []byte) error
+}
+
+// Publisher keeps a broker up to date with the data points of the proxies.
+//
+// Values go out when a proxy's background poller has refreshed its cache:
+// the reads are then answered from the cache, so publishing adds no traffic
+// to the device. Only values that changed are sent, except on a new
+// connection and on Interval, when every point is.
+//
+// A message on <prefix>/<proxy ID>/<point>/set writes the point. Whether
+// the point may be written is up to the Source.
+type Publisher struct {
+	cfg    PublisherConfig
+	source Source
+	log    *logger.Logger
+
+	mu      sync.Mutex
+	pending map[string]bool // Proxies refreshed since the last pass
+	wake    chan struct{}
+
+	// Owned by the run loop.
+	last  map[string]string            // Last payload per state topic
+	known map[string]map[string]string // Discovery component per point name published, per proxy
+
+	connected atomic.Bool
+	published atomic.Int64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPublisher creates a publisher. It does nothing until Start.
+func NewPublisher(cfg PublisherConfig, source Source, log *logger.Logger) *Publisher {
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "modbridge"
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = "homeassistant"
+	}
+	cfg.Will = &Message{Topic: cfg.TopicPrefix + "/status", Payload: []byte("offline"), QoS: cfg.QoS, Retain: true}
+	p := &Publisher{
+		source:  source,
+		log:     log,
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	cfg.OnMessage = p.command
+	p.cfg = cfg
+	return p
+}
+
+// Start connects to the broker in the background and keeps reconnecting
+// until Stop.
+func (p *Publisher) Start() {
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	p.wg.Add(1)
+	go p.run(ctx)
+}
+
+// Stop marks the publisher offline on the broker and disconnects.
+func (p *Publisher) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// Refreshed tells the publisher that a proxy's poller has finished a round.
+// It never blocks: the poller must not wait for the broker.
+func (p *Publisher) Refreshed(proxyID string) {
+	p.mu.Lock()
+	p.pending[proxyID] = true
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats reports whether the publisher is connected and how many values it
+// has published.
+func (p *Publisher) Stats() (connected bool, published int64) {
+	return p.connected.Load(), p.published.Load()
+}
+
+func (p *Publisher) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	backoff := time.Second
+	for {
+		client, err := Dial(ctx, p.cfg.Options)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.log.Warn("MQTT", fmt.Sprintf("Cannot connect to broker %s: %v (retrying in %v)", p.cfg.Addr, err, backoff))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > time.Minute {
+				backoff = time.Minute
+			}
+			continue
+		}
+		backoff = time.Second
+		p.log.Info("MQTT", fmt.Sprintf("Connected to broker %s", p.cfg.Addr))
+
+		p.serve(ctx, client)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// serve publishes over one connection until it ends or the publisher stops.
+func (p *Publisher) serve(ctx context.Context, client *Client) {
+	p.connected.Store(true)
+	defer p.connected.Store(false)
+
+	// A new session starts from scratch: the broker may have been restarted
+	// and lost everything that was not retained.
+	p.last = make(map[string]string)
+	p.known = make(map[string]map[string]string)
+
+	err := client.Publish(ctx, p.status("online"))
+	if err == nil {
+		err = client.Subscribe(ctx, p.cfg.TopicPrefix+"/+/+/set", p.cfg.QoS)
+	}
+	if err == nil {
+		err = p.publishAll(ctx, client, true)
+	}
+
+	var tick <-chan time.Time
+	if p.cfg.Interval > 0 {
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-client.Done():
+			err = client.Err()
+		case <-tick:
+			err = p.publishAll(ctx, client, true)
+		case <-p.wake:
+			err = p.publishPending(ctx, client)
+		}
+	}
+
+	if ctx.Err() == nil {
+		p.log.Warn("MQTT", fmt.Sprintf("Connection to broker %s lost: %v", p.cfg.Addr, err))
+	} else {
+		// Say goodbye ourselves, also when Stop interrupted a publish: a
+		// clean disconnect does not trigger the will.
+		byeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := client.Publish(byeCtx, p.status("offline")); err != nil {
+			p.log.Warn("MQTT", fmt.Sprintf("Failed to publish offline status: %v", err))
+		}
+		cancel()
+	}
+	client.Close()
+}
+
+// status is the availability message of the publisher.
+func (p *Publisher) status(state string) Message {
+	return Message{Topic: p.cfg.TopicPrefix + "/status", Payload: []byte(state), QoS: p.cfg.QoS, Retain: true}
+}
+
+// publishAll publishes the points of every proxy and clears the topics of
+// proxies that no longer have any.
+func (p *Publisher) publishAll(ctx context.Context, client *Client, force bool) error {
+	p.mu.Lock()
+	p.pending = make(map[string]bool)
+	p.mu.Unlock()
+
+	proxies := p.source.PointProxies()
+	seen := make(map[string]bool, len(proxies))
+	for _, ref := range proxies {
+		seen[ref.ID] = true
+		if err := p.publishProxy(ctx, client, ref, force); err != nil {
+			return err
+		}
+	}
+	for proxyID := range p.known {
+		if !seen[proxyID] {
+			if err := p.clear(ctx, client, proxyID, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// publishPending publishes the points of the proxies refreshed since the
+// last pass, where they changed.
+func (p *Publisher) publishPending(ctx context.Context, client *Client) error {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[string]bool)
+	p.mu.Unlock()
+
+	for _, ref := range p.source.PointProxies() {
+		if !pending[ref.ID] {
+			continue
+		}
+		if err := p.publishProxy(ctx, client, ref, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishProxy publishes the points of one proxy. Points that cannot be read
+// keep their last published value.
+func (p *Publisher) publishProxy(ctx context.Context, client *Client, ref ProxyRef, force bool) error {
+	readings, err := p.source.ReadPoints(ref.ID)
+	if err != nil {
+		p.log.Debug("MQTT", fmt.Sprintf("Skipping points of proxy %s: %v", ref.ID, err))
+		return nil
+	}
+
+	known := p.known[ref.ID]
+	if known == nil {
+		known = make(map[string]string)
+		p.known[ref.ID] = known
+	}
+	current := make(map[string]bool, len(readings))
+
+	for _, r := range readings {
+		current[r.Point.Name] = true
+		if r.Err != "" || r.Value == nil {
+			continue
+		}
+		component := discoveryComponent(r.Point)
+		if p.cfg.Discovery == "homeassistant" && known[r.Point.Name] != component {
+			if old, ok := known[r.Point.Name]; ok {
+				// The point became writable or stopped being: the old
+				// entity goes, the new one comes.
+				msg := Message{Topic: p.discoveryTopic(old, ref.ID, r.Point.Name), QoS: p.cfg.QoS, Retain: true}
+				if err := client.Publish(ctx, msg); err != nil {
+					return err
+				}
+			}
+			if err := client.Publish(ctx, p.discoveryMessage(ref, r)); err != nil {
+				return err
+			}
+		}
+		known[r.Point.Name] = component
+
+		topic := p.stateTopic(ref.ID, r.Point.Name)
+		payload := formatValue(r.Value)
+		if last, ok := p.last[topic]; ok && last == payload && !force {
+			continue
+		}
+		msg := Message{Topic: topic, Payload: []byte(payload), QoS: p.cfg.QoS, Retain: p.cfg.Retain}
+		if err := client.Publish(ctx, msg); err != nil {
+			return err
+		}
+		p.last[topic] = payload
+		p.published.Add(1)
+	}
+	return p.clear(ctx, client, ref.ID, current)
+}
+
+// clear removes the retained messages of the points of a proxy that are not
+// in keep, so a deleted point does not linger on the broker or in Home
+// Assistant.
+func (p *Publisher) clear(ctx context.Context, client *Client, proxyID string, keep map[string]bool) error {
+	for name, component := range p.known[proxyID] {
+		if keep[name] {
+			continue
+		}
+		topic := p.stateTopic(proxyID, name)
+		if p.cfg.Retain {
+			if err := client.Publish(ctx, Message{Topic: topic, QoS: p.cfg.QoS, Retain: true}); err != nil {
+				return err
+			}
+		}
+		if p.cfg.Discovery == "homeassistant" {
+			msg := Message{Topic: p.discoveryTopic(component, proxyID, name), QoS: p.cfg.QoS, Retain: true}
+			if err := client.Publish(ctx, msg); err != nil {
+				return err
+			}
+		}
+		delete(p.known[proxyID], name)
+		delete(p.last, topic)
+	}
+	if len(p.known[proxyID]) == 0 {
+		delete(p.known, proxyID)
+	}
+	return nil
+}
+
+// stateTopic is where the value of a point is published:
+// <prefix>/<proxy ID>/<point name>.
+func (p *Publisher) stateTopic(proxyID, point string) string {
+	return p.cfg.TopicPrefix + "/" + topicLevel(proxyID) + "/" + topicLevel(point)
+}
+
+// commandTopic is where a value for a point is sent to be written.
+func (p *Publisher) commandTopic(proxyID, point string) string {
+	return p.stateTopic(proxyID, point) + "/set"
+}
+
+func (p *Publisher) discoveryTopic(component, proxyID, point string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/config", p.cfg.DiscoveryPrefix, component, objectID("modbridge_"+proxyID), objectID(point))
+}
+
+// discoveryComponent is the kind of Home Assistant entity a point becomes:
+// a number that can be set if it is writable, a read-only sensor otherwise.
+func discoveryComponent(pt mapping.Mapping) string {
+	if pt.Writable && pt.DataType != "string" {
+		return "number"
+	}
+	return "sensor"
+}
+
+// discoveryMessage announces a point to Home Assistant. The device is the
+// proxy, so its points are grouped together.
+func (p *Publisher) discoveryMessage(ref ProxyRef, r Reading) Message {
+	pt := r.Point
+	node := objectID("modbridge_" + ref.ID)
+	name := pt.Description
+	if name == "" {
+		name = pt.Name
+	}
+	deviceName := ref.Name
+	if deviceName == "" {
+		deviceName = ref.ID
+	}
+	config := map[string]interface{}{
+		"name":                  name,
+		"unique_id":             node + "_" + objectID(pt.Name),
+		"state_topic":           p.stateTopic(ref.ID, pt.Name),
+		"availability_topic":    p.cfg.TopicPrefix + "/status",
+		"payload_available":     "online",
+		"payload_not_available": "offline",
+		"device": map[string]interface{}{
+			"identifiers":  []string{node},
+			"name":         deviceName,
+			"manufacturer": "ModBridge",
+			"model":        "Modbus proxy",
+		},
+	}
+	if pt.Unit != "" {
+		config["unit_of_measurement"] = pt.Unit
+	}
+	if pt.DataType != "string" {
+		config["state_class"] = "measurement"
+	}
+	component := discoveryComponent(pt)
+	if component == "number" {
+		config["command_topic"] = p.commandTopic(ref.ID, pt.Name)
+		config["min"] = r.Min
+		config["max"] = r.Max
+		config["step"] = math.Max(pt.Step(), 0.001) // The finest step Home Assistant accepts
+		config["mode"] = "box"
+	}
+	payload, _ := json.Marshal(config)
+	return Message{Topic: p.discoveryTopic(component, ref.ID, pt.Name), Payload: payload, QoS: p.cfg.QoS, Retain: true}
+}
+
+// command writes a point on a message to its command topic. Retained
+// commands are ignored: the broker would replay them on every reconnect and
+// set the point again, long after whoever sent it meant to.
+func (p *Publisher) command(msg Message) {
+	rest, isPrefixed := strings.CutPrefix(msg.Topic, p.cfg.TopicPrefix+"/")
+	rest, isSet := strings.CutSuffix(rest, "/set")
+	levels := strings.Split(rest, "/")
+	if !isPrefixed || !isSet || len(levels) != 2 {
+		return
+	}
+	if msg.Retain {
+		p.log.Warn("MQTT", fmt.Sprintf("Ignoring retained command on %s", msg.Topic))
+		return
+	}
+
+	for _, ref := range p.source.PointProxies() {
+		if topicLevel(ref.ID) != levels[0] {
+			continue
+		}
+		if err := p.source.WritePoint(ref.ID, levels[1], msg.Payload); err != nil {
+			p.log.Warn("MQTT", fmt.Sprintf("Command on %s failed: %v", msg.Topic, err))
+		}
+		return
+	}
+	p.log.Debug("MQTT", fmt.Sprintf("Ignoring command on %s: no such proxy", msg.Topic))
+}
+
+// formatValue renders a decoded value as a payload: numbers in their
+// shortest exact form, strings as they are.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// topicLevel makes a name safe to use as one topic level.
+func topicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
+
+// objectID makes a name safe to use as a Home Assistant node or object ID.
+func objectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
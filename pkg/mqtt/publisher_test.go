@@ -0,0 +1,187 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package mqtt
+
+import (
+	"encoding/json"
+	"modbridge/pkg/logger"
+	"modbridge/pkg/mapping"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSource serves fixed readings for one proxy and records writes.
+type testSource struct {
+	mu       sync.Mutex
+	readings []Reading
+	writes   chan string
+}
+
+func (s *testSource) PointProxies() []ProxyRef {
+	return []ProxyRef{{ID: "p1", Name: "Inverter"}}
+}
+
+func (s *testSource) ReadPoints(proxyID string) ([]Reading, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Reading(nil), s.readings...), nil
+}
+
+func (s *testSource) WritePoint(proxyID, point string, payload []byte) error {
+	s.writes <- proxyID + "/" + point + "=" + string(payload)
+	return nil
+}
+
+func (s *testSource) set(readings ...Reading) {
+	s.mu.Lock()
+	s.readings = readings
+	s.mu.Unlock()
+}
+
+func reading(name, unit string, value interface{}) Reading {
+	return Reading{Point: mapping.Mapping{Name: name, DataType: "int32", Unit: unit, Enabled: true}, Value: value}
+}
+
+func TestPublisherPublishesChanges(t *testing.T) {
+	broker := startTestBroker(t)
+	source := &testSource{}
+	source.set(reading("pv_power_w", "W", 1200.0), reading("grid_w", "W", -300.5))
+
+	pub := NewPublisher(PublisherConfig{
+		Options:   Options{Addr: broker.addr(), ClientID: "test"},
+		QoS:       1,
+		Retain:    true,
+		Discovery: "homeassistant",
+	}, source, logger.NewNullLogger(100))
+	pub.Start()
+
+	if msg := broker.next(t); msg.Topic != "modbridge/status" || string(msg.Payload) != "online" || !msg.Retain {
+		t.Fatalf("first message = %s %q, want the retained online status", msg.Topic, msg.Payload)
+	}
+
+	// Every point is announced to Home Assistant before its first value.
+	for _, want := range []struct{ discovery, topic, payload string }{
+		{"homeassistant/sensor/modbridge_p1/pv_power_w/config", "modbridge/p1/pv_power_w", "1200"},
+		{"homeassistant/sensor/modbridge_p1/grid_w/config", "modbridge/p1/grid_w", "-300.5"},
+	} {
+		config := broker.next(t)
+		if config.Topic != want.discovery || !config.Retain {
+			t.Fatalf("got %s, want the retained discovery config %s", config.Topic, want.discovery)
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal(config.Payload, &payload); err != nil {
+			t.Fatalf("discovery payload is not JSON: %v", err)
+		}
+		if payload["state_topic"] != want.topic || payload["unit_of_measurement"] != "W" || payload["availability_topic"] != "modbridge/status" {
+			t.Errorf("discovery payload = %v", payload)
+		}
+		if msg := broker.next(t); msg.Topic != want.topic || string(msg.Payload) != want.payload || msg.QoS != 1 || !msg.Retain {
+			t.Errorf("got %s %q (QoS %d, retain %v), want %s %q", msg.Topic, msg.Payload, msg.QoS, msg.Retain, want.topic, want.payload)
+		}
+	}
+
+	// A refresh with one changed value publishes only that one.
+	source.set(reading("pv_power_w", "W", 1200.0), reading("grid_w", "W", 50.0))
+	pub.Refreshed("p1")
+	if msg := broker.next(t); msg.Topic != "modbridge/p1/grid_w" || string(msg.Payload) != "50" {
+		t.Errorf("after refresh got %s %q, want modbridge/p1/grid_w \"50\"", msg.Topic, msg.Payload)
+	}
+
+	// A removed point is cleared from the broker and from Home Assistant.
+	source.set(reading("pv_power_w", "W", 1200.0))
+	pub.Refreshed("p1")
+	for _, topic := range []string{"modbridge/p1/grid_w", "homeassistant/sensor/modbridge_p1/grid_w/config"} {
+		if msg := broker.next(t); msg.Topic != topic || len(msg.Payload) != 0 || !msg.Retain {
+			t.Errorf("got %s %q, want an empty retained message on %s", msg.Topic, msg.Payload, topic)
+		}
+	}
+
+	pub.Stop()
+	if msg := broker.next(t); msg.Topic != "modbridge/status" || string(msg.Payload) != "offline" {
+		t.Errorf("last message = %s %q, want the offline status", msg.Topic, msg.Payload)
+	}
+	select {
+	case msg := <-broker.published:
+		t.Errorf("unexpected message after stop: %s %q", msg.Topic, msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, published := pub.Stats(); published != 3 {
+		t.Errorf("published %d values, want 3", published)
+	}
+}
+
+func TestPublisherWritesCommands(t *testing.T) {
+	broker := startTestBroker(t)
+	source := &testSource{writes: make(chan string, 10)}
+	limit := reading("charge_limit", "%", 80.0)
+	limit.Point.Writable = true
+	limit.Point.ScaleFactor = 0.5
+	limit.Min, limit.Max = 0, 100
+	source.set(limit)
+
+	pub := NewPublisher(PublisherConfig{Options: Options{Addr: broker.addr(), ClientID: "test"}, Discovery: "homeassistant"}, source, logger.NewNullLogger(100))
+	pub.Start()
+	defer pub.Stop()
+
+	if filter := broker.nextSubscription(t); filter != "modbridge/+/+/set" {
+		t.Errorf("subscribed to %s, want modbridge/+/+/set", filter)
+	}
+	broker.next(t) // online
+
+	// A writable point becomes a number in Home Assistant.
+	config := broker.next(t)
+	if config.Topic != "homeassistant/number/modbridge_p1/charge_limit/config" {
+		t.Fatalf("got %s, want the discovery config of a number", config.Topic)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config.Payload, &payload); err != nil {
+		t.Fatalf("discovery payload is not JSON: %v", err)
+	}
+	if payload["command_topic"] != "modbridge/p1/charge_limit/set" || payload["min"] != 0.0 || payload["max"] != 100.0 || payload["step"] != 0.5 {
+		t.Errorf("discovery payload = %v", payload)
+	}
+	broker.next(t) // value
+
+	// Retained commands and commands for unknown proxies are ignored; the
+	// write after them must be the first one to arrive.
+	broker.send(Message{Topic: "modbridge/p1/charge_limit/set", Payload: []byte("10"), Retain: true}, 0)
+	broker.send(Message{Topic: "modbridge/p2/charge_limit/set", Payload: []byte("20")}, 0)
+	broker.send(Message{Topic: "modbridge/p1/charge_limit/set", Payload: []byte("65"), QoS: 1}, 1)
+	select {
+	case write := <-source.writes:
+		if write != "p1/charge_limit=65" {
+			t.Errorf("source got write %s, want p1/charge_limit=65", write)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command was not written")
+	}
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	broker := startTestBroker(t)
+	source := &testSource{}
+	source.set(reading("pv_power_w", "W", 1200.0))
+
+	pub := NewPublisher(PublisherConfig{Options: Options{Addr: broker.addr(), ClientID: "test"}}, source, logger.NewNullLogger(100))
+	pub.Start()
+	defer pub.Stop()
+
+	broker.next(t) // online
+	if msg := broker.next(t); msg.Topic != "modbridge/p1/pv_power_w" {
+		t.Fatalf("got %s, want the point", msg.Topic)
+	}
+
+	// After a lost connection every value is sent again, changed or not:
+	// the broker may have lost them.
+	broker.dropAll()
+	if msg := broker.next(t); msg.Topic != "modbridge/status" || string(msg.Payload) != "online" {
+		t.Fatalf("after reconnect got %s %q, want the online status", msg.Topic, msg.Payload)
+	}
+	if msg := broker.next(t); msg.Topic != "modbridge/p1/pv_power_w" || string(msg.Payload) != "1200" {
+		t.Errorf("after reconnect got %s %q, want the point again", msg.Topic, msg.Payload)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package notify tells the operator by mail when something happens that
+// needs a person: a target that stops answering, a run of failed logins, a
+// new release.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"modbridge/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of events.
+const (
+	ProxyDown       = "proxy_down"       // A proxy stopped unexpectedly or its target stopped answering
+	ProxyUp         = "proxy_up"         // The proxy or its target is back
+	CircuitOpen     = "circuit_open"     // The circuit breaker towards a target opened
+	CalibrationDone = "calibration_done" // A calibration run finished
+	AuthFailures    = "auth_failures"    // Repeated failed logins from one address
+	UpdateAvailable = "update_available" // A newer release exists
+	AlertFired      = "alert_fired"      // An alert rule fired
+	AlertResolved   = "alert_resolved"   // The alert of a rule was resolved
+)
+
+// Severities of events.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// DefaultThrottle is how long an event of the same kind about the same thing
+// is not mailed again, unless configured otherwise.
+const DefaultThrottle = 15 * time.Minute
+
+// queueSize bounds the mails waiting for the SMTP server. When it is
+// unreachable, events past that are dropped instead of piling up.
+const queueSize = 100
+
+// Event is something an operator may want to hear about.
+type Event struct {
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	ProxyID  string    `json:"proxy_id,omitempty"`
+	Subject  string    `json:"subject"` // What it is about: a target, a client address, a version
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// key identifies the events that are throttled together.
+func (e Event) key() string {
+	return e.Kind + "|" + e.ProxyID + "|" + e.Subject
+}
+
+// Config says which events are mailed, and how.
+type Config struct {
+	SMTP      SMTPConfig
+	OnError   bool          // Mail errors, and the recovery that ends them
+	OnWarning bool          // Mail warnings
+	Throttle  time.Duration // Minimum time between two mails about the same thing (0 = DefaultThrottle)
+}
+
+// Stats counts what the notifier did.
+type Stats struct {
+	Sent       int64      `json:"sent"`
+	Failed     int64      `json:"failed"`
+	Throttled  int64      `json:"throttled"`
+	LastError  string     `json:"last_error,omitempty"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
+
+// throttleState remembers the last mail about one thing.
+type throttleState struct {
+	sent       time.Time
+	suppressed int // Events since then that were not mailed
+}
+
+// Notifier mails events on a goroutine of its own, so that whoever reports
+// one never waits for the SMTP server.
+type Notifier struct {
+	cfg   Config
+	log   *logger.Logger
+	queue chan Event
+	done  chan struct{}
+	stop  context.CancelFunc
+	ctx   context.Context
+
+	mu    sync.Mutex
+	last  map[string]*throttleState
+	stats Stats
+}
+
+// New creates a notifier. Start it to have events mailed.
+func New(cfg Config, log *logger.Logger) *Notifier {
+	if cfg.Throttle <= 0 {
+		cfg.Throttle = DefaultThrottle
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{
+		cfg:   cfg,
+		log:   log,
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+		ctx:   ctx,
+		stop:  cancel,
+		last:  make(map[string]*throttleState),
+	}
+}
+
+// Start begins mailing events.
+func (n *Notifier) Start() {
+	go n.run()
+}
+
+// Stop stops mailing. A mail on its way is abandoned, and waiting ones are
+// dropped.
+func (n *Notifier) Stop() {
+	n.stop()
+	<-n.done
+}
+
+// Stats returns what the notifier has done so far.
+func (n *Notifier) Stats() Stats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.stats
+}
+
+// Notify mails an event, unless the settings do not ask for its kind or the
+// same thing was mailed within the throttle time. It never blocks.
+func (n *Notifier) Notify(ev Event) {
+	if !n.wants(ev) {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	n.mu.Lock()
+	state := n.last[ev.key()]
+	if state != nil && ev.Time.Sub(state.sent) < n.cfg.Throttle {
+		state.suppressed++
+		n.stats.Throttled++
+		n.mu.Unlock()
+		return
+	}
+	if state != nil && state.suppressed > 0 {
+		ev.Message += fmt.Sprintf("\n\n%d more like this since %s were not mailed.",
+			state.suppressed, state.sent.Format(time.RFC1123))
+	}
+	n.last[ev.key()] = &throttleState{sent: ev.Time}
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- ev:
+	default:
+		n.log.Warn("NOTIFY", fmt.Sprintf("Mail queue full, dropped: %s", ev.Title))
+	}
+}
+
+// SendTest mails a test message now and reports whether the server took it.
+func (n *Notifier) SendTest(ctx context.Context) error {
+	body := "This is a test mail from ModBridge.\n\nIf you can read it, alerts will reach you."
+	err := SendMail(ctx, n.cfg.SMTP, "[ModBridge] Test mail", body)
+	n.record(err)
+	return err
+}
+
+// wants reports whether the settings ask for mail about an event. A
+// recovery is mailed whenever the failure it ends would be.
+func (n *Notifier) wants(ev Event) bool {
+	if ev.Kind == ProxyUp {
+		return n.cfg.OnError
+	}
+	switch ev.Severity {
+	case SeverityError:
+		return n.cfg.OnError
+	case SeverityWarning:
+		return n.cfg.OnWarning
+	}
+	return true
+}
+
+// run mails queued events until the notifier stops.
+func (n *Notifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case ev := <-n.queue:
+			err := SendMail(n.ctx, n.cfg.SMTP, mailSubject(ev), mailBody(ev))
+			n.record(err)
+			if err != nil && n.ctx.Err() == nil {
+				n.log.Error("NOTIFY", fmt.Sprintf("Failed to mail %q: %v", ev.Title, err))
+			}
+		}
+	}
+}
+
+// record counts a delivery.
+func (n *Notifier) record(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.stats.Failed++
+		n.stats.LastError = err.Error()
+		return
+	}
+	now := time.Now()
+	n.stats.Sent++
+	n.stats.LastError = ""
+	n.stats.LastSentAt = &now
+}
+
+// mailSubject returns the subject line of an event's mail. A resolved alert
+// keeps the severity it fired with, so that it is mailed whenever the alert
+// was, but says it is resolved.
+func mailSubject(ev Event) string {
+	if ev.Kind == AlertResolved {
+		return "[ModBridge] RESOLVED: " + ev.Title
+	}
+	return fmt.Sprintf("[ModBridge] %s: %s", strings.ToUpper(ev.Severity), ev.Title)
+}
+
+// mailBody returns the text of an event's mail.
+func mailBody(ev Event) string {
+	var b strings.Builder
+	b.WriteString(ev.Message)
+	b.WriteString("\n\n")
+	b.WriteString("Event:    " + ev.Kind + "\n")
+	if ev.ProxyID != "" {
+		b.WriteString("Proxy:    " + ev.ProxyID + "\n")
+	}
+	if ev.Subject != "" {
+		b.WriteString("Subject:  " + ev.Subject + "\n")
+	}
+	b.WriteString("Time:     " + ev.Time.Format(time.RFC1123) + "\n")
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package notify
+
+import (
+	"context"
+	"modbridge/pkg/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifierFiltersAndThrottles(t *testing.T) {
+	server, clientTLS := startTestSMTP(t, false)
+	n := New(Config{SMTP: server.config(SecurityStartTLS, clientTLS), OnError: true, Throttle: time.Hour}, logger.NewNullLogger(100))
+	n.Start()
+	defer n.Stop()
+
+	now := time.Now()
+	down := Event{Kind: ProxyDown, Severity: SeverityError, ProxyID: "p1", Subject: "10.0.0.5:502", Title: "Target down", Message: "no answer", Time: now}
+
+	// Warnings are off.
+	n.Notify(Event{Kind: AuthFailures, Severity: SeverityWarning, Subject: "192.0.2.7", Title: "Failed logins", Time: now})
+	n.Notify(down)
+	subject, body := parseMail(t, server.next(t).Data)
+	if subject != "[ModBridge] ERROR: Target down" || !strings.Contains(body, "Proxy:    p1") {
+		t.Errorf("mail = %q / %q", subject, body)
+	}
+
+	// The same event again within the throttle time is not mailed; a
+	// recovery is, and so is the same event about another target.
+	n.Notify(down)
+	n.Notify(Event{Kind: ProxyUp, Severity: SeverityInfo, ProxyID: "p1", Subject: "10.0.0.5:502", Title: "Target up", Time: now})
+	if subject, _ := parseMail(t, server.next(t).Data); !strings.HasSuffix(subject, "Target up") {
+		t.Errorf("second mail = %q, want the recovery", subject)
+	}
+
+	// Once the throttle time has passed, the next mail counts what was held
+	// back.
+	later := down
+	later.Time = now.Add(2 * time.Hour)
+	n.Notify(later)
+	if _, body := parseMail(t, server.next(t).Data); !strings.Contains(body, "1 more like this") {
+		t.Errorf("body = %q, want the suppressed count", body)
+	}
+
+	// The server has the mail a moment before the notifier counts it.
+	deadline := time.Now().Add(time.Second)
+	for n.Stats().Sent < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := n.Stats(); stats.Sent != 3 || stats.Throttled != 1 || stats.Failed != 0 {
+		t.Errorf("Stats() = %+v, want 3 sent and 1 throttled", stats)
+	}
+}
+
+func TestNotifierSendTest(t *testing.T) {
+	server, clientTLS := startTestSMTP(t, false)
+	n := New(Config{SMTP: server.config(SecurityStartTLS, clientTLS)}, logger.NewNullLogger(100))
+
+	if err := n.SendTest(context.Background()); err != nil {
+		t.Fatalf("SendTest() error = %v", err)
+	}
+	if subject, _ := parseMail(t, server.next(t).Data); subject != "[ModBridge] Test mail" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	server.listener.Close()
+	if err := n.SendTest(context.Background()); err == nil {
+		t.Error("SendTest() succeeded without a server")
+	}
+	if stats := n.Stats(); stats.Sent != 1 || stats.Failed != 1 || stats.LastError == "" {
+		t.Errorf("Stats() = %+v, want 1 sent and 1 failed", stats)
+	}
+}
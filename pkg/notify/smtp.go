@@ -0,0 +1,153 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connection security of an SMTP server.
+const (
+	SecurityStartTLS = "starttls" // Plain connection upgraded with STARTTLS, usually port 587
+	SecurityTLS      = "tls"      // TLS from the first byte, usually port 465
+	SecurityNone     = "none"     // No encryption; only for a relay on the same host or network
+)
+
+// sendTimeout bounds one delivery, from connecting to the server's answer
+// to the mail.
+const sendTimeout = 30 * time.Second
+
+// SMTPConfig says how mail leaves ModBridge.
+type SMTPConfig struct {
+	Host      string
+	Port      int
+	Security  string // starttls (default), tls or none
+	Username  string // Empty = no authentication
+	Password  string
+	From      string
+	To        []string
+	TLSConfig *tls.Config // Replaces the default of checking the server against the system roots
+}
+
+// SendMail delivers one plain-text mail to every recipient.
+func SendMail(ctx context.Context, cfg SMTPConfig, subject, body string) error {
+	if len(cfg.To) == 0 {
+		return errors.New("no recipients")
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	tlsCfg := cfg.TLSConfig
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	tlsCfg = tlsCfg.Clone()
+	if tlsCfg.ServerName == "" {
+		tlsCfg.ServerName = cfg.Host
+	}
+	if cfg.Security == SecurityTLS {
+		conn = tls.Client(conn, tlsCfg)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		return fmt.Errorf("SMTP greeting failed: %w", err)
+	}
+	defer c.Close()
+	if cfg.Security == "" || cfg.Security == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send the password over a connection without
+		// TLS, unless the server is on this host.
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("sender refused: %w", err)
+	}
+	for _, to := range cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s refused: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA refused: %w", err)
+	}
+	if _, err := w.Write(composeMail(cfg.From, cfg.To, subject, body, time.Now())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail refused: %w", err)
+	}
+	return c.Quit()
+}
+
+// composeMail builds the message: UTF-8 text, quoted-printable so that it
+// passes servers without 8BITMIME.
+func composeMail(from string, to []string, subject, body string, date time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	_ = qp.Close()
+	return buf.Bytes()
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "modbridge"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mtls "modbridge/pkg/tls"
+)
+
+// testMail is a mail the stand-in server accepted.
+type testMail struct {
+	From string
+	To   []string
+	Auth string // username:password of AUTH PLAIN, if any
+	TLS  bool   // Whether the mail came over TLS
+	Data string
+}
+
+// testSMTP is an SMTP server in the test process, just enough of one for
+// net/smtp: EHLO, STARTTLS, AUTH PLAIN, MAIL, RCPT, DATA, QUIT.
+type testSMTP struct {
+	listener net.Listener
+	tls      *tls.Config
+	implicit bool // TLS from the first byte
+	mails    chan testMail
+	password string // AUTH PLAIN is refused with any other
+}
+
+// startTestSMTP starts a stand-in server on localhost with a certificate
+// for it, and returns the server and a client TLS config that trusts it.
+func startTestSMTP(t *testing.T, implicit bool) (*testSMTP, *tls.Config) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := mtls.SaveCertificates(dir, "localhost", time.Hour); err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatalf("failed to load certificate: %v", err)
+	}
+	caPEM, _ := os.ReadFile(filepath.Join(dir, "server.crt"))
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &testSMTP{
+		listener: listener,
+		tls:      &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		implicit: implicit,
+		mails:    make(chan testMail, 10),
+		password: "secret",
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s, &tls.Config{RootCAs: roots, ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+}
+
+// config returns a configuration that sends to the stand-in.
+func (s *testSMTP) config(security string, clientTLS *tls.Config) SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return SMTPConfig{
+		Host:      "127.0.0.1",
+		Port:      addr.Port,
+		Security:  security,
+		From:      "modbridge@example.com",
+		To:        []string{"ops@example.com"},
+		TLSConfig: clientTLS,
+	}
+}
+
+func (s *testSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	secure := false
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+		secure = true
+	}
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			_, _ = io.WriteString(conn, l+"\r\n")
+		}
+	}
+
+	var m testMail
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			if secure {
+				reply("250-localhost", "250 AUTH PLAIN")
+			} else {
+				reply("250-localhost", "250-STARTTLS", "250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			fields := strings.Fields(line)
+			raw, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			parts := strings.Split(string(raw), "\x00")
+			if len(parts) != 3 || parts[2] != s.password {
+				reply("535 authentication failed")
+				continue
+			}
+			m.Auth = parts[1] + ":" + parts[2]
+			reply("235 ok")
+		case "MAIL":
+			m.From = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			m.To = append(m.To, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			m.Data, m.TLS = data.String(), secure
+			s.mails <- m
+			m = testMail{}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// next waits for the next mail the stand-in accepts.
+func (s *testSMTP) next(t *testing.T) testMail {
+	t.Helper()
+	select {
+	case m := <-s.mails:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail arrived")
+		return testMail{}
+	}
+}
+
+// parseMail returns the decoded subject and body of a mail.
+func parseMail(t *testing.T, data string) (subject, body string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unreadable mail: %v", err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("unreadable subject: %v", err)
+	}
+	raw, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("unreadable body: %v", err)
+	}
+	// The DATA writer of net/smtp ends the text with a line break.
+	return subject, strings.TrimSuffix(string(raw), "\r\n")
+}
+
+func TestSendMail(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		implicit bool
+		security string
+	}{
+		{"starttls", false, SecurityStartTLS},
+		{"implicit tls", true, SecurityTLS},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, clientTLS := startTestSMTP(t, tt.implicit)
+			cfg := server.config(tt.security, clientTLS)
+			cfg.Username, cfg.Password = "modbridge", "secret"
+
+			if err := SendMail(context.Background(), cfg, "Zähler überlastet", "Line one\nLine two"); err != nil {
+				t.Fatalf("SendMail() error = %v", err)
+			}
+			m := server.next(t)
+			if !m.TLS || m.Auth != "modbridge:secret" {
+				t.Errorf("TLS = %v, auth = %q, want TLS and the credentials", m.TLS, m.Auth)
+			}
+			if m.From != cfg.From || len(m.To) != 1 || m.To[0] != "ops@example.com" {
+				t.Errorf("envelope = %s -> %v", m.From, m.To)
+			}
+			subject, body := parseMail(t, m.Data)
+			if subject != "Zähler überlastet" || body != "Line one\r\nLine two" {
+				t.Errorf("mail = %q / %q", subject, body)
+			}
+		})
+	}
+}
+
+func TestSendMailErrors(t *testing.T) {
+	server, clientTLS := startTestSMTP(t, false)
+
+	cfg := server.config(SecurityStartTLS, clientTLS)
+	cfg.Username, cfg.Password = "modbridge", "wrong"
+	if err := SendMail(context.Background(), cfg, "s", "b"); err == nil || !strings.Contains(err.Error(), "authentication") {
+		t.Errorf("SendMail() with a wrong password error = %v", err)
+	}
+
+	// The server's certificate is not trusted without the test CA.
+	cfg = server.config(SecurityStartTLS, nil)
+	if err := SendMail(context.Background(), cfg, "s", "b"); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("SendMail() with an untrusted certificate error = %v", err)
+	}
+
+	// A relay that needs neither TLS nor a login.
+	cfg = server.config(SecurityNone, nil)
+	if err := SendMail(context.Background(), cfg, "s", "b"); err != nil {
+		t.Errorf("SendMail() without TLS error = %v", err)
+	} else if m := server.next(t); m.TLS {
+		t.Error("mail came over TLS, want plain")
+	}
+}
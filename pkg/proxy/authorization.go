@@ -0,0 +1,84 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"fmt"
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/rbac"
+)
+
+// CertAuthorization decides what a client on a Modbus/TCP Security listener
+// may do by its certificate instead of its address. The first grant that
+// matches the certificate applies; a client no grant matches, or one without
+// a certificate, is refused every request. It works next to the access
+// policy: a request has to pass both.
+type CertAuthorization struct {
+	Grants []CertGrant
+}
+
+// CertGrant gives the holders of some certificates a role and, optionally,
+// further limits.
+type CertGrant struct {
+	Subject  string    // Subject DN or common name of the certificate (empty = any)
+	CertRole string    // Modbus role the certificate carries (empty = any)
+	Role     rbac.Role // Reads need modbus:read, everything else modbus:write
+	Units    []uint8   // Unit IDs the certificate may address (empty = all)
+	AccessRule
+}
+
+// noGrant stands for a client that no grant matches.
+var noGrant = &CertGrant{}
+
+// matches reports whether a grant applies to a client's certificate.
+func (g *CertGrant) matches(s *clientSession) bool {
+	if s.certSubject == "" {
+		return false
+	}
+	if g.Subject != "" && g.Subject != s.certSubject && g.Subject != s.certName {
+		return false
+	}
+	return g.CertRole == "" || g.CertRole == s.certRole
+}
+
+// grantFor returns the grant of a client's certificate: nil when the proxy
+// does not authorize by certificate, noGrant when nothing matches.
+func (ca *CertAuthorization) grantFor(s *clientSession) *CertGrant {
+	if ca == nil {
+		return nil
+	}
+	for i := range ca.Grants {
+		if ca.Grants[i].matches(s) {
+			return &ca.Grants[i]
+		}
+	}
+	return noGrant
+}
+
+// check decides a client request like AccessRule.check does.
+func (g *CertGrant) check(reqFrame []byte) (uint8, string) {
+	if g == nil {
+		return 0, ""
+	}
+	if g == noGrant {
+		return modbus.ExceptionIllegalFunction, "no authorization for this client certificate"
+	}
+	unitID, fc, ok := modbus.FrameUnitAndFunction(reqFrame)
+	if !ok {
+		return 0, ""
+	}
+	perm := rbac.PermModbusWrite
+	if readsOnly(reqFrame, fc) {
+		perm = rbac.PermModbusRead
+	}
+	if !rbac.HasPermission(g.Role, perm) {
+		return modbus.ExceptionIllegalFunction, fmt.Sprintf("function 0x%02X refused: role %s lacks %s", fc, g.Role, perm)
+	}
+	if !containsByte(g.Units, unitID) {
+		return modbus.ExceptionIllegalDataAddress, fmt.Sprintf("unit %d not allowed for this certificate", unitID)
+	}
+	return g.AccessRule.check(reqFrame)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/rbac"
+	mtls "modbridge/pkg/tls"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCertAuthorization verifies that the role in a client certificate
+// decides what the client may do: a viewer reads but cannot write, only
+// reaches its own unit, and every refusal is reported with the certificate.
+func TestCertAuthorization(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	var mu sync.Mutex
+	var denials []Denial
+	serverCert, serverKey, clientCA, clientCert, clientKey := testPKI(t, "viewer")
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.ListenTLS = &mtls.ModbusServerConfig{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: clientCA}
+		p.Authorization = &CertAuthorization{Grants: []CertGrant{
+			{CertRole: "operator", Role: rbac.RoleTechniker},
+			{CertRole: "viewer", Role: rbac.RoleBenutzer, Units: []uint8{1}},
+		}}
+		p.OnDenied = func(d Denial) {
+			mu.Lock()
+			denials = append(denials, d)
+			mu.Unlock()
+		}
+	})
+	defer p.Stop()
+
+	conn := dialSecure(t, p.ListenAddr, serverCert, clientCert, clientKey)
+	defer conn.Close()
+
+	if resp := exchange(t, conn, modbus.CreateReadRequest(1, 1, 3, 0, 2)); modbus.IsExceptionResponse(resp) {
+		t.Errorf("read by a viewer answered % X", resp)
+	}
+	write := []byte{0, 2, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 0, 10, 0, 42}
+	if resp := exchange(t, conn, write); len(resp) != 9 || resp[8] != modbus.ExceptionIllegalFunction {
+		t.Errorf("write by a viewer answered % X, want exception 0x01", resp)
+	}
+	if resp := exchange(t, conn, modbus.CreateReadRequest(3, 2, 3, 0, 2)); len(resp) != 9 || resp[8] != modbus.ExceptionIllegalDataAddress {
+		t.Errorf("read of another unit answered % X, want exception 0x02", resp)
+	}
+
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(denials) != 2 || !strings.Contains(denials[0].Certificate, "CN=scada") || !strings.Contains(denials[0].Reason, "modbus:write") {
+		t.Errorf("reported denials = %+v, want two with the certificate, the first for modbus:write", denials)
+	}
+}
+
+// TestCertAuthorizationRefusesUnknownCertificates verifies that a client
+// whose certificate no grant matches is refused every request.
+func TestCertAuthorizationRefusesUnknownCertificates(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	serverCert, serverKey, clientCA, clientCert, clientKey := testPKI(t, "guest")
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.ListenTLS = &mtls.ModbusServerConfig{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: clientCA}
+		p.Authorization = &CertAuthorization{Grants: []CertGrant{
+			{Subject: "plc-gateway", Role: rbac.RoleAdmin},
+			{CertRole: "operator", Role: rbac.RoleTechniker},
+		}}
+	})
+	defer p.Stop()
+
+	conn := dialSecure(t, p.ListenAddr, serverCert, clientCert, clientKey)
+	defer conn.Close()
+	if resp := exchange(t, conn, modbus.CreateReadRequest(1, 1, 3, 0, 2)); len(resp) != 9 || resp[8] != modbus.ExceptionIllegalFunction {
+		t.Errorf("read with an unknown certificate answered % X, want exception 0x01", resp)
+	}
+	if got := atomic.LoadInt64(&reads); got != 0 {
+		t.Errorf("target saw %d reads, want 0", got)
+	}
+	if got := p.Stats.Denied.Load(); got != 1 {
+		t.Errorf("Denied = %d, want 1", got)
+	}
+}
+
+// TestCertAuthorizationRangesRefuseFunctionsWithoutAddress verifies that a
+// grant limited to some addresses keeps a writer from reaching the device
+// with a function the ranges cannot check, such as Write File Record.
+func TestCertAuthorizationRangesRefuseFunctionsWithoutAddress(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	serverCert, serverKey, clientCA, clientCert, clientKey := testPKI(t, "operator")
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.ListenTLS = &mtls.ModbusServerConfig{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: clientCA}
+		p.Authorization = &CertAuthorization{Grants: []CertGrant{
+			{CertRole: "operator", Role: rbac.RoleTechniker, AccessRule: AccessRule{Ranges: []AccessRange{{Start: 0, Count: 100}}}},
+		}}
+	})
+	defer p.Stop()
+
+	conn := dialSecure(t, p.ListenAddr, serverCert, clientCert, clientKey)
+	defer conn.Close()
+
+	write := []byte{0, 1, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 0, 10, 0, 42}
+	if resp := exchange(t, conn, write); modbus.IsExceptionResponse(resp) {
+		t.Errorf("write inside the range answered % X", resp)
+	}
+	writeFileRecord := []byte{0, 2, 0, 0, 0, 12, 1, 0x15, 9, 6, 0, 1, 0, 0, 0, 1, 0, 42}
+	if resp := exchange(t, conn, writeFileRecord); len(resp) != 9 || resp[8] != modbus.ExceptionIllegalFunction {
+		t.Errorf("write file record answered % X, want exception 0x01", resp)
+	}
+	if got := p.Stats.Denied.Load(); got != 1 {
+		t.Errorf("Denied = %d, want 1", got)
+	}
+}
@@ -118,6 +118,92 @@ func TestCacheServesRepeatedReads(t *testing.T) {
 	}
 }
 
+// TestReadRegistersSharesCache verifies that a read made by the proxy itself
+// is answered from the entry a client's identical read left in the cache, and
+// is rewritten like a client's.
+func TestReadRegistersSharesCache(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.CacheEnabled = true
+		p.CacheTTL = 10 * time.Second
+		p.Rewrite = &RewriteRules{Units: map[uint8]uint8{1: 7}}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(modbus.CreateReadRequest(1, 1, 3, 100, 2)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	if _, err := modbus.ReadFrame(conn); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	regs, err := p.ReadRegisters(1, 3, 100, 2)
+	if err != nil {
+		t.Fatalf("ReadRegisters() error = %v", err)
+	}
+	// The target fills every byte with the unit ID it was asked for.
+	if len(regs) != 2 || regs[0] != 0x0707 || regs[1] != 0x0707 {
+		t.Errorf("ReadRegisters() = %04X, want [0707 0707]", regs)
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1 (the second should come from the cache)", got)
+	}
+
+	if _, err := p.ReadRegisters(1, 6, 100, 1); err == nil {
+		t.Error("ReadRegisters() accepted a write function")
+	}
+	conn.Close()
+	p.Stop()
+	if _, err := p.ReadRegisters(1, 3, 100, 2); err == nil {
+		t.Error("ReadRegisters() read from a stopped proxy")
+	}
+}
+
+// TestWriteRegistersClearsCache verifies that a write of the proxy's own goes
+// to the target and drops the cached reads of its unit, as a client's would.
+func TestWriteRegistersClearsCache(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.CacheEnabled = true
+		p.CacheTTL = 10 * time.Second
+	})
+	defer p.Stop()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.ReadRegisters(1, 3, 100, 2); err != nil {
+			t.Fatalf("ReadRegisters() error = %v", err)
+		}
+	}
+	for _, values := range [][]uint16{{500}, {1, 2}} {
+		if err := p.WriteRegisters(1, 100, values); err != nil {
+			t.Fatalf("WriteRegisters(%v) error = %v", values, err)
+		}
+	}
+	if _, err := p.ReadRegisters(1, 3, 100, 2); err != nil {
+		t.Fatalf("ReadRegisters() error = %v", err)
+	}
+	if got := atomic.LoadInt64(&reads); got != 2 {
+		t.Errorf("target saw %d reads, want 2 (the write must clear the cache)", got)
+	}
+	if err := p.WriteRegisters(1, 100, nil); err == nil {
+		t.Error("WriteRegisters() accepted no values")
+	}
+}
+
 // TestCacheExpiresAfterTTL verifies that a stale entry is not served.
 func TestCacheExpiresAfterTTL(t *testing.T) {
 	var reads int64
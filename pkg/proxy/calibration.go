@@ -248,6 +248,24 @@ func (p *ProxyInstance) Calibrate(ctx context.Context, cfg CalibrationConfig) (*
 	if p.Stats.GetStatus() != "Running" {
 		return nil, refuse("proxyNotRunning", "proxy is not running", nil)
 	}
+	// The probes speak Modbus TCP to a socket. A serial line has no socket to
+	// probe, and nothing to find: its pace follows from the baud rate.
+	if p.Protocol == ProtocolSerial {
+		return nil, refuse("serialNotMeasured",
+			"a serial line is not measured: its pace follows from the baud rate and the frame gap is fixed", nil)
+	}
+	// Several targets are several devices to measure, each with its own
+	// pace; the measurement speaks to one socket.
+	if len(p.Endpoints) > 0 {
+		return nil, refuse("targetsNotMeasured",
+			"this proxy spreads its requests over several targets; measure each of them as a proxy of its own", nil)
+	}
+	// A gateway without a target of its own has nothing to measure; its routes
+	// each lead somewhere else.
+	if !p.hasOwnTarget() {
+		return nil, refuse("routesNotMeasured",
+			"this proxy only forwards over its unit-ID routes and has no target of its own to measure", nil)
+	}
 	probe := cfg.Probe
 	if !probe.Valid() {
 		observed, ok := p.LastObservedRead()
@@ -641,8 +659,7 @@ func (p *ProxyInstance) measureConnections(ctx context.Context, probe ProbeSpec,
 // dialTarget opens a probe connection, honouring the configured connect delay
 // for devices that ignore requests arriving right after the handshake.
 func (p *ProxyInstance) dialTarget(ctx context.Context) (net.Conn, error) {
-	dialer := net.Dialer{Timeout: p.ConnectionTimeout}
-	conn, err := dialer.DialContext(ctx, "tcp", p.TargetAddr)
+	conn, err := p.dialTargetConn(ctx, net.Dialer{Timeout: p.ConnectionTimeout})
 	if err != nil {
 		return nil, err
 	}
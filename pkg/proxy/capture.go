@@ -0,0 +1,42 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/capture"
+	"net"
+	"time"
+)
+
+// SetCapture records the frames of this proxy, and of the proxies behind its
+// routes and endpoints, into s. Without a capture the hooks cost one atomic load per
+// frame. A capture that has ended records nothing, so it need not be taken
+// away again.
+func (p *ProxyInstance) SetCapture(s *capture.Session) {
+	p.capture.Store(s)
+	for _, r := range p.Routes {
+		r.target.SetCapture(s)
+	}
+	for _, ep := range p.Endpoints {
+		ep.target.SetCapture(s)
+	}
+}
+
+// captureTarget records one exchange with the target: the request as it went
+// out and the response, or why none came.
+func (p *ProxyInstance) captureTarget(conn net.Conn, rtu bool, req []byte, sent time.Time, resp []byte, err error) {
+	c := p.capture.Load()
+	if c == nil {
+		return
+	}
+	local, remote := conn.LocalAddr().String(), conn.RemoteAddr().String()
+	c.Record(capture.Frame{Time: sent, Side: capture.SideTarget, RTU: rtu, Src: local, Dst: remote, Data: req})
+	f := capture.Frame{Time: time.Now(), Side: capture.SideTarget, Response: true, RTU: rtu, Src: remote, Dst: local, Data: resp, Latency: time.Since(sent)}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	c.Record(f)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"bytes"
+	"modbridge/pkg/capture"
+	"modbridge/pkg/modbus"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestCaptureRecordsBothSides verifies that a capture holds the client's
+// request and response and, between them, the proxy's own exchange with the
+// target.
+func TestCaptureRecordsBothSides(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	captures := capture.NewManager(t.TempDir(), 0)
+	session, err := captures.Start("tx-test", capture.Options{Format: capture.FormatJSONL}, nil)
+	if err != nil {
+		t.Fatalf("failed to start capture: %v", err)
+	}
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) { p.SetCapture(session) })
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	req := modbus.CreateReadRequest(7, 3, 3, 100, 2)
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	conn.Close()
+	captures.Stop("tx-test")
+
+	path, _, err := captures.Path(session.ID())
+	if err != nil {
+		t.Fatalf("capture not found: %v", err)
+	}
+	frames, err := capture.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("capture holds %d frames, want 4: %+v", len(frames), frames)
+	}
+
+	want := []struct {
+		side     capture.Side
+		response bool
+	}{
+		{capture.SideClient, false},
+		{capture.SideTarget, false},
+		{capture.SideTarget, true},
+		{capture.SideClient, true},
+	}
+	for i, f := range frames {
+		if f.Side != want[i].side || f.Response != want[i].response {
+			t.Errorf("frame %d is %s response=%v, want %s response=%v", i, f.Side, f.Response, want[i].side, want[i].response)
+		}
+	}
+	if !bytes.Equal(frames[0].Data, req) || !bytes.Equal(frames[3].Data, resp) {
+		t.Error("client frames differ from what the client sent and got")
+	}
+	if frames[0].Dst != p.ListenAddr || frames[1].Dst != target.Addr().String() {
+		t.Errorf("frames addressed %s and %s, want the proxy and the target", frames[0].Dst, frames[1].Dst)
+	}
+	if frames[2].Latency <= 0 || frames[3].Latency < frames[2].Latency {
+		t.Errorf("latencies target %v, client %v; want the client's to include the target's", frames[2].Latency, frames[3].Latency)
+	}
+}
@@ -34,7 +34,8 @@ type CircuitBreaker struct {
 	successThreshold int
 
 	openCount        int64
-	halfOpenInFlight bool // true while a single half-open probe is running
+	halfOpenInFlight bool   // true while a single half-open probe is running
+	onOpen           func() // Called when a closed circuit opens (nil = none)
 
 	totalRequests    int64
 	totalFailures    int64
@@ -167,6 +168,45 @@ func (cb *CircuitBreaker) RecordFailure() {
 	}
 }
 
+// Ready reports whether AllowRequest would let a request through now, without
+// counting one or claiming the half-open probe. An open circuit whose timeout
+// has run out is ready: the next request is its probe.
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	switch cb.state {
+	case StateOpen:
+		return time.Since(cb.lastStateChange) > cb.adaptiveOpenTimeout()
+	case StateHalfOpen:
+		return !cb.halfOpenInFlight
+	}
+	return true
+}
+
+// Trip opens the circuit now, whatever its state, because something outside
+// the request path — a failed health probe — knows the target is down. An
+// open circuit stays as it is, so its timeout is not pushed back again and
+// again; a request let through half-open will find the target down too.
+func (cb *CircuitBreaker) Trip() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != StateOpen {
+		cb.halfOpenInFlight = false
+		cb.transitionToOpen()
+	}
+}
+
+// SetOnOpen sets a function called, on a goroutine of its own, whenever a
+// closed circuit opens. Reopening after a failed half-open probe does not
+// count: the target never came back in between.
+func (cb *CircuitBreaker) SetOnOpen(fn func()) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.onOpen = fn
+}
+
 // GetState returns the current state
 func (cb *CircuitBreaker) GetState() CircuitBreakerState {
 	cb.mu.RLock()
@@ -228,6 +268,9 @@ func safeFailureRate(failures, total int64) float64 {
 // Private transition methods
 
 func (cb *CircuitBreaker) transitionToOpen() {
+	if cb.state == StateClosed && cb.onOpen != nil {
+		go cb.onOpen()
+	}
 	cb.state = StateOpen
 	cb.openCount++
 	cb.lastStateChange = time.Now()
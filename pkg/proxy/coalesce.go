@@ -0,0 +1,126 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"bytes"
+	"modbridge/pkg/modbus"
+	"sync"
+)
+
+// flight is a read on its way to the target. Requests for the same registers
+// that arrive meanwhile wait for it instead of asking the device again.
+type flight struct {
+	pdu  []byte        // Unit ID onwards; the key is a hash and may collide
+	done chan struct{} // Closed once resp is set
+	resp []byte        // nil when the leader has no answer to share
+}
+
+// flightGroup holds the reads in flight, by modbus.RequestCacheKey. The zero
+// value is ready to use.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[uint64]*flight
+}
+
+// join returns the flight for a read and whether the caller leads it. The
+// leader must call land with the response. ok is false when the read cannot
+// share a flight: it is no pure read, or another read with the same key but
+// different registers is in flight.
+func (g *flightGroup) join(key uint64, pdu []byte) (f *flight, leader, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f := g.flights[key]; f != nil {
+		return f, false, bytes.Equal(f.pdu, pdu)
+	}
+	if g.flights == nil {
+		g.flights = make(map[uint64]*flight)
+	}
+	f = &flight{pdu: pdu, done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true, true
+}
+
+// land hands the leader's response to the waiters and closes the flight, so
+// the next read of these registers asks the device anew.
+func (g *flightGroup) land(key uint64, f *flight, resp []byte) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	f.resp = resp
+	close(f.done)
+}
+
+// forwardFunc sends a request on and returns the response. local is true
+// when the proxy made the answer up itself for this caller, such as a busy
+// answer for a request that had no turn at the target.
+type forwardFunc func() (resp []byte, local bool)
+
+// forwardWith wraps a dispatch whose answers are all fit to share.
+func forwardWith(dispatch func() []byte) forwardFunc {
+	return func() ([]byte, bool) { return dispatch(), false }
+}
+
+// coalesce answers a request with forward, unless CoalesceReads is set and
+// the same read is already on its way to the target; then it waits for that
+// one and answers with a copy of its response under the request's own
+// transaction ID. This runs whether or not the cache is on: the cache helps
+// the next poll, coalescing the polls of several clients that arrive
+// together. An answer the proxy made up for the leader is not shared;
+// neither is none at all, if the leader panicked. The waiters then forward
+// their requests themselves.
+func (p *ProxyInstance) coalesce(reqFrame []byte, forward forwardFunc) []byte {
+	key, _, cacheable := modbus.RequestCacheKey(reqFrame)
+	if !p.CoalesceReads || !cacheable {
+		resp, _ := forward()
+		return resp
+	}
+	f, leader, ok := p.inflight.join(key, append([]byte(nil), reqFrame[modbus.MBAPHeaderLength:]...))
+	if !ok {
+		resp, _ := forward()
+		return resp
+	}
+	if leader {
+		var shared []byte
+		defer func() { p.inflight.land(key, f, shared) }()
+		resp, local := forward()
+		if !local {
+			// The response is copied before the caller restores its own
+			// numbering in it.
+			shared = append([]byte(nil), resp...)
+		}
+		return resp
+	}
+
+	<-f.done
+	if f.resp == nil {
+		resp, _ := forward()
+		return resp
+	}
+	resp := append([]byte(nil), f.resp...)
+	txID, _ := modbus.FrameTxID(reqFrame)
+	modbus.SetFrameTxID(resp, txID)
+	p.Stats.Coalesced.Add(1)
+	p.countShared(resp)
+	return resp
+}
+
+// countShared counts a shared response like the leader's own: a gateway
+// exception is the proxy failing to get an answer, and anything else, device
+// exceptions included, an answer. Busy answers of the proxy's own are never
+// shared.
+func (p *ProxyInstance) countShared(resp []byte) {
+	if !modbus.IsExceptionResponse(resp) || len(resp) <= 8 {
+		p.Stats.Requests.Add(1)
+		return
+	}
+	switch resp[8] {
+	case 0x0A, 0x0B:
+		p.Stats.Errors.Add(1)
+	default:
+		p.Stats.Requests.Add(1)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/modbus"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCoalesceSharesConcurrentReads verifies that identical reads from
+// several clients at once reach the device once, with the cache off, and that
+// every client gets the answer under its own transaction ID.
+func TestCoalesceSharesConcurrentReads(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 300*time.Millisecond)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) { p.CoalesceReads = true })
+	defer p.Stop()
+
+	const clients = 3
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		conn, err := net.Dial("tcp", p.ListenAddr)
+		if err != nil {
+			t.Fatalf("failed to connect to proxy: %v", err)
+		}
+		defer conn.Close()
+
+		txID := uint16(100 + i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := exchange(t, conn, modbus.CreateReadRequest(txID, 1, 3, 40, 4))
+			if modbus.IsExceptionResponse(resp) {
+				t.Errorf("client %d got exception % X", txID, resp)
+				return
+			}
+			if got, _ := modbus.FrameTxID(resp); got != txID {
+				t.Errorf("client %d got transaction ID %d", txID, got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1", got)
+	}
+	if got := p.Stats.Coalesced.Load(); got != clients-1 {
+		t.Errorf("Coalesced = %d, want %d", got, clients-1)
+	}
+	if got := p.Stats.Requests.Load(); got != clients {
+		t.Errorf("Requests = %d, want %d", got, clients)
+	}
+}
+
+// TestCoalesceKeepsDifferentReadsApart verifies that reads of different
+// registers and reads after the first has landed each reach the device.
+func TestCoalesceKeepsDifferentReadsApart(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 100*time.Millisecond)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) { p.CoalesceReads = true })
+	defer p.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", p.ListenAddr)
+		if err != nil {
+			t.Fatalf("failed to connect to proxy: %v", err)
+		}
+		defer conn.Close()
+
+		addr := uint16(40 + 10*i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exchange(t, conn, modbus.CreateReadRequest(1, 1, 3, addr, 4))
+		}()
+	}
+	wg.Wait()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	exchange(t, conn, modbus.CreateReadRequest(2, 1, 3, 40, 4))
+
+	if got := atomic.LoadInt64(&reads); got != 3 {
+		t.Errorf("target saw %d reads, want 3", got)
+	}
+	if got := p.Stats.Coalesced.Load(); got != 0 {
+		t.Errorf("Coalesced = %d, want 0", got)
+	}
+}
+
+// TestCoalesceDoesNotShareLocalAnswers verifies that a waiter forwards its
+// read itself when the leader has nothing to share: a busy answer the proxy
+// made up for the leader, or no answer because the leader panicked.
+func TestCoalesceDoesNotShareLocalAnswers(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) { p.CoalesceReads = true })
+	defer p.Stop()
+
+	req := modbus.CreateReadRequest(1, 1, 3, 40, 4)
+	busy := modbus.CreateExceptionResponse(req, modbus.ExceptionServerDeviceBusy)
+	answer, _ := modbus.CreateReadResponse(2, 1, 3, []byte{0, 1, 0, 2, 0, 3, 0, 4})
+
+	for name, lead := range map[string]forwardFunc{
+		"busy answer": func() ([]byte, bool) { return busy, true },
+		"panic":       func() ([]byte, bool) { panic("leader failed") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			started, release := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer func() { _ = recover() }()
+				p.coalesce(req, func() ([]byte, bool) {
+					close(started)
+					<-release
+					return lead()
+				})
+			}()
+			<-started
+
+			got := make(chan []byte, 1)
+			go func() {
+				got <- p.coalesce(modbus.CreateReadRequest(2, 1, 3, 40, 4), func() ([]byte, bool) { return answer, false })
+			}()
+			time.Sleep(50 * time.Millisecond) // The second read waits for the first
+			close(release)
+
+			select {
+			case resp := <-got:
+				if modbus.IsExceptionResponse(resp) {
+					t.Errorf("the waiter was answered % X, want its own answer", resp)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the waiter was never answered")
+			}
+		})
+	}
+}
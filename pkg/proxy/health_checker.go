@@ -23,6 +23,11 @@ type HealthChecker struct {
 	log         func(string, string)
 	onUnhealthy func()
 	onRecovery  func()
+	onFailure   func()                                      // Every failed check while unhealthy
+	probe       func(ctx context.Context) error             // Replaces the TCP dial when set
+	dial        func(ctx context.Context) (net.Conn, error) // Replaces the plain TCP dial, e.g. with a TLS handshake (nil = plain)
+	threshold   int                                         // Failed checks in a row before unhealthy
+	lastLatency time.Duration
 	running     bool
 	paused      atomic.Bool // suspends checks while something else needs the target
 	callbackMu  sync.Mutex  // prevents overlapping callbacks
@@ -34,6 +39,8 @@ type HealthStatus struct {
 	LastError        string    `json:"last_error"`
 	ConsecutiveFails int       `json:"consecutive_fails"`
 	Interval         string    `json:"interval"`
+	Probe            string    `json:"probe"`      // tcp (connect only) or modbus (a real read)
+	LatencyMs        float64   `json:"latency_ms"` // Of the last check
 }
 
 func NewHealthChecker(target string, interval, timeout time.Duration, logFn func(string, string)) *HealthChecker {
@@ -47,13 +54,14 @@ func NewHealthChecker(target string, interval, timeout time.Duration, logFn func
 	ctx, cancel := context.WithCancel(context.Background())
 
 	hc := &HealthChecker{
-		target:   target,
-		interval: interval,
-		timeout:  timeout,
-		healthy:  true,
-		log:      logFn,
-		ctx:      ctx,
-		cancel:   cancel,
+		target:    target,
+		interval:  interval,
+		timeout:   timeout,
+		healthy:   true,
+		threshold: 1,
+		log:       logFn,
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 
 	return hc
@@ -98,12 +106,27 @@ func (hc *HealthChecker) Stop() {
 	hc.wg.Wait()
 }
 
+// SetProbe replaces the TCP connect with probe: a check passes when probe
+// returns nil, and a target counts as unhealthy only after threshold checks
+// in a row have failed. A probe runs once right away, so the verdict does not
+// wait an interval. Call it before Start.
+func (hc *HealthChecker) SetProbe(probe func(ctx context.Context) error, threshold int) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.probe = probe
+	hc.threshold = max(threshold, 1)
+}
+
 func (hc *HealthChecker) loop() {
 	defer hc.wg.Done()
 
 	ticker := time.NewTicker(hc.interval)
 	defer ticker.Stop()
 
+	if hc.probe != nil && !hc.paused.Load() {
+		hc.check()
+	}
+
 	for {
 		select {
 		case <-hc.ctx.Done():
@@ -121,31 +144,37 @@ func (hc *HealthChecker) check() {
 	ctx, cancel := context.WithTimeout(hc.ctx, hc.timeout)
 	defer cancel()
 
-	dialer := net.Dialer{Timeout: hc.timeout}
-	conn, err := dialer.DialContext(ctx, "tcp", hc.target)
+	start := time.Now()
+	err := hc.run(ctx)
+	latency := time.Since(start)
 
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
 
 	hc.lastCheck = time.Now()
+	hc.lastLatency = latency
 
 	if err != nil {
-		wasHealthy := hc.healthy
 		hc.consecutive++
-		hc.healthy = false
 		hc.lastError = err.Error()
 		if hc.consecutive == 1 || hc.consecutive%5 == 0 {
 			if hc.log != nil {
-				hc.log("HC", "target unreachable: "+err.Error())
+				hc.log("HC", "target check failed: "+err.Error())
 			}
 		}
-		if wasHealthy && hc.onUnhealthy != nil {
-			hc.callbackMu.Lock()
-			fn := hc.onUnhealthy
-			hc.callbackMu.Unlock()
-			if fn != nil {
-				go fn()
-			}
+		if hc.consecutive < hc.threshold {
+			return
+		}
+		wasHealthy := hc.healthy
+		hc.healthy = false
+		hc.callbackMu.Lock()
+		onUnhealthy, onFailure := hc.onUnhealthy, hc.onFailure
+		hc.callbackMu.Unlock()
+		if wasHealthy && onUnhealthy != nil {
+			go onUnhealthy()
+		}
+		if onFailure != nil {
+			go onFailure()
 		}
 		return
 	}
@@ -154,11 +183,10 @@ func (hc *HealthChecker) check() {
 	hc.consecutive = 0
 	hc.healthy = true
 	hc.lastError = ""
-	conn.Close()
 
 	if wasUnhealthy {
 		if hc.log != nil {
-			hc.log("HC", "target is reachable again")
+			hc.log("HC", "target is healthy again")
 		}
 		hc.callbackMu.Lock()
 		fn := hc.onRecovery
@@ -169,6 +197,36 @@ func (hc *HealthChecker) check() {
 	}
 }
 
+// run performs one check: the probe when there is one, a TCP connect
+// otherwise.
+func (hc *HealthChecker) run(ctx context.Context) error {
+	if hc.probe != nil {
+		return hc.probe(ctx)
+	}
+	if hc.dial != nil {
+		ctx, cancel := context.WithTimeout(ctx, hc.timeout)
+		defer cancel()
+		conn, err := hc.dial(ctx)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	dialer := net.Dialer{Timeout: hc.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", hc.target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// SetDialer replaces the plain TCP connect of the check, for a target that
+// is only healthy once a TLS handshake succeeds. A probe still takes
+// precedence. Call it before Start.
+func (hc *HealthChecker) SetDialer(dial func(ctx context.Context) (net.Conn, error)) {
+	hc.dial = dial
+}
+
 func (hc *HealthChecker) IsHealthy() bool {
 	hc.mu.RLock()
 	defer hc.mu.RUnlock()
@@ -187,14 +245,28 @@ func (hc *HealthChecker) SetOnRecovery(fn func()) {
 	hc.onRecovery = fn
 }
 
+// SetOnFailure sets what runs after every failed check once the target counts
+// as unhealthy, not only the first.
+func (hc *HealthChecker) SetOnFailure(fn func()) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.onFailure = fn
+}
+
 func (hc *HealthChecker) GetStatus() HealthStatus {
 	hc.mu.RLock()
 	defer hc.mu.RUnlock()
+	probe := "tcp"
+	if hc.probe != nil {
+		probe = "modbus"
+	}
 	return HealthStatus{
 		Healthy:          hc.healthy,
 		LastCheck:        hc.lastCheck,
 		LastError:        hc.lastError,
 		ConsecutiveFails: hc.consecutive,
 		Interval:         hc.interval.String(),
+		Probe:            probe,
+		LatencyMs:        hc.lastLatency.Seconds() * 1000,
 	}
 }
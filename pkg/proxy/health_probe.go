@@ -0,0 +1,101 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"modbridge/pkg/modbus"
+	"time"
+)
+
+// HealthProbe is a read that tells whether the target really answers. A TCP
+// connect is not enough for devices such as the Huawei sDongle, which accept
+// the connection and then never reply.
+//
+// The probe goes the way of a forwarded request — pool, pacing, retries — but
+// around the cache, which would answer for a dead device, and around the
+// circuit breaker, which the probe itself opens and which would otherwise
+// keep it from ever seeing the device come back.
+type HealthProbe struct {
+	UnitID           uint8
+	Function         uint8  // FuncReadHoldingRegisters or FuncReadInputRegisters
+	Address          uint16 // As the device numbers it; the rewrite rules do not apply
+	Count            uint16
+	Expect           *uint16       // The first register must hold this value (nil = any)
+	Min, Max         *uint16       // ... and lie in this range (nil = open)
+	MaxLatency       time.Duration // A slower answer fails the probe (0 = any)
+	Interval         time.Duration // 0 = 30s
+	FailureThreshold int           // Failed probes in a row before unhealthy (0 = 1)
+}
+
+// String describes the read, for logs and status.
+func (hp *HealthProbe) String() string {
+	return fmt.Sprintf("unit %d, function 0x%02X, %d register(s) at %d", hp.UnitID, hp.Function, hp.Count, hp.Address)
+}
+
+// probeTarget performs the health probe once.
+func (p *ProxyInstance) probeTarget(ctx context.Context) error {
+	hp := p.HealthProbe
+	if p.calibrating.Load() {
+		// The measurement owns the device; the checker is paused for it,
+		// but a probe may already have been on its way.
+		return nil
+	}
+	req := modbus.CreateReadRequest(uint16(p.getNextRequestID()), hp.UnitID, hp.Function, hp.Address, max(hp.Count, 1))
+
+	start := time.Now()
+	resp, err := p.forwardClientRequest("", req)
+	latency := time.Since(start)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("no answer to the health probe: %w", err)
+	}
+	if modbus.IsExceptionResponse(resp) {
+		return fmt.Errorf("health probe answered with modbus exception 0x%02X", resp[8])
+	}
+	data, err := modbus.ParseReadResponse(resp)
+	if err != nil {
+		return fmt.Errorf("malformed answer to the health probe: %w", err)
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("health probe answered with %d bytes", len(data))
+	}
+	value := binary.BigEndian.Uint16(data)
+	switch {
+	case hp.Expect != nil && value != *hp.Expect:
+		return fmt.Errorf("health probe read %d, expected %d", value, *hp.Expect)
+	case hp.Min != nil && value < *hp.Min:
+		return fmt.Errorf("health probe read %d, below the minimum %d", value, *hp.Min)
+	case hp.Max != nil && value > *hp.Max:
+		return fmt.Errorf("health probe read %d, above the maximum %d", value, *hp.Max)
+	case hp.MaxLatency > 0 && latency > hp.MaxLatency:
+		return fmt.Errorf("health probe took %v, more than the allowed %v", latency.Round(time.Millisecond), hp.MaxLatency)
+	}
+	return nil
+}
+
+// Health returns the verdict of the target check. ok is false when the proxy
+// has none: it is stopped, serves a serial line without a probe, or has only
+// routes. A proxy with endpoints is healthy while one of them is.
+func (p *ProxyInstance) Health() (status HealthStatus, ok bool) {
+	if p.Stats.GetStatus() != "Running" {
+		return HealthStatus{}, false
+	}
+	if len(p.Endpoints) > 0 {
+		return p.endpointsHealth()
+	}
+	p.startMu.Lock()
+	hc := p.healthChecker
+	p.startMu.Unlock()
+	if hc == nil {
+		return HealthStatus{}, false
+	}
+	return hc.GetStatus(), true
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// silentTarget accepts connections and reads everything sent to it without
+// ever answering, like a Huawei sDongle that has lost its inverter.
+func silentTarget(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start mock target: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				_, _ = io.Copy(io.Discard, c)
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+// waitForHealth waits until the proxy's health check reaches a verdict of
+// healthy, and returns the status.
+func waitForHealth(t *testing.T, p *ProxyInstance, healthy bool) HealthStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, ok := p.Health()
+		if ok && !status.LastCheck.IsZero() && status.Healthy == healthy {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health is %+v (ok=%v), want healthy=%v", status, ok, healthy)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestHealthProbe verifies that the probe judges the target by a real read:
+// a device that accepts connections but never answers is unhealthy and has
+// its circuit breaker opened, and so is one whose answer breaks an assertion.
+func TestHealthProbe(t *testing.T) {
+	u16 := func(v uint16) *uint16 { return &v }
+	var reads int64
+	answering := countingTarget(t, &reads, 0)
+	defer answering.Close()
+	slow := countingTarget(t, &reads, 100*time.Millisecond)
+	defer slow.Close()
+	silent := silentTarget(t)
+	defer silent.Close()
+
+	// countingTarget fills every register with the unit ID in both bytes.
+	tests := []struct {
+		name    string
+		target  net.Listener
+		probe   HealthProbe
+		healthy bool
+		errPart string
+	}{
+		{"answers", answering, HealthProbe{UnitID: 3, Function: 3, Count: 1}, true, ""},
+		{"expected value", answering, HealthProbe{UnitID: 3, Function: 4, Count: 2, Expect: u16(0x0303)}, true, ""},
+		{"unexpected value", answering, HealthProbe{UnitID: 3, Function: 3, Count: 1, Expect: u16(1)}, false, "expected 1"},
+		{"out of range", answering, HealthProbe{UnitID: 3, Function: 3, Count: 1, Min: u16(0), Max: u16(100)}, false, "above the maximum"},
+		{"too slow", slow, HealthProbe{UnitID: 3, Function: 3, Count: 1, MaxLatency: 20 * time.Millisecond}, false, "more than the allowed"},
+		{"never answers", silent, HealthProbe{UnitID: 1, Function: 3, Count: 1}, false, "no answer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := tt.probe
+			probe.Interval = 50 * time.Millisecond
+			p := startTestProxy(t, tt.target.Addr().String(), func(p *ProxyInstance) {
+				p.ReadTimeout = 200 * time.Millisecond
+				p.MaxRetries = 0
+				p.HealthProbe = &probe
+			})
+			defer p.Stop()
+
+			status := waitForHealth(t, p, tt.healthy)
+			if status.Probe != "modbus" {
+				t.Errorf("probe kind = %q, want modbus", status.Probe)
+			}
+			if !strings.Contains(status.LastError, tt.errPart) {
+				t.Errorf("last error = %q, want it to mention %q", status.LastError, tt.errPart)
+			}
+			if !tt.healthy {
+				// The breaker is tripped from the checker's goroutine.
+				deadline := time.Now().Add(time.Second)
+				for p.circuitBreaker.GetState() != StateOpen && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if state := p.circuitBreaker.GetState(); state != StateOpen {
+					t.Errorf("circuit breaker state = %v, want open", state)
+				}
+			}
+		})
+	}
+}
+
+// TestHealthProbeFailureThreshold verifies that a target counts as unhealthy
+// only after the configured number of failed probes in a row.
+func TestHealthProbeFailureThreshold(t *testing.T) {
+	silent := silentTarget(t)
+	defer silent.Close()
+
+	p := startTestProxy(t, silent.Addr().String(), func(p *ProxyInstance) {
+		p.ReadTimeout = 100 * time.Millisecond
+		p.MaxRetries = 0
+		p.HealthProbe = &HealthProbe{UnitID: 1, Function: 3, Count: 1, Interval: 50 * time.Millisecond, FailureThreshold: 3}
+	})
+	defer p.Stop()
+
+	status := waitForHealth(t, p, false)
+	if status.ConsecutiveFails < 3 {
+		t.Errorf("unhealthy after %d failed probes, want at least 3", status.ConsecutiveFails)
+	}
+}
+
+// TestHealthCallbacks verifies that a target that stops answering is
+// reported once as down and once with an opened circuit breaker, however
+// many probes fail after that.
+func TestHealthCallbacks(t *testing.T) {
+	silent := silentTarget(t)
+	defer silent.Close()
+
+	down, opened := make(chan string, 10), make(chan struct{}, 10)
+	p := startTestProxy(t, silent.Addr().String(), func(p *ProxyInstance) {
+		p.ReadTimeout = 100 * time.Millisecond
+		p.MaxRetries = 0
+		p.HealthProbe = &HealthProbe{UnitID: 1, Function: 3, Count: 1, Interval: 50 * time.Millisecond}
+		p.OnTargetHealth = func(healthy bool, reason string) {
+			if !healthy {
+				down <- reason
+			}
+		}
+		p.OnCircuitOpen = func() { opened <- struct{}{} }
+	})
+	defer p.Stop()
+
+	select {
+	case reason := <-down:
+		if !strings.Contains(reason, "no answer") {
+			t.Errorf("reason = %q, want it to mention no answer", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("target was not reported down")
+	}
+	select {
+	case <-opened:
+	case <-time.After(time.Second):
+		t.Fatal("circuit breaker opening was not reported")
+	}
+
+	// Further failed probes keep the breaker open without reporting again.
+	time.Sleep(300 * time.Millisecond)
+	if len(down) != 0 || len(opened) != 0 {
+		t.Errorf("reported again: %d down, %d opened", len(down), len(opened))
+	}
+}
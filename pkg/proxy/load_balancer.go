@@ -8,6 +8,7 @@ package proxy
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -23,8 +24,46 @@ const (
 	WeightedRoundRobin
 	IPHash
 	Random
+	Failover // The first available endpoint in configuration order
 )
 
+// policyNames are the names the configuration uses for the policies a proxy
+// with several targets can run. Random is left out: it has nothing to offer
+// over round robin.
+var policyNames = map[string]LoadBalancingPolicy{
+	"failover":          Failover,
+	"round_robin":       RoundRobin,
+	"least_connections": LeastConnections,
+	"weighted":          WeightedRoundRobin,
+	"ip_hash":           IPHash,
+}
+
+// ParseLoadBalancingPolicy returns the policy of a configuration name. The
+// empty name is failover.
+func ParseLoadBalancingPolicy(name string) (LoadBalancingPolicy, error) {
+	if name == "" {
+		return Failover, nil
+	}
+	policy, ok := policyNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown load balancing policy %q", name)
+	}
+	return policy, nil
+}
+
+// String returns the configuration name of the policy.
+func (p LoadBalancingPolicy) String() string {
+	for name, policy := range policyNames {
+		if policy == p {
+			return name
+		}
+	}
+	if p == Random {
+		return "random"
+	}
+	return fmt.Sprintf("policy(%d)", int(p))
+}
+
 // TargetEndpoint represents a backend Modbus device
 type TargetEndpoint struct {
 	Address       string
@@ -35,6 +74,34 @@ type TargetEndpoint struct {
 	FailCount     int64
 	LastCheck     time.Time
 	mu            sync.RWMutex
+
+	// target serves the endpoint when it belongs to a proxy. Its health
+	// checker and circuit breaker then decide whether the endpoint is
+	// available, and IsHealthy is not used.
+	target *ProxyInstance
+}
+
+// NewTargetEndpoint creates an endpoint served by target, configured like any
+// proxy; its listen address is ignored. weight counts for the weighted policy
+// only (0 = 1).
+func NewTargetEndpoint(weight int, target *ProxyInstance) *TargetEndpoint {
+	target.headless = true
+	return &TargetEndpoint{Address: target.TargetAddr, Weight: max(weight, 1), IsHealthy: true, target: target}
+}
+
+// Target returns the proxy that serves this endpoint, if any.
+func (ep *TargetEndpoint) Target() *ProxyInstance {
+	return ep.target
+}
+
+// available reports whether requests may go to the endpoint.
+func (ep *TargetEndpoint) available() bool {
+	if ep.target != nil {
+		return ep.target.available()
+	}
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return ep.IsHealthy
 }
 
 // LoadBalancer manages multiple target endpoints
@@ -112,28 +179,54 @@ func NewLoadBalancer(endpoints []string, config LoadBalancerConfig) (*LoadBalanc
 	return lb, nil
 }
 
+// newEndpointBalancer creates a balancer over endpoints that check their own
+// health, so it runs no check loop of its own.
+func newEndpointBalancer(policy LoadBalancingPolicy, endpoints []*TargetEndpoint) *LoadBalancer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LoadBalancer{
+		policy:    policy,
+		ctx:       ctx,
+		cancel:    cancel,
+		config:    LoadBalancerConfig{Policy: policy},
+		endpoints: endpoints,
+	}
+}
+
 // NextEndpoint returns the next target endpoint based on load balancing policy
 func (lb *LoadBalancer) NextEndpoint() (*TargetEndpoint, error) {
+	selected := lb.pick("")
+	if selected == nil {
+		return nil, fmt.Errorf("no healthy endpoints available")
+	}
+	atomic.AddInt32(&selected.CurrentConns, 1)
+	atomic.AddInt64(&selected.TotalRequests, 1)
+	return selected, nil
+}
+
+// pick selects an endpoint by the policy among the available ones, without
+// counting it as used. client is the address of the client the request is
+// for, or empty if there is none. It returns nil when none is available.
+func (lb *LoadBalancer) pick(client string) *TargetEndpoint {
 	lb.mu.RLock()
 	defer lb.mu.RUnlock()
 
 	// Filter healthy endpoints
 	healthy := make([]*TargetEndpoint, 0)
 	for _, ep := range lb.endpoints {
-		ep.mu.RLock()
-		if ep.IsHealthy {
+		if ep.available() {
 			healthy = append(healthy, ep)
 		}
-		ep.mu.RUnlock()
 	}
 
 	if len(healthy) == 0 {
-		return nil, fmt.Errorf("no healthy endpoints available")
+		return nil
 	}
 
 	var selected *TargetEndpoint
 
 	switch lb.policy {
+	case Failover:
+		selected = healthy[0]
 	case RoundRobin:
 		selected = lb.roundRobin(healthy)
 	case LeastConnections:
@@ -141,19 +234,14 @@ func (lb *LoadBalancer) NextEndpoint() (*TargetEndpoint, error) {
 	case WeightedRoundRobin:
 		selected = lb.weightedRoundRobin(healthy)
 	case IPHash:
-		selected = lb.ipHash(healthy)
+		selected = lb.ipHash(healthy, client)
 	case Random:
 		selected = lb.random(healthy)
 	default:
 		selected = lb.roundRobin(healthy)
 	}
 
-	if selected != nil {
-		atomic.AddInt32(&selected.CurrentConns, 1)
-		atomic.AddInt64(&selected.TotalRequests, 1)
-	}
-
-	return selected, nil
+	return selected
 }
 
 // roundRobin implements round-robin load balancing
@@ -212,11 +300,16 @@ func (lb *LoadBalancer) weightedRoundRobin(healthy []*TargetEndpoint) *TargetEnd
 	return healthy[0]
 }
 
-// ipHash implements consistent hashing based on IP
-func (lb *LoadBalancer) ipHash(healthy []*TargetEndpoint) *TargetEndpoint {
-	// This would use client IP for selection
-	// For now, fall back to round-robin
-	return lb.roundRobin(healthy)
+// ipHash keeps the requests of one client on one endpoint, chosen by a hash
+// of its address. While an endpoint is unavailable its clients spread over
+// the others. A request without a client goes round robin.
+func (lb *LoadBalancer) ipHash(healthy []*TargetEndpoint, client string) *TargetEndpoint {
+	if client == "" {
+		return lb.roundRobin(healthy)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(client))
+	return healthy[h.Sum32()%uint32(len(healthy))]
 }
 
 // random implements random selection
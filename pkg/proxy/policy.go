@@ -0,0 +1,176 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"modbridge/pkg/modbus"
+	"net"
+)
+
+// AccessPolicy is the Modbus-level firewall of a proxy: which function codes
+// and registers a client may use. Anyone who reaches the listen port can
+// otherwise write to the device behind it.
+//
+// The embedded rule applies to every client; the first entry in Clients whose
+// sources contain the client's address replaces it. Unit IDs and addresses are
+// the ones the client sends, before any rewrite.
+type AccessPolicy struct {
+	AccessRule
+	Clients []ClientAccess
+}
+
+// ClientAccess applies a rule to the clients from some networks.
+type ClientAccess struct {
+	Sources []*net.IPNet
+	AccessRule
+}
+
+// AccessRule says what a client may do. The zero rule allows everything.
+type AccessRule struct {
+	ReadOnly  bool          // Only functions that read device state
+	Functions []uint8       // Allowed function codes (empty = all)
+	Ranges    []AccessRange // Allowed addresses (empty = all)
+}
+
+// AccessRange allows access to the addresses Start..Start+Count-1.
+type AccessRange struct {
+	Units    []uint8 // Client unit IDs of the range (empty = all)
+	Start    uint16
+	Count    int
+	ReadOnly bool // Reads only; a write into the range is refused
+}
+
+// Denial describes a request the policy or the authorization refused.
+type Denial struct {
+	ProxyID     string
+	Client      string // Client IP address
+	Certificate string // Subject of the client certificate (empty = none)
+	UnitID      uint8
+	Function    uint8
+	Reason      string
+}
+
+// ruleFor returns the rule for a client address.
+func (ap *AccessPolicy) ruleFor(ip net.IP) *AccessRule {
+	if ap == nil {
+		return nil
+	}
+	if ip != nil {
+		for i := range ap.Clients {
+			for _, src := range ap.Clients[i].Sources {
+				if src.Contains(ip) {
+					return &ap.Clients[i].AccessRule
+				}
+			}
+		}
+	}
+	return &ap.AccessRule
+}
+
+// readsOnly reports whether a request leaves the device's state alone. This
+// is an allow-list on purpose: diagnostics (0x08) can restart a device's
+// communication, and function codes nobody knows about are refused rather
+// than trusted.
+func readsOnly(reqFrame []byte, fc uint8) bool {
+	switch fc {
+	case modbus.FuncReadCoils, modbus.FuncReadDiscreteInputs,
+		modbus.FuncReadHoldingRegisters, modbus.FuncReadInputRegisters,
+		0x07, // Read exception status
+		0x11: // Report server ID
+		return true
+	case 0x2B:
+		// Only the "read device identification" kind of encapsulated
+		// transport; the others can carry anything.
+		return len(reqFrame) > 8 && reqFrame[8] == 0x0E
+	}
+	return false
+}
+
+// check decides a client request. It returns zero when the request may pass,
+// otherwise the exception code to answer with and the reason.
+func (r *AccessRule) check(reqFrame []byte) (uint8, string) {
+	if r == nil {
+		return 0, ""
+	}
+	unitID, fc, ok := modbus.FrameUnitAndFunction(reqFrame)
+	if !ok {
+		return 0, ""
+	}
+
+	if r.ReadOnly && !readsOnly(reqFrame, fc) {
+		return modbus.ExceptionIllegalFunction, fmt.Sprintf("function 0x%02X refused: read-only", fc)
+	}
+	if !containsByte(r.Functions, fc) {
+		return modbus.ExceptionIllegalFunction, fmt.Sprintf("function 0x%02X not allowed", fc)
+	}
+	if len(r.Ranges) == 0 {
+		return 0, ""
+	}
+	// A function without an address the ranges understand (diagnostics,
+	// file records, FIFO queues, vendor codes) could reach anything, so a
+	// rule with ranges refuses it.
+	fields := addressFields(fc)
+	if len(fields) == 0 {
+		return modbus.ExceptionIllegalFunction, fmt.Sprintf("function 0x%02X refused: no address within the allowed ranges", fc)
+	}
+
+	for i, f := range fields {
+		quantity := 1
+		if f.quantity >= 0 {
+			if len(reqFrame) < f.quantity+2 {
+				return modbus.ExceptionIllegalDataAddress, "truncated request"
+			}
+			quantity = int(binary.BigEndian.Uint16(reqFrame[f.quantity:]))
+		} else if len(reqFrame) < f.offset+2 {
+			return modbus.ExceptionIllegalDataAddress, "truncated request"
+		}
+		addr := binary.BigEndian.Uint16(reqFrame[f.offset:])
+
+		// Read/write multiple reads with its first field and writes with
+		// its second; every other write function writes with its only one.
+		write := modbus.IsWriteFunction(fc) && (fc != modbus.FuncReadWriteRegisters || i == 1)
+		if !r.allows(unitID, addr, quantity, write) {
+			return modbus.ExceptionIllegalDataAddress,
+				fmt.Sprintf("unit %d address %d-%d not allowed for function 0x%02X", unitID, addr, int(addr)+quantity-1, fc)
+		}
+	}
+	return 0, ""
+}
+
+// allows reports whether one range of the rule covers a request's addresses.
+func (r *AccessRule) allows(unitID uint8, addr uint16, quantity int, write bool) bool {
+	first, end := int(addr), int(addr)+quantity
+	for _, rg := range r.Ranges {
+		if !containsByte(rg.Units, unitID) || (write && rg.ReadOnly) {
+			continue
+		}
+		if first >= int(rg.Start) && end <= int(rg.Start)+rg.Count {
+			return true
+		}
+	}
+	return false
+}
+
+// deny answers a refused request with a Modbus exception, counts it and
+// reports it. certificate is the subject of the client's certificate, if it
+// has one.
+func (p *ProxyInstance) deny(client, certificate string, reqFrame []byte, exception uint8, reason string) []byte {
+	p.Stats.Denied.Add(1)
+	if certificate != "" {
+		p.log.Warn(p.ID, fmt.Sprintf("Denied request from %s (%s): %s", client, certificate, reason))
+	} else {
+		p.log.Warn(p.ID, fmt.Sprintf("Denied request from %s: %s", client, reason))
+	}
+
+	txID, _ := modbus.FrameTxID(reqFrame)
+	unitID, fc, _ := modbus.FrameUnitAndFunction(reqFrame)
+	if p.OnDenied != nil {
+		p.OnDenied(Denial{ProxyID: p.ID, Client: client, Certificate: certificate, UnitID: unitID, Function: fc, Reason: reason})
+	}
+	return modbus.ExceptionResponse(txID, unitID, fc, exception)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/modbus"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAccessRuleCheck checks single requests against a rule.
+func TestAccessRuleCheck(t *testing.T) {
+	rule := &AccessRule{
+		Functions: []uint8{modbus.FuncReadHoldingRegisters, modbus.FuncWriteSingleRegister, modbus.FuncWriteMultipleRegisters},
+		Ranges: []AccessRange{
+			{Start: 0, Count: 1000, ReadOnly: true},
+			{Units: []uint8{1}, Start: 100, Count: 10},
+		},
+	}
+	writeSingle := func(unitID uint8, addr uint16) []byte {
+		return []byte{0, 1, 0, 0, 0, 6, unitID, modbus.FuncWriteSingleRegister, byte(addr >> 8), byte(addr), 0, 1}
+	}
+
+	// A rule with ranges only allows every function code, yet one that
+	// carries no address must not get past the ranges.
+	rangesOnly := &AccessRule{Ranges: []AccessRange{{Start: 0, Count: 100}}}
+	writeFileRecord := []byte{0, 1, 0, 0, 0, 12, 1, 0x15, 9, 6, 0, 1, 0, 0, 0, 1, 0, 42}
+	diagnostics := []byte{0, 1, 0, 0, 0, 6, 1, 0x08, 0, 1, 0, 0}
+
+	tests := []struct {
+		name      string
+		rule      *AccessRule // nil = rule
+		req       []byte
+		exception uint8
+	}{
+		{"read inside the read-only range", nil, modbus.CreateReadRequest(1, 5, 3, 10, 4), 0},
+		{"read outside every range", nil, modbus.CreateReadRequest(1, 5, 3, 2000, 4), modbus.ExceptionIllegalDataAddress},
+		{"read across the end of a range", nil, modbus.CreateReadRequest(1, 5, 3, 998, 4), modbus.ExceptionIllegalDataAddress},
+		{"function not in the list", nil, modbus.CreateReadRequest(1, 5, 4, 10, 4), modbus.ExceptionIllegalFunction},
+		{"write into the writable range", nil, writeSingle(1, 105), 0},
+		{"write into the read-only range", nil, writeSingle(1, 50), modbus.ExceptionIllegalDataAddress},
+		{"write by another unit", nil, writeSingle(2, 105), modbus.ExceptionIllegalDataAddress},
+		{"write file record under ranges only", rangesOnly, writeFileRecord, modbus.ExceptionIllegalFunction},
+		{"diagnostics under ranges only", rangesOnly, diagnostics, modbus.ExceptionIllegalFunction},
+		{"read inside ranges only", rangesOnly, modbus.CreateReadRequest(1, 5, 3, 10, 4), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.rule
+			if r == nil {
+				r = rule
+			}
+			if exception, reason := r.check(tt.req); exception != tt.exception {
+				t.Errorf("exception = 0x%02X (%s), want 0x%02X", exception, reason, tt.exception)
+			}
+		})
+	}
+
+	readOnly := &AccessRule{ReadOnly: true}
+	if exception, _ := readOnly.check(writeSingle(1, 105)); exception != modbus.ExceptionIllegalFunction {
+		t.Errorf("read-only rule let a write through (exception 0x%02X)", exception)
+	}
+	if exception, _ := readOnly.check(diagnostics); exception != modbus.ExceptionIllegalFunction {
+		t.Errorf("read-only rule let a diagnostics restart through (exception 0x%02X)", exception)
+	}
+}
+
+// TestPolicyThroughProxy verifies that a refused write never reaches the
+// device, is answered with an exception, counted and reported, and that a
+// client network with its own rule may still write.
+func TestPolicyThroughProxy(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	var mu sync.Mutex
+	var denials []Denial
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+
+	start := func(clients []ClientAccess) *ProxyInstance {
+		return startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+			p.Policy = &AccessPolicy{AccessRule: AccessRule{ReadOnly: true}, Clients: clients}
+			p.OnDenied = func(d Denial) {
+				mu.Lock()
+				denials = append(denials, d)
+				mu.Unlock()
+			}
+		})
+	}
+	write := []byte{0, 7, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 0, 10, 0, 42}
+
+	roundTrip := func(p *ProxyInstance, req []byte) []byte {
+		conn, err := net.Dial("tcp", p.ListenAddr)
+		if err != nil {
+			t.Fatalf("failed to connect to proxy: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write(req); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			t.Fatalf("set deadline failed: %v", err)
+		}
+		resp, err := modbus.ReadFrame(conn)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return resp
+	}
+
+	p := start(nil)
+	resp := roundTrip(p, write)
+	if len(resp) != 9 || resp[0] != 0 || resp[1] != 7 || resp[7] != 0x86 || resp[8] != modbus.ExceptionIllegalFunction {
+		t.Errorf("refused write: got % X, want exception 0x01 under transaction 7", resp)
+	}
+	if _, err := modbus.ParseReadResponse(roundTrip(p, modbus.CreateReadRequest(8, 1, 3, 0, 2))); err != nil {
+		t.Errorf("read on a read-only proxy failed: %v", err)
+	}
+	if got := p.Stats.Denied.Load(); got != 1 {
+		t.Errorf("proxy counted %d denials, want 1", got)
+	}
+	mu.Lock()
+	if len(denials) != 1 || denials[0].Client != "127.0.0.1" || denials[0].Function != modbus.FuncWriteSingleRegister {
+		t.Errorf("reported denials = %+v, want one write from 127.0.0.1", denials)
+	}
+	mu.Unlock()
+	p.Stop()
+
+	p = start([]ClientAccess{{Sources: []*net.IPNet{loopback}}})
+	defer p.Stop()
+	if resp := roundTrip(p, write); string(resp) != string(write) {
+		t.Errorf("write from an allowed network: got % X, want the echo % X", resp, write)
+	}
+}
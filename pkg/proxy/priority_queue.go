@@ -22,6 +22,27 @@ const (
 	PriorityCritical RequestPriority = 3
 )
 
+// priorityNames are the configuration names of the priorities.
+var priorityNames = map[string]RequestPriority{
+	"low":      PriorityLow,
+	"normal":   PriorityNormal,
+	"high":     PriorityHigh,
+	"critical": PriorityCritical,
+}
+
+// ParseRequestPriority returns the priority of a configuration name. The
+// empty name is normal.
+func ParseRequestPriority(name string) (RequestPriority, error) {
+	if name == "" {
+		return PriorityNormal, nil
+	}
+	priority, ok := priorityNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown priority %q", name)
+	}
+	return priority, nil
+}
+
 // Request represents a queued request
 type Request struct {
 	ID           int64
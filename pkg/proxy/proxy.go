@@ -7,14 +7,19 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
+	"modbridge/pkg/capture"
 	"modbridge/pkg/devices"
 	"modbridge/pkg/logger"
 	"modbridge/pkg/middleware"
 	"modbridge/pkg/modbus"
 	"modbridge/pkg/pool"
+	"modbridge/pkg/rtu"
+	"modbridge/pkg/simulator"
+	mtls "modbridge/pkg/tls"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -89,27 +94,62 @@ type ProxyInstance struct {
 	ConnectionTimeout time.Duration
 	ReadTimeout       time.Duration
 	MaxRetries        int
-	MaxConns          int           // Maximum concurrent connections (0 = unlimited)
-	Protocol          string        // "tcp" (default) or "rtu-tcp"
-	ConnectDelay      time.Duration // Optional pause after TCP connect before first request (for slow devices like Huawei inverters/sDongles)
-	MaxTargetConns    int           // Maximum simultaneous connections to the target (0 = default). Set to 1 for devices that accept a single Modbus session (SolarEdge/SunSpec inverters).
-	MinRequestGap     time.Duration // Minimum spacing between two requests to the target (0 = none)
-	RequestTimeout    time.Duration // Hard cap for one client request including retries (0 = derived from read timeout and retries)
-	CacheEnabled      bool          // Serve repeated reads from a cache instead of asking the target every time
-	CacheTTL          time.Duration // How long a cached read stays valid (0 = 5s default)
-	PollInterval      time.Duration // Refresh cached reads in the background at this interval (0 = passive cache only)
-
-	listener    net.Listener
-	connPool    *pool.Pool
-	connSem     chan struct{} // Semaphore for limiting concurrent connections
-	startMu     sync.Mutex    // Protects Start/Stop lifecycle
-	pacer       *requestPacer // Enforces MinRequestGap towards the target
-	cache       *ResponseCache
-	poller      *RegisterPoller
-	lastRead    atomic.Value          // Last read a client asked for, replayed as a calibration probe
-	calibrating atomic.Bool           // A measurement owns the target: hold clients off for its duration
-	clientsMu   sync.Mutex            // Guards clients
-	clients     map[net.Conn]struct{} // Live client connections, so a measurement can hand the device back
+	MaxConns          int                      // Maximum concurrent connections (0 = unlimited)
+	Protocol          string                   // "tcp" (default), "rtu-tcp", "serial" or "virtual"
+	Serial            *rtu.Config              // Serial line settings when Protocol is "serial" and the proxy owns the line
+	BusID             string                   // Shared serial bus to use when Protocol is "serial" (replaces Serial)
+	Bus               *rtu.Bus                 // The shared bus named by BusID; nil if it does not exist
+	Virtual           *simulator.Device        // The simulated device when Protocol is "virtual"
+	ConnectDelay      time.Duration            // Optional pause after TCP connect before first request (for slow devices like Huawei inverters/sDongles)
+	MaxTargetConns    int                      // Maximum simultaneous connections to the target (0 = default). Set to 1 for devices that accept a single Modbus session (SolarEdge/SunSpec inverters).
+	MinRequestGap     time.Duration            // Minimum spacing between two requests to the target (0 = none)
+	RequestTimeout    time.Duration            // Hard cap for one client request including retries (0 = derived from read timeout and retries)
+	CacheEnabled      bool                     // Serve repeated reads from a cache instead of asking the target every time
+	CacheTTL          time.Duration            // How long a cached read stays valid (0 = 5s default)
+	PollInterval      time.Duration            // Refresh cached reads in the background at this interval (0 = passive cache only)
+	CoalesceReads     bool                     // Let identical reads in flight at the same time share one request to the target
+	Routes            []*Route                 // Unit-ID routes to other targets; unrouted units go to TargetAddr, if set
+	Rewrite           *RewriteRules            // Unit-ID and register address translation between client and device (nil = none)
+	Policy            *AccessPolicy            // Modbus firewall: allowed function codes and registers per client (nil = allow all)
+	OnDenied          func(Denial)             // Called for every request the policy or the authorization refuses
+	RateLimit         *RateLimit               // Request-rate limits per client and proxy, and fair queuing towards the target (nil = none)
+	OnRefreshed       func()                   // Called after every background refresh round of the cache
+	HealthProbe       *HealthProbe             // A Modbus read that decides target health instead of a TCP connect (nil = connect)
+	ListenTLS         *mtls.ModbusServerConfig // Modbus/TCP Security on the listen port (nil = plain Modbus TCP)
+	Authorization     *CertAuthorization       // What the holders of client certificates may do (nil = anything the policy allows)
+	TargetTLS         *mtls.ModbusClientConfig // TLS towards the target (nil = plain Modbus TCP)
+	// OnTargetHealth is called when the target check finds the target down,
+	// and again when it is back; OnCircuitOpen when the circuit breaker opens.
+	OnTargetHealth func(healthy bool, reason string)
+	OnCircuitOpen  func()
+	// Endpoints are redundant targets for the same device, used instead of
+	// TargetAddr; EndpointPolicy says which of them gets a request. Its zero
+	// value is RoundRobin; the configuration defaults to Failover.
+	Endpoints      []*TargetEndpoint
+	EndpointPolicy LoadBalancingPolicy
+
+	headless     bool // Serves a route of another proxy: no listener of its own
+	listener     net.Listener
+	connPool     *pool.Pool
+	serialBus    *rtu.Bus                                      // The serial line when Protocol is "serial"; takes the place of connPool
+	openSerial   func(cfg *rtu.Config) (rtu.SerialPort, error) // Opens the serial line (nil = rtu.OpenSerialPort); replaced in tests
+	connSem      chan struct{}                                 // Semaphore for limiting concurrent connections
+	startMu      sync.Mutex                                    // Protects Start/Stop lifecycle
+	pacer        *requestPacer                                 // Enforces MinRequestGap towards the target
+	cache        *ResponseCache
+	poller       *RegisterPoller
+	lastRead     atomic.Value                    // Last read a client asked for, replayed as a calibration probe
+	calibrating  atomic.Bool                     // A measurement owns the target: hold clients off for its duration
+	clientsMu    sync.Mutex                      // Guards clients
+	clients      map[net.Conn]*clientSession     // Live client connections, for the session view and so a measurement can hand the device back
+	capture      atomic.Pointer[capture.Session] // Records frames while a capture runs (nil = none)
+	balancer     *LoadBalancer                   // Picks among Endpoints (nil = no endpoints)
+	limiter      *rateLimiter                    // Enforces RateLimit (nil = none)
+	queue        *fairQueue                      // Shares the target among clients when RateLimit is set (nil = none)
+	inflight     flightGroup                     // Reads on their way to the target, shared by identical reads
+	listenTLS    *tls.Config                     // Built from ListenTLS at start (nil = plain)
+	targetTLS    *tls.Config                     // Built from TargetTLS at start (nil = plain)
+	certificates []CertificateStatus             // Certificates loaded at start, for the expiry check
 
 	log           *logger.Logger
 	deviceTracker *devices.Tracker
@@ -123,6 +163,7 @@ type ProxyInstance struct {
 	requestID       int64
 	targetTxID      uint32
 	staleResponses  int64
+	pacingWait      atomic.Int64 // Time requests spent waiting for MinRequestGap, in nanoseconds
 	healthChecker   *HealthChecker
 	adaptiveTimeout *AdaptiveTimeout
 	recoveryManager *RecoveryManager
@@ -143,6 +184,9 @@ type Stats struct {
 	lastStartNano atomic.Int64 // stores UnixNano; use SetLastStart/GetLastStart
 	Requests      atomic.Int64
 	Errors        atomic.Int64
+	Denied        atomic.Int64 // Requests refused by the access policy or the authorization
+	Limited       atomic.Int64 // Requests answered busy by the rate limits
+	Coalesced     atomic.Int64 // Reads answered with the response to an identical read in flight
 	ActiveConns   atomic.Int64
 	status        atomic.Value // stores string
 }
@@ -215,22 +259,66 @@ func (p *ProxyInstance) Start() error {
 	}
 
 	validator := middleware.NewValidator()
-	if err := validator.ValidatePort(p.ListenAddr); err != nil {
-		p.Stats.setStatus("Error")
-		return fmt.Errorf("invalid listen address: %w", err)
+	if !p.headless {
+		if err := validator.ValidatePort(p.ListenAddr); err != nil {
+			p.Stats.setStatus("Error")
+			return fmt.Errorf("invalid listen address: %w", err)
+		}
 	}
-	if err := validator.ValidatePort(p.TargetAddr); err != nil {
+	serial := p.Protocol == ProtocolSerial
+	virtual := p.Protocol == ProtocolVirtual
+	// A gateway whose routes cover every unit it serves needs no target of
+	// its own, and neither does a proxy whose endpoints are its targets.
+	ownTarget := len(p.Endpoints) == 0 && (p.hasOwnTarget() || len(p.Routes) == 0)
+	switch {
+	case !ownTarget || serial:
+	case virtual:
+		if p.Virtual == nil {
+			p.Stats.setStatus("Error")
+			return errNoVirtualDevice
+		}
+	default:
+		if err := validator.ValidatePort(p.TargetAddr); err != nil {
+			p.Stats.setStatus("Error")
+			return fmt.Errorf("invalid target address: %w", err)
+		}
+	}
+
+	if err := p.loadTLS(); err != nil {
 		p.Stats.setStatus("Error")
-		return fmt.Errorf("invalid target address: %w", err)
+		p.log.Error(p.ID, fmt.Sprintf("Failed to load certificates: %v", err))
+		return err
 	}
 
-	l, err := net.Listen("tcp", p.ListenAddr)
-	if err != nil {
+	// Routes and endpoints first: nothing else has been set up yet that
+	// would need undoing.
+	if err := p.startRoutes(); err != nil {
+		p.Stats.setStatus("Error")
+		p.log.Error(p.ID, fmt.Sprintf("Failed to start route: %v", err))
+		return err
+	}
+	if err := p.startEndpoints(); err != nil {
+		p.stopRoutes()
 		p.Stats.setStatus("Error")
-		p.log.Error(p.ID, fmt.Sprintf("Port %s already in use or invalid: %v", p.ListenAddr, err))
-		return fmt.Errorf("port %s already in use: %w", p.ListenAddr, err)
+		p.log.Error(p.ID, fmt.Sprintf("Failed to start target: %v", err))
+		return err
+	}
+
+	var err error
+	if !p.headless {
+		l, lerr := net.Listen("tcp", p.ListenAddr)
+		if lerr != nil {
+			p.stopEndpoints()
+			p.stopRoutes()
+			p.Stats.setStatus("Error")
+			p.log.Error(p.ID, fmt.Sprintf("Port %s already in use or invalid: %v", p.ListenAddr, lerr))
+			return fmt.Errorf("port %s already in use: %w", p.ListenAddr, lerr)
+		}
+		if p.listenTLS != nil {
+			l = tls.NewListener(l, p.listenTLS)
+		}
+		p.listener = l
 	}
-	p.listener = l
 
 	// Create connection pool for target with optimized settings.
 	// MaxTargetConns caps how many sockets the target ever sees at once. Many
@@ -240,7 +328,20 @@ func (p *ProxyInstance) Start() error {
 	if maxTargetConns <= 0 {
 		maxTargetConns = 10 // Moderate concurrency limit to avoid dropping connections
 	}
-	p.pacer = &requestPacer{gap: p.MinRequestGap}
+	p.pacer = &requestPacer{gap: p.MinRequestGap, waited: &p.pacingWait}
+
+	// The queue has as many slots as the target has connections; a request
+	// holding one is sure of a connection, so beyond that the waiting happens
+	// here, in fair order, rather than in the pool.
+	p.limiter, p.queue = nil, nil
+	if p.RateLimit != nil {
+		p.limiter = newRateLimiter(p.RateLimit)
+		slots := maxTargetConns
+		if serial {
+			slots = 1
+		}
+		p.queue = newFairQueue(slots)
+	}
 
 	poolCfg := pool.Config{
 		InitialSize:    1, // Modbus targets usually accept 1-3 connections max
@@ -252,7 +353,7 @@ func (p *ProxyInstance) Start() error {
 				Timeout:   p.ConnectionTimeout,
 				KeepAlive: 30 * time.Second, // Optimized: TCP keep-alive
 			}
-			conn, err := d.DialContext(ctx, "tcp", p.TargetAddr)
+			conn, err := p.dialTargetConn(ctx, d)
 			if err != nil {
 				return nil, err
 			}
@@ -273,51 +374,37 @@ func (p *ProxyInstance) Start() error {
 		},
 	}
 
-	p.connPool, err = pool.NewPool(poolCfg)
-	if err != nil {
-		p.listener.Close()
-		p.Stats.setStatus("Error")
-		p.log.Error(p.ID, fmt.Sprintf("Failed to create connection pool: %v", err))
-		return err
+	if p.headless {
+		// A route dials on first use: one device that is switched off must
+		// not keep the gateway and its other routes from starting.
+		poolCfg.InitialSize = 0
+	}
+
+	switch {
+	case serial:
+		// A serial line is opened once and kept; there is nothing to pool.
+		if err := p.startSerial(); err != nil {
+			p.abortStart()
+			p.log.Error(p.ID, fmt.Sprintf("Failed to open serial line: %v", err))
+			return err
+		}
+	case ownTarget:
+		p.connPool, err = pool.NewPool(poolCfg)
+		if err != nil {
+			p.abortStart()
+			p.log.Error(p.ID, fmt.Sprintf("Failed to create connection pool: %v", err))
+			return err
+		}
 	}
 
 	// Initialize enhanced features
 	p.circuitBreaker = NewCircuitBreaker(DefaultCircuitBreakerConfig())
+	if p.OnCircuitOpen != nil {
+		p.circuitBreaker.SetOnOpen(p.OnCircuitOpen)
+	}
 	p.enhancedStats = NewEnhancedStats(1000) // Track last 1000 requests
 	p.requestID = 0
 
-	// Initialize health checker
-	p.healthChecker = NewHealthChecker(
-		p.TargetAddr,
-		30*time.Second,
-		p.ConnectionTimeout,
-		func(id, msg string) { p.log.Info(id, msg) },
-	)
-	p.healthChecker.Start()
-
-	p.healthChecker.SetOnUnhealthy(func() {
-		p.log.Info(p.ID, "Health checker detected target failure, triggering recovery")
-		if p.recoveryManager != nil {
-			if _, err := p.recoveryManager.AddTask(p.TargetAddr, 10); err != nil {
-				p.log.Error(p.ID, fmt.Sprintf("failed to schedule recovery task: %v", err))
-			}
-		}
-	})
-
-	p.healthChecker.SetOnRecovery(func() {
-		p.log.Info(p.ID, "Health checker detected target recovery, resetting circuit breaker and pre-warming pool")
-		if p.circuitBreaker != nil {
-			p.circuitBreaker.Reset()
-		}
-		if p.connPool != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-			if err := p.connPool.PreWarm(ctx, 2); err != nil {
-				p.log.Error(p.ID, fmt.Sprintf("Pool pre-warm failed: %v", err))
-			}
-		}
-	})
-
 	// RecoveryManager already performs a real TCP dial in attemptRecovery.
 	// No additional onRecovery callback is needed here.
 	p.recoveryManager = NewRecoveryManager(DefaultRecoveryConfig(), nil)
@@ -327,6 +414,14 @@ func (p *ProxyInstance) Start() error {
 
 	p.ctx, p.cancel = context.WithCancel(context.Background())
 
+	// Initialize health checker. Without a probe it dials the target over
+	// TCP, which means nothing for a serial line or a simulated device:
+	// there, every forwarded request is the check. It starts only now
+	// because a probe forwards a request, and that needs the timeouts above.
+	if ownTarget && (!(serial || virtual) || p.HealthProbe != nil) {
+		p.startHealthChecker()
+	}
+
 	// Read cache and background poller. Both are opt-in: a cached register is
 	// by definition not the live value, which is right for dashboards and
 	// wrong for control loops, so the operator decides.
@@ -340,10 +435,11 @@ func (p *ProxyInstance) Start() error {
 			p.PollInterval,
 			10*cacheCfg.TTL,
 			512,
-			p.forwardClientRequest,
+			func(req []byte) ([]byte, error) { return p.forwardClientRequest("", req) },
 			func(key uint64, unitID uint8, resp []byte) { p.cache.SetForUnit(key, unitID, resp) },
 			func(msg string) { p.log.Debug(p.ID, msg) },
 		)
+		p.poller.afterRound = p.OnRefreshed
 		p.poller.Start(p.ctx)
 		p.log.Info(p.ID, fmt.Sprintf("Response cache enabled (ttl %v, background poll %v)", cacheCfg.TTL, p.PollInterval))
 		if cacheTTLTooTight(cacheCfg.TTL, p.PollInterval) {
@@ -356,13 +452,121 @@ func (p *ProxyInstance) Start() error {
 	p.Stats.setStatus("Running")
 	p.Stats.SetLastStart(time.Now())
 
-	p.log.Info(p.ID, fmt.Sprintf("Started proxy listening on %s -> %s (max conns: %d)", p.ListenAddr, p.TargetAddr, p.MaxConns))
+	target := p.TargetAddr
+	if virtual {
+		target = "virtual device"
+	}
+	if serial {
+		target = p.serialBus.Device()
+		if p.BusID != "" {
+			target = fmt.Sprintf("%s (bus %s)", target, p.BusID)
+		}
+	}
+	if len(p.Endpoints) > 0 {
+		target = fmt.Sprintf("%d targets (%s)", len(p.Endpoints), p.EndpointPolicy)
+	}
+	if p.headless {
+		p.log.Info(p.ID, fmt.Sprintf("Started route %s -> %s", p.Name, target))
+		return nil
+	}
+	if len(p.Routes) > 0 {
+		if !p.hasOwnTarget() {
+			target = "no default target"
+		}
+		target = fmt.Sprintf("%s, %d routes", target, len(p.Routes))
+	}
+	security := ""
+	if p.listenTLS != nil {
+		security = " with Modbus/TCP Security"
+	}
+	p.log.Info(p.ID, fmt.Sprintf("Started proxy listening on %s%s -> %s (max conns: %d)", p.ListenAddr, security, target, p.MaxConns))
 
 	p.wg.Add(1)
 	go p.acceptLoop()
+	if len(p.Certificates()) > 0 {
+		p.checkCertificates()
+		p.wg.Add(1)
+		go p.watchCertificates()
+	}
 	return nil
 }
 
+// abortStart undoes the parts of Start that ran before a failure.
+func (p *ProxyInstance) abortStart() {
+	if p.listener != nil {
+		p.listener.Close()
+	}
+	p.stopEndpoints()
+	p.stopRoutes()
+	p.Stats.setStatus("Error")
+}
+
+// startHealthChecker starts the periodic target check and connects it to
+// recovery, the circuit breaker and the pool. With a probe, a failing target
+// also holds the circuit breaker open, so clients get an exception at once
+// instead of each waiting out the timeouts against a device that does not
+// answer.
+func (p *ProxyInstance) startHealthChecker() {
+	interval, timeout := 30*time.Second, p.ConnectionTimeout
+	if p.HealthProbe != nil {
+		if p.HealthProbe.Interval > 0 {
+			interval = p.HealthProbe.Interval
+		}
+		timeout = p.requestBudget() + time.Second
+	}
+	p.healthChecker = NewHealthChecker(
+		p.TargetAddr,
+		interval,
+		timeout,
+		func(_, msg string) { p.log.Info(p.ID, msg) },
+	)
+	if p.targetTLS != nil {
+		p.healthChecker.SetDialer(func(ctx context.Context) (net.Conn, error) {
+			return p.dialTargetConn(ctx, net.Dialer{Timeout: timeout})
+		})
+	}
+	if p.HealthProbe != nil {
+		p.healthChecker.SetProbe(p.probeTarget, p.HealthProbe.FailureThreshold)
+		p.healthChecker.SetOnFailure(func() {
+			if p.circuitBreaker != nil {
+				p.circuitBreaker.Trip()
+			}
+		})
+		p.log.Info(p.ID, fmt.Sprintf("Health probe: %s every %v", p.HealthProbe, interval))
+	}
+
+	hc := p.healthChecker
+	p.healthChecker.SetOnUnhealthy(func() {
+		p.log.Info(p.ID, "Health checker detected target failure, triggering recovery")
+		if p.OnTargetHealth != nil {
+			p.OnTargetHealth(false, hc.GetStatus().LastError)
+		}
+		if p.recoveryManager != nil {
+			if _, err := p.recoveryManager.AddTask(p.TargetAddr, 10); err != nil {
+				p.log.Error(p.ID, fmt.Sprintf("failed to schedule recovery task: %v", err))
+			}
+		}
+	})
+
+	p.healthChecker.SetOnRecovery(func() {
+		p.log.Info(p.ID, "Health checker detected target recovery, resetting circuit breaker and pre-warming pool")
+		if p.OnTargetHealth != nil {
+			p.OnTargetHealth(true, "")
+		}
+		if p.circuitBreaker != nil {
+			p.circuitBreaker.Reset()
+		}
+		if p.connPool != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := p.connPool.PreWarm(ctx, 2); err != nil {
+				p.log.Error(p.ID, fmt.Sprintf("Pool pre-warm failed: %v", err))
+			}
+		}
+	})
+	p.healthChecker.Start()
+}
+
 // Stop stops the proxy.
 func (p *ProxyInstance) Stop() {
 	p.startMu.Lock()
@@ -401,6 +605,13 @@ func (p *ProxyInstance) Stop() {
 	// Wait for all goroutines to finish
 	p.wg.Wait()
 
+	// Only now: a handler still in an exchange must not find the line closed
+	// underneath it, nor its route stopped.
+	p.stopSerial()
+	p.stopVirtual()
+	p.stopEndpoints()
+	p.stopRoutes()
+
 	p.Stats.setStatus("Stopped")
 }
 
@@ -472,6 +683,9 @@ acceptLoop:
 }
 
 func configureTCPConn(conn net.Conn) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
 	tcpConn, ok := conn.(*net.TCPConn)
 	if !ok {
 		return
@@ -482,16 +696,19 @@ func configureTCPConn(conn net.Conn) {
 	_ = tcpConn.SetNoDelay(true)
 }
 
-// registerClient remembers a live client connection. The proxy needs to be able
-// to reach them for one reason only: a measurement has to hand the device back,
-// and nobody can unplug a controller on request.
-func (p *ProxyInstance) registerClient(conn net.Conn) {
+// registerClient remembers a live client connection and starts its session.
+// The proxy needs to reach its clients when a measurement has to hand the
+// device back, since nobody can unplug a controller on request, and when an
+// admin ends a session.
+func (p *ProxyInstance) registerClient(conn net.Conn) *clientSession {
 	p.clientsMu.Lock()
 	defer p.clientsMu.Unlock()
 	if p.clients == nil {
-		p.clients = make(map[net.Conn]struct{})
+		p.clients = make(map[net.Conn]*clientSession)
 	}
-	p.clients[conn] = struct{}{}
+	s := newClientSession(conn)
+	p.clients[conn] = s
+	return s
 }
 
 // unregisterClient forgets a connection that has ended.
@@ -531,10 +748,27 @@ func (p *ProxyInstance) handleClient(clientConn net.Conn, sem chan struct{}) {
 		p.deviceTracker.TrackConnection(clientConn, p.ID)
 	}
 
-	// Remembered so a measurement can reach this connection and end it.
-	p.registerClient(clientConn)
+	if err := p.clientHandshake(clientConn); err != nil {
+		p.log.Warn(p.ID, fmt.Sprintf("TLS handshake with %s failed: %v", clientConn.RemoteAddr(), err))
+		return
+	}
+
+	// Remembered so a measurement or an admin can reach this connection and
+	// end it.
+	session := p.registerClient(clientConn)
 	defer p.unregisterClient(clientConn)
 
+	// The policy cannot change while the proxy runs, so the client's rule
+	// and the grant of its certificate are looked up once per connection.
+	client, _, err := net.SplitHostPort(clientConn.RemoteAddr().String())
+	if err != nil {
+		client = clientConn.RemoteAddr().String()
+	}
+	access := p.Policy.ruleFor(net.ParseIP(client))
+	grant := p.Authorization.grantFor(session)
+	limits := p.RateLimit.limitsFor(net.ParseIP(client))
+	remoteAddr, localAddr := clientConn.RemoteAddr().String(), clientConn.LocalAddr().String()
+
 	for {
 		// Check context
 		select {
@@ -565,6 +799,10 @@ func (p *ProxyInstance) handleClient(clientConn net.Conn, sem chan struct{}) {
 			}
 			return
 		}
+		received := time.Now()
+		if c := p.capture.Load(); c != nil {
+			c.Record(capture.Frame{Time: received, Side: capture.SideClient, Src: remoteAddr, Dst: localAddr, Data: reqFrame})
+		}
 
 		// Debug: Log incoming Modbus request. Guard the formatting call so we
 		// skip the (expensive) %X sprintf on every request when DEBUG is off,
@@ -573,98 +811,35 @@ func (p *ProxyInstance) handleClient(clientConn net.Conn, sem chan struct{}) {
 			p.log.Debug(p.ID, fmt.Sprintf("Received Modbus request: %X (%d bytes)", reqFrame, len(reqFrame)))
 		}
 
-		// Serve reads from the cache when one is enabled. This runs before the
-		// circuit breaker on purpose: when the target is unreachable, recent
-		// data is more useful to the client than an exception, and the TTL
-		// bounds how long that can go on.
-		cacheKey, cacheUnit, cacheable := modbus.RequestCacheKey(reqFrame)
-		if cacheable {
-			// Remember it so calibration can probe with a register the client
-			// already asks for, instead of touching something new.
-			p.recordObservedRead(reqFrame)
-		}
-		if p.cache != nil && cacheable {
-			if p.poller != nil {
-				p.poller.Track(cacheKey, cacheUnit, reqFrame)
-			}
-			if cached, hit := p.cache.Get(cacheKey); hit {
-				clientTxID, _ := modbus.FrameTxID(reqFrame)
-				modbus.SetFrameTxID(cached, clientTxID)
-				p.Stats.Requests.Add(1)
-				if _, err := clientConn.Write(cached); err != nil {
-					p.log.Error(p.ID, fmt.Sprintf("Write cached response error: %v", err))
-					return
-				}
-				continue
-			}
-		}
-
-		// Check circuit breaker BEFORE forwarding
-		if !p.circuitBreaker.AllowRequest() {
-			p.log.Error(p.ID, "Circuit breaker is OPEN, rejecting request")
-			p.Stats.Errors.Add(1)
-			// Send error response to client
-			// Modbus exception: Gateway Target Device Failed to Respond
-			exceptionResp := modbus.CreateExceptionResponse(reqFrame, 0x0B)
-			if _, writeErr := clientConn.Write(exceptionResp); writeErr != nil {
-				p.log.Error(p.ID, fmt.Sprintf("Write exception response error: %v", writeErr))
-				return
-			}
-			continue
-		}
-
-		// Generate unique request ID and track start time
-		reqID := p.getNextRequestID()
-		p.enhancedStats.RecordRequestStart(reqID)
-
+		// The policy and the client's certificate judge what the client
+		// asked for. Then the rewrite: routes, cache and target all work in
+		// the device's numbering, and only the client sees its own.
 		var respFrame []byte
-		var errFwd error
-
-		forwardStart := time.Now()
-
-		// Route to the appropriate forwarding function based on protocol.
-		respFrame, errFwd = p.forwardClientRequest(reqFrame)
-
-		// Record completion
-		bytesRead := len(reqFrame)
-		bytesWritten := 0
-		if errFwd != nil {
-			p.log.Error(p.ID, fmt.Sprintf("Forward error: %v", errFwd))
+		if exception, reason := access.check(reqFrame); exception != 0 {
+			respFrame = p.deny(client, session.certSubject, reqFrame, exception, reason)
+		} else if exception, reason := grant.check(reqFrame); exception != 0 {
+			respFrame = p.deny(client, session.certSubject, reqFrame, exception, reason)
+		} else if fwdFrame, exception := p.Rewrite.rewriteRequest(reqFrame); exception != 0 {
 			p.Stats.Errors.Add(1)
-			p.circuitBreaker.RecordFailure()
-			p.enhancedStats.RecordRequestComplete(reqID, bytesRead, 0, errFwd)
-			exceptionResp := modbus.CreateExceptionResponse(reqFrame, 0x0B)
-			if _, writeErr := clientConn.Write(exceptionResp); writeErr != nil {
-				p.log.Error(p.ID, fmt.Sprintf("Write exception response error: %v", writeErr))
-				return
-			}
-			continue
-		}
-		p.Stats.Requests.Add(1)
-		p.circuitBreaker.RecordSuccess()
-		if p.cache != nil {
-			if cacheable {
-				// Never cache an exception: it describes a moment, not a value.
-				if !modbus.IsExceptionResponse(respFrame) {
-					p.cache.SetForUnit(cacheKey, cacheUnit, respFrame)
-				}
-			} else if unitID, fc, ok := modbus.FrameUnitAndFunction(reqFrame); ok && modbus.IsWriteFunction(fc) {
-				// A write may have changed any register of that unit, and the
-				// frame does not say which cached reads it touches.
-				p.cache.InvalidateUnit(unitID)
-			}
+			respFrame = modbus.CreateExceptionResponse(reqFrame, exception)
+		} else {
+			respFrame = p.limitedDispatch(client, limits, fwdFrame)
+			p.Rewrite.restoreResponse(reqFrame, respFrame)
 		}
-		bytesWritten = len(respFrame)
-		p.enhancedStats.RecordRequestComplete(reqID, bytesRead, bytesWritten, nil)
-		if p.adaptiveTimeout != nil {
-			p.adaptiveTimeout.Record(time.Since(forwardStart))
+
+		if modbus.IsExceptionResponse(respFrame) && len(respFrame) > 8 {
+			p.enhancedStats.RecordException(respFrame[8])
 		}
+		session.record(reqFrame, respFrame)
 
 		// Debug: Log Modbus response (guarded — see request-side comment).
 		if p.log.IsDebugEnabled() {
 			p.log.Debug(p.ID, fmt.Sprintf("Sending Modbus response: %X (%d bytes)", respFrame, len(respFrame)))
 		}
 
+		if c := p.capture.Load(); c != nil {
+			c.Record(capture.Frame{Time: time.Now(), Side: capture.SideClient, Response: true, Src: localAddr, Dst: remoteAddr, Data: respFrame, Latency: time.Since(received)})
+		}
 		if _, err := clientConn.Write(respFrame); err != nil {
 			p.log.Error(p.ID, fmt.Sprintf("Write response error: %v", err))
 			return
@@ -672,14 +847,102 @@ func (p *ProxyInstance) handleClient(clientConn net.Conn, sem chan struct{}) {
 	}
 }
 
+// serveFrame answers one client request on this proxy's own target: from the
+// cache, with a gateway exception when the circuit breaker is open or the
+// target fails, or with the target's response. ok is false when the proxy
+// could not get an answer from the target; the frame is the exception to send
+// instead. client is the address of the client that asked, if any; the
+// ip_hash policy picks an endpoint by it.
+func (p *ProxyInstance) serveFrame(client string, reqFrame []byte) (respFrame []byte, ok bool) {
+	// Serve reads from the cache when one is enabled. This runs before the
+	// circuit breaker on purpose: when the target is unreachable, recent
+	// data is more useful to the client than an exception, and the TTL
+	// bounds how long that can go on.
+	cacheKey, cacheUnit, cacheable := modbus.RequestCacheKey(reqFrame)
+	if cacheable {
+		// Remember it so calibration can probe with a register the client
+		// already asks for, instead of touching something new.
+		p.recordObservedRead(reqFrame)
+	}
+	if p.cache != nil && cacheable {
+		if p.poller != nil {
+			p.poller.Track(cacheKey, cacheUnit, reqFrame)
+		}
+		if cached, hit := p.cache.Get(cacheKey); hit {
+			clientTxID, _ := modbus.FrameTxID(reqFrame)
+			modbus.SetFrameTxID(cached, clientTxID)
+			p.Stats.Requests.Add(1)
+			return cached, true
+		}
+	}
+
+	// Check circuit breaker BEFORE forwarding. With endpoints, each has a
+	// breaker of its own instead: one for all of them would shut out the
+	// standby together with the primary.
+	balanced := p.balancer != nil
+	if !balanced && !p.circuitBreaker.AllowRequest() {
+		p.log.Error(p.ID, "Circuit breaker is OPEN, rejecting request")
+		p.Stats.Errors.Add(1)
+		// Modbus exception: Gateway Target Device Failed to Respond
+		return modbus.CreateExceptionResponse(reqFrame, 0x0B), false
+	}
+
+	// Generate unique request ID and track start time
+	reqID := p.getNextRequestID()
+	p.enhancedStats.RecordRequestStart(reqID)
+
+	forwardStart := time.Now()
+
+	// Route to the appropriate forwarding function based on protocol.
+	respFrame, errFwd := p.forwardClientRequest(client, reqFrame)
+
+	// Record completion
+	bytesRead := len(reqFrame)
+	if errFwd != nil {
+		p.log.Error(p.ID, fmt.Sprintf("Forward error: %v", errFwd))
+		p.Stats.Errors.Add(1)
+		if !balanced {
+			p.circuitBreaker.RecordFailure()
+		}
+		p.enhancedStats.RecordRequestComplete(reqID, bytesRead, 0, errFwd)
+		return modbus.CreateExceptionResponse(reqFrame, 0x0B), false
+	}
+	p.Stats.Requests.Add(1)
+	if !balanced {
+		p.circuitBreaker.RecordSuccess()
+	}
+	if p.cache != nil {
+		if cacheable {
+			// Never cache an exception: it describes a moment, not a value.
+			if !modbus.IsExceptionResponse(respFrame) {
+				p.cache.SetForUnit(cacheKey, cacheUnit, respFrame)
+			}
+		} else if unitID, fc, ok := modbus.FrameUnitAndFunction(reqFrame); ok && modbus.IsWriteFunction(fc) {
+			// A write may have changed any register of that unit, and the
+			// frame does not say which cached reads it touches.
+			p.cache.InvalidateUnit(unitID)
+		}
+	}
+	p.enhancedStats.RecordRequestComplete(reqID, bytesRead, len(respFrame), nil)
+	if p.adaptiveTimeout != nil {
+		p.adaptiveTimeout.Record(time.Since(forwardStart))
+	}
+	return respFrame, true
+}
+
 // forwardClientRequest routes a client request to the right forwarding path.
 // The background poller uses it too, so a refreshed register goes over exactly
 // the same wire path — pacing, retries and split reads included — as a live
-// client read.
-func (p *ProxyInstance) forwardClientRequest(reqFrame []byte) ([]byte, error) {
+// client read. client is as for serveFrame.
+func (p *ProxyInstance) forwardClientRequest(client string, reqFrame []byte) ([]byte, error) {
 	switch {
+	case p.balancer != nil:
+		// Every endpoint speaks the protocol and splits reads itself.
+		return p.forwardEndpoints(client, reqFrame)
 	case p.Protocol == "rtu-tcp":
 		return p.forwardRequestRTU(reqFrame)
+	case p.Protocol == ProtocolSerial:
+		return p.forwardRequestSerial(reqFrame)
 	case p.MaxReadSize > 0 && modbus.IsReadRequest(reqFrame):
 		return p.handleSplitRead(reqFrame)
 	default:
@@ -869,8 +1132,10 @@ func (p *ProxyInstance) forwardAttempt(req []byte, clientTxID uint16, remaining
 		rawConn.Close()
 		return nil, err
 	}
+	sent := time.Now()
 
-	resp, err := p.readMatchingResponse(rawConn, out, txID, time.Now().Add(readTimeout))
+	resp, err := p.readMatchingResponse(rawConn, out, txID, sent.Add(readTimeout))
+	p.captureTarget(rawConn, false, out, sent, resp, err)
 	if err != nil {
 		// A response may still be in flight; it would land in front of the next
 		// request on this connection, so the connection must not be reused.
@@ -967,13 +1232,15 @@ func (p *ProxyInstance) forwardAttemptRTU(rtuReq []byte, unitID, fc byte, txID u
 		rawConn.Close()
 		return nil, err
 	}
-	if err := rawConn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+	sent := time.Now()
+	if err := rawConn.SetReadDeadline(sent.Add(readTimeout)); err != nil {
 		markBroken()
 		rawConn.Close()
 		return nil, err
 	}
 
 	rtuResp, err := modbus.ReadRTUFrame(rawConn, fc)
+	p.captureTarget(rawConn, true, rtuReq, sent, rtuResp, err)
 	if err != nil {
 		markBroken()
 		rawConn.Close()
@@ -0,0 +1,367 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"modbridge/pkg/modbus"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimit keeps one client from taking a device away from the others. Most
+// devices serve one request at a time, some only over a single connection; a
+// script that polls in a tight loop then starves every other client. Two
+// things keep that in check:
+//
+//   - Token buckets per client address and for the proxy as a whole. A request
+//     over the limit is answered at once with SERVER DEVICE BUSY (0x06),
+//     which well-behaved masters take as "try again later".
+//   - A fair queue in front of the target. When all connections to the device
+//     are busy, waiting requests are served by priority class and, within a
+//     class, in turn by client, so a client with many requests in flight
+//     waits behind one that has few.
+//
+// The proxy's own requests — points, the MQTT publisher and its /set writes —
+// count against the proxy's limit and take their turn in the queue like a client of
+// InternalPriority. They have no client limit of their own: their pace is set
+// where they are configured.
+type RateLimit struct {
+	ClientRate       float64         // Requests per second per client address (0 = unlimited)
+	ClientBurst      int             // Requests a client may send at once (0 = one second's worth, at least 1)
+	ProxyRate        float64         // Requests per second of all clients together (0 = unlimited)
+	ProxyBurst       int             // As ClientBurst, for the proxy
+	InternalPriority RequestPriority // Queue class of the proxy's own requests
+	Classes          []ClientClass
+}
+
+// internalClient is the client key of the proxy's own requests. No client
+// address is empty, so they share a turn in the queue of their own, and
+// ip_hash spreads them round robin as before.
+const internalClient = ""
+
+// ClientClass gives the clients from some networks a priority and limits of
+// their own. The first class that matches a client applies.
+type ClientClass struct {
+	Sources     []*net.IPNet
+	Priority    RequestPriority
+	ClientRate  *float64 // Replaces RateLimit.ClientRate for these clients (nil = keep it)
+	ClientBurst int      // Replaces RateLimit.ClientBurst (0 = keep it)
+}
+
+// clientLimits is what applies to one client.
+type clientLimits struct {
+	priority RequestPriority
+	rate     float64
+	burst    int
+}
+
+// limitsFor returns the class and limits of a client address.
+func (rl *RateLimit) limitsFor(ip net.IP) clientLimits {
+	if rl == nil {
+		return clientLimits{priority: PriorityNormal}
+	}
+	limits := clientLimits{priority: PriorityNormal, rate: rl.ClientRate, burst: rl.ClientBurst}
+	if ip == nil {
+		return limits
+	}
+	for _, c := range rl.Classes {
+		for _, network := range c.Sources {
+			if !network.Contains(ip) {
+				continue
+			}
+			limits.priority = c.Priority
+			if c.ClientRate != nil {
+				limits.rate = *c.ClientRate
+			}
+			if c.ClientBurst > 0 {
+				limits.burst = c.ClientBurst
+			}
+			return limits
+		}
+	}
+	return limits
+}
+
+// internalLimits returns what applies to the proxy's own requests.
+func (rl *RateLimit) internalLimits() clientLimits {
+	if rl == nil {
+		return clientLimits{priority: PriorityNormal}
+	}
+	return clientLimits{priority: rl.InternalPriority}
+}
+
+// tokenBucket allows rate requests per second with bursts of burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	b := float64(burst)
+	if b <= 0 {
+		b = max(rate, 1)
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+// allow takes a token if one is there.
+func (tb *tokenBucket) allow(now time.Time) bool {
+	tb.tokens = min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// refund gives back a token taken for a request that did not go ahead.
+func (tb *tokenBucket) refund() {
+	tb.tokens = min(tb.burst, tb.tokens+1)
+}
+
+// idleBucketAge is how long a client's bucket is kept after its last request.
+// A bucket that has been idle that long is full again and can be made anew.
+const idleBucketAge = 10 * time.Minute
+
+// rateLimiter holds the buckets of a proxy.
+type rateLimiter struct {
+	mu      sync.Mutex
+	proxy   *tokenBucket // nil = no proxy limit
+	clients map[string]*tokenBucket
+	swept   time.Time
+}
+
+func newRateLimiter(rl *RateLimit) *rateLimiter {
+	l := &rateLimiter{clients: make(map[string]*tokenBucket), swept: time.Now()}
+	if rl.ProxyRate > 0 {
+		l.proxy = newTokenBucket(rl.ProxyRate, rl.ProxyBurst, time.Now())
+	}
+	return l
+}
+
+// allow reports whether a request of client may go ahead under its limits
+// and the proxy's. A request the proxy's limit holds back costs the client
+// nothing, so a client within its own limit does not use it up on requests
+// that never reach the device.
+func (l *rateLimiter) allow(client string, limits clientLimits) bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) > idleBucketAge {
+		for key, b := range l.clients {
+			if now.Sub(b.last) > idleBucketAge {
+				delete(l.clients, key)
+			}
+		}
+		l.swept = now
+	}
+
+	var b *tokenBucket
+	if limits.rate > 0 {
+		var ok bool
+		if b, ok = l.clients[client]; !ok {
+			b = newTokenBucket(limits.rate, limits.burst, now)
+			l.clients[client] = b
+		}
+		if !b.allow(now) {
+			return false
+		}
+	}
+	if l.proxy != nil && !l.proxy.allow(now) {
+		if b != nil {
+			b.refund()
+		}
+		return false
+	}
+	return true
+}
+
+// fairQueue hands out the slots towards the target. A request takes a slot
+// at once while one is free; otherwise it waits, and a freed slot goes to the
+// highest priority class with waiters and, within it, to the next client in
+// turn.
+type fairQueue struct {
+	mu      sync.Mutex
+	slots   int
+	busy    int
+	waiting int
+	classes [PriorityCritical + 1]fairClass
+}
+
+// fairClass holds the waiters of one priority class, by client, and the order
+// in which the clients take turns.
+type fairClass struct {
+	turn    []string
+	waiters map[string][]chan struct{}
+}
+
+func newFairQueue(slots int) *fairQueue {
+	q := &fairQueue{slots: max(slots, 1)}
+	for i := range q.classes {
+		q.classes[i].waiters = make(map[string][]chan struct{})
+	}
+	return q
+}
+
+// acquire waits for a slot. The returned function gives it back and must be
+// called once the request is done; on error, no slot is held.
+func (q *fairQueue) acquire(ctx context.Context, client string, priority RequestPriority) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	priority = min(max(priority, PriorityLow), PriorityCritical)
+
+	q.mu.Lock()
+	if q.busy < q.slots && q.waiting == 0 {
+		q.busy++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	granted := make(chan struct{})
+	c := &q.classes[priority]
+	if len(c.waiters[client]) == 0 {
+		c.turn = append(c.turn, client)
+	}
+	c.waiters[client] = append(c.waiters[client], granted)
+	q.waiting++
+	q.mu.Unlock()
+
+	select {
+	case <-granted:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case <-granted:
+		// Granted while giving up: pass the slot on.
+		q.releaseLocked()
+	default:
+		q.remove(c, client, granted)
+	}
+	return nil, ctx.Err()
+}
+
+// release gives a slot back, handing it to the next waiter if there is one.
+func (q *fairQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+func (q *fairQueue) releaseLocked() {
+	for p := len(q.classes) - 1; p >= 0; p-- {
+		c := &q.classes[p]
+		if len(c.turn) == 0 {
+			continue
+		}
+		client := c.turn[0]
+		c.turn = c.turn[1:]
+		next := c.waiters[client][0]
+		if rest := c.waiters[client][1:]; len(rest) > 0 {
+			c.waiters[client] = rest
+			c.turn = append(c.turn, client)
+		} else {
+			delete(c.waiters, client)
+		}
+		q.waiting--
+		close(next) // The slot passes on; busy stays as it is
+		return
+	}
+	q.busy--
+}
+
+// remove takes a waiter that gave up out of its class.
+func (q *fairQueue) remove(c *fairClass, client string, waiter chan struct{}) {
+	list := c.waiters[client]
+	for i, w := range list {
+		if w != waiter {
+			continue
+		}
+		list = append(list[:i], list[i+1:]...)
+		q.waiting--
+		break
+	}
+	if len(list) > 0 {
+		c.waiters[client] = list
+		return
+	}
+	delete(c.waiters, client)
+	for i, name := range c.turn {
+		if name == client {
+			c.turn = append(c.turn[:i], c.turn[i+1:]...)
+			break
+		}
+	}
+}
+
+// Waiting returns how many requests wait for a slot.
+func (q *fairQueue) Waiting() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiting
+}
+
+// limitedDispatch forwards a request under the rate limits. A request over a
+// limit, or one that has not had its turn at the target within the request
+// budget, is answered SERVER DEVICE BUSY. Identical reads are coalesced after
+// the client's own limit has been checked but before the queue, so a read
+// that can share a flight does not hold a slot while it waits.
+func (p *ProxyInstance) limitedDispatch(client string, limits clientLimits, reqFrame []byte) []byte {
+	if p.limiter == nil {
+		return p.coalesce(reqFrame, forwardWith(func() []byte { return p.dispatch(client, reqFrame) }))
+	}
+	if !p.limiter.allow(client, limits) {
+		return p.busy(client, reqFrame, "over the rate limit")
+	}
+	return p.coalesce(reqFrame, func() ([]byte, bool) { return p.queuedDispatch(client, limits, reqFrame) })
+}
+
+// queuedDispatch forwards a request once it has had its turn at the target.
+// local is true for the busy answer to a request that had no turn.
+func (p *ProxyInstance) queuedDispatch(client string, limits clientLimits, reqFrame []byte) (resp []byte, local bool) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.requestBudget())
+	release, err := p.queue.acquire(ctx, client, limits.priority)
+	cancel()
+	if err != nil {
+		return p.busy(client, reqFrame, "no turn at the target within the request budget"), true
+	}
+	defer release()
+	return p.dispatch(client, reqFrame), false
+}
+
+// busy answers a request the limits hold back. It is logged at debug level
+// only: a client over its limit sends many of them.
+func (p *ProxyInstance) busy(client string, reqFrame []byte, reason string) []byte {
+	p.Stats.Limited.Add(1)
+	if p.log.IsDebugEnabled() {
+		if client == internalClient {
+			client = "an internal request"
+		}
+		p.log.Debug(p.ID, fmt.Sprintf("Busy answer to %s: %s", client, reason))
+	}
+	return modbus.CreateExceptionResponse(reqFrame, modbus.ExceptionServerDeviceBusy)
+}
+
+// QueuedRequests returns how many client requests wait for the target.
+func (p *ProxyInstance) QueuedRequests() int {
+	return p.queue.Waiting()
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"context"
+	"modbridge/pkg/modbus"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRateLimitAnswersBusy verifies that a client over its rate gets SERVER
+// DEVICE BUSY and that the request does not reach the target.
+func TestRateLimitAnswersBusy(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.RateLimit = &RateLimit{ClientRate: 0.1, ClientBurst: 2}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 2; i++ {
+		if resp := exchange(t, conn, modbus.CreateReadRequest(uint16(i), 1, 3, 100, 1)); modbus.IsExceptionResponse(resp) {
+			t.Fatalf("read %d within the burst answered % X", i, resp)
+		}
+	}
+	resp := exchange(t, conn, modbus.CreateReadRequest(3, 1, 3, 100, 1))
+	if !modbus.IsExceptionResponse(resp) || resp[8] != modbus.ExceptionServerDeviceBusy {
+		t.Errorf("read over the limit answered % X, want exception 0x06", resp)
+	}
+	if got := atomic.LoadInt64(&reads); got != 2 {
+		t.Errorf("target saw %d reads, want 2", got)
+	}
+	if got := p.Stats.Limited.Load(); got != 1 {
+		t.Errorf("Limited = %d, want 1", got)
+	}
+}
+
+// TestRateLimitProxyLimitCostsClientNothing verifies that a request held back
+// by the proxy's limit leaves the client's own tokens alone.
+func TestRateLimitProxyLimitCostsClientNothing(t *testing.T) {
+	l := newRateLimiter(&RateLimit{ProxyRate: 0.001, ProxyBurst: 1})
+	limits := clientLimits{priority: PriorityNormal, rate: 0.001, burst: 2}
+
+	if !l.allow("a", limits) {
+		t.Fatal("the first request was refused")
+	}
+	for i := 0; i < 5; i++ {
+		if l.allow("a", limits) {
+			t.Fatalf("request %d went past the spent proxy limit", i)
+		}
+	}
+
+	// The proxy has room again; the client still has its second token.
+	l.proxy = newTokenBucket(0.001, 10, time.Now())
+	if !l.allow("a", limits) {
+		t.Error("the client's second token was spent on requests the proxy refused")
+	}
+	if l.allow("a", limits) {
+		t.Error("the client went past its own burst")
+	}
+}
+
+// TestRateLimitCountsInternalReads verifies that the proxy's own reads use
+// up the proxy's limit like a client's, and are held back by it in turn.
+func TestRateLimitCountsInternalReads(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.RateLimit = &RateLimit{ClientRate: 0.001, ClientBurst: 5, ProxyRate: 0.001, ProxyBurst: 1}
+	})
+	defer p.Stop()
+
+	if _, err := p.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 100, 2); err != nil {
+		t.Fatalf("the first internal read failed: %v", err)
+	}
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	resp := exchange(t, conn, modbus.CreateReadRequest(1, 1, 3, 200, 1))
+	if !modbus.IsExceptionResponse(resp) || resp[8] != modbus.ExceptionServerDeviceBusy {
+		t.Errorf("client read after the internal one answered % X, want exception 0x06", resp)
+	}
+	if _, err := p.ReadRegisters(1, modbus.FuncReadHoldingRegisters, 300, 2); err == nil {
+		t.Error("an internal read went past the spent proxy limit")
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1", got)
+	}
+	if got := p.Stats.Limited.Load(); got != 2 {
+		t.Errorf("Limited = %d, want 2", got)
+	}
+}
+
+// TestRateLimitQueuesInternalWrites verifies that a write of the proxy's own
+// waits its turn at the target instead of going past the queue.
+func TestRateLimitQueuesInternalWrites(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.MaxTargetConns = 1
+		p.RateLimit = &RateLimit{}
+	})
+	defer p.Stop()
+
+	release, err := p.queue.acquire(context.Background(), "holder", PriorityCritical)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	written := make(chan error, 1)
+	go func() { written <- p.WriteRegisters(1, 10, []uint16{42}) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.QueuedRequests() == 0 {
+		select {
+		case err := <-written:
+			t.Fatalf("the write went past the queue (err %v)", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the write never started waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Errorf("WriteRegisters after its turn: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write was not served once the slot was free")
+	}
+}
+
+// TestFairQueueOrder verifies that a freed slot goes to the highest priority
+// class first and, within a class, to the clients in turn.
+func TestFairQueueOrder(t *testing.T) {
+	q := newFairQueue(1)
+	release, err := q.acquire(context.Background(), "holder", PriorityNormal)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	served := make(chan string, 4)
+	queue := func(client string, priority RequestPriority) {
+		want := q.Waiting() + 1
+		go func() {
+			done, err := q.acquire(context.Background(), client, priority)
+			if err != nil {
+				t.Errorf("acquire for %s failed: %v", client, err)
+				return
+			}
+			served <- client
+			done()
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for q.Waiting() < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never started waiting", client)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// A sends twice before B once; C is of a higher class and came last.
+	queue("A", PriorityNormal)
+	queue("A", PriorityNormal)
+	queue("B", PriorityNormal)
+	queue("C", PriorityHigh)
+
+	release()
+	var order []string
+	for i := 0; i < 4; i++ {
+		select {
+		case client := <-served:
+			order = append(order, client)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %v were served", order)
+		}
+	}
+	if got := strings.Join(order, ","); got != "C,A,B,A" {
+		t.Errorf("served in order %s, want C,A,B,A", got)
+	}
+}
+
+// TestFairQueueGiveUp verifies that a request that stops waiting leaves the
+// queue, and the slot, as they were.
+func TestFairQueueGiveUp(t *testing.T) {
+	q := newFairQueue(1)
+	release, err := q.acquire(context.Background(), "holder", PriorityNormal)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.acquire(ctx, "late", PriorityNormal); err == nil {
+		t.Fatal("acquire succeeded while the only slot was held")
+	}
+	if n := q.Waiting(); n != 0 {
+		t.Errorf("Waiting = %d after giving up, want 0", n)
+	}
+
+	release()
+	done, err := q.acquire(context.Background(), "next", PriorityNormal)
+	if err != nil {
+		t.Fatalf("slot not free after release: %v", err)
+	}
+	done()
+}
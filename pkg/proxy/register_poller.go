@@ -38,6 +38,10 @@ type RegisterPoller struct {
 	store   func(key uint64, unitID uint8, resp []byte)
 	logf    func(msg string)
 
+	// afterRound is called when a refresh round has finished, so whoever
+	// reads the cache knows it holds fresh values.
+	afterRound func()
+
 	// Merge adjacent ranges into one read when they are no further apart than
 	// this. Zero disables batching and every request is refreshed on its own.
 	maxAddressGap uint16
@@ -164,6 +168,9 @@ func (rp *RegisterPoller) loop(ctx context.Context) {
 		started := time.Now()
 		rp.refreshAll(ctx)
 		rp.reportRoundDuration(time.Since(started))
+		if rp.afterRound != nil && ctx.Err() == nil {
+			rp.afterRound()
+		}
 	}
 }
 
@@ -0,0 +1,104 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"modbridge/pkg/modbus"
+)
+
+// ErrDenied is returned by WriteRegisters when the access policy refuses the
+// write.
+var ErrDenied = errors.New("refused by the access policy")
+
+// ReadRegisters reads holding (0x03) or input (0x04) registers for the proxy
+// itself rather than for a client. The request takes the path a client's
+// would — rewrite, rate limits and queue, coalescing, routes, cache, circuit
+// breaker — so a register a client or the poller keeps warm is answered from
+// the cache without touching the device. The access policy is not applied to
+// reads: the points and the poller read what they were configured to, and
+// the API has its own permissions.
+func (p *ProxyInstance) ReadRegisters(unitID, fc uint8, addr, count uint16) ([]uint16, error) {
+	if fc != modbus.FuncReadHoldingRegisters && fc != modbus.FuncReadInputRegisters {
+		return nil, fmt.Errorf("function 0x%02X does not read registers", fc)
+	}
+	if count == 0 || count > 125 {
+		return nil, fmt.Errorf("cannot read %d registers at once", count)
+	}
+	if p.Stats.GetStatus() != "Running" {
+		return nil, errors.New("proxy is not running")
+	}
+	if p.calibrating.Load() {
+		return nil, errors.New("proxy is measuring its target")
+	}
+
+	reqFrame := modbus.CreateReadRequest(uint16(p.getNextRequestID()), unitID, fc, addr, count)
+	fwdFrame, exception := p.Rewrite.rewriteRequest(reqFrame)
+	if exception != 0 {
+		return nil, fmt.Errorf("modbus exception 0x%02X", exception)
+	}
+	respFrame := p.limitedDispatch(internalClient, p.RateLimit.internalLimits(), fwdFrame)
+	p.Rewrite.restoreResponse(reqFrame, respFrame)
+
+	if modbus.IsExceptionResponse(respFrame) {
+		return nil, fmt.Errorf("modbus exception 0x%02X", respFrame[8])
+	}
+	data, err := modbus.ParseReadResponse(respFrame)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 2*int(count) {
+		return nil, fmt.Errorf("expected %d registers, got %d bytes", count, len(data))
+	}
+	regs := make([]uint16, count)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return regs, nil
+}
+
+// WriteRegisters writes holding registers for the proxy itself, with Write
+// Single Register (0x06) for one value and Write Multiple Registers (0x10)
+// for more. Like ReadRegisters it goes the way of a client's request, so the
+// write counts against the rate limits, waits its turn in the queue behind
+// the clients already waiting, is paced, matched to its response and clears
+// the cached reads of its unit like any other. Unlike a read, a write is held to the access policy's
+// rule for every client: a register the firewall keeps clients from writing
+// is not written from MQTT either. A refused write returns ErrDenied.
+func (p *ProxyInstance) WriteRegisters(unitID uint8, addr uint16, values []uint16) error {
+	if p.Stats.GetStatus() != "Running" {
+		return errors.New("proxy is not running")
+	}
+	if p.calibrating.Load() {
+		return errors.New("proxy is measuring its target")
+	}
+
+	reqFrame, err := modbus.CreateWriteRequest(uint16(p.getNextRequestID()), unitID, addr, values)
+	if err != nil {
+		return err
+	}
+	if exception, reason := p.Policy.ruleFor(nil).check(reqFrame); exception != 0 {
+		p.Stats.Denied.Add(1)
+		p.log.Warn(p.ID, fmt.Sprintf("Denied point write: %s", reason))
+		return fmt.Errorf("%w: %s", ErrDenied, reason)
+	}
+	fwdFrame, exception := p.Rewrite.rewriteRequest(reqFrame)
+	if exception != 0 {
+		return fmt.Errorf("modbus exception 0x%02X", exception)
+	}
+	respFrame := p.limitedDispatch(internalClient, p.RateLimit.internalLimits(), fwdFrame)
+	p.Rewrite.restoreResponse(reqFrame, respFrame)
+
+	if modbus.IsExceptionResponse(respFrame) {
+		return fmt.Errorf("modbus exception 0x%02X", respFrame[8])
+	}
+	if !modbus.ResponseMatchesRequest(reqFrame, respFrame) {
+		return errors.New("response does not match the write")
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"modbridge/pkg/modbus"
+)
+
+// RewriteRules translate between the numbering a client uses and the one the
+// device behind the proxy understands: SCADA systems hard-coded to unit 1 and
+// a fixed register map in front of a device on unit 126 with a shifted base.
+//
+// Rules are written in the client's terms. The request is rewritten before it
+// goes anywhere — routes, cache and target all see the device's numbering, so
+// two clients asking the same register under different numbers share one
+// cache entry. The answer is translated back before the client gets it.
+type RewriteRules struct {
+	// Units maps a client unit ID to the device's.
+	Units map[uint8]uint8
+	// Registers move address ranges. The first rule whose range touches a
+	// request decides it.
+	Registers []RegisterRewrite
+}
+
+// RegisterRewrite moves the client addresses Start..Start+Count-1 by Offset.
+type RegisterRewrite struct {
+	Functions []uint8 // Function codes the rule applies to (empty = all)
+	Units     []uint8 // Client unit IDs the rule applies to (empty = all)
+	Start     uint16
+	Count     int
+	Offset    int
+}
+
+// applies reports whether the rule covers a function code of a client unit.
+func (r *RegisterRewrite) applies(unitID, fc uint8) bool {
+	return containsByte(r.Functions, fc) && containsByte(r.Units, unitID)
+}
+
+// containsByte reports whether set holds b; an empty set holds everything.
+func containsByte(set []uint8, b uint8) bool {
+	if len(set) == 0 {
+		return true
+	}
+	for _, s := range set {
+		if s == b {
+			return true
+		}
+	}
+	return false
+}
+
+// addressField is where a request carries a start address and how many
+// registers or coils follow it.
+type addressField struct {
+	offset   int // Offset of the address in the TCP frame
+	quantity int // Offset of the quantity, or -1 for a single address
+}
+
+// addressFields lists the address fields of a request by function code.
+// Read/write multiple carries two: the read range and the write range.
+func addressFields(fc uint8) []addressField {
+	switch fc {
+	case modbus.FuncReadCoils, modbus.FuncReadDiscreteInputs,
+		modbus.FuncReadHoldingRegisters, modbus.FuncReadInputRegisters,
+		modbus.FuncWriteMultipleCoils, modbus.FuncWriteMultipleRegisters:
+		return []addressField{{offset: 8, quantity: 10}}
+	case modbus.FuncWriteSingleCoil, modbus.FuncWriteSingleRegister, modbus.FuncMaskWriteRegister:
+		return []addressField{{offset: 8, quantity: -1}}
+	case modbus.FuncReadWriteRegisters:
+		return []addressField{{offset: 8, quantity: 10}, {offset: 12, quantity: 14}}
+	}
+	return nil
+}
+
+// echoesAddress reports whether the normal response to fc repeats the
+// request's start address, which then has to be translated back too.
+func echoesAddress(fc uint8) bool {
+	switch fc {
+	case modbus.FuncWriteSingleCoil, modbus.FuncWriteSingleRegister,
+		modbus.FuncWriteMultipleCoils, modbus.FuncWriteMultipleRegisters,
+		modbus.FuncMaskWriteRegister:
+		return true
+	}
+	return false
+}
+
+// rewriteRequest returns the frame to forward for a client request: the
+// request itself when no rule applies, a rewritten copy otherwise. A non-zero
+// exception code means the request must be refused with it instead.
+func (r *RewriteRules) rewriteRequest(reqFrame []byte) ([]byte, uint8) {
+	if r == nil {
+		return reqFrame, 0
+	}
+	unitID, fc, ok := modbus.FrameUnitAndFunction(reqFrame)
+	if !ok {
+		return reqFrame, 0
+	}
+
+	var fwd []byte
+	modify := func() []byte {
+		if fwd == nil {
+			fwd = make([]byte, len(reqFrame))
+			copy(fwd, reqFrame)
+		}
+		return fwd
+	}
+
+	if to, ok := r.Units[unitID]; ok && to != unitID {
+		modify()[6] = to
+	}
+
+	for _, f := range addressFields(fc) {
+		quantity := 1
+		if f.quantity >= 0 {
+			if len(reqFrame) < f.quantity+2 {
+				return reqFrame, 0
+			}
+			quantity = int(binary.BigEndian.Uint16(reqFrame[f.quantity:]))
+		} else if len(reqFrame) < f.offset+2 {
+			return reqFrame, 0
+		}
+
+		addr := binary.BigEndian.Uint16(reqFrame[f.offset:])
+		mapped, exception := r.mapRange(unitID, fc, addr, quantity)
+		if exception != 0 {
+			return reqFrame, exception
+		}
+		if mapped != addr {
+			binary.BigEndian.PutUint16(modify()[f.offset:], mapped)
+		}
+	}
+
+	if fwd == nil {
+		return reqFrame, 0
+	}
+	return fwd, 0
+}
+
+// mapRange translates the client range addr..addr+quantity-1.
+func (r *RewriteRules) mapRange(unitID, fc uint8, addr uint16, quantity int) (uint16, uint8) {
+	first, end := int(addr), int(addr)+quantity
+	for i := range r.Registers {
+		rule := &r.Registers[i]
+		if !rule.applies(unitID, fc) {
+			continue
+		}
+		ruleFirst, ruleEnd := int(rule.Start), int(rule.Start)+rule.Count
+		if first >= ruleFirst && end <= ruleEnd {
+			return uint16(first + rule.Offset), 0
+		}
+		if first < ruleEnd && end > ruleFirst {
+			// Half the request would land elsewhere on the device than
+			// the client thinks: refuse it rather than guess.
+			return addr, modbus.ExceptionIllegalDataAddress
+		}
+	}
+	return addr, 0
+}
+
+// restoreResponse translates a response back to the numbering of the client
+// request it answers. It works in place.
+func (r *RewriteRules) restoreResponse(reqFrame, respFrame []byte) {
+	if r == nil || len(reqFrame) < 8 || len(respFrame) < 8 {
+		return
+	}
+	respFrame[6] = reqFrame[6]
+	if echoesAddress(reqFrame[7]) && !modbus.IsExceptionResponse(respFrame) &&
+		len(reqFrame) >= 10 && len(respFrame) >= 10 {
+		copy(respFrame[8:10], reqFrame[8:10])
+	}
+}
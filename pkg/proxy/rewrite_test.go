@@ -0,0 +1,147 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"modbridge/pkg/modbus"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRewriteRequest checks the address translation of single requests.
+func TestRewriteRequest(t *testing.T) {
+	rules := &RewriteRules{
+		Units: map[uint8]uint8{1: 126},
+		Registers: []RegisterRewrite{
+			{Functions: []uint8{modbus.FuncReadHoldingRegisters, modbus.FuncWriteSingleRegister, modbus.FuncReadWriteRegisters}, Start: 0, Count: 100, Offset: 40000},
+		},
+	}
+
+	readWrite := func(readAddr, writeAddr uint16) []byte {
+		frame := make([]byte, 19)
+		binary.BigEndian.PutUint16(frame[4:6], 13)
+		frame[6], frame[7] = 1, modbus.FuncReadWriteRegisters
+		binary.BigEndian.PutUint16(frame[8:10], readAddr)
+		binary.BigEndian.PutUint16(frame[10:12], 2)
+		binary.BigEndian.PutUint16(frame[12:14], writeAddr)
+		binary.BigEndian.PutUint16(frame[14:16], 1)
+		frame[16] = 2
+		return frame
+	}
+
+	tests := []struct {
+		name      string
+		req       []byte
+		unit      uint8
+		addrs     []uint16 // address fields after the rewrite, 4 bytes apart
+		exception uint8
+	}{
+		{"inside the range", modbus.CreateReadRequest(1, 1, 3, 10, 4), 126, []uint16{40010}, 0},
+		{"last registers of the range", modbus.CreateReadRequest(1, 1, 3, 96, 4), 126, []uint16{40096}, 0},
+		{"outside the range", modbus.CreateReadRequest(1, 1, 3, 200, 4), 126, []uint16{200}, 0},
+		{"straddles the edge", modbus.CreateReadRequest(1, 1, 3, 98, 4), 0, nil, modbus.ExceptionIllegalDataAddress},
+		{"other function code", modbus.CreateReadRequest(1, 1, 4, 10, 4), 126, []uint16{10}, 0},
+		{"other unit", modbus.CreateReadRequest(1, 2, 3, 10, 4), 2, []uint16{40010}, 0},
+		{"read/write multiple", readWrite(5, 50), 126, []uint16{40005, 40050}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := append([]byte(nil), tt.req...)
+			fwd, exception := rules.rewriteRequest(tt.req)
+			if exception != tt.exception {
+				t.Fatalf("exception = 0x%02X, want 0x%02X", exception, tt.exception)
+			}
+			if string(tt.req) != string(orig) {
+				t.Error("the client's frame was modified in place")
+			}
+			if exception != 0 {
+				return
+			}
+			if fwd[6] != tt.unit {
+				t.Errorf("unit = %d, want %d", fwd[6], tt.unit)
+			}
+			for i, want := range tt.addrs {
+				if got := binary.BigEndian.Uint16(fwd[8+4*i:]); got != want {
+					t.Errorf("address field %d = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestRewriteThroughProxy verifies the full round trip: the device sees its
+// own unit ID and addresses, the client gets answers in its numbering, and a
+// client using the device's numbering shares the cache entry.
+func TestRewriteThroughProxy(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.CacheEnabled = true
+		p.CacheTTL = 10 * time.Second
+		p.Rewrite = &RewriteRules{
+			Units:     map[uint8]uint8{1: 126},
+			Registers: []RegisterRewrite{{Start: 0, Count: 100, Offset: 40000}},
+		}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	resp := routedRead(t, conn, 1, 1)
+	if resp[6] != 1 {
+		t.Errorf("answer carries unit %d, want the client's unit 1", resp[6])
+	}
+	if data, err := modbus.ParseReadResponse(resp); err != nil || data[0] != 126 {
+		t.Errorf("got % X (err %v), want data from unit 126", resp, err)
+	}
+
+	// Unit 126 at address 0 is the same device register after the rewrite.
+	resp = routedRead(t, conn, 2, 126)
+	if resp[6] != 126 {
+		t.Errorf("answer carries unit %d, want 126", resp[6])
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1 (both requests name the same device register)", got)
+	}
+
+	// A write echoes its address: the client must get its own back.
+	write := []byte{0, 3, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 0, 7, 0, 42}
+	if _, err := conn.Write(write); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	resp, err = modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(resp) != string(write) {
+		t.Errorf("write answered with % X, want the client's own frame % X", resp, write)
+	}
+
+	// A request across the edge of the range is refused, not forwarded.
+	if _, err := conn.Write(modbus.CreateReadRequest(4, 1, 3, 98, 4)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	resp, err = modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if len(resp) != 9 || resp[6] != 1 || resp[8] != modbus.ExceptionIllegalDataAddress {
+		t.Errorf("straddling read: got % X, want exception 0x02 for unit 1", resp)
+	}
+}
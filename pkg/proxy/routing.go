@@ -0,0 +1,202 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"fmt"
+	"modbridge/pkg/modbus"
+)
+
+// Route sends the requests for a range of unit IDs to a target of its own. A
+// proxy with routes is a gateway: one listener in front of many devices, each
+// reached over the path that suits it.
+//
+// Behind a route sits a complete proxy without a listener, so every route has
+// its own pacing, retries, cache, poller and circuit breaker. One slow or dead
+// device trips its own breaker and leaves the other routes alone.
+type Route struct {
+	ID        string
+	FirstUnit uint8 // First client unit ID sent down this route
+	LastUnit  uint8 // Last client unit ID sent down this route (inclusive)
+	// TargetUnit rewrites the unit ID on the way to the target (0 = keep the
+	// client's). With a range, FirstUnit becomes TargetUnit and the rest
+	// follow in order. Answers carry the client's unit ID again.
+	TargetUnit uint8
+
+	target *ProxyInstance
+}
+
+// NewRoute creates a route for the unit IDs first..last over target. The target
+// is configured like any proxy; its listen address is ignored.
+func NewRoute(id string, first, last, targetUnit uint8, target *ProxyInstance) *Route {
+	if last < first {
+		last = first
+	}
+	target.headless = true
+	return &Route{ID: id, FirstUnit: first, LastUnit: last, TargetUnit: targetUnit, target: target}
+}
+
+// Target returns the proxy that serves this route.
+func (r *Route) Target() *ProxyInstance {
+	return r.target
+}
+
+// matches reports whether a client unit ID belongs to this route.
+func (r *Route) matches(unitID uint8) bool {
+	return unitID >= r.FirstUnit && unitID <= r.LastUnit
+}
+
+// targetUnitFor maps a client unit ID to the one the target is asked for.
+func (r *Route) targetUnitFor(unitID uint8) uint8 {
+	if r.TargetUnit == 0 {
+		return unitID
+	}
+	return r.TargetUnit + (unitID - r.FirstUnit)
+}
+
+// serve answers a request of client on this route, translating the unit ID
+// both ways.
+func (r *Route) serve(client string, reqFrame []byte) ([]byte, bool) {
+	unitID := reqFrame[6]
+	targetUnit := r.targetUnitFor(unitID)
+	if targetUnit == unitID {
+		return r.target.serveFrame(client, reqFrame)
+	}
+
+	fwd := make([]byte, len(reqFrame))
+	copy(fwd, reqFrame)
+	fwd[6] = targetUnit
+
+	respFrame, ok := r.target.serveFrame(client, fwd)
+	if len(respFrame) > 6 {
+		respFrame[6] = unitID
+	}
+	return respFrame, ok
+}
+
+// routeFor returns the route for a request frame, or nil if none matches.
+func (p *ProxyInstance) routeFor(reqFrame []byte) *Route {
+	if len(p.Routes) == 0 || len(reqFrame) < 7 {
+		return nil
+	}
+	unitID := reqFrame[6]
+	for _, r := range p.Routes {
+		if r.matches(unitID) {
+			return r
+		}
+	}
+	return nil
+}
+
+// hasOwnTarget reports whether the proxy has a target of its own besides its
+// routes, or endpoints in its place. Without one, unrouted unit IDs have
+// nowhere to go.
+func (p *ProxyInstance) hasOwnTarget() bool {
+	return p.Protocol == ProtocolSerial || p.Protocol == ProtocolVirtual || p.TargetAddr != "" || len(p.Endpoints) > 0
+}
+
+// dispatch answers one client request: over the route for its unit ID, or on
+// the proxy's own target. A unit ID nobody serves gets "gateway path
+// unavailable", which tells the client the address is wrong rather than that
+// a device is down. client is the address of the client that asked, or
+// empty for the proxy's own requests.
+func (p *ProxyInstance) dispatch(client string, reqFrame []byte) []byte {
+	route := p.routeFor(reqFrame)
+	if route == nil && len(p.Routes) > 0 && !p.hasOwnTarget() {
+		p.Stats.Errors.Add(1)
+		return modbus.CreateExceptionResponse(reqFrame, 0x0A)
+	}
+	if route == nil {
+		respFrame, _ := p.serveFrame(client, reqFrame)
+		return respFrame
+	}
+
+	// The proxy's own counters cover everything its clients asked, routed or
+	// not; the route's target keeps the per-route numbers.
+	respFrame, ok := route.serve(client, reqFrame)
+	if ok {
+		p.Stats.Requests.Add(1)
+	} else {
+		p.Stats.Errors.Add(1)
+	}
+	return respFrame
+}
+
+// startRoutes starts the proxies behind every route. If one fails, those
+// already started are stopped again.
+func (p *ProxyInstance) startRoutes() error {
+	for i, r := range p.Routes {
+		// A routed unit is cached by the route's proxy; its refresh rounds
+		// concern the points of this one.
+		r.target.OnRefreshed = p.OnRefreshed
+		if err := r.target.Start(); err != nil {
+			for _, started := range p.Routes[:i] {
+				started.target.Stop()
+			}
+			return fmt.Errorf("route %s: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// stopRoutes stops the proxies behind every route.
+func (p *ProxyInstance) stopRoutes() {
+	for _, r := range p.Routes {
+		r.target.Stop()
+	}
+}
+
+// RouteStats is the per-route view shown next to a proxy.
+type RouteStats struct {
+	ID           string `json:"id"`
+	FirstUnit    uint8  `json:"first_unit"`
+	LastUnit     uint8  `json:"last_unit"`
+	TargetUnit   uint8  `json:"target_unit,omitempty"`
+	Target       string `json:"target"`
+	Protocol     string `json:"protocol"`
+	Status       string `json:"status"`
+	Requests     int64  `json:"requests"`
+	Errors       int64  `json:"errors"`
+	CircuitState string `json:"circuit_state"`
+	CacheHits    int64  `json:"cache_hits"`
+	CacheMisses  int64  `json:"cache_misses"`
+}
+
+// RouteStats returns the counters of every route, in configuration order.
+func (p *ProxyInstance) RouteStats() []RouteStats {
+	out := make([]RouteStats, 0, len(p.Routes))
+	for _, r := range p.Routes {
+		t := r.target
+		target := t.TargetAddr
+		if t.Protocol == ProtocolVirtual {
+			target = "virtual device"
+		}
+		if t.Protocol == ProtocolSerial {
+			switch {
+			case t.BusID != "":
+				target = "bus " + t.BusID
+			case t.Serial != nil:
+				target = t.Serial.Device
+			}
+		}
+		cache := t.CacheStats()
+		out = append(out, RouteStats{
+			ID:           r.ID,
+			FirstUnit:    r.FirstUnit,
+			LastUnit:     r.LastUnit,
+			TargetUnit:   r.TargetUnit,
+			Target:       target,
+			Protocol:     t.Protocol,
+			Status:       t.Stats.GetStatus(),
+			Requests:     t.Stats.Requests.Load(),
+			Errors:       t.Stats.Errors.Load(),
+			CircuitState: t.circuitState(),
+			CacheHits:    cache.Hits,
+			CacheMisses:  cache.Misses,
+		})
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/logger"
+	"modbridge/pkg/modbus"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// routeTarget builds the proxy behind a route to a network target.
+func routeTarget(id, targetAddr string) *ProxyInstance {
+	return NewProxyInstance("tx-test", id, "", targetAddr, 0, 5, 5, 1, logger.NewNullLogger(100), nil)
+}
+
+// routedRead sends one read request for unitID and returns the answer.
+func routedRead(t *testing.T, conn net.Conn, txID uint16, unitID uint8) []byte {
+	t.Helper()
+
+	if _, err := conn.Write(modbus.CreateReadRequest(txID, unitID, 3, 0, 2)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return resp
+}
+
+// TestRoutingDispatchesByUnit verifies that one listener sends each unit ID to
+// the target of its route, rewrites the unit ID where asked, answers under the
+// client's unit ID and rejects unit IDs no route serves.
+func TestRoutingDispatchesByUnit(t *testing.T) {
+	var readsA, readsB int64
+	targetA := countingTarget(t, &readsA, 0)
+	defer targetA.Close()
+	targetB := countingTarget(t, &readsB, 0)
+	defer targetB.Close()
+
+	p := startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Routes = []*Route{
+			NewRoute("meters", 1, 5, 0, routeTarget("meters", targetA.Addr().String())),
+			NewRoute("inverter", 10, 10, 1, routeTarget("inverter", targetB.Addr().String())),
+		}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	resp := routedRead(t, conn, 1, 3)
+	if data, err := modbus.ParseReadResponse(resp); err != nil || data[0] != 3 {
+		t.Errorf("unit 3: got % X (err %v), want data from unit 3 on target A", resp, err)
+	}
+
+	resp = routedRead(t, conn, 2, 10)
+	if resp[6] != 10 {
+		t.Errorf("unit 10 answered as unit %d, want the client's unit 10", resp[6])
+	}
+	if data, err := modbus.ParseReadResponse(resp); err != nil || data[0] != 1 {
+		t.Errorf("unit 10: got % X (err %v), want data from unit 1 on target B", resp, err)
+	}
+
+	resp = routedRead(t, conn, 3, 50)
+	if len(resp) != 9 || resp[7] != 0x83 || resp[8] != 0x0A {
+		t.Errorf("unrouted unit 50: got % X, want exception 0x0A", resp)
+	}
+
+	if a, b := atomic.LoadInt64(&readsA), atomic.LoadInt64(&readsB); a != 1 || b != 1 {
+		t.Errorf("targets saw %d and %d requests, want 1 each", a, b)
+	}
+
+	stats := p.RouteStats()
+	if len(stats) != 2 {
+		t.Fatalf("got %d route stats, want 2", len(stats))
+	}
+	for _, s := range stats {
+		if s.Requests != 1 || s.Errors != 0 {
+			t.Errorf("route %s: %d requests, %d errors, want 1 and 0", s.ID, s.Requests, s.Errors)
+		}
+	}
+	if got := p.Stats.Requests.Load(); got != 2 {
+		t.Errorf("proxy counted %d requests, want 2 (the routed ones)", got)
+	}
+	if got := p.Stats.Errors.Load(); got != 1 {
+		t.Errorf("proxy counted %d errors, want 1 (the unrouted one)", got)
+	}
+}
+
+// TestRoutingIsolatesRoutes verifies that a dead target only fails its own
+// route and that each route keeps its own cache.
+func TestRoutingIsolatesRoutes(t *testing.T) {
+	var reads int64
+	healthy := countingTarget(t, &reads, 0)
+	defer healthy.Close()
+
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	deadAddr := dead.Addr().String()
+	dead.Close()
+
+	cached := routeTarget("healthy", healthy.Addr().String())
+	cached.CacheEnabled = true
+	cached.CacheTTL = 10 * time.Second
+
+	p := startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Routes = []*Route{
+			NewRoute("healthy", 1, 1, 0, cached),
+			NewRoute("dead", 2, 2, 0, routeTarget("dead", deadAddr)),
+		}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		if resp := routedRead(t, conn, uint16(2*i+1), 2); len(resp) != 9 || resp[8] != 0x0B {
+			t.Errorf("dead route: got % X, want exception 0x0B", resp)
+		}
+		if _, err := modbus.ParseReadResponse(routedRead(t, conn, uint16(2*i+2), 1)); err != nil {
+			t.Errorf("healthy route failed next to a dead one: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("healthy target saw %d requests, want 1 (the rest from the route's cache)", got)
+	}
+	for _, s := range p.RouteStats() {
+		switch s.ID {
+		case "healthy":
+			if s.Errors != 0 || s.CacheHits != 2 {
+				t.Errorf("healthy route: %d errors, %d cache hits, want 0 and 2", s.Errors, s.CacheHits)
+			}
+		case "dead":
+			if s.Errors == 0 || s.CacheHits != 0 {
+				t.Errorf("dead route: %d errors, %d cache hits, want errors and no hits", s.Errors, s.CacheHits)
+			}
+		}
+	}
+}
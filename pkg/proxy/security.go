@@ -0,0 +1,167 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	mtls "modbridge/pkg/tls"
+	"net"
+	"sort"
+	"time"
+)
+
+// A proxy can be the secure front door for plain field devices: clients
+// speak Modbus/TCP Security to the listener, the proxy speaks plain Modbus
+// TCP to the device on its own network. It can as well reach a device that
+// speaks TLS itself. Certificates are read at start, so a renewed one takes
+// effect with the next restart of the proxy.
+
+// certificateWarning is how long before it expires a certificate is warned
+// about.
+const certificateWarning = 30 * 24 * time.Hour
+
+// certificateCheckInterval is how often a running proxy checks its
+// certificates again.
+const certificateCheckInterval = 24 * time.Hour
+
+// CertificateStatus describes a certificate a proxy presents.
+type CertificateStatus struct {
+	Use      string    `json:"use"` // listen or target
+	File     string    `json:"file"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+	Expiring bool      `json:"expiring"` // Expired or expires within 30 days
+}
+
+// loadTLS builds the TLS configurations of the listener and the target from
+// their certificate files.
+func (p *ProxyInstance) loadTLS() error {
+	p.listenTLS, p.targetTLS, p.certificates = nil, nil, nil
+	if p.ListenTLS != nil && !p.headless {
+		cfg, err := p.ListenTLS.TLSConfig()
+		if err != nil {
+			return fmt.Errorf("listener TLS: %w", err)
+		}
+		p.listenTLS = cfg
+		p.addCertificate("listen", p.ListenTLS.CertFile)
+	}
+	if p.TargetTLS != nil && p.Protocol != ProtocolSerial && p.TargetAddr != "" {
+		host, _, err := net.SplitHostPort(p.TargetAddr)
+		if err != nil {
+			host = p.TargetAddr
+		}
+		cfg, err := p.TargetTLS.TLSConfig(host)
+		if err != nil {
+			return fmt.Errorf("target TLS: %w", err)
+		}
+		p.targetTLS = cfg
+		if p.TargetTLS.CertFile != "" {
+			p.addCertificate("target", p.TargetTLS.CertFile)
+		}
+	}
+	return nil
+}
+
+// addCertificate records a certificate for the expiry check. The file has
+// just been loaded, so a failure here is not worth refusing to start over.
+func (p *ProxyInstance) addCertificate(use, file string) {
+	info, err := mtls.LoadCertificateInfo(file)
+	if err != nil {
+		p.log.Warn(p.ID, fmt.Sprintf("Cannot read the %s certificate %s: %v", use, file, err))
+		return
+	}
+	p.certificates = append(p.certificates, CertificateStatus{
+		Use:      use,
+		File:     file,
+		Subject:  info.Subject,
+		Issuer:   info.Issuer,
+		NotAfter: info.NotAfter,
+	})
+}
+
+// Certificates returns the certificates the proxy presents, those of its
+// redundant targets and routes included.
+func (p *ProxyInstance) Certificates() []CertificateStatus {
+	out := make([]CertificateStatus, 0, len(p.certificates))
+	for _, sub := range append([]*ProxyInstance{p}, p.subTargets()...) {
+		for _, c := range sub.certificates {
+			left := time.Until(c.NotAfter)
+			c.DaysLeft = int(left.Hours() / 24)
+			c.Expiring = left < certificateWarning
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].NotAfter.Before(out[j].NotAfter) })
+	return out
+}
+
+// checkCertificates warns about every certificate that has expired or is
+// about to.
+func (p *ProxyInstance) checkCertificates() {
+	for _, c := range p.Certificates() {
+		switch {
+		case time.Now().After(c.NotAfter):
+			p.log.Error(p.ID, fmt.Sprintf("The %s certificate %s (%s) expired on %s", c.Use, c.File, c.Subject, c.NotAfter.Format("2006-01-02")))
+		case c.Expiring:
+			p.log.Warn(p.ID, fmt.Sprintf("The %s certificate %s (%s) expires in %d days, on %s", c.Use, c.File, c.Subject, c.DaysLeft, c.NotAfter.Format("2006-01-02")))
+		}
+	}
+}
+
+// watchCertificates repeats the expiry check while the proxy runs, so a
+// proxy that runs for months still warns in time.
+func (p *ProxyInstance) watchCertificates() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkCertificates()
+		}
+	}
+}
+
+// dialTargetConn connects to the target, with the TLS handshake when the
+// target speaks TLS. The connect delay is the caller's business.
+func (p *ProxyInstance) dialTargetConn(ctx context.Context, d net.Dialer) (net.Conn, error) {
+	if p.Protocol == ProtocolVirtual {
+		return p.dialVirtual()
+	}
+	conn, err := d.DialContext(ctx, "tcp", p.TargetAddr)
+	if err != nil || p.targetTLS == nil {
+		return conn, err
+	}
+	tc := tls.Client(conn, p.targetTLS)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s: %w", p.TargetAddr, err)
+	}
+	return tc, nil
+}
+
+// clientHandshake completes the TLS handshake of a client before its first
+// request, so the session knows who is connected. A client that does not
+// finish within the connection timeout is dropped.
+func (p *ProxyInstance) clientHandshake(conn net.Conn) error {
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	timeout := p.ConnectionTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+	return tc.HandshakeContext(ctx)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"modbridge/pkg/modbus"
+	mtls "modbridge/pkg/tls"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPKI writes a self-signed server certificate for 127.0.0.1, a CA for
+// client certificates and a client certificate it signed with the given role.
+func testPKI(t *testing.T, role string) (serverCert, serverKey, clientCA, clientCert, clientKey string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := mtls.SaveCertificates(dir, "localhost", time.Hour); err != nil {
+		t.Fatalf("failed to create server certificate: %v", err)
+	}
+	caPEM, caKeyPEM, err := mtls.GenerateModbusCA("clients", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create client CA: %v", err)
+	}
+	certPEM, keyPEM, err := mtls.GenerateModbusClientCert(caPEM, caKeyPEM, "scada", role, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create client certificate: %v", err)
+	}
+	clientCA, clientCert, clientKey = filepath.Join(dir, "ca.crt"), filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	for file, data := range map[string][]byte{clientCA: caPEM, clientCert: certPEM, clientKey: keyPEM} {
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), clientCA, clientCert, clientKey
+}
+
+// dialSecure connects to a Modbus/TCP Security listener, with a client
+// certificate unless certFile is empty.
+func dialSecure(t *testing.T, addr, serverCert, certFile, keyFile string) *tls.Conn {
+	t.Helper()
+
+	caPEM, _ := os.ReadFile(serverCert)
+	cfg := &tls.Config{RootCAs: x509.NewCertPool(), ServerName: "127.0.0.1", MinVersion: tls.VersionTLS12}
+	cfg.RootCAs.AppendCertsFromPEM(caPEM)
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			t.Fatalf("failed to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	return conn
+}
+
+// TestSecureListenerRequiresClientCertificate verifies that the listener
+// serves a client with a certificate from its CA, records the certificate's
+// role in the session and turns away a client without one.
+func TestSecureListenerRequiresClientCertificate(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	serverCert, serverKey, clientCA, clientCert, clientKey := testPKI(t, "operator")
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.ListenTLS = &mtls.ModbusServerConfig{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: clientCA}
+	})
+	defer p.Stop()
+
+	conn := dialSecure(t, p.ListenAddr, serverCert, clientCert, clientKey)
+	defer conn.Close()
+	if resp := exchange(t, conn, modbus.CreateReadRequest(1, 1, 3, 0, 2)); modbus.IsExceptionResponse(resp) {
+		t.Fatalf("read answered % X", resp)
+	}
+	sessions := p.Sessions()
+	if len(sessions) != 1 || sessions[0].CertRole != "operator" {
+		t.Errorf("sessions = %+v, want one with role operator", sessions)
+	}
+
+	anonymous := dialSecure(t, p.ListenAddr, serverCert, "", "")
+	defer anonymous.Close()
+	_ = anonymous.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := anonymous.Write(modbus.CreateReadRequest(2, 1, 3, 0, 2)); err == nil {
+		if _, err := modbus.ReadFrame(anonymous); err == nil {
+			t.Error("client without a certificate got an answer")
+		}
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1", got)
+	}
+	if certs := p.Certificates(); len(certs) != 1 || certs[0].Use != "listen" || !certs[0].Expiring {
+		t.Errorf("Certificates() = %+v, want the listen certificate, expiring", certs)
+	}
+}
+
+// TestTargetTLS verifies that a proxy reaches a target over TLS, here a
+// second proxy acting as the secure front door of a plain device.
+func TestTargetTLS(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	serverCert, serverKey, clientCA, clientCert, clientKey := testPKI(t, "")
+	door := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.ListenTLS = &mtls.ModbusServerConfig{CertFile: serverCert, KeyFile: serverKey, ClientCAFile: clientCA}
+	})
+	defer door.Stop()
+
+	p := startTestProxy(t, door.ListenAddr, func(p *ProxyInstance) {
+		p.TargetTLS = &mtls.ModbusClientConfig{CAFile: serverCert, CertFile: clientCert, KeyFile: clientKey}
+	})
+	defer p.Stop()
+
+	if _, err := p.ReadRegisters(1, 3, 0, 2); err != nil {
+		t.Fatalf("ReadRegisters() error = %v", err)
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("target saw %d reads, want 1", got)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/rtu"
+	"time"
+)
+
+// ProtocolSerial forwards to a Modbus RTU device on a local serial line
+// instead of a network target.
+const ProtocolSerial = "serial"
+
+// startSerial joins the serial bus for a proxy whose target is local. A proxy
+// either shares a bus with others (Bus) or owns a line of its own (Serial),
+// which is simply a bus nobody else uses. The bus takes the place of the
+// connection pool: there is exactly one line, and its queue serialises every
+// exchange on it.
+func (p *ProxyInstance) startSerial() error {
+	bus := p.Bus
+	if bus == nil {
+		if p.BusID != "" {
+			return fmt.Errorf("serial bus %q is not configured", p.BusID)
+		}
+		if p.Serial == nil || p.Serial.Device == "" {
+			return fmt.Errorf("serial protocol requires a serial device")
+		}
+		bus = rtu.NewBus(p.Serial, rtu.BusOptions{}, p.openSerial)
+	}
+
+	if err := bus.Acquire(); err != nil {
+		return err
+	}
+	p.serialBus = bus
+	return nil
+}
+
+// stopSerial leaves the serial bus. The line closes once its last user is
+// gone.
+func (p *ProxyInstance) stopSerial() {
+	if p.serialBus == nil {
+		return
+	}
+	if err := p.serialBus.Release(); err != nil {
+		p.log.Error(p.ID, fmt.Sprintf("Failed to close serial line: %v", err))
+	}
+	p.serialBus = nil
+}
+
+// forwardRequestSerial sends a Modbus TCP request to a device on the local
+// serial line and answers with a Modbus TCP frame. It follows the same budget
+// and retry rules as the network paths, so a client of a serial proxy gets the
+// same guarantees as one of a TCP proxy.
+func (p *ProxyInstance) forwardRequestSerial(tcpReq []byte) ([]byte, error) {
+	if len(tcpReq) < 8 {
+		return nil, fmt.Errorf("serial: tcp request too short (%d bytes)", len(tcpReq))
+	}
+	if p.serialBus == nil {
+		return nil, fmt.Errorf("serial: line is not open")
+	}
+
+	budget := p.requestBudget()
+	deadline := time.Now().Add(budget)
+
+	var lastErr error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := p.retryBackoff(attempt)
+			if time.Now().Add(backoff).After(deadline) {
+				break
+			}
+			time.Sleep(backoff)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+
+		tcpResp, err := p.forwardAttemptSerial(tcpReq, remaining)
+		if err == nil {
+			return tcpResp, nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("serial: request budget of %v exhausted before an attempt could run", budget)
+	}
+	return nil, fmt.Errorf("serial: failed within budget %v (max %d retries): %w", budget, p.MaxRetries, lastErr)
+}
+
+// forwardAttemptSerial performs one exchange on the serial bus; time spent
+// waiting behind other users of the line counts against the attempt. A Modbus
+// exception from the device is an answer, not a failure: it goes back to the
+// client as-is and is not retried.
+func (p *ProxyInstance) forwardAttemptSerial(tcpReq []byte, remaining time.Duration) ([]byte, error) {
+	readTimeout, _ := p.currentTimeouts()
+	if readTimeout > remaining {
+		readTimeout = remaining
+	}
+
+	ctx, cancel := context.WithTimeout(p.proxyContext(), remaining)
+	defer cancel()
+
+	if err := p.pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	txID, _ := modbus.FrameTxID(tcpReq)
+	unitID, fc, _ := modbus.FrameUnitAndFunction(tcpReq)
+
+	resp, err := p.serialBus.Do(ctx, &rtu.Request{
+		SlaveID:  unitID,
+		Function: fc,
+		Data:     tcpReq[8:],
+	}, readTimeout)
+	if err != nil {
+		var exception *rtu.ExceptionError
+		if errors.As(err, &exception) {
+			return modbus.ExceptionResponse(txID, unitID, fc, exception.Code), nil
+		}
+		return nil, err
+	}
+
+	return serialResponseFrame(txID, resp), nil
+}
+
+// serialResponseFrame wraps a parsed RTU response in a Modbus TCP frame. The
+// RTU client strips the byte count of read responses while parsing, so it is
+// put back here.
+func serialResponseFrame(txID uint16, resp *rtu.Response) []byte {
+	pdu := make([]byte, 0, 2+1+len(resp.Data))
+	pdu = append(pdu, resp.SlaveID, resp.Function)
+	if modbus.IsReadFunction(resp.Function) {
+		pdu = append(pdu, byte(len(resp.Data)))
+	}
+	pdu = append(pdu, resp.Data...)
+
+	frame := make([]byte, modbus.MBAPHeaderLength+len(pdu))
+	modbus.SetFrameTxID(frame, txID)
+	frame[4] = byte(len(pdu) >> 8)
+	frame[5] = byte(len(pdu))
+	copy(frame[modbus.MBAPHeaderLength:], pdu)
+	return frame
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"io"
+	"modbridge/pkg/logger"
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/rtu"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSerialSlave stands in for an RS-485 line with Modbus RTU devices on it.
+// It answers read requests with the unit ID as payload and rejects unit 99
+// with "illegal data address", counting every frame it receives.
+func fakeSerialSlave(t *testing.T, reads *int64) func(cfg *rtu.Config) (rtu.SerialPort, error) {
+	t.Helper()
+
+	return func(cfg *rtu.Config) (rtu.SerialPort, error) {
+		proxySide, deviceSide := net.Pipe()
+
+		go func() {
+			defer deviceSide.Close()
+			for {
+				// Every read request on the line is 8 bytes: unit, FC,
+				// address, quantity and CRC.
+				req := make([]byte, 8)
+				if _, err := io.ReadFull(deviceSide, req); err != nil {
+					return
+				}
+				atomic.AddInt64(reads, 1)
+
+				unitID, fc := req[0], req[1]
+				var tcpResp []byte
+				if unitID == 99 {
+					tcpResp = modbus.ExceptionResponse(0, unitID, fc, 0x02)
+				} else {
+					quantity := binary.BigEndian.Uint16(req[4:6])
+					data := make([]byte, quantity*2)
+					for i := range data {
+						data[i] = unitID
+					}
+					tcpResp, _ = modbus.CreateReadResponse(0, unitID, fc, data)
+				}
+
+				rtuResp, err := modbus.TCPToRTU(tcpResp)
+				if err != nil {
+					return
+				}
+				if _, err := deviceSide.Write(rtuResp); err != nil {
+					return
+				}
+			}
+		}()
+
+		return proxySide, nil
+	}
+}
+
+// startSerialTestProxy starts a proxy whose target is the fake serial line.
+func startSerialTestProxy(t *testing.T, reads *int64, configure func(*ProxyInstance)) *ProxyInstance {
+	t.Helper()
+
+	return startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Protocol = ProtocolSerial
+		p.Serial = rtu.DefaultConfig()
+		p.Serial.Device = "/dev/ttyFAKE0"
+		p.Serial.InterFrameDelay = rtu.FrameGap(p.Serial.BaudRate)
+		p.openSerial = fakeSerialSlave(t, reads)
+		if configure != nil {
+			configure(p)
+		}
+	})
+}
+
+// serialRoundTrip sends one read request through the proxy and returns the
+// answer.
+func serialRoundTrip(t *testing.T, conn net.Conn, txID uint16, unitID uint8) []byte {
+	t.Helper()
+
+	if _, err := conn.Write(modbus.CreateReadRequest(txID, unitID, 3, 100, 4)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return resp
+}
+
+// TestSerialForwardsReads verifies that a Modbus TCP read reaches the device
+// on the serial line and comes back as a complete TCP frame, byte count
+// included and under the client's transaction ID.
+func TestSerialForwardsReads(t *testing.T) {
+	var reads int64
+	p := startSerialTestProxy(t, &reads, nil)
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i, unitID := range []uint8{1, 7, 1} {
+		txID := uint16(0x100 + i)
+		resp := serialRoundTrip(t, conn, txID, unitID)
+
+		if got := binary.BigEndian.Uint16(resp[0:2]); got != txID {
+			t.Errorf("request %d answered with transaction ID 0x%04X, want 0x%04X", i, got, txID)
+		}
+		data, err := modbus.ParseReadResponse(resp)
+		if err != nil {
+			t.Fatalf("request %d: invalid response: %v", i, err)
+		}
+		if len(data) != 8 {
+			t.Fatalf("request %d: %d data bytes, want 8", i, len(data))
+		}
+		for _, b := range data {
+			if b != unitID {
+				t.Fatalf("request %d: data % X does not come from unit %d", i, data, unitID)
+			}
+		}
+	}
+
+	if got := atomic.LoadInt64(&reads); got != 3 {
+		t.Errorf("serial line saw %d requests, want 3", got)
+	}
+}
+
+// TestSerialPassesExceptions verifies that an exception from a serial device
+// goes back to the client as a Modbus exception and is not retried.
+func TestSerialPassesExceptions(t *testing.T) {
+	var reads int64
+	p := startSerialTestProxy(t, &reads, nil)
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	resp := serialRoundTrip(t, conn, 0x42, 99)
+	if len(resp) != 9 || resp[7] != 0x83 || resp[8] != 0x02 {
+		t.Fatalf("got % X, want exception 0x02 for FC 0x03", resp)
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("serial line saw %d requests, want 1 (exceptions are answers, not failures)", got)
+	}
+}
+
+// TestSerialUsesCache verifies that the response cache sits in front of the
+// serial line like it does for network targets.
+func TestSerialUsesCache(t *testing.T) {
+	var reads int64
+	p := startSerialTestProxy(t, &reads, func(p *ProxyInstance) {
+		p.CacheEnabled = true
+		p.CacheTTL = 10 * time.Second
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		serialRoundTrip(t, conn, uint16(i+1), 5)
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("serial line saw %d requests, want 1 (the rest should come from the cache)", got)
+	}
+}
+
+// TestSerialRequiresDevice verifies that a serial proxy without a device
+// refuses to start instead of failing on the first request.
+func TestSerialRequiresDevice(t *testing.T) {
+	p := NewProxyInstance("serial-test", "serial-test", "127.0.0.1:0", "", 0, 5, 5, 3, logger.NewNullLogger(100), nil)
+	p.Protocol = ProtocolSerial
+	if err := p.Start(); err == nil {
+		p.Stop()
+		t.Fatal("Start() succeeded without a serial device")
+	}
+}
+
+// TestSerialSharedBus verifies that two proxies on one shared bus both reach
+// their units over a single line, and that the line outlives the first proxy
+// to stop.
+func TestSerialSharedBus(t *testing.T) {
+	var reads, opens int64
+	line := rtu.DefaultConfig()
+	line.Device = "/dev/ttyFAKE0"
+	open := fakeSerialSlave(t, &reads)
+	bus := rtu.NewBus(line, rtu.BusOptions{}, func(cfg *rtu.Config) (rtu.SerialPort, error) {
+		atomic.AddInt64(&opens, 1)
+		return open(cfg)
+	})
+
+	shared := func(p *ProxyInstance) {
+		p.Protocol = ProtocolSerial
+		p.BusID = "rs485-1"
+		p.Bus = bus
+	}
+	first := startTestProxy(t, "", shared)
+	defer first.Stop()
+	second := startTestProxy(t, "", shared)
+	defer second.Stop()
+
+	for _, p := range []*ProxyInstance{first, second} {
+		conn, err := net.Dial("tcp", p.ListenAddr)
+		if err != nil {
+			t.Fatalf("failed to connect to proxy: %v", err)
+		}
+		if resp := serialRoundTrip(t, conn, 1, 4); len(resp) != 9+8 {
+			t.Errorf("got % X, want a read response", resp)
+		}
+		conn.Close()
+	}
+	if got := atomic.LoadInt64(&opens); got != 1 {
+		t.Errorf("serial line opened %d times for two proxies, want 1", got)
+	}
+
+	first.Stop()
+	conn, err := net.Dial("tcp", second.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	if resp := serialRoundTrip(t, conn, 2, 6); len(resp) != 9+8 {
+		t.Errorf("after the first proxy stopped: got % X, want a read response", resp)
+	}
+}
+
+// TestSerialUnknownBus verifies that a proxy naming a bus that does not exist
+// refuses to start with a clear error.
+func TestSerialUnknownBus(t *testing.T) {
+	p := NewProxyInstance("serial-test", "serial-test", "127.0.0.1:0", "", 0, 5, 5, 3, logger.NewNullLogger(100), nil)
+	p.Protocol = ProtocolSerial
+	p.BusID = "missing"
+	if err := p.Start(); err == nil {
+		p.Stop()
+		t.Fatal("Start() succeeded with an unknown bus")
+	}
+}
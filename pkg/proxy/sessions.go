@@ -0,0 +1,226 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"modbridge/pkg/modbus"
+	mtls "modbridge/pkg/tls"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The device tracker knows which addresses have connected; a session says what
+// one connection is doing right now. When an inverter slows down, the sessions
+// show which of the clients asks for what and how often — usually enough to
+// find the script that polls a register block in a tight loop.
+
+// maxSessionReads caps the distinct reads a session keeps counts for. A client
+// walking the whole register space would otherwise grow the map without bound;
+// past the cap, only reads already known are still counted.
+const maxSessionReads = 256
+
+// topSessionReads is how many of the most frequent reads a session shows.
+const topSessionReads = 5
+
+// sessionSeq numbers the sessions of all proxies, so that an ID names one
+// connection for the lifetime of the process.
+var sessionSeq atomic.Uint64
+
+// readKey identifies a read by what it asks the device for.
+type readKey struct {
+	unit     uint8
+	function uint8
+	address  uint16
+	count    uint16
+}
+
+// clientSession is the accounting for one live client connection.
+type clientSession struct {
+	id     string
+	conn   net.Conn
+	remote string
+	since  time.Time
+
+	// The client certificate of a Modbus/TCP Security connection: its
+	// subject, common name and the role it carries (empty = none).
+	certSubject string
+	certName    string
+	certRole    string
+
+	mu        sync.Mutex
+	last      time.Time
+	requests  int64
+	errors    int64
+	bytesIn   int64
+	bytesOut  int64
+	functions map[uint8]int64
+	reads     map[readKey]int64
+}
+
+func newClientSession(conn net.Conn) *clientSession {
+	s := &clientSession{
+		id:        fmt.Sprintf("s%d", sessionSeq.Add(1)),
+		conn:      conn,
+		remote:    conn.RemoteAddr().String(),
+		since:     time.Now(),
+		functions: make(map[uint8]int64),
+		reads:     make(map[readKey]int64),
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			s.certSubject = certs[0].Subject.String()
+			s.certName = certs[0].Subject.CommonName
+			s.certRole, _, _ = mtls.ModbusRole(certs[0])
+		}
+	}
+	return s
+}
+
+// record counts one exchange as the client saw it: its own request and the
+// answer it got, before any rewrite. An exception answer counts as an error.
+func (s *clientSession) record(req, resp []byte) {
+	unit, fc, ok := modbus.FrameUnitAndFunction(req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = time.Now()
+	s.requests++
+	s.bytesIn += int64(len(req))
+	s.bytesOut += int64(len(resp))
+	if modbus.IsExceptionResponse(resp) {
+		s.errors++
+	}
+	if !ok {
+		return
+	}
+	s.functions[fc]++
+	if !modbus.IsReadRequest(req) || len(req) < 12 {
+		return
+	}
+	key := readKey{
+		unit:     unit,
+		function: fc,
+		address:  uint16(req[8])<<8 | uint16(req[9]),
+		count:    uint16(req[10])<<8 | uint16(req[11]),
+	}
+	if _, known := s.reads[key]; known || len(s.reads) < maxSessionReads {
+		s.reads[key]++
+	}
+}
+
+// SessionRead is one of the reads a client sends most.
+type SessionRead struct {
+	UnitID   uint8  `json:"unit_id"`
+	Function uint8  `json:"function_code"`
+	Address  uint16 `json:"address"`
+	Count    uint16 `json:"count"`
+	Reads    int64  `json:"reads"`
+}
+
+// SessionInfo describes a live client connection.
+type SessionInfo struct {
+	ID             string           `json:"id"`
+	ProxyID        string           `json:"proxy_id"`
+	RemoteAddr     string           `json:"remote_addr"`
+	ConnectedSince time.Time        `json:"connected_since"`
+	LastRequest    *time.Time       `json:"last_request,omitempty"`
+	Requests       int64            `json:"requests"`
+	Errors         int64            `json:"errors"`
+	BytesIn        int64            `json:"bytes_in"`  // Sent by the client
+	BytesOut       int64            `json:"bytes_out"` // Sent to the client
+	Functions      map[string]int64 `json:"functions"` // Requests by function code, e.g. "0x03"
+	TopReads       []SessionRead    `json:"top_reads"`
+	CertSubject    string           `json:"cert_subject,omitempty"` // Client certificate of a Modbus/TCP Security connection
+	CertRole       string           `json:"cert_role,omitempty"`    // Modbus role in that certificate
+}
+
+func (s *clientSession) info(proxyID string) SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := SessionInfo{
+		ID:             s.id,
+		ProxyID:        proxyID,
+		RemoteAddr:     s.remote,
+		ConnectedSince: s.since,
+		Requests:       s.requests,
+		Errors:         s.errors,
+		BytesIn:        s.bytesIn,
+		BytesOut:       s.bytesOut,
+		Functions:      make(map[string]int64, len(s.functions)),
+		TopReads:       make([]SessionRead, 0, topSessionReads),
+		CertSubject:    s.certSubject,
+		CertRole:       s.certRole,
+	}
+	if !s.last.IsZero() {
+		last := s.last
+		info.LastRequest = &last
+	}
+	for fc, n := range s.functions {
+		info.Functions[fmt.Sprintf("0x%02X", fc)] = n
+	}
+	for key, n := range s.reads {
+		info.TopReads = append(info.TopReads, SessionRead{UnitID: key.unit, Function: key.function, Address: key.address, Count: key.count, Reads: n})
+	}
+	sort.Slice(info.TopReads, func(i, j int) bool {
+		a, b := info.TopReads[i], info.TopReads[j]
+		if a.Reads != b.Reads {
+			return a.Reads > b.Reads
+		}
+		if a.UnitID != b.UnitID {
+			return a.UnitID < b.UnitID
+		}
+		return a.Address < b.Address
+	})
+	if len(info.TopReads) > topSessionReads {
+		info.TopReads = info.TopReads[:topSessionReads]
+	}
+	return info
+}
+
+// Sessions returns the live client connections, oldest first.
+func (p *ProxyInstance) Sessions() []SessionInfo {
+	p.clientsMu.Lock()
+	sessions := make([]*clientSession, 0, len(p.clients))
+	for _, s := range p.clients {
+		sessions = append(sessions, s)
+	}
+	p.clientsMu.Unlock()
+
+	out := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, s.info(p.ID))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ConnectedSince.Before(out[j].ConnectedSince) })
+	return out
+}
+
+// KickSession ends the client connection with the given session ID. It
+// reports false when the proxy has no such session. The client may connect
+// again; to keep it out, the access policy is the tool.
+func (p *ProxyInstance) KickSession(id string) bool {
+	p.clientsMu.Lock()
+	var target *clientSession
+	for _, s := range p.clients {
+		if s.id == id {
+			target = s
+			break
+		}
+	}
+	p.clientsMu.Unlock()
+	if target == nil {
+		return false
+	}
+
+	p.log.Info(p.ID, fmt.Sprintf("Ending client session %s from %s on request", id, target.remote))
+	_ = target.conn.Close()
+	return true
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/modbus"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestSessionsAccounting verifies that each client connection is shown with
+// its own counters and most frequent reads, and that kicking a session ends
+// that connection only.
+func TestSessionsAccounting(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), nil)
+	defer p.Stop()
+
+	busy, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer busy.Close()
+	quiet, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer quiet.Close()
+
+	for i := 0; i < 4; i++ {
+		exchange(t, busy, modbus.CreateReadRequest(uint16(i), 1, 3, 40000, 10))
+	}
+	exchange(t, busy, modbus.CreateReadRequest(9, 2, 4, 30000, 2))
+	exchange(t, quiet, modbus.CreateReadRequest(1, 1, 3, 0, 1))
+
+	sessions := p.Sessions()
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	var s SessionInfo
+	for _, candidate := range sessions {
+		if candidate.RemoteAddr == busy.LocalAddr().String() {
+			s = candidate
+		}
+	}
+	if s.ID == "" || s.ProxyID != "tx-test" {
+		t.Fatalf("no session for the busy client in %+v", sessions)
+	}
+	if s.Requests != 5 || s.Errors != 0 || s.Functions["0x03"] != 4 || s.Functions["0x04"] != 1 {
+		t.Errorf("session = %+v, want 5 requests: four 0x03, one 0x04", s)
+	}
+	if s.BytesIn != 5*12 || s.BytesOut == 0 || s.LastRequest == nil {
+		t.Errorf("session bytes in %d, out %d, last %v", s.BytesIn, s.BytesOut, s.LastRequest)
+	}
+	if len(s.TopReads) != 2 || s.TopReads[0].Address != 40000 || s.TopReads[0].Count != 10 || s.TopReads[0].Reads != 4 {
+		t.Errorf("top reads = %+v, want 40000 x10 first with 4 reads", s.TopReads)
+	}
+
+	if p.KickSession("s0") {
+		t.Error("KickSession accepted an unknown session")
+	}
+	if !p.KickSession(s.ID) {
+		t.Fatal("KickSession did not find the session")
+	}
+	if err := busy.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	if _, err := busy.Read(make([]byte, 1)); err == nil {
+		t.Error("kicked connection still open")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(p.Sessions()) != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if left := p.Sessions(); len(left) != 1 || left[0].RemoteAddr != quiet.LocalAddr().String() {
+		t.Errorf("sessions after the kick = %+v, want only the quiet client", left)
+	}
+}
@@ -35,6 +35,37 @@ type EnhancedStats struct {
 	latencyWindow  int
 	requestsWindow time.Duration
 	totalRequests  int64
+
+	// The percentiles above only see the last latencyWindow requests; the
+	// histogram counts every request since start, which is what a scraper
+	// needs to compute rates and quantiles of its own.
+	latencyBuckets []int64 // Per bucket of LatencyBuckets, plus one above the last
+	latencySum     time.Duration
+	exceptions     map[uint8]int64 // Exception responses sent to clients, by code
+}
+
+// LatencyBuckets are the upper bounds of the latency histogram. They span a
+// fast local gateway to a slow device behind a cellular link.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram is the distribution of request latencies since start.
+type LatencyHistogram struct {
+	Bounds []time.Duration // Upper bound of each bucket
+	Counts []int64         // Requests at or below each bound (cumulative)
+	Count  int64           // All requests, including those above the last bound
+	Sum    time.Duration
 }
 
 // LatencyPercentiles returns latency statistics
@@ -66,6 +97,8 @@ func NewEnhancedStats(latencyWindow int) *EnhancedStats {
 		lastRequestTime:   now,
 		requestsResetTime: now,
 		requestsWindow:    60 * time.Minute,
+		latencyBuckets:    make([]int64, len(LatencyBuckets)+1),
+		exceptions:        make(map[uint8]int64),
 	}
 }
 
@@ -145,6 +178,44 @@ func (s *EnhancedStats) recordLatency(latency time.Duration) {
 	if latency > s.maxLatency {
 		s.maxLatency = latency
 	}
+
+	bucket := sort.Search(len(LatencyBuckets), func(i int) bool { return latency <= LatencyBuckets[i] })
+	s.latencyBuckets[bucket]++
+	s.latencySum += latency
+}
+
+// RecordException counts an exception response sent to a client.
+func (s *EnhancedStats) RecordException(code uint8) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exceptions[code]++
+}
+
+// Exceptions returns how many exception responses went to clients, by code.
+func (s *EnhancedStats) Exceptions() map[uint8]int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make(map[uint8]int64, len(s.exceptions))
+	for code, n := range s.exceptions {
+		out[code] = n
+	}
+	return out
+}
+
+// Histogram returns the latency histogram.
+func (s *EnhancedStats) Histogram() LatencyHistogram {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h := LatencyHistogram{Bounds: LatencyBuckets, Counts: make([]int64, len(LatencyBuckets)), Sum: s.latencySum}
+	for i, n := range s.latencyBuckets {
+		h.Count += n
+		if i < len(h.Counts) {
+			h.Counts[i] = h.Count
+		}
+	}
+	return h
 }
 
 // checkRequestWindow checks if the request window has expired and resets if needed
@@ -257,3 +328,73 @@ func (s *EnhancedStats) GetThroughput(window time.Duration) float64 {
 
 	return float64(s.latencyCount) / window.Seconds()
 }
+
+// ExceptionCounts returns how many exception responses the proxy sent to its
+// clients, by exception code. Denials, unrouted unit IDs and a failed target
+// count as well as the exceptions the device itself returned.
+func (p *ProxyInstance) ExceptionCounts() map[uint8]int64 {
+	if p.enhancedStats == nil {
+		return nil
+	}
+	return p.enhancedStats.Exceptions()
+}
+
+// LatencyHistogram returns the distribution of forward latencies since the
+// proxy was started. Cache hits are not in it: they never reach the target.
+func (p *ProxyInstance) LatencyHistogram() LatencyHistogram {
+	if p.enhancedStats == nil {
+		return LatencyHistogram{Bounds: LatencyBuckets, Counts: make([]int64, len(LatencyBuckets))}
+	}
+	return p.enhancedStats.Histogram()
+}
+
+// PacingWait returns how long requests have waited for MinRequestGap so far,
+// on this proxy and behind its routes and endpoints. A value that grows about
+// as fast as the clock means the gap, not the device, sets the pace.
+func (p *ProxyInstance) PacingWait() time.Duration {
+	wait := time.Duration(p.pacingWait.Load())
+	for _, t := range p.subTargets() {
+		wait += t.PacingWait()
+	}
+	return wait
+}
+
+// TargetConns returns the connections open to the target: those serving a
+// request and those idle in the pool, on this proxy and behind its routes
+// and endpoints.
+func (p *ProxyInstance) TargetConns() (active, idle int) {
+	if p.connPool != nil && p.Stats.GetStatus() == "Running" {
+		s := p.connPool.Stats()
+		active, idle = s.ActiveConns, s.IdleConns
+	}
+	for _, t := range p.subTargets() {
+		a, i := t.TargetConns()
+		active += a
+		idle += i
+	}
+	return active, idle
+}
+
+// CircuitState names the state of the proxy's circuit breaker: closed, open
+// or half-open. A proxy with endpoints never opens its own; each endpoint
+// has one.
+func (p *ProxyInstance) CircuitState() string {
+	return p.circuitState()
+}
+
+// subTargets returns the proxies behind the routes and endpoints.
+func (p *ProxyInstance) subTargets() []*ProxyInstance {
+	if len(p.Routes) == 0 && len(p.Endpoints) == 0 {
+		return nil
+	}
+	out := make([]*ProxyInstance, 0, len(p.Routes)+len(p.Endpoints))
+	for _, r := range p.Routes {
+		out = append(out, r.target)
+	}
+	for _, ep := range p.Endpoints {
+		if ep.target != nil {
+			out = append(out, ep.target)
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"modbridge/pkg/modbus"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMetricsCounters verifies that forwarded requests land in the latency
+// histogram, exception responses are counted by code, and the pacing wait
+// adds up.
+func TestMetricsCounters(t *testing.T) {
+	var reads int64
+	target := countingTarget(t, &reads, 0)
+	defer target.Close()
+
+	p := startTestProxy(t, target.Addr().String(), func(p *ProxyInstance) {
+		p.MinRequestGap = 50 * time.Millisecond
+		p.Policy = &AccessPolicy{AccessRule: AccessRule{ReadOnly: true}}
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		exchange(t, conn, modbus.CreateReadRequest(uint16(i), 1, 3, uint16(i), 1))
+	}
+	write, err := modbus.CreateWriteRequest(9, 1, 100, []uint16{1})
+	if err != nil {
+		t.Fatalf("failed to build write: %v", err)
+	}
+	if resp := exchange(t, conn, write); !modbus.IsExceptionResponse(resp) {
+		t.Fatalf("write answered % X, want the policy's exception", resp)
+	}
+
+	h := p.LatencyHistogram()
+	if h.Count != 3 || h.Counts[len(h.Counts)-1] != 3 || h.Sum <= 0 {
+		t.Errorf("histogram = %+v, want the 3 forwarded reads", h)
+	}
+	for i := 1; i < len(h.Counts); i++ {
+		if h.Counts[i] < h.Counts[i-1] {
+			t.Errorf("bucket counts %v are not cumulative", h.Counts)
+			break
+		}
+	}
+	if got := p.ExceptionCounts(); len(got) != 1 || got[0x01] != 1 {
+		t.Errorf("exceptions = %v, want one 0x01 for the refused write", got)
+	}
+	// Three reads 50ms apart wait about 100ms between them.
+	if wait := p.PacingWait(); wait < 50*time.Millisecond {
+		t.Errorf("pacing wait = %v, want the gaps between the reads", wait)
+	}
+	if atomic.LoadInt64(&reads) != 3 {
+		t.Errorf("target saw %d reads, want 3", reads)
+	}
+}
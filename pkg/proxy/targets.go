@@ -0,0 +1,177 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"fmt"
+	"modbridge/pkg/modbus"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A proxy with Endpoints has several targets for the same device: two
+// gateways on one bus, a primary and a standby PLC. Like a route, every
+// endpoint is a complete proxy without a listener, so each has its own
+// connection pool, pacing, health checker and circuit breaker; the proxy in
+// front keeps the cache and the clients. Which endpoint gets a request is up
+// to EndpointPolicy, among the endpoints whose health checker and breaker
+// would have one.
+
+// available reports whether this proxy's target is worth sending a request
+// to: it runs, its health check has not given up on it, and its circuit
+// breaker would let a request through.
+func (p *ProxyInstance) available() bool {
+	if p.Stats.GetStatus() != "Running" {
+		return false
+	}
+	if p.healthChecker != nil && !p.healthChecker.IsHealthy() {
+		return false
+	}
+	return p.circuitBreaker == nil || p.circuitBreaker.Ready()
+}
+
+// forwardEndpoints sends a request to the endpoint the policy picks. A read
+// that fails there is tried on the other available endpoints in turn; a write
+// is not, because it may have reached the device before the answer got lost.
+// When no endpoint is available, all are tried in order of preference rather
+// than none: their breakers still turn away what they must, and a device may
+// be back before its health check has noticed.
+func (p *ProxyInstance) forwardEndpoints(client string, req []byte) ([]byte, error) {
+	first := p.balancer.pick(client)
+	candidates := make([]*TargetEndpoint, 0, len(p.Endpoints))
+	if first != nil {
+		candidates = append(candidates, first)
+	}
+	for _, ep := range p.Endpoints {
+		if ep != first && (first == nil || ep.available()) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if _, fc, ok := modbus.FrameUnitAndFunction(req); ok && modbus.IsWriteFunction(fc) && len(candidates) > 1 {
+		candidates = candidates[:1]
+	}
+
+	var failed []string
+	for _, ep := range candidates {
+		atomic.AddInt32(&ep.CurrentConns, 1)
+		atomic.AddInt64(&ep.TotalRequests, 1)
+		resp, ok := ep.target.serveFrame("", req)
+		p.balancer.ReleaseEndpoint(ep)
+		if ok {
+			return resp, nil
+		}
+		failed = append(failed, ep.Address)
+	}
+	return nil, fmt.Errorf("no endpoint answered (tried %s)", strings.Join(failed, ", "))
+}
+
+// startEndpoints starts the proxies behind every endpoint. If one fails,
+// those already started are stopped again.
+func (p *ProxyInstance) startEndpoints() error {
+	if len(p.Endpoints) == 0 {
+		return nil
+	}
+	for i, ep := range p.Endpoints {
+		if err := ep.target.Start(); err != nil {
+			for _, started := range p.Endpoints[:i] {
+				started.target.Stop()
+			}
+			return fmt.Errorf("target %s: %w", ep.Address, err)
+		}
+	}
+	p.balancer = newEndpointBalancer(p.EndpointPolicy, p.Endpoints)
+	return nil
+}
+
+// stopEndpoints stops the proxies behind every endpoint.
+func (p *ProxyInstance) stopEndpoints() {
+	for _, ep := range p.Endpoints {
+		ep.target.Stop()
+	}
+}
+
+// endpointsHealth sums up the health of the endpoints: healthy while one of
+// them is. The details are those of the first endpoint with a check, and the
+// error names every unhealthy one.
+func (p *ProxyInstance) endpointsHealth() (HealthStatus, bool) {
+	var (
+		status   HealthStatus
+		found    bool
+		problems []string
+	)
+	for _, ep := range p.Endpoints {
+		hs, ok := ep.target.Health()
+		if !ok {
+			continue
+		}
+		if !found {
+			status, found = hs, true
+		}
+		if hs.LastCheck.After(status.LastCheck) {
+			status.LastCheck = hs.LastCheck
+		}
+		if hs.Healthy {
+			status.Healthy = true
+		} else {
+			problems = append(problems, ep.Address+": "+hs.LastError)
+		}
+	}
+	status.LastError = strings.Join(problems, "; ")
+	if status.Healthy {
+		status.ConsecutiveFails = 0
+	}
+	return status, found
+}
+
+// EndpointStats is the per-endpoint view shown next to a proxy.
+type EndpointStats struct {
+	Address        string        `json:"address"`
+	Weight         int           `json:"weight"`
+	Status         string        `json:"status"`
+	Available      bool          `json:"available"` // Would get requests now
+	Health         *HealthStatus `json:"health,omitempty"`
+	Requests       int64         `json:"requests"` // Sent to the endpoint, answered or not
+	Errors         int64         `json:"errors"`
+	ActiveRequests int32         `json:"active_requests"`
+	CircuitState   string        `json:"circuit_state"`
+	LatencyP95Ms   float64       `json:"latency_p95_ms"`
+}
+
+// EndpointStats returns the counters of every endpoint, in configuration
+// order.
+func (p *ProxyInstance) EndpointStats() []EndpointStats {
+	out := make([]EndpointStats, 0, len(p.Endpoints))
+	for _, ep := range p.Endpoints {
+		t := ep.target
+		s := EndpointStats{
+			Address:        ep.Address,
+			Weight:         ep.Weight,
+			Status:         t.Stats.GetStatus(),
+			Available:      ep.available(),
+			Requests:       atomic.LoadInt64(&ep.TotalRequests),
+			Errors:         t.Stats.Errors.Load(),
+			ActiveRequests: atomic.LoadInt32(&ep.CurrentConns),
+			CircuitState:   t.circuitState(),
+			LatencyP95Ms:   float64(t.LatencyPercentiles().P95) / float64(time.Millisecond),
+		}
+		if hs, ok := t.Health(); ok {
+			s.Health = &hs
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// circuitState names the state of the proxy's circuit breaker.
+func (p *ProxyInstance) circuitState() string {
+	if p.circuitBreaker != nil {
+		if s, ok := p.circuitBreaker.GetMetrics()["state"].(string); ok {
+			return s
+		}
+	}
+	return "closed"
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"fmt"
+	"modbridge/pkg/logger"
+	"modbridge/pkg/modbus"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// endpointTarget builds the endpoint for one of several targets, giving up
+// quickly on a target that does not answer.
+func endpointTarget(targetAddr string, weight int) *TargetEndpoint {
+	p := NewProxyInstance("tx-test", targetAddr, "", targetAddr, 0, 5, 5, 0, logger.NewNullLogger(100), nil)
+	p.ReadTimeout = 200 * time.Millisecond
+	return NewTargetEndpoint(weight, p)
+}
+
+// exchange sends one request through the proxy and returns the answer.
+func exchange(t *testing.T, conn net.Conn, req []byte) []byte {
+	t.Helper()
+
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return resp
+}
+
+// TestTargetsFailover verifies that a read the primary does not answer is
+// served by the standby, while a write is not sent a second time.
+func TestTargetsFailover(t *testing.T) {
+	primary := silentTarget(t)
+	defer primary.Close()
+	var reads int64
+	standby := countingTarget(t, &reads, 0)
+	defer standby.Close()
+
+	p := startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Endpoints = []*TargetEndpoint{
+			endpointTarget(primary.Addr().String(), 0),
+			endpointTarget(standby.Addr().String(), 0),
+		}
+		p.EndpointPolicy = Failover
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	// The primary is still the first choice, so the write goes there only.
+	write, err := modbus.CreateWriteRequest(1, 3, 100, []uint16{42})
+	if err != nil {
+		t.Fatalf("failed to build write: %v", err)
+	}
+	if resp := exchange(t, conn, write); !modbus.IsExceptionResponse(resp) {
+		t.Errorf("write answered % X, want an exception: it must not reach the standby", resp)
+	}
+
+	resp := exchange(t, conn, modbus.CreateReadRequest(2, 3, 3, 100, 1))
+	if data, err := modbus.ParseReadResponse(resp); err != nil || data[0] != 3 {
+		t.Errorf("read answered % X (err %v), want the standby's data", resp, err)
+	}
+	if got := atomic.LoadInt64(&reads); got != 1 {
+		t.Errorf("standby saw %d reads, want 1", got)
+	}
+
+	stats := p.EndpointStats()
+	if len(stats) != 2 {
+		t.Fatalf("got %d endpoint stats, want 2", len(stats))
+	}
+	if stats[0].Requests != 2 || stats[0].Errors != 2 {
+		t.Errorf("primary: %d requests, %d errors, want 2 and 2", stats[0].Requests, stats[0].Errors)
+	}
+	if stats[1].Requests != 1 || stats[1].Errors != 0 {
+		t.Errorf("standby: %d requests, %d errors, want 1 and 0", stats[1].Requests, stats[1].Errors)
+	}
+}
+
+// TestTargetsRoundRobin verifies that the round-robin policy spreads the
+// requests evenly over healthy targets.
+func TestTargetsRoundRobin(t *testing.T) {
+	var readsA, readsB int64
+	targetA := countingTarget(t, &readsA, 0)
+	defer targetA.Close()
+	targetB := countingTarget(t, &readsB, 0)
+	defer targetB.Close()
+
+	p := startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Endpoints = []*TargetEndpoint{
+			endpointTarget(targetA.Addr().String(), 0),
+			endpointTarget(targetB.Addr().String(), 0),
+		}
+		p.EndpointPolicy = RoundRobin
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 4; i++ {
+		resp := exchange(t, conn, modbus.CreateReadRequest(uint16(i), 1, 3, uint16(i), 1))
+		if modbus.IsExceptionResponse(resp) {
+			t.Fatalf("read %d answered with an exception: % X", i, resp)
+		}
+	}
+	if a, b := atomic.LoadInt64(&readsA), atomic.LoadInt64(&readsB); a != 2 || b != 2 {
+		t.Errorf("targets saw %d and %d reads, want 2 each", a, b)
+	}
+}
+
+// TestTargetsIPHash verifies that the ip_hash policy keeps a client on one
+// target while different clients spread over the targets.
+func TestTargetsIPHash(t *testing.T) {
+	var readsA, readsB int64
+	targetA := countingTarget(t, &readsA, 0)
+	defer targetA.Close()
+	targetB := countingTarget(t, &readsB, 0)
+	defer targetB.Close()
+
+	p := startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Endpoints = []*TargetEndpoint{
+			endpointTarget(targetA.Addr().String(), 0),
+			endpointTarget(targetB.Addr().String(), 0),
+		}
+		p.EndpointPolicy = IPHash
+	})
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 4; i++ {
+		resp := exchange(t, conn, modbus.CreateReadRequest(uint16(i), 1, 3, uint16(i), 1))
+		if modbus.IsExceptionResponse(resp) {
+			t.Fatalf("read %d answered with an exception: % X", i, resp)
+		}
+	}
+	if a, b := atomic.LoadInt64(&readsA), atomic.LoadInt64(&readsB); a+b != 4 || (a != 0 && b != 0) {
+		t.Errorf("targets saw %d and %d reads, want all 4 on one", a, b)
+	}
+
+	lb := newEndpointBalancer(IPHash, []*TargetEndpoint{{Address: "a", IsHealthy: true}, {Address: "b", IsHealthy: true}})
+	used := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		client := fmt.Sprintf("192.168.1.%d", 10+i)
+		ep := lb.pick(client)
+		if again := lb.pick(client); again != ep {
+			t.Fatalf("client %s went to %s, then to %s", client, ep.Address, again.Address)
+		}
+		used[ep.Address] = true
+	}
+	if len(used) != 2 {
+		t.Errorf("20 clients all went to %v, want both targets used", used)
+	}
+}
@@ -8,6 +8,7 @@ package proxy
 import (
 	"context"
 	"fmt"
+	"modbridge/pkg/capture"
 	"modbridge/pkg/modbus"
 	"net"
 	"sync"
@@ -25,9 +26,10 @@ const maxStaleFrames = 8
 // target. Many Modbus devices — SolarEdge/SunSpec inverters, small RTU
 // gateways — silently drop requests that arrive back-to-back.
 type requestPacer struct {
-	mu   sync.Mutex
-	gap  time.Duration
-	next time.Time
+	mu     sync.Mutex
+	gap    time.Duration
+	next   time.Time
+	waited *atomic.Int64 // Sums up the waits in nanoseconds (nil = not counted)
 }
 
 // reserve claims the next slot and returns how long the caller has to wait for
@@ -47,6 +49,9 @@ func (rp *requestPacer) reserve() time.Duration {
 		wait = rp.next.Sub(now)
 	}
 	rp.next = now.Add(wait + rp.gap)
+	if rp.waited != nil {
+		rp.waited.Add(int64(wait))
+	}
 	return wait
 }
 
@@ -164,6 +169,9 @@ func (p *ProxyInstance) readMatchingResponse(conn net.Conn, req []byte, wantTxID
 
 		atomic.AddInt64(&p.staleResponses, 1)
 		p.log.Warn(p.ID, fmt.Sprintf("Discarding stale target response (transaction 0x%04X, expected 0x%04X)", gotTxID, wantTxID))
+		if c := p.capture.Load(); c != nil {
+			c.Record(capture.Frame{Time: time.Now(), Side: capture.SideTarget, Response: true, Src: conn.RemoteAddr().String(), Dst: conn.LocalAddr().String(), Data: resp, Error: "stale response discarded"})
+		}
 
 		if stale+1 >= maxStaleFrames {
 			return nil, fmt.Errorf("target out of sync: %d stale responses while waiting for transaction 0x%04X", stale+1, wantTxID)
@@ -0,0 +1,48 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"errors"
+	"modbridge/pkg/simulator"
+	"net"
+)
+
+// ProtocolVirtual answers from a simulated device held in memory
+// (pkg/simulator) instead of a device on the network.
+const ProtocolVirtual = "virtual"
+
+// errNoVirtualDevice is returned by a virtual proxy that was given no device.
+var errNoVirtualDevice = errors.New("virtual protocol requires a simulated device")
+
+// dialVirtual connects to the simulated device. The connection stands in for
+// the socket to a network target, so everything on the way — the pool,
+// pacing, retries, the split of large reads, calibration — runs against the
+// device exactly as it would against a real one, quirks included.
+func (p *ProxyInstance) dialVirtual() (net.Conn, error) {
+	if p.Virtual == nil {
+		return nil, errNoVirtualDevice
+	}
+	return p.Virtual.Connect(), nil
+}
+
+// stopVirtual ends the connections that are still open to the simulated
+// device. The device and what was written to it stay for the next start.
+func (p *ProxyInstance) stopVirtual() {
+	if p.Protocol == ProtocolVirtual && p.Virtual != nil {
+		p.Virtual.Disconnect()
+	}
+}
+
+// VirtualStats returns the counters of the simulated device, or nil if the
+// proxy has none.
+func (p *ProxyInstance) VirtualStats() *simulator.Stats {
+	if p.Protocol != ProtocolVirtual || p.Virtual == nil {
+		return nil
+	}
+	stats := p.Virtual.Stats()
+	return &stats
+}
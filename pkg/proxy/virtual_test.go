@@ -0,0 +1,142 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package proxy
+
+import (
+	"encoding/binary"
+	"modbridge/pkg/modbus"
+	"modbridge/pkg/simulator"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startVirtualTestProxy starts a proxy whose target is a simulated device.
+func startVirtualTestProxy(t *testing.T, device *simulator.Device, configure func(*ProxyInstance)) *ProxyInstance {
+	t.Helper()
+
+	return startTestProxy(t, "", func(p *ProxyInstance) {
+		p.Protocol = ProtocolVirtual
+		p.Virtual = device
+		if configure != nil {
+			configure(p)
+		}
+	})
+}
+
+// virtualExchange sends one request through the proxy and returns the
+// answer.
+func virtualExchange(t *testing.T, conn net.Conn, req []byte) []byte {
+	t.Helper()
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatalf("set deadline failed: %v", err)
+	}
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return resp
+}
+
+// virtualRead reads one holding register through the proxy.
+func virtualRead(t *testing.T, conn net.Conn, txID, addr uint16) uint16 {
+	t.Helper()
+	resp := virtualExchange(t, conn, modbus.CreateReadRequest(txID, 1, 3, addr, 1))
+	data, err := modbus.ParseReadResponse(resp)
+	if err != nil || len(data) != 2 {
+		t.Fatalf("read of register %d answered % x (%v)", addr, resp, err)
+	}
+	return binary.BigEndian.Uint16(data)
+}
+
+// TestVirtualKeepsWritesAcrossRestart verifies that a virtual proxy answers
+// from its device, and that what a client wrote is still there after the
+// proxy was stopped and started again.
+func TestVirtualKeepsWritesAcrossRestart(t *testing.T) {
+	device := simulator.New([]simulator.Register{{Address: 100, Count: 2, Value: 42}}, simulator.Quirks{})
+	p := startVirtualTestProxy(t, device, nil)
+	defer p.Stop()
+
+	conn, err := net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	if got := virtualRead(t, conn, 1, 100); got != 42 {
+		t.Fatalf("register 100 = %d, want 42", got)
+	}
+	write, _ := modbus.CreateWriteRequest(2, 1, 101, []uint16{7})
+	if resp := virtualExchange(t, conn, write); modbus.IsExceptionResponse(resp) {
+		t.Fatalf("write answered % x", resp)
+	}
+	if resp := virtualExchange(t, conn, modbus.CreateReadRequest(3, 1, 3, 102, 1)); !modbus.IsExceptionResponse(resp) || resp[8] != modbus.ExceptionIllegalDataAddress {
+		t.Fatalf("read of an unknown register answered % x, want illegal data address", resp)
+	}
+	conn.Close()
+
+	p.Stop()
+	if s := device.Stats(); s.Sessions != 0 {
+		t.Fatalf("%d sessions to the device are still open after Stop", s.Sessions)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatalf("failed to restart proxy: %v", err)
+	}
+	conn, err = net.Dial("tcp", p.ListenAddr)
+	if err != nil {
+		t.Fatalf("failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	if got := virtualRead(t, conn, 4, 101); got != 7 {
+		t.Fatalf("register 101 after the restart = %d, want the 7 written", got)
+	}
+}
+
+// TestVirtualSingleSessionBehindOneTargetConn verifies the quirk and the
+// setting that deals with it: a device that answers one session only serves
+// any number of clients through a proxy that keeps one connection to it.
+func TestVirtualSingleSessionBehindOneTargetConn(t *testing.T) {
+	device := simulator.New([]simulator.Register{{Address: 0, Value: 5}}, simulator.Quirks{SingleSession: true})
+	p := startVirtualTestProxy(t, device, func(p *ProxyInstance) { p.MaxTargetConns = 1 })
+	defer p.Stop()
+
+	var wg sync.WaitGroup
+	for c := 0; c < 4; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", p.ListenAddr)
+			if err != nil {
+				t.Errorf("failed to connect to proxy: %v", err)
+				return
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+			for i := 0; i < 10; i++ {
+				if _, err := conn.Write(modbus.CreateReadRequest(uint16(c*100+i), 1, 3, 0, 1)); err != nil {
+					t.Errorf("client %d: write failed: %v", c, err)
+					return
+				}
+				resp, err := modbus.ReadFrame(conn)
+				if err != nil {
+					t.Errorf("client %d: read failed: %v", c, err)
+					return
+				}
+				if data, err := modbus.ParseReadResponse(resp); err != nil || len(data) != 2 || data[1] != 5 {
+					t.Errorf("client %d read % x, want 5", c, resp)
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// Identical reads in flight together may be coalesced into one.
+	if s := device.Stats(); s.Dropped != 0 || s.Answered == 0 || s.Answered > 40 {
+		t.Errorf("device stats = %+v, want every request answered and none dropped", s)
+	}
+}
@@ -61,6 +61,16 @@ const (
 	// Logs permissions
 	PermLogsView   Permission = "logs:view"
 	PermLogsExport Permission = "logs:export"
+
+	// Modbus permissions, for clients on a Modbus/TCP Security listener
+	PermModbusRead  Permission = "modbus:read"
+	PermModbusWrite Permission = "modbus:write"
+
+	// Alert permissions: see the alerts and rules, acknowledge and resolve
+	// alerts, change rules and webhooks
+	PermAlertView        Permission = "alert:view"
+	PermAlertAcknowledge Permission = "alert:acknowledge"
+	PermAlertManage      Permission = "alert:manage"
 )
 
 // RolePermissions defines the permissions for each role
@@ -73,6 +83,8 @@ var RolePermissions = map[Role][]Permission{
 		PermUserView, PermUserCreate, PermUserEdit, PermUserDelete,
 		PermAuditView, PermAuditExport,
 		PermLogsView, PermLogsExport,
+		PermModbusRead, PermModbusWrite,
+		PermAlertView, PermAlertAcknowledge, PermAlertManage,
 	},
 	RoleTechniker: {
 		PermProxyView, PermProxyCreate, PermProxyEdit, PermProxyDelete, PermProxyControl,
@@ -80,6 +92,8 @@ var RolePermissions = map[Role][]Permission{
 		PermConfigView,
 		PermSystemView,
 		PermLogsView,
+		PermModbusRead, PermModbusWrite,
+		PermAlertView, PermAlertAcknowledge, PermAlertManage,
 	},
 	RoleBenutzer: {
 		PermProxyView, PermProxyControl,
@@ -87,6 +101,8 @@ var RolePermissions = map[Role][]Permission{
 		PermConfigView,
 		PermSystemView,
 		PermLogsView,
+		PermModbusRead,
+		PermAlertView, PermAlertAcknowledge,
 	},
 	RoleAuditor: {
 		PermProxyView,
@@ -95,6 +111,8 @@ var RolePermissions = map[Role][]Permission{
 		PermSystemView,
 		PermAuditView, PermAuditExport,
 		PermLogsView, PermLogsExport,
+		PermModbusRead,
+		PermAlertView,
 	},
 }
 
@@ -21,6 +21,13 @@ func TestHasPermission(t *testing.T) {
 		{RoleAuditor, PermAuditExport, true},
 		{RoleAuditor, PermProxyControl, false}, // auditor is read-only
 		{RoleTechniker, PermProxyDelete, true},
+		{RoleTechniker, PermModbusWrite, true},
+		{RoleBenutzer, PermModbusRead, true},
+		{RoleBenutzer, PermModbusWrite, false}, // Modbus clients of this role only read
+		{RoleBenutzer, PermAlertAcknowledge, true},
+		{RoleBenutzer, PermAlertManage, false}, // users handle alerts, not rules
+		{RoleAuditor, PermAlertView, true},
+		{RoleAuditor, PermAlertAcknowledge, false},
 		{Role("unknown"), PermProxyView, false}, // unknown role → deny all
 	}
 	for _, c := range cases {
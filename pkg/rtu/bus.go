@@ -0,0 +1,339 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package rtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnitSuspended is returned for a unit that failed too often in a row and
+// is skipped until its backoff has run out.
+var ErrUnitSuspended = errors.New("unit suspended after repeated failures")
+
+// ErrBusClosed is returned for requests on a bus nobody has opened.
+var ErrBusClosed = errors.New("serial bus is not open")
+
+// Default tuning of a bus, used for zero values in BusOptions.
+const (
+	DefaultUnitFailureThreshold = 3
+	DefaultUnitBackoff          = 30 * time.Second
+	defaultBusQueueSize         = 64
+)
+
+// BusOptions controls how a bus treats units that stop answering.
+type BusOptions struct {
+	// UnitTimeout caps a single exchange with one unit (0 = Config.Timeout).
+	// On a multi-drop line every second spent waiting for a silent unit is a
+	// second every other unit waits too, so this is usually well below the
+	// timeout a client would accept.
+	UnitTimeout time.Duration
+	// FailureThreshold is the number of consecutive failures after which a
+	// unit is suspended (0 = DefaultUnitFailureThreshold).
+	FailureThreshold int
+	// Backoff is how long a suspended unit is skipped before the next request
+	// is let through as a probe (0 = DefaultUnitBackoff).
+	Backoff time.Duration
+}
+
+// UnitStats is the view of one unit ID on a bus.
+type UnitStats struct {
+	UnitID              byte      `json:"unit_id"`
+	Requests            uint64    `json:"requests"`
+	Failures            uint64    `json:"failures"`
+	Timeouts            uint64    `json:"timeouts"`
+	Exceptions          uint64    `json:"exceptions"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	SuspendedUntil      time.Time `json:"suspended_until,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Bus owns one serial line and lets several users share it. Every request
+// goes through a single queue and is sent by one worker, so exchanges never
+// overlap and the RTU client keeps the t3.5 silence between frames no matter
+// how many proxies address units on the line. Units are tracked one by one: a
+// unit that keeps failing is suspended for a while, so one dead slave costs
+// the others one timeout per backoff period instead of one per request.
+//
+// A bus opens its port when the first user acquires it and closes it when the
+// last one releases it.
+type Bus struct {
+	cfg  *Config
+	opts BusOptions
+	open func(cfg *Config) (SerialPort, error)
+
+	mu     sync.Mutex
+	refs   int
+	client *RTUClient
+	queue  chan *busRequest
+	done   chan struct{}
+	wg     sync.WaitGroup
+	units  map[byte]*UnitStats
+}
+
+type busRequest struct {
+	ctx     context.Context
+	req     *Request
+	timeout time.Duration
+	reply   chan busReply
+}
+
+type busReply struct {
+	resp *Response
+	err  error
+}
+
+// NewBus creates a bus for the line described by cfg. open opens the port
+// (nil = OpenSerialPort). The port is not opened before Acquire.
+func NewBus(cfg *Config, opts BusOptions, open func(cfg *Config) (SerialPort, error)) *Bus {
+	if open == nil {
+		open = OpenSerialPort
+	}
+	if opts.UnitTimeout <= 0 {
+		opts.UnitTimeout = cfg.Timeout
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultUnitFailureThreshold
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultUnitBackoff
+	}
+	return &Bus{
+		cfg:   cfg,
+		opts:  opts,
+		open:  open,
+		units: make(map[byte]*UnitStats),
+	}
+}
+
+// Device returns the serial device of the bus.
+func (b *Bus) Device() string {
+	return b.cfg.Device
+}
+
+// Acquire registers a user of the bus, opening the port for the first one.
+func (b *Bus) Acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.refs > 0 {
+		b.refs++
+		return nil
+	}
+
+	port, err := b.open(b.cfg)
+	if err != nil {
+		return fmt.Errorf("open serial line %s: %w", b.cfg.Device, err)
+	}
+	client, err := NewRTUClient(b.cfg, port)
+	if err != nil {
+		port.Close()
+		return err
+	}
+
+	b.client = client
+	b.queue = make(chan *busRequest, defaultBusQueueSize)
+	b.done = make(chan struct{})
+	b.refs = 1
+
+	b.wg.Add(1)
+	go b.run(client, b.queue, b.done)
+	return nil
+}
+
+// Release unregisters a user of the bus. The last one closes the port.
+func (b *Bus) Release() error {
+	b.mu.Lock()
+	if b.refs == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	b.refs--
+	if b.refs > 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	client, done := b.client, b.done
+	b.client, b.queue, b.done = nil, nil, nil
+	b.mu.Unlock()
+
+	close(done)
+	b.wg.Wait()
+	return client.Close()
+}
+
+// IsOpen reports whether the port is currently open.
+func (b *Bus) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.refs > 0
+}
+
+// Do queues a request for the bus and waits for its answer. timeout caps the
+// exchange itself (0 = the bus's unit timeout); ctx bounds the whole call,
+// time in the queue included. A Modbus exception comes back as
+// *ExceptionError, like from the RTU client.
+func (b *Bus) Do(ctx context.Context, req *Request, timeout time.Duration) (*Response, error) {
+	if err := b.checkUnit(req.SlaveID); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	queue, done := b.queue, b.done
+	b.mu.Unlock()
+	if queue == nil {
+		return nil, ErrBusClosed
+	}
+
+	r := &busRequest{ctx: ctx, req: req, timeout: timeout, reply: make(chan busReply, 1)}
+	select {
+	case queue <- r:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-done:
+		return nil, ErrBusClosed
+	}
+
+	select {
+	case rep := <-r.reply:
+		return rep.resp, rep.err
+	case <-ctx.Done():
+		// The worker still answers into the buffered channel; nobody waits.
+		return nil, ctx.Err()
+	case <-done:
+		select {
+		case rep := <-r.reply:
+			return rep.resp, rep.err
+		default:
+			return nil, ErrBusClosed
+		}
+	}
+}
+
+// Units returns the per-unit counters, ordered by unit ID.
+func (b *Bus) Units() []UnitStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]UnitStats, 0, len(b.units))
+	for _, u := range b.units {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UnitID < out[j].UnitID })
+	return out
+}
+
+// run is the bus worker: the only goroutine that talks on the line.
+func (b *Bus) run(client *RTUClient, queue chan *busRequest, done chan struct{}) {
+	defer b.wg.Done()
+	for {
+		select {
+		case r := <-queue:
+			b.serve(client, r)
+		case <-done:
+			for {
+				select {
+				case r := <-queue:
+					r.reply <- busReply{err: ErrBusClosed}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// serve performs one queued exchange.
+func (b *Bus) serve(client *RTUClient, r *busRequest) {
+	// A caller that has given up does not get bus time: the line is the
+	// scarce resource every other unit is waiting for.
+	if err := r.ctx.Err(); err != nil {
+		r.reply <- busReply{err: err}
+		return
+	}
+	// The unit may have been suspended while this request sat in the queue.
+	if err := b.checkUnit(r.req.SlaveID); err != nil {
+		r.reply <- busReply{err: err}
+		return
+	}
+
+	timeout := r.timeout
+	if timeout <= 0 || timeout > b.opts.UnitTimeout {
+		timeout = b.opts.UnitTimeout
+	}
+	if deadline, ok := r.ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	resp, err := client.SendRequestTimeout(r.req, timeout)
+	b.record(r.req.SlaveID, err)
+
+	var exception *ExceptionError
+	if err != nil && !errors.As(err, &exception) {
+		// Whatever the unit sends late, or half a frame from a garbled
+		// answer, would otherwise be read as the start of the next reply.
+		client.discardInput()
+	}
+	r.reply <- busReply{resp: resp, err: err}
+}
+
+// checkUnit fails fast for a suspended unit.
+func (b *Bus) checkUnit(unitID byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u, ok := b.units[unitID]
+	if !ok || u.SuspendedUntil.IsZero() {
+		return nil
+	}
+	if time.Now().Before(u.SuspendedUntil) {
+		return fmt.Errorf("unit %d: %w (until %s)", unitID, ErrUnitSuspended, u.SuspendedUntil.Format(time.RFC3339))
+	}
+	// Backoff is over: let this request through as the probe. A failure
+	// suspends the unit again straight away.
+	u.SuspendedUntil = time.Time{}
+	return nil
+}
+
+// record updates a unit's counters after an exchange. An exception counts as
+// a sign of life: the unit answered, it just did not like the request.
+func (b *Bus) record(unitID byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u, ok := b.units[unitID]
+	if !ok {
+		u = &UnitStats{UnitID: unitID}
+		b.units[unitID] = u
+	}
+	u.Requests++
+
+	var exception *ExceptionError
+	switch {
+	case err == nil:
+		u.ConsecutiveFailures = 0
+	case errors.As(err, &exception):
+		u.Exceptions++
+		u.ConsecutiveFailures = 0
+	default:
+		u.Failures++
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			u.Timeouts++
+		}
+		u.LastError = err.Error()
+		u.ConsecutiveFailures++
+		if u.ConsecutiveFailures >= b.opts.FailureThreshold {
+			u.SuspendedUntil = time.Now().Add(b.opts.Backoff)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package rtu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// busSlave simulates the devices on a multi-drop line. Every unit answers a
+// read of one register with its own ID; units listed in silent never answer,
+// units listed in late answer only after the given delay.
+type busSlave struct {
+	silent   map[byte]bool
+	late     map[byte]time.Duration
+	requests int64
+	gapErrs  int64
+	minGap   time.Duration
+}
+
+func (s *busSlave) open(t *testing.T) func(cfg *Config) (SerialPort, error) {
+	return func(cfg *Config) (SerialPort, error) {
+		busSide, deviceSide := net.Pipe()
+		go s.serve(deviceSide)
+		return busSide, nil
+	}
+}
+
+func (s *busSlave) serve(conn net.Conn) {
+	defer conn.Close()
+	var lastAnswer time.Time
+	for {
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		if !lastAnswer.IsZero() && time.Since(lastAnswer) < s.minGap {
+			atomic.AddInt64(&s.gapErrs, 1)
+		}
+		atomic.AddInt64(&s.requests, 1)
+
+		unitID := req[0]
+		if s.silent[unitID] {
+			continue
+		}
+		if delay := s.late[unitID]; delay > 0 {
+			time.Sleep(delay)
+		}
+
+		resp := []byte{unitID, req[1], 2, 0, unitID}
+		resp = append(resp, calculateCRC(resp)...)
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+		lastAnswer = time.Now()
+	}
+}
+
+func newTestBus(t *testing.T, slave *busSlave, opts BusOptions) *Bus {
+	t.Helper()
+	return newTestBusWithGap(t, slave, opts, FrameGap(9600))
+}
+
+func newTestBusWithGap(t *testing.T, slave *busSlave, opts BusOptions, gap time.Duration) *Bus {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Device = "/dev/ttyFAKE0"
+	cfg.InterFrameDelay = gap
+	slave.minGap = gap
+
+	bus := NewBus(cfg, opts, slave.open(t))
+	if err := bus.Acquire(); err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	t.Cleanup(func() { bus.Release() })
+	return bus
+}
+
+func readRequest(unitID byte) *Request {
+	return &Request{SlaveID: unitID, Function: 0x03, Data: []byte{0, 0, 0, 1}}
+}
+
+// TestBusSerializesUsers verifies that concurrent users of one bus get their
+// own answers and that the line keeps its t3.5 silence between frames.
+func TestBusSerializesUsers(t *testing.T) {
+	slave := &busSlave{}
+	bus := newTestBus(t, slave, BusOptions{UnitTimeout: time.Second})
+
+	var wg sync.WaitGroup
+	var wrong int64
+	for unit := byte(1); unit <= 4; unit++ {
+		wg.Add(1)
+		go func(unit byte) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				resp, err := bus.Do(context.Background(), readRequest(unit), 0)
+				if err != nil || resp.SlaveID != unit || resp.Data[1] != unit {
+					atomic.AddInt64(&wrong, 1)
+				}
+			}
+		}(unit)
+	}
+	wg.Wait()
+
+	if wrong != 0 {
+		t.Errorf("%d requests got an error or another unit's answer", wrong)
+	}
+	if got := atomic.LoadInt64(&slave.requests); got != 20 {
+		t.Errorf("line saw %d requests, want 20", got)
+	}
+	if got := atomic.LoadInt64(&slave.gapErrs); got != 0 {
+		t.Errorf("%d requests arrived without the inter-frame silence", got)
+	}
+}
+
+// TestBusSuspendsDeadUnit verifies that a unit that stops answering is skipped
+// after the failure threshold, so it no longer costs the others a timeout.
+func TestBusSuspendsDeadUnit(t *testing.T) {
+	slave := &busSlave{silent: map[byte]bool{9: true}}
+	bus := newTestBus(t, slave, BusOptions{
+		UnitTimeout:      50 * time.Millisecond,
+		FailureThreshold: 2,
+		Backoff:          time.Minute,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := bus.Do(context.Background(), readRequest(9), 0); err == nil {
+			t.Fatal("silent unit answered")
+		}
+	}
+
+	start := time.Now()
+	_, err := bus.Do(context.Background(), readRequest(9), 0)
+	if !errors.Is(err, ErrUnitSuspended) {
+		t.Fatalf("third request to the dead unit: got %v, want ErrUnitSuspended", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("suspended unit took %v to fail, want an immediate answer", elapsed)
+	}
+	if got := atomic.LoadInt64(&slave.requests); got != 2 {
+		t.Errorf("line saw %d requests, want 2 (the suspended one must not reach it)", got)
+	}
+
+	if resp, err := bus.Do(context.Background(), readRequest(1), 0); err != nil || resp.SlaveID != 1 {
+		t.Fatalf("healthy unit on the same line: resp %+v, err %v", resp, err)
+	}
+
+	for _, u := range bus.Units() {
+		if u.UnitID == 9 {
+			if u.Timeouts != 2 || u.ConsecutiveFailures != 2 || u.SuspendedUntil.IsZero() {
+				t.Errorf("dead unit stats = %+v, want 2 timeouts and a suspension", u)
+			}
+		}
+	}
+}
+
+// TestBusDiscardsLateAnswer verifies that an answer arriving after its
+// request timed out is not taken for the reply to the next request.
+func TestBusDiscardsLateAnswer(t *testing.T) {
+	// The answer comes 10ms after the timeout, while the bus is still
+	// listening for the line to go quiet (one 30ms frame gap).
+	slave := &busSlave{late: map[byte]time.Duration{5: 50 * time.Millisecond}}
+	bus := newTestBusWithGap(t, slave, BusOptions{UnitTimeout: 40 * time.Millisecond}, 30*time.Millisecond)
+
+	if _, err := bus.Do(context.Background(), readRequest(5), 0); err == nil {
+		t.Fatal("late unit answered within its timeout")
+	}
+
+	resp, err := bus.Do(context.Background(), readRequest(2), 0)
+	if err != nil {
+		t.Fatalf("request after the late answer failed: %v", err)
+	}
+	if resp.SlaveID != 2 {
+		t.Errorf("got an answer from unit %d, want unit 2", resp.SlaveID)
+	}
+}
+
+// TestBusOpensForFirstUserOnly verifies the reference counting: the line
+// stays open while any user remains.
+func TestBusOpensForFirstUserOnly(t *testing.T) {
+	var opens int64
+	slave := &busSlave{}
+	cfg := DefaultConfig()
+	cfg.Device = "/dev/ttyFAKE0"
+	bus := NewBus(cfg, BusOptions{}, func(cfg *Config) (SerialPort, error) {
+		atomic.AddInt64(&opens, 1)
+		return slave.open(t)(cfg)
+	})
+
+	if _, err := bus.Do(context.Background(), readRequest(1), 0); !errors.Is(err, ErrBusClosed) {
+		t.Fatalf("request on a closed bus: got %v, want ErrBusClosed", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := bus.Acquire(); err != nil {
+			t.Fatalf("Acquire() failed: %v", err)
+		}
+	}
+	if opens != 1 {
+		t.Errorf("line opened %d times for two users, want 1", opens)
+	}
+
+	bus.Release()
+	if _, err := bus.Do(context.Background(), readRequest(1), 0); err != nil {
+		t.Errorf("request with one user left failed: %v", err)
+	}
+
+	bus.Release()
+	if bus.IsOpen() {
+		t.Error("bus still open after the last user left")
+	}
+}
@@ -40,6 +40,20 @@ type Config struct {
 	SlaveID byte `json:"slave_id" yaml:"slave_id"`
 }
 
+// FrameGap returns the t3.5 silence the Modbus serial line specification
+// requires between two frames at the given baud rate: three and a half
+// character times of 11 bits each. Above 19200 baud the specification fixes it
+// at 1.75 ms, because the computed value would be too short to time reliably.
+func FrameGap(baudRate int) time.Duration {
+	if baudRate <= 0 {
+		baudRate = 9600
+	}
+	if baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(float64(time.Second) * 3.5 * 11 / float64(baudRate))
+}
+
 // DefaultConfig returns default RTU configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -290,6 +304,13 @@ func (c *RTUClient) WriteMultipleRegisters(startAddr uint16, values []uint16) er
 
 // SendRequest sends a Modbus RTU request and waits for response
 func (c *RTUClient) SendRequest(req *Request) (*Response, error) {
+	return c.SendRequestTimeout(req, c.config.Timeout)
+}
+
+// SendRequestTimeout is SendRequest with a caller-supplied deadline for this
+// one exchange. A proxy forwarding on behalf of a client has a budget for the
+// whole request, retries included, and cannot let a single attempt outlive it.
+func (c *RTUClient) SendRequestTimeout(req *Request, timeout time.Duration) (*Response, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -308,7 +329,7 @@ func (c *RTUClient) SendRequest(req *Request) (*Response, error) {
 	}
 
 	// Set deadline
-	deadline := time.Now().Add(c.config.Timeout)
+	deadline := time.Now().Add(timeout)
 	if err := c.port.SetDeadline(deadline); err != nil {
 		return nil, fmt.Errorf("failed to set deadline: %w", err)
 	}
@@ -329,6 +350,37 @@ func (c *RTUClient) SendRequest(req *Request) (*Response, error) {
 	return resp, nil
 }
 
+// Bounds for discardInput: how long the line must stay quiet before it counts
+// as idle, and how long to keep reading a line that never does.
+const (
+	minDiscardQuiet = 10 * time.Millisecond
+	maxDiscardTime  = 500 * time.Millisecond
+)
+
+// discardInput reads and drops whatever arrives on the line until it has been
+// quiet for a while. After a timeout or a broken frame the device may still be
+// sending; without this, those bytes would be taken for the next response.
+func (c *RTUClient) discardInput() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	quiet := c.config.InterFrameDelay
+	if quiet < minDiscardQuiet {
+		quiet = minDiscardQuiet
+	}
+
+	buf := make([]byte, 256)
+	stop := time.Now().Add(maxDiscardTime)
+	for time.Now().Before(stop) {
+		if err := c.port.SetDeadline(time.Now().Add(quiet)); err != nil {
+			return
+		}
+		if _, err := c.port.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
 // buildFrame builds an RTU frame with CRC
 func (c *RTUClient) buildFrame(req *Request) ([]byte, error) {
 	length := 2 + len(req.Data) // SlaveID + Function + Data + CRC
@@ -0,0 +1,109 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+//go:build linux
+
+package rtu
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cmspar selects mark/space parity together with PARENB. The syscall package
+// does not define it; the value is the same on every Linux architecture.
+const cmspar = 0x40000000
+
+// baudRates maps the rates ValidateConfig accepts to their termios constants.
+var baudRates = map[int]uint32{
+	300:    syscall.B300,
+	600:    syscall.B600,
+	1200:   syscall.B1200,
+	2400:   syscall.B2400,
+	4800:   syscall.B4800,
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// OpenSerialPort opens cfg.Device in raw mode with the configured line
+// settings. The descriptor stays non-blocking so the runtime poller serves it:
+// that is what makes SetDeadline work, and a read that never returns on a
+// silent bus is exactly what a deadline is there to prevent.
+func OpenSerialPort(cfg *Config) (SerialPort, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	speed, ok := baudRates[cfg.BaudRate]
+	if !ok {
+		return nil, fmt.Errorf("invalid baud rate: %d", cfg.BaudRate)
+	}
+
+	cflag := speed | syscall.CREAD | syscall.CLOCAL
+	switch cfg.DataBits {
+	case 5:
+		cflag |= syscall.CS5
+	case 6:
+		cflag |= syscall.CS6
+	case 7:
+		cflag |= syscall.CS7
+	case 8, 0:
+		cflag |= syscall.CS8
+	default:
+		return nil, fmt.Errorf("invalid data bits: %d (must be 5-8)", cfg.DataBits)
+	}
+	switch cfg.Parity {
+	case "None", "":
+	case "Odd":
+		cflag |= syscall.PARENB | syscall.PARODD
+	case "Even":
+		cflag |= syscall.PARENB
+	case "Mark":
+		cflag |= syscall.PARENB | syscall.PARODD | cmspar
+	case "Space":
+		cflag |= syscall.PARENB | cmspar
+	default:
+		return nil, fmt.Errorf("invalid parity: %s", cfg.Parity)
+	}
+	if cfg.StopBits == 2 {
+		cflag |= syscall.CSTOPB
+	}
+
+	f, err := os.OpenFile(cfg.Device, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", cfg.Device, err)
+	}
+
+	// Raw mode: no echo, no line discipline, no translation of CR/LF — a
+	// Modbus frame is binary and every byte has to arrive as it was sent.
+	// The speed travels in the CBAUD bits of Cflag; the separate speed fields
+	// do not exist on every architecture and TCSETS ignores them.
+	termios := syscall.Termios{Cflag: cflag}
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+
+	raw, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("configure %s: %w", cfg.Device, err)
+	}
+	var ioctlErr syscall.Errno
+	if err := raw.Control(func(fd uintptr) {
+		_, _, ioctlErr = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(&termios)))
+	}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("configure %s: %w", cfg.Device, err)
+	}
+	if ioctlErr != 0 {
+		f.Close()
+		return nil, fmt.Errorf("configure %s: %w", cfg.Device, ioctlErr)
+	}
+
+	return f, nil
+}
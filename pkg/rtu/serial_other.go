@@ -0,0 +1,19 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+//go:build !linux
+
+package rtu
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// OpenSerialPort is only implemented on Linux, which is where the RS-485
+// adapters ModBridge drives are attached in practice.
+func OpenSerialPort(cfg *Config) (SerialPort, error) {
+	return nil, fmt.Errorf("serial ports are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package simulator
+
+import (
+	"modbridge/pkg/capture"
+	"modbridge/pkg/modbus"
+	"sort"
+	"time"
+)
+
+// FromCapture turns the reads recorded in a capture into replayed registers:
+// every address a client read takes the values it was answered with, at the
+// pace they were recorded, and all of them start over together once the
+// capture is through. The addresses are the ones the clients used. Unit IDs
+// are not told apart, so a capture of one device makes the best replay.
+func FromCapture(frames []capture.Frame) []Register {
+	samples := make(map[cellKey][]Sample)
+	var first, last time.Time
+	reads := 0
+	for _, ex := range capture.ClientExchanges(frames) {
+		if ex.Response == nil || modbus.IsExceptionResponse(ex.Response.Data) {
+			continue
+		}
+		_, _, fc, addr, count, err := modbus.ParseReadRequest(ex.Request.Data)
+		if err != nil || !modbus.IsReadFunction(fc) || int(addr)+int(count) > 0x10000 {
+			continue
+		}
+		data, err := modbus.ParseReadResponse(ex.Response.Data)
+		if err != nil {
+			continue
+		}
+		table, bits := readTable(fc)
+		values := unpack(data, int(count), bits)
+		if values == nil {
+			continue
+		}
+
+		at := ex.Request.Time
+		if reads == 0 {
+			first = at
+		}
+		last = at
+		reads++
+		for i, v := range values {
+			k := cellKey{table, addr + uint16(i)}
+			s := samples[k]
+			if n := len(s); n > 0 && s[n-1].Value == v {
+				continue // Still the same value
+			}
+			samples[k] = append(s, Sample{At: at.Sub(first), Value: v})
+		}
+	}
+	if reads == 0 {
+		return nil
+	}
+
+	// One pass lasts the capture and one more gap between two reads, so the
+	// last values hold about as long as the others did.
+	span := last.Sub(first)
+	gap := DefaultInterval
+	if reads > 1 && span > 0 {
+		gap = span / time.Duration(reads-1)
+	}
+	keys := make([]cellKey, 0, len(samples))
+	for k := range samples {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].table != keys[j].table {
+			return keys[i].table < keys[j].table
+		}
+		return keys[i].addr < keys[j].addr
+	})
+	registers := make([]Register, 0, len(keys))
+	for _, k := range keys {
+		registers = append(registers, Register{
+			Table:   k.table,
+			Address: k.addr,
+			Kind:    Replay,
+			Period:  span + gap,
+			Samples: samples[k],
+		})
+	}
+	return registers
+}
+
+// unpack splits the data of a read response into count values, or returns
+// nil if it holds too few.
+func unpack(data []byte, count int, bits bool) []int {
+	if bits {
+		if len(data) < (count+7)/8 {
+			return nil
+		}
+		values := make([]int, count)
+		for i := range values {
+			values[i] = int(data[i/8]>>(i%8)) & 1
+		}
+		return values
+	}
+	if len(data) < 2*count {
+		return nil
+	}
+	values := make([]int, count)
+	for i := range values {
+		values[i] = int(data[2*i])<<8 | int(data[2*i+1])
+	}
+	return values
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package simulator
+
+import (
+	"encoding/binary"
+	"modbridge/pkg/modbus"
+	"net"
+	"time"
+)
+
+// Limits of one request, from the Modbus specification.
+const (
+	maxReadRegisters  = 125
+	maxReadBits       = 2000
+	maxWriteRegisters = 123
+	maxWriteBits      = 1968
+)
+
+// Connect opens a Modbus TCP connection to the device. It lives in memory:
+// there is no port, and nothing outside the process can reach the device.
+// Asked for its addresses, it names the device 127.0.0.1:502 and gives the
+// client a port of its own, so captures of it read like those of a socket.
+func (d *Device) Connect() net.Conn {
+	client, server := net.Pipe()
+	d.mu.Lock()
+	d.conns[server] = struct{}{}
+	d.lastPort++
+	port := 49152 + d.lastPort%16384
+	d.mu.Unlock()
+	d.wg.Add(1)
+	go d.serve(server)
+
+	device := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 502}
+	local := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	return pipeConn{Conn: client, local: local, remote: device}
+}
+
+// pipeConn is the client end of a connection to the device, with the
+// addresses of a socket.
+type pipeConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+// Disconnect ends every open connection. The registers keep their values.
+func (d *Device) Disconnect() {
+	d.mu.Lock()
+	for c := range d.conns {
+		c.Close()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *Device) serve(conn net.Conn) {
+	defer d.wg.Done()
+	defer func() {
+		d.mu.Lock()
+		delete(d.conns, conn)
+		if d.session == conn {
+			d.session = nil
+		}
+		d.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		req, err := modbus.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		resp := d.handle(conn, req)
+		if resp == nil {
+			continue
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle answers one request frame, or returns nil when the device leaves it
+// unanswered.
+func (d *Device) handle(conn net.Conn, req []byte) []byte {
+	d.requests.Add(1)
+	if len(req) < 8 {
+		d.dropped.Add(1)
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	q := d.quirks
+	if q.SingleSession {
+		if d.session == nil {
+			d.session = conn
+		}
+		if d.session != conn {
+			d.dropped.Add(1)
+			return nil
+		}
+	}
+	if q.MinGap > 0 && !d.lastReply.IsZero() && now.Sub(d.lastReply) < q.MinGap {
+		d.dropped.Add(1)
+		return nil
+	}
+	if q.DropRate > 0 && d.rand.Float64() < q.DropRate {
+		d.dropped.Add(1)
+		return nil
+	}
+	d.lastReply = now
+	d.answered.Add(1)
+
+	if q.ExceptionRate > 0 && d.rand.Float64() < q.ExceptionRate {
+		code := q.ExceptionCode
+		if code == 0 {
+			code = modbus.ExceptionServerDeviceBusy
+		}
+		d.exceptions.Add(1)
+		return modbus.CreateExceptionResponse(req, code)
+	}
+
+	pdu, code := d.answerLocked(req[7], req[8:], now)
+	if code != 0 {
+		d.exceptions.Add(1)
+		return modbus.CreateExceptionResponse(req, code)
+	}
+	resp := make([]byte, modbus.MBAPHeaderLength+2+len(pdu))
+	copy(resp, req[:4])
+	binary.BigEndian.PutUint16(resp[4:6], uint16(2+len(pdu)))
+	resp[6], resp[7] = req[6], req[7]
+	copy(resp[8:], pdu)
+	return resp
+}
+
+// answerLocked carries out a request on the registers and returns the data
+// of the response, or the exception code it earns.
+func (d *Device) answerLocked(fc byte, data []byte, now time.Time) ([]byte, byte) {
+	switch fc {
+	case modbus.FuncReadCoils, modbus.FuncReadDiscreteInputs,
+		modbus.FuncReadHoldingRegisters, modbus.FuncReadInputRegisters:
+		if len(data) != 4 {
+			return nil, modbus.ExceptionIllegalDataValue
+		}
+		addr, count := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		table, bits := readTable(fc)
+		limit := maxReadRegisters
+		if bits {
+			limit = maxReadBits
+		}
+		if count == 0 || int(count) > limit {
+			return nil, modbus.ExceptionIllegalDataValue
+		}
+		cells, code := d.cellsLocked(table, addr, count)
+		if code != 0 {
+			return nil, code
+		}
+		values := make([]uint16, len(cells))
+		for i, c := range cells {
+			values[i] = d.valueLocked(c, now)
+		}
+		if bits {
+			return append([]byte{byte((count + 7) / 8)}, packBits(values)...), 0
+		}
+		out := make([]byte, 1+2*len(values))
+		out[0] = byte(2 * len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint16(out[1+2*i:], v)
+		}
+		return out, 0
+
+	case modbus.FuncWriteSingleCoil, modbus.FuncWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, modbus.ExceptionIllegalDataValue
+		}
+		addr, v := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		table := HoldingRegisters
+		if fc == modbus.FuncWriteSingleCoil {
+			if v != 0xFF00 && v != 0x0000 {
+				return nil, modbus.ExceptionIllegalDataValue
+			}
+			table, v = Coils, v>>15
+		}
+		cells, code := d.cellsLocked(table, addr, 1)
+		if code != 0 {
+			return nil, code
+		}
+		cells[0].write(v)
+		d.writes.Add(1)
+		return append([]byte(nil), data...), 0
+
+	case modbus.FuncWriteMultipleCoils, modbus.FuncWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, modbus.ExceptionIllegalDataValue
+		}
+		addr, count, n := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]), int(data[4])
+		values := data[5:]
+		bits := fc == modbus.FuncWriteMultipleCoils
+		table, limit, want := HoldingRegisters, maxWriteRegisters, 2*int(count)
+		if bits {
+			table, limit, want = Coils, maxWriteBits, (int(count)+7)/8
+		}
+		if count == 0 || int(count) > limit || n != want || len(values) != n {
+			return nil, modbus.ExceptionIllegalDataValue
+		}
+		cells, code := d.cellsLocked(table, addr, count)
+		if code != 0 {
+			return nil, code
+		}
+		for i, c := range cells {
+			if bits {
+				c.write(uint16(values[i/8]>>(i%8)) & 1)
+			} else {
+				c.write(binary.BigEndian.Uint16(values[2*i:]))
+			}
+		}
+		d.writes.Add(1)
+		return append([]byte(nil), data[:4]...), 0
+	}
+	return nil, modbus.ExceptionIllegalFunction
+}
+
+// cellsLocked returns the cells of a range of addresses. A range that leaves
+// the configured registers is an illegal address, as on a real device; a
+// register set to answer with an exception answers the whole request so.
+func (d *Device) cellsLocked(table Table, addr, count uint16) ([]*cell, byte) {
+	if int(addr)+int(count) > 0x10000 {
+		return nil, modbus.ExceptionIllegalDataAddress
+	}
+	cells := make([]*cell, count)
+	for i := range cells {
+		c, ok := d.cells[cellKey{table, addr + uint16(i)}]
+		if !ok {
+			return nil, modbus.ExceptionIllegalDataAddress
+		}
+		if c.Exception != 0 {
+			return nil, c.Exception
+		}
+		cells[i] = c
+	}
+	return cells, 0
+}
+
+// readTable returns the table a read function reads, and whether it holds
+// bits.
+func readTable(fc byte) (Table, bool) {
+	switch fc {
+	case modbus.FuncReadCoils:
+		return Coils, true
+	case modbus.FuncReadDiscreteInputs:
+		return DiscreteInputs, true
+	case modbus.FuncReadInputRegisters:
+		return InputRegisters, false
+	}
+	return HoldingRegisters, false
+}
+
+// packBits packs values into bits, the first in the lowest bit of the first
+// byte.
+func packBits(values []uint16) []byte {
+	out := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v != 0 {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+// Package simulator is a virtual Modbus device: a register bank held in
+// memory that a proxy can use as its target. Registers hold static values,
+// follow a waveform or replay recorded values; writes stay until the device
+// is built anew. The quirks of real devices — one session only, a minimum
+// gap between requests, lost requests, exceptions — can be switched on, so
+// the device is a safe stand-in for an inverter while an integration is
+// developed against it.
+package simulator
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Table is one of the four Modbus data tables.
+type Table string
+
+const (
+	HoldingRegisters Table = "holding"
+	InputRegisters   Table = "input"
+	Coils            Table = "coil"
+	DiscreteInputs   Table = "discrete"
+)
+
+// Kind says how a register gets its value.
+type Kind string
+
+const (
+	Static     Kind = "static"      // Value, until written
+	Sine       Kind = "sine"        // Between Min and Max, one cycle per Period
+	Ramp       Kind = "ramp"        // From Min up to Max in Period, then again
+	RandomWalk Kind = "random_walk" // From Value by up to Step per Interval, kept within Min and Max
+	Replay     Kind = "replay"      // Samples in turn, over and over
+)
+
+// Defaults for the timing of registers left at zero.
+const (
+	DefaultPeriod   = time.Minute
+	DefaultInterval = time.Second
+)
+
+// maxWalkSteps bounds how many steps a random walk catches up on at once,
+// after the device was not asked for a long time.
+const maxWalkSteps = 10000
+
+// Register describes one register, or a block of them that behave alike.
+// Values are 16 bits; negative ones are sent in two's complement. A coil or
+// discrete input is on when its value is not zero.
+type Register struct {
+	Table     Table
+	Address   uint16
+	Count     uint16 // Consecutive addresses that behave alike (0 = 1)
+	Kind      Kind   // Empty means Static
+	Value     int
+	Min, Max  int
+	Period    time.Duration // Sine and ramp: one cycle (0 = DefaultPeriod); replay: one pass (0 = last sample + Interval)
+	Step      int           // Random walk: largest change per interval (0 = 1)
+	Interval  time.Duration // Random walk and replay: how long a value holds (0 = DefaultInterval)
+	Samples   []Sample      // Replay: the values, by time since the start
+	Exception byte          // Answer every access with this exception code instead (0 = none)
+}
+
+// Sample is a value a replayed register takes at a time since the start.
+type Sample struct {
+	At    time.Duration
+	Value int
+}
+
+// Quirks are the habits of real devices the virtual one imitates.
+type Quirks struct {
+	SingleSession bool          // Answer one connection only; the others get no answers until it closes
+	MinGap        time.Duration // Leave requests unanswered that come sooner than this after the last answer
+	DropRate      float64       // Share of requests left unanswered (0-1)
+	ExceptionRate float64       // Share of requests answered with ExceptionCode (0-1)
+	ExceptionCode byte          // Exception of ExceptionRate (0 = server device busy)
+}
+
+// Stats counts what the device did with the requests it got.
+type Stats struct {
+	Requests   int64 `json:"requests"`
+	Answered   int64 `json:"answered"`
+	Dropped    int64 `json:"dropped"`    // Left unanswered by a quirk
+	Exceptions int64 `json:"exceptions"` // Answered with an exception, by a quirk or a register
+	Writes     int64 `json:"writes"`
+	Sessions   int   `json:"sessions"` // Connections open right now
+}
+
+type cellKey struct {
+	table Table
+	addr  uint16
+}
+
+// cell is the state of one address.
+type cell struct {
+	Register
+	walk   int       // Current value of a random walk
+	walkAt time.Time // When the walk last stepped
+}
+
+// Device is a virtual Modbus device. It answers any unit ID.
+type Device struct {
+	quirks Quirks
+	start  time.Time
+	now    func() time.Time
+
+	mu        sync.Mutex
+	cells     map[cellKey]*cell
+	rand      *rand.Rand
+	lastReply time.Time             // When the last answer went out, for MinGap
+	session   net.Conn              // The connection served with SingleSession
+	conns     map[net.Conn]struct{} // Server ends of the open connections
+	lastPort  int                   // Numbers the client ends
+	wg        sync.WaitGroup
+
+	requests, answered, dropped, exceptions, writes atomic.Int64
+}
+
+// New builds a device from its registers. Where two describe the same
+// address, the later one counts.
+func New(registers []Register, quirks Quirks) *Device {
+	now := time.Now()
+	d := &Device{
+		quirks: quirks,
+		start:  now,
+		now:    time.Now,
+		cells:  make(map[cellKey]*cell),
+		rand:   rand.New(rand.NewSource(now.UnixNano())),
+		conns:  make(map[net.Conn]struct{}),
+	}
+	for _, r := range registers {
+		if r.Kind == "" {
+			r.Kind = Static
+		}
+		if r.Table == "" {
+			r.Table = HoldingRegisters
+		}
+		if r.Kind == Replay && len(r.Samples) > 0 {
+			r.Samples = append([]Sample(nil), r.Samples...)
+			sort.SliceStable(r.Samples, func(i, j int) bool { return r.Samples[i].At < r.Samples[j].At })
+		}
+		count := max(int(r.Count), 1)
+		for i := 0; i < count && int(r.Address)+i <= math.MaxUint16; i++ {
+			c := &cell{Register: r, walk: r.Value, walkAt: now}
+			c.Address, c.Count = r.Address+uint16(i), 1
+			d.cells[cellKey{r.Table, c.Address}] = c
+		}
+	}
+	return d
+}
+
+// Value returns what a register reads as right now.
+func (d *Device) Value(table Table, addr uint16) (uint16, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.cells[cellKey{table, addr}]
+	if !ok {
+		return 0, false
+	}
+	return d.valueLocked(c, d.now()), true
+}
+
+// Stats returns the counters of the device.
+func (d *Device) Stats() Stats {
+	d.mu.Lock()
+	sessions := len(d.conns)
+	d.mu.Unlock()
+	return Stats{
+		Requests:   d.requests.Load(),
+		Answered:   d.answered.Load(),
+		Dropped:    d.dropped.Load(),
+		Exceptions: d.exceptions.Load(),
+		Writes:     d.writes.Load(),
+		Sessions:   sessions,
+	}
+}
+
+// valueLocked works out the value of a cell at a time.
+func (d *Device) valueLocked(c *cell, now time.Time) uint16 {
+	elapsed := now.Sub(d.start)
+	period := c.Period
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	v := c.Value
+	switch c.Kind {
+	case Sine:
+		phase := 2 * math.Pi * float64(elapsed%period) / float64(period)
+		mid, amp := float64(c.Min+c.Max)/2, float64(c.Max-c.Min)/2
+		v = int(math.Round(mid + amp*math.Sin(phase)))
+	case Ramp:
+		v = c.Min + int(float64(c.Max-c.Min)*float64(elapsed%period)/float64(period))
+	case RandomWalk:
+		step := c.Step
+		if step <= 0 {
+			step = 1
+		}
+		steps := int(now.Sub(c.walkAt) / interval)
+		for i := 0; i < steps && i < maxWalkSteps; i++ {
+			c.walk += d.rand.Intn(2*step+1) - step
+			c.walk = min(max(c.walk, c.Min), c.Max)
+		}
+		c.walkAt = c.walkAt.Add(time.Duration(steps) * interval)
+		v = c.walk
+	case Replay:
+		v = c.replayed(elapsed, interval)
+	}
+	return uint16(v) // Two's complement for negative values
+}
+
+// replayed returns the sample of a replayed register at a time since the
+// start. A pass lasts Period, or until the last sample has held for one
+// interval; then it starts over.
+func (c *cell) replayed(elapsed, interval time.Duration) int {
+	if len(c.Samples) == 0 {
+		return c.Value
+	}
+	cycle := c.Period
+	if cycle <= 0 {
+		cycle = c.Samples[len(c.Samples)-1].At + interval
+	}
+	t := elapsed % cycle
+	i := sort.Search(len(c.Samples), func(i int) bool { return c.Samples[i].At > t })
+	if i == 0 {
+		// Before the first sample the last one of the pass before still holds.
+		return c.Samples[len(c.Samples)-1].Value
+	}
+	return c.Samples[i-1].Value
+}
+
+// write stores a value the way a device keeps what it was told: from now on
+// the register reads as that value.
+func (c *cell) write(v uint16) {
+	c.Kind, c.Value, c.Samples = Static, int(v), nil
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package simulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"modbridge/pkg/capture"
+	"modbridge/pkg/modbus"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a device clock that only moves when told to.
+type clock struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.at
+}
+
+func (c *clock) set(at time.Time) {
+	c.mu.Lock()
+	c.at = at
+	c.mu.Unlock()
+}
+
+func (c *clock) advance(by time.Duration) { c.set(c.now().Add(by)) }
+
+func setQuirks(d *Device, q Quirks) {
+	d.mu.Lock()
+	d.quirks = q
+	d.mu.Unlock()
+}
+
+func newTestDevice(registers []Register, quirks Quirks) (*Device, *clock) {
+	d := New(registers, quirks)
+	c := &clock{at: d.start}
+	d.now = c.now
+	for _, cell := range d.cells {
+		cell.walkAt = d.start
+	}
+	return d, c
+}
+
+// exchange sends a request and returns the answer, or nil if none comes.
+func exchange(t *testing.T, conn net.Conn, req []byte) []byte {
+	t.Helper()
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := modbus.ReadFrame(conn)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+func readRegisters(t *testing.T, conn net.Conn, fc uint8, addr, count uint16) []uint16 {
+	t.Helper()
+	resp := exchange(t, conn, modbus.CreateReadRequest(1, 1, fc, addr, count))
+	data, err := modbus.ParseReadResponse(resp)
+	if err != nil {
+		t.Fatalf("read of %d at %d: %v (% x)", count, addr, err, resp)
+	}
+	values := make([]uint16, len(data)/2)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return values
+}
+
+func exceptionCode(resp []byte) byte {
+	if !modbus.IsExceptionResponse(resp) {
+		return 0
+	}
+	return resp[8]
+}
+
+func TestWaveforms(t *testing.T) {
+	d, c := newTestDevice([]Register{
+		{Table: InputRegisters, Address: 0, Kind: Sine, Min: -100, Max: 100, Period: 4 * time.Second},
+		{Table: InputRegisters, Address: 1, Kind: Ramp, Min: 0, Max: 1000, Period: 10 * time.Second},
+		{Table: InputRegisters, Address: 2, Kind: RandomWalk, Value: 50, Min: 45, Max: 55, Step: 2},
+		{Table: InputRegisters, Address: 3, Kind: Replay, Interval: time.Second, Samples: []Sample{{0, 7}, {time.Second, 8}, {2 * time.Second, 9}}},
+	}, Quirks{})
+
+	value := func(addr uint16) int {
+		v, ok := d.Value(InputRegisters, addr)
+		if !ok {
+			t.Fatalf("no register at %d", addr)
+		}
+		return int(int16(v))
+	}
+	tests := []struct {
+		at    time.Duration
+		addr  uint16
+		value int
+	}{
+		{0, 0, 0},
+		{time.Second, 0, 100},
+		{3 * time.Second, 0, -100},
+		{5 * time.Second, 0, 100}, // The second cycle
+		{0, 1, 0},
+		{5 * time.Second, 1, 500},
+		{12 * time.Second, 1, 200},
+		{0, 3, 7},
+		{1500 * time.Millisecond, 3, 8},
+		{2 * time.Second, 3, 9},
+		{3 * time.Second, 3, 7}, // Over again
+	}
+	for _, tt := range tests {
+		c.set(d.start.Add(tt.at))
+		if got := value(tt.addr); got != tt.value {
+			t.Errorf("register %d after %v = %d, want %d", tt.addr, tt.at, got, tt.value)
+		}
+	}
+
+	c.set(d.start)
+	last := value(2)
+	for i := 1; i <= 200; i++ {
+		c.set(d.start.Add(time.Duration(i) * time.Second))
+		v := value(2)
+		if v < 45 || v > 55 || v-last > 2 || last-v > 2 {
+			t.Fatalf("random walk went from %d to %d in a step", last, v)
+		}
+		last = v
+	}
+}
+
+func TestReadsAndWritesPersist(t *testing.T) {
+	d, c := newTestDevice([]Register{
+		{Address: 100, Count: 4, Value: 42},
+		{Address: 200, Kind: Ramp, Min: 0, Max: 100},
+		{Table: Coils, Address: 0, Count: 10},
+		{Table: InputRegisters, Address: 300, Value: 0xFFFF},
+	}, Quirks{})
+	conn := d.Connect()
+	defer conn.Close()
+
+	if got := readRegisters(t, conn, 3, 100, 4); len(got) != 4 || got[3] != 42 {
+		t.Fatalf("holding registers = %v, want four times 42", got)
+	}
+
+	write, _ := modbus.CreateWriteRequest(2, 1, 101, []uint16{7, 8})
+	if resp := exchange(t, conn, write); !bytes.Equal(resp[8:12], write[8:12]) {
+		t.Fatalf("write answered % x", resp)
+	}
+	single := []byte{0, 3, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 0, 200, 0, 99}
+	if resp := exchange(t, conn, single); !bytes.Equal(resp, single) {
+		t.Fatalf("single write answered % x, want the request echoed", resp)
+	}
+	coils := []byte{0, 4, 0, 0, 0, 8, 1, modbus.FuncWriteMultipleCoils, 0, 2, 0, 3, 1, 0b101}
+	if resp := exchange(t, conn, coils); exceptionCode(resp) != 0 {
+		t.Fatalf("coil write answered % x", resp)
+	}
+
+	// Time goes by; what was written stays, the ramp included.
+	c.advance(30 * time.Second)
+	if got := readRegisters(t, conn, 3, 100, 4); got[1] != 7 || got[2] != 8 || got[0] != 42 {
+		t.Errorf("holding registers after the write = %v", got)
+	}
+	if got := readRegisters(t, conn, 3, 200, 1); got[0] != 99 {
+		t.Errorf("written ramp register = %d, want 99", got[0])
+	}
+	resp := exchange(t, conn, modbus.CreateReadRequest(5, 1, modbus.FuncReadCoils, 0, 10))
+	if data, err := modbus.ParseReadResponse(resp); err != nil || !bytes.Equal(data, []byte{0b10100, 0}) {
+		t.Errorf("coils = % x (%v), want 14 00", data, err)
+	}
+	if got := readRegisters(t, conn, 4, 300, 1); got[0] != 0xFFFF {
+		t.Errorf("input register = %d, want 65535", got[0])
+	}
+
+	for name, req := range map[string][]byte{
+		"read past the block":         modbus.CreateReadRequest(6, 1, 3, 102, 3),
+		"write to an input register":  {0, 7, 0, 0, 0, 6, 1, modbus.FuncWriteSingleRegister, 1, 44, 0, 1},
+		"read of an unknown register": modbus.CreateReadRequest(8, 1, 4, 0, 1),
+	} {
+		if code := exceptionCode(exchange(t, conn, req)); code != modbus.ExceptionIllegalDataAddress {
+			t.Errorf("%s: exception %d, want illegal data address", name, code)
+		}
+	}
+	if s := d.Stats(); s.Writes != 3 || s.Sessions != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestQuirks(t *testing.T) {
+	read := modbus.CreateReadRequest(1, 1, 3, 0, 1)
+
+	t.Run("single session", func(t *testing.T) {
+		d, _ := newTestDevice([]Register{{Address: 0}}, Quirks{SingleSession: true})
+		first, second := d.Connect(), d.Connect()
+		defer second.Close()
+		if exchange(t, first, read) == nil {
+			t.Fatal("the first session got no answer")
+		}
+		if exchange(t, second, read) != nil {
+			t.Fatal("a second session was answered")
+		}
+		first.Close()
+		deadline := time.Now().Add(5 * time.Second)
+		for exchange(t, second, read) == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("the second session was not answered once the first was closed")
+			}
+		}
+	})
+
+	t.Run("minimum gap", func(t *testing.T) {
+		d, c := newTestDevice([]Register{{Address: 0}}, Quirks{MinGap: time.Second})
+		conn := d.Connect()
+		defer conn.Close()
+		if exchange(t, conn, read) == nil {
+			t.Fatal("the first request got no answer")
+		}
+		c.advance(500 * time.Millisecond)
+		if exchange(t, conn, read) != nil {
+			t.Fatal("a request within the gap was answered")
+		}
+		c.advance(time.Second)
+		if exchange(t, conn, read) == nil {
+			t.Fatal("a request after the gap got no answer")
+		}
+	})
+
+	t.Run("drops and exceptions", func(t *testing.T) {
+		d, _ := newTestDevice([]Register{{Address: 0}, {Address: 1, Exception: modbus.ExceptionSlaveDeviceFailure}}, Quirks{DropRate: 1})
+		conn := d.Connect()
+		defer conn.Close()
+		if exchange(t, conn, read) != nil {
+			t.Fatal("a request was answered at a drop rate of 1")
+		}
+		setQuirks(d, Quirks{ExceptionRate: 1})
+		if code := exceptionCode(exchange(t, conn, read)); code != modbus.ExceptionServerDeviceBusy {
+			t.Fatalf("exception %d at an exception rate of 1, want server device busy", code)
+		}
+		setQuirks(d, Quirks{})
+		if code := exceptionCode(exchange(t, conn, modbus.CreateReadRequest(2, 1, 3, 0, 2))); code != modbus.ExceptionSlaveDeviceFailure {
+			t.Fatalf("exception %d for a register set to fail, want slave device failure", code)
+		}
+		if s := d.Stats(); s.Requests != 3 || s.Dropped != 1 || s.Exceptions != 2 {
+			t.Errorf("stats = %+v", s)
+		}
+	})
+}
+
+func TestFromCapture(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var frames []capture.Frame
+	for i, value := range []byte{7, 8, 8} {
+		txID := uint16(i + 1)
+		req := modbus.CreateReadRequest(txID, 1, 4, 100, 2)
+		resp, _ := modbus.CreateReadResponse(txID, 1, 4, []byte{0, value, 0, 1})
+		sent := at.Add(time.Duration(i) * time.Second)
+		frames = append(frames,
+			capture.Frame{Time: sent, Side: capture.SideClient, Src: "192.168.1.20:50000", Dst: "192.168.1.2:5020", Data: req},
+			capture.Frame{Time: sent.Add(10 * time.Millisecond), Side: capture.SideClient, Response: true, Src: "192.168.1.2:5020", Dst: "192.168.1.20:50000", Data: resp},
+		)
+	}
+
+	registers := FromCapture(frames)
+	if len(registers) != 2 {
+		t.Fatalf("FromCapture() = %+v, want two registers", registers)
+	}
+	r := registers[0]
+	want := []Sample{{0, 7}, {time.Second, 8}}
+	if r.Table != InputRegisters || r.Address != 100 || r.Kind != Replay || r.Period != 3*time.Second ||
+		len(r.Samples) != 2 || r.Samples[0] != want[0] || r.Samples[1] != want[1] {
+		t.Fatalf("register 100 = %+v, want samples %v over 3s", r, want)
+	}
+
+	d, c := newTestDevice(registers, Quirks{})
+	for _, step := range []struct {
+		at    time.Duration
+		value uint16
+	}{{0, 7}, {1500 * time.Millisecond, 8}, {2500 * time.Millisecond, 8}, {3 * time.Second, 7}} {
+		c.set(d.start.Add(step.at))
+		if v, _ := d.Value(InputRegisters, 100); v != step.value {
+			t.Errorf("replayed register after %v = %d, want %d", step.at, v, step.value)
+		}
+	}
+}
@@ -208,6 +208,106 @@ func TestProxyIntegrationMultipleConnections(t *testing.T) {
 	}
 }
 
+// TestProxyIntegrationIdenticalConcurrentReads tests that identical reads
+// from several connections at once share one request to the device, and that
+// every connection gets the answer under its own transaction ID
+func TestProxyIntegrationIdenticalConcurrentReads(t *testing.T) {
+	mockConfig := mockmodbus.DefaultConfig()
+	mockConfig.Port = 15041
+	mockServer := mockmodbus.NewMockServer(mockConfig)
+
+	err := mockServer.Start()
+	if err != nil {
+		t.Fatalf("Failed to start mock server: %v", err)
+	}
+	defer func() { _ = mockServer.Stop() }()
+	// Slow enough that all reads are in flight together
+	mockServer.SetDelay(300 * time.Millisecond)
+
+	time.Sleep(100 * time.Millisecond)
+
+	cfgMgr := config.NewManager("test_config.json")
+	log := logger.NewNullLogger(100)
+	db, _ := database.NewDB(":memory:")
+	mgr := manager.NewManager(cfgMgr, log, db)
+
+	proxyCfg := config.ProxyConfig{
+		ID:            "test-proxy-coalesce",
+		Name:          "Test Coalescing Proxy",
+		ListenAddr:    ":15042",
+		TargetAddr:    mockServer.GetAddress(),
+		CoalesceReads: true,
+		Enabled:       true,
+	}
+
+	err = mgr.AddProxy(proxyCfg, false)
+	if err != nil {
+		t.Fatalf("Failed to add proxy: %v", err)
+	}
+
+	err = mgr.StartProxy(proxyCfg.ID)
+	if err != nil {
+		t.Fatalf("Failed to start proxy: %v", err)
+	}
+	defer func() { _ = mgr.StopProxy(proxyCfg.ID) }()
+
+	time.Sleep(200 * time.Millisecond)
+
+	const numConnections = 5
+	conns := make([]net.Conn, numConnections)
+
+	for i := 0; i < numConnections; i++ {
+		conn, err := net.Dial("tcp", "127.0.0.1:15042")
+		if err != nil {
+			t.Fatalf("Failed to create connection %d: %v", i, err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	// The same read from every connection, each with a transaction ID of its own
+	for i, conn := range conns {
+		request := []byte{
+			0x00, byte(i + 1), // Transaction ID
+			0x00, 0x00, // Protocol ID
+			0x00, 0x06, // Length
+			0x01,       // Unit ID
+			0x03,       // Function code
+			0x00, 0x00, // Address
+			0x00, 0x02, // Quantity
+		}
+		_, err := conn.Write(request)
+		if err != nil {
+			t.Fatalf("Failed to send request from connection %d: %v", i, err)
+		}
+	}
+
+	expected := []byte{0x00, 0x00, 0x00, 0x07, 0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02}
+	for i, conn := range conns {
+		response := make([]byte, 256)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(response)
+		if err != nil {
+			t.Fatalf("Failed to read response from connection %d: %v", i, err)
+		}
+
+		if n != 13 {
+			t.Fatalf("Unexpected response length from connection %d: % X", i, response[:n])
+		}
+		if response[0] != 0x00 || response[1] != byte(i+1) {
+			t.Errorf("Connection %d got transaction ID %02X%02X, expected %04X", i, response[0], response[1], i+1)
+		}
+		if string(response[2:n]) != string(expected) {
+			t.Errorf("Connection %d got % X, expected the registers 0001 0002", i, response[:n])
+		}
+	}
+
+	requestLog := mockServer.GetRequestLog()
+	if len(requestLog) != 1 {
+		t.Errorf("Expected the identical reads to share 1 request, got %d", len(requestLog))
+	}
+}
+
 // TestProxyIntegrationErrorHandling tests proxy error handling
 func TestProxyIntegrationErrorHandling(t *testing.T) {
 	// Try to connect to non-existent Modbus device
@@ -3,256 +3,466 @@
 // Created by Xerolux
 // https://github.com/Xerolux/modbridge
 
+// Package timeseries records the history of data points in the database, so
+// that what a device reported can be looked at after the fact. Every reading
+// is kept for a short while; one-minute and one-hour summaries of them are
+// kept much longer.
 package timeseries
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"math"
-	"os"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// DataPoint represents a time-series data point
-type DataPoint struct {
-	ProxyID         string
-	RegisterAddress int
-	Value           float64
-	RawValue        uint16
-	Unit            string
-	Quality         string
-	Timestamp       time.Time
-}
+// Resolutions of the history.
+const (
+	ResolutionRaw    = "raw" // Every reading
+	ResolutionMinute = "1m"
+	ResolutionHour   = "1h"
+	ResolutionAuto   = "auto" // The finest resolution that suits the time range
+)
 
-// Manager manages time-series data
-type Manager struct {
-	mu            sync.RWMutex
-	data          map[string][]*DataPoint // key: proxyID:registerAddress
-	maxPoints     int
-	retention     time.Duration
-	filePath      string
-	autoFlush     bool
-	flushInterval time.Duration
+// buckets is the width in seconds of each stored resolution.
+var buckets = map[string]int64{
+	ResolutionRaw:    0,
+	ResolutionMinute: 60,
+	ResolutionHour:   3600,
 }
 
-// NewManager creates a new time-series manager
-func NewManager(maxPoints int, retention time.Duration, filePath string) *Manager {
-	m := &Manager{
-		data:          make(map[string][]*DataPoint),
-		maxPoints:     maxPoints,
-		retention:     retention,
-		filePath:      filePath,
-		autoFlush:     true,
-		flushInterval: 5 * time.Minute,
-	}
-	go m.autoFlushLoop()
-	go m.cleanupOldData()
-	return m
-}
+// maxBuffered bounds the readings held while the database cannot be
+// written. The oldest go first.
+const maxBuffered = 100000
 
-// Add adds a data point
-func (m *Manager) Add(point *DataPoint) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// maxQueryPoints bounds the points one query returns.
+const maxQueryPoints = 100000
 
-	key := fmt.Sprintf("%s:%d", point.ProxyID, point.RegisterAddress)
-	series := m.data[key]
-	series = append(series, point)
+// Config describes how long the history is kept and how often it is
+// written.
+type Config struct {
+	RawRetention    time.Duration // Default: 48h
+	MinuteRetention time.Duration // Default: 30 days
+	HourRetention   time.Duration // Default: 365 days
+	FlushInterval   time.Duration // Default: 1 minute
+}
 
-	// Keep only maxPoints
-	if len(series) > m.maxPoints {
-		series = series[len(series)-m.maxPoints:]
-	}
+// Reading is the current value of one data point. Err is set instead of
+// Value when it could not be read.
+type Reading struct {
+	Point string
+	Value interface{}
+	Err   string
+}
 
-	m.data[key] = series
+// Source is where the history gets its readings from.
+type Source interface {
+	// ReadPoints reads every data point of a proxy.
+	ReadPoints(proxyID string) ([]Reading, error)
 }
 
-// Query retrieves data points for a specific register
-func (m *Manager) Query(proxyID string, registerAddress int, start, end time.Time) []*DataPoint {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// DataPoint is one point of a history: a reading, or the summary of the
+// readings in one bucket. Value is the reading or their average, and nil
+// when there was none that succeeded.
+type DataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     *float64  `json:"value"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+	Count     int       `json:"count"`            // Readings with a value
+	Errors    int       `json:"errors,omitempty"` // Readings that failed
+	Error     string    `json:"error,omitempty"`  // Why a single reading failed
+}
 
-	key := fmt.Sprintf("%s:%d", proxyID, registerAddress)
-	series := m.data[key]
+// Manager records the data points of the proxies after every refresh of
+// their pollers, like the MQTT publisher: the readings come from the cache
+// the poller has just filled, so recording costs no device traffic.
+//
+// Readings are collected in memory and written in one transaction per
+// FlushInterval. On flash storage that is the difference between one write
+// a minute and one per point per poll round.
+type Manager struct {
+	db     *database.DB
+	source Source
+	cfg    Config
+	log    *logger.Logger
+
+	mu      sync.Mutex
+	buffer  []database.PointSample // Readings not yet written
+	pending map[string]bool        // Proxies refreshed since the last pass
+	wake    chan struct{}
+
+	recorded atomic.Int64
+	written  atomic.Int64
+	dropped  atomic.Int64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
 
-	result := make([]*DataPoint, 0)
-	for _, point := range series {
-		if (start.IsZero() || point.Timestamp.After(start)) &&
-			(end.IsZero() || point.Timestamp.Before(end)) {
-			result = append(result, point)
-		}
+// NewManager creates a history. It records nothing until Start.
+func NewManager(db *database.DB, source Source, cfg Config, log *logger.Logger) *Manager {
+	if cfg.RawRetention <= 0 {
+		cfg.RawRetention = 48 * time.Hour
+	}
+	if cfg.MinuteRetention <= 0 {
+		cfg.MinuteRetention = 30 * 24 * time.Hour
+	}
+	if cfg.HourRetention <= 0 {
+		cfg.HourRetention = 365 * 24 * time.Hour
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Minute
+	}
+	return &Manager{
+		db:      db,
+		source:  source,
+		cfg:     cfg,
+		log:     log,
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
 	}
-
-	return result
 }
 
-// GetLatest returns the latest data point
-func (m *Manager) GetLatest(proxyID string, registerAddress int) *DataPoint {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	key := fmt.Sprintf("%s:%d", proxyID, registerAddress)
-	series := m.data[key]
+// Start records in the background until Stop.
+func (m *Manager) Start() {
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.wg.Add(1)
+	go m.run(ctx)
+}
 
-	if len(series) == 0 {
-		return nil
+// Stop stops recording and writes what is still held.
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
 	}
+	m.wg.Wait()
+}
 
-	return series[len(series)-1]
+// Refreshed tells the history that a proxy's poller has finished a round.
+// It never blocks.
+func (m *Manager) Refreshed(proxyID string) {
+	m.mu.Lock()
+	m.pending[proxyID] = true
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
 }
 
-// GetAggregatedData returns aggregated statistics
-func (m *Manager) GetAggregatedData(proxyID string, registerAddress int, start, end time.Time) map[string]interface{} {
-	points := m.Query(proxyID, registerAddress, start, end)
+func (m *Manager) run(ctx context.Context) {
+	defer m.wg.Done()
 
-	if len(points) == 0 {
-		return nil
+	flush := time.NewTicker(m.cfg.FlushInterval)
+	defer flush.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	m.cleanup()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.Flush(); err != nil {
+				m.log.Error("HISTORY", fmt.Sprintf("Failed to write history on shutdown: %v", err))
+			}
+			return
+		case <-m.wake:
+			m.recordPending()
+		case <-flush.C:
+			if err := m.Flush(); err != nil {
+				m.log.Warn("HISTORY", fmt.Sprintf("Failed to write history: %v", err))
+			}
+		case <-cleanup.C:
+			m.cleanup()
+		}
 	}
+}
 
-	sum := 0.0
-	min := points[0].Value
-	max := points[0].Value
-	count := len(points)
-
-	for _, point := range points {
-		sum += point.Value
-		if point.Value < min {
-			min = point.Value
+// recordPending reads the points of the proxies refreshed since the last
+// pass.
+func (m *Manager) recordPending() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string]bool)
+	m.mu.Unlock()
+
+	for proxyID := range pending {
+		readings, err := m.source.ReadPoints(proxyID)
+		if err != nil {
+			continue
 		}
-		if point.Value > max {
-			max = point.Value
+		now := time.Now()
+		for _, r := range readings {
+			m.Add(proxyID, r, now)
 		}
 	}
+}
 
-	avg := sum / float64(count)
-
-	// Calculate standard deviation
-	variance := 0.0
-	for _, point := range points {
-		diff := point.Value - avg
-		variance += diff * diff
+// Add records one reading. Only numbers are recorded, and failures: a
+// string has no history worth a chart, and a disabled point no reading.
+func (m *Manager) Add(proxyID string, r Reading, at time.Time) {
+	s := database.PointSample{ProxyID: proxyID, Point: r.Point, Time: at}
+	if r.Err != "" {
+		s.Errors, s.Error = 1, r.Err
+	} else if v, ok := r.Value.(float64); ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		s.Count, s.Sum, s.Min, s.Max = 1, v, v, v
+	} else {
+		return
 	}
-	variance /= float64(count)
-	stdDev := math.Sqrt(variance)
 
-	return map[string]interface{}{
-		"count":  count,
-		"min":    min,
-		"max":    max,
-		"avg":    avg,
-		"stddev": stdDev,
-		"sum":    sum,
-		"start":  start,
-		"end":    end,
+	m.mu.Lock()
+	if len(m.buffer) >= maxBuffered {
+		m.buffer = m.buffer[1:]
+		m.dropped.Add(1)
 	}
+	m.buffer = append(m.buffer, s)
+	m.mu.Unlock()
+	m.recorded.Add(1)
 }
 
-// ExportCSV exports data to CSV format
-func (m *Manager) ExportCSV(proxyID string, registerAddress int, start, end time.Time) (string, error) {
-	points := m.Query(proxyID, registerAddress, start, end)
-
-	if m.filePath != "" {
-		fileName := fmt.Sprintf("%s_%d_%s.csv", proxyID, registerAddress, time.Now().Format("20060102_150405"))
-		if m.filePath != "" {
-			fileName = fmt.Sprintf("%s/%s", m.filePath, fileName)
-		}
-
-		file, err := os.Create(fileName)
-		if err != nil {
-			return "", err
-		}
-		defer file.Close()
-
-		writer := csv.NewWriter(file)
-		defer writer.Flush()
+// Flush writes the buffered readings together with their one-minute and
+// one-hour summaries. If that fails they stay buffered for the next try.
+func (m *Manager) Flush() error {
+	m.mu.Lock()
+	raw := m.buffer
+	m.buffer = nil
+	m.mu.Unlock()
+	if len(raw) == 0 {
+		return nil
+	}
 
-		if err := writer.Write([]string{"Timestamp", "Value", "RawValue", "Unit", "Quality"}); err != nil {
-			return "", fmt.Errorf("failed to write CSV header: %w", err)
+	if err := m.db.SavePointSamples(append(raw, summarize(raw)...)); err != nil {
+		m.mu.Lock()
+		m.buffer = append(raw, m.buffer...)
+		if excess := len(m.buffer) - maxBuffered; excess > 0 {
+			m.buffer = m.buffer[excess:]
+			m.dropped.Add(int64(excess))
 		}
+		m.mu.Unlock()
+		return err
+	}
+	m.written.Add(int64(len(raw)))
+	return nil
+}
 
-		for _, point := range points {
-			if err := writer.Write([]string{
-				point.Timestamp.Format(time.RFC3339),
-				fmt.Sprintf("%.6f", point.Value),
-				fmt.Sprintf("%d", point.RawValue),
-				point.Unit,
-				point.Quality,
-			}); err != nil {
-				return "", fmt.Errorf("failed to write CSV row: %w", err)
+// summarize folds readings into the buckets of every coarser resolution.
+// The database merges them with what a bucket already holds.
+func summarize(raw []database.PointSample) []database.PointSample {
+	type key struct {
+		proxyID, point string
+		bucket         int64
+		start          int64
+	}
+	index := make(map[key]int)
+	var out []database.PointSample
+	for _, s := range raw {
+		for _, width := range []int64{buckets[ResolutionMinute], buckets[ResolutionHour]} {
+			start := s.Time.Truncate(time.Duration(width) * time.Second)
+			k := key{s.ProxyID, s.Point, width, start.UnixMilli()}
+			i, ok := index[k]
+			if !ok {
+				index[k] = len(out)
+				out = append(out, database.PointSample{ProxyID: s.ProxyID, Point: s.Point, Bucket: width, Time: start})
+				i = len(out) - 1
+			}
+			b := &out[i]
+			if s.Count > 0 {
+				if b.Count == 0 || s.Min < b.Min {
+					b.Min = s.Min
+				}
+				if b.Count == 0 || s.Max > b.Max {
+					b.Max = s.Max
+				}
+				b.Count += s.Count
+				b.Sum += s.Sum
 			}
+			b.Errors += s.Errors
 		}
+	}
+	return out
+}
 
-		writer.Flush()
-		if err := writer.Error(); err != nil {
-			return "", fmt.Errorf("failed to flush CSV writer: %w", err)
+// cleanup removes what is past the retention of its resolution.
+func (m *Manager) cleanup() {
+	now := time.Now()
+	for resolution, keep := range map[string]time.Duration{
+		ResolutionRaw:    m.cfg.RawRetention,
+		ResolutionMinute: m.cfg.MinuteRetention,
+		ResolutionHour:   m.cfg.HourRetention,
+	} {
+		if _, err := m.db.DeletePointSamples(buckets[resolution], now.Add(-keep)); err != nil {
+			m.log.Warn("HISTORY", fmt.Sprintf("Failed to remove old %s history: %v", resolution, err))
 		}
-
-		return fileName, nil
 	}
-
-	return "", fmt.Errorf("file path not configured")
 }
 
-// cleanupOldData removes data older than retention period
-func (m *Manager) cleanupOldData() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
+// Resolve picks the resolution for a query: the one asked for, or for
+// "auto" (or nothing) the finest that still holds the whole range and gives
+// a chart a sensible number of points.
+func (m *Manager) Resolve(resolution string, start, end time.Time) (string, error) {
+	switch resolution {
+	case ResolutionRaw, ResolutionMinute, ResolutionHour:
+		return resolution, nil
+	case "", ResolutionAuto:
+	default:
+		return "", fmt.Errorf("unknown resolution %q, use raw, 1m, 1h or auto", resolution)
+	}
+	age := time.Since(start)
+	span := end.Sub(start)
+	switch {
+	case span <= 6*time.Hour && age <= m.cfg.RawRetention:
+		return ResolutionRaw, nil
+	case span <= 7*24*time.Hour && age <= m.cfg.MinuteRetention:
+		return ResolutionMinute, nil
+	}
+	return ResolutionHour, nil
+}
 
-	for range ticker.C {
+// Query returns the history of one point from start to end, oldest first.
+// Raw readings not yet written are included; the summaries of the current
+// minute and hour are complete only once they are.
+func (m *Manager) Query(proxyID, point, resolution string, start, end time.Time) ([]DataPoint, error) {
+	bucket, ok := buckets[resolution]
+	if !ok {
+		return nil, fmt.Errorf("unknown resolution %q", resolution)
+	}
+	samples, err := m.db.QueryPointSamples(proxyID, point, bucket, start, end, maxQueryPoints)
+	if err != nil {
+		return nil, err
+	}
+	if resolution == ResolutionRaw {
 		m.mu.Lock()
-		cutoff := time.Now().Add(-m.retention)
-		for key, series := range m.data {
-			newSeries := make([]*DataPoint, 0)
-			for _, point := range series {
-				if point.Timestamp.After(cutoff) {
-					newSeries = append(newSeries, point)
-				}
-			}
-			if len(newSeries) > 0 {
-				m.data[key] = newSeries
-			} else {
-				delete(m.data, key)
+		for _, s := range m.buffer {
+			if s.ProxyID == proxyID && s.Point == point && !s.Time.Before(start) && s.Time.Before(end) && len(samples) < maxQueryPoints {
+				samples = append(samples, s)
 			}
 		}
 		m.mu.Unlock()
 	}
-}
 
-// autoFlushLoop periodically flushes data to disk
-func (m *Manager) autoFlushLoop() {
-	if !m.autoFlush {
-		return
+	points := make([]DataPoint, len(samples))
+	for i, s := range samples {
+		p := DataPoint{Timestamp: s.Time, Count: s.Count, Errors: s.Errors, Error: s.Error}
+		if s.Count > 0 {
+			avg := s.Sum / float64(s.Count)
+			p.Value = &avg
+			if bucket > 0 {
+				min, max := s.Min, s.Max
+				p.Min, p.Max = &min, &max
+			}
+		}
+		points[i] = p
 	}
+	return points, nil
+}
 
-	ticker := time.NewTicker(m.flushInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		m.Flush()
+// GetAggregatedData returns statistics of one point from start to end,
+// computed at the resolution "auto" picks. Nil when there is no history.
+func (m *Manager) GetAggregatedData(proxyID, point string, start, end time.Time) (map[string]interface{}, error) {
+	resolution, err := m.Resolve(ResolutionAuto, start, end)
+	if err != nil {
+		return nil, err
+	}
+	points, err := m.Query(proxyID, point, resolution, start, end)
+	if err != nil {
+		return nil, err
 	}
+	return Aggregate(points), nil
 }
 
-// Flush flushes all data to disk
-func (m *Manager) Flush() error {
-	// Implement file-based persistence if needed
-	return nil
+// Aggregate summarizes points: how many readings there were and failed,
+// and the smallest, largest and average value. The average weighs each
+// summary by its readings. Nil when there are no points.
+func Aggregate(points []DataPoint) map[string]interface{} {
+	if len(points) == 0 {
+		return nil
+	}
+	count, errors := 0, 0
+	sum := 0.0
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		errors += p.Errors
+		if p.Value == nil {
+			continue
+		}
+		count += p.Count
+		sum += *p.Value * float64(p.Count)
+		lo, hi := *p.Value, *p.Value
+		if p.Min != nil {
+			lo, hi = *p.Min, *p.Max
+		}
+		min, max = math.Min(min, lo), math.Max(max, hi)
+	}
+	stats := map[string]interface{}{
+		"count":  count,
+		"errors": errors,
+		"start":  points[0].Timestamp,
+		"end":    points[len(points)-1].Timestamp,
+	}
+	if count > 0 {
+		stats["min"], stats["max"], stats["avg"] = min, max, sum/float64(count)
+	}
+	return stats
 }
 
-// GetStats returns time-series statistics
-func (m *Manager) GetStats() map[string]interface{} {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// ExportCSV writes the history of one point from start to end as CSV: one
+// row per point, with min and max empty for raw readings.
+func (m *Manager) ExportCSV(w io.Writer, proxyID, point, resolution string, start, end time.Time) error {
+	points, err := m.Query(proxyID, point, resolution, start, end)
+	if err != nil {
+		return err
+	}
 
-	totalPoints := 0
-	for _, series := range m.data {
-		totalPoints += len(series)
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"timestamp", "value", "min", "max", "count", "errors", "error"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	number := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+	for _, p := range points {
+		if err := writer.Write([]string{
+			p.Timestamp.Format(time.RFC3339Nano),
+			number(p.Value),
+			number(p.Min),
+			number(p.Max),
+			strconv.Itoa(p.Count),
+			strconv.Itoa(p.Errors),
+			p.Error,
+		}); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
 	}
+	writer.Flush()
+	return writer.Error()
+}
 
+// GetStats returns how many readings were recorded, written and dropped,
+// and how many wait to be written.
+func (m *Manager) GetStats() map[string]interface{} {
+	m.mu.Lock()
+	buffered := len(m.buffer)
+	m.mu.Unlock()
 	return map[string]interface{}{
-		"total_series": len(m.data),
-		"total_points": totalPoints,
-		"max_points":   m.maxPoints,
-		"retention":    m.retention.String(),
+		"enabled":          true,
+		"recorded":         m.recorded.Load(),
+		"written":          m.written.Load(),
+		"dropped":          m.dropped.Load(),
+		"buffered":         buffered,
+		"raw_retention":    m.cfg.RawRetention.String(),
+		"minute_retention": m.cfg.MinuteRetention.String(),
+		"hour_retention":   m.cfg.HourRetention.String(),
 	}
 }
@@ -0,0 +1,182 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package timeseries
+
+import (
+	"bytes"
+	"modbridge/pkg/database"
+	"modbridge/pkg/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSource serves fixed readings for every proxy.
+type testSource struct {
+	readings []Reading
+}
+
+func (s testSource) ReadPoints(proxyID string) ([]Reading, error) {
+	return s.readings, nil
+}
+
+func newTestManager(t *testing.T, source Source) *Manager {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewManager(db, source, Config{}, logger.NewNullLogger(100))
+}
+
+func TestFlushWritesEveryResolution(t *testing.T) {
+	m := newTestManager(t, nil)
+	start := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	// Three readings in the first minute, one failure and one more in the
+	// second; a string is not recorded.
+	m.Add("p1", Reading{Point: "power", Value: 100.0}, start)
+	m.Add("p1", Reading{Point: "power", Value: 300.0}, start.Add(20*time.Second))
+	m.Add("p1", Reading{Point: "power", Value: 200.0}, start.Add(40*time.Second))
+	m.Add("p1", Reading{Point: "power", Err: "timeout"}, start.Add(60*time.Second))
+	m.Add("p1", Reading{Point: "power", Value: 50.0}, start.Add(80*time.Second))
+	m.Add("p1", Reading{Point: "serial", Value: "SN123"}, start)
+
+	// Raw readings are queryable before they are written...
+	raw, err := m.Query("p1", "power", ResolutionRaw, start, start.Add(time.Hour))
+	if err != nil || len(raw) != 5 {
+		t.Fatalf("Query(raw) before flush = %d points, %v; want 5", len(raw), err)
+	}
+
+	// ...and after, from the database, in two flushes whose summaries merge.
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	m.Add("p1", Reading{Point: "power", Value: 0.0}, start.Add(90*time.Second))
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	raw, err = m.Query("p1", "power", ResolutionRaw, start, start.Add(time.Hour))
+	if err != nil || len(raw) != 6 {
+		t.Fatalf("Query(raw) = %d points, %v; want 6", len(raw), err)
+	}
+	if raw[3].Value != nil || raw[3].Error != "timeout" || raw[3].Errors != 1 {
+		t.Errorf("failed reading = %+v, want its error and no value", raw[3])
+	}
+
+	minutes, err := m.Query("p1", "power", ResolutionMinute, start, start.Add(time.Hour))
+	if err != nil || len(minutes) != 2 {
+		t.Fatalf("Query(1m) = %d points, %v; want 2", len(minutes), err)
+	}
+	if first := minutes[0]; first.Count != 3 || *first.Value != 200 || *first.Min != 100 || *first.Max != 300 {
+		t.Errorf("first minute = count %d, avg %v, min %v, max %v; want 3, 200, 100, 300", first.Count, *first.Value, *first.Min, *first.Max)
+	}
+	if second := minutes[1]; second.Count != 2 || second.Errors != 1 || *second.Value != 25 || *second.Min != 0 {
+		t.Errorf("second minute = %+v, want two readings averaging 25 and one error", second)
+	}
+
+	hours, err := m.Query("p1", "power", ResolutionHour, start, start.Add(time.Hour))
+	if err != nil || len(hours) != 1 || hours[0].Count != 5 || hours[0].Errors != 1 || *hours[0].Value != 130 {
+		t.Errorf("Query(1h) = %+v, %v; want one bucket of 5 readings averaging 130", hours, err)
+	}
+
+	if stats := Aggregate(minutes); stats["count"] != 5 || stats["errors"] != 1 || stats["min"] != 0.0 || stats["max"] != 300.0 || stats["avg"] != 130.0 {
+		t.Errorf("Aggregate() = %v", stats)
+	}
+	if stats := Aggregate(nil); stats != nil {
+		t.Errorf("Aggregate(nil) = %v, want nil", stats)
+	}
+}
+
+func TestRecordsRefreshedProxies(t *testing.T) {
+	m := newTestManager(t, testSource{readings: []Reading{{Point: "power", Value: 42.0}}})
+	m.Start()
+	m.Refreshed("p1")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for m.recorded.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Stop writes what is buffered.
+	m.Stop()
+
+	samples, err := m.db.QueryPointSamples("p1", "power", 0, time.Now().Add(-time.Minute), time.Now().Add(time.Minute), 10)
+	if err != nil || len(samples) != 1 || samples[0].Sum != 42 {
+		t.Errorf("stored samples = %+v, %v; want the one reading", samples, err)
+	}
+}
+
+func TestCleanupRemovesExpiredHistory(t *testing.T) {
+	m := newTestManager(t, nil)
+	old := time.Now().Add(-72 * time.Hour)
+	m.Add("p1", Reading{Point: "power", Value: 1.0}, old)
+	m.Add("p1", Reading{Point: "power", Value: 2.0}, time.Now())
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	m.cleanup()
+
+	// The raw reading is past the 48 hours, its summaries are not.
+	raw, _ := m.Query("p1", "power", ResolutionRaw, old.Add(-time.Hour), time.Now().Add(time.Minute))
+	if len(raw) != 1 || *raw[0].Value != 2 {
+		t.Errorf("raw history after cleanup = %d points, want only the recent one", len(raw))
+	}
+	minutes, _ := m.Query("p1", "power", ResolutionMinute, old.Add(-time.Hour), time.Now().Add(time.Minute))
+	if len(minutes) != 2 {
+		t.Errorf("minute history after cleanup = %d points, want 2", len(minutes))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m := NewManager(nil, nil, Config{}, nil)
+	now := time.Now()
+	tests := []struct {
+		resolution string
+		from       time.Duration
+		want       string
+		wantErr    bool
+	}{
+		{"", time.Hour, ResolutionRaw, false},
+		{"auto", 24 * time.Hour, ResolutionMinute, false},
+		{"auto", 60 * time.Hour, ResolutionMinute, false}, // Raw would be gone
+		{"auto", 30 * 24 * time.Hour, ResolutionHour, false},
+		{"1h", time.Hour, ResolutionHour, false},
+		{"5m", time.Hour, "", true},
+	}
+	for _, tt := range tests {
+		got, err := m.Resolve(tt.resolution, now.Add(-tt.from), now)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Resolve(%q, last %v) = %q, %v; want %q", tt.resolution, tt.from, got, err, tt.want)
+		}
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	m := newTestManager(t, nil)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.Add("p1", Reading{Point: "power", Value: 1.5}, at)
+	m.Add("p1", Reading{Point: "power", Err: "exception 2"}, at.Add(time.Second))
+
+	var buf bytes.Buffer
+	if err := m.ExportCSV(&buf, "p1", "power", ResolutionRaw, at, at.Add(time.Minute)); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"timestamp,value,min,max,count,errors,error",
+		at.Local().Format(time.RFC3339Nano) + ",1.5,,,1,0,",
+		at.Add(time.Second).Local().Format(time.RFC3339Nano) + ",,,,0,1,exception 2",
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Errorf("ExportCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
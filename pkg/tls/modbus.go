@@ -0,0 +1,262 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Modbus/TCP Security (the Modbus Organization's "MB-TCP-Security" spec)
+// is Modbus TCP inside TLS 1.2 or later, on port 802, with certificates on
+// both sides. A client certificate may carry a role in an extension of its
+// own; the server maps roles to what a client may do.
+
+// ModbusRoleOID identifies the role extension of a Modbus/TCP Security client
+// certificate. Its value is an ASN.1 UTF8String.
+var ModbusRoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// ModbusServerConfig is the TLS side of a Modbus/TCP Security listener.
+type ModbusServerConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // CA that signs the client certificates
+	ClientAuth   string // verify-cert (default), verify-ca, require, request or none
+	MinVersion   string // 1.2 (default) or 1.3
+}
+
+// TLSConfig loads the certificates and returns the server configuration.
+func (c *ModbusServerConfig) TLSConfig() (*tls.Config, error) {
+	clientAuth := c.ClientAuth
+	if clientAuth == "" {
+		clientAuth = "verify-cert"
+	}
+	minVersion := c.MinVersion
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	m, err := NewManager(&Config{
+		CertFile:       c.CertFile,
+		KeyFile:        c.KeyFile,
+		ClientCAFile:   c.ClientCAFile,
+		ClientAuth:     clientAuth,
+		MinVersion:     minVersion,
+		MaxVersion:     "1.3",
+		SessionTickets: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m.cert == nil {
+		return nil, errors.New("a server certificate and key are required")
+	}
+	if m.clientCAs == nil && (clientAuth == "verify-cert" || clientAuth == "verify-ca") {
+		return nil, fmt.Errorf("client auth %s needs a client CA", clientAuth)
+	}
+
+	cfg, err := m.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.VerifyPeerCertificate != nil && cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		// A client that is not asked for a certificate may come without one.
+		verify := cfg.VerifyPeerCertificate
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return nil
+			}
+			return verify(rawCerts, chains)
+		}
+	}
+	return cfg, nil
+}
+
+// ModbusClientConfig is the TLS side of a connection to a Modbus/TCP
+// Security device.
+type ModbusClientConfig struct {
+	CAFile             string // CA that signs the device's certificate (empty = system roots)
+	CertFile           string // Certificate to present to devices that ask for one
+	KeyFile            string
+	ServerName         string // Name checked against the device's certificate (empty = host of the address)
+	InsecureSkipVerify bool   // Accept any device certificate
+}
+
+// TLSConfig loads the certificates and returns the client configuration for
+// a device on host.
+func (c *ModbusClientConfig) TLSConfig(host string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402 -- opt-in for devices with self-signed certificates
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	if c.CAFile != "" {
+		caCert, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate")
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// ModbusRole returns the role a client certificate carries. ok is false when
+// it has none.
+func ModbusRole(cert *x509.Certificate) (role string, ok bool, err error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(ModbusRoleOID) {
+			continue
+		}
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &role, "utf8"); err != nil {
+			return "", false, fmt.Errorf("invalid role extension: %w", err)
+		}
+		return role, true, nil
+	}
+	return "", false, nil
+}
+
+// LoadCertificateInfo reads the first certificate of a PEM file.
+func LoadCertificateInfo(certFile string) (*CertInfo, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate in %s", certFile)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		x509Cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return newCertInfo(x509Cert), nil
+	}
+}
+
+// GenerateModbusCA generates a CA for Modbus/TCP Security client
+// certificates. Its key is ECDSA P-256, as are those of the certificates it
+// issues.
+func GenerateModbusCA(commonName string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"ModBridge"},
+			CommonName:   commonName,
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return encodeCertificate(&template, &template, privateKey, privateKey)
+}
+
+// GenerateModbusClientCert issues a client certificate signed by a CA from
+// GenerateModbusCA. A non-empty role goes into the Modbus role extension.
+func GenerateModbusClientCert(caCertPEM, caKeyPEM []byte, commonName, role string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	caBlock, _ := pem.Decode(caCertPEM)
+	if caBlock == nil {
+		return nil, nil, errors.New("no CA certificate")
+	}
+	caCert, err := x509.ParseCertificate(caBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	keyBlock, _ := pem.Decode(caKeyPEM)
+	if keyBlock == nil {
+		return nil, nil, errors.New("no CA key")
+	}
+	caKey, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	signer, ok := caKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("CA key cannot sign")
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"ModBridge"},
+			CommonName:   commonName,
+		},
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(validFor),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if role != "" {
+		value, err := asn1.MarshalWithParams(role, "utf8")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode role: %w", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: ModbusRoleOID, Value: value}}
+	}
+
+	return encodeCertificate(&template, caCert, privateKey, signer)
+}
+
+// encodeCertificate signs a certificate for key and returns both as PEM.
+func encodeCertificate(template, parent *x509.Certificate, key *ecdsa.PrivateKey, signer crypto.Signer) (certPEM, keyPEM []byte, err error) {
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+	return certPEM, keyPEM, nil
+}
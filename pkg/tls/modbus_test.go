@@ -0,0 +1,101 @@
+// Copyright (c) 2026 Xerolux. All rights reserved.
+// ModBridge — Modbus TCP Proxy Manager
+// Created by Xerolux
+// https://github.com/Xerolux/modbridge
+
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModbusRoleRoundTrip(t *testing.T) {
+	caCert, caKey, err := GenerateModbusCA("ca", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+
+	for _, role := range []string{"operator", ""} {
+		certPEM, _, err := GenerateModbusClientCert(caCert, caKey, "scada", role, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to generate client cert: %v", err)
+		}
+		block, _ := pem.Decode(certPEM)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse client cert: %v", err)
+		}
+
+		got, ok, err := ModbusRole(cert)
+		if err != nil {
+			t.Fatalf("ModbusRole() error = %v", err)
+		}
+		if ok != (role != "") || got != role {
+			t.Errorf("ModbusRole() = %q, %v, want %q", got, ok, role)
+		}
+	}
+}
+
+func TestModbusServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := SaveCertificates(dir, "localhost", time.Hour); err != nil {
+		t.Fatalf("Failed to save certificates: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+
+	// Verifying client certificates takes a CA.
+	if _, err := (&ModbusServerConfig{CertFile: certFile, KeyFile: keyFile}).TLSConfig(); err == nil {
+		t.Error("Expected an error for verify-cert without a client CA")
+	}
+
+	cfg, err := (&ModbusServerConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}).TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("ClientAuth = %v, want RequireAndVerifyClientCert", cfg.ClientAuth)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", cfg.MinVersion)
+	}
+
+	cfg, err = (&ModbusServerConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "none", MinVersion: "1.3"}).TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+	if cfg.ClientAuth != tls.NoClientCert || cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("ClientAuth = %v, MinVersion = %x, want none and TLS 1.3", cfg.ClientAuth, cfg.MinVersion)
+	}
+}
+
+func TestLoadCertificateInfo(t *testing.T) {
+	dir := t.TempDir()
+	if err := SaveCertificates(dir, "gateway", 40*24*time.Hour); err != nil {
+		t.Fatalf("Failed to save certificates: %v", err)
+	}
+
+	info, err := LoadCertificateInfo(filepath.Join(dir, "server.crt"))
+	if err != nil {
+		t.Fatalf("LoadCertificateInfo() error = %v", err)
+	}
+	if info.Subject != "gateway" {
+		t.Errorf("Subject = %q, want gateway", info.Subject)
+	}
+	if days := info.DaysUntilExpiry(); days < 39 || days > 40 {
+		t.Errorf("DaysUntilExpiry() = %d, want 39-40", days)
+	}
+
+	empty := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(empty, []byte("no certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertificateInfo(empty); err == nil {
+		t.Error("Expected an error for a file without a certificate")
+	}
+}
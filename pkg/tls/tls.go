@@ -372,7 +372,12 @@ func (m *Manager) GetCertificateInfo() (*CertInfo, error) {
 		return nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
-	info := &CertInfo{
+	return newCertInfo(x509Cert), nil
+}
+
+// newCertInfo describes a parsed certificate.
+func newCertInfo(x509Cert *x509.Certificate) *CertInfo {
+	return &CertInfo{
 		Subject:            x509Cert.Subject.CommonName,
 		Issuer:             x509Cert.Issuer.CommonName,
 		NotBefore:          x509Cert.NotBefore,
@@ -383,8 +388,6 @@ func (m *Manager) GetCertificateInfo() (*CertInfo, error) {
 		SerialNumber:       x509Cert.SerialNumber.String(),
 		SignatureAlgorithm: x509Cert.SignatureAlgorithm.String(),
 	}
-
-	return info, nil
 }
 
 // CertInfo holds certificate information
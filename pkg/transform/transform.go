@@ -192,15 +192,93 @@ func (t *Transformer) TransformRegister(value uint16, config *TransformConfig) (
 		return 0, fmt.Errorf("unknown transformation type: %d", config.Type)
 	}
 
-	// Apply clamping
-	if config.MinValue != nil && result < *config.MinValue {
-		result = *config.MinValue
+	return config.clamp(result), nil
+}
+
+// TransformValue transforms a value that has already been decoded from one
+// or more registers, such as an int32 or float32 data point. Byte swapping is
+// part of decoding and not available here; map and custom functions take the
+// value as an unsigned integer and reject anything else.
+func (t *Transformer) TransformValue(value float64, config *TransformConfig) (float64, error) {
+	if config == nil {
+		return value, nil
 	}
-	if config.MaxValue != nil && result > *config.MaxValue {
-		result = *config.MaxValue
+
+	asUint := func() (uint64, error) {
+		if value < 0 || value != math.Trunc(value) || value > math.MaxUint64 {
+			return 0, fmt.Errorf("value %v is not an unsigned integer", value)
+		}
+		return uint64(value), nil
 	}
 
-	return result, nil
+	var result float64
+
+	switch config.Type {
+	case TransformNone:
+		result = value
+
+	case TransformScale:
+		result = value * config.Scale
+
+	case TransformLinear:
+		result = config.Slope*value + config.Intercept
+
+	case TransformMap:
+		raw, err := asUint()
+		if err != nil {
+			return 0, err
+		}
+		mapped, ok := config.Map[raw]
+		if !ok {
+			return 0, fmt.Errorf("no mapping found for value %d", raw)
+		}
+		result = mapped
+
+	case TransformToInt:
+		result = value
+		if config.Precision > 0 {
+			result = value / math.Pow(10, float64(config.Precision))
+		}
+
+	case TransformToFloat:
+		result = value
+		if config.Precision > 0 {
+			roundTo := math.Pow(10, float64(config.Precision))
+			result = math.Round(result*roundTo) / roundTo
+		}
+
+	case TransformCustom:
+		fn, ok := t.registry.Get(config.CustomFunc)
+		if !ok {
+			return 0, fmt.Errorf("custom function '%s' not found", config.CustomFunc)
+		}
+		raw, err := asUint()
+		if err != nil {
+			return 0, err
+		}
+		if result, err = fn(raw); err != nil {
+			return 0, fmt.Errorf("custom function failed: %w", err)
+		}
+
+	case TransformSwap:
+		return 0, errors.New("byte swapping applies to raw registers only")
+
+	default:
+		return 0, fmt.Errorf("unknown transformation type: %d", config.Type)
+	}
+
+	return config.clamp(result), nil
+}
+
+// clamp limits a result to the configured minimum and maximum.
+func (t *TransformConfig) clamp(result float64) float64 {
+	if t.MinValue != nil && result < *t.MinValue {
+		result = *t.MinValue
+	}
+	if t.MaxValue != nil && result > *t.MaxValue {
+		result = *t.MaxValue
+	}
+	return result
 }
 
 // TransformRegisterArray transforms multiple register values
@@ -248,32 +326,58 @@ func (t *Transformer) InverseTransform(value float64, config *TransformConfig) (
 	if config == nil {
 		return uint16(value), nil
 	}
+	result, err := t.inverse(value, config)
+	if err != nil {
+		return 0, err
+	}
 
-	var result float64
+	// Clamp to valid range
+	if result < 0 {
+		result = 0
+	}
+	if result > math.MaxUint16 {
+		result = math.MaxUint16
+	}
 
+	return uint16(math.Round(result)), nil
+}
+
+// InverseValue turns a value in engineering units back into the raw value
+// that TransformValue would have turned into it, for writing to a device.
+// Unlike InverseTransform, the value is first held to MinValue and MaxValue,
+// so a write can never set what a read would have clamped away. The result is
+// not rounded or bounded to a register: how it is stored is up to the
+// caller's data type.
+func (t *Transformer) InverseValue(value float64, config *TransformConfig) (float64, error) {
+	if config == nil {
+		return value, nil
+	}
+	return t.inverse(config.clamp(value), config)
+}
+
+// inverse undoes the transformation itself, without clamping.
+func (t *Transformer) inverse(value float64, config *TransformConfig) (float64, error) {
 	switch config.Type {
 	case TransformNone:
-		result = value
+		return value, nil
 
 	case TransformScale:
 		if config.Scale == 0 {
 			return 0, errors.New("scale factor cannot be zero for inverse transform")
 		}
-		result = value / config.Scale
+		return value / config.Scale, nil
 
 	case TransformLinear:
 		if config.Slope == 0 {
 			return 0, errors.New("slope cannot be zero for inverse transform")
 		}
-		result = (value - config.Intercept) / config.Slope
+		return (value - config.Intercept) / config.Slope, nil
 
 	case TransformToInt:
 		if config.Precision > 0 {
-			multiplier := math.Pow(10, float64(config.Precision))
-			result = value * multiplier
-		} else {
-			result = value
+			return value * math.Pow(10, float64(config.Precision)), nil
 		}
+		return value, nil
 
 	case TransformSwap, TransformToFloat, TransformMap, TransformCustom:
 		return 0, fmt.Errorf("inverse transform not supported for type %d", config.Type)
@@ -281,16 +385,6 @@ func (t *Transformer) InverseTransform(value float64, config *TransformConfig) (
 	default:
 		return 0, fmt.Errorf("unknown transformation type: %d", config.Type)
 	}
-
-	// Clamp to valid range
-	if result < 0 {
-		result = 0
-	}
-	if result > math.MaxUint16 {
-		result = math.MaxUint16
-	}
-
-	return uint16(math.Round(result)), nil
 }
 
 // TransformCoil transforms a coil value (boolean)
@@ -386,6 +386,24 @@ func TestInverseTransform(t *testing.T) {
 	}
 }
 
+func TestInverseValue(t *testing.T) {
+	trans := NewTransformer()
+	min, max := -50.0, 80.0
+	config := &TransformConfig{Type: TransformScale, Scale: 0.1, MinValue: &min, MaxValue: &max}
+
+	// Negative results are kept for signed registers.
+	if raw, err := trans.InverseValue(-12.5, config); err != nil || math.Abs(raw+125) > 1e-9 {
+		t.Errorf("InverseValue(-12.5) = %v, %v; want -125", raw, err)
+	}
+	// Values beyond the limits are clamped before the inversion.
+	if raw, err := trans.InverseValue(95, config); err != nil || math.Abs(raw-800) > 1e-9 {
+		t.Errorf("InverseValue(95) = %v, %v; want 800", raw, err)
+	}
+	if _, err := trans.InverseValue(1, &TransformConfig{Type: TransformCustom, CustomFunc: "x"}); err == nil {
+		t.Error("Expected error for unsupported inverse transform")
+	}
+}
+
 func TestTransformCoil(t *testing.T) {
 	trans := NewTransformer()
 
@@ -850,3 +868,39 @@ func TestDeviceTransformConfig_Coils(t *testing.T) {
 		t.Error("Expected no transform for coil 10")
 	}
 }
+
+func TestTransformValue(t *testing.T) {
+	tr := NewTransformer()
+	if err := tr.Register("negate", func(v uint64) (float64, error) { return -float64(v), nil }); err != nil {
+		t.Fatalf("Failed to register function: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   float64
+		config  *TransformConfig
+		want    float64
+		wantErr bool
+	}{
+		{"nil config", -12.5, nil, -12.5, false},
+		{"scale on a negative int32", -1200, &TransformConfig{Type: TransformScale, Scale: 0.1}, -120, false},
+		{"linear on a float", 20.5, &TransformConfig{Type: TransformLinear, Slope: 1.8, Intercept: 32}, 68.9, false},
+		{"map of a status code", 3, &TransformConfig{Type: TransformMap, Map: map[uint64]float64{3: 1}}, 1, false},
+		{"map of a fraction", 3.5, &TransformConfig{Type: TransformMap, Map: map[uint64]float64{3: 1}}, 0, true},
+		{"custom", 7, &TransformConfig{Type: TransformCustom, CustomFunc: "negate"}, -7, false},
+		{"swap", 7, &TransformConfig{Type: TransformSwap}, 0, true},
+		{"clamped", 150, &TransformConfig{Type: TransformNone, MaxValue: ptrFloat64(100)}, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tr.TransformValue(tt.value, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransformValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("TransformValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}